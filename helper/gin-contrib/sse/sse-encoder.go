@@ -22,6 +22,7 @@ const ContentType = "text/event-stream"
 
 var contentType = []string{ContentType}
 var noCache = []string{"no-cache"}
+var keepAlive = []string{"keep-alive"}
 
 var fieldReplacer = strings.NewReplacer(
 	"\n", "\\n",
@@ -98,6 +99,9 @@ func (r Event) WriteContentType(w http.ResponseWriter) {
 	if _, exist := header["Cache-Control"]; !exist {
 		header["Cache-Control"] = noCache
 	}
+	if _, exist := header["Connection"]; !exist {
+		header["Connection"] = keepAlive
+	}
 }
 
 func kindOfData(data interface{}) reflect.Kind {
@@ -0,0 +1,78 @@
+// Copyright 2014 Manu Martinez-Almeida.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package gin
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// fakeCertManager answers a fixed token to the HTTP-01 challenges and counts
+// the certificates it is asked for.
+type fakeCertManager struct {
+	requested []string
+}
+
+func (m *fakeCertManager) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	m.requested = append(m.requested, hello.ServerName)
+	return &tls.Certificate{}, nil
+}
+
+func (m *fakeCertManager) HTTPHandler(fallback http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/.well-known/acme-challenge/token" {
+			w.Write([]byte("token.thumbprint"))
+			return
+		}
+		fallback.ServeHTTP(w, req)
+	})
+}
+
+func TestAutoTLSChallengeAndRedirect(t *testing.T) {
+	handler := new(fakeCertManager).HTTPHandler(http.HandlerFunc(redirectHTTPS))
+	tests := []struct {
+		method, target string
+		code           int
+		location       string
+	}{
+		{"GET", "http://example.com/.well-known/acme-challenge/token", http.StatusOK, ""},
+		{"GET", "http://example.com:80/api/v1/version?x=1", http.StatusMovedPermanently, "https://example.com/api/v1/version?x=1"},
+		{"HEAD", "http://example.com/", http.StatusMovedPermanently, "https://example.com/"},
+		{"POST", "http://example.com/api/v1/login", http.StatusPermanentRedirect, "https://example.com/api/v1/login"},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(test.method, test.target, nil))
+		if w.Code != test.code || w.Header().Get("Location") != test.location {
+			t.Errorf("%s %s: got %d %q", test.method, test.target, w.Code, w.Header().Get("Location"))
+		}
+	}
+}
+
+func TestAutoTLSHostWhitelist(t *testing.T) {
+	manager := new(fakeCertManager)
+	getCertificate := hostWhitelist([]string{"Example.com", "www.example.com"}, manager.GetCertificate)
+	for _, name := range []string{"example.com", "WWW.example.com", "example.com."} {
+		if _, err := getCertificate(&tls.ClientHelloInfo{ServerName: name}); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+	for _, name := range []string{"", "evil.com", "sub.example.com"} {
+		if _, err := getCertificate(&tls.ClientHelloInfo{ServerName: name}); err == nil {
+			t.Errorf("%s: not refused", name)
+		}
+	}
+	if len(manager.requested) != 3 {
+		t.Errorf("manager asked for %v", manager.requested)
+	}
+}
+
+func TestRunAutoTLSWithoutDomains(t *testing.T) {
+	if err := New().RunAutoTLS(new(fakeCertManager), nil, ""); err == nil {
+		t.Error("no error without domains")
+	}
+}
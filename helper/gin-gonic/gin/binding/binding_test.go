@@ -0,0 +1,54 @@
+// Copyright 2014 Manu Martinez-Almeida.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package binding
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// requiredValidator fails the fields tagged binding:"required" left to their
+// zero value, nested structs included. It stands in for the default
+// validator, the tests check that the bindings validate, not its rules.
+type requiredValidator struct{}
+
+func (requiredValidator) ValidateStruct(obj interface{}) error {
+	return checkRequired(reflect.ValueOf(obj), "")
+}
+
+func (requiredValidator) Engine() interface{} {
+	return nil
+}
+
+func checkRequired(v reflect.Value, prefix string) error {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		name := prefix + field.Name
+		if field.Tag.Get("binding") == "required" && v.Field(i).IsZero() {
+			return fmt.Errorf("%s is required", name)
+		}
+		if err := checkRequired(v.Field(i), name+"."); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// useRequiredValidator sets Validator to a requiredValidator for the test.
+func useRequiredValidator(t *testing.T) {
+	saved := Validator
+	Validator = requiredValidator{}
+	t.Cleanup(func() { Validator = saved })
+}
@@ -6,12 +6,20 @@ package binding
 
 import (
 	"errors"
+	"fmt"
 	"reflect"
 	"strconv"
 	"strings"
 	"time"
 )
 
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// mapForm sets the fields of the struct ptr points to from form. A field
+// absent from form takes its default, given as `form:"name,default=value"` or
+// as `default:"value"`; the default of a slice is split on commas, e.g.
+// `form:"codecs,default=h264,aac"`. A field
+// sent empty, e.g. "?limit=", does not take its default.
 func mapForm(ptr interface{}, form map[string][]string) error {
 	typ := reflect.TypeOf(ptr).Elem()
 	val := reflect.ValueOf(ptr).Elem()
@@ -24,14 +32,15 @@ func mapForm(ptr interface{}, form map[string][]string) error {
 
 		structFieldKind := structField.Kind()
 		inputFieldName := typeField.Tag.Get("form")
-		inputFieldNameList := strings.Split(inputFieldName, ",")
+		inputFieldNameList := strings.SplitN(inputFieldName, ",", 2)
 		inputFieldName = inputFieldNameList[0]
 		var defaultValue string
-		if len(inputFieldNameList) > 1 {
-			defaultList := strings.SplitN(inputFieldNameList[1], "=", 2)
-			if defaultList[0] == "default" {
-				defaultValue = defaultList[1]
-			}
+		// the default runs to the end of the tag, commas of a slice included
+		if len(inputFieldNameList) > 1 && strings.HasPrefix(inputFieldNameList[1], "default=") {
+			defaultValue = strings.TrimPrefix(inputFieldNameList[1], "default=")
+		}
+		if defaultValue == "" {
+			defaultValue = typeField.Tag.Get("default")
 		}
 		if inputFieldName == "" {
 			inputFieldName = typeField.Name
@@ -60,8 +69,11 @@ func mapForm(ptr interface{}, form map[string][]string) error {
 			if defaultValue == "" {
 				continue
 			}
-			inputValue = make([]string, 1)
-			inputValue[0] = defaultValue
+			if structFieldKind == reflect.Slice {
+				inputValue = strings.Split(defaultValue, ",")
+			} else {
+				inputValue = []string{defaultValue}
+			}
 		}
 
 		numElems := len(inputValue)
@@ -100,6 +112,9 @@ func setWithProperType(valueKind reflect.Kind, val string, structField reflect.V
 	case reflect.Int32:
 		return setIntField(val, 32, structField)
 	case reflect.Int64:
+		if structField.Type() == durationType {
+			return setDurationField(val, structField)
+		}
 		return setIntField(val, 64, structField)
 	case reflect.Uint:
 		return setUintField(val, 0, structField)
@@ -175,10 +190,25 @@ func setFloatField(val string, bitSize int, field reflect.Value) error {
 	return err
 }
 
+func setDurationField(val string, field reflect.Value) error {
+	if val == "" {
+		val = "0"
+	}
+	d, err := time.ParseDuration(val)
+	if err == nil {
+		field.SetInt(int64(d))
+	}
+	return err
+}
+
+// setTimeField parses val with the layout of the time_format tag, or as
+// seconds or milliseconds since the epoch with "unix" or "unixmilli". The time
+// is in time_location, UTC with time_utc, else local. An empty val is the zero
+// time.
 func setTimeField(val string, structField reflect.StructField, value reflect.Value) error {
 	timeFormat := structField.Tag.Get("time_format")
 	if timeFormat == "" {
-		return errors.New("Blank time format")
+		return fmt.Errorf("field %s: blank time format", structField.Name)
 	}
 
 	if val == "" {
@@ -194,14 +224,28 @@ func setTimeField(val string, structField reflect.StructField, value reflect.Val
 	if locTag := structField.Tag.Get("time_location"); locTag != "" {
 		loc, err := time.LoadLocation(locTag)
 		if err != nil {
-			return err
+			return fmt.Errorf("field %s: %v", structField.Name, err)
 		}
 		l = loc
 	}
 
-	t, err := time.ParseInLocation(timeFormat, val, l)
-	if err != nil {
-		return err
+	var t time.Time
+	switch timeFormat {
+	case "unix", "unixmilli":
+		n, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return fmt.Errorf("field %s: invalid %s time %q", structField.Name, timeFormat, val)
+		}
+		if timeFormat == "unix" {
+			t = time.Unix(n, 0).In(l)
+		} else {
+			t = time.Unix(n/1000, n%1000*int64(time.Millisecond)).In(l)
+		}
+	default:
+		var err error
+		if t, err = time.ParseInLocation(timeFormat, val, l); err != nil {
+			return fmt.Errorf("field %s: %v", structField.Name, err)
+		}
 	}
 
 	value.Set(reflect.ValueOf(t))
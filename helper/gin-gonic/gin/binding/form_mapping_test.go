@@ -0,0 +1,156 @@
+// Copyright 2014 Manu Martinez-Almeida.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package binding
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type formStream struct {
+	Path    string        `form:"path" binding:"required"`
+	Limit   int           `form:"limit,default=20"`
+	Offset  uint16        `form:"offset"`
+	Rate    float64       `form:"rate" default:"1.5"`
+	Record  bool          `form:"record,default=true"`
+	Public  bool          `form:"public"`
+	Codecs  []string      `form:"codecs,default=h264,aac"`
+	Ports   []int         `form:"ports"`
+	Timeout time.Duration `form:"timeout,default=5s"`
+	Weight  *int          `form:"weight"`
+}
+
+func TestMapFormTypes(t *testing.T) {
+	var obj formStream
+	err := mapForm(&obj, map[string][]string{
+		"path":    {"/live/a"},
+		"limit":   {"50"},
+		"offset":  {"10"},
+		"record":  {"0"},
+		"public":  {"1"},
+		"codecs":  {"h265"},
+		"ports":   {"554", "8554"},
+		"timeout": {"1m"},
+		"weight":  {"3"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	three := 3
+	want := formStream{
+		Path: "/live/a", Limit: 50, Offset: 10, Rate: 1.5, Record: false, Public: true,
+		Codecs: []string{"h265"}, Ports: []int{554, 8554}, Timeout: time.Minute, Weight: &three,
+	}
+	if !reflect.DeepEqual(obj, want) {
+		t.Fatalf("mapped %+v, want %+v", obj, want)
+	}
+}
+
+func TestMapFormDefaults(t *testing.T) {
+	var obj formStream
+	if err := mapForm(&obj, map[string][]string{"path": {"/live/a"}, "limit": {""}}); err != nil {
+		t.Fatal(err)
+	}
+	want := formStream{
+		Path: "/live/a", Limit: 0, Rate: 1.5, Record: true,
+		Codecs: []string{"h264", "aac"}, Timeout: 5 * time.Second,
+	}
+	if !reflect.DeepEqual(obj, want) {
+		t.Fatalf("mapped %+v, want %+v (an empty limit is not defaulted)", obj, want)
+	}
+}
+
+func TestMapFormErrors(t *testing.T) {
+	for _, form := range []map[string][]string{
+		{"limit": {"ten"}},
+		{"offset": {"70000"}},
+		{"public": {"maybe"}},
+		{"ports": {"554", "x"}},
+		{"timeout": {"5"}},
+	} {
+		if err := mapForm(&formStream{}, form); err == nil {
+			t.Errorf("%v: no error", form)
+		}
+	}
+}
+
+func TestFormBindingRequired(t *testing.T) {
+	useRequiredValidator(t)
+	req := httptest.NewRequest("GET", "/?limit=5", nil)
+	var obj formStream
+	err := Form.Bind(req, &obj)
+	if err == nil || !strings.Contains(err.Error(), "Path") {
+		t.Fatalf("err = %v, want Path required", err)
+	}
+	req = httptest.NewRequest("GET", "/?path=/live/a&ports=554&ports=8554", nil)
+	if err := Form.Bind(req, &obj); err != nil || obj.Path != "/live/a" || len(obj.Ports) != 2 {
+		t.Fatalf("bound %+v, %v", obj, err)
+	}
+}
+
+func TestSetTimeField(t *testing.T) {
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Skip(err)
+	}
+	tests := []struct {
+		tag  reflect.StructTag
+		val  string
+		want time.Time
+	}{
+		{`time_format:"2006-01-02 15:04"`, "2018-06-01 08:30", time.Date(2018, 6, 1, 8, 30, 0, 0, time.Local)},
+		{`time_format:"2006-01-02 15:04" time_utc:"1"`, "2018-06-01 08:30", time.Date(2018, 6, 1, 8, 30, 0, 0, time.UTC)},
+		{`time_format:"2006-01-02 15:04" time_location:"Asia/Shanghai"`, "2018-06-01 08:30", time.Date(2018, 6, 1, 8, 30, 0, 0, shanghai)},
+		// time_location wins over time_utc
+		{`time_format:"2006-01-02 15:04" time_utc:"1" time_location:"Asia/Shanghai"`, "2018-06-01 08:30", time.Date(2018, 6, 1, 8, 30, 0, 0, shanghai)},
+		{`time_format:"unix"`, "1527841800", time.Unix(1527841800, 0)},
+		{`time_format:"unix" time_utc:"1"`, "1527841800", time.Unix(1527841800, 0).UTC()},
+		{`time_format:"unixmilli" time_location:"Asia/Shanghai"`, "1527841800123", time.Unix(1527841800, 123*int64(time.Millisecond)).In(shanghai)},
+		{`time_format:"2006-01-02"`, "", time.Time{}},
+	}
+	for _, test := range tests {
+		field := reflect.StructField{Name: "At", Tag: test.tag}
+		var got time.Time
+		if err := setTimeField(test.val, field, reflect.ValueOf(&got).Elem()); err != nil {
+			t.Errorf("%s %q: %v", test.tag, test.val, err)
+			continue
+		}
+		if !got.Equal(test.want) || got.Location().String() != test.want.Location().String() {
+			t.Errorf("%s %q = %v, want %v", test.tag, test.val, got, test.want)
+		}
+	}
+}
+
+func TestSetTimeFieldErrors(t *testing.T) {
+	tests := []struct {
+		tag reflect.StructTag
+		val string
+	}{
+		{``, "2018-06-01"},
+		{`time_format:"2006-01-02"`, "06/01/2018"},
+		{`time_format:"2006-01-02" time_location:"Mars/Olympus"`, "2018-06-01"},
+		{`time_format:"unix"`, "yesterday"},
+		{`time_format:"unixmilli"`, "1.5"},
+	}
+	for _, test := range tests {
+		field := reflect.StructField{Name: "StartedAt", Tag: test.tag}
+		var got time.Time
+		err := setTimeField(test.val, field, reflect.ValueOf(&got).Elem())
+		if err == nil || !strings.HasPrefix(err.Error(), "field StartedAt: ") {
+			t.Errorf("%s %q: err = %v, want the field named", test.tag, test.val, err)
+		}
+	}
+
+	var obj struct {
+		Since time.Time `form:"since" time_format:"unix"`
+	}
+	err := mapForm(&obj, map[string][]string{"since": {"soon"}})
+	if err == nil || !strings.Contains(err.Error(), "field Since") {
+		t.Fatalf("mapForm err = %v, want the field named", err)
+	}
+}
@@ -12,6 +12,13 @@ import (
 	"EasyDarwin/helper/ugorji/go/codec"
 )
 
+// msgpackHandle decodes msgpack raw strings into string rather than []byte
+// when the target is an interface{}. It is set up once and shared, which the
+// codec allows for concurrent decoders.
+var msgpackHandle = &codec.MsgpackHandle{RawToString: true}
+
+// msgpackBinding decodes application/msgpack and application/x-msgpack bodies.
+// Like the JSON binding, an empty body fails with io.EOF.
 type msgpackBinding struct{}
 
 func (msgpackBinding) Name() string {
@@ -27,8 +34,7 @@ func (msgpackBinding) BindBody(body []byte, obj interface{}) error {
 }
 
 func decodeMsgPack(r io.Reader, obj interface{}) error {
-	cdc := new(codec.MsgpackHandle)
-	if err := codec.NewDecoder(r, cdc).Decode(&obj); err != nil {
+	if err := codec.NewDecoder(r, msgpackHandle).Decode(&obj); err != nil {
 		return err
 	}
 	return validate(obj)
@@ -0,0 +1,64 @@
+// Copyright 2017 Manu Martinez-Almeida.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package binding
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"EasyDarwin/helper/gin-gonic/gin/render"
+)
+
+type msgpackStream struct {
+	Path    string      `codec:"path" binding:"required"`
+	Players int         `codec:"players"`
+	Extra   interface{} `codec:"extra"`
+}
+
+// TestMsgPackRoundTrip renders a struct with render.MsgPack and binds the
+// body back.
+func TestMsgPackRoundTrip(t *testing.T) {
+	useRequiredValidator(t)
+	w := httptest.NewRecorder()
+	if err := (render.MsgPack{Data: msgpackStream{Path: "/live/a", Players: 3, Extra: "hd"}}).Render(w); err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest("POST", "/", w.Body)
+	req.Header.Set("Content-Type", w.Header().Get("Content-Type"))
+
+	var obj msgpackStream
+	if err := MsgPack.Bind(req, &obj); err != nil {
+		t.Fatal(err)
+	}
+	if obj.Path != "/live/a" || obj.Players != 3 {
+		t.Fatalf("bound %+v", obj)
+	}
+	// raw strings in an interface{} are strings, not []byte
+	if s, ok := obj.Extra.(string); !ok || s != "hd" {
+		t.Fatalf("extra = %#v, want the string hd", obj.Extra)
+	}
+}
+
+func TestMsgPackValidationError(t *testing.T) {
+	useRequiredValidator(t)
+	w := httptest.NewRecorder()
+	if err := (render.MsgPack{Data: msgpackStream{Players: 3}}).Render(w); err != nil {
+		t.Fatal(err)
+	}
+	var obj msgpackStream
+	err := MsgPack.BindBody(w.Body.Bytes(), &obj)
+	if err == nil || !strings.Contains(err.Error(), "Path") {
+		t.Fatalf("err = %v, want Path required", err)
+	}
+	if obj.Players != 3 {
+		t.Fatalf("players = %d, want the decoded value kept", obj.Players)
+	}
+
+	if err := MsgPack.BindBody(nil, &obj); err != io.EOF {
+		t.Fatalf("empty body err = %v, want io.EOF", err)
+	}
+}
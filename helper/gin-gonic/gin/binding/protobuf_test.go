@@ -0,0 +1,101 @@
+// Copyright 2014 Manu Martinez-Almeida.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package binding
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"EasyDarwin/helper/gin-gonic/gin/render"
+	"EasyDarwin/helper/golang/protobuf/proto"
+)
+
+// streamMsg is what protoc-gen-go writes for
+//
+//	message Stream { optional string path = 1; optional int32 players = 2; }
+type streamMsg struct {
+	Path             *string `protobuf:"bytes,1,opt,name=path" json:"path,omitempty"`
+	Players          *int32  `protobuf:"varint,2,opt,name=players" json:"players,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *streamMsg) Reset()         { *m = streamMsg{} }
+func (m *streamMsg) String() string { return proto.CompactTextString(m) }
+func (*streamMsg) ProtoMessage()    {}
+
+func (m *streamMsg) GetPath() string {
+	if m != nil && m.Path != nil {
+		return *m.Path
+	}
+	return ""
+}
+
+func (m *streamMsg) GetPlayers() int32 {
+	if m != nil && m.Players != nil {
+		return *m.Players
+	}
+	return 0
+}
+
+func TestProtoBufRenderThenBind(t *testing.T) {
+	w := httptest.NewRecorder()
+	msg := &streamMsg{Path: proto.String("/live/a"), Players: proto.Int32(3)}
+	if err := (render.ProtoBuf{Data: msg}).Render(w); err != nil {
+		t.Fatal(err)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/x-protobuf" {
+		t.Fatalf("content type = %s", ct)
+	}
+	req := httptest.NewRequest("POST", "/", w.Body)
+	req.Header.Set("Content-Type", MIMEPROTOBUF2)
+	if b := Default("POST", req.Header.Get("Content-Type")); b != ProtoBuf {
+		t.Fatalf("binding for %s = %s", MIMEPROTOBUF2, b.Name())
+	}
+
+	var obj streamMsg
+	if err := ProtoBuf.Bind(req, &obj); err != nil {
+		t.Fatal(err)
+	}
+	if obj.GetPath() != "/live/a" || obj.GetPlayers() != 3 {
+		t.Fatalf("bound %v", &obj)
+	}
+}
+
+func TestProtoBufBindThenRender(t *testing.T) {
+	body, err := proto.Marshal(&streamMsg{Path: proto.String("/live/b")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var obj streamMsg
+	if err := ProtoBuf.BindBody(body, &obj); err != nil {
+		t.Fatal(err)
+	}
+	obj.Players = proto.Int32(7)
+
+	w := httptest.NewRecorder()
+	if err := (render.ProtoBuf{Data: &obj}).Render(w); err != nil {
+		t.Fatal(err)
+	}
+	var back streamMsg
+	if err := proto.Unmarshal(w.Body.Bytes(), &back); err != nil {
+		t.Fatal(err)
+	}
+	if back.GetPath() != "/live/b" || back.GetPlayers() != 7 {
+		t.Fatalf("rendered %v", &back)
+	}
+}
+
+func TestProtoBufNotAMessage(t *testing.T) {
+	var obj struct{ Path string }
+	err := ProtoBuf.BindBody([]byte{0x0a, 0x01, 'a'}, &obj)
+	if err == nil || !strings.Contains(err.Error(), "is not a proto.Message") {
+		t.Fatalf("bind err = %v", err)
+	}
+	err = (render.ProtoBuf{Data: obj}).Render(httptest.NewRecorder())
+	if err == nil || !strings.Contains(err.Error(), "is not a proto.Message") {
+		t.Fatalf("render err = %v", err)
+	}
+}
@@ -0,0 +1,48 @@
+// Copyright 2018 Manu Martinez-Almeida.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package binding
+
+import (
+	"io/ioutil"
+	"net/http"
+
+	"gopkg.in/yaml.v2"
+)
+
+// yamlBinding decodes YAML documents with gopkg.in/yaml.v2, so struct fields
+// are matched by their `yaml` tags. Anchors and aliases are resolved, yaml.v2
+// caps how far aliases may expand a document. A key given twice in a mapping
+// takes the last value, or is an error along with unknown fields when
+// EnableDecoderDisallowUnknownFields is set. The body is read whole, so it
+// should be capped, e.g. with http.MaxBytesReader; a body over the cap fails
+// the binding with the error of the reader.
+type yamlBinding struct{}
+
+func (yamlBinding) Name() string {
+	return "yaml"
+}
+
+func (yamlBinding) Bind(req *http.Request, obj interface{}) error {
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return err
+	}
+	return decodeYAML(body, obj)
+}
+
+func (yamlBinding) BindBody(body []byte, obj interface{}) error {
+	return decodeYAML(body, obj)
+}
+
+func decodeYAML(body []byte, obj interface{}) error {
+	unmarshal := yaml.Unmarshal
+	if EnableDecoderDisallowUnknownFields {
+		unmarshal = yaml.UnmarshalStrict
+	}
+	if err := unmarshal(body, obj); err != nil {
+		return err
+	}
+	return validate(obj)
+}
@@ -5,7 +5,9 @@
 package gin
 
 import (
+	"bytes"
 	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"math"
@@ -31,6 +33,8 @@ const (
 	MIMEPlain             = binding.MIMEPlain
 	MIMEPOSTForm          = binding.MIMEPOSTForm
 	MIMEMultipartPOSTForm = binding.MIMEMultipartPOSTForm
+	MIMEYAML              = binding.MIMEYAML
+	MIMEPROTOBUF          = binding.MIMEPROTOBUF
 	BodyBytesKey          = "_gin-gonic/gin/bodybyteskey"
 )
 
@@ -57,6 +61,9 @@ type Context struct {
 
 	// Accepted defines a list of manually accepted formats for content negotiation.
 	Accepted []string
+
+	// methods allowed for the path of a 405 or an automatic OPTIONS
+	allowedMethods []string
 }
 
 /************************************/
@@ -71,6 +78,7 @@ func (c *Context) reset() {
 	c.Keys = nil
 	c.Errors = c.Errors[0:0]
 	c.Accepted = nil
+	c.allowedMethods = nil
 }
 
 // Copy returns a copy of the current context that can be safely used outside the request's scope.
@@ -95,6 +103,14 @@ func (c *Context) Handler() HandlerFunc {
 	return c.handlers.Last()
 }
 
+// AllowedMethods returns the methods allowed for the path of the request when
+// it is answered with 405, see Engine.HandleMethodNotAllowed, or by an automatic
+// OPTIONS, see Engine.HandleOPTIONS, e.g. for a NoMethod handler writing them
+// in its body. They are those of the Allow header, nil for other requests.
+func (c *Context) AllowedMethods() []string {
+	return c.allowedMethods
+}
+
 /************************************/
 /*********** FLOW CONTROL ***********/
 /************************************/
@@ -497,6 +513,7 @@ func (c *Context) SaveUploadedFile(file *multipart.FileHeader, dst string) error
 // Depending the "Content-Type" header different bindings are used:
 //     "application/json" --> JSON binding
 //     "application/xml"  --> XML binding
+//     "application/x-yaml", "text/yaml" --> YAML binding
 // otherwise --> returns an error.
 // It parses the request's body as JSON if Content-Type == "application/json" using JSON or XML as a JSON input.
 // It decodes the json payload into the struct specified as a pointer.
@@ -511,6 +528,11 @@ func (c *Context) BindJSON(obj interface{}) error {
 	return c.MustBindWith(obj, binding.JSON)
 }
 
+// BindYAML is a shortcut for c.MustBindWith(obj, binding.YAML).
+func (c *Context) BindYAML(obj interface{}) error {
+	return c.MustBindWith(obj, binding.YAML)
+}
+
 // BindQuery is a shortcut for c.MustBindWith(obj, binding.Query).
 func (c *Context) BindQuery(obj interface{}) error {
 	return c.MustBindWith(obj, binding.Query)
@@ -531,6 +553,7 @@ func (c *Context) MustBindWith(obj interface{}, b binding.Binding) (err error) {
 // Depending the "Content-Type" header different bindings are used:
 //     "application/json" --> JSON binding
 //     "application/xml"  --> XML binding
+//     "application/x-yaml", "text/yaml" --> YAML binding
 // otherwise --> returns an error
 // It parses the request's body as JSON if Content-Type == "application/json" using JSON or XML as a JSON input.
 // It decodes the json payload into the struct specified as a pointer.
@@ -545,6 +568,11 @@ func (c *Context) ShouldBindJSON(obj interface{}) error {
 	return c.ShouldBindWith(obj, binding.JSON)
 }
 
+// ShouldBindYAML is a shortcut for c.ShouldBindWith(obj, binding.YAML).
+func (c *Context) ShouldBindYAML(obj interface{}) error {
+	return c.ShouldBindWith(obj, binding.YAML)
+}
+
 // ShouldBindQuery is a shortcut for c.ShouldBindWith(obj, binding.Query).
 func (c *Context) ShouldBindQuery(obj interface{}) error {
 	return c.ShouldBindWith(obj, binding.Query)
@@ -556,11 +584,20 @@ func (c *Context) ShouldBindWith(obj interface{}, b binding.Binding) error {
 	return b.Bind(c.Request, obj)
 }
 
+// ErrBodyTooLarge is returned by ShouldBindBodyWith for a body over the
+// engine's MaxBodyBytes.
+var ErrBodyTooLarge = errors.New("http: request body too large")
+
 // ShouldBindBodyWith is similar with ShouldBindWith, but it stores the request
-// body into the context, and reuse when it is called again.
+// body into the context, and reuse when it is called again. So middlewares,
+// e.g. for audit logging or signature checking, and the handler can all bind
+// the body, with the same or different bindings and types, as long as each
+// one goes through ShouldBindBodyWith.
 //
 // NOTE: This method reads the body before binding. So you should use
 // ShouldBindWith for better performance if you need to call only once.
+// The whole body is kept in memory until the request is done; it is capped
+// by the engine's MaxBodyBytes.
 func (c *Context) ShouldBindBodyWith(
 	obj interface{}, bb binding.BindingBody,
 ) (err error) {
@@ -571,10 +608,18 @@ func (c *Context) ShouldBindBodyWith(
 		}
 	}
 	if body == nil {
-		body, err = ioutil.ReadAll(c.Request.Body)
+		r := io.Reader(c.Request.Body)
+		max := c.engine.MaxBodyBytes
+		if max > 0 {
+			r = io.LimitReader(r, max+1)
+		}
+		body, err = ioutil.ReadAll(r)
 		if err != nil {
 			return err
 		}
+		if max > 0 && int64(len(body)) > max {
+			return ErrBodyTooLarge
+		}
 		c.Set(BodyBytesKey, body)
 	}
 	return bb.BindBody(body, obj)
@@ -773,6 +818,12 @@ func (c *Context) YAML(code int, obj interface{}) {
 	c.Render(code, render.YAML{Data: obj})
 }
 
+// ProtoBuf serializes the given proto.Message as ProtoBuf into the response body.
+// It also sets the Content-Type as "application/x-protobuf".
+func (c *Context) ProtoBuf(code int, obj interface{}) {
+	c.Render(code, render.ProtoBuf{Data: obj})
+}
+
 // String writes the given string into the response body.
 func (c *Context) String(code int, format string, values ...interface{}) {
 	c.Render(code, render.String{Format: format, Data: values})
@@ -796,6 +847,8 @@ func (c *Context) Data(code int, contentType string, data []byte) {
 }
 
 // DataFromReader writes the specified reader into the body stream and updates the HTTP code.
+// A negative contentLength is an unknown length: no Content-Length is sent and
+// the body is streamed until the reader ends.
 func (c *Context) DataFromReader(code int, contentLength int64, contentType string, reader io.Reader, extraHeaders map[string]string) {
 	c.Render(code, render.Reader{
 		Headers:       extraHeaders,
@@ -806,11 +859,85 @@ func (c *Context) DataFromReader(code int, contentLength int64, contentType stri
 }
 
 // File writes the specified file into the body stream in a efficient way.
+// Range requests are answered with 206 and the requested bytes, and
+// conditional requests (If-None-Match, If-Modified-Since) with 304 when the
+// file is unchanged; an ETag header set before is used for If-None-Match.
 func (c *Context) File(filepath string) {
 	http.ServeFile(c.Writer, c.Request, filepath)
 }
 
-// SSEvent writes a Server-Sent Event into the body stream.
+// FileFromFS writes the specified file of fs into the body stream, answering
+// range and conditional requests like File.
+func (c *Context) FileFromFS(filepath string, fs http.FileSystem) {
+	defer func(old string) {
+		c.Request.URL.Path = old
+	}(c.Request.URL.Path)
+
+	c.Request.URL.Path = filepath
+
+	http.FileServer(fs).ServeHTTP(c.Writer, c.Request)
+}
+
+// FileAttachment writes the specified file into the body stream as a download
+// named filename. Like File, it answers range and conditional requests.
+func (c *Context) FileAttachment(filepath, filename string) {
+	c.Header("Content-Disposition", contentDisposition("attachment", filename))
+	http.ServeFile(c.Writer, c.Request, filepath)
+}
+
+// FileInline writes the specified file into the body stream to be displayed
+// by the browser, named filename when saved.
+func (c *Context) FileInline(filepath, filename string) {
+	c.Header("Content-Disposition", contentDisposition("inline", filename))
+	http.ServeFile(c.Writer, c.Request, filepath)
+}
+
+// contentDisposition returns a Content-Disposition value of the given type
+// for filename. A filename that is not plain ASCII is given UTF-8 percent
+// encoded as filename* (RFC 5987), after an ASCII filename for the clients
+// not reading filename*.
+func contentDisposition(dispositionType, filename string) string {
+	var ascii, encoded bytes.Buffer
+	plain := true
+	for i := 0; i < len(filename); i++ {
+		b := filename[i]
+		switch {
+		case b < 0x20 || b >= 0x7f || b == '"' || b == '\\':
+			plain = false
+			if b < 0x80 || b >= 0xc0 {
+				// one _ for each control character or UTF-8 sequence
+				ascii.WriteByte('_')
+			}
+		default:
+			ascii.WriteByte(b)
+		}
+		if isAttrChar(b) {
+			encoded.WriteByte(b)
+		} else {
+			fmt.Fprintf(&encoded, "%%%02X", b)
+		}
+	}
+	if plain {
+		return dispositionType + `; filename="` + filename + `"`
+	}
+	return dispositionType + `; filename="` + ascii.String() + `"; filename*=UTF-8''` + encoded.String()
+}
+
+// isAttrChar tells whether b is an attr-char of RFC 5987, kept as is in
+// filename*.
+func isAttrChar(b byte) bool {
+	switch {
+	case 'a' <= b && b <= 'z', 'A' <= b && b <= 'Z', '0' <= b && b <= '9':
+		return true
+	}
+	return strings.IndexByte("!#$&+-.^_`|~", b) >= 0
+}
+
+// SSEvent writes a Server-Sent Event into the body stream: an "event:" line
+// with name and "data:" lines with message, JSON encoded for a struct, slice
+// or map. The first event sets the text/event-stream Content-Type and, unless
+// already set, Cache-Control: no-cache and Connection: keep-alive.
+// Render an sse.Event to also give the event an id or a retry.
 func (c *Context) SSEvent(name string, message interface{}) {
 	c.Render(-1, sse.Event{
 		Event: name,
@@ -818,18 +945,27 @@ func (c *Context) SSEvent(name string, message interface{}) {
 	})
 }
 
-func (c *Context) Stream(step func(w io.Writer) bool) {
+// Stream calls step, flushing the writer after each call, until step returns
+// false or the client is gone, reported by the request's context or the
+// writer's CloseNotify. It returns true if the client is gone.
+func (c *Context) Stream(step func(w io.Writer) bool) bool {
 	w := c.Writer
-	clientGone := w.CloseNotify()
+	var clientGone <-chan bool
+	if cn, ok := c.writermem.ResponseWriter.(http.CloseNotifier); ok {
+		clientGone = cn.CloseNotify()
+	}
+	done := c.Request.Context().Done()
 	for {
 		select {
 		case <-clientGone:
-			return
+			return true
+		case <-done:
+			return true
 		default:
 			keepOpen := step(w)
 			w.Flush()
 			if !keepOpen {
-				return
+				return false
 			}
 		}
 	}
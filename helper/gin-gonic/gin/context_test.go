@@ -0,0 +1,237 @@
+package gin
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"EasyDarwin/helper/gin-gonic/gin/binding"
+)
+
+func newJSONContext(body string) *Context {
+	c, _ := CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("POST", "/", strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", MIMEJSON)
+	return c
+}
+
+func TestShouldBindJSONDisallowUnknownFields(t *testing.T) {
+	type stream struct {
+		Path string      `json:"path"`
+		ID   interface{} `json:"id"`
+	}
+	const body = `{"path":"/live/a","id":7,"pathh":"typo"}`
+
+	var obj stream
+	if err := newJSONContext(body).ShouldBindJSON(&obj); err != nil || obj.Path != "/live/a" {
+		t.Fatalf("ShouldBindJSON = %+v, %v, want unknown fields ignored by default", obj, err)
+	}
+
+	EnableJsonDecoderDisallowUnknownFields()
+	EnableJsonDecoderUseNumber()
+	defer func() {
+		binding.EnableDecoderDisallowUnknownFields = false
+		binding.EnableDecoderUseNumber = false
+	}()
+	err := newJSONContext(body).ShouldBindJSON(&stream{})
+	if err == nil || !strings.Contains(err.Error(), `"pathh"`) {
+		t.Fatalf("ShouldBindJSON err = %v, want the unknown field named", err)
+	}
+	obj = stream{}
+	if err := newJSONContext(`{"path":"/live/a","id":7}`).ShouldBindJSON(&obj); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := obj.ID.(interface{ Int64() (int64, error) }); !ok {
+		t.Fatalf("id = %T, want a json.Number with UseNumber combined", obj.ID)
+	}
+}
+
+// validatorFunc is a binding.StructValidator for the tests.
+type validatorFunc func(obj interface{}) error
+
+func (f validatorFunc) ValidateStruct(obj interface{}) error {
+	return f(obj)
+}
+
+func (validatorFunc) Engine() interface{} {
+	return nil
+}
+
+func TestShouldBindYAML(t *testing.T) {
+	type record struct {
+		Dir      string   `yaml:"dir" binding:"required"`
+		Segments []string `yaml:"segments"`
+	}
+	type stream struct {
+		Path   string  `yaml:"path"`
+		Record record  `yaml:"record"`
+		Backup *record `yaml:"backup"`
+	}
+	saved := binding.Validator
+	binding.Validator = validatorFunc(func(obj interface{}) error {
+		if s, ok := obj.(*stream); ok && s.Record.Dir == "" {
+			return errors.New("Record.Dir is required")
+		}
+		return nil
+	})
+	defer func() { binding.Validator = saved }()
+	newYAMLContext := func(body string) *Context {
+		c, _ := CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest("POST", "/", strings.NewReader(body))
+		c.Request.Header.Set("Content-Type", MIMEYAML)
+		return c
+	}
+
+	var obj stream
+	err := newYAMLContext(`
+path: /live/a
+record:
+  dir: /data/a
+  segments: [a.ts, b.ts]
+backup:
+  dir: /backup/a
+`).ShouldBindYAML(&obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if obj.Path != "/live/a" || obj.Record.Dir != "/data/a" || len(obj.Record.Segments) != 2 ||
+		obj.Backup == nil || obj.Backup.Dir != "/backup/a" {
+		t.Fatalf("bound %+v", obj)
+	}
+
+	obj = stream{}
+	err = newYAMLContext("path: /live/a\nrecord:\n  segments: [a.ts]\n").ShouldBindYAML(&obj)
+	if err == nil || err.Error() != "Record.Dir is required" {
+		t.Fatalf("err = %v, want the validation error", err)
+	}
+	if err := newYAMLContext("path: [").ShouldBindYAML(&stream{}); err == nil {
+		t.Fatal("no error for a malformed document")
+	}
+}
+
+// TestShouldBindBodyWithMiddlewares binds the body in two middlewares, to two
+// structs, then in the handler: the body is read once and kept.
+func TestShouldBindBodyWithMiddlewares(t *testing.T) {
+	type auth struct {
+		Token string `json:"token"`
+	}
+	type stream struct {
+		Path string `json:"path"`
+	}
+	router := New()
+	router.MaxBodyBytes = 64
+	var got auth
+	var gotStream, again stream
+	router.Use(func(c *Context) {
+		if err := c.ShouldBindBodyWith(&got, binding.JSON); err != nil {
+			c.AbortWithStatus(http.StatusBadRequest)
+		}
+	}, func(c *Context) {
+		if err := c.ShouldBindBodyWith(&gotStream, binding.JSON); err != nil {
+			c.AbortWithStatus(http.StatusBadRequest)
+		}
+	})
+	router.POST("/streams", func(c *Context) {
+		if err := c.ShouldBindBodyWith(&again, binding.JSON); err != nil {
+			c.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+		c.Status(http.StatusCreated)
+	})
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/streams", strings.NewReader(`{"token":"t0k","path":"/live/a"}`))
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("code = %d", w.Code)
+	}
+	if got.Token != "t0k" || gotStream.Path != "/live/a" || again.Path != "/live/a" {
+		t.Fatalf("bound %+v, %+v, %+v", got, gotStream, again)
+	}
+
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest("POST", "/streams", strings.NewReader(`{"token":"`+strings.Repeat("x", 64)+`"}`))
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("code over MaxBodyBytes = %d", w.Code)
+	}
+	c, _ := CreateTestContext(httptest.NewRecorder())
+	c.engine.MaxBodyBytes = 4
+	c.Request = httptest.NewRequest("POST", "/", strings.NewReader(`{"token":"t0k"}`))
+	if err := c.ShouldBindBodyWith(&auth{}, binding.JSON); err != ErrBodyTooLarge {
+		t.Fatalf("err = %v, want ErrBodyTooLarge", err)
+	}
+}
+
+func TestFileAttachment(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "record.mp4")
+	if err := ioutil.WriteFile(file, []byte("0123456789"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	router := New()
+	router.GET("/record", func(c *Context) {
+		c.FileAttachment(file, "录像 2018.mp4")
+	})
+
+	w := performRequest(router, "GET", "/record")
+	if w.Code != http.StatusOK || w.Body.String() != "0123456789" {
+		t.Fatalf("got %d %q", w.Code, w.Body.String())
+	}
+	const want = `attachment; filename="__ 2018.mp4"; filename*=UTF-8''%E5%BD%95%E5%83%8F%202018.mp4`
+	if got := w.Header().Get("Content-Disposition"); got != want {
+		t.Fatalf("Content-Disposition = %s, want %s", got, want)
+	}
+
+	req := httptest.NewRequest("GET", "/record", nil)
+	req.Header.Set("Range", "bytes=2-5")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusPartialContent || w.Body.String() != "2345" {
+		t.Fatalf("range: got %d %q", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("Content-Range"); got != "bytes 2-5/10" {
+		t.Fatalf("Content-Range = %s", got)
+	}
+	if got := w.Header().Get("Content-Disposition"); got != want {
+		t.Fatalf("range: Content-Disposition = %s", got)
+	}
+}
+
+func TestFileFromFS(t *testing.T) {
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "index.m3u8"), []byte("#EXTM3U\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	router := New()
+	router.GET("/hls/*file", func(c *Context) {
+		c.Header("ETag", `"v1"`)
+		c.FileFromFS(c.Param("file"), http.Dir(dir))
+	})
+	get := func(header, value string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/hls/index.m3u8", nil)
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := get("", ""); w.Code != http.StatusOK || w.Body.String() != "#EXTM3U\n" {
+		t.Fatalf("got %d %q", w.Code, w.Body.String())
+	}
+	w := get("Range", "bytes=1-3")
+	if w.Code != http.StatusPartialContent || w.Body.String() != "EXT" || w.Header().Get("Content-Range") != "bytes 1-3/8" {
+		t.Fatalf("range: got %d %q %s", w.Code, w.Body.String(), w.Header().Get("Content-Range"))
+	}
+	if w := get("If-None-Match", `"v1"`); w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Fatalf("If-None-Match: got %d %q", w.Code, w.Body.String())
+	}
+	if w := get("If-None-Match", `"v0"`); w.Code != http.StatusOK {
+		t.Fatalf("stale If-None-Match: got %d", w.Code)
+	}
+}
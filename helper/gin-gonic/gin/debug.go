@@ -6,8 +6,11 @@ package gin
 
 import (
 	"bytes"
+	"fmt"
 	"html/template"
+	"io"
 	"log"
+	"strings"
 )
 
 func init() {
@@ -20,30 +23,47 @@ func IsDebugging() bool {
 	return ginMode == debugCode
 }
 
-func debugPrintRoute(httpMethod, absolutePath string, handlers HandlersChain) {
-	if IsDebugging() {
+func (engine *Engine) debugPrintRoute(httpMethod, absolutePath string, handlers HandlersChain) {
+	if engine.IsDebugging() {
 		nuHandlers := len(handlers)
 		handlerName := nameOfFunction(handlers.Last())
-		debugPrint("%-6s %-25s --> %s (%d handlers)\n", httpMethod, absolutePath, handlerName, nuHandlers)
+		printDebug(engine.Writer(), "%-6s %-25s --> %s (%d handlers)\n", httpMethod, absolutePath, handlerName, nuHandlers)
 	}
 }
 
-func debugPrintLoadTemplate(tmpl *template.Template) {
-	if IsDebugging() {
+func (engine *Engine) debugPrintLoadTemplate(tmpl *template.Template) {
+	if engine.IsDebugging() {
 		var buf bytes.Buffer
 		for _, tmpl := range tmpl.Templates() {
 			buf.WriteString("\t- ")
 			buf.WriteString(tmpl.Name())
 			buf.WriteString("\n")
 		}
-		debugPrint("Loaded HTML Templates (%d): \n%s\n", len(tmpl.Templates()), buf.String())
+		printDebug(engine.Writer(), "Loaded HTML Templates (%d): \n%s\n", len(tmpl.Templates()), buf.String())
 	}
 }
 
 func debugPrint(format string, values ...interface{}) {
 	if IsDebugging() {
-		log.Printf("[GIN-debug] "+format, values...)
+		printDebug(DefaultWriter, format, values...)
+	}
+}
+
+// debugPrint prints in the mode and to the writer of the engine rather than
+// the package ones.
+func (engine *Engine) debugPrint(format string, values ...interface{}) {
+	if engine.IsDebugging() {
+		printDebug(engine.Writer(), format, values...)
+	}
+}
+
+// printDebug writes one debug line to out with a single Write call, so lines
+// of concurrent requests do not interleave.
+func printDebug(out io.Writer, format string, values ...interface{}) {
+	if !strings.HasSuffix(format, "\n") {
+		format += "\n"
 	}
+	fmt.Fprintf(out, "[GIN-debug] "+format, values...)
 }
 
 func debugPrintWARNINGDefault() {
@@ -63,8 +83,8 @@ func debugPrintWARNINGNew() {
 `)
 }
 
-func debugPrintWARNINGSetHTMLTemplate() {
-	debugPrint(`[WARNING] Since SetHTMLTemplate() is NOT thread-safe. It should only be called
+func (engine *Engine) debugPrintWARNINGSetHTMLTemplate() {
+	engine.debugPrint(`[WARNING] Since SetHTMLTemplate() is NOT thread-safe. It should only be called
 at initialization. ie. before any route is registered or the router is listening in a socket:
 
 	router := gin.Default()
@@ -73,8 +93,8 @@ at initialization. ie. before any route is registered or the router is listening
 `)
 }
 
-func debugPrintError(err error) {
+func (engine *Engine) debugPrintError(err error) {
 	if err != nil {
-		debugPrint("[ERROR] %v\n", err)
+		engine.debugPrint("[ERROR] %v\n", err)
 	}
 }
@@ -5,11 +5,20 @@
 package gin
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
 	"html/template"
+	"io"
+	"io/ioutil"
 	"net"
 	"net/http"
 	"os"
+	"path/filepath"
+	"strings"
 	"sync"
+	"time"
 
 	"EasyDarwin/helper/gin-gonic/gin/render"
 )
@@ -18,6 +27,7 @@ const (
 	// Version is Framework's version.
 	Version                = "v1.3.0"
 	defaultMultipartMemory = 32 << 20 // 32 MB
+	defaultShutdownTimeout = 5 * time.Second
 )
 
 var (
@@ -71,12 +81,19 @@ type Engine struct {
 	// If enabled, the router checks if another method is allowed for the
 	// current route, if the current request can not be routed.
 	// If this is the case, the request is answered with 'Method Not Allowed'
-	// and HTTP status code 405.
+	// and HTTP status code 405, with an Allow header listing the methods
+	// allowed, see Context.AllowedMethods.
 	// If no other Method is allowed, the request is delegated to the NotFound
 	// handler.
 	HandleMethodNotAllowed bool
 	ForwardedByClientIP    bool
 
+	// If enabled, the router answers an OPTIONS request without a handler of
+	// its own, when other methods are allowed for the path, with 204 and an
+	// Allow header listing them, after the global middleware, e.g. CORS.
+	// The response can be taken over with GlobalOPTIONS.
+	HandleOPTIONS bool
+
 	// #726 #755 If enabled, it will thrust some headers starting with
 	// 'X-AppEngine...' for better integration with that PaaS.
 	AppEngine bool
@@ -93,6 +110,23 @@ type Engine struct {
 	// method call.
 	MaxMultipartMemory int64
 
+	// MaxBodyBytes caps the bytes ShouldBindBodyWith reads and keeps in the
+	// context, larger bodies fail with ErrBodyTooLarge. 0 means no limit.
+	MaxBodyBytes int64
+
+	// ShutdownTimeout is how long RunWithContext waits for the requests in
+	// flight once its context is done, before closing their connections.
+	ShutdownTimeout time.Duration
+
+	// ConfigureServer, if set, is called by RunWithContext with the
+	// http.Server it built, before serving, to tweak its timeouts and such.
+	ConfigureServer func(*http.Server)
+
+	// UseH2C serves HTTP/2 without TLS (h2c) besides HTTP/1.1 on the same
+	// port, to the clients sending the HTTP/2 preface (prior knowledge).
+	// It applies to the Run methods but RunTLS, which negotiates HTTP/2.
+	UseH2C bool
+
 	delims           render.Delims
 	secureJsonPrefix string
 	HTMLRender       render.HTMLRender
@@ -101,8 +135,15 @@ type Engine struct {
 	allNoMethod      HandlersChain
 	noRoute          HandlersChain
 	noMethod         HandlersChain
+	allOPTIONS       HandlersChain
+	globalOPTIONS    HandlersChain
 	pool             sync.Pool
 	trees            methodTrees
+	mode             string
+
+	writersMu   sync.RWMutex
+	writer      io.Writer
+	errorWriter io.Writer
 }
 
 var _ IRouter = &Engine{}
@@ -112,9 +153,11 @@ var _ IRouter = &Engine{}
 // - RedirectTrailingSlash:  true
 // - RedirectFixedPath:      false
 // - HandleMethodNotAllowed: false
+// - HandleOPTIONS:          false
 // - ForwardedByClientIP:    true
 // - UseRawPath:             false
 // - UnescapePathValues:     true
+// - ShutdownTimeout:        5s
 func New() *Engine {
 	debugPrintWARNINGNew()
 	engine := &Engine{
@@ -127,16 +170,19 @@ func New() *Engine {
 		RedirectTrailingSlash:  true,
 		RedirectFixedPath:      false,
 		HandleMethodNotAllowed: false,
+		HandleOPTIONS:          false,
 		ForwardedByClientIP:    true,
 		AppEngine:              defaultAppEngine,
 		UseRawPath:             false,
 		UnescapePathValues:     true,
 		MaxMultipartMemory:     defaultMultipartMemory,
+		ShutdownTimeout:        defaultShutdownTimeout,
 		trees:                  make(methodTrees, 0, 9),
 		delims:                 render.Delims{Left: "{{", Right: "}}"},
 		secureJsonPrefix:       "while(1);",
 	}
 	engine.RouterGroup.engine = engine
+	engine.rebuildOPTIONSHandlers()
 	engine.pool.New = func() interface{} {
 		return engine.allocateContext()
 	}
@@ -173,8 +219,8 @@ func (engine *Engine) LoadHTMLGlob(pattern string) {
 	right := engine.delims.Right
 	templ := template.Must(template.New("").Delims(left, right).Funcs(engine.FuncMap).ParseGlob(pattern))
 
-	if IsDebugging() {
-		debugPrintLoadTemplate(templ)
+	if engine.IsDebugging() {
+		engine.debugPrintLoadTemplate(templ)
 		engine.HTMLRender = render.HTMLDebug{Glob: pattern, FuncMap: engine.FuncMap, Delims: engine.delims}
 		return
 	}
@@ -185,7 +231,7 @@ func (engine *Engine) LoadHTMLGlob(pattern string) {
 // LoadHTMLFiles loads a slice of HTML files
 // and associates the result with HTML renderer.
 func (engine *Engine) LoadHTMLFiles(files ...string) {
-	if IsDebugging() {
+	if engine.IsDebugging() {
 		engine.HTMLRender = render.HTMLDebug{Files: files, FuncMap: engine.FuncMap, Delims: engine.delims}
 		return
 	}
@@ -197,7 +243,7 @@ func (engine *Engine) LoadHTMLFiles(files ...string) {
 // SetHTMLTemplate associate a template with HTML renderer.
 func (engine *Engine) SetHTMLTemplate(templ *template.Template) {
 	if len(engine.trees) > 0 {
-		debugPrintWARNINGSetHTMLTemplate()
+		engine.debugPrintWARNINGSetHTMLTemplate()
 	}
 
 	engine.HTMLRender = render.HTMLProduction{Template: templ.Funcs(engine.FuncMap)}
@@ -214,12 +260,22 @@ func (engine *Engine) NoRoute(handlers ...HandlerFunc) {
 	engine.rebuild404Handlers()
 }
 
-// NoMethod sets the handlers called when... TODO.
+// NoMethod sets the handlers called when the request is answered with 405,
+// see HandleMethodNotAllowed. The Allow header is set when they are called,
+// and Context.AllowedMethods lists the methods allowed.
 func (engine *Engine) NoMethod(handlers ...HandlerFunc) {
 	engine.noMethod = handlers
 	engine.rebuild405Handlers()
 }
 
+// GlobalOPTIONS sets the handlers answering the OPTIONS requests handled
+// automatically, see HandleOPTIONS, in place of the 204. The Allow header is
+// set when they are called.
+func (engine *Engine) GlobalOPTIONS(handlers ...HandlerFunc) {
+	engine.globalOPTIONS = handlers
+	engine.rebuildOPTIONSHandlers()
+}
+
 // Use attachs a global middleware to the router. ie. the middleware attached though Use() will be
 // included in the handlers chain for every single request. Even 404, 405, static files...
 // For example, this is the right place for a logger or error management middleware.
@@ -227,6 +283,7 @@ func (engine *Engine) Use(middleware ...HandlerFunc) IRoutes {
 	engine.RouterGroup.Use(middleware...)
 	engine.rebuild404Handlers()
 	engine.rebuild405Handlers()
+	engine.rebuildOPTIONSHandlers()
 	return engine
 }
 
@@ -238,12 +295,26 @@ func (engine *Engine) rebuild405Handlers() {
 	engine.allNoMethod = engine.combineHandlers(engine.noMethod)
 }
 
+func (engine *Engine) rebuildOPTIONSHandlers() {
+	handlers := engine.globalOPTIONS
+	if handlers == nil {
+		handlers = HandlersChain{handleOPTIONS}
+	}
+	engine.allOPTIONS = engine.combineHandlers(handlers)
+}
+
+// handleOPTIONS is the default answer to the OPTIONS requests handled
+// automatically.
+func handleOPTIONS(c *Context) {
+	c.Status(http.StatusNoContent)
+}
+
 func (engine *Engine) addRoute(method, path string, handlers HandlersChain) {
 	assert1(path[0] == '/', "path must begin with '/'")
 	assert1(method != "", "HTTP method can not be empty")
 	assert1(len(handlers) > 0, "there must be at least one handler")
 
-	debugPrintRoute(method, path, handlers)
+	engine.debugPrintRoute(method, path, handlers)
 	root := engine.trees.get(method)
 	if root == nil {
 		root = new(node)
@@ -280,11 +351,42 @@ func iterate(path, method string, routes RoutesInfo, root *node) RoutesInfo {
 // It is a shortcut for http.ListenAndServe(addr, router)
 // Note: this method will block the calling goroutine indefinitely unless an error happens.
 func (engine *Engine) Run(addr ...string) (err error) {
-	defer func() { debugPrintError(err) }()
+	defer func() { engine.debugPrintError(err) }()
 
 	address := resolveAddress(addr)
-	debugPrint("Listening and serving HTTP on %s\n", address)
-	err = http.ListenAndServe(address, engine)
+	engine.debugPrint("Listening and serving HTTP on %s\n", address)
+	err = engine.newServer(address).ListenAndServe()
+	return
+}
+
+// RunWithContext attaches the router to a http.Server and starts listening and serving HTTP requests
+// until ctx is done. The server is then shut down: it stops accepting connections and waits up to
+// ShutdownTimeout for the requests in flight, after which the remaining connections are closed.
+// It returns the error of the shutdown, or the error that stopped the server before.
+func (engine *Engine) RunWithContext(ctx context.Context, addr ...string) (err error) {
+	defer func() { engine.debugPrintError(err) }()
+
+	server := engine.newServer(resolveAddress(addr))
+	if engine.ConfigureServer != nil {
+		engine.ConfigureServer(server)
+	}
+	engine.debugPrint("Listening and serving HTTP on %s\n", server.Addr)
+	served := make(chan error, 1)
+	go func() {
+		served <- server.ListenAndServe()
+	}()
+	select {
+	case err = <-served:
+		return
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), engine.ShutdownTimeout)
+	defer cancel()
+	if err = server.Shutdown(shutdownCtx); err != nil {
+		server.Close()
+	}
+	<-served
 	return
 }
 
@@ -292,30 +394,200 @@ func (engine *Engine) Run(addr ...string) (err error) {
 // It is a shortcut for http.ListenAndServeTLS(addr, certFile, keyFile, router)
 // Note: this method will block the calling goroutine indefinitely unless an error happens.
 func (engine *Engine) RunTLS(addr, certFile, keyFile string) (err error) {
-	debugPrint("Listening and serving HTTPS on %s\n", addr)
-	defer func() { debugPrintError(err) }()
+	engine.debugPrint("Listening and serving HTTPS on %s\n", addr)
+	defer func() { engine.debugPrintError(err) }()
 
 	err = http.ListenAndServeTLS(addr, certFile, keyFile, engine)
 	return
 }
 
+// CertManager obtains and renews certificates through ACME for RunAutoTLS, e.g. an
+// *autocert.Manager of golang.org/x/crypto/acme/autocert, which has both methods.
+// x/crypto is not vendored, so the application brings the manager.
+type CertManager interface {
+	// GetCertificate returns the certificate for the host name of a TLS handshake,
+	// obtaining it first if needed.
+	GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error)
+	// HTTPHandler answers the HTTP-01 challenges and passes the other requests to fallback.
+	HTTPHandler(fallback http.Handler) http.Handler
+}
+
+// acmeTLSALPN is the ALPN protocol of the TLS-ALPN-01 challenges.
+const acmeTLSALPN = "acme-tls/1"
+
+// RunAutoTLS attaches the router to a http.Server and starts listening and serving HTTPS requests
+// on :443, with certificates obtained and renewed by manager for the whitelisted domains; a handshake
+// for any other host name is refused before reaching the manager. It also listens on :80 to answer
+// the HTTP-01 challenges and redirect the other requests to HTTPS.
+// cacheDir, unless empty, is created or restricted to the mode 0700 for the cache of the manager,
+// which holds the account key and the certificates and is reused across restarts:
+//
+//	manager := &autocert.Manager{Prompt: autocert.AcceptTOS, Cache: autocert.DirCache(cacheDir)}
+//	router.RunAutoTLS(manager, []string{"example.com"}, cacheDir)
+//
+// Note: this method will block the calling goroutine indefinitely unless an error happens.
+func (engine *Engine) RunAutoTLS(manager CertManager, domains []string, cacheDir string) (err error) {
+	engine.debugPrint("Listening and serving HTTPS on :443 for %v\n", domains)
+	defer func() { engine.debugPrintError(err) }()
+
+	if len(domains) == 0 {
+		return errors.New("no domain to obtain certificates for")
+	}
+	if cacheDir != "" {
+		if err = os.MkdirAll(cacheDir, 0700); err != nil {
+			return
+		}
+		// the cache holds private keys, a directory made before may be readable by others
+		if err = os.Chmod(cacheDir, 0700); err != nil {
+			return
+		}
+	}
+	challengeServer := &http.Server{
+		Addr:    ":80",
+		Handler: manager.HTTPHandler(http.HandlerFunc(redirectHTTPS)),
+	}
+	server := &http.Server{
+		Addr:    ":443",
+		Handler: engine,
+		TLSConfig: &tls.Config{
+			GetCertificate: hostWhitelist(domains, manager.GetCertificate),
+			NextProtos:     []string{"h2", "http/1.1", acmeTLSALPN},
+		},
+	}
+	served := make(chan error, 2)
+	go func() {
+		served <- challengeServer.ListenAndServe()
+	}()
+	go func() {
+		served <- server.ListenAndServeTLS("", "")
+	}()
+	// certificates can be neither obtained nor served without the other server
+	err = <-served
+	challengeServer.Close()
+	server.Close()
+	<-served
+	return
+}
+
+// hostWhitelist returns a GetCertificate refusing the handshakes for other host names than
+// domains, so that no certificate is requested for them.
+func hostWhitelist(domains []string, getCertificate func(*tls.ClientHelloInfo) (*tls.Certificate, error)) func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	whitelist := make(map[string]bool, len(domains))
+	for _, domain := range domains {
+		whitelist[strings.ToLower(domain)] = true
+	}
+	return func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+		if !whitelist[strings.TrimSuffix(strings.ToLower(hello.ServerName), ".")] {
+			return nil, fmt.Errorf("host %q is not whitelisted for automatic TLS", hello.ServerName)
+		}
+		return getCertificate(hello)
+	}
+}
+
+// redirectHTTPS redirects a plain HTTP request to the same URL over HTTPS, on the default port.
+// Other methods than GET and HEAD are redirected with 308 so their body is sent again.
+func redirectHTTPS(w http.ResponseWriter, req *http.Request) {
+	host := req.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	code := http.StatusMovedPermanently
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		code = http.StatusPermanentRedirect
+	}
+	http.Redirect(w, req, "https://"+host+req.URL.RequestURI(), code)
+}
+
 // RunUnix attaches the router to a http.Server and starts listening and serving HTTP requests
-// through the specified unix socket (ie. a file).
+// through the specified unix socket (ie. a file). A socket file left by a previous run is removed,
+// and the new one is given the mode perm, e.g. 0660 for a proxy running as another user of the group.
+// The socket is created and given its mode in a private directory next to file, then moved to file,
+// so it is never reachable with the default mode. The socket file is removed when the listener closes.
 // Note: this method will block the calling goroutine indefinitely unless an error happens.
-func (engine *Engine) RunUnix(file string) (err error) {
-	debugPrint("Listening and serving HTTP on unix:/%s", file)
-	defer func() { debugPrintError(err) }()
+func (engine *Engine) RunUnix(file string, perm os.FileMode) (err error) {
+	engine.debugPrint("Listening and serving HTTP on unix:/%s", file)
+	defer func() { engine.debugPrintError(err) }()
+
+	listener, err := listenUnix(file, perm)
+	if err != nil {
+		return
+	}
+	defer os.Remove(file)
+	defer listener.Close()
+	err = engine.newServer("").Serve(listener)
+	return
+}
 
-	os.Remove(file)
-	listener, err := net.Listen("unix", file)
+// listenUnix listens on the unix socket file with the mode perm. The socket is
+// not unlinked by the listener, it is up to the caller.
+func listenUnix(file string, perm os.FileMode) (listener *net.UnixListener, err error) {
+	dir, err := ioutil.TempDir(filepath.Dir(file), ".gin-unix-")
+	if err != nil {
+		return
+	}
+	defer os.RemoveAll(dir)
+	tmp := filepath.Join(dir, "sock")
+	listener, err = net.ListenUnix("unix", &net.UnixAddr{Name: tmp, Net: "unix"})
+	if err != nil {
+		return
+	}
+	listener.SetUnlinkOnClose(false)
+	if err = os.Chmod(tmp, perm); err == nil {
+		os.Remove(file)
+		err = os.Rename(tmp, file)
+	}
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return
+}
+
+// RunFd attaches the router to a http.Server and starts listening and serving HTTP requests
+// through the specified file descriptor, e.g. a socket passed by systemd socket activation.
+// Note: this method will block the calling goroutine indefinitely unless an error happens.
+func (engine *Engine) RunFd(fd int) (err error) {
+	engine.debugPrint("Listening and serving HTTP on fd@%d\n", fd)
+	defer func() { engine.debugPrintError(err) }()
+
+	f := os.NewFile(uintptr(fd), fmt.Sprintf("fd@%d", fd))
+	// the listener has its own copy of the descriptor
+	defer f.Close()
+	listener, err := net.FileListener(f)
 	if err != nil {
 		return
 	}
 	defer listener.Close()
-	err = http.Serve(listener, engine)
+	err = engine.newServer("").Serve(listener)
+	return
+}
+
+// RunListener attaches the router to a http.Server and starts listening and serving HTTP requests
+// through the specified net.Listener.
+// Note: this method will block the calling goroutine indefinitely unless an error happens.
+func (engine *Engine) RunListener(listener net.Listener) (err error) {
+	engine.debugPrint("Listening and serving HTTP on listener %s\n", listener.Addr())
+	defer func() { engine.debugPrintError(err) }()
+
+	err = engine.newServer("").Serve(listener)
 	return
 }
 
+// newServer returns the http.Server of the Run methods, speaking h2c too
+// with UseH2C.
+func (engine *Engine) newServer(addr string) *http.Server {
+	server := &http.Server{
+		Addr:    addr,
+		Handler: engine,
+	}
+	if engine.UseH2C {
+		server.Protocols = new(http.Protocols)
+		server.Protocols.SetHTTP1(true)
+		server.Protocols.SetUnencryptedHTTP2(true)
+	}
+	return server
+}
+
 // ServeHTTP conforms to the http.Handler interface.
 func (engine *Engine) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	c := engine.pool.Get().(*Context)
@@ -374,22 +646,58 @@ func (engine *Engine) handleHTTPRequest(c *Context) {
 		break
 	}
 
+	if httpMethod == "OPTIONS" && engine.HandleOPTIONS {
+		if allowed := engine.allowedMethods(path, httpMethod, unescape); len(allowed) > 0 {
+			c.handlers = engine.allOPTIONS
+			c.allowedMethods = allowed
+			c.writermem.Header().Set("Allow", strings.Join(allowed, ", "))
+			c.Next()
+			c.writermem.WriteHeaderNow()
+			return
+		}
+	}
+
 	if engine.HandleMethodNotAllowed {
-		for _, tree := range engine.trees {
-			if tree.method == httpMethod {
-				continue
-			}
-			if handlers, _, _ := tree.root.getValue(path, nil, unescape); handlers != nil {
-				c.handlers = engine.allNoMethod
-				serveError(c, http.StatusMethodNotAllowed, default405Body)
-				return
-			}
+		if allowed := engine.allowedMethods(path, httpMethod, unescape); len(allowed) > 0 {
+			c.handlers = engine.allNoMethod
+			c.allowedMethods = allowed
+			c.writermem.Header().Set("Allow", strings.Join(allowed, ", "))
+			serveError(c, http.StatusMethodNotAllowed, default405Body)
+			return
 		}
 	}
 	c.handlers = engine.allNoRoute
 	serveError(c, http.StatusNotFound, default404Body)
 }
 
+// allowedMethods returns the methods with a handler for path but reqMethod,
+// in the order they were first registered, none when there are none.
+// OPTIONS is added with HandleOPTIONS. The path * of OPTIONS * takes every
+// method registered.
+func (engine *Engine) allowedMethods(path, reqMethod string, unescape bool) []string {
+	allow := make([]string, 0, len(engine.trees)+1)
+	options := false
+	for _, tree := range engine.trees {
+		if tree.method == reqMethod {
+			continue
+		}
+		if path != "*" {
+			if handlers, _, _ := tree.root.getValue(path, nil, unescape); handlers == nil {
+				continue
+			}
+		}
+		allow = append(allow, tree.method)
+		options = options || tree.method == "OPTIONS"
+	}
+	if len(allow) == 0 {
+		return nil
+	}
+	if engine.HandleOPTIONS && !options {
+		allow = append(allow, "OPTIONS")
+	}
+	return allow
+}
+
 var mimePlain = []string{MIMEPlain}
 
 func serveError(c *Context, code int, defaultMessage []byte) {
@@ -419,7 +727,7 @@ func redirectTrailingSlash(c *Context) {
 	if length := len(path); length > 1 && path[length-1] == '/' {
 		req.URL.Path = path[:length-1]
 	}
-	debugPrint("redirecting request %d: %s --> %s", code, path, req.URL.String())
+	c.engine.debugPrint("redirecting request %d: %s --> %s", code, path, req.URL.String())
 	http.Redirect(c.Writer, req, req.URL.String(), code)
 	c.writermem.WriteHeaderNow()
 }
@@ -434,7 +742,7 @@ func redirectFixedPath(c *Context, root *node, trailingSlash bool) bool {
 			code = http.StatusTemporaryRedirect
 		}
 		req.URL.Path = string(fixedPath)
-		debugPrint("redirecting request %d: %s --> %s", code, path, req.URL.String())
+		c.engine.debugPrint("redirecting request %d: %s --> %s", code, path, req.URL.String())
 		http.Redirect(c.Writer, req, req.URL.String(), code)
 		c.writermem.WriteHeaderNow()
 		return true
@@ -0,0 +1,118 @@
+package gin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func init() {
+	SetMode(TestMode)
+}
+
+func performRequest(r http.Handler, method, path string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestHandleOPTIONS(t *testing.T) {
+	router := New()
+	router.HandleOPTIONS = true
+	middleware := 0
+	router.Use(func(c *Context) {
+		middleware++
+		c.Header("Access-Control-Allow-Origin", "*")
+	})
+	router.GET("/a", func(c *Context) {})
+	router.POST("/a", func(c *Context) {})
+	router.GET("/b", func(c *Context) {})
+	router.OPTIONS("/b", func(c *Context) { c.String(http.StatusOK, "explicit") })
+
+	w := performRequest(router, "OPTIONS", "/a")
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("OPTIONS /a status = %d, want 204", w.Code)
+	}
+	if allow := w.Header().Get("Allow"); allow != "GET, POST, OPTIONS" {
+		t.Fatalf("OPTIONS /a Allow = %q", allow)
+	}
+	if w.Header().Get("Access-Control-Allow-Origin") != "*" || middleware != 1 {
+		t.Fatalf("global middleware ran %d times, want once before the 204", middleware)
+	}
+
+	w = performRequest(router, "OPTIONS", "/b")
+	if w.Code != http.StatusOK || w.Body.String() != "explicit" {
+		t.Fatalf("OPTIONS /b = %d %q, want the explicit handler", w.Code, w.Body.String())
+	}
+
+	w = performRequest(router, "OPTIONS", "/nope")
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("OPTIONS /nope status = %d, want 404", w.Code)
+	}
+}
+
+func TestHandleOPTIONSDisabled(t *testing.T) {
+	router := New()
+	router.GET("/a", func(c *Context) {})
+	if w := performRequest(router, "OPTIONS", "/a"); w.Code != http.StatusNotFound {
+		t.Fatalf("OPTIONS /a status = %d, want 404 without HandleOPTIONS", w.Code)
+	}
+}
+
+func TestGlobalOPTIONS(t *testing.T) {
+	router := New()
+	router.HandleOPTIONS = true
+	router.GlobalOPTIONS(func(c *Context) {
+		c.JSON(http.StatusOK, c.AllowedMethods())
+	})
+	router.GET("/a", func(c *Context) {})
+	router.POST("/a", func(c *Context) {})
+
+	w := performRequest(router, "OPTIONS", "/a")
+	if w.Code != http.StatusOK || w.Body.String() != `["GET","POST","OPTIONS"]` {
+		t.Fatalf("OPTIONS /a = %d %q", w.Code, w.Body.String())
+	}
+	if allow := w.Header().Get("Allow"); allow != "GET, POST, OPTIONS" {
+		t.Fatalf("OPTIONS /a Allow = %q", allow)
+	}
+}
+
+func TestMethodNotAllowedAllow(t *testing.T) {
+	router := New()
+	router.HandleMethodNotAllowed = true
+	router.GET("/a", func(c *Context) {})
+	router.DELETE("/a", func(c *Context) {})
+
+	w := performRequest(router, "POST", "/a")
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("POST /a status = %d, want 405", w.Code)
+	}
+	if allow := w.Header().Get("Allow"); allow != "GET, DELETE" {
+		t.Fatalf("POST /a Allow = %q", allow)
+	}
+	if w = performRequest(router, "POST", "/nope"); w.Code != http.StatusNotFound || w.Header().Get("Allow") != "" {
+		t.Fatalf("POST /nope = %d Allow %q, want 404 without Allow", w.Code, w.Header().Get("Allow"))
+	}
+}
+
+func TestMethodNotAllowedCustomHandler(t *testing.T) {
+	router := New()
+	router.HandleMethodNotAllowed = true
+	router.NoMethod(func(c *Context) {
+		c.JSON(http.StatusMethodNotAllowed, map[string]interface{}{"allow": c.AllowedMethods()})
+	})
+	router.GET("/a", func(c *Context) {})
+	router.DELETE("/a", func(c *Context) {})
+
+	w := performRequest(router, "POST", "/a")
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("POST /a status = %d, want 405", w.Code)
+	}
+	if allow := w.Header().Get("Allow"); allow != "GET, DELETE" {
+		t.Fatalf("POST /a Allow = %q", allow)
+	}
+	if w.Body.String() != `{"allow":["GET","DELETE"]}` {
+		t.Fatalf("POST /a body = %q", w.Body.String())
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright 2014 Manu Martinez-Almeida.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package gin
+
+import (
+	"bufio"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"testing"
+)
+
+// TestRunListenerH2C drives an HTTP/2 prior-knowledge client and a plain
+// HTTP/1.1 client against the same listener of an engine with UseH2C.
+func TestRunListenerH2C(t *testing.T) {
+	SetMode(TestMode)
+	router := New()
+	router.UseH2C = true
+	router.ForwardedByClientIP = true
+	router.GET("/ip", func(c *Context) {
+		c.String(http.StatusOK, "%s %s", c.Request.Proto, c.ClientIP())
+	})
+	router.GET("/stream", func(c *Context) {
+		i := 0
+		c.Stream(func(w io.Writer) bool {
+			i++
+			w.Write([]byte{'0' + byte(i), '\n'})
+			return i < 3
+		})
+	})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go router.RunListener(listener)
+	base := "http://" + listener.Addr().String()
+
+	h2 := new(http.Protocols)
+	h2.SetUnencryptedHTTP2(true)
+	clients := map[string]*http.Client{
+		"HTTP/2.0": {Transport: &http.Transport{Protocols: h2}},
+		"HTTP/1.1": {Transport: &http.Transport{}},
+	}
+	for proto, client := range clients {
+		req, _ := http.NewRequest("GET", base+"/ip", nil)
+		req.Header.Set("X-Forwarded-For", "10.0.0.1")
+		res, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("%s: %v", proto, err)
+		}
+		body, _ := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if res.Proto != proto || string(body) != proto+" 10.0.0.1" {
+			t.Errorf("%s: got %s %q", proto, res.Proto, body)
+		}
+
+		res, err = client.Get(base + "/stream")
+		if err != nil {
+			t.Fatalf("%s: %v", proto, err)
+		}
+		var lines []string
+		for scanner := bufio.NewScanner(res.Body); scanner.Scan(); {
+			lines = append(lines, scanner.Text())
+		}
+		res.Body.Close()
+		if len(lines) != 3 || lines[0] != "1" || lines[2] != "3" {
+			t.Errorf("%s: streamed %q", proto, lines)
+		}
+	}
+}
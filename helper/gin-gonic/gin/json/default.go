@@ -0,0 +1,12 @@
+// Copyright 2017 Bo-Yi Wu.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+//go:build !jsoniter
+// +build !jsoniter
+
+package json
+
+// DefaultAPI is the API used unless another one is installed with Use,
+// encoding/json unless built with the jsoniter tag.
+var DefaultAPI = StdAPI
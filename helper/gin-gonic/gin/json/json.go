@@ -2,15 +2,106 @@
 // Use of this source code is governed by a MIT style
 // license that can be found in the LICENSE file.
 
-//go:build !jsoniter
-// +build !jsoniter
-
 package json
 
-import "encoding/json"
-
-var (
-	Marshal       = json.Marshal
-	MarshalIndent = json.MarshalIndent
-	NewDecoder    = json.NewDecoder
+import (
+	"encoding/json"
+	"io"
+	"sync/atomic"
 )
+
+// Decoder reads JSON values from an input stream, see encoding/json.Decoder.
+type Decoder interface {
+	Decode(v interface{}) error
+	More() bool
+	Buffered() io.Reader
+	UseNumber()
+	DisallowUnknownFields()
+}
+
+// Encoder writes JSON values to an output stream, see encoding/json.Encoder.
+type Encoder interface {
+	Encode(v interface{}) error
+	SetIndent(prefix, indent string)
+	SetEscapeHTML(on bool)
+}
+
+// API is a JSON codec. The render and binding packages and Gin itself encode
+// and decode through the API installed with Use.
+type API interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+	MarshalIndent(v interface{}, prefix, indent string) ([]byte, error)
+	NewDecoder(r io.Reader) Decoder
+	NewEncoder(w io.Writer) Encoder
+}
+
+// StdAPI is the API of encoding/json.
+var StdAPI API = stdAPI{}
+
+type stdAPI struct{}
+
+func (stdAPI) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (stdAPI) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+func (stdAPI) MarshalIndent(v interface{}, prefix, indent string) ([]byte, error) {
+	return json.MarshalIndent(v, prefix, indent)
+}
+
+func (stdAPI) NewDecoder(r io.Reader) Decoder {
+	return json.NewDecoder(r)
+}
+
+func (stdAPI) NewEncoder(w io.Writer) Encoder {
+	return json.NewEncoder(w)
+}
+
+// apiHolder keeps the concrete type stored in current the same.
+type apiHolder struct {
+	API
+}
+
+var current atomic.Value
+
+func init() {
+	current.Store(apiHolder{DefaultAPI})
+}
+
+// Use installs api as the codec of Gin, nil restores DefaultAPI. It is safe
+// to call at any time, requests being served pick it up on their next call.
+func Use(api API) {
+	if api == nil {
+		api = DefaultAPI
+	}
+	current.Store(apiHolder{api})
+}
+
+// Current returns the API installed with Use.
+func Current() API {
+	return current.Load().(apiHolder).API
+}
+
+func Marshal(v interface{}) ([]byte, error) {
+	return Current().Marshal(v)
+}
+
+func Unmarshal(data []byte, v interface{}) error {
+	return Current().Unmarshal(data, v)
+}
+
+func MarshalIndent(v interface{}, prefix, indent string) ([]byte, error) {
+	return Current().MarshalIndent(v, prefix, indent)
+}
+
+func NewDecoder(r io.Reader) Decoder {
+	return Current().NewDecoder(r)
+}
+
+func NewEncoder(w io.Writer) Encoder {
+	return Current().NewEncoder(w)
+}
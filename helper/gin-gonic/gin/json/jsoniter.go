@@ -7,11 +7,24 @@
 
 package json
 
-import "github.com/json-iterator/go"
+import (
+	"io"
 
-var (
-	json          = jsoniter.ConfigCompatibleWithStandardLibrary
-	Marshal       = json.Marshal
-	MarshalIndent = json.MarshalIndent
-	NewDecoder    = json.NewDecoder
+	"github.com/json-iterator/go"
 )
+
+// DefaultAPI is the API used unless another one is installed with Use,
+// jsoniter when built with the jsoniter tag.
+var DefaultAPI API = jsoniterAPI{jsoniter.ConfigCompatibleWithStandardLibrary}
+
+type jsoniterAPI struct {
+	jsoniter.API
+}
+
+func (api jsoniterAPI) NewDecoder(r io.Reader) Decoder {
+	return api.API.NewDecoder(r)
+}
+
+func (api jsoniterAPI) NewEncoder(w io.Writer) Encoder {
+	return api.API.NewEncoder(w)
+}
@@ -0,0 +1,68 @@
+package gin
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"EasyDarwin/helper/gin-gonic/gin/json"
+)
+
+// countingJSON is the standard codec counting the values it encodes and
+// decodes.
+type countingJSON struct {
+	json.API
+	encodes, decodes int32
+}
+
+func (api *countingJSON) Marshal(v interface{}) ([]byte, error) {
+	atomic.AddInt32(&api.encodes, 1)
+	return api.API.Marshal(v)
+}
+
+func (api *countingJSON) NewEncoder(w io.Writer) json.Encoder {
+	atomic.AddInt32(&api.encodes, 1)
+	return api.API.NewEncoder(w)
+}
+
+func (api *countingJSON) NewDecoder(r io.Reader) json.Decoder {
+	atomic.AddInt32(&api.decodes, 1)
+	return api.API.NewDecoder(r)
+}
+
+func TestJSONCodec(t *testing.T) {
+	api := &countingJSON{API: json.StdAPI}
+	json.Use(api)
+	defer json.Use(nil)
+
+	router := New()
+	router.POST("/echo", func(c *Context) {
+		var body struct {
+			Name string `json:"name"`
+		}
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+		c.JSON(http.StatusOK, H{"hello": body.Name})
+	})
+	req := httptest.NewRequest("POST", "/echo", strings.NewReader(`{"name":"gin"}`))
+	req.Header.Set("Content-Type", MIMEJSON)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK || w.Body.String() != `{"hello":"gin"}` {
+		t.Fatalf("response = %d %s", w.Code, w.Body)
+	}
+	if api.decodes != 1 || api.encodes != 1 {
+		t.Fatalf("decodes %d encodes %d, want binding and rendering through the codec", api.decodes, api.encodes)
+	}
+
+	json.Use(nil)
+	if json.Current() != json.DefaultAPI {
+		t.Fatal("Use(nil) does not restore DefaultAPI")
+	}
+}
@@ -9,8 +9,11 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"strings"
+	"sync"
 	"time"
 
+	"EasyDarwin/helper/gin-gonic/gin/json"
 	"EasyDarwin/helper/mattn/go-isatty"
 )
 
@@ -47,29 +50,224 @@ func ErrorLoggerT(typ ErrorType) HandlerFunc {
 	}
 }
 
-// Logger instances a Logger middleware that will write the logs to gin.DefaultWriter.
+// LogFormatter formats the line logged for a request by the Logger middleware,
+// see LoggerWithFormatter. The line should end with a newline.
+type LogFormatter func(params LogFormatterParams) string
+
+// LogFormatterParams is the request given to a LogFormatter.
+type LogFormatterParams struct {
+	Request *http.Request
+
+	// TimeStamp is when the response was written, in UTC with LoggerConfig.UTC.
+	TimeStamp time.Time
+	// Time is TimeStamp formatted with LoggerConfig.TimeFormat, empty when it
+	// is not set: the formatter then formats TimeStamp with a layout of its own.
+	Time string
+	// StatusCode is the HTTP status of the response.
+	StatusCode int
+	// Latency is how long the handlers took, truncated to LoggerConfig.LatencyPrecision.
+	Latency  time.Duration
+	ClientIP string
+	Method   string
+	// Path is the path of the request with its query, if any.
+	Path  string
+	Proto string
+	// BodySize is the size of the response body, -1 when nothing was written.
+	BodySize int
+	// ErrorMessage is the private errors of the context, see ErrorTypePrivate.
+	ErrorMessage string
+	// Keys is a copy of the keys set on the context, see Context.Set.
+	Keys map[string]interface{}
+
+	isTerm bool
+}
+
+// IsOutputColor reports whether the log is written to a terminal taking colors.
+func (p *LogFormatterParams) IsOutputColor() bool {
+	return p.isTerm
+}
+
+// StatusCodeColor returns the escape sequence coloring the status code, empty
+// when the output takes no colors.
+func (p *LogFormatterParams) StatusCodeColor() string {
+	if !p.isTerm {
+		return ""
+	}
+	return colorForStatus(p.StatusCode)
+}
+
+// MethodColor returns the escape sequence coloring the method, empty when the
+// output takes no colors.
+func (p *LogFormatterParams) MethodColor() string {
+	if !p.isTerm {
+		return ""
+	}
+	return colorForMethod(p.Method)
+}
+
+// ResetColor returns the escape sequence resetting the color, empty when the
+// output takes no colors.
+func (p *LogFormatterParams) ResetColor() string {
+	if !p.isTerm {
+		return ""
+	}
+	return reset
+}
+
+// defaultLogTimeFormat is the layout of the time of the lines of Logger.
+const defaultLogTimeFormat = "2006/01/02 - 15:04:05"
+
+// defaultLogFormatter is the colored text of Logger.
+func defaultLogFormatter(params LogFormatterParams) string {
+	timestamp := params.Time
+	if timestamp == "" {
+		timestamp = params.TimeStamp.Format(defaultLogTimeFormat)
+	}
+	return fmt.Sprintf("[GIN] %s |%s %3d %s| %13v | %15s |%s %-7s %s %s\n%s",
+		timestamp,
+		params.StatusCodeColor(), params.StatusCode, params.ResetColor(),
+		params.Latency,
+		params.ClientIP,
+		params.MethodColor(), params.Method, params.ResetColor(),
+		params.Path,
+		params.ErrorMessage,
+	)
+}
+
+// JSONLogFormatter formats a request as one JSON object per line, without
+// colors whatever the output (set LoggerConfig.DisableColor to skip checking
+// for a terminal):
+//
+//	{"time":"2006-01-02T15:04:05.999999999Z07:00","status":200,"latency":1234,
+//	"clientIP":"::1","method":"GET","path":"/a?b=c","proto":"HTTP/1.1",
+//	"bodySize":42,"error":"","keys":{"requestId":"..."}}
+//
+// The time is in time.RFC3339Nano unless LoggerConfig.TimeFormat is set, i.e.
+// when params.Time is empty. The latency is in integer microseconds. A key of
+// the context that can not be encoded in JSON is logged as its fmt.Sprint.
+func JSONLogFormatter(params LogFormatterParams) string {
+	timestamp := params.Time
+	if timestamp == "" {
+		timestamp = params.TimeStamp.Format(time.RFC3339Nano)
+	}
+	keys := make(map[string]interface{}, len(params.Keys))
+	for key, value := range params.Keys {
+		if _, err := json.Marshal(value); err != nil {
+			value = fmt.Sprint(value)
+		}
+		keys[key] = value
+	}
+	line, err := json.Marshal(struct {
+		Time     string                 `json:"time"`
+		Status   int                    `json:"status"`
+		Latency  int64                  `json:"latency"`
+		ClientIP string                 `json:"clientIP"`
+		Method   string                 `json:"method"`
+		Path     string                 `json:"path"`
+		Proto    string                 `json:"proto"`
+		BodySize int                    `json:"bodySize"`
+		Error    string                 `json:"error"`
+		Keys     map[string]interface{} `json:"keys"`
+	}{
+		Time:     timestamp,
+		Status:   params.StatusCode,
+		Latency:  int64(params.Latency / time.Microsecond),
+		ClientIP: params.ClientIP,
+		Method:   params.Method,
+		Path:     params.Path,
+		Proto:    params.Proto,
+		BodySize: params.BodySize,
+		Error:    strings.TrimSpace(params.ErrorMessage),
+		Keys:     keys,
+	})
+	if err != nil {
+		return fmt.Sprintf("{\"error\":%q}\n", err.Error())
+	}
+	return string(line) + "\n"
+}
+
+// LoggerConfig is the configuration of the Logger middleware, see LoggerWithConfig.
+type LoggerConfig struct {
+	// Formatter formats the lines, the colored text of Logger if nil.
+	Formatter LogFormatter
+	// Output is where the lines are written, the writer of the engine if nil.
+	Output io.Writer
+	// SkipPaths are the paths of the requests not logged, matched exactly
+	// without the query, e.g. a health check.
+	SkipPaths []string
+	// Skip, if set, is called after the handlers, and the request is not logged
+	// when it returns true, e.g. to log an HLS playlist only when it failed.
+	Skip func(c *Context) bool
+	// TimeFormat is the layout of LogFormatterParams.Time. If empty, the formatter
+	// picks its own: "2006/01/02 - 15:04:05" for Logger, time.RFC3339Nano for
+	// JSONLogFormatter.
+	TimeFormat string
+	// UTC logs the time in UTC rather than in the local time.
+	UTC bool
+	// LatencyPrecision, if positive, truncates the latency to a multiple of it,
+	// e.g. time.Millisecond.
+	LatencyPrecision time.Duration
+	// DisableColor leaves the output uncolored even on a terminal, without
+	// checking it, e.g. for JSONLogFormatter. See also DisableConsoleColor.
+	DisableColor bool
+}
+
+// Logger instances a Logger middleware that will write the logs to the writer
+// of the engine, see Engine.SetWriter, or to gin.DefaultWriter.
 // By default gin.DefaultWriter = os.Stdout.
 func Logger() HandlerFunc {
-	return LoggerWithWriter(DefaultWriter)
+	return LoggerWithConfig(LoggerConfig{})
+}
+
+// LoggerWithFormatter instances a Logger middleware writing the lines of formatter to
+// the writer of the engine, e.g. LoggerWithFormatter(JSONLogFormatter) for a log pipeline
+// ingesting JSON. Use LoggerWithConfig to also disable the colors.
+func LoggerWithFormatter(formatter LogFormatter, notlogged ...string) HandlerFunc {
+	return LoggerWithConfig(LoggerConfig{
+		Formatter: formatter,
+		SkipPaths: notlogged,
+	})
 }
 
 // LoggerWithWriter instance a Logger middleware with the specified writter buffer.
 // Example: os.Stdout, a file opened in write mode, a socket...
 func LoggerWithWriter(out io.Writer, notlogged ...string) HandlerFunc {
-	isTerm := true
+	return LoggerWithConfig(LoggerConfig{
+		Output:    out,
+		SkipPaths: notlogged,
+	})
+}
+
+// terminals caches isTerm of the *os.File written by the loggers.
+var terminals sync.Map
 
-	if w, ok := out.(*os.File); !ok ||
-		(os.Getenv("TERM") == "dumb" || (!isatty.IsTerminal(w.Fd()) && !isatty.IsCygwinTerminal(w.Fd()))) ||
-		disableColor {
-		isTerm = false
+// isTerm returns true if out is a terminal taking colors.
+func isTerm(out io.Writer) bool {
+	w, ok := out.(*os.File)
+	if !ok || disableColor {
+		return false
 	}
+	if term, ok := terminals.Load(w); ok {
+		return term.(bool)
+	}
+	term := os.Getenv("TERM") != "dumb" && (isatty.IsTerminal(w.Fd()) || isatty.IsCygwinTerminal(w.Fd()))
+	terminals.Store(w, term)
+	return term
+}
 
+// LoggerWithConfig instances a Logger middleware with conf. A skipped request writes
+// nothing, the other middlewares, e.g. Recovery, run for it as usual.
+func LoggerWithConfig(conf LoggerConfig) HandlerFunc {
+	formatter := conf.Formatter
+	if formatter == nil {
+		formatter = defaultLogFormatter
+	}
 	var skip map[string]struct{}
 
-	if length := len(notlogged); length > 0 {
+	if length := len(conf.SkipPaths); length > 0 {
 		skip = make(map[string]struct{}, length)
 
-		for _, path := range notlogged {
+		for _, path := range conf.SkipPaths {
 			skip[path] = struct{}{}
 		}
 	}
@@ -84,35 +282,50 @@ func LoggerWithWriter(out io.Writer, notlogged ...string) HandlerFunc {
 		c.Next()
 
 		// Log only when path is not being skipped
-		if _, ok := skip[path]; !ok {
+		if _, ok := skip[path]; !ok && (conf.Skip == nil || !conf.Skip(c)) {
 			// Stop timer
 			end := time.Now()
 			latency := end.Sub(start)
-
-			clientIP := c.ClientIP()
-			method := c.Request.Method
-			statusCode := c.Writer.Status()
-			var statusColor, methodColor, resetColor string
-			if isTerm {
-				statusColor = colorForStatus(statusCode)
-				methodColor = colorForMethod(method)
-				resetColor = reset
+			if conf.LatencyPrecision > 0 {
+				latency = latency.Truncate(conf.LatencyPrecision)
+			}
+			if conf.UTC {
+				end = end.UTC()
 			}
-			comment := c.Errors.ByType(ErrorTypePrivate).String()
 
+			out := conf.Output
+			if out == nil {
+				out = c.engine.Writer()
+			}
+			params := LogFormatterParams{
+				Request:      c.Request,
+				TimeStamp:    end,
+				StatusCode:   c.Writer.Status(),
+				Latency:      latency,
+				ClientIP:     c.ClientIP(),
+				Method:       c.Request.Method,
+				Path:         path,
+				Proto:        c.Request.Proto,
+				BodySize:     c.Writer.Size(),
+				ErrorMessage: c.Errors.ByType(ErrorTypePrivate).String(),
+			}
+			if len(c.Keys) > 0 {
+				params.Keys = make(map[string]interface{}, len(c.Keys))
+				for key, value := range c.Keys {
+					params.Keys[key] = value
+				}
+			}
+			if conf.TimeFormat != "" {
+				params.Time = end.Format(conf.TimeFormat)
+			}
 			if raw != "" {
-				path = path + "?" + raw
+				params.Path = path + "?" + raw
+			}
+			if !conf.DisableColor {
+				params.isTerm = isTerm(out)
 			}
 
-			fmt.Fprintf(out, "[GIN] %v |%s %3d %s| %13v | %15s |%s %-7s %s %s\n%s",
-				end.Format("2006/01/02 - 15:04:05"),
-				statusColor, statusCode, resetColor,
-				latency,
-				clientIP,
-				methodColor, method, resetColor,
-				path,
-				comment,
-			)
+			fmt.Fprint(out, formatter(params))
 		}
 	}
 }
@@ -0,0 +1,166 @@
+// Copyright 2014 Manu Martinez-Almeida.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package gin
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// captureParams returns a LogFormatter keeping the params of the last line.
+func captureParams(params *LogFormatterParams) LogFormatter {
+	return func(p LogFormatterParams) string {
+		*params = p
+		return "\n"
+	}
+}
+
+func TestLoggerUTCAndLatencyPrecision(t *testing.T) {
+	var params LogFormatterParams
+	router := New()
+	router.Use(LoggerWithConfig(LoggerConfig{
+		Formatter:        captureParams(&params),
+		Output:           new(bytes.Buffer),
+		TimeFormat:       time.RFC3339Nano,
+		UTC:              true,
+		LatencyPrecision: time.Millisecond,
+	}))
+	router.GET("/slow", func(c *Context) {
+		time.Sleep(3 * time.Millisecond)
+	})
+	performRequest(router, "GET", "/slow")
+
+	if params.TimeStamp.Location() != time.UTC || !strings.HasSuffix(params.Time, "Z") {
+		t.Errorf("time = %s in %v, want UTC", params.Time, params.TimeStamp.Location())
+	}
+	if params.Time != params.TimeStamp.Format(time.RFC3339Nano) {
+		t.Errorf("time = %s, want %s", params.Time, params.TimeStamp.Format(time.RFC3339Nano))
+	}
+	if params.Latency < 3*time.Millisecond || params.Latency%time.Millisecond != 0 {
+		t.Errorf("latency = %v, want whole milliseconds", params.Latency)
+	}
+
+	router = New()
+	router.Use(LoggerWithConfig(LoggerConfig{Formatter: captureParams(&params), Output: new(bytes.Buffer)}))
+	router.GET("/fast", func(c *Context) {})
+	performRequest(router, "GET", "/fast")
+	if params.TimeStamp.Location() != time.Local {
+		t.Errorf("location = %v, want local", params.TimeStamp.Location())
+	}
+}
+
+func TestJSONLogFormatterTimeFallback(t *testing.T) {
+	stamp := time.Date(2018, 6, 1, 8, 30, 0, 123456789, time.UTC)
+	var line struct {
+		Time    string `json:"time"`
+		Status  int    `json:"status"`
+		Latency int64  `json:"latency"`
+	}
+	err := json.Unmarshal([]byte(JSONLogFormatter(LogFormatterParams{
+		TimeStamp:  stamp,
+		StatusCode: http.StatusOK,
+		Latency:    1500 * time.Microsecond,
+	})), &line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line.Time != "2018-06-01T08:30:00.123456789Z" || line.Status != 200 || line.Latency != 1500 {
+		t.Fatalf("line = %+v", line)
+	}
+
+	err = json.Unmarshal([]byte(JSONLogFormatter(LogFormatterParams{TimeStamp: stamp, Time: "08:30"})), &line)
+	if err != nil || line.Time != "08:30" {
+		t.Fatalf("time = %s, %v, want the formatted Time kept", line.Time, err)
+	}
+}
+
+// TestJSONLogFormatterParseBack parses a line of a JSON logger back.
+func TestJSONLogFormatterParseBack(t *testing.T) {
+	var out bytes.Buffer
+	router := New()
+	router.Use(LoggerWithConfig(LoggerConfig{Formatter: JSONLogFormatter, Output: &out, DisableColor: true}))
+	router.GET("/streams", func(c *Context) {
+		c.Set("requestId", "r1")
+		c.Set("done", make(chan struct{}))
+		c.Error(errors.New("db down")).SetType(ErrorTypePrivate)
+		c.String(http.StatusServiceUnavailable, "retry")
+	})
+	req := httptest.NewRequest("GET", "/streams?limit=5", nil)
+	req.RemoteAddr = "10.0.0.1:5000"
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	var line struct {
+		Time     string                 `json:"time"`
+		Status   int                    `json:"status"`
+		Latency  int64                  `json:"latency"`
+		ClientIP string                 `json:"clientIP"`
+		Method   string                 `json:"method"`
+		Path     string                 `json:"path"`
+		Proto    string                 `json:"proto"`
+		BodySize int                    `json:"bodySize"`
+		Error    string                 `json:"error"`
+		Keys     map[string]interface{} `json:"keys"`
+	}
+	if !strings.HasSuffix(out.String(), "}\n") || strings.Count(out.String(), "\n") != 1 {
+		t.Fatalf("not one line: %q", out.String())
+	}
+	if err := json.Unmarshal(out.Bytes(), &line); err != nil {
+		t.Fatalf("%v: %s", err, out.String())
+	}
+	if _, err := time.Parse(time.RFC3339Nano, line.Time); err != nil {
+		t.Errorf("time %s: %v", line.Time, err)
+	}
+	if line.Status != http.StatusServiceUnavailable || line.ClientIP != "10.0.0.1" || line.Method != "GET" ||
+		line.Path != "/streams?limit=5" || line.Proto != "HTTP/1.1" || line.BodySize != 5 ||
+		!strings.Contains(line.Error, "db down") || line.Latency < 0 {
+		t.Errorf("line = %+v", line)
+	}
+	if line.Keys["requestId"] != "r1" || !strings.HasPrefix(line.Keys["done"].(string), "0x") {
+		t.Errorf("keys = %v", line.Keys)
+	}
+}
+
+func TestLoggerWithConfigSkip(t *testing.T) {
+	var out bytes.Buffer
+	router := New()
+	router.Use(LoggerWithConfig(LoggerConfig{
+		Output:    &out,
+		SkipPaths: []string{"/health"},
+		// playlists are only logged when they failed
+		Skip: func(c *Context) bool {
+			return strings.HasSuffix(c.Request.URL.Path, ".m3u8") && c.Writer.Status() < http.StatusBadRequest
+		},
+	}))
+	router.GET("/health", func(c *Context) {})
+	router.GET("/hls/:file", func(c *Context) {
+		if c.Param("file") == "missing.m3u8" {
+			c.Status(http.StatusNotFound)
+		}
+	})
+	router.GET("/api/streams", func(c *Context) {})
+
+	for _, path := range []string{"/health", "/health?probe=1", "/hls/live.m3u8"} {
+		out.Reset()
+		if w := performRequest(router, "GET", path); w.Code != http.StatusOK {
+			t.Fatalf("%s: code %d", path, w.Code)
+		}
+		if out.Len() != 0 {
+			t.Errorf("%s logged: %s", path, out.String())
+		}
+	}
+	for _, path := range []string{"/api/streams", "/hls/missing.m3u8"} {
+		out.Reset()
+		performRequest(router, "GET", path)
+		if !strings.HasPrefix(out.String(), "[GIN] ") || !strings.Contains(out.String(), path) {
+			t.Errorf("%s not logged: %q", path, out.String())
+		}
+	}
+}
@@ -5,6 +5,7 @@
 package gin
 
 import (
+	"fmt"
 	"io"
 	"os"
 
@@ -39,24 +40,47 @@ var modeName = DebugMode
 
 func init() {
 	mode := os.Getenv(ENV_GIN_MODE)
-	SetMode(mode)
+	if err := TrySetMode(mode); err != nil {
+		// a typo in the environment must not crash the process before main
+		SetMode(DebugMode)
+		debugPrint("[WARNING] %s=%q: %v, using %q mode\n", ENV_GIN_MODE, mode, err, DebugMode)
+	}
 }
 
+// SetMode sets the default mode of engines, see TrySetMode. It panics on an
+// unknown mode.
 func SetMode(value string) {
-	switch value {
-	case DebugMode, "":
-		ginMode = debugCode
-	case ReleaseMode:
-		ginMode = releaseCode
-	case TestMode:
-		ginMode = testCode
-	default:
-		panic("gin mode unknown: " + value)
+	if err := TrySetMode(value); err != nil {
+		panic(err.Error())
+	}
+}
+
+// TrySetMode sets the default mode of engines that do not set their own with
+// Engine.SetMode. Empty means DebugMode. An unknown mode returns an error and
+// leaves the mode unchanged.
+func TrySetMode(value string) error {
+	code, err := parseMode(value)
+	if err != nil {
+		return err
 	}
 	if value == "" {
 		value = DebugMode
 	}
+	ginMode = code
 	modeName = value
+	return nil
+}
+
+func parseMode(value string) (int, error) {
+	switch value {
+	case DebugMode, "":
+		return debugCode, nil
+	case ReleaseMode:
+		return releaseCode, nil
+	case TestMode:
+		return testCode, nil
+	}
+	return 0, fmt.Errorf("gin mode unknown: %s", value)
 }
 
 func DisableBindValidation() {
@@ -67,6 +91,82 @@ func EnableJsonDecoderUseNumber() {
 	binding.EnableDecoderUseNumber = true
 }
 
+// EnableJsonDecoderDisallowUnknownFields makes JSON binding fail on fields
+// of the body that are not in the bound struct.
+func EnableJsonDecoderDisallowUnknownFields() {
+	binding.EnableDecoderDisallowUnknownFields = true
+}
+
 func Mode() string {
 	return modeName
 }
+
+// SetMode sets the mode of the engine, used for its debug output and to
+// reload HTML templates in debug mode. Empty falls back to the package mode
+// set with SetMode. An unknown mode returns an error.
+func (engine *Engine) SetMode(value string) error {
+	if _, err := parseMode(value); err != nil {
+		return err
+	}
+	engine.mode = value
+	return nil
+}
+
+// Mode returns the mode of the engine, the package mode unless set with
+// Engine.SetMode.
+func (engine *Engine) Mode() string {
+	if engine.mode != "" {
+		return engine.mode
+	}
+	return Mode()
+}
+
+// IsDebugging returns true if the engine is running in debug mode.
+func (engine *Engine) IsDebugging() bool {
+	return engine.Mode() == DebugMode
+}
+
+// SetWriter sets the writer of the debug output and of the Logger()
+// middleware of the engine. A nil w restores DefaultWriter. It is safe to
+// call while the engine is serving.
+func (engine *Engine) SetWriter(w io.Writer) {
+	engine.writersMu.Lock()
+	engine.writer = w
+	engine.writersMu.Unlock()
+}
+
+// SetErrorWriter sets the writer of the Recovery() middleware of the
+// engine. A nil w restores DefaultErrorWriter.
+func (engine *Engine) SetErrorWriter(w io.Writer) {
+	engine.writersMu.Lock()
+	engine.errorWriter = w
+	engine.writersMu.Unlock()
+}
+
+// Writer returns the writer set with SetWriter, DefaultWriter otherwise.
+// It may be called on a nil engine.
+func (engine *Engine) Writer() io.Writer {
+	if engine != nil {
+		engine.writersMu.RLock()
+		w := engine.writer
+		engine.writersMu.RUnlock()
+		if w != nil {
+			return w
+		}
+	}
+	return DefaultWriter
+}
+
+// ErrorWriter returns the writer set with SetErrorWriter,
+// DefaultErrorWriter otherwise. It may be called on a nil engine.
+func (engine *Engine) ErrorWriter() io.Writer {
+	if engine != nil {
+		engine.writersMu.RLock()
+		w := engine.errorWriter
+		engine.writersMu.RUnlock()
+		if w != nil {
+			return w
+		}
+	}
+	return DefaultErrorWriter
+}
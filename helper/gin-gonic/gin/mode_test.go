@@ -0,0 +1,79 @@
+package gin
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestTrySetMode(t *testing.T) {
+	defer SetMode(TestMode)
+
+	if err := TrySetMode("relase"); err == nil || !strings.Contains(err.Error(), "relase") {
+		t.Fatalf("TrySetMode of a typo err = %v", err)
+	}
+	if Mode() != TestMode {
+		t.Fatalf("mode = %s after a failed TrySetMode, want it unchanged", Mode())
+	}
+	if err := TrySetMode(""); err != nil || Mode() != DebugMode || !IsDebugging() {
+		t.Fatalf("TrySetMode(\"\") err %v mode %s, want debug", err, Mode())
+	}
+	defer func() {
+		if recover() == nil {
+			t.Fatal("SetMode of a typo did not panic")
+		}
+	}()
+	SetMode("relase")
+}
+
+// Run by TestModeEnvTypo in a process of its own, where init saw the typo.
+func TestModeEnvTypoHelper(t *testing.T) {
+	if os.Getenv("GIN_MODE_TEST_HELPER") != "1" {
+		t.Skip("run by TestModeEnvTypo")
+	}
+}
+
+func TestModeEnvTypo(t *testing.T) {
+	cmd := exec.Command(os.Args[0], "-test.run=TestModeEnvTypoHelper$", "-test.v")
+	cmd.Env = append(os.Environ(), "GIN_MODE_TEST_HELPER=1", ENV_GIN_MODE+"=relase")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("GIN_MODE typo crashed the process: %v\n%s", err, out)
+	}
+	if !strings.Contains(string(out), `[WARNING] GIN_MODE="relase": gin mode unknown: relase, using "debug" mode`) {
+		t.Fatalf("output = %s, want a warning and debug mode", out)
+	}
+}
+
+func TestEngineMode(t *testing.T) {
+	var buf bytes.Buffer
+	defer func(w io.Writer) { DefaultWriter = w }(DefaultWriter)
+	DefaultWriter = &buf
+
+	release, debug := New(), New()
+	if err := release.SetMode(ReleaseMode); err != nil {
+		t.Fatal(err)
+	}
+	if err := debug.SetMode(DebugMode); err != nil {
+		t.Fatal(err)
+	}
+	if err := debug.SetMode("relase"); err == nil || debug.Mode() != DebugMode {
+		t.Fatalf("SetMode of a typo err = %v, mode %s", err, debug.Mode())
+	}
+	release.GET("/release", func(c *Context) {})
+	debug.GET("/debug", func(c *Context) {})
+
+	if out := buf.String(); strings.Contains(out, "/release") || !strings.Contains(out, "/debug") {
+		t.Fatalf("route output = %q, want only the debug engine's route", out)
+	}
+	if release.IsDebugging() || !debug.IsDebugging() {
+		t.Fatal("IsDebugging does not follow the engine mode")
+	}
+	// without a mode of its own an engine follows the package mode
+	if engine := New(); engine.Mode() != TestMode {
+		t.Fatalf("engine mode = %s, want the package mode", engine.Mode())
+	}
+}
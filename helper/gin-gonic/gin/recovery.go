@@ -10,9 +10,12 @@ import (
 	"io"
 	"io/ioutil"
 	"log"
+	"net"
 	"net/http"
 	"net/http/httputil"
+	"os"
 	"runtime"
+	"strings"
 	"time"
 )
 
@@ -23,32 +26,169 @@ var (
 	slash     = []byte("/")
 )
 
+// RecoveryFunc answers a request whose handlers panicked with err, see
+// RecoveryWithHandler.
+type RecoveryFunc func(c *Context, err interface{})
+
+// RecoveryConfig is the configuration of the Recovery middleware, see RecoveryWithConfig.
+type RecoveryConfig struct {
+	// Output is where the panics are logged, the error writer of the engine if nil,
+	// see Engine.SetErrorWriter.
+	Output io.Writer
+	// Handler writes the response of a request that panicked, a bare 500 if nil.
+	// See RecoveryWithHandler.
+	Handler RecoveryFunc
+	// MaxBodyDump is the number of bytes of the request body logged with the
+	// panic, none if 0. The body is only known when it was read through
+	// ShouldBindBodyWith: Recovery does not read the body itself.
+	MaxBodyDump int
+	// RedactHeaders are the request headers whose values are logged as "*",
+	// DefaultRedactHeaders if nil.
+	RedactHeaders []string
+}
+
+// DefaultRedactHeaders are the headers redacted from the requests logged by Recovery.
+var DefaultRedactHeaders = []string{"Authorization", "Cookie", "Set-Cookie"}
+
 // Recovery returns a middleware that recovers from any panics and writes a 500 if there was one.
+// The panics are logged to the error writer of the engine, see Engine.SetErrorWriter,
+// or to DefaultErrorWriter, with the request line and headers, see RecoveryConfig.
+// Nothing is logged when DefaultErrorWriter is nil.
 func Recovery() HandlerFunc {
-	return RecoveryWithWriter(DefaultErrorWriter)
+	return RecoveryWithConfig(RecoveryConfig{})
+}
+
+// RecoveryWithHandler is like Recovery, but the response is written by handle, called
+// with the panic value once the stack is logged, e.g. to write a JSON error envelope
+// and report the panic to an error tracker. When handle writes nothing, the response
+// is a 500. handle is not called when the client went away (broken pipe or connection
+// reset): nothing can be written, the request is aborted with the error.
+func RecoveryWithHandler(handle RecoveryFunc) HandlerFunc {
+	return RecoveryWithConfig(RecoveryConfig{Handler: handle})
 }
 
 // RecoveryWithWriter returns a middleware for a given writer that recovers from any panics and writes a 500 if there was one.
 func RecoveryWithWriter(out io.Writer) HandlerFunc {
+	return CustomRecoveryWithWriter(out, defaultHandleRecovery)
+}
+
+// CustomRecoveryWithWriter is like RecoveryWithHandler, but the panics are logged to out.
+func CustomRecoveryWithWriter(out io.Writer, handle RecoveryFunc) HandlerFunc {
+	if out == nil {
+		out = ioutil.Discard
+	}
+	return RecoveryWithConfig(RecoveryConfig{Output: out, Handler: handle})
+}
+
+func defaultHandleRecovery(c *Context, err interface{}) {
+	c.AbortWithStatus(http.StatusInternalServerError)
+}
+
+func newRecoveryLogger(out io.Writer) *log.Logger {
+	return log.New(out, "\n\n\x1b[31m", log.LstdFlags)
+}
+
+// RecoveryWithConfig instances a Recovery middleware with conf.
+func RecoveryWithConfig(conf RecoveryConfig) HandlerFunc {
+	handle := conf.Handler
+	if handle == nil {
+		handle = defaultHandleRecovery
+	}
+	redact := conf.RedactHeaders
+	if redact == nil {
+		redact = DefaultRedactHeaders
+	}
 	var logger *log.Logger
-	if out != nil {
-		logger = log.New(out, "\n\n\x1b[31m", log.LstdFlags)
+	if conf.Output != nil {
+		logger = newRecoveryLogger(conf.Output)
 	}
+
 	return func(c *Context) {
 		defer func() {
 			if err := recover(); err != nil {
-				if logger != nil {
+				brokenPipe := isBrokenPipe(err)
+				l := logger
+				if l == nil {
+					// DefaultErrorWriter set to nil turns the logs off
+					if out := c.engine.ErrorWriter(); out != nil {
+						l = newRecoveryLogger(out)
+					}
+				}
+				if brokenPipe {
+					if l != nil {
+						httprequest := dumpRequest(c, redact, conf.MaxBodyDump)
+						l.Printf("[Recovery] %s client went away:\n%s\n%s%s", timeFormat(time.Now()), httprequest, err, reset)
+					}
+					// The connection is dead, no response can be written.
+					c.Error(err.(error))
+					c.Abort()
+					return
+				}
+				if l != nil {
+					httprequest := dumpRequest(c, redact, conf.MaxBodyDump)
 					stack := stack(3)
-					httprequest, _ := httputil.DumpRequest(c.Request, false)
-					logger.Printf("[Recovery] %s panic recovered:\n%s\n%s\n%s%s", timeFormat(time.Now()), string(httprequest), err, stack, reset)
+					l.Printf("[Recovery] %s panic recovered:\n%s\n%s\n%s%s", timeFormat(time.Now()), httprequest, err, stack, reset)
 				}
-				c.AbortWithStatus(http.StatusInternalServerError)
+				handle(c, err)
+				if !c.Writer.Written() {
+					c.AbortWithStatus(http.StatusInternalServerError)
+				}
+				c.Abort()
 			}
 		}()
 		c.Next()
 	}
 }
 
+// dumpRequest returns the request line and headers of the request of c, the
+// values of the headers of redact replaced with "*", and up to maxBody bytes
+// of the body cached by ShouldBindBodyWith.
+func dumpRequest(c *Context, redact []string, maxBody int) string {
+	req := *c.Request
+	req.Header = make(http.Header, len(c.Request.Header))
+	for key, values := range c.Request.Header {
+		req.Header[key] = values
+	}
+	for _, key := range redact {
+		key = http.CanonicalHeaderKey(key)
+		if values, ok := req.Header[key]; ok {
+			redacted := make([]string, len(values))
+			for i := range redacted {
+				redacted[i] = "*"
+			}
+			req.Header[key] = redacted
+		}
+	}
+	dump, _ := httputil.DumpRequest(&req, false)
+	if maxBody <= 0 {
+		return string(dump)
+	}
+	cb, _ := c.Get(BodyBytesKey)
+	body, _ := cb.([]byte)
+	if len(body) > maxBody {
+		return fmt.Sprintf("%s%s... (%d more bytes)\n", dump, body[:maxBody], len(body)-maxBody)
+	}
+	if len(body) > 0 {
+		return fmt.Sprintf("%s%s\n", dump, body)
+	}
+	return string(dump)
+}
+
+// isBrokenPipe reports whether the panic err is the failure to write to a
+// client that closed the connection.
+func isBrokenPipe(err interface{}) bool {
+	ne, ok := err.(*net.OpError)
+	if !ok {
+		return false
+	}
+	se, ok := ne.Err.(*os.SyscallError)
+	if !ok {
+		return false
+	}
+	msg := strings.ToLower(se.Error())
+	return strings.Contains(msg, "broken pipe") || strings.Contains(msg, "connection reset by peer")
+}
+
 // stack returns a nicely formatted stack frame, skipping skip frames.
 func stack(skip int) []byte {
 	buf := new(bytes.Buffer) // the returned data
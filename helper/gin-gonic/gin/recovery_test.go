@@ -0,0 +1,130 @@
+// Copyright 2014 Manu Martinez-Almeida.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package gin
+
+import (
+	"bytes"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+
+	"EasyDarwin/helper/gin-gonic/gin/binding"
+)
+
+func TestRecoveryDumpsRedactedRequest(t *testing.T) {
+	var out bytes.Buffer
+	router := New()
+	router.Use(RecoveryWithConfig(RecoveryConfig{Output: &out, MaxBodyDump: 64}))
+	router.POST("/login", func(c *Context) {
+		var login struct {
+			User string `json:"user"`
+		}
+		c.ShouldBindBodyWith(&login, binding.JSON)
+		panic("login of " + login.User + " failed")
+	})
+
+	req := httptest.NewRequest("POST", "/login", strings.NewReader(`{"user":"admin"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer s3cr3t")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("code = %d", w.Code)
+	}
+	log := out.String()
+	for _, want := range []string{"POST /login HTTP/1.1", "Authorization: *", `{"user":"admin"}`, "login of admin failed"} {
+		if !strings.Contains(log, want) {
+			t.Errorf("log misses %q:\n%s", want, log)
+		}
+	}
+	if strings.Contains(log, "s3cr3t") {
+		t.Errorf("log holds the Authorization value:\n%s", log)
+	}
+}
+
+func TestRecoveryNilErrorWriter(t *testing.T) {
+	saved := DefaultErrorWriter
+	DefaultErrorWriter = nil
+	defer func() { DefaultErrorWriter = saved }()
+
+	router := New()
+	router.Use(Recovery())
+	router.GET("/panic", func(c *Context) { panic("failed") })
+	if w := performRequest(router, "GET", "/panic"); w.Code != http.StatusInternalServerError {
+		t.Fatalf("code = %d", w.Code)
+	}
+}
+
+func TestRecoveryWithHandlerJSON(t *testing.T) {
+	router := New()
+	router.SetErrorWriter(new(bytes.Buffer))
+	var recovered interface{}
+	router.Use(RecoveryWithHandler(func(c *Context, err interface{}) {
+		recovered = err
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, H{"code": 503, "msg": "stream store down"})
+	}))
+	router.GET("/streams", func(c *Context) { panic("store down") })
+
+	w := performRequest(router, "GET", "/streams")
+	if w.Code != http.StatusServiceUnavailable || w.Body.String() != `{"code":503,"msg":"stream store down"}` {
+		t.Fatalf("got %d %s", w.Code, w.Body.String())
+	}
+	if recovered != "store down" {
+		t.Fatalf("handler called with %v", recovered)
+	}
+
+	// a handler writing nothing gets the default 500
+	router = New()
+	router.SetErrorWriter(new(bytes.Buffer))
+	router.Use(RecoveryWithHandler(func(c *Context, err interface{}) {}))
+	router.GET("/streams", func(c *Context) { panic("store down") })
+	if w := performRequest(router, "GET", "/streams"); w.Code != http.StatusInternalServerError {
+		t.Fatalf("silent handler: got %d", w.Code)
+	}
+}
+
+func TestRecoveryWithHandlerBrokenPipe(t *testing.T) {
+	var out bytes.Buffer
+	router := New()
+	router.SetErrorWriter(&out)
+	called := false
+	var errs []*Error
+	router.Use(func(c *Context) {
+		c.Next()
+		errs = c.Errors
+	}, RecoveryWithHandler(func(c *Context, err interface{}) {
+		called = true
+	}))
+	errnos := map[string]syscall.Errno{"/epipe": syscall.EPIPE, "/econnreset": syscall.ECONNRESET}
+	for path, errno := range errnos {
+		errno := errno
+		router.GET(path, func(c *Context) {
+			panic(&net.OpError{Op: "write", Net: "tcp", Err: os.NewSyscallError("write", errno)})
+		})
+	}
+
+	for path, errno := range errnos {
+		out.Reset()
+		called, errs = false, nil
+		w := performRequest(router, "GET", path)
+		if called {
+			t.Errorf("%v: handler called", errno)
+		}
+		if w.Body.Len() != 0 {
+			t.Errorf("%v: body written %q", errno, w.Body.String())
+		}
+		if len(errs) != 1 || !strings.Contains(errs[0].Error(), errno.Error()) {
+			t.Errorf("%v: context errors %v", errno, errs)
+		}
+		if !strings.Contains(out.String(), "client went away") || strings.Contains(out.String(), "panic recovered") {
+			t.Errorf("%v: logged %s", errno, out.String())
+		}
+	}
+}
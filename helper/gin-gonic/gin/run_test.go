@@ -0,0 +1,216 @@
+// Copyright 2014 Manu Martinez-Almeida.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package gin
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// freeAddr returns a local address nothing listens on.
+func freeAddr(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	return listener.Addr().String()
+}
+
+// TestRunWithContextDrains cancels the context during a slow request: the
+// request completes while new connections are refused.
+func TestRunWithContextDrains(t *testing.T) {
+	started := make(chan struct{})
+	router := New()
+	router.GET("/slow", func(c *Context) {
+		close(started)
+		time.Sleep(300 * time.Millisecond)
+		c.String(http.StatusOK, "done")
+	})
+	router.GET("/ping", func(c *Context) {
+		c.String(http.StatusOK, "pong")
+	})
+
+	addr := freeAddr(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ran := make(chan error, 1)
+	go func() {
+		ran <- router.RunWithContext(ctx, addr)
+	}()
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+	for start := time.Now(); ; time.Sleep(10 * time.Millisecond) {
+		if res, err := client.Get("http://" + addr + "/ping"); err == nil {
+			res.Body.Close()
+			break
+		}
+		if time.Since(start) > 2*time.Second {
+			t.Fatal("server not up")
+		}
+	}
+
+	type result struct {
+		body string
+		err  error
+	}
+	slow := make(chan result, 1)
+	go func() {
+		res, err := client.Get("http://" + addr + "/slow")
+		if err != nil {
+			slow <- result{err: err}
+			return
+		}
+		defer res.Body.Close()
+		body, err := ioutil.ReadAll(res.Body)
+		slow <- result{string(body), err}
+	}()
+	<-started
+	cancel()
+
+	for start := time.Now(); ; time.Sleep(10 * time.Millisecond) {
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			break
+		}
+		conn.Close()
+		if time.Since(start) > 200*time.Millisecond {
+			t.Fatal("new connections still accepted after cancel")
+		}
+	}
+	select {
+	case <-ran:
+		t.Fatal("RunWithContext returned before the slow request completed")
+	default:
+	}
+
+	if r := <-slow; r.err != nil || r.body != "done" {
+		t.Fatalf("slow request = %q, %v", r.body, r.err)
+	}
+	select {
+	case err := <-ran:
+		if err != nil {
+			t.Fatalf("RunWithContext = %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("RunWithContext did not return once drained")
+	}
+}
+
+// unixClient returns a client dialing the unix socket file.
+func unixClient(file string) *http.Client {
+	return &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return new(net.Dialer).DialContext(ctx, "unix", file)
+		},
+	}}
+}
+
+// getBody GETs url with client, retrying until the server is up.
+func getBody(t *testing.T, client *http.Client, url string) string {
+	for start := time.Now(); ; time.Sleep(10 * time.Millisecond) {
+		res, err := client.Get(url)
+		if err == nil {
+			body, _ := ioutil.ReadAll(res.Body)
+			res.Body.Close()
+			return string(body)
+		}
+		if time.Since(start) > 2*time.Second {
+			t.Fatalf("GET %s: %v", url, err)
+		}
+	}
+}
+
+func newPingEngine() *Engine {
+	router := New()
+	router.GET("/ping", func(c *Context) {
+		c.String(http.StatusOK, "pong")
+	})
+	return router
+}
+
+func TestRunUnix(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "gin.sock")
+	// a socket file left by a previous run
+	if err := ioutil.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	ran := make(chan error, 1)
+	go func() {
+		ran <- newPingEngine().RunUnix(file, 0660)
+	}()
+	if body := getBody(t, unixClient(file), "http://gin/ping"); body != "pong" {
+		t.Fatalf("body = %q", body)
+	}
+	info, err := os.Stat(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode()&os.ModeSocket == 0 || info.Mode().Perm() != 0660 {
+		t.Fatalf("socket mode = %v, want 0660", info.Mode())
+	}
+	// nothing is left of the private directory of the socket
+	if entries, _ := ioutil.ReadDir(dir); len(entries) != 1 {
+		t.Fatalf("%d files in the socket directory", len(entries))
+	}
+	select {
+	case err := <-ran:
+		t.Fatalf("RunUnix = %v", err)
+	default:
+	}
+}
+
+func TestRunUnixError(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "missing", "gin.sock")
+	if err := newPingEngine().RunUnix(file, 0660); err == nil {
+		t.Fatal("no error for a socket in a missing directory")
+	}
+}
+
+func TestRunFd(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	f, err := listener.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	go newPingEngine().RunFd(int(f.Fd()))
+	if body := getBody(t, http.DefaultClient, "http://"+listener.Addr().String()+"/ping"); body != "pong" {
+		t.Fatalf("body = %q", body)
+	}
+}
+
+func TestRunListener(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ran := make(chan error, 1)
+	go func() {
+		ran <- newPingEngine().RunListener(listener)
+	}()
+	if body := getBody(t, http.DefaultClient, "http://"+listener.Addr().String()+"/ping"); body != "pong" {
+		t.Fatalf("body = %q", body)
+	}
+	listener.Close()
+	select {
+	case err := <-ran:
+		if err == nil {
+			t.Fatal("RunListener returned no error once its listener closed")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("RunListener did not return once its listener closed")
+	}
+}
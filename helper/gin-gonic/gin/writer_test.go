@@ -0,0 +1,86 @@
+package gin
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// syncBuffer is a bytes.Buffer safe for the writes of concurrent requests.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func newWriterEngine(name string, out, errOut *syncBuffer) *Engine {
+	engine := New()
+	engine.SetMode(DebugMode)
+	engine.SetWriter(out)
+	engine.SetErrorWriter(errOut)
+	engine.Use(Logger(), Recovery())
+	engine.GET("/"+name, func(c *Context) {})
+	engine.GET("/"+name+"/panic", func(c *Context) { panic(name + " failed") })
+	return engine
+}
+
+func TestEngineWriters(t *testing.T) {
+	var outA, errA, outB, errB syncBuffer
+	a := newWriterEngine("a", &outA, &errA)
+	b := newWriterEngine("b", &outB, &errB)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() { defer wg.Done(); performRequest(a, "GET", "/a") }()
+		go func() { defer wg.Done(); performRequest(b, "GET", "/b") }()
+	}
+	wg.Wait()
+	performRequest(a, "GET", "/a/panic")
+	performRequest(b, "GET", "/b/panic")
+
+	for _, test := range []struct {
+		out, errOut  *syncBuffer
+		mine, theirs string
+	}{
+		{&outA, &errA, "/a", "/b"},
+		{&outB, &errB, "/b", "/a"},
+	} {
+		out := test.out.String()
+		if strings.Contains(out, test.theirs) {
+			t.Fatalf("output of the %s engine has lines of the other: %s", test.mine, out)
+		}
+		// the route debug lines and one access line per request
+		if !strings.Contains(out, "[GIN-debug] GET    "+test.mine) || strings.Count(out, "| GET      "+test.mine) < 10 {
+			t.Fatalf("output of the %s engine = %s", test.mine, out)
+		}
+		errOut := test.errOut.String()
+		if !strings.Contains(errOut, test.mine[1:]+" failed") || strings.Contains(errOut, test.theirs[1:]+" failed") {
+			t.Fatalf("error output of the %s engine = %s", test.mine, errOut)
+		}
+	}
+
+	// without writers of its own an engine uses the defaults
+	var def bytes.Buffer
+	engine := New()
+	if engine.Writer() != DefaultWriter || engine.ErrorWriter() != DefaultErrorWriter {
+		t.Fatal("engine without writers does not use the defaults")
+	}
+	engine.SetWriter(&def)
+	engine.SetWriter(nil)
+	if engine.Writer() != DefaultWriter {
+		t.Fatal("SetWriter(nil) does not restore DefaultWriter")
+	}
+}
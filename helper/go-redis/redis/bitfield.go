@@ -0,0 +1,127 @@
+package redis
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// BitField builds a BITFIELD command. Sub-commands are validated as they
+// are added; the first invalid one is reported by Exec without sending
+// anything to Redis.
+//
+//	client.BitField("key").Get("u8", 0).Set("u8", 8, 255).IncrBy("i16", 16, -3).Overflow("SAT").Exec()
+type BitField struct {
+	process func(cmd Cmder) error
+	args    []interface{}
+	err     error
+}
+
+func (c *cmdable) BitField(key string) *BitField {
+	return &BitField{
+		process: c.process,
+		args:    []interface{}{"bitfield", key},
+	}
+}
+
+// Get appends GET type offset. Offset is an integer bit offset or a string,
+// where "#N" means N times the width of type.
+func (b *BitField) Get(typ string, offset interface{}) *BitField {
+	return b.add("get", typ, offset)
+}
+
+// Set appends SET type offset value.
+func (b *BitField) Set(typ string, offset interface{}, value int64) *BitField {
+	return b.add("set", typ, offset, value)
+}
+
+// IncrBy appends INCRBY type offset increment.
+func (b *BitField) IncrBy(typ string, offset interface{}, increment int64) *BitField {
+	return b.add("incrby", typ, offset, increment)
+}
+
+// Overflow appends OVERFLOW WRAP|SAT|FAIL, which applies to the SET and
+// INCRBY sub-commands that follow it.
+func (b *BitField) Overflow(mode string) *BitField {
+	if b.err != nil {
+		return b
+	}
+	switch m := strings.ToUpper(mode); m {
+	case "WRAP", "SAT", "FAIL":
+		b.args = append(b.args, "overflow", m)
+	default:
+		b.err = fmt.Errorf("redis: BITFIELD overflow mode %q is not WRAP, SAT or FAIL", mode)
+	}
+	return b
+}
+
+// Exec sends the command. The reply has one entry per GET, SET and INCRBY,
+// and the entry is nil for an operation skipped by OVERFLOW FAIL.
+func (b *BitField) Exec() *IntSliceCmd {
+	cmd := NewIntSliceCmd(b.args...)
+	if b.err != nil {
+		cmd.setErr(b.err)
+		return cmd
+	}
+	b.process(cmd)
+	return cmd
+}
+
+func (b *BitField) add(op, typ string, offset interface{}, value ...interface{}) *BitField {
+	if b.err != nil {
+		return b
+	}
+	if err := checkBitFieldType(typ); err != nil {
+		b.err = err
+		return b
+	}
+	off, err := bitFieldOffset(offset)
+	if err != nil {
+		b.err = err
+		return b
+	}
+	b.args = append(b.args, op, typ, off)
+	b.args = append(b.args, value...)
+	return b
+}
+
+// checkBitFieldType accepts u1 to u63 and i1 to i64.
+func checkBitFieldType(typ string) error {
+	if len(typ) < 2 || (typ[0] != 'u' && typ[0] != 'i') {
+		return fmt.Errorf("redis: BITFIELD type %q must be u<bits> or i<bits>", typ)
+	}
+	bits, err := strconv.Atoi(typ[1:])
+	max := 64
+	if typ[0] == 'u' {
+		max = 63
+	}
+	if err != nil || bits < 1 || bits > max {
+		return fmt.Errorf("redis: BITFIELD type %q must have 1 to %d bits", typ, max)
+	}
+	return nil
+}
+
+func bitFieldOffset(offset interface{}) (string, error) {
+	var n int64
+	switch v := offset.(type) {
+	case int:
+		n = int64(v)
+	case int64:
+		n = v
+	case uint:
+		n = int64(v)
+	case string:
+		digits := strings.TrimPrefix(v, "#")
+		var err error
+		if n, err = strconv.ParseInt(digits, 10, 64); err != nil || n < 0 {
+			return "", fmt.Errorf("redis: BITFIELD offset %q must be N or #N with N >= 0", v)
+		}
+		return v, nil
+	default:
+		return "", fmt.Errorf("redis: BITFIELD offset must be an integer or a string, got %T", offset)
+	}
+	if n < 0 {
+		return "", fmt.Errorf("redis: BITFIELD offset %d must not be negative", n)
+	}
+	return strconv.FormatInt(n, 10), nil
+}
@@ -0,0 +1,62 @@
+package redis
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBitField(t *testing.T) {
+	srv := newFakeServer(t, func(conn int, args []string) string {
+		// GET, then an INCRBY skipped by OVERFLOW FAIL, then SET
+		return respArray(respInt(5), respNil, respInt(-3))
+	})
+	client := NewClient(&Options{Addr: srv.Addr})
+	defer client.Close()
+
+	vals, err := client.BitField("key").
+		Get("u8", 0).
+		Overflow("fail").
+		IncrBy("u2", "#1", 1).
+		Set("i16", int64(16), -3).
+		Exec().Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmds := srv.Cmds()
+	want := "bitfield key get u8 0 overflow FAIL incrby u2 #1 1 set i16 16 -3"
+	if got := strings.Join(cmds[len(cmds)-1].Args, " "); got != want {
+		t.Fatalf("command = %s, want %s", got, want)
+	}
+	if len(vals) != 3 || vals[0] == nil || *vals[0] != 5 || vals[1] != nil || vals[2] == nil || *vals[2] != -3 {
+		t.Fatalf("reply = %v", vals)
+	}
+}
+
+func TestBitFieldInvalid(t *testing.T) {
+	srv := newFakeServer(t, nil)
+	client := NewClient(&Options{Addr: srv.Addr})
+	defer client.Close()
+
+	tests := []struct {
+		bf  *BitField
+		err string
+	}{
+		{client.BitField("key").Get("x8", 0), `type "x8"`},
+		{client.BitField("key").Get("u64", 0), `type "u64" must have 1 to 63 bits`},
+		{client.BitField("key").Set("i65", 0, 1), `type "i65" must have 1 to 64 bits`},
+		{client.BitField("key").Get("u8", -1), "offset -1 must not be negative"},
+		{client.BitField("key").Get("u8", "#x"), `offset "#x"`},
+		{client.BitField("key").Get("u8", 1.5), "got float64"},
+		{client.BitField("key").Overflow("clamp"), `overflow mode "clamp"`},
+		// the first error wins
+		{client.BitField("key").Overflow("clamp").Get("x8", 0), "overflow mode"},
+	}
+	for _, test := range tests {
+		if err := test.bf.Exec().Err(); err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("err = %v, want %s", err, test.err)
+		}
+	}
+	if n := len(srv.Cmds()); n != 0 {
+		t.Fatalf("%d commands sent, want none", n)
+	}
+}
@@ -0,0 +1,393 @@
+package redis
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"EasyDarwin/helper/go-redis/redis/internal"
+	"EasyDarwin/helper/go-redis/redis/internal/pool"
+)
+
+// DefaultCachedCommands are the commands cached by default with Options.Cache.
+var DefaultCachedCommands = []string{"get", "hgetall"}
+
+// CacheOptions configure the in-process cache of a Client, see Options.Cache.
+type CacheOptions struct {
+	// Maximum number of cached replies; the least recently used reply
+	// is evicted first.
+	// Default is 1000.
+	Size int
+	// Time a reply is served from the cache.
+	// Default is 1 minute.
+	TTL time.Duration
+	// Read-only commands whose replies are cached. The key must be their
+	// first argument.
+	// Default is DefaultCachedCommands.
+	Commands []string
+}
+
+func (opt *CacheOptions) init() {
+	if opt.Size == 0 {
+		opt.Size = 1000
+	}
+	if opt.TTL == 0 {
+		opt.TTL = time.Minute
+	}
+	if opt.Commands == nil {
+		opt.Commands = DefaultCachedCommands
+	}
+}
+
+// CacheStats are the counters of the Client cache.
+type CacheStats struct {
+	Hits          uint64 // replies served from the cache
+	Misses        uint64 // replies read from the server
+	Invalidations uint64 // keys dropped because they changed
+}
+
+type bypassCacheKey struct{}
+
+// WithoutCache returns a context that makes a Client with Options.Cache read
+// from the server, e.g. client.WithContext(WithoutCache(ctx)).Get(key).
+// The reply is not cached either.
+func WithoutCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, bypassCacheKey{}, true)
+}
+
+func isCacheBypassed(ctx context.Context) bool {
+	bypass, _ := ctx.Value(bypassCacheKey{}).(bool)
+	return bypass
+}
+
+type cacheEntry struct {
+	id        string // command type and args
+	key       string
+	cmd       Cmder
+	expiresAt time.Time
+}
+
+// clientCache caches replies of read commands of a Client. Entries are
+// dropped when the Client writes their key, or when the server notifies
+// a change of the key on its keyspace channel.
+type clientCache struct {
+	opt           *CacheOptions
+	channelPrefix string
+	commands      map[string]struct{}
+	cmdsInfoCache *cmdsInfoCache
+
+	mu       sync.Mutex
+	lru      *list.List
+	entries  map[string]*list.Element       // id -> entry
+	keys     map[string]map[string]struct{} // key -> ids
+	inflight map[string]int                 // key -> reads being sent
+	dirty    map[string]struct{}            // keys changed while being read
+
+	subMu      sync.Mutex
+	pubsub     *PubSub
+	subscribed map[string]struct{}
+
+	hits, misses, invalidations uint64
+}
+
+func newClientCache(c *Client) *clientCache {
+	opt := *c.opt.Cache
+	opt.init()
+	cache := &clientCache{
+		opt:           &opt,
+		channelPrefix: fmt.Sprintf("__keyspace@%d__:", c.opt.DB),
+		commands:      make(map[string]struct{}, len(opt.Commands)),
+		lru:           list.New(),
+		entries:       make(map[string]*list.Element),
+		keys:          make(map[string]map[string]struct{}),
+		inflight:      make(map[string]int),
+		dirty:         make(map[string]struct{}),
+		pubsub:        c.Subscribe(),
+		subscribed:    make(map[string]struct{}),
+	}
+	for _, name := range opt.Commands {
+		cache.commands[internal.ToLower(name)] = struct{}{}
+	}
+	cache.cmdsInfoCache = newCmdsInfoCache(func() (map[string]*CommandInfo, error) {
+		cmd := NewCommandsInfoCmd("command")
+		_ = c.baseClient.Process(cmd)
+		return cmd.Result()
+	})
+	go cache.listen()
+	return cache
+}
+
+func (c *clientCache) process(ctx context.Context, cmd Cmder, fn func(Cmder) error) error {
+	if _, ok := c.commands[cmd.Name()]; !ok {
+		err := fn(cmd)
+		c.invalidateCmd(cmd)
+		return err
+	}
+	if isCacheBypassed(ctx) {
+		return fn(cmd)
+	}
+
+	key := cmd.stringArg(1)
+	id := coalesceKey(fmt.Sprintf("%T", cmd), cmd)
+	if c.get(id, cmd) {
+		atomic.AddUint64(&c.hits, 1)
+		return cmd.Err()
+	}
+	atomic.AddUint64(&c.misses, 1)
+
+	// The key is watched before it is read, so a change made after the
+	// read can not be missed.
+	c.mu.Lock()
+	c.inflight[key]++
+	c.mu.Unlock()
+	watchErr := c.watch(key)
+
+	err := fn(cmd)
+
+	c.mu.Lock()
+	if _, dirty := c.dirty[key]; !dirty && watchErr == nil && (err == nil || err == Nil) {
+		c.add(id, key, cmd)
+	}
+	c.inflight[key]--
+	if c.inflight[key] == 0 {
+		delete(c.inflight, key)
+		delete(c.dirty, key)
+	}
+	c.mu.Unlock()
+	if watchErr != nil {
+		go c.unwatch(key)
+	}
+	return err
+}
+
+func (c *clientCache) get(id string, cmd Cmder) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[id]
+	if !ok {
+		return false
+	}
+	entry := el.Value.(*cacheEntry)
+	if time.Now().After(entry.expiresAt) {
+		c.remove(el)
+		go c.unwatch(entry.key)
+		return false
+	}
+	if !copyCmdResult(cmd, entry.cmd) {
+		return false
+	}
+	c.lru.MoveToFront(el)
+	return true
+}
+
+// add caches a copy of the reply of cmd. c.mu must be held.
+func (c *clientCache) add(id, key string, cmd Cmder) {
+	clone, ok := cloneCmd(cmd)
+	if !ok || !copyCmdResult(clone, cmd) {
+		return
+	}
+	entry := &cacheEntry{
+		id:        id,
+		key:       key,
+		cmd:       clone,
+		expiresAt: time.Now().Add(c.opt.TTL),
+	}
+	if el, ok := c.entries[id]; ok {
+		el.Value = entry
+		c.lru.MoveToFront(el)
+		return
+	}
+	c.entries[id] = c.lru.PushFront(entry)
+	ids, ok := c.keys[key]
+	if !ok {
+		ids = make(map[string]struct{})
+		c.keys[key] = ids
+	}
+	ids[id] = struct{}{}
+	for c.lru.Len() > c.opt.Size {
+		el := c.lru.Back()
+		c.remove(el)
+		go c.unwatch(el.Value.(*cacheEntry).key)
+	}
+}
+
+// remove drops a cached reply. c.mu must be held.
+func (c *clientCache) remove(el *list.Element) {
+	entry := el.Value.(*cacheEntry)
+	c.lru.Remove(el)
+	delete(c.entries, entry.id)
+	if ids, ok := c.keys[entry.key]; ok {
+		delete(ids, entry.id)
+		if len(ids) == 0 {
+			delete(c.keys, entry.key)
+		}
+	}
+}
+
+// invalidateCmd drops the keys written by cmd.
+func (c *clientCache) invalidateCmd(cmd Cmder) {
+	switch cmd.Name() {
+	case "flushdb", "flushall", "swapdb":
+		c.flush()
+		return
+	}
+	info := c.cmdInfo(cmd.Name())
+	if info == nil {
+		// Without COMMAND the first argument is taken for the key.
+		c.invalidate(cmd.stringArg(1))
+		return
+	}
+	if info.ReadOnly {
+		return
+	}
+	c.invalidate(cmdKeys(cmd, info)...)
+}
+
+func (c *clientCache) cmdInfo(name string) *CommandInfo {
+	cmdsInfo, err := c.cmdsInfoCache.Get()
+	if err != nil {
+		return nil
+	}
+	return cmdsInfo[name]
+}
+
+func (c *clientCache) invalidate(keys ...string) {
+	var dropped []string
+	c.mu.Lock()
+	for _, key := range keys {
+		if c.inflight[key] > 0 {
+			c.dirty[key] = struct{}{}
+		}
+		ids, ok := c.keys[key]
+		if !ok {
+			continue
+		}
+		for id := range ids {
+			if el, ok := c.entries[id]; ok {
+				c.remove(el)
+			}
+		}
+		dropped = append(dropped, key)
+	}
+	c.mu.Unlock()
+	atomic.AddUint64(&c.invalidations, uint64(len(dropped)))
+	for _, key := range dropped {
+		go c.unwatch(key)
+	}
+}
+
+// flush drops every cached reply.
+func (c *clientCache) flush() {
+	c.mu.Lock()
+	keys := make([]string, 0, len(c.keys)+len(c.inflight))
+	for key := range c.keys {
+		keys = append(keys, key)
+	}
+	for key := range c.inflight {
+		keys = append(keys, key)
+	}
+	c.mu.Unlock()
+	c.invalidate(keys...)
+}
+
+func (c *clientCache) watch(key string) error {
+	c.subMu.Lock()
+	defer c.subMu.Unlock()
+	if _, ok := c.subscribed[key]; ok {
+		return nil
+	}
+	if err := c.pubsub.Subscribe(c.channelPrefix + key); err != nil {
+		return err
+	}
+	c.subscribed[key] = struct{}{}
+	return nil
+}
+
+// unwatch unsubscribes from the keyspace channel of key unless key is
+// cached or being read again.
+func (c *clientCache) unwatch(key string) {
+	c.subMu.Lock()
+	defer c.subMu.Unlock()
+	if _, ok := c.subscribed[key]; !ok {
+		return
+	}
+	c.mu.Lock()
+	_, cached := c.keys[key]
+	used := cached || c.inflight[key] > 0
+	c.mu.Unlock()
+	if used {
+		return
+	}
+	delete(c.subscribed, key)
+	_ = c.pubsub.Unsubscribe(c.channelPrefix + key)
+}
+
+func (c *clientCache) listen() {
+	lost := false
+	for {
+		msgi, err := c.pubsub.Receive()
+		if err != nil {
+			if err == pool.ErrClosed {
+				return
+			}
+			if internal.IsNetworkError(err) {
+				// Notifications sent while the connection is down are lost.
+				lost = true
+				c.flush()
+				time.Sleep(time.Second)
+			}
+			continue
+		}
+		if lost {
+			// Changes made before the channels were resubscribed.
+			lost = false
+			c.flush()
+		}
+		if msg, ok := msgi.(*Message); ok && strings.HasPrefix(msg.Channel, c.channelPrefix) {
+			c.invalidate(msg.Channel[len(c.channelPrefix):])
+		}
+	}
+}
+
+func (c *clientCache) Stats() *CacheStats {
+	return &CacheStats{
+		Hits:          atomic.LoadUint64(&c.hits),
+		Misses:        atomic.LoadUint64(&c.misses),
+		Invalidations: atomic.LoadUint64(&c.invalidations),
+	}
+}
+
+func (c *clientCache) Close() error {
+	return c.pubsub.Close()
+}
+
+// cmdKeys returns the keys of cmd described by info.
+func cmdKeys(cmd Cmder, info *CommandInfo) []string {
+	first := cmdFirstKeyPos(cmd, info)
+	if first == 0 {
+		return nil
+	}
+	args := cmd.Args()
+	last, step := first, 1
+	if info != nil {
+		last, step = int(info.LastKeyPos), int(info.StepCount)
+		if last < 0 {
+			last += len(args)
+		}
+		if last < first {
+			last = first
+		}
+		if step <= 0 {
+			step = 1
+		}
+	}
+	var keys []string
+	for i := first; i <= last && i < len(args); i += step {
+		keys = append(keys, cmd.stringArg(i))
+	}
+	return keys
+}
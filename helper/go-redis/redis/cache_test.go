@@ -0,0 +1,110 @@
+package redis
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newKeyspaceServer returns a fake server keeping string values that
+// notifies SET on the __keyspace@0__ channels.
+func newKeyspaceServer(t *testing.T) *fakeServer {
+	var (
+		mu     sync.Mutex
+		values = make(map[string]string)
+		subs   = make(map[string]int) // channel -> conn
+		srv    *fakeServer
+	)
+	srv = newFakeServer(t, func(conn int, args []string) string {
+		mu.Lock()
+		defer mu.Unlock()
+		switch strings.ToLower(args[0]) {
+		case "command":
+			return respCommandInfo("get readonly 1", "set write 1")
+		case "subscribe":
+			subs[args[1]] = conn
+			return respArray(respBulk("subscribe"), respBulk(args[1]), respInt(1))
+		case "unsubscribe":
+			delete(subs, args[1])
+			return respArray(respBulk("unsubscribe"), respBulk(args[1]), respInt(0))
+		case "get":
+			if v, ok := values[args[1]]; ok {
+				return respBulk(v)
+			}
+			return respNil
+		case "set":
+			values[args[1]] = args[2]
+			channel := "__keyspace@0__:" + args[1]
+			if sub, ok := subs[channel]; ok {
+				srv.Push(sub, respArray(respBulk("message"), respBulk(channel), respBulk("set")))
+			}
+		}
+		return "+OK\r\n"
+	})
+	return srv
+}
+
+func countCmds(srv *fakeServer, name string) int {
+	n := 0
+	for _, cmd := range srv.Cmds() {
+		if cmd.Args[0] == name {
+			n++
+		}
+	}
+	return n
+}
+
+func TestClientCacheInvalidatedByOtherClient(t *testing.T) {
+	srv := newKeyspaceServer(t)
+	cached := NewClient(&Options{Addr: srv.Addr, Cache: &CacheOptions{}})
+	defer cached.Close()
+	other := NewClient(&Options{Addr: srv.Addr})
+	defer other.Close()
+
+	other.Set("key", "v1", 0)
+	for i := 0; i < 3; i++ {
+		if v := cached.Get("key").Val(); v != "v1" {
+			t.Fatalf("Get = %q", v)
+		}
+	}
+	if n := countCmds(srv, "get"); n != 1 {
+		t.Fatalf("GETs sent = %d, want the repeats served from the cache", n)
+	}
+
+	other.Set("key", "v2", 0)
+	start := time.Now()
+	for cached.Get("key").Val() != "v2" {
+		if time.Since(start) > time.Second {
+			t.Fatal("cached value not invalidated by the SET of another client")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if stats := cached.CacheStats(); stats.Hits < 2 || stats.Misses < 2 || stats.Invalidations != 1 {
+		t.Fatalf("stats = %+v", stats)
+	}
+}
+
+func TestClientCacheOwnWrite(t *testing.T) {
+	srv := newKeyspaceServer(t)
+	client := NewClient(&Options{Addr: srv.Addr, Cache: &CacheOptions{}})
+	defer client.Close()
+
+	if err := client.Get("key").Err(); err != Nil {
+		t.Fatalf("Get of a missing key err = %v", err)
+	}
+	if err := client.Get("key").Err(); err != Nil {
+		t.Fatalf("cached Get of a missing key err = %v", err)
+	}
+	client.Set("key", "v1", 0)
+	// no wait: the own write drops the key before Set returns
+	if v := client.Get("key").Val(); v != "v1" {
+		t.Fatalf("Get after Set = %q", v)
+	}
+	bypass := client.WithContext(WithoutCache(context.Background()))
+	bypass.Get("key")
+	if n := countCmds(srv, "get"); n != 3 {
+		t.Fatalf("GETs sent = %d, want 3", n)
+	}
+}
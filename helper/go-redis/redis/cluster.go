@@ -43,7 +43,8 @@ type ClusterOptions struct {
 
 	// Following options are copied from Options struct.
 
-	OnConnect func(*Conn) error
+	OnConnect        func(*Conn) error
+	OnConnectContext func(ctx context.Context, cn *Conn, addr string) error
 
 	MaxRetries      int
 	MinRetryBackoff time.Duration
@@ -60,6 +61,8 @@ type ClusterOptions struct {
 	IdleTimeout        time.Duration
 	IdleCheckFrequency time.Duration
 
+	CommandStats bool
+
 	TLSConfig *tls.Config
 }
 
@@ -105,7 +108,8 @@ func (opt *ClusterOptions) clientOptions() *Options {
 	const disableIdleCheck = -1
 
 	return &Options{
-		OnConnect: opt.OnConnect,
+		OnConnect:        opt.OnConnect,
+		OnConnectContext: opt.OnConnectContext,
 
 		MaxRetries:      opt.MaxRetries,
 		MinRetryBackoff: opt.MinRetryBackoff,
@@ -123,6 +127,8 @@ func (opt *ClusterOptions) clientOptions() *Options {
 
 		IdleCheckFrequency: disableIdleCheck,
 
+		CommandStats: opt.CommandStats,
+
 		TLSConfig: opt.TLSConfig,
 	}
 }
@@ -730,11 +736,23 @@ func (c *ClusterClient) cmdInfo(name string) *CommandInfo {
 
 	info := cmdsInfo[name]
 	if info == nil {
-		internal.Logf("info for cmd=%s not found", name)
+		internal.Limitf("info for cmd=%s not found", name)
 	}
 	return info
 }
 
+// HashSlot returns the cluster slot of key, taking its hash tag into account,
+// e.g. to batch keys by slot.
+func HashSlot(key string) int {
+	return hashtag.Slot(key)
+}
+
+// SameSlot reports whether keys hash to the same cluster slot, so a
+// multi-key command on them does not fail with CROSSSLOT.
+func SameSlot(keys ...string) bool {
+	return hashtag.SameSlot(keys...)
+}
+
 func cmdSlot(cmd Cmder, pos int) int {
 	if pos == 0 {
 		return hashtag.RandomSlot()
@@ -748,6 +766,16 @@ func (c *ClusterClient) cmdSlot(cmd Cmder) int {
 	return cmdSlot(cmd, cmdFirstKeyPos(cmd, cmdInfo))
 }
 
+// checkCmdSlot fails multi-key commands with keys in several slots before
+// the server answers CROSSSLOT, naming the first key out of place.
+func (c *ClusterClient) checkCmdSlot(cmd Cmder) error {
+	keys := cmdKeys(cmd, c.cmdInfo(cmd.Name()))
+	if i := hashtag.CrossSlotIndex(keys...); i >= 0 {
+		return fmt.Errorf("redis: %s keys must be in the same slot, %q is not in the slot of %q", cmd.Name(), keys[i], keys[0])
+	}
+	return nil
+}
+
 func (c *ClusterClient) cmdSlotAndNode(cmd Cmder) (int, *clusterNode, error) {
 	state, err := c.state.Get()
 	if err != nil {
@@ -794,13 +822,10 @@ func (c *ClusterClient) Watch(fn func(*Tx) error, keys ...string) error {
 		return fmt.Errorf("redis: Watch requires at least one key")
 	}
 
-	slot := hashtag.Slot(keys[0])
-	for _, key := range keys[1:] {
-		if hashtag.Slot(key) != slot {
-			err := fmt.Errorf("redis: Watch requires all keys to be in the same slot")
-			return err
-		}
+	if i := hashtag.CrossSlotIndex(keys...); i >= 0 {
+		return fmt.Errorf("redis: Watch requires all keys to be in the same slot, %q is not in the slot of %q", keys[i], keys[0])
 	}
+	slot := hashtag.Slot(keys[0])
 
 	node, err := c.slotMasterNode(slot)
 	if err != nil {
@@ -873,6 +898,10 @@ func (c *ClusterClient) defaultProcess(cmd Cmder) error {
 
 		if node == nil {
 			var err error
+			if err = c.checkCmdSlot(cmd); err != nil {
+				cmd.setErr(err)
+				break
+			}
 			_, node, err = c.cmdSlotAndNode(cmd)
 			if err != nil {
 				cmd.setErr(err)
@@ -1069,6 +1098,8 @@ func (c *ClusterClient) PoolStats() *PoolStats {
 		acc.Hits += s.Hits
 		acc.Misses += s.Misses
 		acc.Timeouts += s.Timeouts
+		acc.WaitCount += s.WaitCount
+		acc.WaitDuration += s.WaitDuration
 
 		acc.TotalConns += s.TotalConns
 		acc.FreeConns += s.FreeConns
@@ -1080,6 +1111,8 @@ func (c *ClusterClient) PoolStats() *PoolStats {
 		acc.Hits += s.Hits
 		acc.Misses += s.Misses
 		acc.Timeouts += s.Timeouts
+		acc.WaitCount += s.WaitCount
+		acc.WaitDuration += s.WaitDuration
 
 		acc.TotalConns += s.TotalConns
 		acc.FreeConns += s.FreeConns
@@ -1172,7 +1205,7 @@ func (c *ClusterClient) defaultProcessPipeline(cmds []Cmder) error {
 		failedCmds := make(map[*clusterNode][]Cmder)
 
 		for node, cmds := range cmdsMap {
-			cn, _, err := node.Client.getConn()
+			cn, _, err := node.Client.getConn(context.Background())
 			if err != nil {
 				if err == pool.ErrClosed {
 					c.remapCmds(cmds, failedCmds)
@@ -1336,7 +1369,7 @@ func (c *ClusterClient) defaultProcessTxPipeline(cmds []Cmder) error {
 			failedCmds := make(map[*clusterNode][]Cmder)
 
 			for node, cmds := range cmdsMap {
-				cn, _, err := node.Client.getConn()
+				cn, _, err := node.Client.getConn(context.Background())
 				if err != nil {
 					if err == pool.ErrClosed {
 						c.remapCmds(cmds, failedCmds)
@@ -0,0 +1,163 @@
+package redis
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"EasyDarwin/helper/go-redis/redis/internal"
+	"EasyDarwin/helper/go-redis/redis/internal/singleflight"
+)
+
+// DefaultCoalescedCommands are the read-only commands coalesced by a Ring
+// with EnableCommandCoalescing when RingOptions.CoalescedCommands is empty.
+var DefaultCoalescedCommands = []string{
+	"get", "mget", "exists", "strlen", "getrange", "getbit", "bitcount",
+	"hget", "hmget", "hgetall", "hexists", "hlen", "hkeys", "hvals",
+	"llen", "lrange", "lindex",
+	"scard", "smembers", "sismember",
+	"zcard", "zscore", "zrank", "zrange", "zrangebyscore",
+	"ttl", "pttl", "type",
+}
+
+// SingleflightPipeline deduplicates identical read-only commands that are
+// in flight at the same time. The first command with a given
+// <shard>:<name>:<args> key is sent, the others wait for it and get a copy
+// of its result.
+type SingleflightPipeline struct {
+	group    singleflight.Group
+	commands map[string]struct{}
+}
+
+func NewSingleflightPipeline(commands []string) *SingleflightPipeline {
+	if len(commands) == 0 {
+		commands = DefaultCoalescedCommands
+	}
+	p := &SingleflightPipeline{
+		commands: make(map[string]struct{}, len(commands)),
+	}
+	for _, name := range commands {
+		p.commands[internal.ToLower(name)] = struct{}{}
+	}
+	return p
+}
+
+// Process runs fn for cmd, or waits for an identical command already sent
+// to the same shard and copies its result into cmd.
+func (p *SingleflightPipeline) Process(shard string, cmd Cmder, fn func(Cmder) error) error {
+	if _, ok := p.commands[cmd.Name()]; !ok {
+		return fn(cmd)
+	}
+
+	leader := false
+	v, _ := p.group.Do(coalesceKey(shard, cmd), func() (interface{}, error) {
+		leader = true
+		_ = fn(cmd)
+		return cmd, nil
+	})
+	if leader {
+		return cmd.Err()
+	}
+
+	if !copyCmdResult(cmd, v.(Cmder)) {
+		// Same command sent through a different Cmder type, e.g. Do.
+		return fn(cmd)
+	}
+	return cmd.Err()
+}
+
+func coalesceKey(shard string, cmd Cmder) string {
+	var b strings.Builder
+	b.WriteString(shard)
+	for _, arg := range cmd.Args() {
+		b.WriteByte(':')
+		b.WriteString(strconv.Quote(fmt.Sprint(arg)))
+	}
+	return b.String()
+}
+
+// copyCmdResult copies the reply of src into dst. Slices and maps are copied,
+// so callers can not see each other's modifications.
+func copyCmdResult(dst, src Cmder) bool {
+	switch dst := dst.(type) {
+	case *Cmd:
+		src, ok := src.(*Cmd)
+		if !ok {
+			return false
+		}
+		dst.val, dst.err = src.val, src.err
+	case *StatusCmd:
+		src, ok := src.(*StatusCmd)
+		if !ok {
+			return false
+		}
+		dst.val, dst.err = src.val, src.err
+	case *StringCmd:
+		src, ok := src.(*StringCmd)
+		if !ok {
+			return false
+		}
+		dst.val, dst.err = append([]byte(nil), src.val...), src.err
+	case *IntCmd:
+		src, ok := src.(*IntCmd)
+		if !ok {
+			return false
+		}
+		dst.val, dst.err = src.val, src.err
+	case *BoolCmd:
+		src, ok := src.(*BoolCmd)
+		if !ok {
+			return false
+		}
+		dst.val, dst.err = src.val, src.err
+	case *FloatCmd:
+		src, ok := src.(*FloatCmd)
+		if !ok {
+			return false
+		}
+		dst.val, dst.err = src.val, src.err
+	case *DurationCmd:
+		src, ok := src.(*DurationCmd)
+		if !ok {
+			return false
+		}
+		dst.val, dst.err = src.val, src.err
+	case *SliceCmd:
+		src, ok := src.(*SliceCmd)
+		if !ok {
+			return false
+		}
+		dst.val, dst.err = append([]interface{}(nil), src.val...), src.err
+	case *StringSliceCmd:
+		src, ok := src.(*StringSliceCmd)
+		if !ok {
+			return false
+		}
+		dst.val, dst.err = append([]string(nil), src.val...), src.err
+	case *BoolSliceCmd:
+		src, ok := src.(*BoolSliceCmd)
+		if !ok {
+			return false
+		}
+		dst.val, dst.err = append([]bool(nil), src.val...), src.err
+	case *ZSliceCmd:
+		src, ok := src.(*ZSliceCmd)
+		if !ok {
+			return false
+		}
+		dst.val, dst.err = append([]Z(nil), src.val...), src.err
+	case *StringStringMapCmd:
+		src, ok := src.(*StringStringMapCmd)
+		if !ok {
+			return false
+		}
+		m := make(map[string]string, len(src.val))
+		for k, v := range src.val {
+			m[k] = v
+		}
+		dst.val, dst.err = m, src.err
+	default:
+		return false
+	}
+	return true
+}
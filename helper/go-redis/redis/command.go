@@ -2,10 +2,15 @@ package redis
 
 import (
 	"bytes"
+	"context"
 	"fmt"
+	"io"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"EasyDarwin/helper/go-redis/redis/internal"
 	"EasyDarwin/helper/go-redis/redis/internal/pool"
@@ -23,6 +28,9 @@ type Cmder interface {
 
 	readTimeout() *time.Duration
 
+	context() context.Context
+	setContext(context.Context)
+
 	Err() error
 	fmt.Stringer
 }
@@ -56,25 +64,101 @@ func writeCmd(cn *pool.Conn, cmds ...Cmder) error {
 	return err
 }
 
+// CmdStringMaxLen limits the length of every argument and of the reply
+// rendered by Cmder.String. Longer values are truncated. Zero disables truncation.
+var CmdStringMaxLen = 64
+
+// cmdRedactedArgs maps a command name to the argument positions that
+// are replaced with "?" by Cmder.String.
+var (
+	cmdRedactedArgs = map[string][]int{
+		"auth": {1, 2},
+	}
+	cmdRedactedArgsMu sync.RWMutex
+)
+
+// RedactCmdArgs hides the arguments at the given positions of the named
+// command when it is rendered by Cmder.String. Position 0 is the command name.
+// It is safe to call while commands are rendered.
+func RedactCmdArgs(name string, pos ...int) {
+	pos = append([]int(nil), pos...)
+	cmdRedactedArgsMu.Lock()
+	cmdRedactedArgs[internal.ToLower(name)] = pos
+	cmdRedactedArgsMu.Unlock()
+}
+
 func cmdString(cmd Cmder, val interface{}) string {
+	cmdRedactedArgsMu.RLock()
+	redacted := cmdRedactedArgs[cmd.Name()]
+	cmdRedactedArgsMu.RUnlock()
 	var ss []string
-	for _, arg := range cmd.Args() {
-		ss = append(ss, fmt.Sprint(arg))
+	for i, arg := range cmd.Args() {
+		if isRedactedArg(redacted, i) {
+			ss = append(ss, "?")
+			continue
+		}
+		ss = append(ss, formatCmdValue(arg))
 	}
 	s := strings.Join(ss, " ")
 	if err := cmd.Err(); err != nil {
 		return s + ": " + err.Error()
 	}
 	if val != nil {
-		switch vv := val.(type) {
-		case []byte:
-			return s + ": " + string(vv)
-		default:
-			return s + ": " + fmt.Sprint(val)
+		return s + ": " + formatCmdValue(val)
+	}
+	return s
+
+}
+
+func isRedactedArg(redacted []int, pos int) bool {
+	for _, p := range redacted {
+		if p == pos {
+			return true
 		}
 	}
+	return false
+}
+
+// formatCmdValue renders v truncated to CmdStringMaxLen bytes. Binary or
+// non-printable values are quoted so they are safe to write to a log.
+func formatCmdValue(v interface{}) string {
+	var s string
+	switch vv := v.(type) {
+	case string:
+		s = vv
+	case []byte:
+		s = string(vv)
+	default:
+		s = fmt.Sprint(v)
+	}
+	truncated := false
+	if CmdStringMaxLen > 0 && len(s) > CmdStringMaxLen {
+		n := CmdStringMaxLen
+		for i := 0; i < utf8.UTFMax-1 && n > 0 && !utf8.RuneStart(s[n]); i++ {
+			n--
+		}
+		s = s[:n]
+		truncated = true
+	}
+	if !isPrintable(s) {
+		s = strconv.Quote(s)
+	}
+	if truncated {
+		s += "..."
+	}
 	return s
+}
 
+func isPrintable(s string) bool {
+	if !utf8.ValidString(s) {
+		return false
+	}
+	for _, r := range s {
+		if !unicode.IsPrint(r) {
+			return false
+		}
+	}
+	return true
 }
 
 func cmdFirstKeyPos(cmd Cmder, info *CommandInfo) int {
@@ -87,6 +171,12 @@ func cmdFirstKeyPos(cmd Cmder, info *CommandInfo) int {
 		return 0
 	case "publish":
 		return 1
+	// Servers older than 6.2 do not know these.
+	case "getdel", "getex", "copy":
+		return 1
+	case "object":
+		// Redis 7 reports no keys for container commands.
+		return 2
 	}
 	if info == nil {
 		return 0
@@ -101,6 +191,8 @@ type baseCmd struct {
 	err   error
 
 	_readTimeout *time.Duration
+
+	ctx context.Context
 }
 
 var _ Cmder = (*Cmd)(nil)
@@ -139,6 +231,17 @@ func (cmd *baseCmd) setReadTimeout(d time.Duration) {
 	cmd._readTimeout = &d
 }
 
+func (cmd *baseCmd) context() context.Context {
+	if cmd.ctx != nil {
+		return cmd.ctx
+	}
+	return context.Background()
+}
+
+func (cmd *baseCmd) setContext(ctx context.Context) {
+	cmd.ctx = ctx
+}
+
 func (cmd *baseCmd) setErr(e error) {
 	cmd.err = e
 }
@@ -487,6 +590,43 @@ func (cmd *StringCmd) readReply(cn *pool.Conn) error {
 
 //------------------------------------------------------------------------------
 
+// StreamCmd copies a bulk string reply to a writer as it is read, so large
+// values are not held in memory. Val is the number of bytes written.
+type StreamCmd struct {
+	baseCmd
+
+	w   io.Writer
+	val int64
+}
+
+var _ Cmder = (*StreamCmd)(nil)
+
+func NewStreamCmd(w io.Writer, args ...interface{}) *StreamCmd {
+	return &StreamCmd{
+		baseCmd: baseCmd{_args: args},
+		w:       w,
+	}
+}
+
+func (cmd *StreamCmd) Val() int64 {
+	return cmd.val
+}
+
+func (cmd *StreamCmd) Result() (int64, error) {
+	return cmd.val, cmd.err
+}
+
+func (cmd *StreamCmd) String() string {
+	return cmdString(cmd, cmd.val)
+}
+
+func (cmd *StreamCmd) readReply(cn *pool.Conn) error {
+	cmd.val, cmd.err = cn.Rd.ReadBulkInto(cmd.w)
+	return cmd.err
+}
+
+//------------------------------------------------------------------------------
+
 type FloatCmd struct {
 	baseCmd
 
@@ -600,6 +740,52 @@ func (cmd *BoolSliceCmd) readReply(cn *pool.Conn) error {
 
 //------------------------------------------------------------------------------
 
+// IntSliceCmd holds an array of integer replies in which a nil reply
+// is kept as a nil element, e.g. a BITFIELD operation that hit OVERFLOW FAIL.
+type IntSliceCmd struct {
+	baseCmd
+
+	val []*int64
+}
+
+var _ Cmder = (*IntSliceCmd)(nil)
+
+func NewIntSliceCmd(args ...interface{}) *IntSliceCmd {
+	return &IntSliceCmd{
+		baseCmd: baseCmd{_args: args},
+	}
+}
+
+func (cmd *IntSliceCmd) Val() []*int64 {
+	return cmd.val
+}
+
+func (cmd *IntSliceCmd) Result() ([]*int64, error) {
+	return cmd.val, cmd.err
+}
+
+func (cmd *IntSliceCmd) String() string {
+	vals := make([]interface{}, len(cmd.val))
+	for i, v := range cmd.val {
+		if v != nil {
+			vals[i] = *v
+		}
+	}
+	return cmdString(cmd, vals)
+}
+
+func (cmd *IntSliceCmd) readReply(cn *pool.Conn) error {
+	var v interface{}
+	v, cmd.err = cn.Rd.ReadArrayReply(intSliceParser)
+	if cmd.err != nil {
+		return cmd.err
+	}
+	cmd.val = v.([]*int64)
+	return nil
+}
+
+//------------------------------------------------------------------------------
+
 type StringStringMapCmd struct {
 	baseCmd
 
@@ -0,0 +1,90 @@
+package redis
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestCmdString(t *testing.T) {
+	cmd := NewStatusCmd("set", "key", "value")
+	cmd.val = "OK"
+	if s := cmd.String(); s != "set key value: OK" {
+		t.Fatalf("String = %q", s)
+	}
+	get := NewStringCmd("get", "key")
+	get.setErr(errors.New("redis: nil"))
+	if s := get.String(); s != "get key: redis: nil" {
+		t.Fatalf("String = %q", s)
+	}
+}
+
+func TestCmdStringTruncation(t *testing.T) {
+	defer func(n int) { CmdStringMaxLen = n }(CmdStringMaxLen)
+	CmdStringMaxLen = 8
+
+	cmd := NewStatusCmd("set", "key", strings.Repeat("v", 20))
+	cmd.val = "OK"
+	if s := cmd.String(); s != "set key vvvvvvvv...: OK" {
+		t.Fatalf("String = %q", s)
+	}
+
+	// a multibyte rune is not cut in half: byte 7 is inside "本"
+	CmdStringMaxLen = 7
+	cmd = NewStatusCmd("set", "key", "ab日本語")
+	cmd.val = "OK"
+	if s := cmd.String(); s != "set key ab日...: OK" {
+		t.Fatalf("String = %q", s)
+	}
+
+	CmdStringMaxLen = 0
+	cmd = NewStatusCmd("set", "key", strings.Repeat("v", 100))
+	if s := cmd.String(); !strings.Contains(s, strings.Repeat("v", 100)) {
+		t.Fatalf("String = %q, want the value untruncated", s)
+	}
+}
+
+func TestCmdStringRedactsAuth(t *testing.T) {
+	cmd := NewStatusCmd("auth", "secret")
+	cmd.val = "OK"
+	if s := cmd.String(); s != "auth ?: OK" {
+		t.Fatalf("String = %q", s)
+	}
+	// AUTH username password
+	cmd = NewStatusCmd("AUTH", "user", "secret")
+	if s := cmd.String(); strings.Contains(s, "user") || strings.Contains(s, "secret") {
+		t.Fatalf("String = %q, want the credentials redacted", s)
+	}
+}
+
+func TestCmdStringNonUTF8(t *testing.T) {
+	cmd := NewStatusCmd("set", "key", []byte{0xff, 0xfe, 'a', '\n'})
+	cmd.val = "OK"
+	if s := cmd.String(); s != `set key "\xff\xfea\n": OK` {
+		t.Fatalf("String = %q", s)
+	}
+}
+
+// Run with -race: redactions may be added while commands are logged.
+func TestRedactCmdArgsConcurrent(t *testing.T) {
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			RedactCmdArgs("x-secret-set", 2)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			_ = NewStatusCmd("x-secret-set", "key", "secret").String()
+		}
+	}()
+	wg.Wait()
+
+	if s := NewStatusCmd("x-secret-set", "key", "secret").String(); s != "x-secret-set key ?: " {
+		t.Fatalf("String = %q", s)
+	}
+}
@@ -21,7 +21,7 @@ func usePrecise(dur time.Duration) bool {
 
 func formatMs(dur time.Duration) int64 {
 	if dur > 0 && dur < time.Millisecond {
-		internal.Logf(
+		internal.Limitf(
 			"specified duration is %s, but minimal supported value is %s",
 			dur, time.Millisecond,
 		)
@@ -31,7 +31,7 @@ func formatMs(dur time.Duration) int64 {
 
 func formatSec(dur time.Duration) int64 {
 	if dur > 0 && dur < time.Second {
-		internal.Logf(
+		internal.Limitf(
 			"specified duration is %s, but minimal supported value is %s",
 			dur, time.Second,
 		)
@@ -65,6 +65,7 @@ type Cmdable interface {
 	ClientGetName() *StringCmd
 	Echo(message interface{}) *StringCmd
 	Ping() *StatusCmd
+	Wait(numSlaves int, timeout time.Duration) *IntCmd
 	Quit() *StatusCmd
 	Del(keys ...string) *IntCmd
 	Unlink(keys ...string) *IntCmd
@@ -78,6 +79,8 @@ type Cmdable interface {
 	ObjectRefCount(key string) *IntCmd
 	ObjectEncoding(key string) *StringCmd
 	ObjectIdleTime(key string) *DurationCmd
+	ObjectFreq(key string) *IntCmd
+	Copy(src, dst string, destDB int, replace bool) *IntCmd
 	Persist(key string) *BoolCmd
 	PExpire(key string, expiration time.Duration) *BoolCmd
 	PExpireAt(key string, tm time.Time) *BoolCmd
@@ -104,12 +107,16 @@ type Cmdable interface {
 	BitOpXor(destKey string, keys ...string) *IntCmd
 	BitOpNot(destKey string, key string) *IntCmd
 	BitPos(key string, bit int64, pos ...int64) *IntCmd
+	BitField(key string) *BitField
 	Decr(key string) *IntCmd
 	DecrBy(key string, decrement int64) *IntCmd
 	Get(key string) *StringCmd
+	GetInto(key string, w io.Writer) *StreamCmd
 	GetBit(key string, offset int64) *IntCmd
 	GetRange(key string, start, end int64) *StringCmd
 	GetSet(key string, value interface{}) *StringCmd
+	GetDel(key string) *StringCmd
+	GetEx(key string, opts GetExOptions) *StringCmd
 	Incr(key string) *IntCmd
 	IncrBy(key string, value int64) *IntCmd
 	IncrByFloat(key string, value float64) *FloatCmd
@@ -221,6 +228,7 @@ type Cmdable interface {
 	FlushDB() *StatusCmd
 	FlushDBAsync() *StatusCmd
 	Info(section ...string) *StringCmd
+	InfoMap(section ...string) *InfoMapCmd
 	LastSave() *IntCmd
 	Save() *StatusCmd
 	Shutdown() *StatusCmd
@@ -322,7 +330,7 @@ func (c *cmdable) Ping() *StatusCmd {
 }
 
 func (c *cmdable) Wait(numSlaves int, timeout time.Duration) *IntCmd {
-	cmd := NewIntCmd("wait", numSlaves, int(timeout/time.Millisecond))
+	cmd := newWaitCmd(numSlaves, timeout)
 	c.process(cmd)
 	return cmd
 }
@@ -440,6 +448,30 @@ func (c *cmdable) ObjectIdleTime(key string) *DurationCmd {
 	return cmd
 }
 
+// ObjectFreq returns the access frequency counter of key. It needs an
+// LFU maxmemory-policy on the server.
+func (c *cmdable) ObjectFreq(key string) *IntCmd {
+	cmd := NewIntCmd("object", "freq", key)
+	c.process(cmd)
+	return cmd
+}
+
+// Redis `COPY src dst [DB destDB] [REPLACE]` command. It returns 1 if src
+// was copied and 0 otherwise. A destDB of 0 or less copies into the
+// current database. Ring requires src and dst to be on the same shard.
+func (c *cmdable) Copy(src, dst string, destDB int, replace bool) *IntCmd {
+	args := []interface{}{"copy", src, dst}
+	if destDB > 0 {
+		args = append(args, "db", destDB)
+	}
+	if replace {
+		args = append(args, "replace")
+	}
+	cmd := NewIntCmd(args...)
+	c.process(cmd)
+	return cmd
+}
+
 func (c *cmdable) Persist(key string) *BoolCmd {
 	cmd := NewBoolCmd("persist", key)
 	c.process(cmd)
@@ -728,6 +760,15 @@ func (c *cmdable) Get(key string) *StringCmd {
 	return cmd
 }
 
+// GetInto writes the value of key to w as it is read from the connection,
+// e.g. for multi-megabyte values. It returns Nil if key does not exist.
+// A reply that failed after part of it was written is not retried.
+func (c *cmdable) GetInto(key string, w io.Writer) *StreamCmd {
+	cmd := NewStreamCmd(w, "get", key)
+	c.process(cmd)
+	return cmd
+}
+
 func (c *cmdable) GetBit(key string, offset int64) *IntCmd {
 	cmd := NewIntCmd("getbit", key, offset)
 	c.process(cmd)
@@ -746,6 +787,47 @@ func (c *cmdable) GetSet(key string, value interface{}) *StringCmd {
 	return cmd
 }
 
+// Redis `GETDEL key` command. It returns redis.Nil error when key does not exist.
+func (c *cmdable) GetDel(key string) *StringCmd {
+	cmd := NewStringCmd("getdel", key)
+	c.process(cmd)
+	return cmd
+}
+
+// GetExOptions are the expiration options of GetEx. Persist takes
+// precedence over Expiration, which takes precedence over ExpireAt.
+// With none of them set GetEx behaves like Get.
+type GetExOptions struct {
+	Expiration time.Duration
+	ExpireAt   time.Time
+	Persist    bool
+}
+
+func (opts *GetExOptions) args(key string) []interface{} {
+	args := []interface{}{"getex", key}
+	switch {
+	case opts.Persist:
+		args = append(args, "persist")
+	case opts.Expiration > 0:
+		if usePrecise(opts.Expiration) {
+			args = append(args, "px", formatMs(opts.Expiration))
+		} else {
+			args = append(args, "ex", formatSec(opts.Expiration))
+		}
+	case !opts.ExpireAt.IsZero():
+		args = append(args, "pxat", opts.ExpireAt.UnixNano()/int64(time.Millisecond))
+	}
+	return args
+}
+
+// Redis `GETEX key [EX seconds|PX milliseconds|PXAT timestamp|PERSIST]` command.
+// It returns redis.Nil error when key does not exist.
+func (c *cmdable) GetEx(key string, opts GetExOptions) *StringCmd {
+	cmd := NewStringCmd(opts.args(key)...)
+	c.process(cmd)
+	return cmd
+}
+
 func (c *cmdable) Incr(key string) *IntCmd {
 	cmd := NewIntCmd("incr", key)
 	c.process(cmd)
@@ -0,0 +1,154 @@
+package redis
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"EasyDarwin/helper/go-redis/redis/internal/proto"
+)
+
+// newKeyServer returns a fake server that knows the string "value" of key
+// and nothing else.
+func newKeyServer(t *testing.T) *fakeServer {
+	return newFakeServer(t, func(conn int, args []string) string {
+		switch strings.ToLower(args[0]) {
+		case "getdel", "getex":
+			if args[1] == "key" {
+				return respBulk("value")
+			}
+			return respNil
+		case "copy":
+			if args[1] == "key" {
+				return respInt(1)
+			}
+			return respInt(0)
+		case "object":
+			return respInt(7)
+		}
+		return "+OK\r\n"
+	})
+}
+
+func TestGetDelGetEx(t *testing.T) {
+	srv := newKeyServer(t)
+	client := NewClient(&Options{Addr: srv.Addr})
+	defer client.Close()
+
+	if v, err := client.GetDel("key").Result(); v != "value" || err != nil {
+		t.Fatalf("GetDel = %q, %v", v, err)
+	}
+	if _, err := client.GetDel("missing").Result(); err != Nil {
+		t.Fatalf("GetDel of a missing key err = %v, want redis.Nil", err)
+	}
+	if _, err := client.GetEx("missing", GetExOptions{Persist: true}).Result(); err != Nil {
+		t.Fatalf("GetEx of a missing key err = %v, want redis.Nil", err)
+	}
+
+	at := time.Unix(1600000000, 123e6)
+	for _, test := range []struct {
+		opts GetExOptions
+		args string
+	}{
+		{GetExOptions{}, "getex key"},
+		{GetExOptions{Expiration: 10 * time.Second}, "getex key ex 10"},
+		{GetExOptions{Expiration: 1500 * time.Millisecond}, "getex key px 1500"},
+		{GetExOptions{ExpireAt: at}, "getex key pxat 1600000000123"},
+		{GetExOptions{Expiration: time.Second, ExpireAt: at}, "getex key ex 1"},
+		{GetExOptions{Persist: true, Expiration: time.Second}, "getex key persist"},
+	} {
+		if v := client.GetEx("key", test.opts).Val(); v != "value" {
+			t.Fatalf("GetEx(%+v) = %q", test.opts, v)
+		}
+		cmds := srv.Cmds()
+		if args := strings.Join(cmds[len(cmds)-1].Args, " "); args != test.args {
+			t.Fatalf("GetEx(%+v) sent %q, want %q", test.opts, args, test.args)
+		}
+	}
+}
+
+func TestCopyObjectFreq(t *testing.T) {
+	srv := newKeyServer(t)
+	client := NewClient(&Options{Addr: srv.Addr})
+	defer client.Close()
+
+	if n := client.Copy("key", "dst", 0, false).Val(); n != 1 {
+		t.Fatalf("Copy = %d", n)
+	}
+	if n, err := client.Copy("missing", "dst", 2, true).Result(); n != 0 || err != nil {
+		t.Fatalf("Copy of a missing key = %d, %v", n, err)
+	}
+	if n := client.ObjectFreq("key").Val(); n != 7 {
+		t.Fatalf("ObjectFreq = %d", n)
+	}
+	var got []string
+	for _, cmd := range srv.Cmds() {
+		got = append(got, strings.Join(cmd.Args, " "))
+	}
+	if strings.Join(got, "|") != "copy key dst|copy missing dst db 2 replace|object freq key" {
+		t.Fatalf("commands = %q", got)
+	}
+}
+
+func TestRingCopyRouting(t *testing.T) {
+	srv1, srv2 := newKeyServer(t), newKeyServer(t)
+	ring := NewRing(&RingOptions{
+		Addrs: map[string]string{"shard1": srv1.Addr, "shard2": srv2.Addr},
+	})
+	defer ring.Close()
+
+	if n, err := ring.Copy("{key}", "{key}.copy", 0, false).Result(); n != 0 || err != nil {
+		t.Fatalf("Copy on one shard = %d, %v", n, err)
+	}
+	// find a key on the other shard
+	dst := ""
+	for i := 0; dst == ""; i++ {
+		key := "dst" + strings.Repeat("x", i)
+		if ring.shards.Hash(key) != ring.shards.Hash("key") {
+			dst = key
+		}
+	}
+	if err := ring.Copy("key", dst, 0, false).Err(); err == nil || !strings.Contains(err.Error(), "same ring shard") {
+		t.Fatalf("Copy across shards err = %v", err)
+	}
+}
+
+func TestGetInto(t *testing.T) {
+	value := strings.Repeat("0123456789abcdef", 1<<16) // 1MB
+	srv := newFakeServer(t, func(conn int, args []string) string {
+		switch args[1] {
+		case "big":
+			return respBulk(value)
+		case "broken":
+			// the rest of the value never comes
+			return "$100\r\n0123456789"
+		}
+		return respNil
+	})
+	client := NewClient(&Options{Addr: srv.Addr, MaxRetries: 2, ReadTimeout: 100 * time.Millisecond})
+	defer client.Close()
+
+	var buf bytes.Buffer
+	if n, err := client.GetInto("big", &buf).Result(); n != int64(len(value)) || err != nil || buf.String() != value {
+		t.Fatalf("GetInto = %d, %v", n, err)
+	}
+	if err := client.GetInto("missing", &buf).Err(); err != Nil {
+		t.Fatalf("GetInto of a missing key err = %v, want redis.Nil", err)
+	}
+
+	buf.Reset()
+	err := client.GetInto("broken", &buf).Err()
+	if streamErr, ok := err.(*proto.StreamError); !ok || streamErr.Written != 10 || buf.String() != "0123456789" {
+		t.Fatalf("GetInto of a broken reply err = %v, wrote %q", err, buf.String())
+	}
+	gets := 0
+	for _, cmd := range srv.Cmds() {
+		if cmd.Args[1] == "broken" {
+			gets++
+		}
+	}
+	if gets != 1 {
+		t.Fatalf("broken GET sent %d times, want no retry after a partial write", gets)
+	}
+}
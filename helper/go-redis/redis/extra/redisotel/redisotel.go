@@ -0,0 +1,109 @@
+// Package redisotel traces Ring commands with OpenTelemetry.
+//
+// go.opentelemetry.io is not vendored under helper/, so the hook takes the
+// Tracer interface below, the subset of trace.Tracer it uses. An otel tracer
+// is plugged in with a few lines of adapter in the application:
+//
+//	type otelTracer struct{ trace.Tracer }
+//
+//	func (t otelTracer) Start(ctx context.Context, name string, attrs ...redisotel.Attribute) (context.Context, redisotel.Span) {
+//		kvs := make([]attribute.KeyValue, len(attrs))
+//		for i, a := range attrs {
+//			kvs[i] = attribute.String(a.Key, a.Value)
+//		}
+//		return t.Tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(kvs...))
+//	}
+//
+// trace.Span already has the RecordError and End methods of Span, modulo
+// their variadic options.
+package redisotel
+
+import (
+	"context"
+	"strconv"
+
+	"EasyDarwin/helper/go-redis/redis"
+)
+
+// Attribute is a string attribute of a span.
+type Attribute struct {
+	Key   string
+	Value string
+}
+
+// Span is the part of an OpenTelemetry trace.Span the hook uses.
+type Span interface {
+	RecordError(err error)
+	End()
+}
+
+// Tracer starts the client span of a command, as a child of the span found in
+// ctx, and returns the context carrying the new span.
+type Tracer interface {
+	Start(ctx context.Context, spanName string, attrs ...Attribute) (context.Context, Span)
+}
+
+type spanKey struct{}
+
+type tracingHook struct {
+	tracer Tracer
+}
+
+var _ redis.Hook = (*tracingHook)(nil)
+
+// NewTracingHook returns a hook that starts a client span for every command,
+// and one for every pipeline or transaction. A pipeline may span several
+// shards, so its span has no net.peer.name.
+// The span is a child of the span found in the context set with Ring.WithContext,
+// so passing the incoming HTTP request context links Redis calls to the request trace.
+//
+//	ring.AddHook(redisotel.NewTracingHook(otelTracer{otel.Tracer("easydarwin")}))
+//	ring.WithContext(c.Request.Context()).Get(key)
+func NewTracingHook(tracer Tracer) redis.Hook {
+	return &tracingHook{tracer: tracer}
+}
+
+func (h *tracingHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	ctx, span := h.tracer.Start(ctx, cmd.Name(),
+		Attribute{Key: "db.system", Value: "redis"},
+		Attribute{Key: "db.operation", Value: cmd.Name()},
+		Attribute{Key: "net.peer.name", Value: redis.ShardAddr(ctx)},
+	)
+	return context.WithValue(ctx, spanKey{}, span), nil
+}
+
+func (h *tracingHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	span, ok := ctx.Value(spanKey{}).(Span)
+	if !ok {
+		return nil
+	}
+	if err := cmd.Err(); err != nil && err != redis.Nil {
+		span.RecordError(err)
+	}
+	span.End()
+	return nil
+}
+
+func (h *tracingHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	ctx, span := h.tracer.Start(ctx, "pipeline",
+		Attribute{Key: "db.system", Value: "redis"},
+		Attribute{Key: "db.operation", Value: "pipeline"},
+		Attribute{Key: "db.redis.num_cmd", Value: strconv.Itoa(len(cmds))},
+	)
+	return context.WithValue(ctx, spanKey{}, span), nil
+}
+
+func (h *tracingHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	span, ok := ctx.Value(spanKey{}).(Span)
+	if !ok {
+		return nil
+	}
+	for _, cmd := range cmds {
+		if err := cmd.Err(); err != nil && err != redis.Nil {
+			span.RecordError(err)
+			break
+		}
+	}
+	span.End()
+	return nil
+}
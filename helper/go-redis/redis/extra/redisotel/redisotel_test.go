@@ -0,0 +1,146 @@
+package redisotel
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"EasyDarwin/helper/go-redis/redis"
+)
+
+type fakeSpan struct {
+	name   string
+	attrs  map[string]string
+	parent interface{}
+	errs   []error
+	ended  bool
+}
+
+func (s *fakeSpan) RecordError(err error) { s.errs = append(s.errs, err) }
+func (s *fakeSpan) End()                  { s.ended = true }
+
+type parentKey struct{}
+
+type fakeTracer struct {
+	spans []*fakeSpan
+}
+
+func (t *fakeTracer) Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span) {
+	span := &fakeSpan{name: name, attrs: map[string]string{}, parent: ctx.Value(parentKey{})}
+	for _, a := range attrs {
+		span.attrs[a.Key] = a.Value
+	}
+	t.spans = append(t.spans, span)
+	return ctx, span
+}
+
+// serveNil answers every command with a nil bulk string.
+func serveNil(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			cn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer cn.Close()
+				r := bufio.NewReader(cn)
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					n, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+					for i := 0; i < 2*n; i++ {
+						if _, err := r.ReadString('\n'); err != nil {
+							return
+						}
+					}
+					cn.Write([]byte("$-1\r\n"))
+				}
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+// failHook fails the commands before they are sent.
+type failHook struct{ err error }
+
+func (h failHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	return ctx, h.err
+}
+
+func (h failHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error { return nil }
+
+func (h failHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	return ctx, h.err
+}
+
+func (h failHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error { return nil }
+
+func TestTracingHook(t *testing.T) {
+	addr := serveNil(t)
+	ring := redis.NewRing(&redis.RingOptions{
+		Addrs: map[string]string{"shard1": addr},
+	})
+	defer ring.Close()
+	tracer := new(fakeTracer)
+	ring.AddHook(NewTracingHook(tracer))
+
+	ctx := context.WithValue(context.Background(), parentKey{}, "request")
+	if err := ring.WithContext(ctx).Get("key").Err(); err != redis.Nil {
+		t.Fatalf("Get err = %v", err)
+	}
+	if len(tracer.spans) != 1 {
+		t.Fatalf("%d spans", len(tracer.spans))
+	}
+	span := tracer.spans[0]
+	want := map[string]string{"db.system": "redis", "db.operation": "get", "net.peer.name": addr}
+	if span.name != "get" || !reflect.DeepEqual(span.attrs, want) || span.parent != "request" {
+		t.Errorf("span %s %v, parent %v", span.name, span.attrs, span.parent)
+	}
+	// redis.Nil is a reply, not an error
+	if !span.ended || len(span.errs) != 0 {
+		t.Errorf("span ended %v, errors %v", span.ended, span.errs)
+	}
+}
+
+func TestTracingHookRecordsError(t *testing.T) {
+	ring := redis.NewRing(&redis.RingOptions{
+		Addrs: map[string]string{"shard1": serveNil(t)},
+	})
+	defer ring.Close()
+	tracer := new(fakeTracer)
+	ring.AddHook(NewTracingHook(tracer))
+	failure := errors.New("refused")
+	ring.AddHook(failHook{err: failure})
+
+	ring.Get("key")
+	pipe := ring.Pipeline()
+	pipe.Get("a")
+	pipe.Get("b")
+	pipe.Exec()
+
+	if len(tracer.spans) != 2 {
+		t.Fatalf("%d spans", len(tracer.spans))
+	}
+	if tracer.spans[1].name != "pipeline" || tracer.spans[1].attrs["db.redis.num_cmd"] != "2" {
+		t.Errorf("pipeline span %s %v", tracer.spans[1].name, tracer.spans[1].attrs)
+	}
+	for _, span := range tracer.spans {
+		if !span.ended || len(span.errs) != 1 || span.errs[0] != failure {
+			t.Errorf("span %s ended %v, errors %v", span.name, span.ended, span.errs)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+// Package redisstats exports pool, shard and command stats of a Client,
+// Ring or ClusterClient, e.g. through expvar.
+package redisstats
+
+import (
+	"expvar"
+	"time"
+
+	"EasyDarwin/helper/go-redis/redis"
+)
+
+// StatsProvider is implemented by redis.Client, redis.Ring and redis.ClusterClient.
+type StatsProvider interface {
+	Stats() *redis.Stats
+}
+
+var (
+	_ StatsProvider = (*redis.Client)(nil)
+	_ StatsProvider = (*redis.Ring)(nil)
+	_ StatsProvider = (*redis.ClusterClient)(nil)
+)
+
+type Pool struct {
+	Hits       uint32 `json:"hits"`
+	Misses     uint32 `json:"misses"`
+	Timeouts   uint32 `json:"timeouts"`
+	TotalConns uint32 `json:"total_conns"`
+	FreeConns  uint32 `json:"free_conns"`
+	StaleConns uint32 `json:"stale_conns"`
+
+	WaitCount    uint32        `json:"wait_count"`
+	WaitDuration time.Duration `json:"wait_duration_ns"`
+
+	ConnAgeEvictions uint32 `json:"pool_conn_age_evictions_total"`
+}
+
+type Shard struct {
+	Addr     string `json:"addr"`
+	Up       bool   `json:"up"`
+	Pool     Pool   `json:"pool"`
+	Commands uint64 `json:"commands"`
+	Errors   uint64 `json:"errors"`
+}
+
+// Snapshot is the JSON-friendly form of redis.Stats.
+type Snapshot struct {
+	Pool       Pool    `json:"pool"`
+	Commands   uint64  `json:"commands"`
+	Errors     uint64  `json:"errors"`
+	ShardsUp   int     `json:"shards_up"`
+	ShardsDown int     `json:"shards_down"`
+	Shards     []Shard `json:"shards"`
+}
+
+func newPool(s *redis.PoolStats) Pool {
+	return Pool{
+		Hits:       s.Hits,
+		Misses:     s.Misses,
+		Timeouts:   s.Timeouts,
+		TotalConns: s.TotalConns,
+		FreeConns:  s.FreeConns,
+		StaleConns: s.StaleConns,
+
+		WaitCount:    s.WaitCount,
+		WaitDuration: s.WaitDuration,
+
+		ConnAgeEvictions: s.ConnAgeEvictions,
+	}
+}
+
+// Collect takes a snapshot of the provider's stats.
+func Collect(provider StatsProvider) *Snapshot {
+	st := provider.Stats()
+	snap := &Snapshot{
+		Pool:     newPool(st.Pool),
+		Commands: st.Commands,
+		Errors:   st.Errors,
+		Shards:   make([]Shard, 0, len(st.Nodes)),
+	}
+	for _, node := range st.Nodes {
+		if node.Up {
+			snap.ShardsUp++
+		} else {
+			snap.ShardsDown++
+		}
+		snap.Shards = append(snap.Shards, Shard{
+			Addr:     node.Addr,
+			Up:       node.Up,
+			Pool:     newPool(node.Pool),
+			Commands: node.Commands,
+			Errors:   node.Errors,
+		})
+	}
+	return snap
+}
+
+// PublishExpvar publishes the provider's stats under name. A fresh snapshot
+// is taken every time /debug/vars is read. Like expvar.Publish it panics
+// if name is already registered.
+func PublishExpvar(name string, provider StatsProvider) {
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		return Collect(provider)
+	}))
+}
@@ -0,0 +1,44 @@
+package redisstats
+
+import (
+	"encoding/json"
+	"expvar"
+	"sort"
+	"testing"
+
+	"EasyDarwin/helper/go-redis/redis"
+)
+
+func TestPublishExpvarRing(t *testing.T) {
+	ring := redis.NewRing(&redis.RingOptions{
+		Addrs: map[string]string{"shard1": "127.0.0.1:7001", "shard2": "127.0.0.1:7002"},
+	})
+	defer ring.Close()
+	PublishExpvar("redisstats_test_ring", ring)
+
+	v := expvar.Get("redisstats_test_ring")
+	if v == nil {
+		t.Fatal("not published")
+	}
+	snap := Snapshot{}
+	if err := json.Unmarshal([]byte(v.String()), &snap); err != nil {
+		t.Fatalf("%v in %s", err, v.String())
+	}
+	if len(snap.Shards) != 2 || snap.ShardsUp != 2 || snap.ShardsDown != 0 {
+		t.Fatalf("snapshot = %+v", snap)
+	}
+	addrs := []string{snap.Shards[0].Addr, snap.Shards[1].Addr}
+	sort.Strings(addrs)
+	if addrs[0] != "127.0.0.1:7001" || addrs[1] != "127.0.0.1:7002" {
+		t.Fatalf("shard addrs = %v", addrs)
+	}
+
+	var raw map[string]interface{}
+	json.Unmarshal([]byte(v.String()), &raw)
+	shard := raw["shards"].([]interface{})[0].(map[string]interface{})
+	for _, key := range []string{"addr", "up", "pool", "commands", "errors"} {
+		if _, ok := shard[key]; !ok {
+			t.Errorf("shard entry has no %q: %v", key, shard)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package redis
+
+import (
+	"EasyDarwin/helper/go-redis/redis/internal"
+	"EasyDarwin/helper/go-redis/redis/internal/pool"
+)
+
+// failover promotes the first live replica of shard, which is down, and
+// replaces shard with a client to it, see RingOptions.AutoFailover. It
+// reports whether a replica was promoted. It is called on every heartbeat
+// until it does, so its failures are logged with Limitf.
+func (c *ringShards) failover(shard *ringShard, opt *RingOptions) bool {
+	name := shard.Client.opt.shardName
+	if shard.Client.slaves == nil {
+		internal.Limitf("ring shard %s is down and has no replica to fail over to", name)
+		return false
+	}
+
+	for i, slave := range shard.Client.slaves.slaves {
+		if slave.IsDown() {
+			continue
+		}
+		addr := slave.Client.opt.Addr
+		if err := slave.Client.SlaveOf("NO", "ONE").Err(); err != nil {
+			internal.Limitf("ring shard %s: promoting replica %s failed: %s", name, addr, err)
+			continue
+		}
+
+		replicas := make([]string, 0, len(shard.Client.opt.SlaveAddrs)-1)
+		replicas = append(replicas, shard.Client.opt.SlaveAddrs[:i]...)
+		replicas = append(replicas, shard.Client.opt.SlaveAddrs[i+1:]...)
+		promoted := NewClient(opt.shardOptions(name, addr, replicas))
+		if err := c.replace(name, shard, promoted); err != nil {
+			// The shard was restored or the ring closed meanwhile.
+			_ = promoted.Close()
+			return false
+		}
+		internal.Logf("ring shard %s failed over from %s to %s", name, shard.Client.opt.Addr, addr)
+		if opt.AfterFailover != nil {
+			opt.AfterFailover(name, addr)
+		}
+		return true
+	}
+
+	internal.Limitf("ring shard %s is down and no replica could be promoted", name)
+	return false
+}
+
+// replace points the shard name to a new shard of cl and closes the
+// client of old. With old set, nothing is replaced unless name is still
+// served by old.
+func (c *ringShards) replace(name string, old *ringShard, cl *Client) error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return pool.ErrClosed
+	}
+	cur, ok := c.shards[name]
+	if !ok || old != nil && cur != old {
+		c.mu.Unlock()
+		return ErrShardNotFound
+	}
+
+	shard := c.newShard(cl)
+	c.shards[name] = shard
+	// The list is read only, readers may still hold the former one.
+	list := make([]*ringShard, 0, len(c.list))
+	for _, s := range c.list {
+		if s == cur {
+			s = shard
+		}
+		list = append(list, s)
+	}
+	c.list = list
+	// The new shard is up, it must serve the keys of name right away.
+	if !c.hash.Has(name) {
+		c.hash.Add(name)
+	}
+	c.mu.Unlock()
+
+	cur.closeHeartbeatConn()
+	_ = cur.Client.Close()
+	return nil
+}
+
+// RestoreShard points the shard name back to the primary at addr, usually
+// its address in RingOptions.Addrs once it was repaired after a failover,
+// with the replicas of RingOptions.Replicas. The client of the promoted
+// replica is closed. Making the replica a replica again, or addr the
+// primary of the data, is up to the caller.
+//
+// It returns ErrShardNotFound when the ring has no shard name.
+func (c *Ring) RestoreShard(name, addr string) error {
+	cl := NewClient(c.opt.shardOptions(name, addr, c.opt.Replicas[name]))
+	if err := c.shards.replace(name, nil, cl); err != nil {
+		_ = cl.Close()
+		return err
+	}
+	internal.Logf("ring shard %s restored to %s", name, addr)
+	return nil
+}
@@ -0,0 +1,56 @@
+package redis
+
+import (
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestRingFailoverRetried fails the first promotion of the replica: the
+// failover must be tried again on the next heartbeats while the primary is
+// down, not only when the shard went down.
+func TestRingFailoverRetried(t *testing.T) {
+	primary := newReplicaServer(t, "primary")
+	var promotions int32
+	replica := newFakeServer(t, func(conn int, args []string) string {
+		switch strings.ToLower(args[0]) {
+		case "slaveof":
+			if atomic.AddInt32(&promotions, 1) == 1 {
+				return "-ERR LOADING Redis is loading the dataset in memory\r\n"
+			}
+		case "ping":
+			return "+PONG\r\n"
+		case "get":
+			return respBulk("replica")
+		}
+		return "+OK\r\n"
+	})
+	failedOver := make(chan string, 1)
+	ring := NewRing(&RingOptions{
+		Addrs:              map[string]string{"shard1": primary.Addr},
+		Replicas:           map[string][]string{"shard1": {replica.Addr}},
+		HeartbeatFrequency: 10 * time.Millisecond,
+		AutoFailover:       true,
+		AfterFailover: func(name, addr string) {
+			failedOver <- addr
+		},
+	})
+	defer ring.Close()
+
+	primary.Close()
+	select {
+	case addr := <-failedOver:
+		if addr != replica.Addr {
+			t.Fatalf("failed over to %s, want %s", addr, replica.Addr)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("no failover after %d promotions", atomic.LoadInt32(&promotions))
+	}
+	if n := atomic.LoadInt32(&promotions); n != 2 {
+		t.Fatalf("%d promotions, want 2", n)
+	}
+	if v := ring.Get("key").Val(); v != "replica" {
+		t.Fatalf("GET after the failover = %q", v)
+	}
+}
@@ -0,0 +1,187 @@
+package redis
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeServer speaks enough RESP to stand in for a redis server. Every
+// command is passed to handle with the number of the connection it came on,
+// starting at 1, and handle returns the raw reply. A nil handle answers PING
+// with PONG and everything else with OK.
+type fakeServer struct {
+	Addr   string
+	handle func(conn int, args []string) string
+
+	ln    net.Listener
+	mu    sync.Mutex
+	conns []net.Conn
+	cmds  []fakeCmd
+	wg    sync.WaitGroup
+}
+
+type fakeCmd struct {
+	Conn int
+	Args []string
+}
+
+func newFakeServer(t *testing.T, handle func(conn int, args []string) string) *fakeServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &fakeServer{Addr: ln.Addr().String(), handle: handle, ln: ln}
+	srv.wg.Add(1)
+	go srv.serve()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func (srv *fakeServer) serve() {
+	defer srv.wg.Done()
+	for {
+		cn, err := srv.ln.Accept()
+		if err != nil {
+			return
+		}
+		srv.mu.Lock()
+		srv.conns = append(srv.conns, cn)
+		id := len(srv.conns)
+		srv.mu.Unlock()
+		srv.wg.Add(1)
+		go srv.serveConn(id, cn)
+	}
+}
+
+func (srv *fakeServer) serveConn(id int, cn net.Conn) {
+	defer srv.wg.Done()
+	defer cn.Close()
+	rd := bufio.NewReader(cn)
+	for {
+		args, err := readFakeCmd(rd)
+		if err != nil {
+			return
+		}
+		srv.mu.Lock()
+		srv.cmds = append(srv.cmds, fakeCmd{id, args})
+		srv.mu.Unlock()
+		reply := "+OK\r\n"
+		if srv.handle != nil {
+			reply = srv.handle(id, args)
+		} else if strings.EqualFold(args[0], "ping") {
+			reply = "+PONG\r\n"
+		}
+		if _, err := io.WriteString(cn, reply); err != nil {
+			return
+		}
+	}
+}
+
+func readFakeCmd(rd *bufio.Reader) ([]string, error) {
+	line, err := rd.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[0] != '*' {
+		return nil, fmt.Errorf("unexpected %q", line)
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		if line, err = rd.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err = io.ReadFull(rd, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+// Cmds returns the commands received so far.
+func (srv *fakeServer) Cmds() []fakeCmd {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	return append([]fakeCmd(nil), srv.cmds...)
+}
+
+// Conns returns the number of connections accepted so far.
+func (srv *fakeServer) Conns() int {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	return len(srv.conns)
+}
+
+// Push writes raw to connection conn, e.g. a pubsub message.
+func (srv *fakeServer) Push(conn int, raw string) error {
+	srv.mu.Lock()
+	cn := srv.conns[conn-1]
+	srv.mu.Unlock()
+	_, err := io.WriteString(cn, raw)
+	return err
+}
+
+// DropConns closes the connections accepted so far.
+func (srv *fakeServer) DropConns() {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	for _, cn := range srv.conns {
+		cn.Close()
+	}
+}
+
+func (srv *fakeServer) Close() {
+	srv.ln.Close()
+	srv.DropConns()
+	srv.wg.Wait()
+}
+
+func respBulk(s string) string {
+	return fmt.Sprintf("$%d\r\n%s\r\n", len(s), s)
+}
+
+func respInt(n int64) string {
+	return fmt.Sprintf(":%d\r\n", n)
+}
+
+func respArray(items ...string) string {
+	return fmt.Sprintf("*%d\r\n%s", len(items), strings.Join(items, ""))
+}
+
+const respNil = "$-1\r\n"
+
+// respCommandInfo returns a COMMAND reply describing the commands, each
+// given as name, flags, first key position and optionally last key
+// position, e.g. "get readonly 1" or "mget readonly 1 -1".
+func respCommandInfo(cmds ...string) string {
+	var infos []string
+	for _, cmd := range cmds {
+		fields := strings.Fields(cmd)
+		first, _ := strconv.Atoi(fields[2])
+		last := first
+		if len(fields) > 3 {
+			last, _ = strconv.Atoi(fields[3])
+		}
+		infos = append(infos, respArray(
+			respBulk(fields[0]), respInt(-2),
+			respArray("+"+fields[1]+"\r\n"),
+			respInt(int64(first)), respInt(int64(last)), respInt(1),
+		))
+	}
+	return respArray(infos...)
+}
@@ -0,0 +1,114 @@
+package redis
+
+import "context"
+
+// Hook is called around every command processed by a Ring, and around
+// every pipeline or transaction executed by it.
+// BeforeProcess may return a derived context (e.g. carrying a span),
+// which is then passed to AfterProcess; likewise for the pipeline methods.
+type Hook interface {
+	BeforeProcess(ctx context.Context, cmd Cmder) (context.Context, error)
+	AfterProcess(ctx context.Context, cmd Cmder) error
+
+	BeforeProcessPipeline(ctx context.Context, cmds []Cmder) (context.Context, error)
+	AfterProcessPipeline(ctx context.Context, cmds []Cmder) error
+}
+
+type hooks struct {
+	hooks []Hook
+}
+
+// AddHook appends the hook. Hooks run in the order they were added.
+func (hs *hooks) AddHook(hook Hook) {
+	hs.hooks = append(hs.hooks, hook)
+}
+
+func (hs hooks) process(ctx context.Context, cmd Cmder, fn func(Cmder) error) error {
+	if len(hs.hooks) == 0 {
+		return fn(cmd)
+	}
+
+	ctxs := make([]context.Context, len(hs.hooks))
+	var hookIndex int
+	var retErr error
+
+	for ; hookIndex < len(hs.hooks) && retErr == nil; hookIndex++ {
+		ctxs[hookIndex] = ctx
+		var hookCtx context.Context
+		hookCtx, retErr = hs.hooks[hookIndex].BeforeProcess(ctx, cmd)
+		if hookCtx != nil {
+			ctx = hookCtx
+		}
+		if retErr != nil {
+			cmd.setErr(retErr)
+		}
+	}
+
+	if retErr == nil {
+		retErr = fn(cmd)
+	}
+
+	for hookIndex--; hookIndex >= 0; hookIndex-- {
+		if err := hs.hooks[hookIndex].AfterProcess(ctx, cmd); err != nil {
+			retErr = err
+			cmd.setErr(retErr)
+		}
+		ctx = ctxs[hookIndex]
+	}
+
+	return retErr
+}
+
+func (hs hooks) processPipeline(ctx context.Context, cmds []Cmder, fn func([]Cmder) error) error {
+	if len(hs.hooks) == 0 {
+		return fn(cmds)
+	}
+
+	ctxs := make([]context.Context, len(hs.hooks))
+	var hookIndex int
+	var retErr error
+
+	for ; hookIndex < len(hs.hooks) && retErr == nil; hookIndex++ {
+		ctxs[hookIndex] = ctx
+		var hookCtx context.Context
+		hookCtx, retErr = hs.hooks[hookIndex].BeforeProcessPipeline(ctx, cmds)
+		if hookCtx != nil {
+			ctx = hookCtx
+		}
+		if retErr != nil {
+			setCmdsErr(cmds, retErr)
+		}
+	}
+
+	if retErr == nil {
+		retErr = fn(cmds)
+	}
+
+	for hookIndex--; hookIndex >= 0; hookIndex-- {
+		if err := hs.hooks[hookIndex].AfterProcessPipeline(ctx, cmds); err != nil {
+			retErr = err
+			setCmdsErr(cmds, retErr)
+		}
+		ctx = ctxs[hookIndex]
+	}
+
+	return retErr
+}
+
+type shardAddrKey struct{}
+
+// ShardAddr returns the address of the ring shard a command is sent to.
+// It is only set on the context passed to a Hook.
+func ShardAddr(ctx context.Context) string {
+	addr, _ := ctx.Value(shardAddrKey{}).(string)
+	return addr
+}
+
+type shardNameKey struct{}
+
+// ShardName returns the name of the ring shard a connection is for.
+// It is only set on the context passed to Options.OnConnectContext.
+func ShardName(ctx context.Context) string {
+	name, _ := ctx.Value(shardNameKey{}).(string)
+	return name
+}
@@ -0,0 +1,168 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type recordingHook struct {
+	name  string
+	calls *[]string
+	err   error
+}
+
+func (h recordingHook) BeforeProcess(ctx context.Context, cmd Cmder) (context.Context, error) {
+	*h.calls = append(*h.calls, h.name+".before")
+	return ctx, h.err
+}
+
+func (h recordingHook) AfterProcess(ctx context.Context, cmd Cmder) error {
+	*h.calls = append(*h.calls, h.name+".after")
+	return nil
+}
+
+func (h recordingHook) BeforeProcessPipeline(ctx context.Context, cmds []Cmder) (context.Context, error) {
+	*h.calls = append(*h.calls, h.name+".beforePipeline")
+	return ctx, h.err
+}
+
+func (h recordingHook) AfterProcessPipeline(ctx context.Context, cmds []Cmder) error {
+	*h.calls = append(*h.calls, h.name+".afterPipeline")
+	return nil
+}
+
+func TestHooksProcessPipeline(t *testing.T) {
+	var calls []string
+	var hs hooks
+	hs.AddHook(recordingHook{name: "a", calls: &calls})
+	hs.AddHook(recordingHook{name: "b", calls: &calls})
+
+	cmds := []Cmder{NewStatusCmd("ping"), NewStatusCmd("ping")}
+	err := hs.processPipeline(context.Background(), cmds, func(cmds []Cmder) error {
+		calls = append(calls, "exec")
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"a.beforePipeline", "b.beforePipeline", "exec", "b.afterPipeline", "a.afterPipeline"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Fatalf("calls = %v, want %v", calls, want)
+	}
+}
+
+func TestHooksProcessPipelineBeforeError(t *testing.T) {
+	var calls []string
+	var hs hooks
+	denied := errors.New("denied")
+	hs.AddHook(recordingHook{name: "a", calls: &calls, err: denied})
+	hs.AddHook(recordingHook{name: "b", calls: &calls})
+
+	cmds := []Cmder{NewStatusCmd("ping")}
+	err := hs.processPipeline(context.Background(), cmds, func(cmds []Cmder) error {
+		t.Fatal("pipeline executed after a BeforeProcessPipeline error")
+		return nil
+	})
+	if err != denied {
+		t.Fatalf("err = %v, want %v", err, denied)
+	}
+	if cmds[0].Err() != denied {
+		t.Fatalf("cmd err = %v, want %v", cmds[0].Err(), denied)
+	}
+	want := []string{"a.beforePipeline", "a.afterPipeline"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Fatalf("calls = %v, want %v", calls, want)
+	}
+}
+
+type ctxKey struct{}
+
+// ctxHook puts its name in the context of BeforeProcess and records the value
+// AfterProcess gets back, with the shard address.
+type ctxHook struct {
+	name  string
+	calls *[]string
+}
+
+func (h ctxHook) BeforeProcess(ctx context.Context, cmd Cmder) (context.Context, error) {
+	*h.calls = append(*h.calls, h.name+".before "+cmd.Name()+" "+ShardAddr(ctx))
+	return context.WithValue(ctx, ctxKey{}, h.name), nil
+}
+
+func (h ctxHook) AfterProcess(ctx context.Context, cmd Cmder) error {
+	*h.calls = append(*h.calls, h.name+".after "+ctx.Value(ctxKey{}).(string))
+	return nil
+}
+
+func (h ctxHook) BeforeProcessPipeline(ctx context.Context, cmds []Cmder) (context.Context, error) {
+	return ctx, nil
+}
+
+func (h ctxHook) AfterProcessPipeline(ctx context.Context, cmds []Cmder) error {
+	return nil
+}
+
+func TestHooksProcess(t *testing.T) {
+	var calls []string
+	var hs hooks
+	hs.AddHook(recordingHook{name: "a", calls: &calls})
+	hs.AddHook(recordingHook{name: "b", calls: &calls})
+
+	err := hs.process(context.Background(), NewStatusCmd("ping"), func(cmd Cmder) error {
+		calls = append(calls, "exec")
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"a.before", "b.before", "exec", "b.after", "a.after"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Fatalf("calls = %v, want %v", calls, want)
+	}
+}
+
+func TestHooksProcessBeforeError(t *testing.T) {
+	var calls []string
+	var hs hooks
+	denied := errors.New("denied")
+	hs.AddHook(recordingHook{name: "a", calls: &calls})
+	hs.AddHook(recordingHook{name: "b", calls: &calls, err: denied})
+	hs.AddHook(recordingHook{name: "c", calls: &calls})
+
+	cmd := NewStatusCmd("ping")
+	err := hs.process(context.Background(), cmd, func(cmd Cmder) error {
+		t.Fatal("command executed after a BeforeProcess error")
+		return nil
+	})
+	if err != denied || cmd.Err() != denied {
+		t.Fatalf("err = %v, cmd err = %v, want %v", err, cmd.Err(), denied)
+	}
+	want := []string{"a.before", "b.before", "b.after", "a.after"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Fatalf("calls = %v, want %v", calls, want)
+	}
+}
+
+func TestRingHooksProcess(t *testing.T) {
+	srv := newFakeServer(t, func(conn int, args []string) string {
+		return "$1\r\nv\r\n"
+	})
+	ring := NewRing(&RingOptions{
+		Addrs: map[string]string{"shard1": srv.Addr},
+	})
+	defer ring.Close()
+
+	var calls []string
+	ring.AddHook(ctxHook{name: "a", calls: &calls})
+	ring.AddHook(ctxHook{name: "b", calls: &calls})
+	if val, err := ring.Get("key").Result(); err != nil || val != "v" {
+		t.Fatalf("Get = %q, %v", val, err)
+	}
+	// each AfterProcess gets the context its own BeforeProcess returned
+	want := []string{"a.before get " + srv.Addr, "b.before get " + srv.Addr, "b.after b", "a.after a"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Fatalf("calls = %v, want %v", calls, want)
+	}
+}
@@ -0,0 +1,284 @@
+package redis
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"EasyDarwin/helper/go-redis/redis/internal"
+	"EasyDarwin/helper/go-redis/redis/internal/pool"
+)
+
+// InfoMapCmd is the INFO reply grouped by lower cased section name,
+// e.g. Val()["memory"]["used_memory"].
+type InfoMapCmd struct {
+	baseCmd
+
+	val map[string]map[string]string
+}
+
+var _ Cmder = (*InfoMapCmd)(nil)
+
+func NewInfoMapCmd(args ...interface{}) *InfoMapCmd {
+	return &InfoMapCmd{
+		baseCmd: baseCmd{_args: args},
+	}
+}
+
+func (cmd *InfoMapCmd) Val() map[string]map[string]string {
+	return cmd.val
+}
+
+func (cmd *InfoMapCmd) Result() (map[string]map[string]string, error) {
+	return cmd.val, cmd.err
+}
+
+// Stats parses the common fields of the reply. Fields of sections that
+// were not requested are left zero.
+func (cmd *InfoMapCmd) Stats() (*InfoStats, error) {
+	if cmd.err != nil {
+		return nil, cmd.err
+	}
+	return NewInfoStats(cmd.val)
+}
+
+func (cmd *InfoMapCmd) String() string {
+	return cmdString(cmd, cmd.val)
+}
+
+func (cmd *InfoMapCmd) readReply(cn *pool.Conn) error {
+	var b []byte
+	b, cmd.err = cn.Rd.ReadBytesReply()
+	if cmd.err != nil {
+		return cmd.err
+	}
+	cmd.val = ParseInfo(string(b))
+	return nil
+}
+
+func (c *cmdable) InfoMap(section ...string) *InfoMapCmd {
+	args := []interface{}{"info"}
+	if len(section) > 0 {
+		args = append(args, section[0])
+	}
+	cmd := NewInfoMapCmd(args...)
+	c.process(cmd)
+	return cmd
+}
+
+// ParseInfo parses an INFO bulk string. Keyspace lines such as
+// "db0:keys=1,expires=0,avg_ttl=0" are kept as "db0" => "keys=1,...".
+func ParseInfo(info string) map[string]map[string]string {
+	m := make(map[string]map[string]string)
+	var section map[string]string
+	for _, line := range strings.Split(info, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if line[0] == '#' {
+			name := internal.ToLower(strings.TrimSpace(line[1:]))
+			section = m[name]
+			if section == nil {
+				section = make(map[string]string)
+				m[name] = section
+			}
+			continue
+		}
+		ind := strings.IndexByte(line, ':')
+		if ind == -1 {
+			continue
+		}
+		if section == nil {
+			section = make(map[string]string)
+			m[""] = section
+		}
+		section[line[:ind]] = line[ind+1:]
+	}
+	return m
+}
+
+//------------------------------------------------------------------------------
+
+type KeyspaceStats struct {
+	Keys    int64
+	Expires int64
+	AvgTTL  time.Duration
+}
+
+// InfoStats holds the commonly used INFO fields.
+type InfoStats struct {
+	// Server
+	Version string
+	Mode    string
+	Uptime  time.Duration
+
+	// Clients
+	ConnectedClients int64
+	BlockedClients   int64
+
+	// Memory
+	UsedMemory            int64
+	UsedMemoryRSS         int64
+	UsedMemoryPeak        int64
+	MaxMemory             int64
+	MaxMemoryPolicy       string
+	MemFragmentationRatio float64
+
+	// Persistence
+	Loading                 bool
+	RDBChangesSinceLastSave int64
+	RDBBgsaveInProgress     bool
+	RDBLastSaveTime         time.Time
+	RDBLastBgsaveStatus     string
+	AOFEnabled              bool
+	AOFRewriteInProgress    bool
+	AOFLastWriteStatus      string
+
+	// Stats
+	TotalConnectionsReceived int64
+	TotalCommandsProcessed   int64
+	InstantaneousOpsPerSec   int64
+	RejectedConnections      int64
+	ExpiredKeys              int64
+	EvictedKeys              int64
+	KeyspaceHits             int64
+	KeyspaceMisses           int64
+
+	// Replication
+	Role             string
+	ConnectedSlaves  int64
+	MasterReplOffset int64
+	MasterLinkStatus string
+
+	// Keyspace by db index.
+	Keyspace map[int]KeyspaceStats
+}
+
+// NewInfoStats builds InfoStats from the result of ParseInfo.
+// Missing fields are left zero, malformed numbers are an error.
+func NewInfoStats(info map[string]map[string]string) (*InfoStats, error) {
+	p := infoParser{info: info}
+	st := &InfoStats{
+		Version: p.string("server", "redis_version"),
+		Mode:    p.string("server", "redis_mode"),
+		Uptime:  time.Duration(p.int("server", "uptime_in_seconds")) * time.Second,
+
+		ConnectedClients: p.int("clients", "connected_clients"),
+		BlockedClients:   p.int("clients", "blocked_clients"),
+
+		UsedMemory:            p.int("memory", "used_memory"),
+		UsedMemoryRSS:         p.int("memory", "used_memory_rss"),
+		UsedMemoryPeak:        p.int("memory", "used_memory_peak"),
+		MaxMemory:             p.int("memory", "maxmemory"),
+		MaxMemoryPolicy:       p.string("memory", "maxmemory_policy"),
+		MemFragmentationRatio: p.float("memory", "mem_fragmentation_ratio"),
+
+		Loading:                 p.bool("persistence", "loading"),
+		RDBChangesSinceLastSave: p.int("persistence", "rdb_changes_since_last_save"),
+		RDBBgsaveInProgress:     p.bool("persistence", "rdb_bgsave_in_progress"),
+		RDBLastBgsaveStatus:     p.string("persistence", "rdb_last_bgsave_status"),
+		AOFEnabled:              p.bool("persistence", "aof_enabled"),
+		AOFRewriteInProgress:    p.bool("persistence", "aof_rewrite_in_progress"),
+		AOFLastWriteStatus:      p.string("persistence", "aof_last_write_status"),
+
+		TotalConnectionsReceived: p.int("stats", "total_connections_received"),
+		TotalCommandsProcessed:   p.int("stats", "total_commands_processed"),
+		InstantaneousOpsPerSec:   p.int("stats", "instantaneous_ops_per_sec"),
+		RejectedConnections:      p.int("stats", "rejected_connections"),
+		ExpiredKeys:              p.int("stats", "expired_keys"),
+		EvictedKeys:              p.int("stats", "evicted_keys"),
+		KeyspaceHits:             p.int("stats", "keyspace_hits"),
+		KeyspaceMisses:           p.int("stats", "keyspace_misses"),
+
+		Role:             p.string("replication", "role"),
+		ConnectedSlaves:  p.int("replication", "connected_slaves"),
+		MasterReplOffset: p.int("replication", "master_repl_offset"),
+		MasterLinkStatus: p.string("replication", "master_link_status"),
+	}
+	if sec := p.int("persistence", "rdb_last_save_time"); sec > 0 {
+		st.RDBLastSaveTime = time.Unix(sec, 0)
+	}
+	if p.err != nil {
+		return nil, p.err
+	}
+
+	keyspace, err := parseKeyspace(info["keyspace"])
+	if err != nil {
+		return nil, err
+	}
+	st.Keyspace = keyspace
+	return st, nil
+}
+
+func parseKeyspace(section map[string]string) (map[int]KeyspaceStats, error) {
+	keyspace := make(map[int]KeyspaceStats, len(section))
+	for name, value := range section {
+		if !strings.HasPrefix(name, "db") {
+			continue
+		}
+		db, err := strconv.Atoi(name[2:])
+		if err != nil {
+			return nil, fmt.Errorf("redis: invalid keyspace db %q", name)
+		}
+		var ks KeyspaceStats
+		for _, field := range strings.Split(value, ",") {
+			ind := strings.IndexByte(field, '=')
+			if ind == -1 {
+				continue
+			}
+			n, err := strconv.ParseInt(field[ind+1:], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("redis: invalid keyspace field %s %q", name, field)
+			}
+			switch field[:ind] {
+			case "keys":
+				ks.Keys = n
+			case "expires":
+				ks.Expires = n
+			case "avg_ttl":
+				ks.AvgTTL = time.Duration(n) * time.Millisecond
+			}
+		}
+		keyspace[db] = ks
+	}
+	return keyspace, nil
+}
+
+type infoParser struct {
+	info map[string]map[string]string
+	err  error
+}
+
+func (p *infoParser) string(section, key string) string {
+	return p.info[section][key]
+}
+
+func (p *infoParser) int(section, key string) int64 {
+	s := p.string(section, key)
+	if s == "" {
+		return 0
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil && p.err == nil {
+		p.err = fmt.Errorf("redis: invalid INFO field %s=%q", key, s)
+	}
+	return n
+}
+
+func (p *infoParser) float(section, key string) float64 {
+	s := p.string(section, key)
+	if s == "" {
+		return 0
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil && p.err == nil {
+		p.err = fmt.Errorf("redis: invalid INFO field %s=%q", key, s)
+	}
+	return f
+}
+
+func (p *infoParser) bool(section, key string) bool {
+	return p.int(section, key) != 0
+}
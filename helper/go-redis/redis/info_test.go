@@ -0,0 +1,279 @@
+package redis
+
+import (
+	"testing"
+	"time"
+)
+
+// INFO of a Redis 5 master, trimmed
+const info5 = "" +
+	"# Server\r\n" +
+	"redis_version:5.0.7\r\n" +
+	"redis_git_sha1:00000000\r\n" +
+	"redis_git_dirty:0\r\n" +
+	"redis_build_id:66bd629f924ac924\r\n" +
+	"redis_mode:standalone\r\n" +
+	"os:Linux 5.4.0-42-generic x86_64\r\n" +
+	"arch_bits:64\r\n" +
+	"multiplexing_api:epoll\r\n" +
+	"gcc_version:9.3.0\r\n" +
+	"process_id:812\r\n" +
+	"run_id:9d2bc6cc5c84b4e7b8ab5b8e0ab1d1b3a4f1e3f2\r\n" +
+	"tcp_port:6379\r\n" +
+	"uptime_in_seconds:86400\r\n" +
+	"uptime_in_days:1\r\n" +
+	"hz:10\r\n" +
+	"configured_hz:10\r\n" +
+	"lru_clock:10390523\r\n" +
+	"executable:/usr/bin/redis-server\r\n" +
+	"config_file:/etc/redis/redis.conf\r\n" +
+	"\r\n" +
+	"# Clients\r\n" +
+	"connected_clients:12\r\n" +
+	"client_recent_max_input_buffer:2\r\n" +
+	"client_recent_max_output_buffer:0\r\n" +
+	"blocked_clients:1\r\n" +
+	"\r\n" +
+	"# Memory\r\n" +
+	"used_memory:1048576\r\n" +
+	"used_memory_human:1.00M\r\n" +
+	"used_memory_rss:4194304\r\n" +
+	"used_memory_rss_human:4.00M\r\n" +
+	"used_memory_peak:2097152\r\n" +
+	"used_memory_peak_human:2.00M\r\n" +
+	"maxmemory:0\r\n" +
+	"maxmemory_human:0B\r\n" +
+	"maxmemory_policy:noeviction\r\n" +
+	"mem_fragmentation_ratio:4.00\r\n" +
+	"mem_allocator:jemalloc-5.2.1\r\n" +
+	"\r\n" +
+	"# Persistence\r\n" +
+	"loading:0\r\n" +
+	"rdb_changes_since_last_save:7\r\n" +
+	"rdb_bgsave_in_progress:0\r\n" +
+	"rdb_last_save_time:1600000000\r\n" +
+	"rdb_last_bgsave_status:ok\r\n" +
+	"aof_enabled:0\r\n" +
+	"aof_rewrite_in_progress:0\r\n" +
+	"aof_last_write_status:ok\r\n" +
+	"\r\n" +
+	"# Stats\r\n" +
+	"total_connections_received:120\r\n" +
+	"total_commands_processed:4500\r\n" +
+	"instantaneous_ops_per_sec:3\r\n" +
+	"rejected_connections:0\r\n" +
+	"expired_keys:10\r\n" +
+	"evicted_keys:0\r\n" +
+	"keyspace_hits:900\r\n" +
+	"keyspace_misses:100\r\n" +
+	"\r\n" +
+	"# Replication\r\n" +
+	"role:master\r\n" +
+	"connected_slaves:1\r\n" +
+	"slave0:ip=10.0.0.2,port=6379,state=online,offset=1234,lag=0\r\n" +
+	"master_replid:2b6bd3a8c4f3b3c6e1a2f0d9c8b7a6f5e4d3c2b1\r\n" +
+	"master_repl_offset:1234\r\n" +
+	"\r\n" +
+	"# CPU\r\n" +
+	"used_cpu_sys:12.50\r\n" +
+	"used_cpu_user:8.25\r\n" +
+	"\r\n" +
+	"# Cluster\r\n" +
+	"cluster_enabled:0\r\n" +
+	"\r\n" +
+	"# Keyspace\r\n" +
+	"db0:keys=42,expires=2,avg_ttl=3600000\r\n" +
+	"db3:keys=1,expires=0,avg_ttl=0\r\n"
+
+// INFO of a Redis 6 replica, trimmed
+const info6 = "" +
+	"# Server\r\n" +
+	"redis_version:6.2.6\r\n" +
+	"redis_git_sha1:00000000\r\n" +
+	"redis_git_dirty:0\r\n" +
+	"redis_build_id:b61f37314a089f19\r\n" +
+	"redis_mode:standalone\r\n" +
+	"os:Linux 5.15.0-1019-aws x86_64\r\n" +
+	"arch_bits:64\r\n" +
+	"monotonic_clock:POSIX clock_gettime\r\n" +
+	"multiplexing_api:epoll\r\n" +
+	"atomicvar_api:c11-builtin\r\n" +
+	"gcc_version:10.2.1\r\n" +
+	"process_id:1\r\n" +
+	"process_supervised:no\r\n" +
+	"run_id:4f5ee4fd6b52fcdb52b2cc4f8b4d8cfc1c2e0d63\r\n" +
+	"tcp_port:6379\r\n" +
+	"server_time_usec:1665900000000000\r\n" +
+	"uptime_in_seconds:3600\r\n" +
+	"uptime_in_days:0\r\n" +
+	"hz:10\r\n" +
+	"configured_hz:10\r\n" +
+	"lru_clock:7283301\r\n" +
+	"executable:/data/redis-server\r\n" +
+	"config_file:\r\n" +
+	"io_threads_active:0\r\n" +
+	"\r\n" +
+	"# Clients\r\n" +
+	"connected_clients:3\r\n" +
+	"cluster_connections:0\r\n" +
+	"maxclients:10000\r\n" +
+	"client_recent_max_input_buffer:24\r\n" +
+	"client_recent_max_output_buffer:0\r\n" +
+	"blocked_clients:0\r\n" +
+	"tracking_clients:0\r\n" +
+	"clients_in_timeout_table:0\r\n" +
+	"\r\n" +
+	"# Memory\r\n" +
+	"used_memory:873448\r\n" +
+	"used_memory_human:852.98K\r\n" +
+	"used_memory_rss:7475200\r\n" +
+	"used_memory_rss_human:7.13M\r\n" +
+	"used_memory_peak:932352\r\n" +
+	"used_memory_peak_human:910.50K\r\n" +
+	"maxmemory:268435456\r\n" +
+	"maxmemory_human:256.00M\r\n" +
+	"maxmemory_policy:allkeys-lru\r\n" +
+	"allocator_frag_ratio:1.28\r\n" +
+	"mem_fragmentation_ratio:8.96\r\n" +
+	"mem_allocator:jemalloc-5.1.0\r\n" +
+	"lazyfree_pending_objects:0\r\n" +
+	"\r\n" +
+	"# Persistence\r\n" +
+	"loading:0\r\n" +
+	"current_cow_size:0\r\n" +
+	"rdb_changes_since_last_save:0\r\n" +
+	"rdb_bgsave_in_progress:0\r\n" +
+	"rdb_last_save_time:1665896400\r\n" +
+	"rdb_last_bgsave_status:ok\r\n" +
+	"aof_enabled:1\r\n" +
+	"aof_rewrite_in_progress:0\r\n" +
+	"aof_last_write_status:ok\r\n" +
+	"\r\n" +
+	"# Stats\r\n" +
+	"total_connections_received:8\r\n" +
+	"total_commands_processed:21\r\n" +
+	"instantaneous_ops_per_sec:0\r\n" +
+	"total_net_input_bytes:512\r\n" +
+	"rejected_connections:0\r\n" +
+	"expired_keys:0\r\n" +
+	"expired_stale_perc:0.00\r\n" +
+	"evicted_keys:0\r\n" +
+	"keyspace_hits:5\r\n" +
+	"keyspace_misses:2\r\n" +
+	"total_reads_processed:29\r\n" +
+	"total_writes_processed:21\r\n" +
+	"io_threaded_reads_processed:0\r\n" +
+	"\r\n" +
+	"# Replication\r\n" +
+	"role:slave\r\n" +
+	"master_host:10.0.0.1\r\n" +
+	"master_port:6379\r\n" +
+	"master_link_status:up\r\n" +
+	"master_last_io_seconds_ago:1\r\n" +
+	"master_sync_in_progress:0\r\n" +
+	"slave_repl_offset:5678\r\n" +
+	"slave_priority:100\r\n" +
+	"slave_read_only:1\r\n" +
+	"replica_announced:1\r\n" +
+	"connected_slaves:0\r\n" +
+	"master_failover_state:no-failover\r\n" +
+	"master_replid:c0a7f2d3e4b5a6978877665544332211ffeeddcc\r\n" +
+	"master_repl_offset:5678\r\n" +
+	"\r\n" +
+	"# CPU\r\n" +
+	"used_cpu_sys:1.234567\r\n" +
+	"used_cpu_user:0.765432\r\n" +
+	"\r\n" +
+	"# Modules\r\n" +
+	"\r\n" +
+	"# Errorstats\r\n" +
+	"errorstat_ERR:count=1\r\n" +
+	"\r\n" +
+	"# Cluster\r\n" +
+	"cluster_enabled:0\r\n" +
+	"\r\n" +
+	"# Keyspace\r\n" +
+	"db0:keys=3,expires=1,avg_ttl=12000\r\n"
+
+func TestParseInfoRedis5(t *testing.T) {
+	info := ParseInfo(info5)
+	if info["server"]["redis_version"] != "5.0.7" || info["keyspace"]["db0"] != "keys=42,expires=2,avg_ttl=3600000" {
+		t.Fatalf("info = %v", info)
+	}
+	st, err := NewInfoStats(info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Version != "5.0.7" || st.Mode != "standalone" || st.Uptime != 24*time.Hour {
+		t.Fatalf("server = %s %s %v", st.Version, st.Mode, st.Uptime)
+	}
+	if st.ConnectedClients != 12 || st.BlockedClients != 1 {
+		t.Fatalf("clients = %d %d", st.ConnectedClients, st.BlockedClients)
+	}
+	if st.UsedMemory != 1048576 || st.UsedMemoryRSS != 4194304 || st.MaxMemory != 0 || st.MaxMemoryPolicy != "noeviction" || st.MemFragmentationRatio != 4 {
+		t.Fatalf("memory = %+v", st)
+	}
+	if st.RDBChangesSinceLastSave != 7 || !st.RDBLastSaveTime.Equal(time.Unix(1600000000, 0)) || st.AOFEnabled {
+		t.Fatalf("persistence = %+v", st)
+	}
+	if st.TotalCommandsProcessed != 4500 || st.KeyspaceHits != 900 || st.KeyspaceMisses != 100 || st.ExpiredKeys != 10 {
+		t.Fatalf("stats = %+v", st)
+	}
+	if st.Role != "master" || st.ConnectedSlaves != 1 || st.MasterReplOffset != 1234 {
+		t.Fatalf("replication = %s %d %d", st.Role, st.ConnectedSlaves, st.MasterReplOffset)
+	}
+	if len(st.Keyspace) != 2 || st.Keyspace[0] != (KeyspaceStats{42, 2, time.Hour}) || st.Keyspace[3].Keys != 1 {
+		t.Fatalf("keyspace = %v", st.Keyspace)
+	}
+}
+
+func TestParseInfoRedis6(t *testing.T) {
+	st, err := NewInfoStats(ParseInfo(info6))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Version != "6.2.6" || st.Uptime != time.Hour || st.ConnectedClients != 3 {
+		t.Fatalf("stats = %+v", st)
+	}
+	if st.MaxMemory != 268435456 || st.MaxMemoryPolicy != "allkeys-lru" || st.MemFragmentationRatio != 8.96 {
+		t.Fatalf("memory = %+v", st)
+	}
+	if !st.AOFEnabled || st.AOFLastWriteStatus != "ok" {
+		t.Fatalf("persistence = %+v", st)
+	}
+	if st.Role != "slave" || st.MasterLinkStatus != "up" || st.MasterReplOffset != 5678 {
+		t.Fatalf("replication = %s %s %d", st.Role, st.MasterLinkStatus, st.MasterReplOffset)
+	}
+	if st.Keyspace[0] != (KeyspaceStats{3, 1, 12 * time.Second}) {
+		t.Fatalf("keyspace = %v", st.Keyspace)
+	}
+}
+
+func TestNewInfoStatsInvalid(t *testing.T) {
+	if _, err := NewInfoStats(ParseInfo("# Clients\r\nconnected_clients:many\r\n")); err == nil {
+		t.Fatal("malformed number accepted")
+	}
+	if _, err := NewInfoStats(ParseInfo("# Keyspace\r\ndb0:keys=x\r\n")); err == nil {
+		t.Fatal("malformed keyspace accepted")
+	}
+}
+
+func TestInfoMap(t *testing.T) {
+	srv := newFakeServer(t, func(conn int, args []string) string {
+		return respBulk(info6)
+	})
+	client := NewClient(&Options{Addr: srv.Addr})
+	defer client.Close()
+
+	cmd := client.InfoMap("replication")
+	if cmd.Val()["replication"]["role"] != "slave" {
+		t.Fatalf("InfoMap = %v, err %v", cmd.Val(), cmd.Err())
+	}
+	cmds := srv.Cmds()
+	if args := cmds[len(cmds)-1].Args; len(args) != 2 || args[1] != "replication" {
+		t.Fatalf("command = %q", args)
+	}
+	if st, err := cmd.Stats(); err != nil || st.Role != "slave" {
+		t.Fatalf("Stats = %+v, %v", st, err)
+	}
+}
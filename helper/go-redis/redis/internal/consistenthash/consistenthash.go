@@ -21,6 +21,7 @@ import (
 	"hash/crc32"
 	"sort"
 	"strconv"
+	"sync"
 )
 
 type Hash func(data []byte) uint32
@@ -30,6 +31,7 @@ type Map struct {
 	replicas int
 	keys     []int // Sorted
 	hashMap  map[int]string
+	members  map[string]struct{}
 }
 
 func New(replicas int, fn Hash) *Map {
@@ -37,6 +39,7 @@ func New(replicas int, fn Hash) *Map {
 		replicas: replicas,
 		hash:     fn,
 		hashMap:  make(map[int]string),
+		members:  make(map[string]struct{}),
 	}
 	if m.hash == nil {
 		m.hash = crc32.ChecksumIEEE
@@ -51,16 +54,67 @@ func (m *Map) IsEmpty() bool {
 
 // Adds some keys to the hash.
 func (m *Map) Add(keys ...string) {
+	if len(keys) == 0 {
+		return
+	}
 	for _, key := range keys {
 		for i := 0; i < m.replicas; i++ {
 			hash := int(m.hash([]byte(strconv.Itoa(i) + key)))
 			m.keys = append(m.keys, hash)
 			m.hashMap[hash] = key
 		}
+		m.members[key] = struct{}{}
 	}
 	sort.Ints(m.keys)
 }
 
+// Removes some keys from the hash. Items of the other keys stay where
+// they are, so only the items of the removed keys move.
+func (m *Map) Remove(keys ...string) {
+	removed := false
+	for _, key := range keys {
+		if _, ok := m.members[key]; !ok {
+			continue
+		}
+		for i := 0; i < m.replicas; i++ {
+			hash := int(m.hash([]byte(strconv.Itoa(i) + key)))
+			// A colliding replica of another key is kept.
+			if m.hashMap[hash] == key {
+				delete(m.hashMap, hash)
+			}
+		}
+		delete(m.members, key)
+		removed = true
+	}
+	if !removed {
+		return
+	}
+	// Filtering keeps m.keys sorted.
+	sorted := m.keys[:0]
+	for _, hash := range m.keys {
+		if _, ok := m.hashMap[hash]; ok {
+			sorted = append(sorted, hash)
+		}
+	}
+	m.keys = sorted
+}
+
+// Returns the keys in the hash, sorted.
+func (m *Map) Members() []string {
+	members := make([]string, 0, len(m.members))
+	for key := range m.members {
+		members = append(members, key)
+	}
+	sort.Strings(members)
+	return members
+}
+
+// Returns true if key is in the hash.
+func (m *Map) Has(key string) bool {
+	_, ok := m.members[key]
+	return ok
+}
+
 // Gets the closest item in the hash to the provided key.
 func (m *Map) Get(key string) string {
 	if m.IsEmpty() {
@@ -79,3 +133,109 @@ func (m *Map) Get(key string) string {
 
 	return m.hashMap[m.keys[idx]]
 }
+
+// Gets the n-th member after the member of the closest item in the hash to
+// the provided key, going round the ring; n = 0 is Get(key).
+func (m *Map) GetNext(key string, n int) string {
+	if m.IsEmpty() {
+		return ""
+	}
+	n %= len(m.members)
+
+	hash := int(m.hash([]byte(key)))
+	idx := sort.Search(len(m.keys), func(i int) bool { return m.keys[i] >= hash })
+
+	seen := make(map[string]struct{}, n)
+	for i := 0; i < len(m.keys); i++ {
+		member := m.hashMap[m.keys[(idx+i)%len(m.keys)]]
+		if _, ok := seen[member]; ok {
+			continue
+		}
+		if len(seen) == n {
+			return member
+		}
+		seen[member] = struct{}{}
+	}
+	return ""
+}
+
+// BoundedMap is a Map with bounded loads: a key goes to the next member on
+// the ring when its member would carry more than c times the average load.
+// Loads are reported by the caller with AddLoad.
+type BoundedMap struct {
+	*Map
+
+	mu    sync.Mutex
+	loads map[string]float64
+}
+
+func NewBounded(replicas int, fn Hash) *BoundedMap {
+	return &BoundedMap{
+		Map:   New(replicas, fn),
+		loads: make(map[string]float64),
+	}
+}
+
+// Gets the first member from the closest item in the hash to the provided
+// key whose load stays within c times the average load with the key added.
+// The closest member is returned when none does, e.g. for c <= 1.
+func (m *BoundedMap) GetWithLoad(key string, c float64) string {
+	if m.IsEmpty() {
+		return ""
+	}
+
+	hash := int(m.hash([]byte(key)))
+	idx := sort.Search(len(m.keys), func(i int) bool { return m.keys[i] >= hash })
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var total float64
+	for member := range m.members {
+		total += m.loads[member]
+	}
+	capacity := c * (total + 1) / float64(len(m.members))
+
+	for i := 0; i < len(m.keys); i++ {
+		member := m.hashMap[m.keys[(idx+i)%len(m.keys)]]
+		if m.loads[member]+1 <= capacity {
+			return member
+		}
+	}
+	return m.hashMap[m.keys[idx%len(m.keys)]]
+}
+
+// Adds n to the load of member.
+func (m *BoundedMap) AddLoad(member string, n float64) {
+	m.mu.Lock()
+	m.loads[member] += n
+	m.mu.Unlock()
+}
+
+// Multiplies every load by factor, e.g. 0.5 halves them, so past hot spots
+// fade out.
+func (m *BoundedMap) Decay(factor float64) {
+	m.mu.Lock()
+	for member, load := range m.loads {
+		m.loads[member] = load * factor
+	}
+	m.mu.Unlock()
+}
+
+// Sets every load to zero.
+func (m *BoundedMap) ResetLoads() {
+	m.mu.Lock()
+	m.loads = make(map[string]float64)
+	m.mu.Unlock()
+}
+
+// Returns a copy of the loads of the members.
+func (m *BoundedMap) Loads() map[string]float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	loads := make(map[string]float64, len(m.members))
+	for member := range m.members {
+		loads[member] = m.loads[member]
+	}
+	return loads
+}
@@ -0,0 +1,146 @@
+package consistenthash
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+)
+
+func TestRemoveKeepsOtherMembers(t *testing.T) {
+	hash := New(100, nil)
+	hash.Add("shard1", "shard2", "shard3", "shard4")
+
+	before := make(map[string]string)
+	for i := 0; i < 10000; i++ {
+		key := "key" + strconv.Itoa(i)
+		before[key] = hash.Get(key)
+	}
+
+	hash.Remove("shard2")
+	if hash.Has("shard2") || fmt.Sprint(hash.Members()) != "[shard1 shard3 shard4]" {
+		t.Fatalf("members = %v", hash.Members())
+	}
+	moved := 0
+	for key, member := range before {
+		got := hash.Get(key)
+		if member != "shard2" && got != member {
+			t.Fatalf("%s moved from %s to %s", key, member, got)
+		}
+		if member == "shard2" {
+			if got == "shard2" {
+				t.Fatalf("%s still on the removed member", key)
+			}
+			moved++
+		}
+	}
+	if moved == 0 {
+		t.Fatal("no key was on shard2")
+	}
+
+	// adding it back restores the original assignment
+	hash.Add("shard2")
+	for key, member := range before {
+		if got := hash.Get(key); got != member {
+			t.Fatalf("%s on %s after re-adding, want %s", key, got, member)
+		}
+	}
+}
+
+func TestRemoveUnknown(t *testing.T) {
+	hash := New(3, nil)
+	hash.Add("a")
+	hash.Remove("b")
+	if !hash.Has("a") || len(hash.keys) != 3 {
+		t.Fatalf("keys = %v after removing an unknown member", hash.keys)
+	}
+	hash.Remove("a")
+	if !hash.IsEmpty() || hash.Get("x") != "" {
+		t.Fatal("hash not empty after removing the last member")
+	}
+}
+
+// The cost of a shard going down and coming back: rebuilding the whole
+// hash, as rebalance did before, against removing and adding the shard.
+func BenchmarkRebalance(b *testing.B) {
+	var members []string
+	for i := 0; i < 16; i++ {
+		members = append(members, "shard"+strconv.Itoa(i))
+	}
+	b.Run("Rebuild", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			hash := New(100, nil)
+			hash.Add(members[1:]...)
+			hash = New(100, nil)
+			hash.Add(members...)
+		}
+	})
+	b.Run("RemoveAdd", func(b *testing.B) {
+		hash := New(100, nil)
+		hash.Add(members...)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			hash.Remove(members[0])
+			hash.Add(members[0])
+		}
+	})
+}
+
+func maxMean(loads map[string]float64) float64 {
+	var max, total float64
+	for _, load := range loads {
+		total += load
+		if load > max {
+			max = load
+		}
+	}
+	return max / (total / float64(len(loads)))
+}
+
+func TestBoundedLoadDistribution(t *testing.T) {
+	const factor = 1.25
+	hash := NewBounded(100, nil)
+	hash.Add("shard1", "shard2", "shard3", "shard4", "shard5")
+
+	// half of the requests are for one hot key
+	plain := make(map[string]float64)
+	for i := 0; i < 10000; i++ {
+		key := "hot"
+		if i%2 == 1 {
+			key = "key" + strconv.Itoa(i)
+		}
+		plain[hash.Get(key)]++
+		hash.AddLoad(hash.GetWithLoad(key, factor), 1)
+	}
+	if ratio := maxMean(plain); ratio < 2 {
+		t.Fatalf("max/mean without bounds = %.2f, want the hot key to skew it", ratio)
+	}
+	if ratio := maxMean(hash.Loads()); ratio > factor {
+		t.Fatalf("max/mean = %.2f, want at most %v", ratio, factor)
+	}
+}
+
+func TestBoundedLoads(t *testing.T) {
+	hash := NewBounded(10, nil)
+	hash.Add("a", "b")
+	owner := hash.Get("key")
+	if got := hash.GetWithLoad("key", 1.25); got != owner {
+		t.Fatalf("GetWithLoad without load = %s, want the owner %s", got, owner)
+	}
+	// a factor below 1 can not be met by anyone, so the owner serves
+	if got := hash.GetWithLoad("key", 0.5); got != owner {
+		t.Fatalf("GetWithLoad(0.5) = %s, want the owner", got)
+	}
+	hash.AddLoad(owner, 10)
+	if got := hash.GetWithLoad("key", 1.25); got == owner {
+		t.Fatal("GetWithLoad kept the key on the overloaded owner")
+	}
+
+	hash.Decay(0.5)
+	if hash.Loads()[owner] != 5 {
+		t.Fatalf("loads after Decay = %v", hash.Loads())
+	}
+	hash.ResetLoads()
+	if loads := hash.Loads(); loads["a"] != 0 || loads["b"] != 0 || len(loads) != 2 {
+		t.Fatalf("loads after ResetLoads = %v", loads)
+	}
+}
@@ -69,6 +69,27 @@ func Slot(key string) int {
 	return int(crc16sum(key)) % SlotNumber
 }
 
+// SameSlot reports whether keys hash to the same slot. Unlike Slot, it takes
+// an empty key for slot 0 rather than a random slot.
+func SameSlot(keys ...string) bool {
+	return CrossSlotIndex(keys...) < 0
+}
+
+// CrossSlotIndex returns the index of the first key that does not hash to
+// the slot of keys[0], or -1 if they all do.
+func CrossSlotIndex(keys ...string) int {
+	if len(keys) == 0 {
+		return -1
+	}
+	slot := crc16sum(Key(keys[0])) % SlotNumber
+	for i, key := range keys[1:] {
+		if crc16sum(Key(key))%SlotNumber != slot {
+			return i + 1
+		}
+	}
+	return -1
+}
+
 func crc16sum(key string) (crc uint16) {
 	for i := 0; i < len(key); i++ {
 		crc = (crc << 8) ^ crc16tab[(byte(crc>>8)^key[i])&0x00ff]
@@ -0,0 +1,54 @@
+package hashtag
+
+import (
+	"testing"
+)
+
+func TestKey(t *testing.T) {
+	for _, test := range []struct {
+		key, tag string
+	}{
+		{"foo", "foo"},
+		{"{user1000}.following", "user1000"},
+		{"foo{bar}{zap}", "bar"},
+		{"a{b}c{d}", "b"},
+		// an empty tag hashes the whole key
+		{"{}", "{}"},
+		{"foo{}{bar}", "foo{}{bar}"},
+		{"foo{{bar}}zap", "{bar"},
+		{"foo{bar", "foo{bar"},
+		{"", ""},
+	} {
+		if tag := Key(test.key); tag != test.tag {
+			t.Errorf("Key(%q) = %q, want %q", test.key, tag, test.tag)
+		}
+	}
+}
+
+func TestSlot(t *testing.T) {
+	// CLUSTER KEYSLOT of a redis server
+	if slot := Slot("foo"); slot != 12182 {
+		t.Fatalf("Slot(foo) = %d", slot)
+	}
+	if Slot("{foo}.bar") != Slot("foo") || Slot("a{b}c{d}") != Slot("b") {
+		t.Fatal("hash tag ignored")
+	}
+}
+
+func TestSameSlot(t *testing.T) {
+	if !SameSlot() || !SameSlot("foo") || !SameSlot("{user}.a", "{user}.b", "x{user}y") {
+		t.Fatal("keys of one tag in several slots")
+	}
+	if !SameSlot("", "") {
+		t.Fatal("empty keys in several slots")
+	}
+	if SameSlot("{}", "{}.a") {
+		t.Fatal("empty tags in one slot, want the whole keys hashed")
+	}
+	if i := CrossSlotIndex("{a}1", "{a}2", "{b}3", "{c}4"); i != 2 {
+		t.Fatalf("CrossSlotIndex = %d, want 2", i)
+	}
+	if i := CrossSlotIndex("a{b}c{d}", "b", "{b}{d}"); i != -1 {
+		t.Fatalf("CrossSlotIndex = %d, want -1", i)
+	}
+}
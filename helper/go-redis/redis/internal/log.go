@@ -3,6 +3,8 @@ package internal
 import (
 	"fmt"
 	"log"
+	"sync"
+	"time"
 )
 
 var Logger *log.Logger
@@ -13,3 +15,81 @@ func Logf(s string, args ...interface{}) {
 	}
 	Logger.Output(2, fmt.Sprintf(s, args...))
 }
+
+// Debug enables Debugf output.
+var Debug = false
+
+func Debugf(s string, args ...interface{}) {
+	if !Debug || Logger == nil {
+		return
+	}
+	Logger.Output(2, fmt.Sprintf(s, args...))
+}
+
+// LogRateLimit enables the suppression done by Limitf. Disable it to see
+// every message, e.g. while debugging.
+var LogRateLimit = true
+
+const (
+	logRate  = 1 // lines per second and format string
+	logBurst = 1
+)
+
+type logBucket struct {
+	tokens     float64
+	last       time.Time
+	suppressed int
+}
+
+var (
+	logBucketsMu sync.Mutex
+	logBuckets   = make(map[string]*logBucket)
+)
+
+// Limitf is Logf for call sites that may fire on every command. Messages
+// sharing the format string s are limited to logRate lines per second;
+// the next line that passes tells how many were suppressed.
+func Limitf(s string, args ...interface{}) {
+	if Logger == nil {
+		return
+	}
+	if !LogRateLimit {
+		Logger.Output(2, fmt.Sprintf(s, args...))
+		return
+	}
+	suppressed, ok := allowLog(s, time.Now())
+	if !ok {
+		return
+	}
+	msg := fmt.Sprintf(s, args...)
+	if suppressed > 0 {
+		msg += fmt.Sprintf(" (suppressed %d similar messages)", suppressed)
+	}
+	Logger.Output(2, msg)
+}
+
+// allowLog takes a token from the bucket of s. When it succeeds it returns
+// the number of messages suppressed since the last one allowed.
+func allowLog(s string, now time.Time) (int, bool) {
+	logBucketsMu.Lock()
+	defer logBucketsMu.Unlock()
+
+	b, ok := logBuckets[s]
+	if !ok {
+		b = &logBucket{tokens: logBurst, last: now}
+		logBuckets[s] = b
+	}
+	b.tokens += now.Sub(b.last).Seconds() * logRate
+	if b.tokens > logBurst {
+		b.tokens = logBurst
+	}
+	b.last = now
+	if b.tokens < 1 {
+		b.suppressed++
+		return 0, false
+	}
+	b.tokens--
+	suppressed := b.suppressed
+	b.suppressed = 0
+	return suppressed, true
+}
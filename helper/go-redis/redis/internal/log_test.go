@@ -0,0 +1,69 @@
+package internal
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testLogger(t *testing.T) *bytes.Buffer {
+	var buf bytes.Buffer
+	saved := Logger
+	t.Cleanup(func() { Logger = saved })
+	Logger = log.New(&buf, "", 0)
+	return &buf
+}
+
+func TestLimitf(t *testing.T) {
+	buf := testLogger(t)
+	for i := 0; i < 10000; i++ {
+		Limitf("limitf test: info for cmd=%s not found", "foo")
+		Limitf("limitf test: other %d", i)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) > 4 {
+		t.Fatalf("%d lines logged, want a few: %q", len(lines), lines[:5])
+	}
+	if lines[0] != "limitf test: info for cmd=foo not found" || lines[1] != "limitf test: other 0" {
+		t.Fatalf("lines = %q, want the first of each message", lines)
+	}
+
+	// a second later the next message tells how many were suppressed
+	suppressed, ok := allowLog("limitf test: info for cmd=%s not found", time.Now().Add(time.Second))
+	if !ok || suppressed < 9990 {
+		t.Fatalf("allowLog = %d, %v, want the suppressed messages counted", suppressed, ok)
+	}
+	if suppressed, ok := allowLog("limitf test: info for cmd=%s not found", time.Now().Add(3*time.Second)); !ok || suppressed != 0 {
+		t.Fatalf("allowLog = %d, %v, want the count reset", suppressed, ok)
+	}
+}
+
+func TestLimitfSummary(t *testing.T) {
+	buf := testLogger(t)
+	const format = "limitf summary test %d"
+	Limitf(format, 1)
+	Limitf(format, 2)
+	Limitf(format, 3)
+	logBucketsMu.Lock()
+	logBuckets[format].last = time.Now().Add(-time.Second)
+	logBucketsMu.Unlock()
+	Limitf(format, 4)
+
+	if got := buf.String(); got != "limitf summary test 1\nlimitf summary test 4 (suppressed 2 similar messages)\n" {
+		t.Fatalf("log = %q", got)
+	}
+}
+
+func TestLimitfDisabled(t *testing.T) {
+	buf := testLogger(t)
+	LogRateLimit = false
+	defer func() { LogRateLimit = true }()
+	for i := 0; i < 10; i++ {
+		Limitf("limitf disabled test")
+	}
+	if n := strings.Count(buf.String(), "\n"); n != 10 {
+		t.Fatalf("%d lines logged, want every message", n)
+	}
+}
@@ -16,16 +16,24 @@ type Conn struct {
 	Rd *proto.Reader
 	Wb *proto.WriteBuffer
 
-	Inited bool
-	usedAt atomic.Value
+	Inited    bool
+	usedAt    atomic.Value
+	createdAt time.Time
 }
 
 func NewConn(netConn net.Conn) *Conn {
+	return NewConnSize(netConn, 0)
+}
+
+// NewConnSize returns a Conn reading netConn with a buffer of readerSize
+// bytes, 0 for the default.
+func NewConnSize(netConn net.Conn, readerSize int) *Conn {
 	cn := &Conn{
-		netConn: netConn,
-		Wb:      proto.NewWriteBuffer(),
+		netConn:   netConn,
+		Wb:        proto.NewWriteBuffer(),
+		createdAt: time.Now(),
 	}
-	cn.Rd = proto.NewReader(cn.netConn)
+	cn.Rd = proto.NewReaderSize(cn.netConn, readerSize)
 	cn.SetUsedAt(time.Now())
 	return cn
 }
@@ -34,6 +42,10 @@ func (cn *Conn) UsedAt() time.Time {
 	return cn.usedAt.Load().(time.Time)
 }
 
+func (cn *Conn) CreatedAt() time.Time {
+	return cn.createdAt
+}
+
 func (cn *Conn) SetUsedAt(tm time.Time) {
 	cn.usedAt.Store(tm)
 }
@@ -43,10 +55,41 @@ func (cn *Conn) SetNetConn(netConn net.Conn) {
 	cn.Rd.Reset(netConn)
 }
 
+// ShrinkBuffers releases the read and write buffers grown past max bytes
+// by a large reply or command.
+func (cn *Conn) ShrinkBuffers(max int) {
+	cn.Rd.Shrink(max)
+	cn.Wb.Shrink(max)
+}
+
 func (cn *Conn) IsStale(timeout time.Duration) bool {
 	return timeout > 0 && time.Since(cn.UsedAt()) > timeout
 }
 
+// IsExpired reports whether the connection is older than maxAge.
+func (cn *Conn) IsExpired(maxAge time.Duration) bool {
+	return maxAge > 0 && time.Since(cn.createdAt) > maxAge
+}
+
+// aliveCheckTimeout bounds the read done by IsAlive.
+const aliveCheckTimeout = time.Millisecond
+
+// IsAlive probes an idle connection with a short read. A healthy idle
+// connection has nothing to read and the read times out; a connection
+// closed by the peer returns EOF or another error.
+func (cn *Conn) IsAlive() bool {
+	if err := cn.netConn.SetReadDeadline(time.Now().Add(aliveCheckTimeout)); err != nil {
+		return false
+	}
+	var b [1]byte
+	_, err := cn.netConn.Read(b[:])
+	if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+		return true
+	}
+	// Unexpected data or EOF, the connection can not be reused.
+	return false
+}
+
 func (cn *Conn) SetReadTimeout(timeout time.Duration) error {
 	now := time.Now()
 	cn.SetUsedAt(now)
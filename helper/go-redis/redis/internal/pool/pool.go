@@ -1,6 +1,8 @@
 package pool
 
 import (
+	"container/list"
+	"context"
 	"errors"
 	"net"
 	"sync"
@@ -27,16 +29,21 @@ type Stats struct {
 	Misses   uint32 // number of times free connection was NOT found in the pool
 	Timeouts uint32 // number of times a wait timeout occurred
 
+	WaitCount    uint32        // number of times Get waited for a connection
+	WaitDuration time.Duration // total time Get waited for a connection
+
 	TotalConns uint32 // number of total connections in the pool
 	FreeConns  uint32 // number of free connections in the pool
 	StaleConns uint32 // number of stale connections removed from the pool
+
+	ConnAgeEvictions uint32 // number of connections closed for exceeding MaxConnAge
 }
 
 type Pooler interface {
 	NewConn() (*Conn, error)
 	CloseConn(*Conn) error
 
-	Get() (*Conn, bool, error)
+	Get(ctx context.Context) (*Conn, bool, error)
 	Put(*Conn) error
 	Remove(*Conn) error
 
@@ -51,12 +58,19 @@ type Options struct {
 	Dialer  func() (net.Conn, error)
 	OnClose func(*Conn) error
 
-	PoolSize           int
-	PoolTimeout        time.Duration
-	IdleTimeout        time.Duration
-	IdleCheckFrequency time.Duration
+	PoolSize               int
+	PoolTimeout            time.Duration
+	IdleTimeout            time.Duration
+	IdleCheckFrequency     time.Duration
+	MaxIdleTimeBeforeCheck time.Duration
+	MaxConnAge             time.Duration
+	ReaderBufferSize       int
 }
 
+// retainedBufferSize is the size up to which connection buffers grown by
+// large replies are kept when a connection is put back, see Put.
+const retainedBufferSize = 64 * 1024
+
 type ConnPool struct {
 	opt *Options
 
@@ -65,7 +79,11 @@ type ConnPool struct {
 	lastDialError   error
 	lastDialErrorMu sync.RWMutex
 
-	queue chan struct{}
+	// At most PoolSize turns are held, each by a Get that returned or by
+	// ReapStaleConns. Waiters for a turn are served FIFO.
+	turnsMu sync.Mutex
+	turns   int
+	waiters list.List // of *turnWaiter
 
 	connsMu sync.Mutex
 	conns   []*Conn
@@ -73,7 +91,10 @@ type ConnPool struct {
 	freeConnsMu sync.Mutex
 	freeConns   []*Conn
 
-	stats Stats
+	stats        Stats
+	waitDuration int64 // atomic, nanoseconds
+
+	recycledAt int64 // atomic, unix nano
 
 	_closed uint32 // atomic
 }
@@ -84,7 +105,6 @@ func NewConnPool(opt *Options) *ConnPool {
 	p := &ConnPool{
 		opt: opt,
 
-		queue:     make(chan struct{}, opt.PoolSize),
 		conns:     make([]*Conn, 0, opt.PoolSize),
 		freeConns: make([]*Conn, 0, opt.PoolSize),
 	}
@@ -105,14 +125,11 @@ func (p *ConnPool) NewConn() (*Conn, error) {
 
 	netConn, err := p.opt.Dialer()
 	if err != nil {
-		p.setLastDialError(err)
-		if atomic.AddUint32(&p.dialErrorsNum, 1) == uint32(p.opt.PoolSize) {
-			go p.tryDial()
-		}
+		p.DialFailed(err)
 		return nil, err
 	}
 
-	cn := NewConn(netConn)
+	cn := NewConnSize(netConn, p.opt.ReaderBufferSize)
 	p.connsMu.Lock()
 	p.conns = append(p.conns, cn)
 	p.connsMu.Unlock()
@@ -120,6 +137,15 @@ func (p *ConnPool) NewConn() (*Conn, error) {
 	return cn, nil
 }
 
+// DialFailed records a failed dial. Once PoolSize dials in a row failed,
+// NewConn returns the last error until a background dial succeeds.
+func (p *ConnPool) DialFailed(err error) {
+	p.setLastDialError(err)
+	if atomic.AddUint32(&p.dialErrorsNum, 1) == uint32(p.opt.PoolSize) {
+		go p.tryDial()
+	}
+}
+
 func (p *ConnPool) tryDial() {
 	for {
 		if p.closed() {
@@ -152,29 +178,88 @@ func (p *ConnPool) getLastDialError() error {
 	return err
 }
 
-// Get returns existed connection from the pool or creates a new one.
-func (p *ConnPool) Get() (*Conn, bool, error) {
-	if p.closed() {
-		return nil, false, ErrClosed
+type turnWaiter struct {
+	ready      chan struct{}
+	enqueuedAt time.Time
+}
+
+// waitTurn takes one of the PoolSize turns, waiting behind earlier waiters
+// for at most PoolTimeout or until ctx is done. It reports whether it had
+// to wait and for how long.
+func (p *ConnPool) waitTurn(ctx context.Context) (waited bool, d time.Duration, err error) {
+	p.turnsMu.Lock()
+	if p.turns < p.opt.PoolSize && p.waiters.Len() == 0 {
+		p.turns++
+		p.turnsMu.Unlock()
+		return false, 0, nil
+	}
+	w := &turnWaiter{
+		ready:      make(chan struct{}),
+		enqueuedAt: time.Now(),
 	}
+	el := p.waiters.PushBack(w)
+	p.turnsMu.Unlock()
+
+	timer := timers.Get().(*time.Timer)
+	timer.Reset(p.opt.PoolTimeout)
 
 	select {
-	case p.queue <- struct{}{}:
-	default:
-		timer := timers.Get().(*time.Timer)
-		timer.Reset(p.opt.PoolTimeout)
+	case <-w.ready:
+		if !timer.Stop() {
+			<-timer.C
+		}
+	case <-timer.C:
+		err = ErrPoolTimeout
+	case <-ctx.Done():
+		if !timer.Stop() {
+			<-timer.C
+		}
+		err = ctx.Err()
+	}
+	timers.Put(timer)
 
+	if err != nil {
+		p.turnsMu.Lock()
 		select {
-		case p.queue <- struct{}{}:
-			if !timer.Stop() {
-				<-timer.C
-			}
-			timers.Put(timer)
-		case <-timer.C:
-			timers.Put(timer)
-			atomic.AddUint32(&p.stats.Timeouts, 1)
-			return nil, false, ErrPoolTimeout
+		case <-w.ready:
+			// The turn was handed over while giving up, keep it.
+			err = nil
+		default:
+			p.waiters.Remove(el)
 		}
+		p.turnsMu.Unlock()
+	}
+	return true, time.Since(w.enqueuedAt), err
+}
+
+// freeTurn hands the turn to the oldest waiter, if any.
+func (p *ConnPool) freeTurn() {
+	p.turnsMu.Lock()
+	if el := p.waiters.Front(); el != nil {
+		p.waiters.Remove(el)
+		close(el.Value.(*turnWaiter).ready)
+	} else {
+		p.turns--
+	}
+	p.turnsMu.Unlock()
+}
+
+// Get returns existed connection from the pool or creates a new one.
+// Once PoolSize connections are in use it waits for one to be put back,
+// for at most PoolTimeout or until ctx is done.
+func (p *ConnPool) Get(ctx context.Context) (*Conn, bool, error) {
+	if p.closed() {
+		return nil, false, ErrClosed
+	}
+
+	waited, d, err := p.waitTurn(ctx)
+	if waited {
+		atomic.AddUint32(&p.stats.WaitCount, 1)
+		atomic.AddInt64(&p.waitDuration, int64(d))
+	}
+	if err != nil {
+		atomic.AddUint32(&p.stats.Timeouts, 1)
+		return nil, false, err
 	}
 
 	for {
@@ -191,6 +276,17 @@ func (p *ConnPool) Get() (*Conn, bool, error) {
 			continue
 		}
 
+		if cn.IsExpired(p.opt.MaxConnAge) {
+			atomic.AddUint32(&p.stats.ConnAgeEvictions, 1)
+			p.CloseConn(cn)
+			continue
+		}
+
+		if cn.IsStale(p.opt.MaxIdleTimeBeforeCheck) && !cn.IsAlive() {
+			p.CloseConn(cn)
+			continue
+		}
+
 		atomic.AddUint32(&p.stats.Hits, 1)
 		return cn, false, nil
 	}
@@ -199,7 +295,7 @@ func (p *ConnPool) Get() (*Conn, bool, error) {
 
 	newcn, err := p.NewConn()
 	if err != nil {
-		<-p.queue
+		p.freeTurn()
 		return nil, false, err
 	}
 
@@ -219,19 +315,28 @@ func (p *ConnPool) popFree() *Conn {
 
 func (p *ConnPool) Put(cn *Conn) error {
 	if data := cn.Rd.PeekBuffered(); data != nil {
-		internal.Logf("connection has unread data: %q", data)
+		internal.Limitf("connection has unread data: %q", data)
 		return p.Remove(cn)
 	}
+	if cn.CreatedAt().UnixNano() <= atomic.LoadInt64(&p.recycledAt) {
+		return p.Remove(cn)
+	}
+	// Idle connections must not pin the memory of a multi-megabyte reply.
+	max := retainedBufferSize
+	if p.opt.ReaderBufferSize > max {
+		max = p.opt.ReaderBufferSize
+	}
+	cn.ShrinkBuffers(max)
 	p.freeConnsMu.Lock()
 	p.freeConns = append(p.freeConns, cn)
 	p.freeConnsMu.Unlock()
-	<-p.queue
+	p.freeTurn()
 	return nil
 }
 
 func (p *ConnPool) Remove(cn *Conn) error {
 	_ = p.CloseConn(cn)
-	<-p.queue
+	p.freeTurn()
 	return nil
 }
 
@@ -277,9 +382,14 @@ func (p *ConnPool) Stats() *Stats {
 		Misses:   atomic.LoadUint32(&p.stats.Misses),
 		Timeouts: atomic.LoadUint32(&p.stats.Timeouts),
 
+		WaitCount:    atomic.LoadUint32(&p.stats.WaitCount),
+		WaitDuration: time.Duration(atomic.LoadInt64(&p.waitDuration)),
+
 		TotalConns: uint32(p.Len()),
 		FreeConns:  uint32(p.FreeLen()),
 		StaleConns: atomic.LoadUint32(&p.stats.StaleConns),
+
+		ConnAgeEvictions: atomic.LoadUint32(&p.stats.ConnAgeEvictions),
 	}
 }
 
@@ -301,6 +411,23 @@ func (p *ConnPool) Filter(fn func(*Conn) bool) error {
 	return firstErr
 }
 
+// Recycle closes all free connections. Connections that are in use
+// are closed when they are put back, so new dials replace every
+// connection that existed before the call.
+func (p *ConnPool) Recycle() int {
+	atomic.StoreInt64(&p.recycledAt, time.Now().UnixNano())
+
+	p.freeConnsMu.Lock()
+	freeConns := p.freeConns
+	p.freeConns = make([]*Conn, 0, p.opt.PoolSize)
+	p.freeConnsMu.Unlock()
+
+	for _, cn := range freeConns {
+		_ = p.CloseConn(cn)
+	}
+	return len(freeConns)
+}
+
 func (p *ConnPool) Close() error {
 	if !atomic.CompareAndSwapUint32(&p._closed, 0, 1) {
 		return ErrClosed
@@ -342,13 +469,16 @@ func (p *ConnPool) reapStaleConn() bool {
 func (p *ConnPool) ReapStaleConns() (int, error) {
 	var n int
 	for {
-		p.queue <- struct{}{}
+		if _, _, err := p.waitTurn(context.Background()); err != nil {
+			// Every connection stayed in use, none of them is idle.
+			break
+		}
 		p.freeConnsMu.Lock()
 
 		reaped := p.reapStaleConn()
 
 		p.freeConnsMu.Unlock()
-		<-p.queue
+		p.freeTurn()
 
 		if reaped {
 			n++
@@ -0,0 +1,100 @@
+package pool
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func newTestPool(size int) *ConnPool {
+	return NewConnPool(&Options{
+		Dialer: func() (net.Conn, error) {
+			cn, _ := net.Pipe()
+			return cn, nil
+		},
+		PoolSize:    size,
+		PoolTimeout: time.Second,
+		IdleTimeout: time.Minute,
+	})
+}
+
+func (p *ConnPool) waitersLen() int {
+	p.turnsMu.Lock()
+	defer p.turnsMu.Unlock()
+	return p.waiters.Len()
+}
+
+func TestConnPoolWaitersFIFO(t *testing.T) {
+	p := newTestPool(1)
+	defer p.Close()
+
+	cn, _, err := p.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	const waiters = 5
+	order := make(chan int, waiters)
+	for i := 0; i < waiters; i++ {
+		go func(i int) {
+			cn, _, err := p.Get(context.Background())
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			order <- i
+			time.Sleep(time.Millisecond)
+			p.Put(cn)
+		}(i)
+		// enqueue the waiters one after the other
+		for p.waitersLen() != i+1 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	time.Sleep(10 * time.Millisecond)
+	p.Put(cn)
+
+	for i := 0; i < waiters; i++ {
+		if got := <-order; got != i {
+			t.Fatalf("waiter %d served in turn %d, want arrival order", got, i)
+		}
+	}
+	stats := p.Stats()
+	if stats.WaitCount != waiters || stats.WaitDuration < 10*time.Millisecond*waiters {
+		t.Fatalf("wait count %d duration %v", stats.WaitCount, stats.WaitDuration)
+	}
+	if stats.TotalConns != 1 || stats.Timeouts != 0 {
+		t.Fatalf("stats = %+v", stats)
+	}
+}
+
+func TestConnPoolWaitContext(t *testing.T) {
+	p := newTestPool(1)
+	defer p.Close()
+
+	cn, _, err := p.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, _, err := p.Get(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("Get err = %v, want the context deadline", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("Get waited %v, want the context deadline before PoolTimeout", elapsed)
+	}
+	if p.waitersLen() != 0 {
+		t.Fatal("waiter left in the queue")
+	}
+	if stats := p.Stats(); stats.Timeouts != 1 || stats.WaitCount != 1 {
+		t.Fatalf("stats = %+v", stats)
+	}
+
+	// the turn is still handed to the next waiter
+	p.Put(cn)
+	if _, _, err := p.Get(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
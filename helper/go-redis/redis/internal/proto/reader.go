@@ -11,6 +11,8 @@ import (
 
 const bytesAllocLimit = 1024 * 1024 // 1mb
 
+const defaultBufSize = 4096
+
 const (
 	ErrorReply  = '-'
 	StatusReply = '+'
@@ -27,19 +29,42 @@ type RedisError string
 
 func (e RedisError) Error() string { return string(e) }
 
+// StreamError is returned by ReadBulkInto when reading or writing failed
+// after part of the reply was written. Such a command can not be retried,
+// the writer already holds a part of the value.
+type StreamError struct {
+	Written int64
+	Err     error
+}
+
+func (e *StreamError) Error() string {
+	return fmt.Sprintf("redis: reply streamed partially (%d bytes): %s", e.Written, e.Err)
+}
+
 //------------------------------------------------------------------------------
 
 type MultiBulkParse func(*Reader, int64) (interface{}, error)
 
 type Reader struct {
-	src *bufio.Reader
-	buf []byte
+	src  *bufio.Reader
+	buf  []byte
+	size int
 }
 
 func NewReader(rd io.Reader) *Reader {
+	return NewReaderSize(rd, defaultBufSize)
+}
+
+// NewReaderSize returns a Reader buffering size bytes of rd. Bulk replies
+// larger than size grow the reply buffer, see Shrink.
+func NewReaderSize(rd io.Reader, size int) *Reader {
+	if size <= 0 {
+		size = defaultBufSize
+	}
 	return &Reader{
-		src: bufio.NewReader(rd),
-		buf: make([]byte, 4096),
+		src:  bufio.NewReaderSize(rd, size),
+		buf:  make([]byte, size),
+		size: size,
 	}
 }
 
@@ -47,6 +72,13 @@ func (r *Reader) Reset(rd io.Reader) {
 	r.src.Reset(rd)
 }
 
+// Shrink releases the reply buffer if a large reply grew it past max bytes.
+func (r *Reader) Shrink(max int) {
+	if cap(r.buf) > max {
+		r.buf = make([]byte, r.size)
+	}
+}
+
 func (r *Reader) PeekBuffered() []byte {
 	if n := r.src.Buffered(); n != 0 {
 		b, _ := r.src.Peek(n)
@@ -138,6 +170,41 @@ func (r *Reader) ReadTmpBytesReply() ([]byte, error) {
 	}
 }
 
+// ReadBulkInto copies a bulk or status reply to w without buffering it
+// whole and returns the number of bytes written. Once bytes were written
+// errors are returned as a *StreamError.
+func (r *Reader) ReadBulkInto(w io.Writer) (int64, error) {
+	line, err := r.ReadLine()
+	if err != nil {
+		return 0, err
+	}
+	switch line[0] {
+	case ErrorReply:
+		return 0, ParseErrorReply(line)
+	case StatusReply:
+		n, err := w.Write(parseStatusValue(line))
+		if err != nil && n > 0 {
+			err = &StreamError{Written: int64(n), Err: err}
+		}
+		return int64(n), err
+	case StringReply:
+		replyLen, err := strconv.ParseInt(string(line[1:]), 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		n, err := io.CopyN(w, r.src, replyLen)
+		if err == nil {
+			_, err = r.src.Discard(2)
+		}
+		if err != nil && n > 0 {
+			err = &StreamError{Written: n, Err: err}
+		}
+		return n, err
+	default:
+		return 0, fmt.Errorf("redis: can't parse string reply: %.100q", line)
+	}
+}
+
 func (r *Reader) ReadBytesReply() ([]byte, error) {
 	b, err := r.ReadTmpBytesReply()
 	if err != nil {
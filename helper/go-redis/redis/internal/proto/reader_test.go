@@ -0,0 +1,83 @@
+package proto
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"io/ioutil"
+	"runtime"
+	"strconv"
+	"testing"
+)
+
+func bulkReply(value []byte) []byte {
+	reply := []byte("$" + strconv.Itoa(len(value)) + "\r\n")
+	reply = append(reply, value...)
+	return append(reply, "\r\n"...)
+}
+
+func TestReadBulkIntoLargeReply(t *testing.T) {
+	value := bytes.Repeat([]byte("0123456789abcdef"), 1<<20) // 16MB
+	src := append(bulkReply(value), "+OK\r\n"...)
+	rd := NewReader(bytes.NewReader(src))
+	hash := sha1.New()
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	n, err := rd.ReadBulkInto(hash)
+	runtime.ReadMemStats(&after)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(len(value)) || !bytes.Equal(hash.Sum(nil), sha1Sum(value)) {
+		t.Fatalf("streamed %d bytes, want the %d bytes of the value", n, len(value))
+	}
+	if alloc := after.TotalAlloc - before.TotalAlloc; alloc > 1<<20 {
+		t.Fatalf("allocated %d bytes streaming the reply, want it not buffered", alloc)
+	}
+	if cap(rd.buf) > defaultBufSize {
+		t.Fatalf("reply buffer grew to %d bytes", cap(rd.buf))
+	}
+	// the CRLF of the bulk reply is consumed
+	if line, err := rd.ReadLine(); string(line) != "+OK" || err != nil {
+		t.Fatalf("next reply = %q, %v", line, err)
+	}
+}
+
+func sha1Sum(b []byte) []byte {
+	sum := sha1.Sum(b)
+	return sum[:]
+}
+
+func TestReadBulkIntoTruncated(t *testing.T) {
+	src := bulkReply(make([]byte, 100000))
+	rd := NewReader(bytes.NewReader(src[:50000]))
+	n, err := rd.ReadBulkInto(ioutil.Discard)
+	streamErr, ok := err.(*StreamError)
+	if !ok || streamErr.Written != n || n == 0 {
+		t.Fatalf("ReadBulkInto = %d, %v, want a StreamError after a partial write", n, err)
+	}
+
+	rd = NewReader(bytes.NewReader([]byte("-ERR wrong type\r\n")))
+	if n, err := rd.ReadBulkInto(ioutil.Discard); n != 0 || err == nil || err.Error() != "ERR wrong type" {
+		t.Fatalf("ReadBulkInto of an error = %d, %v", n, err)
+	}
+	rd = NewReader(bytes.NewReader([]byte("$-1\r\n")))
+	if _, err := rd.ReadBulkInto(ioutil.Discard); err != Nil {
+		t.Fatalf("ReadBulkInto of nil err = %v", err)
+	}
+}
+
+func TestReaderShrink(t *testing.T) {
+	rd := NewReaderSize(bytes.NewReader(bulkReply(make([]byte, 1<<20))), 8192)
+	if _, err := rd.ReadTmpBytesReply(); err != nil {
+		t.Fatal(err)
+	}
+	if cap(rd.buf) < 1<<20 {
+		t.Fatalf("reply buffer = %d bytes, want it grown", cap(rd.buf))
+	}
+	rd.Shrink(64 * 1024)
+	if cap(rd.buf) != 8192 {
+		t.Fatalf("reply buffer = %d bytes after Shrink, want 8192", cap(rd.buf))
+	}
+}
@@ -20,6 +20,13 @@ func (w *WriteBuffer) Len() int      { return len(w.b) }
 func (w *WriteBuffer) Bytes() []byte { return w.b }
 func (w *WriteBuffer) Reset()        { w.b = w.b[:0] }
 
+// Shrink releases the buffer if a large command grew it past max bytes.
+func (w *WriteBuffer) Shrink(max int) {
+	if cap(w.b) > max {
+		w.b = make([]byte, 0, 4096)
+	}
+}
+
 func (w *WriteBuffer) Append(args []interface{}) error {
 	w.b = append(w.b, ArrayReply)
 	w.b = strconv.AppendUint(w.b, uint64(len(args)), 10)
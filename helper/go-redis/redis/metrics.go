@@ -0,0 +1,198 @@
+package redis
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// MetricsRecorder observes the latency of commands, see Options.MetricsRecorder.
+// It is called for every attempt, so a retried command is observed once per try,
+// and for every command of a pipeline with the duration of its batch.
+type MetricsRecorder interface {
+	ObserveCommand(name string, dur time.Duration, err error)
+}
+
+// CommandMetricsRecorder is a MetricsRecorder that wants the details of an
+// observation. ObserveCommandAttempt is called instead of ObserveCommand.
+type CommandMetricsRecorder interface {
+	MetricsRecorder
+	ObserveCommandAttempt(obs *CommandObservation)
+}
+
+// CommandObservation is one attempt of one command.
+type CommandObservation struct {
+	Name     string
+	Shard    string // Ring shard name, empty for other clients
+	Attempt  int    // 0 for the first try
+	Pipeline bool
+	Duration time.Duration
+	Err      error
+}
+
+// observe reports cmds, sent in one attempt that began at start, to
+// Options.MetricsRecorder.
+func (c *baseClient) observe(start time.Time, attempt int, pipeline bool, cmds ...Cmder) {
+	recorder := c.opt.MetricsRecorder
+	if recorder == nil {
+		return
+	}
+	dur := time.Since(start)
+	attemptRecorder, ok := recorder.(CommandMetricsRecorder)
+	for _, cmd := range cmds {
+		if !ok {
+			recorder.ObserveCommand(cmd.Name(), dur, cmd.Err())
+			continue
+		}
+		attemptRecorder.ObserveCommandAttempt(&CommandObservation{
+			Name:     cmd.Name(),
+			Shard:    c.opt.shardName,
+			Attempt:  attempt,
+			Pipeline: pipeline,
+			Duration: dur,
+			Err:      cmd.Err(),
+		})
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// Upper bounds of the histogram buckets, 64µs doubling up to about 34s.
+// Slower commands land in the last bucket.
+var latencyBuckets = func() []time.Duration {
+	buckets := make([]time.Duration, 20)
+	for i := range buckets {
+		buckets[i] = 64 * time.Microsecond << uint(i)
+	}
+	return buckets
+}()
+
+type latencyHistogram struct {
+	counts  []uint64
+	count   uint64
+	errors  uint64
+	retries uint64
+	max     time.Duration
+}
+
+func (h *latencyHistogram) observe(obs *CommandObservation) {
+	i := sort.Search(len(latencyBuckets)-1, func(i int) bool {
+		return obs.Duration <= latencyBuckets[i]
+	})
+	h.counts[i]++
+	h.count++
+	if obs.Err != nil && obs.Err != Nil {
+		h.errors++
+	}
+	if obs.Attempt > 0 {
+		h.retries++
+	}
+	if obs.Duration > h.max {
+		h.max = obs.Duration
+	}
+}
+
+// quantile returns the upper bound of the bucket holding the q quantile.
+func (h *latencyHistogram) quantile(q float64) time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+	rank := uint64(q*float64(h.count) + 0.5)
+	if rank == 0 {
+		rank = 1
+	}
+	var seen uint64
+	for i, n := range h.counts {
+		seen += n
+		if seen >= rank {
+			if latencyBuckets[i] > h.max {
+				return h.max
+			}
+			return latencyBuckets[i]
+		}
+	}
+	return h.max
+}
+
+type latencyKey struct {
+	shard, name string
+}
+
+// LatencySnapshot is the latency of one command on one shard. Percentiles are
+// the upper bounds of histogram buckets, so they are exact to a factor of 2.
+type LatencySnapshot struct {
+	Shard   string
+	Name    string
+	Count   uint64 // attempts, including retries
+	Errors  uint64 // failed attempts, redis.Nil is not an error
+	Retries uint64 // attempts after the first
+	P50     time.Duration
+	P90     time.Duration
+	P99     time.Duration
+	Max     time.Duration
+}
+
+// HistogramRecorder is an in-memory MetricsRecorder keeping a latency
+// histogram per shard and command.
+type HistogramRecorder struct {
+	mu         sync.Mutex
+	histograms map[latencyKey]*latencyHistogram
+}
+
+var _ CommandMetricsRecorder = (*HistogramRecorder)(nil)
+
+func NewHistogramRecorder() *HistogramRecorder {
+	return &HistogramRecorder{
+		histograms: make(map[latencyKey]*latencyHistogram),
+	}
+}
+
+func (r *HistogramRecorder) ObserveCommand(name string, dur time.Duration, err error) {
+	r.ObserveCommandAttempt(&CommandObservation{Name: name, Duration: dur, Err: err})
+}
+
+func (r *HistogramRecorder) ObserveCommandAttempt(obs *CommandObservation) {
+	key := latencyKey{shard: obs.Shard, name: obs.Name}
+	r.mu.Lock()
+	h, ok := r.histograms[key]
+	if !ok {
+		h = &latencyHistogram{counts: make([]uint64, len(latencyBuckets))}
+		r.histograms[key] = h
+	}
+	h.observe(obs)
+	r.mu.Unlock()
+}
+
+// Snapshot returns the latencies sorted by shard and command name.
+func (r *HistogramRecorder) Snapshot() []LatencySnapshot {
+	r.mu.Lock()
+	snapshots := make([]LatencySnapshot, 0, len(r.histograms))
+	for key, h := range r.histograms {
+		snapshots = append(snapshots, LatencySnapshot{
+			Shard:   key.shard,
+			Name:    key.name,
+			Count:   h.count,
+			Errors:  h.errors,
+			Retries: h.retries,
+			P50:     h.quantile(0.5),
+			P90:     h.quantile(0.9),
+			P99:     h.quantile(0.99),
+			Max:     h.max,
+		})
+	}
+	r.mu.Unlock()
+	sort.Slice(snapshots, func(i, j int) bool {
+		if snapshots[i].Shard != snapshots[j].Shard {
+			return snapshots[i].Shard < snapshots[j].Shard
+		}
+		return snapshots[i].Name < snapshots[j].Name
+	})
+	return snapshots
+}
+
+// Reset drops every observation.
+func (r *HistogramRecorder) Reset() {
+	r.mu.Lock()
+	r.histograms = make(map[latencyKey]*latencyHistogram)
+	r.mu.Unlock()
+}
@@ -0,0 +1,137 @@
+package redis
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// obsRecorder keeps every observation.
+type obsRecorder struct {
+	mu  sync.Mutex
+	obs []CommandObservation
+}
+
+func (r *obsRecorder) ObserveCommand(name string, dur time.Duration, err error) {
+	r.ObserveCommandAttempt(&CommandObservation{Name: name, Duration: dur, Err: err})
+}
+
+func (r *obsRecorder) ObserveCommandAttempt(obs *CommandObservation) {
+	r.mu.Lock()
+	r.obs = append(r.obs, *obs)
+	r.mu.Unlock()
+}
+
+func (r *obsRecorder) Observations() []CommandObservation {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]CommandObservation(nil), r.obs...)
+}
+
+func TestMetricsRecorderPipeline(t *testing.T) {
+	srv := newFakeServer(t, nil)
+	recorder := &obsRecorder{}
+	client := NewClient(&Options{Addr: srv.Addr, MetricsRecorder: recorder})
+	defer client.Close()
+
+	_, err := client.Pipelined(func(pipe Pipeliner) error {
+		pipe.Set("a", "1", 0)
+		pipe.Set("b", "2", 0)
+		pipe.Ping()
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	obs := recorder.Observations()
+	if len(obs) != 3 || obs[0].Name != "set" || obs[2].Name != "ping" {
+		t.Fatalf("observations = %+v, want one per command", obs)
+	}
+	for _, o := range obs {
+		if !o.Pipeline || o.Duration != obs[0].Duration || o.Attempt != 0 {
+			t.Fatalf("observation = %+v, want the batch duration", o)
+		}
+	}
+}
+
+func TestMetricsRecorderRetries(t *testing.T) {
+	var srv *fakeServer
+	var gets int
+	srv = newFakeServer(t, func(conn int, args []string) string {
+		if strings.EqualFold(args[0], "get") {
+			gets++
+			if gets == 1 {
+				// the first try fails with a broken connection
+				srv.DropConns()
+			}
+			return respBulk("value")
+		}
+		return "+OK\r\n"
+	})
+	recorder := NewHistogramRecorder()
+	client := NewClient(&Options{Addr: srv.Addr, MaxRetries: 2, MetricsRecorder: recorder})
+	defer client.Close()
+
+	if v, err := client.Get("key").Result(); v != "value" || err != nil {
+		t.Fatalf("Get = %q, %v", v, err)
+	}
+	snapshots := recorder.Snapshot()
+	if len(snapshots) != 1 {
+		t.Fatalf("snapshots = %+v", snapshots)
+	}
+	if s := snapshots[0]; s.Name != "get" || s.Count != 2 || s.Errors != 1 || s.Retries != 1 {
+		t.Fatalf("snapshot = %+v, want the failed try and the retry counted", s)
+	}
+}
+
+func TestHistogramRecorderQuantiles(t *testing.T) {
+	recorder := NewHistogramRecorder()
+	for i := 0; i < 98; i++ {
+		recorder.ObserveCommandAttempt(&CommandObservation{Name: "get", Shard: "b", Duration: 50 * time.Microsecond})
+	}
+	recorder.ObserveCommandAttempt(&CommandObservation{Name: "get", Shard: "b", Duration: 3 * time.Millisecond, Err: Nil})
+	recorder.ObserveCommandAttempt(&CommandObservation{Name: "get", Shard: "b", Duration: time.Minute, Attempt: 1})
+	recorder.ObserveCommand("set", time.Millisecond, nil)
+
+	snapshots := recorder.Snapshot()
+	if len(snapshots) != 2 || snapshots[0].Name != "set" || snapshots[1].Shard != "b" {
+		t.Fatalf("snapshots = %+v, want them sorted by shard and name", snapshots)
+	}
+	get := snapshots[1]
+	if get.Count != 100 || get.Errors != 0 || get.Retries != 1 || get.Max != time.Minute {
+		t.Fatalf("snapshot = %+v", get)
+	}
+	if get.P50 != 64*time.Microsecond || get.P90 != 64*time.Microsecond || get.P99 != 4096*time.Microsecond {
+		t.Fatalf("percentiles = %v %v %v", get.P50, get.P90, get.P99)
+	}
+	if set := snapshots[0]; set.P50 != time.Millisecond {
+		t.Fatalf("set P50 = %v, want the max when it is below the bucket bound", set.P50)
+	}
+
+	recorder.Reset()
+	if len(recorder.Snapshot()) != 0 {
+		t.Fatal("snapshots left after Reset")
+	}
+}
+
+func TestRingMetricsRecorderShard(t *testing.T) {
+	srv := newFakeServer(t, nil)
+	recorder := &obsRecorder{}
+	ring := NewRing(&RingOptions{
+		Addrs:           map[string]string{"shard1": srv.Addr},
+		MetricsRecorder: recorder,
+	})
+	defer ring.Close()
+
+	ring.Set("key", "value", 0)
+	for _, o := range recorder.Observations() {
+		if o.Name == "set" {
+			if o.Shard != "shard1" || o.Pipeline {
+				t.Fatalf("observation = %+v", o)
+			}
+			return
+		}
+	}
+	t.Fatal("SET not observed")
+}
@@ -1,6 +1,7 @@
 package redis
 
 import (
+	"context"
 	"crypto/tls"
 	"errors"
 	"fmt"
@@ -26,11 +27,24 @@ type Options struct {
 	Dialer func() (net.Conn, error)
 
 	// Hook that is called when new connection is established.
+	// Deprecated: use OnConnectContext.
 	OnConnect func(*Conn) error
+	// Hook that is called when new connection is established, after
+	// AUTH, SELECT and CLIENT SETNAME, with the address the connection
+	// is for. ctx expires after DialTimeout and, for Ring shards,
+	// carries the shard name, see ShardName. An error closes the
+	// connection and counts as a dial failure, so the command is retried
+	// with backoff as long as MaxRetries allows.
+	OnConnectContext func(ctx context.Context, cn *Conn, addr string) error
 
 	// Optional password. Must match the password specified in the
 	// requirepass server configuration option.
 	Password string
+	// Name set with CLIENT SETNAME on every new connection, so
+	// connections can be told apart in CLIENT LIST.
+	// Default is empty, which leaves connections anonymous.
+	ClientName string
+
 	// Database to be selected after connecting to the server.
 	DB int
 
@@ -56,11 +70,20 @@ type Options struct {
 	// Default is ReadTimeout.
 	WriteTimeout time.Duration
 
+	// Size of the read buffer of every connection.
+	// Default is 4096 bytes. Larger replies grow a per-connection buffer,
+	// which is released when the connection is put back into the pool if
+	// it grew past 64KB or this size. Use GetInto to stream large values
+	// instead of buffering them.
+	ReaderBufferSize int
+
 	// Maximum number of socket connections.
 	// Default is 10 connections per every CPU as reported by runtime.NumCPU.
 	PoolSize int
 	// Amount of time client waits for connection if all connections
-	// are busy before returning an error.
+	// are busy before returning an error. Waiting commands get
+	// connections in the order they asked for them. The deadline of a
+	// context set with WithContext ends the wait earlier.
 	// Default is ReadTimeout + 1 second.
 	PoolTimeout time.Duration
 	// Amount of time after which client closes idle connections.
@@ -71,10 +94,55 @@ type Options struct {
 	// Default is 1 minute.
 	// When minus value is set, then idle check is disabled.
 	IdleCheckFrequency time.Duration
+	// Connections idle longer than this are probed with a short read
+	// before being returned from the pool, and replaced if the peer
+	// closed them. Recently used connections are not probed.
+	// Default is 0, which disables the check.
+	MaxIdleTimeBeforeCheck time.Duration
+	// Connections older than this are closed when taken from the pool
+	// instead of being reused, e.g. to get past load balancers that
+	// silently drop long-lived connections.
+	// Default is 0, which keeps connections regardless of age.
+	MaxConnAge time.Duration
+
+	// Count processed commands and errors for Stats.
+	// Default is false, which keeps the process path free of counters.
+	CommandStats bool
+	// Observes the latency of every attempt of every command, including
+	// pipelined ones, e.g. a HistogramRecorder.
+	// Default is nil.
+	MetricsRecorder MetricsRecorder
+
+	// host:port addresses of replicas of Addr. When set, commands that
+	// COMMAND marks read-only are sent to the replicas round-robin and
+	// all other commands to Addr. Replicas failing 3 subsequent checks
+	// are skipped until they recover; with all of them down reads go
+	// to Addr. Use WithMasterOnly to send reads to Addr anyway.
+	// Pipelines and transactions always use Addr.
+	SlaveAddrs []string
+	// Read-only commands that may be sent to SlaveAddrs. When set, other
+	// read-only commands go to Addr, e.g. to keep reads that must see
+	// the latest writes off the replicas.
+	// Default is empty, which allows every read-only command.
+	ReadOnlyCommandsOnly []string
+
+	// Caches replies of read commands in process, e.g. for hot config
+	// keys. Cached keys are dropped when this client writes them, and
+	// when the server publishes a keyspace notification for them, so
+	// the server needs notify-keyspace-events set, e.g. to "KA".
+	// Writes through pipelines or other clients are only seen through
+	// the notifications. Use WithoutCache to read from the server.
+	// Default is nil, which disables the cache.
+	Cache *CacheOptions
 
 	// Enables read only queries on slave nodes.
 	readOnly bool
 
+	// Name of the Ring shard, passed to OnConnectContext.
+	shardName string
+	// Pick a random replica instead of round-robin, set for Ring shards.
+	randomSlave bool
+
 	// TLS Config to use. When set TLS will be negotiated.
 	TLSConfig *tls.Config
 }
@@ -153,10 +221,6 @@ func ParseURL(redisURL string) (*Options, error) {
 		}
 	}
 
-	if len(u.Query()) > 0 {
-		return nil, errors.New("no options supported")
-	}
-
 	h, p, err := net.SplitHostPort(u.Host)
 	if err != nil {
 		h = u.Host
@@ -186,9 +250,54 @@ func ParseURL(redisURL string) (*Options, error) {
 	if u.Scheme == "rediss" {
 		o.TLSConfig = &tls.Config{ServerName: h}
 	}
+
+	if err := setURLQueryOptions(o, u.Query()); err != nil {
+		return nil, err
+	}
 	return o, nil
 }
 
+// setURLQueryOptions applies query parameters such as ?pool_size=20&read_timeout=1s.
+// Durations use time.ParseDuration syntax.
+func setURLQueryOptions(o *Options, q url.Values) error {
+	for name, values := range q {
+		if len(values) != 1 {
+			return fmt.Errorf("redis: multiple values for option %q", name)
+		}
+		v := values[0]
+
+		var err error
+		switch name {
+		case "dial_timeout":
+			o.DialTimeout, err = time.ParseDuration(v)
+		case "read_timeout":
+			o.ReadTimeout, err = time.ParseDuration(v)
+		case "write_timeout":
+			o.WriteTimeout, err = time.ParseDuration(v)
+		case "pool_size":
+			o.PoolSize, err = strconv.Atoi(v)
+		case "reader_buffer_size":
+			o.ReaderBufferSize, err = strconv.Atoi(v)
+		case "pool_timeout":
+			o.PoolTimeout, err = time.ParseDuration(v)
+		case "idle_timeout":
+			o.IdleTimeout, err = time.ParseDuration(v)
+		case "max_retries":
+			o.MaxRetries, err = strconv.Atoi(v)
+		case "min_retry_backoff":
+			o.MinRetryBackoff, err = time.ParseDuration(v)
+		case "max_retry_backoff":
+			o.MaxRetryBackoff, err = time.ParseDuration(v)
+		default:
+			return fmt.Errorf("redis: unknown option %q in URL", name)
+		}
+		if err != nil {
+			return fmt.Errorf("redis: invalid %s value %q: %s", name, v, err)
+		}
+	}
+	return nil
+}
+
 func newConnPool(opt *Options) *pool.ConnPool {
 	return pool.NewConnPool(&pool.Options{
 		Dialer:             opt.Dialer,
@@ -196,5 +305,9 @@ func newConnPool(opt *Options) *pool.ConnPool {
 		PoolTimeout:        opt.PoolTimeout,
 		IdleTimeout:        opt.IdleTimeout,
 		IdleCheckFrequency: opt.IdleCheckFrequency,
+
+		MaxIdleTimeBeforeCheck: opt.MaxIdleTimeBeforeCheck,
+		MaxConnAge:             opt.MaxConnAge,
+		ReaderBufferSize:       opt.ReaderBufferSize,
 	})
 }
@@ -0,0 +1,58 @@
+package redis
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseURLQueryOptions(t *testing.T) {
+	tests := []struct {
+		query string
+		check func(o *Options) bool
+	}{
+		{"dial_timeout=3s", func(o *Options) bool { return o.DialTimeout == 3*time.Second }},
+		{"read_timeout=500ms", func(o *Options) bool { return o.ReadTimeout == 500*time.Millisecond }},
+		{"write_timeout=1m", func(o *Options) bool { return o.WriteTimeout == time.Minute }},
+		{"pool_size=20", func(o *Options) bool { return o.PoolSize == 20 }},
+		{"reader_buffer_size=65536", func(o *Options) bool { return o.ReaderBufferSize == 65536 }},
+		{"pool_timeout=2s", func(o *Options) bool { return o.PoolTimeout == 2*time.Second }},
+		{"idle_timeout=5m", func(o *Options) bool { return o.IdleTimeout == 5*time.Minute }},
+		{"max_retries=3", func(o *Options) bool { return o.MaxRetries == 3 }},
+		{"min_retry_backoff=8ms", func(o *Options) bool { return o.MinRetryBackoff == 8*time.Millisecond }},
+		{"max_retry_backoff=512ms", func(o *Options) bool { return o.MaxRetryBackoff == 512*time.Millisecond }},
+		{"pool_size=5&read_timeout=1s", func(o *Options) bool { return o.PoolSize == 5 && o.ReadTimeout == time.Second }},
+	}
+	for _, test := range tests {
+		o, err := ParseURL("redis://:secret@localhost:6379/2?" + test.query)
+		if err != nil {
+			t.Errorf("%s: %v", test.query, err)
+			continue
+		}
+		if !test.check(o) {
+			t.Errorf("%s: options %+v", test.query, o)
+		}
+		if o.Addr != "localhost:6379" || o.Password != "secret" || o.DB != 2 {
+			t.Errorf("%s: addr %s password %s db %d", test.query, o.Addr, o.Password, o.DB)
+		}
+	}
+}
+
+func TestParseURLQueryOptionsErrors(t *testing.T) {
+	tests := []struct {
+		query string
+		err   string
+	}{
+		{"pool_size=many", `invalid pool_size value "many"`},
+		{"read_timeout=1", `invalid read_timeout value "1"`},
+		{"max_retries=1.5", `invalid max_retries value "1.5"`},
+		{"pool_size=1&pool_size=2", `multiple values for option "pool_size"`},
+		{"poolsize=10", `unknown option "poolsize"`},
+	}
+	for _, test := range tests {
+		_, err := ParseURL("redis://localhost:6379?" + test.query)
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: err = %v, want %s", test.query, err, test.err)
+		}
+	}
+}
@@ -49,6 +49,22 @@ func boolSliceParser(rd *proto.Reader, n int64) (interface{}, error) {
 	return bools, nil
 }
 
+// Implements proto.MultiBulkParse
+func intSliceParser(rd *proto.Reader, n int64) (interface{}, error) {
+	ints := make([]*int64, 0, n)
+	for i := int64(0); i < n; i++ {
+		n, err := rd.ReadIntReply()
+		if err == Nil {
+			ints = append(ints, nil)
+		} else if err != nil {
+			return nil, err
+		} else {
+			ints = append(ints, &n)
+		}
+	}
+	return ints, nil
+}
+
 // Implements proto.MultiBulkParse
 func stringSliceParser(rd *proto.Reader, n int64) (interface{}, error) {
 	ss := make([]string, 0, n)
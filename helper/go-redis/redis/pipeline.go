@@ -1,6 +1,7 @@
 package redis
 
 import (
+	"errors"
 	"sync"
 
 	"EasyDarwin/helper/go-redis/redis/internal/pool"
@@ -8,6 +9,8 @@ import (
 
 type pipelineExecer func([]Cmder) error
 
+var errPipelineExecuting = errors.New("redis: pipeline Discard called during Exec")
+
 type Pipeliner interface {
 	StatefulCmdable
 	Process(cmd Cmder) error
@@ -26,9 +29,10 @@ type Pipeline struct {
 
 	exec pipelineExecer
 
-	mu     sync.Mutex
-	cmds   []Cmder
-	closed bool
+	mu        sync.Mutex
+	cmds      []Cmder
+	closed    bool
+	executing int // number of running Execs
 }
 
 func (c *Pipeline) Process(cmd Cmder) error {
@@ -47,12 +51,16 @@ func (c *Pipeline) Close() error {
 	return nil
 }
 
-// Discard resets the pipeline and discards queued commands.
+// Discard resets the pipeline and discards queued commands, so a
+// following Exec sends nothing; a TxPipeline does not send MULTI either.
+// It fails while Exec is running.
 func (c *Pipeline) Discard() error {
 	c.mu.Lock()
-	err := c.discard()
-	c.mu.Unlock()
-	return err
+	defer c.mu.Unlock()
+	if c.executing > 0 {
+		return errPipelineExecuting
+	}
+	return c.discard()
 }
 
 func (c *Pipeline) discard() error {
@@ -67,23 +75,30 @@ func (c *Pipeline) discard() error {
 // client-server roundtrip.
 //
 // Exec always returns list of commands and error of the first failed
-// command if any.
+// command if any. Exec of an empty pipeline returns nil, nil.
+//
+// Commands queued while Exec is running are sent by the next Exec.
 func (c *Pipeline) Exec() ([]Cmder, error) {
 	c.mu.Lock()
-	defer c.mu.Unlock()
-
 	if c.closed {
+		c.mu.Unlock()
 		return nil, pool.ErrClosed
 	}
-
 	if len(c.cmds) == 0 {
+		c.mu.Unlock()
 		return nil, nil
 	}
-
 	cmds := c.cmds
 	c.cmds = nil
+	c.executing++
+	c.mu.Unlock()
+
+	err := c.exec(cmds)
 
-	return cmds, c.exec(cmds)
+	c.mu.Lock()
+	c.executing--
+	c.mu.Unlock()
+	return cmds, err
 }
 
 func (c *Pipeline) pipelined(fn func(Pipeliner) error) ([]Cmder, error) {
@@ -0,0 +1,77 @@
+package redis
+
+import (
+	"testing"
+)
+
+func TestPipelineDiscardThenExec(t *testing.T) {
+	srv := newFakeServer(t, nil)
+	client := NewClient(&Options{Addr: srv.Addr})
+	defer client.Close()
+
+	for _, pipe := range []Pipeliner{client.Pipeline(), client.TxPipeline()} {
+		pipe.Set("a", "1", 0)
+		pipe.Set("b", "2", 0)
+		if err := pipe.Discard(); err != nil {
+			t.Fatal(err)
+		}
+		if cmds, err := pipe.Exec(); cmds != nil || err != nil {
+			t.Fatalf("Exec after Discard = %v, %v, want nil, nil", cmds, err)
+		}
+	}
+	if cmds := srv.Cmds(); len(cmds) != 0 {
+		t.Fatalf("commands = %v, want nothing sent, not even MULTI", cmds)
+	}
+
+	pipe := client.Pipeline()
+	pipe.Set("a", "1", 0)
+	pipe.Discard()
+	pipe.Set("c", "3", 0)
+	cmds, err := pipe.Exec()
+	if err != nil || len(cmds) != 1 {
+		t.Fatalf("Exec = %v, %v", cmds, err)
+	}
+	if got := srv.Cmds(); len(got) != 1 || got[0].Args[1] != "c" {
+		t.Fatalf("commands = %v, want only the one queued after Discard", got)
+	}
+}
+
+func TestPipelineDiscardDuringExec(t *testing.T) {
+	executing, release := make(chan struct{}), make(chan struct{})
+	var sent [][]Cmder
+	pipe := &Pipeline{
+		exec: func(cmds []Cmder) error {
+			sent = append(sent, cmds)
+			if len(sent) == 1 {
+				close(executing)
+				<-release
+			}
+			return nil
+		},
+	}
+	pipe.statefulCmdable.setProcessor(pipe.Process)
+
+	pipe.Set("a", "1", 0)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		pipe.Exec()
+	}()
+	<-executing
+	if err := pipe.Discard(); err != errPipelineExecuting {
+		t.Fatalf("Discard during Exec err = %v", err)
+	}
+	pipe.Set("b", "2", 0)
+	close(release)
+	<-done
+
+	if _, err := pipe.Exec(); err != nil {
+		t.Fatal(err)
+	}
+	if len(sent) != 2 || sent[1][0].stringArg(1) != "b" {
+		t.Fatalf("sent = %v, want the command queued during Exec sent next", sent)
+	}
+	if err := pipe.Discard(); err != nil {
+		t.Fatalf("Discard after Exec err = %v", err)
+	}
+}
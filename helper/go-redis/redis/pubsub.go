@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"EasyDarwin/helper/go-redis/redis/internal"
@@ -30,10 +31,21 @@ type PubSub struct {
 
 	cmd *Cmd
 
-	chOnce sync.Once
-	ch     chan *Message
+	chOnce  sync.Once
+	ch      chan *Message
+	dropped uint64 // atomic
 }
 
+// ErrTimeout is returned by PubSub.ReceiveTimeout when no message arrives
+// in time. The subscription is kept and the next receive can be retried.
+var ErrTimeout error = timeoutError{}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "redis: pubsub receive timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
 func (c *PubSub) conn() (*pool.Conn, error) {
 	c.mu.Lock()
 	cn, err := c._conn(nil)
@@ -296,7 +308,7 @@ func (c *PubSub) newMessage(reply interface{}) (interface{}, error) {
 	}
 }
 
-// ReceiveTimeout acts like Receive but returns an error if message
+// ReceiveTimeout acts like Receive but returns ErrTimeout if message
 // is not received in time. This is low-level API and most clients
 // should use ReceiveMessage.
 func (c *PubSub) ReceiveTimeout(timeout time.Duration) (interface{}, error) {
@@ -313,6 +325,9 @@ func (c *PubSub) ReceiveTimeout(timeout time.Duration) (interface{}, error) {
 	err = c.cmd.readReply(cn)
 	c.releaseConn(cn, err)
 	if err != nil {
+		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+			return nil, ErrTimeout
+		}
 		return nil, err
 	}
 
@@ -375,13 +390,46 @@ func (c *PubSub) receiveMessage(timeout time.Duration) (*Message, error) {
 	}
 }
 
+type channelOptions struct {
+	size        int
+	sendTimeout time.Duration
+}
+
+// ChannelOption configures the Go channel returned by PubSub.Channel.
+type ChannelOption func(*channelOptions)
+
+// WithChannelSize sets the buffer size of the channel. Default is 100.
+func WithChannelSize(size int) ChannelOption {
+	return func(opt *channelOptions) {
+		opt.size = size
+	}
+}
+
+// WithSendTimeout sets how long a message waits for room in a full
+// channel before it is dropped. Default is 0, which waits forever.
+func WithSendTimeout(timeout time.Duration) ChannelOption {
+	return func(opt *channelOptions) {
+		opt.sendTimeout = timeout
+	}
+}
+
 // Channel returns a Go channel for concurrently receiving messages.
 // The channel is closed with PubSub. Receive or ReceiveMessage APIs
-// can not be used after channel is created.
-func (c *PubSub) Channel() <-chan *Message {
+// can not be used after channel is created. Options only take effect
+// on the first call.
+func (c *PubSub) Channel(opts ...ChannelOption) <-chan *Message {
 	c.chOnce.Do(func() {
-		c.ch = make(chan *Message, 100)
+		opt := channelOptions{size: 100}
+		for _, fn := range opts {
+			fn(&opt)
+		}
+		c.ch = make(chan *Message, opt.size)
 		go func() {
+			var timer *time.Timer
+			if opt.sendTimeout > 0 {
+				timer = time.NewTimer(opt.sendTimeout)
+				timer.Stop()
+			}
 			for {
 				msg, err := c.ReceiveMessage()
 				if err != nil {
@@ -390,10 +438,28 @@ func (c *PubSub) Channel() <-chan *Message {
 					}
 					continue
 				}
-				c.ch <- msg
+				if timer == nil {
+					c.ch <- msg
+					continue
+				}
+				timer.Reset(opt.sendTimeout)
+				select {
+				case c.ch <- msg:
+					if !timer.Stop() {
+						<-timer.C
+					}
+				case <-timer.C:
+					atomic.AddUint64(&c.dropped, 1)
+				}
 			}
 			close(c.ch)
 		}()
 	})
 	return c.ch
 }
+
+// DroppedMessages returns the number of messages dropped by Channel
+// because the channel stayed full longer than the send timeout.
+func (c *PubSub) DroppedMessages() uint64 {
+	return atomic.LoadUint64(&c.dropped)
+}
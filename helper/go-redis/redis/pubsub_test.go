@@ -0,0 +1,67 @@
+package redis
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func newPubSubServer(t *testing.T) *fakeServer {
+	return newFakeServer(t, func(conn int, args []string) string {
+		switch strings.ToLower(args[0]) {
+		case "subscribe":
+			return respArray(respBulk("subscribe"), respBulk(args[1]), respInt(1))
+		case "ping":
+			return respArray(respBulk("pong"), respBulk(""))
+		}
+		return "+OK\r\n"
+	})
+}
+
+// subscribedConn returns the connection of the SUBSCRIBE to channel.
+func subscribedConn(t *testing.T, srv *fakeServer, channel string) int {
+	for _, cmd := range srv.Cmds() {
+		if strings.EqualFold(cmd.Args[0], "subscribe") && cmd.Args[1] == channel {
+			return cmd.Conn
+		}
+	}
+	t.Fatalf("no SUBSCRIBE to %s", channel)
+	return 0
+}
+
+func TestPubSubReceiveTimeout(t *testing.T) {
+	srv := newPubSubServer(t)
+	client := NewClient(&Options{Addr: srv.Addr})
+	defer client.Close()
+
+	sub := client.Subscribe("news")
+	defer sub.Close()
+	msg, err := sub.ReceiveTimeout(time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := msg.(*Subscription); !ok {
+		t.Fatalf("first message = %#v, want the subscription", msg)
+	}
+
+	const timeout = 50 * time.Millisecond
+	start := time.Now()
+	if _, err = sub.ReceiveTimeout(timeout); err != ErrTimeout {
+		t.Fatalf("err = %v on a quiet channel, want ErrTimeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > timeout+100*time.Millisecond {
+		t.Fatalf("ReceiveTimeout returned after %v, want about %v", elapsed, timeout)
+	}
+
+	conn := subscribedConn(t, srv, "news")
+	if err = srv.Push(conn, respArray(respBulk("message"), respBulk("news"), respBulk("hello"))); err != nil {
+		t.Fatal(err)
+	}
+	msg, err = sub.ReceiveTimeout(time.Second)
+	if err != nil {
+		t.Fatalf("receive after a timeout: %v", err)
+	}
+	if m, ok := msg.(*Message); !ok || m.Channel != "news" || m.Payload != "hello" {
+		t.Fatalf("message = %#v", msg)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"net"
 	"os"
 	"time"
 
@@ -23,6 +24,19 @@ func SetLogger(logger *log.Logger) {
 	internal.Logger = logger
 }
 
+// SetDebug enables debug messages on the logger set with SetLogger.
+func SetDebug(debug bool) {
+	internal.Debug = debug
+}
+
+// SetLogRateLimit enables or disables the suppression of messages logged
+// per command, e.g. about missing command info or stray replies. With it
+// enabled, which is the default, such a message is logged at most once a
+// second along with the number of similar messages suppressed.
+func SetLogRateLimit(enabled bool) {
+	internal.LogRateLimit = enabled
+}
+
 type baseClient struct {
 	opt      *Options
 	connPool pool.Pooler
@@ -31,6 +45,8 @@ type baseClient struct {
 	processPipeline   func([]Cmder) error
 	processTxPipeline func([]Cmder) error
 
+	cmdStats *cmdStats // nil unless Options.CommandStats is set
+
 	onClose func() error // hook called when client is closed
 }
 
@@ -38,6 +54,12 @@ func (c *baseClient) init() {
 	c.process = c.defaultProcess
 	c.processPipeline = c.defaultProcessPipeline
 	c.processTxPipeline = c.defaultProcessTxPipeline
+	if c.opt.CommandStats {
+		if c.cmdStats == nil {
+			c.cmdStats = new(cmdStats)
+		}
+		c.process = c.cmdStats.wrap(c.process)
+	}
 }
 
 func (c *baseClient) String() string {
@@ -60,8 +82,8 @@ func (c *baseClient) newConn() (*pool.Conn, error) {
 	return cn, nil
 }
 
-func (c *baseClient) getConn() (*pool.Conn, bool, error) {
-	cn, isNew, err := c.connPool.Get()
+func (c *baseClient) getConn(ctx context.Context) (*pool.Conn, bool, error) {
+	cn, isNew, err := c.connPool.Get(ctx)
 	if err != nil {
 		return nil, false, err
 	}
@@ -92,12 +114,15 @@ func (c *baseClient) initConn(cn *pool.Conn) error {
 	if c.opt.Password == "" &&
 		c.opt.DB == 0 &&
 		!c.opt.readOnly &&
-		c.opt.OnConnect == nil {
+		c.opt.ClientName == "" &&
+		c.opt.OnConnect == nil &&
+		c.opt.OnConnectContext == nil {
 		return nil
 	}
 
+	var setName *BoolCmd
 	conn := newConn(c.opt, cn)
-	_, err := conn.Pipelined(func(pipe Pipeliner) error {
+	cmds, err := conn.Pipelined(func(pipe Pipeliner) error {
 		if c.opt.Password != "" {
 			pipe.Auth(c.opt.Password)
 		}
@@ -110,18 +135,67 @@ func (c *baseClient) initConn(cn *pool.Conn) error {
 			pipe.ReadOnly()
 		}
 
+		if c.opt.ClientName != "" {
+			setName = pipe.ClientSetName(c.opt.ClientName)
+		}
+
 		return nil
 	})
 	if err != nil {
-		return err
+		for _, cmd := range cmds {
+			cmdErr := cmd.Err()
+			if cmdErr == nil {
+				continue
+			}
+			// Servers without CLIENT SETNAME still get the connection.
+			if cmd == setName && internal.IsRedisError(cmdErr) {
+				internal.Debugf("redis: CLIENT SETNAME %q failed: %s", c.opt.ClientName, cmdErr)
+				continue
+			}
+			return cmdErr
+		}
 	}
 
 	if c.opt.OnConnect != nil {
-		return c.opt.OnConnect(conn)
+		if err := c.opt.OnConnect(conn); err != nil {
+			return err
+		}
+	}
+
+	if c.opt.OnConnectContext != nil {
+		ctx := context.Background()
+		if c.opt.shardName != "" {
+			ctx = context.WithValue(ctx, shardNameKey{}, c.opt.shardName)
+		}
+		ctx, cancel := context.WithTimeout(ctx, c.opt.DialTimeout)
+		defer cancel()
+		if err := c.opt.OnConnectContext(ctx, conn, c.opt.Addr); err != nil {
+			if p, ok := c.connPool.(*pool.ConnPool); ok {
+				p.DialFailed(err)
+			}
+			return &OnConnectError{Addr: c.opt.Addr, Err: err}
+		}
 	}
 	return nil
 }
 
+// OnConnectError is returned for a connection rejected by
+// Options.OnConnectContext. It is a net.Error, so the command is retried
+// like after a failed dial.
+type OnConnectError struct {
+	Addr string
+	Err  error
+}
+
+var _ net.Error = (*OnConnectError)(nil)
+
+func (e *OnConnectError) Error() string {
+	return fmt.Sprintf("redis: OnConnectContext for %s failed: %s", e.Addr, e.Err)
+}
+
+func (e *OnConnectError) Timeout() bool   { return false }
+func (e *OnConnectError) Temporary() bool { return true }
+
 // WrapProcess wraps function that processes Redis commands.
 func (c *baseClient) WrapProcess(fn func(oldProcess func(cmd Cmder) error) func(cmd Cmder) error) {
 	c.process = fn(c.process)
@@ -137,9 +211,11 @@ func (c *baseClient) defaultProcess(cmd Cmder) error {
 			time.Sleep(c.retryBackoff(attempt))
 		}
 
-		cn, _, err := c.getConn()
+		start := time.Now()
+		cn, _, err := c.getConn(cmd.context())
 		if err != nil {
 			cmd.setErr(err)
+			c.observe(start, attempt, false, cmd)
 			if internal.IsRetryableError(err, true) {
 				continue
 			}
@@ -150,6 +226,7 @@ func (c *baseClient) defaultProcess(cmd Cmder) error {
 		if err := writeCmd(cn, cmd); err != nil {
 			c.releaseConn(cn, err)
 			cmd.setErr(err)
+			c.observe(start, attempt, false, cmd)
 			if internal.IsRetryableError(err, true) {
 				continue
 			}
@@ -159,6 +236,7 @@ func (c *baseClient) defaultProcess(cmd Cmder) error {
 		cn.SetReadTimeout(c.cmdTimeout(cmd))
 		err = cmd.readReply(cn)
 		c.releaseConn(cn, err)
+		c.observe(start, attempt, false, cmd)
 		if err != nil && internal.IsRetryableError(err, cmd.readTimeout() == nil) {
 			continue
 		}
@@ -225,13 +303,16 @@ func (c *baseClient) generalProcessPipeline(cmds []Cmder, p pipelineProcessor) e
 			time.Sleep(c.retryBackoff(attempt))
 		}
 
-		cn, _, err := c.getConn()
+		start := time.Now()
+		cn, _, err := c.getConn(context.Background())
 		if err != nil {
 			setCmdsErr(cmds, err)
+			c.observe(start, attempt, true, cmds...)
 			return err
 		}
 
 		canRetry, err := p(cn, cmds)
+		c.observe(start, attempt, true, cmds...)
 
 		if err == nil || internal.IsRedisError(err) {
 			_ = c.connPool.Put(cn)
@@ -260,6 +341,10 @@ func (c *baseClient) pipelineProcessCmds(cn *pool.Conn, cmds []Cmder) (bool, err
 
 func pipelineReadCmds(cn *pool.Conn, cmds []Cmder) error {
 	for _, cmd := range cmds {
+		// Blocking commands such as WAIT extend the read deadline.
+		if timeout := cmd.readTimeout(); timeout != nil {
+			cn.SetReadTimeout(*timeout)
+		}
 		err := cmd.readReply(cn)
 		if err != nil && !internal.IsRedisError(err) {
 			return err
@@ -340,6 +425,9 @@ type Client struct {
 	cmdable
 
 	ctx context.Context
+
+	slaves *clientSlaves // nil unless Options.SlaveAddrs is set
+	cache  *clientCache  // nil unless Options.Cache is set
 }
 
 // NewClient returns a client to the Redis Server specified by Options.
@@ -354,14 +442,57 @@ func NewClient(opt *Options) *Client {
 	}
 	c.baseClient.init()
 	c.init()
+	if len(opt.SlaveAddrs) > 0 {
+		c.slaves = newClientSlaves(&c)
+	}
+	if opt.Cache != nil {
+		c.cache = newClientCache(&c)
+	}
+	if c.slaves != nil || c.cache != nil {
+		c.onClose = c.closeAddons
+	}
 
 	return &c
 }
 
+func (c *Client) closeAddons() error {
+	var firstErr error
+	if c.cache != nil {
+		firstErr = c.cache.Close()
+	}
+	if c.slaves != nil {
+		if err := c.slaves.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
 func (c *Client) init() {
 	c.cmdable.setProcessor(c.Process)
 }
 
+// Process sends cmd to the master, or to a replica when Options.SlaveAddrs
+// is set and cmd is read-only. With Options.Cache cached replies are
+// served without sending cmd. The deadline of the context set with
+// WithContext bounds the wait for a pooled connection.
+func (c *Client) Process(cmd Cmder) error {
+	if c.ctx != nil {
+		cmd.setContext(c.ctx)
+	}
+	if c.cache != nil {
+		return c.cache.process(c.Context(), cmd, c.routeProcess)
+	}
+	return c.routeProcess(cmd)
+}
+
+func (c *Client) routeProcess(cmd Cmder) error {
+	if c.slaves != nil {
+		return c.slaves.process(c.Context(), cmd, c.baseClient.Process)
+	}
+	return c.baseClient.Process(cmd)
+}
+
 func (c *Client) Context() context.Context {
 	if c.ctx != nil {
 		return c.ctx
@@ -397,6 +528,14 @@ func (c *Client) PoolStats() *PoolStats {
 	return (*PoolStats)(stats)
 }
 
+// CacheStats returns the counters of the cache set with Options.Cache.
+func (c *Client) CacheStats() *CacheStats {
+	if c.cache == nil {
+		return &CacheStats{}
+	}
+	return c.cache.Stats()
+}
+
 func (c *Client) Pipelined(fn func(Pipeliner) error) ([]Cmder, error) {
 	return c.Pipeline().Pipelined(fn)
 }
@@ -0,0 +1,178 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClientMaxIdleTimeBeforeCheck(t *testing.T) {
+	srv := newFakeServer(t, nil)
+	client := NewClient(&Options{
+		Addr:                   srv.Addr,
+		MaxIdleTimeBeforeCheck: 10 * time.Millisecond,
+	})
+	defer client.Close()
+
+	for i := 0; i < 3; i++ {
+		if err := client.Ping().Err(); err != nil {
+			t.Fatal(err)
+		}
+		// the server drops the idle connections, e.g. on its timeout
+		srv.DropConns()
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err := client.Ping().Err(); err != nil {
+		t.Fatalf("Ping after the server dropped the idle connection: %v", err)
+	}
+	if srv.Conns() != 4 {
+		t.Fatalf("%d connections, want a new one after each drop", srv.Conns())
+	}
+}
+
+// Without the check, the first command on a dropped connection fails.
+func TestClientWithoutIdleCheck(t *testing.T) {
+	srv := newFakeServer(t, nil)
+	client := NewClient(&Options{Addr: srv.Addr})
+	defer client.Close()
+
+	if err := client.Ping().Err(); err != nil {
+		t.Fatal(err)
+	}
+	srv.DropConns()
+	time.Sleep(20 * time.Millisecond)
+	if err := client.Ping().Err(); err == nil {
+		t.Fatal("Ping on a dropped connection succeeded, the test server did not drop it")
+	}
+}
+
+func TestClientNameSetOncePerConn(t *testing.T) {
+	srv := newFakeServer(t, nil)
+	client := NewClient(&Options{Addr: srv.Addr, ClientName: "easydarwin", PoolSize: 2})
+	defer client.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := client.Ping().Err(); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	setNames := make(map[int]int)
+	for _, cmd := range srv.Cmds() {
+		if strings.EqualFold(cmd.Args[0], "client") {
+			if len(cmd.Args) != 3 || !strings.EqualFold(cmd.Args[1], "setname") || cmd.Args[2] != "easydarwin" {
+				t.Fatalf("command = %q", cmd.Args)
+			}
+			setNames[cmd.Conn]++
+		}
+	}
+	if len(setNames) != srv.Conns() {
+		t.Fatalf("SETNAME on %d of %d connections", len(setNames), srv.Conns())
+	}
+	for conn, n := range setNames {
+		if n != 1 {
+			t.Fatalf("SETNAME sent %d times on connection %d", n, conn)
+		}
+	}
+}
+
+func TestClientNameRejected(t *testing.T) {
+	srv := newFakeServer(t, func(conn int, args []string) string {
+		if strings.EqualFold(args[0], "client") {
+			return "-ERR unknown command 'CLIENT'\r\n"
+		}
+		return "+PONG\r\n"
+	})
+	client := NewClient(&Options{Addr: srv.Addr, ClientName: "easydarwin"})
+	defer client.Close()
+
+	if err := client.Ping().Err(); err != nil {
+		t.Fatalf("Ping with SETNAME rejected: %v", err)
+	}
+}
+
+// failOnce returns an OnConnectContext hook rejecting the first connection.
+func failOnce(calls *int32, addrs chan<- string) func(context.Context, *Conn, string) error {
+	return func(ctx context.Context, cn *Conn, addr string) error {
+		if _, ok := ctx.Deadline(); !ok {
+			return errors.New("no deadline")
+		}
+		addrs <- addr + " " + ShardName(ctx)
+		if atomic.AddInt32(calls, 1) == 1 {
+			return errors.New("not yet")
+		}
+		return nil
+	}
+}
+
+func TestClientOnConnectContextRetried(t *testing.T) {
+	srv := newFakeServer(t, nil)
+	var calls int32
+	addrs := make(chan string, 10)
+	client := NewClient(&Options{
+		Addr:             srv.Addr,
+		MaxRetries:       1,
+		OnConnectContext: failOnce(&calls, addrs),
+	})
+	defer client.Close()
+
+	if err := client.Ping().Err(); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 {
+		t.Fatalf("hook called %d times, want 2", calls)
+	}
+	if addr := <-addrs; addr != srv.Addr+" " {
+		t.Fatalf("hook addr = %q", addr)
+	}
+	if conns := srv.Conns(); conns != 2 {
+		t.Fatalf("conns = %d, want the rejected one replaced", conns)
+	}
+}
+
+func TestClientOnConnectContextWithoutRetries(t *testing.T) {
+	srv := newFakeServer(t, nil)
+	var calls int32
+	client := NewClient(&Options{
+		Addr:             srv.Addr,
+		OnConnectContext: failOnce(&calls, make(chan string, 10)),
+	})
+	defer client.Close()
+
+	err := client.Ping().Err()
+	if e, ok := err.(*OnConnectError); !ok || e.Addr != srv.Addr || e.Err.Error() != "not yet" {
+		t.Fatalf("err = %v, want an OnConnectError", err)
+	}
+	if err := client.Ping().Err(); err != nil {
+		t.Fatalf("second Ping err = %v", err)
+	}
+}
+
+func TestRingOnConnectContextShardName(t *testing.T) {
+	srv := newFakeServer(t, nil)
+	var calls int32
+	addrs := make(chan string, 10)
+	ring := NewRing(&RingOptions{
+		Addrs:            map[string]string{"shard1": srv.Addr},
+		MaxRetries:       1,
+		OnConnectContext: failOnce(&calls, addrs),
+	})
+	defer ring.Close()
+
+	if err := ring.Ping().Err(); err != nil {
+		t.Fatal(err)
+	}
+	if addr := <-addrs; addr != srv.Addr+" shard1" {
+		t.Fatalf("hook addr and shard = %q", addr)
+	}
+}
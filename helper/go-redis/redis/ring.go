@@ -5,7 +5,10 @@ import (
 	"errors"
 	"fmt"
 	"math/rand"
+	"net"
+	"sort"
 	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -20,6 +23,14 @@ const nreplicas = 100
 
 var errRingShardsDown = errors.New("redis: all ring shards are down")
 
+// ErrCrossShardTransaction is returned by Exec of a Ring TxPipeline with
+// keys on more than one shard.
+var ErrCrossShardTransaction = errors.New("redis: ring transaction keys must be on the same shard")
+
+// ErrShardNotFound is returned by Ring.ShardByName and Ring.ShardByAddr
+// when no shard has the given name or address.
+var ErrShardNotFound = errors.New("redis: ring shard not found")
+
 // RingOptions are used to configure a ring client and should be
 // passed to NewRing.
 type RingOptions struct {
@@ -30,13 +41,104 @@ type RingOptions struct {
 	// Shard is considered down after 3 subsequent failed checks.
 	HeartbeatFrequency time.Duration
 
+	// Frequency of re-resolving shard host names. When the resolved
+	// addresses change and the change is seen by two subsequent checks,
+	// the connection pool of the shard is recycled so new connections
+	// dial the new address.
+	// Default is 0, which disables re-resolving.
+	DNSRefreshInterval time.Duration
+
+	// Timeout for heartbeat PINGs. When set, PINGs are sent over a
+	// dedicated connection outside of the shard pool, so they are not
+	// delayed by PoolTimeout, and a timed out PING counts as a failure.
+	// Default is 0, which sends PINGs through the shard pool with the
+	// shard Read/WriteTimeout.
+	HeartbeatTimeout time.Duration
+
+	// Delays adding a shard that came back up to the ring by a random
+	// time between 0 and ReconnectBaseDelay doubled for every time the
+	// shard went down again soon after (full jitter backoff), up to
+	// ReconnectMaxDelay. Ring clients of many processes then reconnect
+	// to a recovered shard at different times instead of all at once,
+	// and a flapping shard is kept out longer.
+	// Default is 0, which adds shards back on the first successful
+	// heartbeat.
+	ReconnectBaseDelay time.Duration
+	// Default is 32 times ReconnectBaseDelay.
+	ReconnectMaxDelay time.Duration
+
+	// Enables coalescing of identical read-only commands that are in
+	// flight at the same time on the same shard: only the first one is
+	// sent and the others get a copy of its reply.
+	EnableCommandCoalescing bool
+	// Commands that may be coalesced.
+	// Default is DefaultCoalescedCommands.
+	CoalescedCommands []string
+
+	// Map of shard name => host:port addresses of its replicas. Commands
+	// that COMMAND marks read-only are sent to a random live replica of
+	// the shard and all other commands to the shard address, see
+	// Options.SlaveAddrs. Replica health is checked apart from the shard,
+	// so a replica going down never marks its shard down.
+	Replicas map[string][]string
+
+	// Promotes the first live replica of a shard that is marked down to
+	// primary with SLAVEOF NO ONE, and points the shard name on the ring
+	// to the replica address, so the key space of the shard stays
+	// available. The other replicas are kept for reads but are not
+	// re-pointed to the new primary. Failback is manual, see
+	// Ring.RestoreShard.
+	AutoFailover bool
+	// Called after a shard failed over to the replica at newAddr, e.g. to
+	// update DNS. The shard client is a new one, so process wrappers
+	// installed on the former client must be installed again here.
+	AfterFailover func(shardName, newAddr string)
+
+	// Caches replies of read commands in process, per shard.
+	// See Options.Cache.
+	Cache *CacheOptions
+
+	// Enables consistent hashing with bounded loads: a key goes to the
+	// next shard on the ring when its shard was picked more than
+	// BoundedLoadFactor times as often as the average shard. Loads are
+	// halved on every heartbeat, so a hot spot stops counting once it
+	// cools down. As a key may then move between shards, use it only
+	// when any shard can serve any key, e.g. with shards as caches.
+	// Must be greater than 1, e.g. 1.25.
+	// Default is 0, which disables it.
+	BoundedLoadFactor float64
+
+	// Options of a shadow ring, e.g. a new shard layout to validate
+	// before cutting over. Writes are also sent to the shadow ring and
+	// reads are compared with it in the background; mismatches are logged.
+	// Replies always come from this ring. Pipelines are not shadowed.
+	ShadowMode *RingOptions
+	// Maximum number of commands in flight to the shadow ring. Commands
+	// beyond it are not shadowed, so a slow shadow ring never holds up
+	// this one nor piles up goroutines.
+	// Default is 64.
+	ShadowMaxInFlight int
+
+	// Retries read-only commands failing with a network error up to
+	// MaxRetries times, moving to the next shard on the ring (wrapping
+	// around) after every MaxRetries/2 failures on a shard. Use it only
+	// when any shard can serve any key, e.g. with shards as caches.
+	// Write commands are never retried on another shard.
+	RetryOnDifferentShard bool
+
 	// Following options are copied from Options struct.
 
-	OnConnect func(*Conn) error
+	OnConnect        func(*Conn) error
+	OnConnectContext func(ctx context.Context, cn *Conn, addr string) error
 
 	DB       int
 	Password string
 
+	// ClientName is copied to every shard. With ClientNameShardSuffix
+	// set, "-<shard name>" is appended to it.
+	ClientName            string
+	ClientNameShardSuffix bool
+
 	MaxRetries      int
 	MinRetryBackoff time.Duration
 	MaxRetryBackoff time.Duration
@@ -45,16 +147,28 @@ type RingOptions struct {
 	ReadTimeout  time.Duration
 	WriteTimeout time.Duration
 
-	PoolSize           int
-	PoolTimeout        time.Duration
-	IdleTimeout        time.Duration
-	IdleCheckFrequency time.Duration
+	PoolSize               int
+	PoolTimeout            time.Duration
+	IdleTimeout            time.Duration
+	IdleCheckFrequency     time.Duration
+	MaxIdleTimeBeforeCheck time.Duration
+	MaxConnAge             time.Duration
+
+	CommandStats bool
+	// Observations are tagged with the shard name.
+	MetricsRecorder MetricsRecorder
 }
 
 func (opt *RingOptions) init() {
 	if opt.HeartbeatFrequency == 0 {
 		opt.HeartbeatFrequency = 500 * time.Millisecond
 	}
+	if opt.ReconnectBaseDelay > 0 && opt.ReconnectMaxDelay == 0 {
+		opt.ReconnectMaxDelay = 32 * opt.ReconnectBaseDelay
+	}
+	if opt.ShadowMaxInFlight == 0 {
+		opt.ShadowMaxInFlight = 64
+	}
 
 	switch opt.MinRetryBackoff {
 	case -1:
@@ -72,10 +186,12 @@ func (opt *RingOptions) init() {
 
 func (opt *RingOptions) clientOptions() *Options {
 	return &Options{
-		OnConnect: opt.OnConnect,
+		OnConnect:        opt.OnConnect,
+		OnConnectContext: opt.OnConnectContext,
 
-		DB:       opt.DB,
-		Password: opt.Password,
+		DB:         opt.DB,
+		Password:   opt.Password,
+		ClientName: opt.ClientName,
 
 		DialTimeout:  opt.DialTimeout,
 		ReadTimeout:  opt.ReadTimeout,
@@ -85,7 +201,28 @@ func (opt *RingOptions) clientOptions() *Options {
 		PoolTimeout:        opt.PoolTimeout,
 		IdleTimeout:        opt.IdleTimeout,
 		IdleCheckFrequency: opt.IdleCheckFrequency,
+
+		MaxIdleTimeBeforeCheck: opt.MaxIdleTimeBeforeCheck,
+		MaxConnAge:             opt.MaxConnAge,
+
+		CommandStats:    opt.CommandStats,
+		MetricsRecorder: opt.MetricsRecorder,
+
+		Cache: opt.Cache,
+	}
+}
+
+// shardOptions returns the options of the client of the shard name at addr.
+func (opt *RingOptions) shardOptions(name, addr string, replicas []string) *Options {
+	clopt := opt.clientOptions()
+	clopt.Addr = addr
+	clopt.shardName = name
+	clopt.SlaveAddrs = replicas
+	clopt.randomSlave = true
+	if opt.ClientName != "" && opt.ClientNameShardSuffix {
+		clopt.ClientName = opt.ClientName + "-" + name
 	}
+	return clopt
 }
 
 //------------------------------------------------------------------------------
@@ -93,6 +230,59 @@ func (opt *RingOptions) clientOptions() *Options {
 type ringShard struct {
 	Client *Client
 	down   int32
+
+	// owned by the heartbeat goroutine
+	addrs        []string
+	pendingAddrs []string
+
+	hbMu   sync.Mutex
+	hbConn *pool.Conn
+
+	// nil unless RingOptions.ReconnectBaseDelay is set, owned by the
+	// heartbeat goroutine
+	backoff *reconnectBackoff
+}
+
+// reconnectBackoff delays adding a recovered shard back to the ring.
+type reconnectBackoff struct {
+	baseDelay time.Duration
+	maxDelay  time.Duration
+
+	attempt    int
+	rejoinAt   time.Time // zero unless the shard waits to rejoin
+	rejoinedAt time.Time
+}
+
+// Up schedules the shard to rejoin the ring and returns the delay.
+func (b *reconnectBackoff) Up(now time.Time) time.Duration {
+	delay := internal.RetryBackoff(b.attempt, b.baseDelay, b.maxDelay)
+	b.attempt++
+	b.rejoinAt = now.Add(delay)
+	return delay
+}
+
+// Down resets the backoff if the shard stayed in the ring for longer than
+// the max delay before going down.
+func (b *reconnectBackoff) Down(now time.Time) {
+	b.rejoinAt = time.Time{}
+	if !b.rejoinedAt.IsZero() && now.Sub(b.rejoinedAt) >= b.maxDelay {
+		b.attempt = 0
+	}
+}
+
+// Rejoin reports whether the delay of the shard has just passed.
+func (b *reconnectBackoff) Rejoin(now time.Time) bool {
+	if b.rejoinAt.IsZero() || now.Before(b.rejoinAt) {
+		return false
+	}
+	b.rejoinAt = time.Time{}
+	b.rejoinedAt = now
+	return true
+}
+
+// Waiting reports whether the shard is up but waits to rejoin the ring.
+func (b *reconnectBackoff) Waiting() bool {
+	return !b.rejoinAt.IsZero()
 }
 
 func (shard *ringShard) String() string {
@@ -130,6 +320,105 @@ func (shard *ringShard) Vote(up bool) bool {
 	return shard.IsDown()
 }
 
+// Ping checks the shard state. With zero timeout the PING goes through
+// the shard pool and pool.ErrPoolTimeout is not counted as a failure.
+func (shard *ringShard) Ping(timeout time.Duration) bool {
+	if timeout == 0 {
+		err := shard.Client.Ping().Err()
+		return err == nil || err == pool.ErrPoolTimeout
+	}
+	return shard.heartbeatPing(timeout) == nil
+}
+
+func (shard *ringShard) heartbeatPing(timeout time.Duration) error {
+	shard.hbMu.Lock()
+	defer shard.hbMu.Unlock()
+
+	if shard.hbConn == nil {
+		netConn, err := shard.Client.opt.Dialer()
+		if err != nil {
+			return err
+		}
+		cn := pool.NewConn(netConn)
+		if err := shard.Client.initConn(cn); err != nil {
+			_ = cn.Close()
+			return err
+		}
+		shard.hbConn = cn
+	}
+
+	cmd := NewStatusCmd("ping")
+	shard.hbConn.SetWriteTimeout(timeout)
+	err := writeCmd(shard.hbConn, cmd)
+	if err == nil {
+		shard.hbConn.SetReadTimeout(timeout)
+		err = cmd.readReply(shard.hbConn)
+	}
+	if err != nil && !internal.IsRedisError(err) {
+		_ = shard.hbConn.Close()
+		shard.hbConn = nil
+	}
+	return err
+}
+
+func (shard *ringShard) closeHeartbeatConn() {
+	shard.hbMu.Lock()
+	if shard.hbConn != nil {
+		_ = shard.hbConn.Close()
+		shard.hbConn = nil
+	}
+	shard.hbMu.Unlock()
+}
+
+// lookupHost is replaced in tests.
+var lookupHost = net.LookupHost
+
+// refreshAddrs re-resolves the shard host name and recycles the
+// connection pool when the resolved addresses changed.
+func (shard *ringShard) refreshAddrs() {
+	host, _, err := net.SplitHostPort(shard.Client.opt.Addr)
+	if err != nil || net.ParseIP(host) != nil {
+		return
+	}
+
+	addrs, err := lookupHost(host)
+	if err != nil {
+		internal.Logf("ring shard %s: lookup failed: %s", shard.Client.opt.Addr, err)
+		return
+	}
+	sort.Strings(addrs)
+
+	switch {
+	case shard.addrs == nil:
+		shard.addrs = addrs
+	case stringsEqual(addrs, shard.addrs):
+		shard.pendingAddrs = nil
+	case !stringsEqual(addrs, shard.pendingAddrs):
+		// Wait for the next check to guard against resolver flapping.
+		shard.pendingAddrs = addrs
+	default:
+		internal.Logf("ring shard %s: addresses changed from %v to %v",
+			shard.Client.opt.Addr, shard.addrs, addrs)
+		shard.addrs = addrs
+		shard.pendingAddrs = nil
+		if p, ok := shard.Client.connPool.(*pool.ConnPool); ok {
+			p.Recycle()
+		}
+	}
+}
+
+func stringsEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
 //------------------------------------------------------------------------------
 
 type ringShards struct {
@@ -138,22 +427,50 @@ type ringShards struct {
 	shards map[string]*ringShard // read only
 	list   []*ringShard          // read only
 	closed bool
+
+	// bounded shares hash, it is nil unless RingOptions.BoundedLoadFactor is set.
+	bounded    *consistenthash.BoundedMap
+	loadFactor float64
+
+	reconnectBaseDelay time.Duration
+	reconnectMaxDelay  time.Duration
 }
 
-func newRingShards() *ringShards {
-	return &ringShards{
-		hash:   consistenthash.New(nreplicas, nil),
+func newRingShards(opt *RingOptions) *ringShards {
+	c := &ringShards{
 		shards: make(map[string]*ringShard),
+
+		reconnectBaseDelay: opt.ReconnectBaseDelay,
+		reconnectMaxDelay:  opt.ReconnectMaxDelay,
+	}
+	if opt.BoundedLoadFactor > 0 {
+		c.bounded = consistenthash.NewBounded(nreplicas, nil)
+		c.hash = c.bounded.Map
+		c.loadFactor = opt.BoundedLoadFactor
+	} else {
+		c.hash = consistenthash.New(nreplicas, nil)
 	}
+	return c
 }
 
 func (c *ringShards) Add(name string, cl *Client) {
-	shard := &ringShard{Client: cl}
+	shard := c.newShard(cl)
 	c.hash.Add(name)
 	c.shards[name] = shard
 	c.list = append(c.list, shard)
 }
 
+func (c *ringShards) newShard(cl *Client) *ringShard {
+	shard := &ringShard{Client: cl}
+	if c.reconnectBaseDelay > 0 {
+		shard.backoff = &reconnectBackoff{
+			baseDelay: c.reconnectBaseDelay,
+			maxDelay:  c.reconnectMaxDelay,
+		}
+	}
+	return shard
+}
+
 func (c *ringShards) List() []*ringShard {
 	c.mu.RLock()
 	list := c.list
@@ -161,6 +478,19 @@ func (c *ringShards) List() []*ringShard {
 	return list
 }
 
+// Named returns a copy of the shards keyed by name.
+func (c *ringShards) Named() map[string]*ringShard {
+	c.mu.RLock()
+	named := make(map[string]*ringShard, len(c.shards))
+	for name, shard := range c.shards {
+		named[name] = shard
+	}
+	c.mu.RUnlock()
+	return named
+}
+
+// Hash returns the name of the shard owning key on the ring, regardless
+// of the shard loads.
 func (c *ringShards) Hash(key string) string {
 	c.mu.RLock()
 	hash := c.hash.Get(key)
@@ -168,7 +498,87 @@ func (c *ringShards) Hash(key string) string {
 	return hash
 }
 
+// Place returns the name of the shard a command on key is sent to and,
+// with bounded loads, counts it in the load of the shard.
+func (c *ringShards) Place(key string) string {
+	c.mu.RLock()
+	hash := c.place(key)
+	c.mu.RUnlock()
+	return hash
+}
+
+// place is Place with c.mu held.
+func (c *ringShards) place(key string) string {
+	hash := c.lookup(key)
+	if c.bounded != nil && hash != "" {
+		c.bounded.AddLoad(hash, 1)
+	}
+	return hash
+}
+
+// lookup is place without counting the load, with c.mu held.
+func (c *ringShards) lookup(key string) string {
+	if c.bounded == nil {
+		return c.hash.Get(key)
+	}
+	return c.bounded.GetWithLoad(key, c.loadFactor)
+}
+
+// SameShard returns the shard a command on keys[0] would be sent to and the
+// first key another shard would get, if any. No load is counted.
+func (c *ringShards) SameShard(keys []string) (name, other string, err error) {
+	return c.sameShard(keys, false)
+}
+
+// PlaceKeys is SameShard for a command sent to the shard name, its load
+// counted like the one of a single-key command.
+func (c *ringShards) PlaceKeys(keys []string) (name, other string, err error) {
+	return c.sameShard(keys, true)
+}
+
+func (c *ringShards) sameShard(keys []string, count bool) (name, other string, err error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.closed {
+		return "", "", pool.ErrClosed
+	}
+	// With bounded loads the shard of a key moves with the loads, keys
+	// sharing a hash tag are looked up once so they stay together.
+	tags := make(map[string]string, len(keys))
+	for i, key := range keys {
+		tag := hashtag.Key(key)
+		hash, ok := tags[tag]
+		if !ok {
+			hash = c.lookup(tag)
+			tags[tag] = hash
+		}
+		if hash == "" {
+			return "", "", errRingShardsDown
+		}
+		if i == 0 {
+			name = hash
+		} else if hash != name {
+			return name, key, nil
+		}
+	}
+	if count && c.bounded != nil {
+		c.bounded.AddLoad(name, 1)
+	}
+	return name, "", nil
+}
+
 func (c *ringShards) GetByKey(key string) (*ringShard, error) {
+	return c.getByKey(key, 0)
+}
+
+// GetNextByKey returns the n-th shard after the shard of key on the ring,
+// wrapping around. The owner of key is counted from, bounded loads are not.
+func (c *ringShards) GetNextByKey(key string, n int) (*ringShard, error) {
+	return c.getByKey(key, n)
+}
+
+func (c *ringShards) getByKey(key string, n int) (*ringShard, error) {
 	key = hashtag.Key(key)
 
 	c.mu.RLock()
@@ -178,7 +588,12 @@ func (c *ringShards) GetByKey(key string) (*ringShard, error) {
 		return nil, pool.ErrClosed
 	}
 
-	hash := c.hash.Get(key)
+	var hash string
+	if n == 0 {
+		hash = c.place(key)
+	} else {
+		hash = c.hash.GetNext(key, n)
+	}
 	if hash == "" {
 		c.mu.RUnlock()
 		return nil, errRingShardsDown
@@ -201,15 +616,59 @@ func (c *ringShards) GetByHash(name string) (*ringShard, error) {
 	return shard, nil
 }
 
+func (c *ringShards) GetByName(name string) (*ringShard, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.closed {
+		return nil, pool.ErrClosed
+	}
+	shard, ok := c.shards[name]
+	if !ok {
+		return nil, ErrShardNotFound
+	}
+	return shard, nil
+}
+
+func (c *ringShards) GetByAddr(addr string) (*ringShard, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.closed {
+		return nil, pool.ErrClosed
+	}
+	for _, shard := range c.list {
+		if shard.Client.opt.Addr == addr {
+			return shard, nil
+		}
+	}
+	return nil, ErrShardNotFound
+}
+
 func (c *ringShards) Random() (*ringShard, error) {
 	return c.GetByKey(strconv.Itoa(rand.Int()))
 }
 
 // heartbeat monitors state of each shard in the ring.
-func (c *ringShards) Heartbeat(frequency time.Duration) {
-	ticker := time.NewTicker(frequency)
+func (c *ringShards) Heartbeat(opt *RingOptions) {
+	ticker := time.NewTicker(opt.HeartbeatFrequency)
 	defer ticker.Stop()
-	for range ticker.C {
+
+	var dnsRefresh <-chan time.Time
+	if opt.DNSRefreshInterval > 0 {
+		dnsTicker := time.NewTicker(opt.DNSRefreshInterval)
+		defer dnsTicker.Stop()
+		dnsRefresh = dnsTicker.C
+	}
+
+	for {
+		var refresh bool
+		select {
+		case <-ticker.C:
+		case <-dnsRefresh:
+			refresh = true
+		}
+
 		var rebalance bool
 
 		c.mu.RLock()
@@ -222,32 +681,78 @@ func (c *ringShards) Heartbeat(frequency time.Duration) {
 		shards := c.list
 		c.mu.RUnlock()
 
+		if refresh {
+			for _, shard := range shards {
+				shard.refreshAddrs()
+			}
+			continue
+		}
+
+		if c.bounded != nil {
+			c.bounded.Decay(0.5)
+		}
+
+		now := time.Now()
+		var failed []*ringShard
 		for _, shard := range shards {
-			err := shard.Client.Ping().Err()
-			if shard.Vote(err == nil || err == pool.ErrPoolTimeout) {
+			changed := shard.Vote(shard.Ping(opt.HeartbeatTimeout))
+			// A failover that found no replica to promote is tried
+			// again on every heartbeat the shard stays down.
+			if opt.AutoFailover && shard.IsDown() {
+				failed = append(failed, shard)
+			}
+			if changed {
 				internal.Logf("ring shard state changed: %s", shard)
 				rebalance = true
+				if shard.backoff == nil {
+					continue
+				}
+				if shard.IsUp() {
+					delay := shard.backoff.Up(now)
+					internal.Logf("ring shard %s rejoins in %s", shard.Client, delay)
+				} else {
+					shard.backoff.Down(now)
+				}
+			}
+			if shard.backoff != nil && shard.backoff.Rejoin(now) {
+				rebalance = true
 			}
 		}
 
+		for _, shard := range failed {
+			c.failover(shard, opt)
+		}
+
 		if rebalance {
 			c.rebalance()
 		}
 	}
 }
 
-// rebalance removes dead shards from the Ring.
+// rebalance removes dead shards from the Ring and adds back the ones that
+// came up, once their reconnect backoff passed. Only the shards whose state
+// changed are touched in the hash.
 func (c *ringShards) rebalance() {
-	hash := consistenthash.New(nreplicas, nil)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return
+	}
+
+	var up, down []string
 	for name, shard := range c.shards {
-		if shard.IsUp() {
-			hash.Add(name)
+		inHash := c.hash.Has(name)
+		if shard.IsUp() && (shard.backoff == nil || !shard.backoff.Waiting()) {
+			if !inHash {
+				up = append(up, name)
+			}
+		} else if inHash {
+			down = append(down, name)
 		}
 	}
-
-	c.mu.Lock()
-	c.hash = hash
-	c.mu.Unlock()
+	c.hash.Remove(down...)
+	c.hash.Add(up...)
 }
 
 func (c *ringShards) Close() error {
@@ -261,6 +766,7 @@ func (c *ringShards) Close() error {
 
 	var firstErr error
 	for _, shard := range c.shards {
+		shard.closeHeartbeatConn()
 		if err := shard.Client.Close(); err != nil && firstErr == nil {
 			firstErr = err
 		}
@@ -290,14 +796,19 @@ func (c *ringShards) Close() error {
 // Otherwise you should use Redis Cluster.
 type Ring struct {
 	cmdable
+	hooks
 
 	ctx context.Context
 
 	opt           *RingOptions
 	shards        *ringShards
 	cmdsInfoCache *cmdsInfoCache
+	singleflight  *SingleflightPipeline
+	shadow        *Ring
+	shadowSem     chan struct{}
 
-	processPipeline func([]Cmder) error
+	processPipeline   func([]Cmder) error
+	processTxPipeline func([]Cmder) error
 }
 
 func NewRing(opt *RingOptions) *Ring {
@@ -305,20 +816,26 @@ func NewRing(opt *RingOptions) *Ring {
 
 	ring := &Ring{
 		opt:    opt,
-		shards: newRingShards(),
+		shards: newRingShards(opt),
 	}
 	ring.cmdsInfoCache = newCmdsInfoCache(ring.cmdsInfo)
+	if opt.EnableCommandCoalescing {
+		ring.singleflight = NewSingleflightPipeline(opt.CoalescedCommands)
+	}
+	if opt.ShadowMode != nil {
+		ring.shadow = NewRing(opt.ShadowMode)
+		ring.shadowSem = make(chan struct{}, opt.ShadowMaxInFlight)
+	}
 
 	ring.processPipeline = ring.defaultProcessPipeline
+	ring.processTxPipeline = ring.defaultProcessTxPipeline
 	ring.cmdable.setProcessor(ring.Process)
 
 	for name, addr := range opt.Addrs {
-		clopt := opt.clientOptions()
-		clopt.Addr = addr
-		ring.shards.Add(name, NewClient(clopt))
+		ring.shards.Add(name, NewClient(opt.shardOptions(name, addr, opt.Replicas[name])))
 	}
 
-	go ring.shards.Heartbeat(opt.HeartbeatFrequency)
+	go ring.shards.Heartbeat(opt)
 
 	return ring
 }
@@ -341,6 +858,8 @@ func (c *Ring) WithContext(ctx context.Context) *Ring {
 
 func (c *Ring) copy() *Ring {
 	cp := *c
+	// commands of the copy must go through its Process, which reads its ctx
+	cp.cmdable.setProcessor(cp.Process)
 	return &cp
 }
 
@@ -362,12 +881,71 @@ func (c *Ring) PoolStats() *PoolStats {
 		acc.Hits += s.Hits
 		acc.Misses += s.Misses
 		acc.Timeouts += s.Timeouts
+		acc.WaitCount += s.WaitCount
+		acc.WaitDuration += s.WaitDuration
 		acc.TotalConns += s.TotalConns
 		acc.FreeConns += s.FreeConns
+		acc.ConnAgeEvictions += s.ConnAgeEvictions
+	}
+	return &acc
+}
+
+// CacheStats returns the cache counters summed over the shards,
+// see RingOptions.Cache.
+func (c *Ring) CacheStats() *CacheStats {
+	var acc CacheStats
+	for _, shard := range c.shards.List() {
+		s := shard.Client.CacheStats()
+		acc.Hits += s.Hits
+		acc.Misses += s.Misses
+		acc.Invalidations += s.Invalidations
 	}
 	return &acc
 }
 
+// InfoStats runs INFO on every live shard and returns the parsed stats
+// keyed by shard name. Shards that fail are left out and the first error
+// is returned along with the stats of the other shards.
+func (c *Ring) InfoStats(section ...string) (map[string]*InfoStats, error) {
+	stats := make(map[string]*InfoStats)
+	var firstErr error
+	for name, shard := range c.shards.Named() {
+		if shard.IsDown() {
+			continue
+		}
+		st, err := shard.Client.InfoMap(section...).Stats()
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		stats[name] = st
+	}
+	return stats, firstErr
+}
+
+// ShardByName returns the client of the shard configured under name in
+// RingOptions.Addrs. The client is the one the ring uses, so it must not
+// be closed by the caller.
+func (c *Ring) ShardByName(name string) (*Client, error) {
+	shard, err := c.shards.GetByName(name)
+	if err != nil {
+		return nil, err
+	}
+	return shard.Client, nil
+}
+
+// ShardByAddr is like ShardByName, but looks the shard up by its
+// configured host:port address.
+func (c *Ring) ShardByAddr(addr string) (*Client, error) {
+	shard, err := c.shards.GetByAddr(addr)
+	if err != nil {
+		return nil, err
+	}
+	return shard.Client, nil
+}
+
 // Subscribe subscribes the client to the specified channels.
 func (c *Ring) Subscribe(channels ...string) *PubSub {
 	if len(channels) == 0 {
@@ -399,8 +977,25 @@ func (c *Ring) PSubscribe(channels ...string) *PubSub {
 // ForEachShard concurrently calls the fn on each live shard in the ring.
 // It returns the first error if any.
 func (c *Ring) ForEachShard(fn func(client *Client) error) error {
+	_, err := c.ForEachShardContext(context.Background(), func(_ context.Context, client *Client) error {
+		return fn(client)
+	})
+	return err
+}
+
+// ForEachShardContext concurrently calls the fn on each live shard in the ring.
+// The context passed to fn is cancelled as soon as one of the calls fails,
+// so the remaining calls can stop early. It waits for all calls to return
+// and returns the number of calls that succeeded and the first error if any.
+func (c *Ring) ForEachShardContext(
+	ctx context.Context, fn func(ctx context.Context, client *Client) error,
+) (int, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
 	shards := c.shards.List()
 	var wg sync.WaitGroup
+	var completed int32
 	errCh := make(chan error, 1)
 	for _, shard := range shards {
 		if shard.IsDown() {
@@ -410,25 +1005,62 @@ func (c *Ring) ForEachShard(fn func(client *Client) error) error {
 		wg.Add(1)
 		go func(shard *ringShard) {
 			defer wg.Done()
-			err := fn(shard.Client)
+			err := fn(ctx, shard.Client)
 			if err != nil {
 				select {
 				case errCh <- err:
+					cancel()
 				default:
 				}
+				return
 			}
+			atomic.AddInt32(&completed, 1)
 		}(shard)
 	}
 	wg.Wait()
 
 	select {
 	case err := <-errCh:
-		return err
+		return int(completed), err
 	default:
-		return nil
+		return int(completed), nil
 	}
 }
 
+// WarmUp concurrently sends PING to every shard so the first connection of
+// each shard is dialed before the ring is used. It waits until all shards
+// respond or ctx is done, and returns an error listing the shards that failed.
+func (c *Ring) WarmUp(ctx context.Context) error {
+	shards := c.shards.List()
+	errCh := make(chan error, len(shards))
+	for _, shard := range shards {
+		go func(shard *ringShard) {
+			err := shard.Client.Ping().Err()
+			if err != nil {
+				err = fmt.Errorf("%s: %s", shard.Client.opt.Addr, err)
+			}
+			errCh <- err
+		}(shard)
+	}
+
+	var failed []string
+	for i := 0; i < len(shards); i++ {
+		select {
+		case err := <-errCh:
+			if err != nil {
+				failed = append(failed, err.Error())
+			}
+		case <-ctx.Done():
+			return fmt.Errorf("redis: ring warm-up: %s, %d of %d shards did not respond",
+				ctx.Err(), len(shards)-i, len(shards))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("redis: ring warm-up failed: %s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
 func (c *Ring) cmdsInfo() (map[string]*CommandInfo, error) {
 	shards := c.shards.List()
 	firstErr := errRingShardsDown
@@ -451,7 +1083,7 @@ func (c *Ring) cmdInfo(name string) *CommandInfo {
 	}
 	info := cmdsInfo[name]
 	if info == nil {
-		internal.Logf("info for cmd=%s not found", name)
+		internal.Limitf("info for cmd=%s not found", name)
 	}
 	return info
 }
@@ -463,9 +1095,43 @@ func (c *Ring) cmdShard(cmd Cmder) (*ringShard, error) {
 		return c.shards.Random()
 	}
 	firstKey := cmd.stringArg(pos)
+	keys := cmdKeys(cmd, cmdInfo)
+	if cmd.Name() == "copy" {
+		// COMMAND of servers older than 6.2 does not know COPY.
+		keys = []string{firstKey, cmd.stringArg(pos + 1)}
+	}
+	if len(keys) > 1 {
+		name, other, err := c.shards.PlaceKeys(keys)
+		if err != nil {
+			return nil, err
+		}
+		if other != "" {
+			return nil, fmt.Errorf("redis: %s keys must be on the same ring shard, %q is not on the shard of %q", cmd.Name(), other, firstKey)
+		}
+		return c.shards.GetByHash(name)
+	}
 	return c.shards.GetByKey(firstKey)
 }
 
+// SameShard reports whether keys, after applying their hash tags, are on the
+// same shard, so a multi-key command on them reads and writes them all. The
+// string is the name of the shard if they are, and the first key on another
+// shard than keys[0] if they are not. With RingOptions.BoundedLoadFactor the
+// shard of a key is the one a command on it would be sent to at the moment.
+func (c *Ring) SameShard(keys ...string) (bool, string, error) {
+	if len(keys) == 0 {
+		return false, "", fmt.Errorf("redis: SameShard requires at least one key")
+	}
+	name, other, err := c.shards.SameShard(keys)
+	if err != nil {
+		return false, "", err
+	}
+	if other != "" {
+		return false, other, nil
+	}
+	return true, name, nil
+}
+
 func (c *Ring) WrapProcess(fn func(oldProcess func(cmd Cmder) error) func(cmd Cmder) error) {
 	c.ForEachShard(func(c *Client) error {
 		c.WrapProcess(fn)
@@ -474,17 +1140,78 @@ func (c *Ring) WrapProcess(fn func(oldProcess func(cmd Cmder) error) func(cmd Cm
 }
 
 func (c *Ring) Process(cmd Cmder) error {
+	ctx := c.Context()
 	shard, err := c.cmdShard(cmd)
-	if err != nil {
-		cmd.setErr(err)
-		return err
+	if err == nil {
+		ctx = context.WithValue(ctx, shardAddrKey{}, shard.Client.opt.Addr)
+	}
+	process := func(cmd Cmder) error {
+		if err != nil {
+			cmd.setErr(err)
+			return err
+		}
+		if c.opt.RetryOnDifferentShard && c.opt.MaxRetries > 0 {
+			cmdInfo := c.cmdInfo(cmd.Name())
+			if pos := cmdFirstKeyPos(cmd, cmdInfo); pos > 0 && cmdInfo != nil && cmdInfo.ReadOnly {
+				return c.processRetrying(shard, cmd, cmd.stringArg(pos))
+			}
+		}
+		return c.processOnShard(shard, cmd)
 	}
-	return shard.Client.Process(cmd)
+	if c.shadow != nil {
+		return c.hooks.process(ctx, cmd, func(cmd Cmder) error {
+			return c.shadowProcess(cmd, process)
+		})
+	}
+	return c.hooks.process(ctx, cmd, process)
+}
+
+func (c *Ring) processOnShard(shard *ringShard, cmd Cmder) error {
+	client := shard.Client
+	if c.ctx != nil {
+		// e.g. WithMasterOnly or WithoutCache.
+		client = client.WithContext(c.ctx)
+	}
+	if c.singleflight != nil {
+		return c.singleflight.Process(client.opt.Addr, cmd, client.Process)
+	}
+	return client.Process(cmd)
+}
+
+// processRetrying runs the read-only cmd on the shard of key, see
+// RingOptions.RetryOnDifferentShard.
+func (c *Ring) processRetrying(shard *ringShard, cmd Cmder, key string) error {
+	perShard := c.opt.MaxRetries / 2
+	if perShard < 1 {
+		perShard = 1
+	}
+	var err error
+	failures, next := 0, 0
+	for attempt := 0; attempt <= c.opt.MaxRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(c.retryBackoff(attempt))
+		}
+		if failures == perShard {
+			failures = 0
+			next++
+			if nextShard, err := c.shards.GetNextByKey(key, next); err == nil {
+				shard = nextShard
+			}
+		}
+		err = c.processOnShard(shard, cmd)
+		if err == nil || !internal.IsRetryableError(err, cmd.readTimeout() == nil) {
+			return err
+		}
+		failures++
+	}
+	return err
 }
 
 func (c *Ring) Pipeline() Pipeliner {
 	pipe := Pipeline{
-		exec: c.processPipeline,
+		exec: func(cmds []Cmder) error {
+			return c.hooks.processPipeline(c.Context(), cmds, c.processPipeline)
+		},
 	}
 	pipe.cmdable.setProcessor(pipe.Process)
 	return &pipe
@@ -506,7 +1233,7 @@ func (c *Ring) defaultProcessPipeline(cmds []Cmder) error {
 		cmdInfo := c.cmdInfo(cmd.Name())
 		hash := cmd.stringArg(cmdFirstKeyPos(cmd, cmdInfo))
 		if hash != "" {
-			hash = c.shards.Hash(hashtag.Key(hash))
+			hash = c.shards.Place(hashtag.Key(hash))
 		}
 		cmdsMap[hash] = append(cmdsMap[hash], cmd)
 	}
@@ -525,13 +1252,16 @@ func (c *Ring) defaultProcessPipeline(cmds []Cmder) error {
 				continue
 			}
 
-			cn, _, err := shard.Client.getConn()
+			start := time.Now()
+			cn, _, err := shard.Client.getConn(context.Background())
 			if err != nil {
 				setCmdsErr(cmds, err)
+				shard.Client.observe(start, attempt, true, cmds...)
 				continue
 			}
 
 			canRetry, err := shard.Client.pipelineProcessCmds(cn, cmds)
+			shard.Client.observe(start, attempt, true, cmds...)
 			if err == nil || internal.IsRedisError(err) {
 				_ = shard.Client.connPool.Put(cn)
 				continue
@@ -555,12 +1285,58 @@ func (c *Ring) defaultProcessPipeline(cmds []Cmder) error {
 	return firstCmdsErr(cmds)
 }
 
+// TxPipeline acts like Pipeline, but wraps queued commands with MULTI/EXEC
+// on the shard of their keys.
+//
+// Cross-shard transactions are not supported: if a command has a key on
+// another shard than the first keyed command, Exec sends nothing and
+// returns ErrCrossShardTransaction. Give the keys of a transaction a common
+// hash tag, e.g. {user1}:name and {user1}:email, to keep them on one shard.
 func (c *Ring) TxPipeline() Pipeliner {
-	panic("not implemented")
+	pipe := Pipeline{
+		exec: func(cmds []Cmder) error {
+			return c.hooks.processPipeline(c.Context(), cmds, c.processTxPipeline)
+		},
+	}
+	pipe.cmdable.setProcessor(pipe.Process)
+	return &pipe
 }
 
 func (c *Ring) TxPipelined(fn func(Pipeliner) error) ([]Cmder, error) {
-	panic("not implemented")
+	return c.TxPipeline().Pipelined(fn)
+}
+
+func (c *Ring) WrapProcessTxPipeline(
+	fn func(oldProcess func([]Cmder) error) func([]Cmder) error,
+) {
+	c.processTxPipeline = fn(c.processTxPipeline)
+}
+
+func (c *Ring) defaultProcessTxPipeline(cmds []Cmder) error {
+	var keys []string
+	for _, cmd := range cmds {
+		keys = append(keys, cmdKeys(cmd, c.cmdInfo(cmd.Name()))...)
+	}
+
+	var shard *ringShard
+	var err error
+	if len(keys) == 0 {
+		shard, err = c.shards.Random()
+	} else {
+		var name, other string
+		name, other, err = c.shards.PlaceKeys(keys)
+		if err == nil && other != "" {
+			err = ErrCrossShardTransaction
+		}
+		if err == nil {
+			shard, err = c.shards.GetByHash(name)
+		}
+	}
+	if err != nil {
+		setCmdsErr(cmds, err)
+		return err
+	}
+	return shard.Client.processTxPipeline(cmds)
 }
 
 // Close closes the ring client, releasing any open resources.
@@ -568,5 +1344,8 @@ func (c *Ring) TxPipelined(fn func(Pipeliner) error) ([]Cmder, error) {
 // It is rare to Close a Ring, as the Ring is meant to be long-lived
 // and shared between many goroutines.
 func (c *Ring) Close() error {
+	if c.shadow != nil {
+		_ = c.shadow.Close()
+	}
 	return c.shards.Close()
 }
@@ -0,0 +1,287 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"EasyDarwin/helper/go-redis/redis/internal/pool"
+)
+
+// fakeResolver answers the lookups of refreshAddrs with the next of its
+// answers, the last one repeated.
+func fakeResolver(t *testing.T, answers ...[]string) {
+	saved := lookupHost
+	t.Cleanup(func() { lookupHost = saved })
+	lookupHost = func(host string) ([]string, error) {
+		if host != "redis.test" {
+			t.Fatalf("lookup of %s, want redis.test", host)
+		}
+		addrs := answers[0]
+		if len(answers) > 1 {
+			answers = answers[1:]
+		}
+		return append([]string(nil), addrs...), nil
+	}
+}
+
+// newIdleShard returns a shard of redis.test with one idle connection.
+func newIdleShard(t *testing.T) (*ringShard, *pool.ConnPool) {
+	client := NewClient(&Options{
+		Addr: "redis.test:6379",
+		Dialer: func() (net.Conn, error) {
+			cn, _ := net.Pipe()
+			return cn, nil
+		},
+	})
+	t.Cleanup(func() { client.Close() })
+	connPool := client.connPool.(*pool.ConnPool)
+	cn, _, err := connPool.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	connPool.Put(cn)
+	return &ringShard{Client: client}, connPool
+}
+
+func TestRingShardRefreshAddrsRecyclesOnChange(t *testing.T) {
+	fakeResolver(t, []string{"10.0.0.1", "10.0.0.2"}, []string{"10.0.0.3"})
+	shard, connPool := newIdleShard(t)
+
+	shard.refreshAddrs()
+	if connPool.FreeLen() != 1 {
+		t.Fatalf("free conns = %d after the first lookup, want 1", connPool.FreeLen())
+	}
+	// the change must show up in two checks in a row
+	shard.refreshAddrs()
+	if connPool.FreeLen() != 1 {
+		t.Fatalf("free conns = %d after one changed lookup, want 1", connPool.FreeLen())
+	}
+	shard.refreshAddrs()
+	if connPool.FreeLen() != 0 {
+		t.Fatalf("free conns = %d after the change persisted, want the pool recycled", connPool.FreeLen())
+	}
+	if len(shard.addrs) != 1 || shard.addrs[0] != "10.0.0.3" {
+		t.Fatalf("addrs = %v", shard.addrs)
+	}
+}
+
+func TestRingShardRefreshAddrsIgnoresFlapping(t *testing.T) {
+	fakeResolver(t, []string{"10.0.0.1"}, []string{"10.0.0.2"}, []string{"10.0.0.1"}, []string{"10.0.0.2"}, []string{"10.0.0.1"})
+	shard, connPool := newIdleShard(t)
+
+	for i := 0; i < 5; i++ {
+		shard.refreshAddrs()
+	}
+	if connPool.FreeLen() != 1 {
+		t.Fatalf("free conns = %d, want the pool kept while the resolver flaps", connPool.FreeLen())
+	}
+}
+
+func TestRingShardRefreshAddrsSortsAnswers(t *testing.T) {
+	fakeResolver(t, []string{"10.0.0.2", "10.0.0.1"}, []string{"10.0.0.1", "10.0.0.2"})
+	shard, connPool := newIdleShard(t)
+
+	for i := 0; i < 3; i++ {
+		shard.refreshAddrs()
+	}
+	if connPool.FreeLen() != 1 {
+		t.Fatalf("free conns = %d, want the pool kept when only the order changed", connPool.FreeLen())
+	}
+}
+
+func TestRingHeartbeatTimeoutDetectsStalledShard(t *testing.T) {
+	stalled, done := make(chan struct{}), make(chan struct{})
+	srv := newFakeServer(t, func(conn int, args []string) string {
+		select {
+		case <-stalled:
+			// no answer until the end of the test, like a shard stuck in
+			// a long command
+			<-done
+		default:
+		}
+		return "+PONG\r\n"
+	})
+	defer close(done)
+	const timeout = 100 * time.Millisecond
+	ring := NewRing(&RingOptions{
+		Addrs:              map[string]string{"shard1": srv.Addr},
+		HeartbeatFrequency: 10 * time.Millisecond,
+		HeartbeatTimeout:   timeout,
+	})
+	defer ring.Close()
+	shard, err := ring.shards.GetByName("shard1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if !shard.IsUp() {
+		t.Fatal("shard down before the stall")
+	}
+
+	close(stalled)
+	start := time.Now()
+	for shard.IsUp() {
+		if time.Since(start) > 10*timeout {
+			t.Fatalf("shard still up %v after the stall", time.Since(start))
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	// three failed PINGs, each one ended by the timeout
+	if elapsed := time.Since(start); elapsed > 3*timeout+200*time.Millisecond {
+		t.Fatalf("stall detected in %v, want about 3×%v", elapsed, timeout)
+	}
+}
+
+func TestRingForEachShardContextCancelsOthers(t *testing.T) {
+	ring := NewRing(&RingOptions{
+		Addrs: map[string]string{"shard1": "127.0.0.1:1", "shard2": "127.0.0.1:2", "shard3": "127.0.0.1:3"},
+	})
+	defer ring.Close()
+
+	failure := errors.New("shard1 failed")
+	var cancelled int32
+	n, err := ring.ForEachShardContext(context.Background(), func(ctx context.Context, client *Client) error {
+		if client.Options().Addr == "127.0.0.1:1" {
+			return failure
+		}
+		select {
+		case <-ctx.Done():
+			atomic.AddInt32(&cancelled, 1)
+			return ctx.Err()
+		case <-time.After(5 * time.Second):
+			return nil
+		}
+	})
+	if err != failure {
+		t.Fatalf("err = %v, want the first failure", err)
+	}
+	if n != 0 || cancelled != 2 {
+		t.Fatalf("completed %d, cancelled %d, want 0 and 2", n, cancelled)
+	}
+
+	n, err = ring.ForEachShardContext(context.Background(), func(ctx context.Context, client *Client) error {
+		return ctx.Err()
+	})
+	if n != 3 || err != nil {
+		t.Fatalf("completed %d err %v, want 3 and nil", n, err)
+	}
+}
+
+func TestRingShardByNameAndAddr(t *testing.T) {
+	ring := NewRing(&RingOptions{
+		Addrs: map[string]string{"shard1": "127.0.0.1:1", "shard2": "127.0.0.1:2"},
+	})
+
+	client, err := ring.ShardByName("shard2")
+	if err != nil || client.Options().Addr != "127.0.0.1:2" {
+		t.Fatalf("ShardByName = %v, %v", client, err)
+	}
+	byAddr, err := ring.ShardByAddr("127.0.0.1:2")
+	if err != nil || byAddr != client {
+		t.Fatalf("ShardByAddr = %v, %v, want the client of shard2", byAddr, err)
+	}
+	if _, err := ring.ShardByName("shard3"); err != ErrShardNotFound {
+		t.Fatalf("ShardByName of an unknown shard err = %v", err)
+	}
+	if _, err := ring.ShardByAddr("127.0.0.1:3"); err != ErrShardNotFound {
+		t.Fatalf("ShardByAddr of an unknown address err = %v", err)
+	}
+
+	ring.Close()
+	if _, err := ring.ShardByName("shard1"); err != pool.ErrClosed {
+		t.Fatalf("ShardByName after Close err = %v", err)
+	}
+	if _, err := ring.ShardByAddr("127.0.0.1:1"); err != pool.ErrClosed {
+		t.Fatalf("ShardByAddr after Close err = %v", err)
+	}
+}
+
+func TestRingSameShard(t *testing.T) {
+	handle := func(conn int, args []string) string {
+		if strings.EqualFold(args[0], "command") {
+			return respCommandInfo("mget readonly 1 -1")
+		}
+		return "+OK\r\n"
+	}
+	srv1, srv2 := newFakeServer(t, handle), newFakeServer(t, handle)
+	ring := NewRing(&RingOptions{
+		Addrs: map[string]string{"shard1": srv1.Addr, "shard2": srv2.Addr},
+	})
+	defer ring.Close()
+
+	same, name, err := ring.SameShard("{user}.a", "x{user}y", "a{user}c{d}")
+	if !same || err != nil || (name != "shard1" && name != "shard2") {
+		t.Fatalf("SameShard = %v, %q, %v", same, name, err)
+	}
+	// the first key hashed to another shard than "a"
+	other := ""
+	for i := 0; other == ""; i++ {
+		key := "b" + strconv.Itoa(i)
+		if ring.shards.Hash(key) != ring.shards.Hash("a") {
+			other = key
+		}
+	}
+	same, name, err = ring.SameShard("a", "{a}b", other)
+	if same || name != other || err != nil {
+		t.Fatalf("SameShard = %v, %q, %v, want %q out of place", same, name, err, other)
+	}
+	if _, _, err := ring.SameShard(); err == nil {
+		t.Fatal("SameShard without keys succeeded")
+	}
+
+	err = ring.MGet("a", other).Err()
+	if err == nil || !strings.Contains(err.Error(), other) {
+		t.Fatalf("MGet across shards err = %v, want %q named", err, other)
+	}
+	for _, srv := range []*fakeServer{srv1, srv2} {
+		for _, cmd := range srv.Cmds() {
+			if cmd.Args[0] == "mget" {
+				t.Fatal("MGet across shards sent")
+			}
+		}
+	}
+}
+
+func TestRingSameShardBoundedLoads(t *testing.T) {
+	handle := func(conn int, args []string) string {
+		switch strings.ToLower(args[0]) {
+		case "command":
+			return respCommandInfo("mget readonly 1 -1")
+		case "mget":
+			return respArray(respBulk("1"), respBulk("2"))
+		}
+		return "+OK\r\n"
+	}
+	srv1, srv2 := newFakeServer(t, handle), newFakeServer(t, handle)
+	ring := NewRing(&RingOptions{
+		Addrs:             map[string]string{"shard1": srv1.Addr, "shard2": srv2.Addr},
+		BoundedLoadFactor: 1.25,
+	})
+	defer ring.Close()
+
+	// the owner of "a" is over its bound, commands on "a" go to the other
+	// shard and so must the check of a multi-key command
+	owner := ring.shards.Hash("a")
+	ring.shards.bounded.AddLoad(owner, 100)
+	same, name, err := ring.SameShard("a", "{a}b")
+	if !same || err != nil || name == owner {
+		t.Fatalf("SameShard = %v, %q, %v, want the shard other than the owner %q", same, name, err, owner)
+	}
+	if err := ring.MGet("a", "{a}b").Err(); err != nil {
+		t.Fatal(err)
+	}
+	srv := map[string]*fakeServer{"shard1": srv1, "shard2": srv2}[name]
+	var sent bool
+	for _, cmd := range srv.Cmds() {
+		sent = sent || cmd.Args[0] == "mget"
+	}
+	if !sent {
+		t.Fatalf("MGet not sent to %s", name)
+	}
+}
@@ -1,6 +1,7 @@
 package redis
 
 import (
+	"context"
 	"crypto/tls"
 	"errors"
 	"net"
@@ -24,7 +25,8 @@ type FailoverOptions struct {
 
 	// Following options are copied from Options struct.
 
-	OnConnect func(*Conn) error
+	OnConnect        func(*Conn) error
+	OnConnectContext func(ctx context.Context, cn *Conn, addr string) error
 
 	Password string
 	DB       int
@@ -47,7 +49,8 @@ func (opt *FailoverOptions) options() *Options {
 	return &Options{
 		Addr: "FailoverClient",
 
-		OnConnect: opt.OnConnect,
+		OnConnect:        opt.OnConnect,
+		OnConnectContext: opt.OnConnectContext,
 
 		DB:       opt.DB,
 		Password: opt.Password,
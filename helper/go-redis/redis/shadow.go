@@ -0,0 +1,79 @@
+package redis
+
+import (
+	"reflect"
+
+	"EasyDarwin/helper/go-redis/redis/internal"
+)
+
+// shadowProcess sends cmd to the primary ring with fn and a copy of it to the
+// shadow ring. Writes are sent to the shadow ring without waiting for it.
+// Reads are served from the primary ring; the shadow reply is compared in the
+// background and a mismatch is logged. With ShadowMaxInFlight commands
+// already in flight to the shadow ring, cmd is not shadowed.
+func (c *Ring) shadowProcess(cmd Cmder, fn func(Cmder) error) error {
+	shadowCmd, ok := cloneCmd(cmd)
+	if !ok {
+		return fn(cmd)
+	}
+	name := cmd.Name()
+	select {
+	case c.shadowSem <- struct{}{}:
+	default:
+		internal.Limitf("ring shadow: %d commands in flight, %s not shadowed", cap(c.shadowSem), name)
+		return fn(cmd)
+	}
+
+	if info := c.cmdInfo(name); info == nil || !info.ReadOnly {
+		go func() {
+			defer func() { <-c.shadowSem }()
+			if err := c.shadow.Process(shadowCmd); err != nil && err != Nil {
+				internal.Limitf("ring shadow: %s failed: %s", name, err)
+			}
+		}()
+		return fn(cmd)
+	}
+
+	want := make(chan string, 1)
+	go func() {
+		defer func() { <-c.shadowSem }()
+		_ = c.shadow.Process(shadowCmd)
+		primary, shadow := <-want, shadowCmd.String()
+		if primary != shadow {
+			internal.Limitf("ring shadow: mismatch: primary=%q shadow=%q", primary, shadow)
+		}
+	}()
+	err := fn(cmd)
+	// The caller may reuse cmd once Process returns, so the reply is
+	// captured here rather than in the goroutine.
+	want <- cmd.String()
+	return err
+}
+
+// cloneCmd returns an unprocessed copy of cmd of the same type. The copy
+// has its own arguments, as Name writes the first one.
+func cloneCmd(cmd Cmder) (Cmder, bool) {
+	// A copy would write the reply to the same writer.
+	if _, ok := cmd.(*StreamCmd); ok {
+		return nil, false
+	}
+	v := reflect.ValueOf(cmd)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return nil, false
+	}
+	clone := reflect.New(v.Elem().Type())
+	clone.Elem().Set(v.Elem())
+	cloned, ok := clone.Interface().(Cmder)
+	if !ok {
+		return nil, false
+	}
+	cloned.setErr(nil)
+	if args, ok := cloned.(interface{ cloneArgs() }); ok {
+		args.cloneArgs()
+	}
+	return cloned, true
+}
+
+func (cmd *baseCmd) cloneArgs() {
+	cmd._args = append([]interface{}(nil), cmd._args...)
+}
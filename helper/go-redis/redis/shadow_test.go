@@ -0,0 +1,103 @@
+package redis
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCloneCmdCopiesArgs(t *testing.T) {
+	cmd := NewStringCmd("GET", "key")
+	clone, ok := cloneCmd(cmd)
+	if !ok {
+		t.Fatal("not cloned")
+	}
+	clone.Args()[1] = "other"
+	if clone.Name() != "get" || cmd.Args()[0] != "GET" || cmd.Args()[1] != "key" {
+		t.Fatalf("original args %v after changing the clone", cmd.Args())
+	}
+	if _, ok := cloneCmd(NewStreamCmd(nil, "get", "key")); ok {
+		t.Fatal("StreamCmd cloned")
+	}
+}
+
+func TestRingShadowWrites(t *testing.T) {
+	primary := newFakeServer(t, nil)
+	shadow := newFakeServer(t, nil)
+	ring := NewRing(&RingOptions{
+		Addrs:      map[string]string{"shard1": primary.Addr},
+		ShadowMode: &RingOptions{Addrs: map[string]string{"shard1": shadow.Addr}},
+	})
+	defer ring.Close()
+
+	// the primary and the shadow process the commands at the same time
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				if err := ring.Set("key", "value", 0).Err(); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	deadline := time.Now().Add(5 * time.Second)
+	for countFakeCmds(shadow, "set") < 160 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := countFakeCmds(shadow, "set"); n != 160 {
+		t.Fatalf("shadow got %d SET, want 160", n)
+	}
+}
+
+func TestRingShadowMaxInFlight(t *testing.T) {
+	primary := newFakeServer(t, nil)
+	release := make(chan struct{})
+	var stalled int32
+	shadow := newFakeServer(t, func(conn int, args []string) string {
+		if strings.EqualFold(args[0], "set") {
+			atomic.AddInt32(&stalled, 1)
+			<-release
+		}
+		return "+OK\r\n"
+	})
+	t.Cleanup(func() { close(release) })
+	ring := NewRing(&RingOptions{
+		Addrs:             map[string]string{"shard1": primary.Addr},
+		ShadowMode:        &RingOptions{Addrs: map[string]string{"shard1": shadow.Addr}},
+		ShadowMaxInFlight: 2,
+	})
+	defer ring.Close()
+
+	start := time.Now()
+	for i := 0; i < 10; i++ {
+		if err := ring.Set("key", "value", 0).Err(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("writes held up %v by the shadow ring", d)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if n := atomic.LoadInt32(&stalled); n != 2 {
+		t.Fatalf("%d commands in flight to the shadow ring, want 2", n)
+	}
+	if n := countFakeCmds(primary, "set"); n != 10 {
+		t.Fatalf("primary got %d SET, want 10", n)
+	}
+}
+
+func countFakeCmds(srv *fakeServer, name string) (n int) {
+	for _, cmd := range srv.Cmds() {
+		if strings.EqualFold(cmd.Args[0], name) {
+			n++
+		}
+	}
+	return
+}
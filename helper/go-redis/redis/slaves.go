@@ -0,0 +1,156 @@
+package redis
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"EasyDarwin/helper/go-redis/redis/internal"
+)
+
+const slaveHeartbeatFrequency = 500 * time.Millisecond
+
+type masterOnlyKey struct{}
+
+// WithMasterOnly returns a context that makes a Client with SlaveAddrs send
+// every command to the master, e.g. client.WithContext(WithMasterOnly(ctx)).
+func WithMasterOnly(ctx context.Context) context.Context {
+	return context.WithValue(ctx, masterOnlyKey{}, true)
+}
+
+func isMasterOnly(ctx context.Context) bool {
+	masterOnly, _ := ctx.Value(masterOnlyKey{}).(bool)
+	return masterOnly
+}
+
+// clientSlaves routes the read-only commands of a Client to its replicas.
+// Replica health is tracked with the votes of ringShard.
+type clientSlaves struct {
+	slaves        []*ringShard
+	next          uint32
+	random        bool
+	allowed       map[string]struct{} // nil allows every read-only command
+	cmdsInfoCache *cmdsInfoCache
+
+	closeOnce sync.Once
+	closing   chan struct{}
+}
+
+func newClientSlaves(c *Client) *clientSlaves {
+	opt := c.opt
+	s := &clientSlaves{
+		random:  opt.randomSlave,
+		closing: make(chan struct{}),
+	}
+	for _, addr := range opt.SlaveAddrs {
+		slaveOpt := *opt
+		slaveOpt.Addr = addr
+		// The default Dialer set by init dials opt.Addr.
+		slaveOpt.Dialer = nil
+		slaveOpt.SlaveAddrs = nil
+		// Writes are seen by the cache of the master client.
+		slaveOpt.Cache = nil
+		s.slaves = append(s.slaves, &ringShard{Client: NewClient(&slaveOpt)})
+	}
+	if len(opt.ReadOnlyCommandsOnly) > 0 {
+		s.allowed = make(map[string]struct{}, len(opt.ReadOnlyCommandsOnly))
+		for _, name := range opt.ReadOnlyCommandsOnly {
+			s.allowed[internal.ToLower(name)] = struct{}{}
+		}
+	}
+	s.cmdsInfoCache = newCmdsInfoCache(func() (map[string]*CommandInfo, error) {
+		// Bypass routing, it needs this info.
+		cmd := NewCommandsInfoCmd("command")
+		_ = c.baseClient.Process(cmd)
+		return cmd.Result()
+	})
+	go s.heartbeat()
+	return s
+}
+
+func (s *clientSlaves) isReadOnly(name string) bool {
+	if s.allowed != nil {
+		if _, ok := s.allowed[name]; !ok {
+			return false
+		}
+	}
+	cmdsInfo, err := s.cmdsInfoCache.Get()
+	if err != nil {
+		return false
+	}
+	info := cmdsInfo[name]
+	return info != nil && info.ReadOnly
+}
+
+// pick returns the next healthy replica, or nil if all of them are down.
+func (s *clientSlaves) pick() *ringShard {
+	if s.random {
+		start := rand.Intn(len(s.slaves))
+		for i := range s.slaves {
+			slave := s.slaves[(start+i)%len(s.slaves)]
+			if slave.IsUp() {
+				return slave
+			}
+		}
+		return nil
+	}
+	for range s.slaves {
+		n := atomic.AddUint32(&s.next, 1)
+		slave := s.slaves[int(n)%len(s.slaves)]
+		if slave.IsUp() {
+			return slave
+		}
+	}
+	return nil
+}
+
+func (s *clientSlaves) process(ctx context.Context, cmd Cmder, master func(Cmder) error) error {
+	if isMasterOnly(ctx) || !s.isReadOnly(cmd.Name()) {
+		return master(cmd)
+	}
+	slave := s.pick()
+	if slave == nil {
+		return master(cmd)
+	}
+	err := slave.Client.Process(cmd)
+	if err == nil || err == Nil || internal.IsRedisError(err) {
+		return err
+	}
+	if slave.Vote(false) {
+		internal.Logf("slave %s is down", slave.Client)
+	}
+	cmd.setErr(nil)
+	return master(cmd)
+}
+
+func (s *clientSlaves) heartbeat() {
+	ticker := time.NewTicker(slaveHeartbeatFrequency)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-s.closing:
+			return
+		}
+		for _, slave := range s.slaves {
+			if slave.Vote(slave.Ping(0)) {
+				internal.Logf("slave state changed: %s", slave)
+			}
+		}
+	}
+}
+
+func (s *clientSlaves) Close() error {
+	var firstErr error
+	s.closeOnce.Do(func() {
+		close(s.closing)
+		for _, slave := range s.slaves {
+			if err := slave.Client.Close(); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	})
+	return firstErr
+}
@@ -0,0 +1,91 @@
+package redis
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// newReplicaServer returns a fake server answering GET with its name.
+func newReplicaServer(t *testing.T, name string) *fakeServer {
+	return newFakeServer(t, func(conn int, args []string) string {
+		switch strings.ToLower(args[0]) {
+		case "command":
+			return respCommandInfo("get readonly 1", "strlen readonly 1", "set write 1")
+		case "get":
+			return respBulk(name)
+		}
+		return "+OK\r\n"
+	})
+}
+
+func TestClientSlaveRouting(t *testing.T) {
+	master := newReplicaServer(t, "master")
+	slave1, slave2 := newReplicaServer(t, "slave1"), newReplicaServer(t, "slave2")
+	client := NewClient(&Options{Addr: master.Addr, SlaveAddrs: []string{slave1.Addr, slave2.Addr}})
+	defer client.Close()
+
+	var got []string
+	for i := 0; i < 4; i++ {
+		got = append(got, client.Get("key").Val())
+	}
+	if strings.Join(got, ",") != "slave2,slave1,slave2,slave1" {
+		t.Fatalf("GET served by %v, want the replicas round-robin", got)
+	}
+	if err := client.Set("key", "value", 0).Err(); err != nil {
+		t.Fatal(err)
+	}
+	for _, slave := range []*fakeServer{slave1, slave2} {
+		for _, cmd := range slave.Cmds() {
+			if cmd.Args[0] == "set" {
+				t.Fatal("SET sent to a replica")
+			}
+		}
+	}
+	if v := client.WithContext(WithMasterOnly(context.Background())).Get("key").Val(); v != "master" {
+		t.Fatalf("GET with WithMasterOnly served by %s", v)
+	}
+}
+
+func TestClientSlaveReadOnlyCommandsOnly(t *testing.T) {
+	master, slave := newReplicaServer(t, "master"), newReplicaServer(t, "slave")
+	client := NewClient(&Options{
+		Addr:                 master.Addr,
+		SlaveAddrs:           []string{slave.Addr},
+		ReadOnlyCommandsOnly: []string{"STRLEN"},
+	})
+	defer client.Close()
+
+	if v := client.Get("key").Val(); v != "master" {
+		t.Fatalf("GET served by %s, want the master", v)
+	}
+	client.StrLen("key")
+	cmds := slave.Cmds()
+	if len(cmds) != 1 || cmds[0].Args[0] != "strlen" {
+		t.Fatalf("replica commands = %v, want only STRLEN", cmds)
+	}
+}
+
+func TestClientSlaveFailover(t *testing.T) {
+	master := newReplicaServer(t, "master")
+	slave1, slave2 := newReplicaServer(t, "slave1"), newReplicaServer(t, "slave2")
+	client := NewClient(&Options{Addr: master.Addr, SlaveAddrs: []string{slave1.Addr, slave2.Addr}})
+	defer client.Close()
+
+	client.Get("key")
+	client.Get("key")
+	slave1.Close()
+	slave2.Close()
+	// the failed reads are retried on the master, and once the replicas
+	// are voted down the master serves the reads directly
+	for i := 0; i < 8; i++ {
+		if v, err := client.Get("key").Result(); v != "master" || err != nil {
+			t.Fatalf("GET %d = %q, %v, want the master", i, v, err)
+		}
+	}
+	for _, slave := range client.slaves.slaves {
+		if slave.IsUp() {
+			t.Fatalf("replica %s still up", slave)
+		}
+	}
+}
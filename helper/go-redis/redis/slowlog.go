@@ -0,0 +1,158 @@
+package redis
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Like the SLOWLOG of Redis, long commands are trimmed to slowLogMaxArgs
+// arguments of slowLogMaxArgLen bytes.
+const (
+	slowLogMaxArgs   = 32
+	slowLogMaxArgLen = 128
+)
+
+// SlowLogEntry is a command that took at least the threshold of a
+// SlowCommandLog, retries included.
+type SlowLogEntry struct {
+	Time     time.Time // when the command started
+	Shard    string    // Ring shard name, empty for other clients
+	Command  string
+	Args     []string // the arguments after the command name, trimmed
+	Duration time.Duration
+	Err      error // redis.Nil is not an error
+}
+
+// SlowCommandLog keeps the last commands slower than its threshold in a
+// fixed-capacity ring buffer. Unlike the SLOWLOG of a Redis server, it
+// gathers the commands of every shard of a Ring, timed on the client, so
+// the network and the connection pool count too.
+//
+// It is installed with WrapProcess, so it composes with the other wrappers:
+// the ones installed before it are timed with the command.
+type SlowCommandLog struct {
+	mu        sync.Mutex
+	threshold time.Duration
+	entries   []SlowLogEntry
+	next      int // index of the next entry to write
+	full      bool
+}
+
+// NewSlowCommandLog returns a log of the last capacity commands that took at
+// least threshold. A threshold <= 0 logs nothing.
+func NewSlowCommandLog(threshold time.Duration, capacity int) *SlowCommandLog {
+	if capacity <= 0 {
+		capacity = 128
+	}
+	return &SlowCommandLog{
+		threshold: threshold,
+		entries:   make([]SlowLogEntry, capacity),
+	}
+}
+
+func (l *SlowCommandLog) SlowThreshold() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.threshold
+}
+
+func (l *SlowCommandLog) SetSlowThreshold(threshold time.Duration) {
+	l.mu.Lock()
+	l.threshold = threshold
+	l.mu.Unlock()
+}
+
+// WrapRing logs the commands of every shard of ring, down or not.
+func (l *SlowCommandLog) WrapRing(ring *Ring) {
+	for _, shard := range ring.shards.List() {
+		shard.Client.WrapProcess(l.WrapProcess(shard.Client.opt.shardName))
+	}
+}
+
+// WrapProcess returns a function for Client.WrapProcess logging the slow
+// commands of the client as commands of shard.
+func (l *SlowCommandLog) WrapProcess(shard string) func(oldProcess func(cmd Cmder) error) func(cmd Cmder) error {
+	return func(oldProcess func(cmd Cmder) error) func(cmd Cmder) error {
+		return func(cmd Cmder) error {
+			start := time.Now()
+			err := oldProcess(cmd)
+			l.observe(start, shard, cmd)
+			return err
+		}
+	}
+}
+
+func (l *SlowCommandLog) observe(start time.Time, shard string, cmd Cmder) {
+	dur := time.Since(start)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.threshold <= 0 || dur < l.threshold {
+		return
+	}
+	entry := SlowLogEntry{
+		Time:     start,
+		Shard:    shard,
+		Command:  cmd.Name(),
+		Args:     slowLogArgs(cmd.Args()),
+		Duration: dur,
+	}
+	if err := cmd.Err(); err != nil && err != Nil {
+		entry.Err = err
+	}
+	l.entries[l.next] = entry
+	l.next++
+	if l.next == len(l.entries) {
+		l.next = 0
+		l.full = true
+	}
+}
+
+// Entries returns the logged commands, the latest first.
+func (l *SlowCommandLog) Entries() []SlowLogEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	n := l.next
+	if l.full {
+		n = len(l.entries)
+	}
+	entries := make([]SlowLogEntry, 0, n)
+	for i := 1; i <= n; i++ {
+		entries = append(entries, l.entries[(l.next-i+len(l.entries))%len(l.entries)])
+	}
+	return entries
+}
+
+// Reset drops every logged command.
+func (l *SlowCommandLog) Reset() {
+	l.mu.Lock()
+	for i := range l.entries {
+		l.entries[i] = SlowLogEntry{}
+	}
+	l.next = 0
+	l.full = false
+	l.mu.Unlock()
+}
+
+// slowLogArgs formats the arguments after the command name.
+func slowLogArgs(args []interface{}) []string {
+	if len(args) > 0 {
+		args = args[1:]
+	}
+	n := len(args)
+	if n > slowLogMaxArgs {
+		n = slowLogMaxArgs - 1
+	}
+	strs := make([]string, 0, n+1)
+	for _, arg := range args[:n] {
+		s := fmt.Sprint(arg)
+		if len(s) > slowLogMaxArgLen {
+			s = fmt.Sprintf("%s... (%d more bytes)", s[:slowLogMaxArgLen], len(s)-slowLogMaxArgLen)
+		}
+		strs = append(strs, s)
+	}
+	if n < len(args) {
+		strs = append(strs, fmt.Sprintf("... (%d more arguments)", len(args)-n))
+	}
+	return strs
+}
@@ -0,0 +1,106 @@
+package redis
+
+import "sync/atomic"
+
+// cmdStats counts commands sent through Process. It is only allocated
+// when Options.CommandStats is set.
+type cmdStats struct {
+	processed uint64
+	errors    uint64
+}
+
+func (s *cmdStats) wrap(fn func(Cmder) error) func(Cmder) error {
+	return func(cmd Cmder) error {
+		err := fn(cmd)
+		atomic.AddUint64(&s.processed, 1)
+		if err != nil && err != Nil {
+			atomic.AddUint64(&s.errors, 1)
+		}
+		return err
+	}
+}
+
+// NodeStats describes one Redis server behind a client.
+type NodeStats struct {
+	Addr string
+	Up   bool
+	Pool *PoolStats
+
+	// Commands and Errors stay zero unless CommandStats is enabled.
+	Commands uint64
+	Errors   uint64
+}
+
+// Stats is a snapshot of the pool and command counters of a client
+// and of every node it talks to.
+type Stats struct {
+	Pool     *PoolStats
+	Commands uint64
+	Errors   uint64
+	Nodes    []NodeStats
+}
+
+func (c *baseClient) nodeStats() NodeStats {
+	st := NodeStats{
+		Addr: c.getAddr(),
+		Up:   true,
+		Pool: (*PoolStats)(c.connPool.Stats()),
+	}
+	if c.cmdStats != nil {
+		st.Commands = atomic.LoadUint64(&c.cmdStats.processed)
+		st.Errors = atomic.LoadUint64(&c.cmdStats.errors)
+	}
+	return st
+}
+
+func (st *Stats) add(node NodeStats) {
+	st.Pool.Hits += node.Pool.Hits
+	st.Pool.Misses += node.Pool.Misses
+	st.Pool.Timeouts += node.Pool.Timeouts
+	st.Pool.WaitCount += node.Pool.WaitCount
+	st.Pool.WaitDuration += node.Pool.WaitDuration
+	st.Pool.TotalConns += node.Pool.TotalConns
+	st.Pool.FreeConns += node.Pool.FreeConns
+	st.Pool.StaleConns += node.Pool.StaleConns
+	st.Pool.ConnAgeEvictions += node.Pool.ConnAgeEvictions
+	st.Commands += node.Commands
+	st.Errors += node.Errors
+	st.Nodes = append(st.Nodes, node)
+}
+
+// Stats returns pool and command stats of the client.
+func (c *Client) Stats() *Stats {
+	st := &Stats{Pool: &PoolStats{}}
+	st.add(c.nodeStats())
+	return st
+}
+
+// Stats returns pool and command stats per shard. Nodes[i].Up reports
+// the shard state as seen by the heartbeat.
+func (c *Ring) Stats() *Stats {
+	st := &Stats{Pool: &PoolStats{}}
+	for _, shard := range c.shards.List() {
+		node := shard.Client.nodeStats()
+		node.Up = shard.IsUp()
+		st.add(node)
+	}
+	return st
+}
+
+// Stats returns pool and command stats per master and slave node.
+// Nodes still loading their dataset are reported as down.
+func (c *ClusterClient) Stats() *Stats {
+	st := &Stats{Pool: &PoolStats{}}
+	state, _ := c.state.Get()
+	if state == nil {
+		return st
+	}
+	for _, nodes := range [][]*clusterNode{state.Masters, state.Slaves} {
+		for _, n := range nodes {
+			node := n.Client.nodeStats()
+			node.Up = !n.Loading()
+			st.add(node)
+		}
+	}
+	return st
+}
@@ -22,6 +22,7 @@ func (c *Client) newTx() *Tx {
 		baseClient: baseClient{
 			opt:      c.opt,
 			connPool: pool.NewStickyConnPool(c.connPool.(*pool.ConnPool), true),
+			cmdStats: c.cmdStats,
 		},
 	}
 	tx.baseClient.init()
@@ -1,6 +1,7 @@
 package redis
 
 import (
+	"context"
 	"crypto/tls"
 	"time"
 )
@@ -31,6 +32,7 @@ type UniversalOptions struct {
 	// Common options
 
 	OnConnect          func(*Conn) error
+	OnConnectContext   func(ctx context.Context, cn *Conn, addr string) error
 	MaxRetries         int
 	Password           string
 	DialTimeout        time.Duration
@@ -55,6 +57,7 @@ func (o *UniversalOptions) cluster() *ClusterOptions {
 		ReadOnly:       o.ReadOnly,
 
 		OnConnect:          o.OnConnect,
+		OnConnectContext:   o.OnConnectContext,
 		MaxRetries:         o.MaxRetries,
 		Password:           o.Password,
 		DialTimeout:        o.DialTimeout,
@@ -79,6 +82,7 @@ func (o *UniversalOptions) failover() *FailoverOptions {
 		DB:            o.DB,
 
 		OnConnect:          o.OnConnect,
+		OnConnectContext:   o.OnConnectContext,
 		MaxRetries:         o.MaxRetries,
 		Password:           o.Password,
 		DialTimeout:        o.DialTimeout,
@@ -103,6 +107,7 @@ func (o *UniversalOptions) simple() *Options {
 		DB:   o.DB,
 
 		OnConnect:          o.OnConnect,
+		OnConnectContext:   o.OnConnectContext,
 		MaxRetries:         o.MaxRetries,
 		Password:           o.Password,
 		DialTimeout:        o.DialTimeout,
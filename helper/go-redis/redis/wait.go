@@ -0,0 +1,80 @@
+package redis
+
+import (
+	"errors"
+	"time"
+
+	"EasyDarwin/helper/go-redis/redis/internal/hashtag"
+)
+
+var errWaitCrossShard = errors.New("redis: DoWait commands must have keys on a single shard")
+
+func newWaitCmd(numSlaves int, timeout time.Duration) *IntCmd {
+	cmd := NewIntCmd("wait", numSlaves, formatMs(timeout))
+	cmd.setReadTimeout(readTimeout(timeout))
+	return cmd
+}
+
+// DoWait runs the writes queued by fn in a pipeline followed by
+// WAIT numSlaves timeout on the same connection, so the acknowledgment
+// covers exactly those writes. It returns the write commands and the WAIT
+// command, whose value is the number of replicas that acknowledged;
+// reaching fewer than numSlaves replicas is not an error.
+func (c *Client) DoWait(
+	fn func(Pipeliner) error, numSlaves int, timeout time.Duration,
+) ([]Cmder, *IntCmd, error) {
+	wait := newWaitCmd(numSlaves, timeout)
+	pipe := Pipeline{
+		exec: func(cmds []Cmder) error {
+			return c.processPipeline(appendWaitCmd(cmds, wait))
+		},
+	}
+	pipe.statefulCmdable.setProcessor(pipe.Process)
+	cmds, err := pipe.Pipelined(fn)
+	return cmds, wait, err
+}
+
+// DoWait is like Client.DoWait. All commands queued by fn must have keys
+// and the keys must belong to the same shard.
+func (c *Ring) DoWait(
+	fn func(Pipeliner) error, numSlaves int, timeout time.Duration,
+) ([]Cmder, *IntCmd, error) {
+	wait := newWaitCmd(numSlaves, timeout)
+	pipe := Pipeline{
+		exec: func(cmds []Cmder) error {
+			shard, err := c.waitShard(cmds)
+			if err != nil {
+				setCmdsErr(cmds, err)
+				wait.setErr(err)
+				return err
+			}
+			return shard.Client.processPipeline(appendWaitCmd(cmds, wait))
+		},
+	}
+	pipe.statefulCmdable.setProcessor(pipe.Process)
+	cmds, err := pipe.Pipelined(fn)
+	return cmds, wait, err
+}
+
+func (c *Ring) waitShard(cmds []Cmder) (*ringShard, error) {
+	var hash string
+	for _, cmd := range cmds {
+		pos := cmdFirstKeyPos(cmd, c.cmdInfo(cmd.Name()))
+		key := cmd.stringArg(pos)
+		if pos == 0 || key == "" {
+			return nil, errWaitCrossShard
+		}
+		h := c.shards.Hash(hashtag.Key(key))
+		if hash != "" && h != hash {
+			return nil, errWaitCrossShard
+		}
+		hash = h
+	}
+	return c.shards.GetByHash(hash)
+}
+
+func appendWaitCmd(cmds []Cmder, wait *IntCmd) []Cmder {
+	all := make([]Cmder, 0, len(cmds)+1)
+	all = append(all, cmds...)
+	return append(all, wait)
+}
@@ -0,0 +1,68 @@
+package redis
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClientDoWait(t *testing.T) {
+	srv := newFakeServer(t, func(conn int, args []string) string {
+		switch strings.ToLower(args[0]) {
+		case "incr":
+			return respInt(1)
+		case "wait":
+			return respInt(1)
+		}
+		return "+OK\r\n"
+	})
+	client := NewClient(&Options{Addr: srv.Addr, ReadTimeout: 100 * time.Millisecond})
+	defer client.Close()
+
+	cmds, wait, err := client.DoWait(func(pipe Pipeliner) error {
+		pipe.Set("key", "value", 0)
+		pipe.Incr("counter")
+		return nil
+	}, 2, 1500*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cmds) != 2 || cmds[1].(*IntCmd).Val() != 1 {
+		t.Fatalf("cmds = %v", cmds)
+	}
+	// fewer replicas than asked for is not an error
+	if wait.Err() != nil || wait.Val() != 1 {
+		t.Fatalf("wait = %v", wait)
+	}
+	if wait.readTimeout() == nil || *wait.readTimeout() != 1500*time.Millisecond+10*time.Second {
+		t.Fatalf("wait read timeout = %v", wait.readTimeout())
+	}
+
+	got := srv.Cmds()
+	if len(got) != 3 {
+		t.Fatalf("commands = %v", got)
+	}
+	for _, cmd := range got {
+		if cmd.Conn != got[0].Conn {
+			t.Fatalf("commands = %v, want all on one connection", got)
+		}
+	}
+	if strings.Join(got[2].Args, " ") != "wait 2 1500" || got[0].Args[0] != "set" || got[1].Args[0] != "incr" {
+		t.Fatalf("commands = %v, want the writes followed by WAIT 2 1500", got)
+	}
+}
+
+func TestRingDoWaitKeyless(t *testing.T) {
+	ring := NewRing(&RingOptions{
+		Addrs: map[string]string{"shard1": "127.0.0.1:1", "shard2": "127.0.0.1:2"},
+	})
+	defer ring.Close()
+
+	_, wait, err := ring.DoWait(func(pipe Pipeliner) error {
+		pipe.Ping()
+		return nil
+	}, 1, time.Second)
+	if err != errWaitCrossShard || wait.Err() != errWaitCrossShard {
+		t.Fatalf("err = %v, wait err = %v, want %v", err, wait.Err(), errWaitCrossShard)
+	}
+}
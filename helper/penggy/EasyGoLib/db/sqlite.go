@@ -2,7 +2,12 @@ package db
 
 import (
 	"fmt"
+	"io"
 	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"EasyDarwin/helper/jinzhu/gorm"
 	_ "EasyDarwin/helper/jinzhu/gorm/dialects/sqlite"
@@ -18,12 +23,50 @@ type Model struct {
 
 var SQLite *gorm.DB
 
+// What Init does when the database fails IntegrityCheck, the on_corruption
+// key of [db].
+const (
+	// log the failure and go on with the database
+	OnCorruptionLog = "log"
+	// move the database to <name>.corrupt.<timestamp>.db and start over
+	// with an empty one
+	OnCorruptionBackupAndRecreate = "backup_and_recreate"
+	// log the failure and exit with code 1
+	OnCorruptionFatal = "fatal"
+)
+
 func Init() (err error) {
 	gorm.DefaultTableNameHandler = func(db *gorm.DB, defaultTablename string) string {
 		return "t_" + defaultTablename
 	}
 	dbFile := utils.DBFile()
 	log.Println("db file -->", utils.DBFile())
+	if err = open(dbFile); err != nil {
+		return
+	}
+	checkErr := IntegrityCheck()
+	if checkErr == nil {
+		return
+	}
+	onCorruption := utils.Conf().Section("db").Key("on_corruption").In(OnCorruptionLog,
+		[]string{OnCorruptionLog, OnCorruptionBackupAndRecreate, OnCorruptionFatal})
+	log.Printf("db file %s corrupt: %v", dbFile, checkErr)
+	switch onCorruption {
+	case OnCorruptionFatal:
+		os.Exit(1)
+	case OnCorruptionBackupAndRecreate:
+		Close()
+		var backup string
+		if backup, err = backupCorrupt(dbFile); err != nil {
+			return
+		}
+		log.Printf("db file %s moved to %s, recreate it", dbFile, backup)
+		err = open(dbFile)
+	}
+	return
+}
+
+func open(dbFile string) (err error) {
 	SQLite, err = gorm.Open("sqlite3", fmt.Sprintf("%s?loc=Asia/Shanghai", dbFile))
 	if err != nil {
 		return
@@ -36,6 +79,61 @@ func Init() (err error) {
 	return
 }
 
+// IntegrityCheck runs PRAGMA integrity_check on the database, and returns the
+// problems it found, or why it could not run, e.g. the file is not a
+// database.
+func IntegrityCheck() error {
+	rows, err := SQLite.DB().Query("PRAGMA integrity_check")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	var problems []string
+	for rows.Next() {
+		var problem string
+		if err := rows.Scan(&problem); err != nil {
+			return err
+		}
+		problems = append(problems, problem)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(problems) == 1 && problems[0] == "ok" {
+		return nil
+	}
+	return fmt.Errorf("integrity check failed: %s", strings.Join(problems, "; "))
+}
+
+// backupCorrupt copies dbFile to <name>.corrupt.<timestamp>.db next to it,
+// then removes it with its journal.
+func backupCorrupt(dbFile string) (backup string, err error) {
+	backup = fmt.Sprintf("%s.corrupt.%s.db", strings.TrimSuffix(dbFile, filepath.Ext(dbFile)), time.Now().Format("20060102150405"))
+	src, err := os.Open(dbFile)
+	if err != nil {
+		return
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(backup, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return
+	}
+	if _, err = io.Copy(dst, src); err != nil {
+		dst.Close()
+		return
+	}
+	if err = dst.Close(); err != nil {
+		return
+	}
+	if err = os.Remove(dbFile); err != nil {
+		return
+	}
+	for _, suffix := range []string{"-journal", "-wal", "-shm"} {
+		os.Remove(dbFile + suffix)
+	}
+	return
+}
+
 func Close() {
 	if SQLite != nil {
 		SQLite.Close()
@@ -129,29 +129,47 @@ func DBFileDev() string {
 	return filepath.Join(CWD(), strings.ToLower(EXEName())+".dev.db")
 }
 
-var conf *ini.File
+var (
+	conf     *ini.File
+	confLock sync.RWMutex
+)
 
 func Conf() *ini.File {
-	if conf != nil {
-		return conf
+	confLock.RLock()
+	_conf := conf
+	confLock.RUnlock()
+	if _conf != nil {
+		return _conf
 	}
-	if _conf, err := ini.InsensitiveLoad(ConfFile()); err != nil {
-		_conf, _ = ini.LoadSources(ini.LoadOptions{Insensitive: true}, []byte(""))
-		conf = _conf
-	} else {
-		conf = _conf
+	confLock.Lock()
+	defer confLock.Unlock()
+	if conf == nil {
+		conf = loadConf()
 	}
 	return conf
 }
 
 func ReloadConf() *ini.File {
-	if _conf, err := ini.InsensitiveLoad(ConfFile()); err != nil {
+	_conf := loadConf()
+	SetConf(_conf)
+	return _conf
+}
+
+// SetConf replaces the config returned by Conf, e.g. with a reloaded file.
+// Readers hold on to the config they got, the swap is safe while they run.
+func SetConf(_conf *ini.File) {
+	confLock.Lock()
+	conf = _conf
+	confLock.Unlock()
+}
+
+// loadConf reads ConfFile, an empty config if it fails.
+func loadConf() *ini.File {
+	_conf, err := ini.InsensitiveLoad(ConfFile())
+	if err != nil {
 		_conf, _ = ini.LoadSources(ini.LoadOptions{Insensitive: true}, []byte(""))
-		conf = _conf
-	} else {
-		conf = _conf
 	}
-	return conf
+	return _conf
 }
 
 func SaveToConf(section string, kvmap map[string]string) error {
@@ -167,7 +185,7 @@ func SaveToConf(section string, kvmap map[string]string) error {
 		sec.Key(k).SetValue(v)
 	}
 	_conf.SaveTo(ConfFile())
-	conf = _conf
+	SetConf(_conf)
 	return nil
 }
 
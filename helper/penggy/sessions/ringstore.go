@@ -0,0 +1,187 @@
+package sessions
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"EasyDarwin/helper/go-redis/redis"
+	gsessions "EasyDarwin/helper/gorilla/sessions"
+	"EasyDarwin/helper/teris-io/shortid"
+)
+
+// SessionStore keeps the data of sessions by ID.
+type SessionStore interface {
+	Set(sessionID string, data map[string]interface{}, ttl time.Duration) error
+	// Get returns nil data for a session that does not exist or expired.
+	Get(sessionID string) (map[string]interface{}, error)
+	Delete(sessionID string) error
+}
+
+// RingSessionKeyPrefix prefixes the Redis keys of the sessions.
+const RingSessionKeyPrefix = "session:"
+
+// ErrRingUnavailable is returned by a RingSessionStore while its ring
+// function returns nil.
+var ErrRingUnavailable = errors.New("sessions: redis ring unavailable")
+
+// RingSessionStore is a SessionStore on a Redis ring: the data of a session
+// is JSON encoded under session:<id>, expiring after the TTL. The ring is
+// looked up on every call, so its owner may replace it, e.g. on a restart.
+type RingSessionStore struct {
+	ring func() *redis.Ring
+}
+
+func NewRingSessionStore(ring func() *redis.Ring) *RingSessionStore {
+	return &RingSessionStore{ring: ring}
+}
+
+func (s *RingSessionStore) getRing() (*redis.Ring, error) {
+	ring := s.ring()
+	if ring == nil {
+		return nil, ErrRingUnavailable
+	}
+	return ring, nil
+}
+
+func (s *RingSessionStore) Set(sessionID string, data map[string]interface{}, ttl time.Duration) error {
+	ring, err := s.getRing()
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return ring.Set(RingSessionKeyPrefix+sessionID, b, ttl).Err()
+}
+
+func (s *RingSessionStore) Get(sessionID string) (map[string]interface{}, error) {
+	ring, err := s.getRing()
+	if err != nil {
+		return nil, err
+	}
+	b, err := ring.Get(RingSessionKeyPrefix + sessionID).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	data := make(map[string]interface{})
+	if err := json.Unmarshal(b, &data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (s *RingSessionStore) Delete(sessionID string) error {
+	ring, err := s.getRing()
+	if err != nil {
+		return err
+	}
+	return ring.Del(RingSessionKeyPrefix + sessionID).Err()
+}
+
+// Touch restarts the TTL of a session.
+func (s *RingSessionStore) Touch(sessionID string, ttl time.Duration) error {
+	ring, err := s.getRing()
+	if err != nil {
+		return err
+	}
+	return ring.Expire(RingSessionKeyPrefix+sessionID, ttl).Err()
+}
+
+// RingStore is a Store keeping the sessions in a RingSessionStore, so the
+// nodes sharing the ring share the sessions. The TTL of a session is its
+// MaxAge and restarts on every request; a cookie naming no live session
+// gets a new ID.
+type RingStore struct {
+	Sessions    *RingSessionStore
+	SessionOpts *gsessions.Options
+}
+
+func NewRingStore(ring func() *redis.Ring) *RingStore {
+	return &RingStore{
+		Sessions: NewRingSessionStore(ring),
+		SessionOpts: &gsessions.Options{
+			Path:   defaultPath,
+			MaxAge: defaultMaxAge,
+		},
+	}
+}
+
+func (st *RingStore) Options(options Options) {
+	st.SessionOpts = &gsessions.Options{
+		Path:     options.Path,
+		Domain:   options.Domain,
+		MaxAge:   options.MaxAge,
+		Secure:   options.Secure,
+		HttpOnly: options.HttpOnly,
+	}
+}
+
+func (st *RingStore) ttl(session *gsessions.Session) time.Duration {
+	return time.Duration(session.Options.MaxAge) * time.Second
+}
+
+// Get returns a session for the given name after adding it to the registry.
+func (st *RingStore) Get(r *http.Request, name string) (*gsessions.Session, error) {
+	return gsessions.GetRegistry(r).Get(st, name)
+}
+
+// New returns the session of the cookie, or a new one, without adding it to
+// the registry.
+func (st *RingStore) New(r *http.Request, name string) (*gsessions.Session, error) {
+	session := gsessions.NewSession(st, name)
+	opts := *st.SessionOpts
+	session.Options = &opts
+	session.IsNew = true
+	session.ID = shortid.MustGenerate()
+
+	cookie, err := r.Cookie(name)
+	if err != nil {
+		return session, nil
+	}
+	data, err := st.Sessions.Get(cookie.Value)
+	if err != nil || data == nil {
+		return session, err
+	}
+	session.ID = cookie.Value
+	session.IsNew = false
+	for k, v := range data {
+		session.Values[k] = v
+	}
+	return session, st.Sessions.Touch(session.ID, st.ttl(session))
+}
+
+func (st *RingStore) RenewID(r *http.Request, w http.ResponseWriter, session *gsessions.Session) error {
+	_id := session.ID
+	session.ID = shortid.MustGenerate()
+	if err := st.Save(r, w, session); err != nil {
+		return err
+	}
+	if err := st.Sessions.Delete(_id); err != nil {
+		return err
+	}
+	http.SetCookie(w, gsessions.NewCookie(session.Name(), session.ID, session.Options))
+	return nil
+}
+
+// Save stores the session, or deletes it when it has no values or a negative MaxAge.
+func (st *RingStore) Save(r *http.Request, w http.ResponseWriter, session *gsessions.Session) error {
+	if session.Options.MaxAge < 0 || len(session.Values) == 0 {
+		return st.Sessions.Delete(session.ID)
+	}
+	data := make(map[string]interface{}, len(session.Values))
+	for k, v := range session.Values {
+		ks, ok := k.(string)
+		if !ok {
+			return fmt.Errorf("Non-string key value, cannot serialize session to JSON: %v", k)
+		}
+		data[ks] = v
+	}
+	return st.Sessions.Set(session.ID, data, st.ttl(session))
+}
@@ -2,24 +2,36 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"EasyDarwin/helper/penggy/EasyGoLib/db"
 
 	figure "EasyDarwin/helper/common-nighthawk/go-figure"
+	"EasyDarwin/helper/go-ini/ini"
 	"EasyDarwin/helper/penggy/EasyGoLib/utils"
 	"EasyDarwin/helper/penggy/service"
 	"EasyDarwin/models"
 	"EasyDarwin/routers"
 	"EasyDarwin/rtsp"
+	"EasyDarwin/version"
 )
 
 var (
+	// Version is the semantic version, given at build time with
+	// -ldflags "-X main.Version=..."
+	Version       string
 	gitCommitCode string
 	buildDateTime string
 )
@@ -27,8 +39,17 @@ var (
 type program struct {
 	httpPort   int
 	httpServer *http.Server
+	// UseH2C serves cleartext HTTP/2 when no TLS certificate is configured
+	UseH2C bool
+	// serves routers.AdminRouter, nil without tls_client_ca_file
+	adminPort   int
+	adminServer *http.Server
+
 	rtspPort   int
 	rtspServer *rtsp.Server
+
+	// serializes the config reloads of SIGHUP and restarts
+	reloadLock sync.Mutex
 }
 
 func (p *program) StopHTTP() (err error) {
@@ -36,24 +57,60 @@ func (p *program) StopHTTP() (err error) {
 		err = fmt.Errorf("HTTP Server Not Found")
 		return
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), routers.Router.ShutdownTimeout)
 	defer cancel()
+	if p.adminServer != nil {
+		if err := p.adminServer.Shutdown(ctx); err != nil {
+			p.adminServer.Close()
+		}
+		p.adminServer = nil
+	}
 	if err = p.httpServer.Shutdown(ctx); err != nil {
+		// requests still running after the timeout are cut off
+		p.httpServer.Close()
 		return
 	}
 	return
 }
 
 func (p *program) StartHTTP() (err error) {
+	sec := utils.Conf().Section("http")
+	certFile := sec.Key("tls_cert_file").MustString("")
+	keyFile := sec.Key("tls_key_file").MustString("")
+	useTLS := certFile != "" && keyFile != ""
+	p.UseH2C = sec.Key("use_h2c").MustBool(false)
+
 	p.httpServer = &http.Server{
 		Addr:              fmt.Sprintf(":%d", p.httpPort),
 		Handler:           routers.Router,
 		ReadHeaderTimeout: 5 * time.Second,
 	}
-	link := fmt.Sprintf("http://%s:%d", utils.LocalIP(), p.httpPort)
+	if p.UseH2C && !useTLS {
+		// HTTP/2 with prior knowledge next to HTTP/1.1 on the same port
+		p.httpServer.Protocols = new(http.Protocols)
+		p.httpServer.Protocols.SetHTTP1(true)
+		p.httpServer.Protocols.SetUnencryptedHTTP2(true)
+	}
+	scheme := "http"
+	if useTLS {
+		// net/http negotiates HTTP/2 through ALPN over TLS
+		scheme = "https"
+	}
+	if routers.AdminRouter != nil {
+		if err = p.startAdminHTTP(certFile, keyFile, sec.Key("tls_client_ca_file").MustString("")); err != nil {
+			return
+		}
+	}
+	link := fmt.Sprintf("%s://%s:%d", scheme, utils.LocalIP(), p.httpPort)
 	log.Println("http server start -->", link)
 	go func() {
-		if err := p.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+		if useTLS {
+			err = p.httpServer.ListenAndServeTLS(certFile, keyFile)
+		} else {
+			err = p.httpServer.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			log.Println("start http server error", err)
 		}
 		log.Println("http server end")
@@ -61,6 +118,47 @@ func (p *program) StartHTTP() (err error) {
 	return
 }
 
+// startAdminHTTP serves the admin APIs on admin_port, to the clients with a
+// certificate of the CAs in caFile only. The pages and the other APIs stay
+// on the HTTP port, open to clients without a certificate.
+func (p *program) startAdminHTTP(certFile, keyFile, caFile string) (err error) {
+	p.adminServer = &http.Server{
+		Addr:              fmt.Sprintf(":%d", p.adminPort),
+		Handler:           routers.AdminRouter,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	if p.adminServer.TLSConfig, err = clientCATLSConfig(caFile); err != nil {
+		p.adminServer = nil
+		return
+	}
+	log.Println("admin server start -->", fmt.Sprintf("https://%s:%d/api/v1/admin", utils.LocalIP(), p.adminPort))
+	server := p.adminServer
+	go func() {
+		if err := server.ListenAndServeTLS(certFile, keyFile); err != nil && err != http.ErrServerClosed {
+			log.Println("start admin server error", err)
+		}
+		log.Println("admin server end")
+	}()
+	return
+}
+
+// clientCATLSConfig requires a client certificate verified against the CAs
+// in caFile during the handshake.
+func clientCATLSConfig(caFile string) (*tls.Config, error) {
+	pem, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificate found in tls_client_ca_file[%s]", caFile)
+	}
+	return &tls.Config{
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		ClientCAs:  pool,
+	}, nil
+}
+
 func (p *program) StartRTSP() (err error) {
 	if p.rtspServer == nil {
 		err = fmt.Errorf("RTSP Server Not Found")
@@ -90,12 +188,51 @@ func (p *program) StopRTSP() (err error) {
 	return
 }
 
+// ReloadConf reloads the config file without a restart, on SIGHUP. The
+// changed keys are logged, those only read at start flagged as requiring a
+// restart, and the others applied by the RTSP server. A file that does not
+// parse is logged and the current config kept.
+func (p *program) ReloadConf() {
+	p.reloadLock.Lock()
+	defer p.reloadLock.Unlock()
+	conf, err := ini.InsensitiveLoad(utils.ConfFile())
+	if err != nil {
+		log.Printf("reload config %s err:%v, keep the current one", utils.ConfFile(), err)
+		return
+	}
+	changes := rtsp.DiffConf(utils.Conf(), conf)
+	utils.SetConf(conf)
+	log.Printf("INFO config %s reloaded, %d keys changed", utils.ConfFile(), len(changes))
+	for _, change := range changes {
+		if change.RequiresRestart {
+			log.Printf("INFO config %v, requires restart", change)
+		} else {
+			log.Printf("INFO config %v", change)
+		}
+		if models.AuditLog.Enabled() {
+			err := models.AuditLog.Append(models.AUDIT_CONFIG_CHANGE, change.Section+"."+change.Key, map[string]interface{}{
+				"old":             change.Old,
+				"new":             change.New,
+				"requiresRestart": change.RequiresRestart,
+			})
+			if err != nil {
+				log.Printf("audit config change %s.%s err:%v", change.Section, change.Key, err)
+			}
+		}
+	}
+	p.rtspServer.ApplyConf(changes)
+}
+
 func (p *program) Start(s service.Service) (err error) {
 	log.Println("********** START **********")
 	if utils.IsPortInUse(p.httpPort) {
 		err = fmt.Errorf("HTTP port[%d] In Use", p.httpPort)
 		return
 	}
+	if p.adminPort > 0 && utils.IsPortInUse(p.adminPort) {
+		err = fmt.Errorf("Admin port[%d] In Use", p.adminPort)
+		return
+	}
 	if utils.IsPortInUse(p.rtspPort) {
 		err = fmt.Errorf("RTSP port[%d] In Use", p.rtspPort)
 		return
@@ -117,11 +254,21 @@ func (p *program) Start(s service.Service) (err error) {
 	}
 	go func() {
 		for range routers.API.RestartChan {
+			p.reloadLock.Lock()
 			p.StopHTTP()
 			p.StopRTSP()
 			utils.ReloadConf()
 			p.StartRTSP()
 			p.StartHTTP()
+			p.reloadLock.Unlock()
+		}
+	}()
+
+	go func() {
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		for range hup {
+			p.ReloadConf()
 		}
 	}()
 
@@ -136,7 +283,7 @@ func (p *program) Start(s service.Service) (err error) {
 			}
 			for i := len(streams) - 1; i > -1; i-- {
 				v := streams[i]
-				if rtsp.GetServer().GetPusher(v.CustomPath) != nil {
+				if rtsp.GetServer().GetPusher(v.CustomPath) != nil || rtsp.GetServer().DataCapExceeded(v.CustomPath) {
 					continue
 				}
 				agent := fmt.Sprintf("EasyDarwinGo/%s", routers.BuildVersion)
@@ -155,7 +302,15 @@ func (p *program) Start(s service.Service) (err error) {
 					continue
 				}
 				pusher := rtsp.NewClientPusher(client)
-				rtsp.GetServer().AddPusher(pusher)
+				if !rtsp.GetServer().AddPusher(pusher) {
+					client.Stop()
+					continue
+				}
+				if codec := rtsp.VideoCodecName(pusher.VCodec()); codec != v.Codec {
+					if err := models.UpdateStreamCodec(v.URL, codec); err != nil {
+						log.Printf("update stream codec err:%v", err)
+					}
+				}
 				//streams = streams[0:i]
 				//streams = append(streams[:i], streams[i+1:]...)
 			}
@@ -185,7 +340,13 @@ func main() {
 
 	log.Printf("git commit code:%s", gitCommitCode)
 	log.Printf("build date:%s", buildDateTime)
-	routers.BuildVersion = fmt.Sprintf("%s.%s", routers.BuildVersion, gitCommitCode)
+	if Version != "" {
+		version.Version = Version
+	}
+	version.BuildTime = buildDateTime
+	version.GitCommit = gitCommitCode
+	log.Printf("version:%s", version.String())
+	routers.BuildVersion = version.String()
 	routers.BuildDateTime = buildDateTime
 
 	sec := utils.Conf().Section("service")
@@ -196,9 +357,15 @@ func main() {
 	}
 
 	httpPort := utils.Conf().Section("http").Key("port").MustInt(10008)
+	adminPort := 0
+	if utils.Conf().Section("http").Key("tls_client_ca_file").MustString("") != "" {
+		adminPort = utils.Conf().Section("http").Key("admin_port").MustInt(10009)
+	}
 	rtspServer := rtsp.GetServer()
+	rtspServer.Version = version.String()
 	p := &program{
 		httpPort:   httpPort,
+		adminPort:  adminPort,
 		rtspPort:   rtspServer.TCPPort,
 		rtspServer: rtspServer,
 	}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"EasyDarwin/helper/penggy/EasyGoLib/db"
+)
+
+// Alias serves the stream at CanonicalPath under AliasPath as well.
+type Alias struct {
+	AliasPath     string `gorm:"type:varchar(256);primary_key;unique"`
+	CanonicalPath string `gorm:"type:varchar(256)"`
+}
+
+func (Alias) TableName() string {
+	return "alias"
+}
+
+func FindAliases() (aliases []Alias, err error) {
+	aliases = make([]Alias, 0)
+	err = db.SQLite.Order("alias_path").Find(&aliases).Error
+	return
+}
+
+func SaveAlias(alias *Alias) error {
+	return db.SQLite.Save(alias).Error
+}
+
+func DeleteAlias(aliasPath string) error {
+	return db.SQLite.Where("alias_path = ?", aliasPath).Delete(Alias{}).Error
+}
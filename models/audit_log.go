@@ -0,0 +1,137 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"EasyDarwin/helper/penggy/EasyGoLib/db"
+	"EasyDarwin/helper/penggy/EasyGoLib/utils"
+)
+
+const (
+	AUDIT_SESSION_START = "session_start"
+	AUDIT_SESSION_STOP  = "session_stop"
+	AUDIT_AUTH          = "auth"
+	AUDIT_CONFIG_CHANGE = "config_change"
+	AUDIT_ADMIN_API     = "admin_api"
+)
+
+// AuditRecord is an event of the audit log. Each record is chained to the
+// previous one by its hash, see ComputeHash, so a record changed or deleted
+// afterwards breaks the chain.
+type AuditRecord struct {
+	ID          uint   `gorm:"primary_key;AUTO_INCREMENT"`
+	Timestamp   int64  `gorm:"index"` // unix nanoseconds
+	EventType   string `gorm:"type:varchar(32);index"`
+	Subject     string `gorm:"type:varchar(256)"` // stream path, user name, config key...
+	DetailsJSON string `gorm:"type:TEXT"`
+	PrevHash    string `gorm:"type:varchar(64)"` // empty for the first record
+	Hash        string `gorm:"type:varchar(64)"`
+}
+
+func (AuditRecord) TableName() string {
+	return "audit_log"
+}
+
+// ComputeHash returns the hex SHA-256 of
+// prev_hash || timestamp || event_type || subject || details_json,
+// the timestamp in decimal.
+func (record *AuditRecord) ComputeHash() string {
+	h := sha256.New()
+	h.Write([]byte(record.PrevHash))
+	h.Write([]byte(strconv.FormatInt(record.Timestamp, 10)))
+	h.Write([]byte(record.EventType))
+	h.Write([]byte(record.Subject))
+	h.Write([]byte(record.DetailsJSON))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// AuditTamperedError is returned by AuditLogger.Verify for the first record
+// that breaks the chain.
+type AuditTamperedError struct {
+	Record AuditRecord
+	Reason string
+}
+
+func (e *AuditTamperedError) Error() string {
+	return fmt.Sprintf("audit log record %d tampered: %s", e.Record.ID, e.Reason)
+}
+
+// AuditLogger appends events to the hash chain of audit_log. Appends are
+// serialized, so the chain must only be written by one process.
+type AuditLogger struct {
+	mu sync.Mutex
+}
+
+// AuditLog is the audit log of the server, written when [audit] enable is set.
+var AuditLog = &AuditLogger{}
+
+// Enabled reports whether events are audited.
+func (l *AuditLogger) Enabled() bool {
+	return utils.Conf().Section("audit").Key("enable").MustBool(false) && db.SQLite != nil
+}
+
+// Append appends an event with details encoded in JSON to the chain.
+func (l *AuditLogger) Append(eventType, subject string, details interface{}) (err error) {
+	detailsJSON, err := json.Marshal(details)
+	if err != nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	tx := db.SQLite.Begin()
+	if err = tx.Error; err != nil {
+		return
+	}
+	var last AuditRecord
+	if res := tx.Last(&last); res.Error != nil && !res.RecordNotFound() {
+		tx.Rollback()
+		return res.Error
+	}
+	record := AuditRecord{
+		Timestamp:   time.Now().UnixNano(),
+		EventType:   eventType,
+		Subject:     subject,
+		DetailsJSON: string(detailsJSON),
+		PrevHash:    last.Hash,
+	}
+	record.Hash = record.ComputeHash()
+	if err = tx.Create(&record).Error; err != nil {
+		tx.Rollback()
+		return
+	}
+	return tx.Commit().Error
+}
+
+// Verify recomputes the chain from the first record. It returns an
+// *AuditTamperedError for the first record whose hash does not match its
+// fields or whose prev_hash is not the hash of the record before it, e.g.
+// after a record before it was deleted. Deleting the last records is not
+// detected.
+func (l *AuditLogger) Verify() error {
+	rows, err := db.SQLite.Model(AuditRecord{}).Order("id").Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	prevHash := ""
+	for rows.Next() {
+		var record AuditRecord
+		if err := db.SQLite.ScanRows(rows, &record); err != nil {
+			return err
+		}
+		if record.PrevHash != prevHash {
+			return &AuditTamperedError{Record: record, Reason: "prev_hash is not the hash of the previous record"}
+		}
+		if record.ComputeHash() != record.Hash {
+			return &AuditTamperedError{Record: record, Reason: "hash does not match the record"}
+		}
+		prevHash = record.Hash
+	}
+	return rows.Err()
+}
@@ -0,0 +1,96 @@
+package models
+
+import (
+	"time"
+
+	"EasyDarwin/helper/penggy/EasyGoLib/db"
+)
+
+// Channel is a linear channel: the recordings of its Items are played one
+// after the other as the live stream at Path, like a TV channel.
+type Channel struct {
+	ID    uint            `gorm:"primary_key;AUTO_INCREMENT"`
+	Path  string          `gorm:"type:varchar(256);unique"`
+	Name  string          `gorm:"type:varchar(256)"`
+	Items []ScheduledItem `gorm:"-"`
+}
+
+func (Channel) TableName() string {
+	return "channel"
+}
+
+// ScheduledItem is a recording played on a channel, in Position order. It
+// starts at StartTime, or when the item before it ends if that is later or
+// StartTime is zero. RecordingID is the path of the recording in the record
+// storage, as listed by /api/v1/record/files.
+type ScheduledItem struct {
+	ID               uint `gorm:"primary_key;AUTO_INCREMENT"`
+	ChannelID        uint `gorm:"index"`
+	Position         int
+	RecordingID      string `gorm:"type:varchar(256)"`
+	StartTime        time.Time
+	TransitionEffect string `gorm:"type:varchar(32)"`
+}
+
+func (ScheduledItem) TableName() string {
+	return "channel_item"
+}
+
+// FindChannels returns the channels with their items.
+func FindChannels() (channels []Channel, err error) {
+	channels = make([]Channel, 0)
+	if err = db.SQLite.Order("id").Find(&channels).Error; err != nil {
+		return
+	}
+	items := make([]ScheduledItem, 0)
+	if err = db.SQLite.Order("channel_id, position").Find(&items).Error; err != nil {
+		return
+	}
+	byID := make(map[uint]*Channel, len(channels))
+	for i := range channels {
+		byID[channels[i].ID] = &channels[i]
+	}
+	for _, item := range items {
+		if channel, ok := byID[item.ChannelID]; ok {
+			channel.Items = append(channel.Items, item)
+		}
+	}
+	return
+}
+
+// SaveChannel saves the channel and replaces its items, numbered in order.
+func SaveChannel(channel *Channel) error {
+	tx := db.SQLite.Begin()
+	if err := tx.Save(channel).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Where("channel_id = ?", channel.ID).Delete(ScheduledItem{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	for i := range channel.Items {
+		item := &channel.Items[i]
+		item.ID = 0
+		item.ChannelID = channel.ID
+		item.Position = i
+		if err := tx.Create(item).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit().Error
+}
+
+func DeleteChannel(id uint) error {
+	tx := db.SQLite.Begin()
+	if err := tx.Where("channel_id = ?", id).Delete(ScheduledItem{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Where("id = ?", id).Delete(Channel{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
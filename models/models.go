@@ -10,7 +10,8 @@ func Init() (err error) {
 	if err != nil {
 		return
 	}
-	db.SQLite.AutoMigrate(User{}, Stream{})
+	db.SQLite.AutoMigrate(User{}, Stream{}, SessionStat{}, Alias{}, StreamAgg{}, RecordingSchedule{}, StreamUsage{}, Channel{}, ScheduledItem{}, TranscodeProfile{}, AuditRecord{})
+	initStreamFTS()
 	count := 0
 	sec := utils.Conf().Section("http")
 	defUser := sec.Key("default_username").MustString("admin")
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"EasyDarwin/helper/penggy/EasyGoLib/db"
+)
+
+// RecordingSchedule records StreamPath at the times matched by CronExpr, a
+// standard five field cron expression. Each match opens a window of
+// MaxDurationMinutes; with MaxDurationMinutes 0 the window is the matched
+// minutes themselves, e.g. "* 9-16 * * 1-5" for weekdays 9:00-17:00.
+type RecordingSchedule struct {
+	ID                 uint   `gorm:"primary_key;AUTO_INCREMENT"`
+	StreamPath         string `gorm:"type:varchar(256);index"`
+	CronExpr           string `gorm:"type:varchar(128)"`
+	MaxDurationMinutes int
+}
+
+func (RecordingSchedule) TableName() string {
+	return "recording_schedule"
+}
+
+func FindRecordingSchedules() (schedules []RecordingSchedule, err error) {
+	schedules = make([]RecordingSchedule, 0)
+	err = db.SQLite.Order("id").Find(&schedules).Error
+	return
+}
+
+func SaveRecordingSchedule(schedule *RecordingSchedule) error {
+	return db.SQLite.Save(schedule).Error
+}
+
+func DeleteRecordingSchedule(id uint) error {
+	return db.SQLite.Where("id = ?", id).Delete(RecordingSchedule{}).Error
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+
+	"EasyDarwin/helper/penggy/EasyGoLib/db"
+)
+
+// SessionStat is written once for every RTSP session when it ends.
+type SessionStat struct {
+	ID               uint      `gorm:"primary_key;AUTO_INCREMENT"`
+	SessionID        string    `gorm:"type:varchar(32)"`
+	StreamPath       string    `gorm:"type:varchar(256);index"`
+	ClientIP         string    `gorm:"type:varchar(64)"`
+	Role             string    `gorm:"type:varchar(8)"` // pub or sub
+	StartTime        time.Time `gorm:"index"`
+	EndTime          time.Time
+	BytesTransferred int64
+	PacketsLost      int64
+	DisconnectReason string `gorm:"type:varchar(32)"`
+}
+
+func (SessionStat) TableName() string {
+	return "session_stat"
+}
+
+// FindSessionStats pages through the stats of sessions that started in [from, to],
+// newest first. Zero values of stream, from and to are not filtered on.
+func FindSessionStats(stream string, from, to time.Time, start, limit int) (stats []SessionStat, total int, err error) {
+	query := db.SQLite.Model(SessionStat{})
+	if stream != "" {
+		query = query.Where("stream_path = ?", stream)
+	}
+	if !from.IsZero() {
+		query = query.Where("start_time >= ?", from)
+	}
+	if !to.IsZero() {
+		query = query.Where("start_time <= ?", to)
+	}
+	if err = query.Count(&total).Error; err != nil {
+		return
+	}
+	stats = make([]SessionStat, 0)
+	err = query.Order("start_time desc").Offset(start).Limit(limit).Find(&stats).Error
+	return
+}
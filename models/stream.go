@@ -1,8 +1,136 @@
 package models
 
+import (
+	"log"
+
+	"EasyDarwin/helper/penggy/EasyGoLib/db"
+)
+
 type Stream struct {
 	URL               string `gorm:"type:varchar(256);primary_key;unique"`
 	CustomPath        string `gorm:"type:varchar(256)"`
+	RawName           string `gorm:"type:varchar(256)"` // CustomPath before stream name normalization
 	IdleTimeout       int
 	HeartbeatInterval int
+	Name              string `gorm:"type:TEXT"`
+	Description       string `gorm:"type:TEXT"`
+	Metadata          string `gorm:"type:TEXT"`
+	// bytes the stream may send to its players in total, 0 for the default of
+	// rtsp.stream_data_cap_bytes
+	DataCapBytes int64
+	// video codec of the stream when last pulled, "H264" or "H265"
+	Codec string `gorm:"type:varchar(16)"`
+	// seconds without a packet after which the publisher of the stream is
+	// torn down, 0 for the default of rtsp.stall_timeout, negative to never
+	StallTimeout int
+}
+
+// FindStreamDataCap returns the DataCapBytes of the stream pulled to path, 0
+// if there is none.
+func FindStreamDataCap(path string) (int64, error) {
+	var stream Stream
+	query := db.SQLite.Where("custom_path = ?", path).First(&stream)
+	if query.RecordNotFound() {
+		return 0, nil
+	}
+	return stream.DataCapBytes, query.Error
+}
+
+// FindStreamStallTimeout returns the StallTimeout of the stream at path, 0 if
+// there is none.
+func FindStreamStallTimeout(path string) (int, error) {
+	var stream Stream
+	query := db.SQLite.Where("custom_path = ?", path).First(&stream)
+	if query.RecordNotFound() {
+		return 0, nil
+	}
+	return stream.StallTimeout, query.Error
+}
+
+// FindStreamMetadata returns the Metadata of the stream at path, found is false
+// if there is none.
+func FindStreamMetadata(path string) (metadata string, found bool, err error) {
+	var stream Stream
+	query := db.SQLite.Where("custom_path = ?", path).First(&stream)
+	if query.RecordNotFound() {
+		return "", false, nil
+	}
+	return stream.Metadata, query.Error == nil, query.Error
+}
+
+// UpdateStreamMetadata sets the Metadata of the stream at path.
+func UpdateStreamMetadata(path, metadata string) error {
+	return db.SQLite.Model(&Stream{}).Where("custom_path = ?", path).Update("metadata", metadata).Error
+}
+
+// UpdateStreamCodec records the video codec of the stream pulled from url.
+func UpdateStreamCodec(url, codec string) error {
+	return db.SQLite.Model(&Stream{}).Where("url = ?", url).Update("codec", codec).Error
+}
+
+// StreamFTSEnabled reports whether the t_stream_fts full-text index is available.
+// It needs the sqlite driver to be built with the fts5 tag.
+var StreamFTSEnabled = false
+
+var streamFTSSchema = []string{
+	`CREATE VIRTUAL TABLE IF NOT EXISTS t_stream_fts USING fts5(name, description, metadata, content='t_streams', content_rowid='rowid')`,
+	`CREATE TRIGGER IF NOT EXISTS t_streams_fts_ai AFTER INSERT ON t_streams BEGIN
+		INSERT INTO t_stream_fts(rowid, name, description, metadata) VALUES (new.rowid, new.name, new.description, new.metadata);
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS t_streams_fts_ad AFTER DELETE ON t_streams BEGIN
+		INSERT INTO t_stream_fts(t_stream_fts, rowid, name, description, metadata) VALUES ('delete', old.rowid, old.name, old.description, old.metadata);
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS t_streams_fts_au AFTER UPDATE ON t_streams BEGIN
+		INSERT INTO t_stream_fts(t_stream_fts, rowid, name, description, metadata) VALUES ('delete', old.rowid, old.name, old.description, old.metadata);
+		INSERT INTO t_stream_fts(rowid, name, description, metadata) VALUES (new.rowid, new.name, new.description, new.metadata);
+	END`,
+	`INSERT INTO t_stream_fts(t_stream_fts) VALUES ('rebuild')`,
+}
+
+func initStreamFTS() {
+	for _, sql := range streamFTSSchema {
+		if err := db.SQLite.Exec(sql).Error; err != nil {
+			log.Printf("stream full-text search disabled, %v", err)
+			return
+		}
+	}
+	StreamFTSEnabled = true
+}
+
+type StreamSearchResult struct {
+	Stream
+	Highlights map[string]string `gorm:"-"`
+}
+
+// SearchStreams runs a full-text MATCH query against t_stream_fts.
+// Matches in the result highlights are wrapped with <b></b>.
+func SearchStreams(q string) (results []StreamSearchResult, err error) {
+	type row struct {
+		Stream
+		NameHighlight        string
+		DescriptionHighlight string
+		MetadataHighlight    string
+	}
+	rows := make([]row, 0)
+	err = db.SQLite.Raw(`SELECT t_streams.*,
+		highlight(t_stream_fts, 0, '<b>', '</b>') AS name_highlight,
+		highlight(t_stream_fts, 1, '<b>', '</b>') AS description_highlight,
+		highlight(t_stream_fts, 2, '<b>', '</b>') AS metadata_highlight
+		FROM t_stream_fts JOIN t_streams ON t_streams.rowid = t_stream_fts.rowid
+		WHERE t_stream_fts MATCH ? ORDER BY rank`, q).Scan(&rows).Error
+	if err != nil {
+		return
+	}
+	results = make([]StreamSearchResult, 0, len(rows))
+	for _, r := range rows {
+		results = append(results, StreamSearchResult{
+			Stream: r.Stream,
+			Highlights: map[string]string{
+				"name":        r.NameHighlight,
+				"description": r.DescriptionHighlight,
+				"metadata":    r.MetadataHighlight,
+			},
+		})
+	}
+	return
 }
@@ -0,0 +1,73 @@
+package models
+
+import (
+	"time"
+
+	"EasyDarwin/helper/penggy/EasyGoLib/db"
+)
+
+// StreamAgg holds the traffic of one stream over one period of a window, 1m, 5m or 1h.
+type StreamAgg struct {
+	ID               uint      `gorm:"primary_key;AUTO_INCREMENT"`
+	StreamPath       string    `gorm:"type:varchar(256);index"`
+	Window           string    `gorm:"type:varchar(4)"`
+	PeriodStart      time.Time `gorm:"index"`
+	AvgBitrateKbps   float64
+	PeakBitrateKbps  float64
+	TotalSubscribers int
+	TotalBytes       int64
+}
+
+func (StreamAgg) TableName() string {
+	return "stream_agg"
+}
+
+// FindSessionStatsBetween returns the stats of sessions that were running at some time in [from, to).
+func FindSessionStatsBetween(from, to time.Time) (stats []SessionStat, err error) {
+	stats = make([]SessionStat, 0)
+	err = db.SQLite.Where("start_time < ? and end_time > ?", to, from).Find(&stats).Error
+	return
+}
+
+// FindStreamAggs returns the rows of window with a period start in [from, to), oldest first.
+// Zero values of stream, from and to are not filtered on.
+func FindStreamAggs(stream, window string, from, to time.Time) (aggs []StreamAgg, err error) {
+	query := db.SQLite.Where(&StreamAgg{Window: window})
+	if stream != "" {
+		query = query.Where("stream_path = ?", stream)
+	}
+	if !from.IsZero() {
+		query = query.Where("period_start >= ?", from)
+	}
+	if !to.IsZero() {
+		query = query.Where("period_start < ?", to)
+	}
+	aggs = make([]StreamAgg, 0)
+	err = query.Order("period_start").Find(&aggs).Error
+	return
+}
+
+// SaveStreamAggs replaces the rows of window for the period starting at periodStart.
+func SaveStreamAggs(window string, periodStart time.Time, aggs []StreamAgg) (err error) {
+	tx := db.SQLite.Begin()
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+	if err = tx.Where(&StreamAgg{Window: window}).Where("period_start = ?", periodStart).Delete(StreamAgg{}).Error; err != nil {
+		return
+	}
+	for i := range aggs {
+		if err = tx.Create(&aggs[i]).Error; err != nil {
+			return
+		}
+	}
+	return tx.Commit().Error
+}
+
+// DeleteStreamAggsBefore deletes the rows of periods that started before t.
+func DeleteStreamAggsBefore(t time.Time) (int64, error) {
+	res := db.SQLite.Where("period_start < ?", t).Delete(StreamAgg{})
+	return res.RowsAffected, res.Error
+}
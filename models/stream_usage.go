@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"EasyDarwin/helper/penggy/EasyGoLib/db"
+)
+
+// StreamUsage holds the bytes a stream sent to its players, counted against
+// its data cap.
+type StreamUsage struct {
+	StreamPath string `gorm:"type:varchar(256);primary_key;unique"`
+	Bytes      int64
+	UpdatedAt  time.Time
+}
+
+func (StreamUsage) TableName() string {
+	return "stream_usage"
+}
+
+// FindStreamUsage returns the bytes saved for path, 0 if there are none.
+func FindStreamUsage(path string) (int64, error) {
+	var usage StreamUsage
+	query := db.SQLite.Where("stream_path = ?", path).First(&usage)
+	if query.RecordNotFound() {
+		return 0, nil
+	}
+	return usage.Bytes, query.Error
+}
+
+func SaveStreamUsage(path string, bytes int64) error {
+	return db.SQLite.Save(&StreamUsage{StreamPath: path, Bytes: bytes}).Error
+}
+
+func DeleteStreamUsage(path string) error {
+	return db.SQLite.Where("stream_path = ?", path).Delete(StreamUsage{}).Error
+}
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"EasyDarwin/helper/penggy/EasyGoLib/db"
+)
+
+// TranscodeProfile is a variant of the stream at Path, transcoded to H.264
+// and AAC and published as the stream <Path>_<Name>, e.g. /live/cam1_720p.
+// The profiles of a stream make its ABR ladder.
+type TranscodeProfile struct {
+	ID           uint   `gorm:"primary_key;AUTO_INCREMENT"`
+	Path         string `gorm:"type:varchar(256);unique_index:idx_transcode_profile_path_name"`
+	Name         string `gorm:"type:varchar(32);unique_index:idx_transcode_profile_path_name"`
+	Width        int    // 0 keeps the aspect ratio of the source
+	Height       int
+	VideoBitrate int // kbps
+	AudioBitrate int // kbps
+}
+
+func (TranscodeProfile) TableName() string {
+	return "transcode_profile"
+}
+
+// FindTranscodeProfiles returns the profiles of every stream, or of path if
+// given, by path then ascending bitrate.
+func FindTranscodeProfiles(path ...string) (profiles []TranscodeProfile, err error) {
+	profiles = make([]TranscodeProfile, 0)
+	query := db.SQLite.Order("path, video_bitrate + audio_bitrate, name")
+	if len(path) > 0 {
+		query = query.Where("path = ?", path[0])
+	}
+	err = query.Find(&profiles).Error
+	return
+}
+
+func SaveTranscodeProfile(profile *TranscodeProfile) error {
+	return db.SQLite.Save(profile).Error
+}
+
+func DeleteTranscodeProfile(id uint) error {
+	return db.SQLite.Where("id = ?", id).Delete(TranscodeProfile{}).Error
+}
@@ -0,0 +1,26 @@
+package routers
+
+import (
+	"net/http"
+
+	"EasyDarwin/helper/gin-gonic/gin"
+)
+
+// RequireClientCert guards the admin APIs of AdminRouter: the request must
+// come over TLS with a client certificate verified against
+// tls_client_ca_file. The listener already refuses other connections, this
+// is a second check. The common name of the certificate is kept in the gin
+// context as "admin_user" and logged.
+func RequireClientCert() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		state := c.Request.TLS
+		if state == nil || len(state.VerifiedChains) == 0 || len(state.PeerCertificates) == 0 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, "client certificate required")
+			return
+		}
+		adminUser := state.PeerCertificates[0].Subject.CommonName
+		c.Set("admin_user", adminUser)
+		RequestLogger(c).Printf("admin %s %s by %q", c.Request.Method, c.Request.URL.Path, adminUser)
+		c.Next()
+	}
+}
@@ -0,0 +1,109 @@
+package routers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"EasyDarwin/helper/gin-gonic/gin"
+	"EasyDarwin/helper/penggy/EasyGoLib/utils"
+	"EasyDarwin/rtsp"
+)
+
+/**
+ * @api {get} /api/v1/stream/alias 获取流别名列表
+ * @apiGroup stream
+ * @apiName StreamAliasList
+ * @apiUse pageParam
+ * @apiUse pageSuccess
+ * @apiSuccess (200) {String} rows.alias 别名PATH
+ * @apiSuccess (200) {String} rows.canonical 别名指向的流PATH
+ */
+func (h *APIHandler) StreamAliasList(c *gin.Context) {
+	form := utils.NewPageForm()
+	if err := c.Bind(form); err != nil {
+		return
+	}
+	rows := make([]interface{}, 0)
+	for _, alias := range rtsp.GetServer().Aliases.List() {
+		if form.Q != "" && !strings.Contains(alias.AliasPath, form.Q) && !strings.Contains(alias.CanonicalPath, form.Q) {
+			continue
+		}
+		rows = append(rows, map[string]interface{}{
+			"alias":     alias.AliasPath,
+			"canonical": alias.CanonicalPath,
+		})
+	}
+	pr := utils.NewPageResult(rows)
+	if form.Sort != "" {
+		pr.Sort(form.Sort, form.Order)
+	}
+	pr.Slice(form.Start, form.Limit)
+	c.IndentedJSON(200, pr)
+}
+
+/**
+ * @api {post} /api/v1/stream/alias 添加或修改流别名
+ * @apiGroup stream
+ * @apiName StreamAliasSave
+ * @apiDescription 播放别名时服务器返回RTSP 302, 重定向到别名指向的流。别名可以指向另一个别名, 最多解析5层, 形成循环的别名会被拒绝。
+ * @apiParam {String} alias 别名PATH
+ * @apiParam {String} canonical 别名指向的流PATH
+ * @apiUse simpleSuccess
+ * @apiUse authError
+ */
+func (h *APIHandler) StreamAliasSave(c *gin.Context) {
+	type Form struct {
+		Alias     string `form:"alias" binding:"required"`
+		Canonical string `form:"canonical" binding:"required"`
+	}
+	var form Form
+	if err := c.Bind(&form); err != nil {
+		return
+	}
+	server := rtsp.GetServer()
+	aliasPath, err := server.NormalizeStreamName(form.Alias)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	canonicalPath, err := server.NormalizeStreamName(form.Canonical)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := server.Aliases.Set(aliasPath, canonicalPath); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, fmt.Sprintf("Save alias err: %v", err))
+		return
+	}
+	c.IndentedJSON(200, "OK")
+}
+
+/**
+ * @api {delete} /api/v1/stream/alias 删除流别名
+ * @apiGroup stream
+ * @apiName StreamAliasDelete
+ * @apiParam {String} alias 别名PATH
+ * @apiUse simpleSuccess
+ * @apiUse authError
+ */
+func (h *APIHandler) StreamAliasDelete(c *gin.Context) {
+	type Form struct {
+		Alias string `form:"alias" binding:"required"`
+	}
+	var form Form
+	if err := c.Bind(&form); err != nil {
+		return
+	}
+	server := rtsp.GetServer()
+	aliasPath, err := server.NormalizeStreamName(form.Alias)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := server.Aliases.Delete(aliasPath); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, fmt.Sprintf("Delete alias err: %v", err))
+		return
+	}
+	c.IndentedJSON(200, "OK")
+}
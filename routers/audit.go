@@ -0,0 +1,60 @@
+package routers
+
+import (
+	"EasyDarwin/helper/gin-gonic/gin"
+	"EasyDarwin/models"
+)
+
+// auditedGETs are the API calls that change the server although they are GETs.
+var auditedGETs = map[string]bool{
+	"/api/v1/modifypassword": true,
+	"/api/v1/stream/start":   true,
+	"/api/v1/stream/stop":    true,
+}
+
+// AuditAPI appends the API calls that change the server to the audit log, all
+// of them with all set, e.g. for the admin API. The query is left out, it may
+// carry passwords.
+func AuditAPI(all bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		method, path := c.Request.Method, c.Request.URL.Path
+		if !all && (method == "GET" || method == "HEAD" || method == "OPTIONS") && !auditedGETs[path] {
+			return
+		}
+		if !models.AuditLog.Enabled() {
+			return
+		}
+		// the logged in user, or the client certificate on AdminRouter
+		subject := c.GetString("uname")
+		if subject == "" {
+			subject = c.GetString("admin_user")
+		}
+		err := models.AuditLog.Append(models.AUDIT_ADMIN_API, subject, map[string]interface{}{
+			"method":    method,
+			"path":      path,
+			"status":    c.Writer.Status(),
+			"clientIP":  c.ClientIP(),
+			"requestId": c.GetString("requestId"),
+		})
+		if err != nil {
+			RequestLogger(c).Printf("audit %s %s err:%v", method, path, err)
+		}
+	}
+}
+
+// auditLogin appends a login attempt of user to the audit log.
+func auditLogin(c *gin.Context, user string, success bool) {
+	if !models.AuditLog.Enabled() {
+		return
+	}
+	err := models.AuditLog.Append(models.AUDIT_AUTH, user, map[string]interface{}{
+		"method":    "login",
+		"success":   success,
+		"clientIP":  c.ClientIP(),
+		"requestId": c.GetString("requestId"),
+	})
+	if err != nil {
+		RequestLogger(c).Printf("audit login of %s err:%v", user, err)
+	}
+}
@@ -0,0 +1,28 @@
+package routers
+
+import (
+	"net/http"
+
+	"EasyDarwin/helper/gin-gonic/gin"
+)
+
+// MaxBodySize rejects requests whose Content-Length exceeds maxBytes with 413
+// before reading the body. Bodies without a length, e.g. chunked ones, fail
+// to read past maxBytes and the connection is closed, so an upload is never
+// buffered whole. maxBytes <= 0 disables the limit.
+func MaxBodySize(maxBytes int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if maxBytes <= 0 || c.Request.Body == nil {
+			c.Next()
+			return
+		}
+		if c.Request.ContentLength > maxBytes {
+			// the unread body would otherwise be drained to reuse the connection
+			c.Header("Connection", "close")
+			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, "Request Entity Too Large")
+			return
+		}
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes)
+		c.Next()
+	}
+}
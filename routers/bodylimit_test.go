@@ -0,0 +1,86 @@
+package routers
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"EasyDarwin/helper/gin-gonic/gin"
+)
+
+func multipartUpload(t *testing.T, size int) (*bytes.Buffer, string) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	file, err := form.CreateFormFile("file", "record.mp4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Write(make([]byte, size))
+	form.Close()
+	return &body, form.FormDataContentType()
+}
+
+func TestMaxBodySize(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(MaxBodySize(1 << 20))
+	handled := 0
+	router.POST("/upload", func(c *gin.Context) {
+		handled++
+		if _, err := c.FormFile("file"); err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+		c.String(http.StatusOK, "ok")
+	})
+
+	upload := func(size int, chunked bool) *httptest.ResponseRecorder {
+		body, contentType := multipartUpload(t, size)
+		req := httptest.NewRequest("POST", "/upload", body)
+		req.Header.Set("Content-Type", contentType)
+		if chunked {
+			req.ContentLength = -1
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := upload(1000, false); w.Code != http.StatusOK {
+		t.Fatalf("small upload status = %d: %s", w.Code, w.Body)
+	}
+	w := upload(2<<20, false)
+	if w.Code != http.StatusRequestEntityTooLarge || w.Header().Get("Connection") != "close" {
+		t.Fatalf("upload over the limit status = %d, Connection %q", w.Code, w.Header().Get("Connection"))
+	}
+	if handled != 1 {
+		t.Fatalf("handler ran %d times, want the large upload rejected before it", handled)
+	}
+	// without a Content-Length the body fails to read past the limit
+	if w := upload(2<<20, true); w.Code != http.StatusBadRequest {
+		t.Fatalf("chunked upload over the limit status = %d", w.Code)
+	}
+}
+
+func TestMaxBodySizeDisabled(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(MaxBodySize(0))
+	router.POST("/upload", func(c *gin.Context) {
+		if _, err := c.FormFile("file"); err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+		c.String(http.StatusOK, "ok")
+	})
+	body, contentType := multipartUpload(t, 2<<20)
+	req := httptest.NewRequest("POST", "/upload", body)
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d with the limit disabled", w.Code)
+	}
+}
@@ -0,0 +1,172 @@
+package routers
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"EasyDarwin/helper/gin-gonic/gin"
+	"EasyDarwin/helper/penggy/EasyGoLib/utils"
+	"EasyDarwin/models"
+	"EasyDarwin/rtsp"
+)
+
+/**
+ * @apiDefine channel 频道
+ */
+
+/**
+ * @api {get} /api/v1/channel 获取频道列表
+ * @apiGroup channel
+ * @apiName ChannelList
+ * @apiUse pageParam
+ * @apiUse pageSuccess
+ * @apiSuccess (200) {Number} rows.id 频道ID
+ * @apiSuccess (200) {String} rows.path 频道的播放PATH
+ * @apiSuccess (200) {String} rows.name 频道名称
+ * @apiSuccess (200) {Boolean} rows.live 是否正在播出
+ * @apiSuccess (200) {Array} rows.items 节目单, 按播出顺序
+ * @apiSuccess (200) {String} rows.items.recordingID 录像文件的相对路径, 即 /api/v1/record/files 返回的path
+ * @apiSuccess (200) {String} rows.items.startTime 开始时间, RFC3339格式, 为空时接在上一个节目之后播出
+ * @apiSuccess (200) {String} rows.items.transitionEffect 转场效果, 为空时直接切换, fade 为淡入
+ */
+func (h *APIHandler) ChannelList(c *gin.Context) {
+	form := utils.NewPageForm()
+	if err := c.Bind(form); err != nil {
+		return
+	}
+	channels, err := models.FindChannels()
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, fmt.Sprintf("Query channels err: %v", err))
+		return
+	}
+	rows := make([]interface{}, 0)
+	for _, channel := range channels {
+		items := make([]interface{}, 0, len(channel.Items))
+		for _, item := range channel.Items {
+			startTime := ""
+			if !item.StartTime.IsZero() {
+				startTime = item.StartTime.Format(time.RFC3339)
+			}
+			items = append(items, map[string]interface{}{
+				"recordingID":      item.RecordingID,
+				"startTime":        startTime,
+				"transitionEffect": item.TransitionEffect,
+			})
+		}
+		pusher := rtsp.GetServer().GetPusher(channel.Path)
+		rows = append(rows, map[string]interface{}{
+			"id":    channel.ID,
+			"path":  channel.Path,
+			"name":  channel.Name,
+			"live":  pusher != nil && pusher.Session != nil && pusher.Session.TransType == rtsp.TRANS_TYPE_CHANNEL,
+			"items": items,
+		})
+	}
+	pr := utils.NewPageResult(rows)
+	if form.Sort != "" {
+		pr.Sort(form.Sort, form.Order)
+	}
+	pr.Slice(form.Start, form.Limit)
+	c.IndentedJSON(200, pr)
+}
+
+/**
+ * @api {post} /api/v1/channel 添加或修改频道
+ * @apiGroup channel
+ * @apiName ChannelSave
+ * @apiDescription 请求体为JSON。频道按节目单顺序播放录像, 像直播流一样通过RTSP播放, 视频以MPEG-TS(MP2T)格式传输。
+ * 频道启动时从开始时间已过的最后一个节目开始, 并跳到其应播放到的位置。需要配置 rtsp.ffmpeg_path 与 rtsp.m3u8_dir_path。
+ * @apiParam {Number} [id] 频道ID, 修改时填写
+ * @apiParam {String} path 频道的播放PATH
+ * @apiParam {String} [name] 频道名称
+ * @apiParam {Array} items 节目单, 按播出顺序
+ * @apiParam {String} items.recordingID 录像文件的相对路径, 即 /api/v1/record/files 返回的path
+ * @apiParam {String} [items.startTime] 开始时间, RFC3339格式, 为空或早于上一个节目结束时接在上一个节目之后播出
+ * @apiParam {String=,fade} [items.transitionEffect] 转场效果, fade 为1秒淡入, 需要转码
+ * @apiSuccess (200) {Number} id 频道ID
+ * @apiUse authError
+ */
+func (h *APIHandler) ChannelSave(c *gin.Context) {
+	type Item struct {
+		RecordingID      string    `json:"recordingID" binding:"required"`
+		StartTime        time.Time `json:"startTime"`
+		TransitionEffect string    `json:"transitionEffect"`
+	}
+	type Form struct {
+		ID    uint   `json:"id"`
+		Path  string `json:"path" binding:"required"`
+		Name  string `json:"name"`
+		Items []Item `json:"items" binding:"required,dive"`
+	}
+	var form Form
+	if err := c.BindJSON(&form); err != nil {
+		return
+	}
+	server := rtsp.GetServer()
+	path, err := server.NormalizeStreamName(form.Path)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	channel := models.Channel{ID: form.ID, Path: path, Name: form.Name}
+	storage, _ := rtsp.NewRecordStorage().(*rtsp.LocalStorage)
+	for _, item := range form.Items {
+		switch item.TransitionEffect {
+		case rtsp.CHANNEL_TRANSITION_CUT, rtsp.CHANNEL_TRANSITION_FADE:
+		default:
+			c.AbortWithStatusJSON(http.StatusBadRequest, fmt.Sprintf("unknown transition effect %q", item.TransitionEffect))
+			return
+		}
+		if storage != nil {
+			file, err := storage.Resolve(item.RecordingID)
+			if err == nil {
+				_, err = os.Stat(file)
+			}
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusBadRequest, fmt.Sprintf("recording %s not found", item.RecordingID))
+				return
+			}
+		}
+		channel.Items = append(channel.Items, models.ScheduledItem{
+			RecordingID:      item.RecordingID,
+			StartTime:        item.StartTime,
+			TransitionEffect: item.TransitionEffect,
+		})
+	}
+	if err := models.SaveChannel(&channel); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, fmt.Sprintf("Save channel err: %v", err))
+		return
+	}
+	if channels := server.Channels(); channels != nil {
+		channels.Reload()
+	}
+	c.IndentedJSON(200, channel.ID)
+}
+
+/**
+ * @api {delete} /api/v1/channel 删除频道
+ * @apiGroup channel
+ * @apiName ChannelDelete
+ * @apiParam {Number} id 频道ID
+ * @apiUse simpleSuccess
+ * @apiUse authError
+ */
+func (h *APIHandler) ChannelDelete(c *gin.Context) {
+	type Form struct {
+		ID uint `form:"id" binding:"required"`
+	}
+	var form Form
+	if err := c.Bind(&form); err != nil {
+		return
+	}
+	if err := models.DeleteChannel(form.ID); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, fmt.Sprintf("Delete channel err: %v", err))
+		return
+	}
+	if channels := rtsp.GetServer().Channels(); channels != nil {
+		channels.Reload()
+	}
+	c.IndentedJSON(200, "OK")
+}
@@ -0,0 +1,36 @@
+package routers
+
+import (
+	"fmt"
+	"net/http"
+
+	"EasyDarwin/helper/gin-gonic/gin"
+	"EasyDarwin/rtsp"
+)
+
+/**
+ * @api {put} /api/v1/admin/config/mdns 开关mDNS服务发现
+ * @apiGroup sys
+ * @apiName SetMDNSConfig
+ * @apiDescription 开启后服务器在局域网内通过mDNS(Bonjour)广播 _rtsp._tcp 服务, TXT记录包含播放路径、版本与流数量。重启服务后恢复配置文件中的 mdns_enable。管理接口，见 http.tls_client_ca_file。
+ * @apiParam {Boolean} enabled 是否开启
+ * @apiSuccess (200) {Boolean} enabled 当前是否开启
+ * @apiUse authError
+ */
+func (h *APIHandler) SetMDNSConfig(c *gin.Context) {
+	type Form struct {
+		Enabled *bool `form:"enabled" json:"enabled" binding:"exists"`
+	}
+	var form Form
+	if err := c.Bind(&form); err != nil {
+		return
+	}
+	server := rtsp.GetServer()
+	if err := server.SetMDNSEnabled(*form.Enabled); err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, fmt.Sprintf("Set mdns err: %v", err))
+		return
+	}
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"enabled": server.MDNSEnabled(),
+	})
+}
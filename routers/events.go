@@ -0,0 +1,60 @@
+package routers
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"EasyDarwin/helper/gin-gonic/gin"
+	"EasyDarwin/rtsp"
+)
+
+/**
+ * @apiDefine events 事件
+ */
+
+/**
+ * @api {get} /api/v1/events/sse 订阅实时事件
+ * @apiGroup events
+ * @apiName EventsSSE
+ * @apiDescription 以 Server-Sent Events (text/event-stream) 推送事件, 每个事件为一行 event: 类型 与一行 data: JSON。
+ * 事件类型有 stream.start, stream.stop, stream.param_changed, subscriber.join, subscriber.leave。
+ * 客户端接收过慢导致缓冲区(http.sse_buffer_size)满时, 服务器会断开连接, 客户端应重新连接。
+ * @apiSuccess (200) {String} event 事件类型
+ * @apiSuccess (200) {String} stream 流PATH
+ * @apiSuccess (200) {Number} time 事件时间, Unix时间戳(秒)
+ * @apiSuccess (200) {Object} [data] 事件数据
+ */
+func (h *APIHandler) EventsSSE(c *gin.Context) {
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	events := rtsp.GetServer().Events
+	ch := events.Subscribe()
+	defer events.Unsubscribe(ch)
+	// comment lines keep proxies from closing an idle stream.
+	ticker := time.NewTicker(15 * time.Second)
+	defer ticker.Stop()
+	done := c.Request.Context().Done()
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case e, ok := <-ch:
+			if !ok {
+				// dropped for not keeping up.
+				return false
+			}
+			c.SSEvent(e.Event, e)
+		case <-ticker.C:
+			if _, err := io.WriteString(w, ": ping\n\n"); err != nil {
+				return false
+			}
+		case <-done:
+			return false
+		}
+		return true
+	})
+}
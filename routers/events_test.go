@@ -0,0 +1,83 @@
+package routers
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"EasyDarwin/helper/gin-gonic/gin"
+	"EasyDarwin/rtsp"
+)
+
+// readSSEvent reads the next event of an SSE stream, skipping comments.
+func readSSEvent(t *testing.T, rd *bufio.Reader) (event string, data string) {
+	for {
+		line, err := rd.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		line = strings.TrimRight(line, "\n")
+		switch {
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimPrefix(line, "event:")
+		case strings.HasPrefix(line, "data:"):
+			data = strings.TrimPrefix(line, "data:")
+		case line == "" && event != "":
+			return event, data
+		}
+	}
+}
+
+func TestEventsSSE(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/v1/events/sse", API.EventsSSE)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	events := rtsp.GetServer().Events
+	subscribers := events.Len()
+	res, err := http.Get(server.URL + "/api/v1/events/sse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ct := res.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %s", ct)
+	}
+	// the headers are flushed before the handler subscribes
+	start := time.Now()
+	for events.Len() != subscribers+1 {
+		if time.Since(start) > 2*time.Second {
+			t.Fatalf("subscribers = %d, want the connection subscribed", events.Len())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	rtsp.GetServer().Emit(rtsp.EVENT_STREAM_START, "/live/a", nil)
+	rtsp.GetServer().Emit(rtsp.EVENT_SUBSCRIBER_JOIN, "/live/a", map[string]string{"id": "p1"})
+	rd := bufio.NewReader(res.Body)
+	for _, want := range []string{rtsp.EVENT_STREAM_START, rtsp.EVENT_SUBSCRIBER_JOIN} {
+		event, data := readSSEvent(t, rd)
+		var e rtsp.StreamEvent
+		if err := json.Unmarshal([]byte(data), &e); err != nil {
+			t.Fatalf("data %q: %v", data, err)
+		}
+		if event != want || e.Event != want || e.Stream != "/live/a" || e.Time == 0 {
+			t.Fatalf("event %s data %s, want %s of /live/a", event, data, want)
+		}
+	}
+
+	// the handler returns once the client goes away
+	res.Body.Close()
+	start = time.Now()
+	for events.Len() != subscribers {
+		if time.Since(start) > 2*time.Second {
+			t.Fatal("still subscribed after the client disconnected")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -2,10 +2,12 @@ package routers
 
 import (
 	"bytes"
-	"log"
+	"fmt"
 	"math"
+	"net/http"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
 	"regexp"
 	"strconv"
@@ -14,6 +16,7 @@ import (
 
 	"EasyDarwin/helper/gin-gonic/gin"
 	"EasyDarwin/helper/penggy/EasyGoLib/utils"
+	"EasyDarwin/rtsp"
 )
 
 /**
@@ -42,33 +45,24 @@ import (
  * @apiSuccess (200) {String} rows.folder	录像文件夹名称
  */
 func (h *APIHandler) RecordFolders(c *gin.Context) {
-	mp4Path := utils.Conf().Section("rtsp").Key("m3u8_dir_path").MustString("")
 	form := utils.NewPageForm()
 	if err := c.Bind(form); err != nil {
-		log.Printf("record folder bind err:%v", err)
+		RequestLogger(c).Printf("record folder bind err:%v", err)
 		return
 	}
 	var files = make([]interface{}, 0)
-	if mp4Path != "" {
-		visit := func(files *[]interface{}) filepath.WalkFunc {
-			return func(path string, info os.FileInfo, err error) error {
-				if err != nil {
-					return err
-				}
-				if path == mp4Path {
-					return nil
-				}
-				if !info.IsDir() {
-					return nil
-				}
-				*files = append(*files, map[string]interface{}{"folder": info.Name()})
-				return filepath.SkipDir
-			}
-		}
-		err := filepath.Walk(mp4Path, visit(&files))
-		if err != nil {
-			log.Printf("Query RecordFolders err:%v", err)
+	entries, err := rtsp.NewRecordStorage().List("")
+	if err != nil {
+		RequestLogger(c).Printf("Query RecordFolders err:%v", err)
+	}
+	folders := make(map[string]bool)
+	for _, entry := range entries {
+		i := strings.Index(entry.Path, "/")
+		if i < 0 || folders[entry.Path[:i]] {
+			continue
 		}
+		folders[entry.Path[:i]] = true
+		files = append(files, map[string]interface{}{"folder": entry.Path[:i]})
 	}
 	pr := utils.NewPageResult(files)
 	if form.Sort != "" {
@@ -106,77 +100,60 @@ func (h *APIHandler) RecordFiles(c *gin.Context) {
 	form.Limit = math.MaxUint32
 	err := c.Bind(&form)
 	if err != nil {
-		log.Printf("record file bind err:%v", err)
+		RequestLogger(c).Printf("record file bind err:%v", err)
 		return
 	}
 
 	files := make([]interface{}, 0)
-	mp4Path := utils.Conf().Section("rtsp").Key("m3u8_dir_path").MustString("")
-	if mp4Path != "" {
-		ffmpeg_path := utils.Conf().Section("rtsp").Key("ffmpeg_path").MustString("")
-		ffmpeg_folder, executable := filepath.Split(ffmpeg_path)
-		split := strings.Split(executable, ".")
-		suffix := ""
-		if len(split) > 1 {
-			suffix = split[1]
+	storage := rtsp.NewRecordStorage()
+	ffmpeg_path := utils.Conf().Section("rtsp").Key("ffmpeg_path").MustString("")
+	ffmpeg_folder, executable := filepath.Split(ffmpeg_path)
+	split := strings.Split(executable, ".")
+	suffix := ""
+	if len(split) > 1 {
+		suffix = split[1]
+	}
+	ffprobe := ffmpeg_folder + "ffprobe" + suffix
+	entries, err := storage.List(strings.Trim(form.Folder, "/") + "/")
+	if err != nil {
+		RequestLogger(c).Printf("Query RecordFiles err:%v", err)
+	}
+	var average = regexp.MustCompile(`Duration: ((\d+):(\d+):(\d+).(\d+))`)
+	for _, entry := range entries {
+		if entry.Size == 0 {
+			continue
 		}
-		ffprobe := ffmpeg_folder + "ffprobe" + suffix
-		folder := filepath.Join(mp4Path, form.Folder)
-		visit := func(files *[]interface{}) filepath.WalkFunc {
-			return func(path string, info os.FileInfo, err error) error {
-				if err != nil {
-					return err
-				}
-				if path == folder {
-					return nil
-				}
-				if info.IsDir() {
-					return nil
-				}
-				if info.Size() == 0 {
-					return nil
-				}
-				if info.Name() == ".DS_Store" {
-					return nil
-				}
-				if !strings.HasSuffix(strings.ToLower(info.Name()), ".m3u8") && !strings.HasSuffix(strings.ToLower(info.Name()), ".ts") {
-					return nil
-				}
-				cmd := exec.Command(ffprobe, "-i", path)
-				cmdOutput := &bytes.Buffer{}
-				//cmd.Stdout = cmdOutput
-				cmd.Stderr = cmdOutput
-				err = cmd.Run()
-				bytes := cmdOutput.Bytes()
-				output := string(bytes)
-				//log.Printf("%v result:%v", cmd, output)
-				var average = regexp.MustCompile(`Duration: ((\d+):(\d+):(\d+).(\d+))`)
-				result := average.FindStringSubmatch(output)
-				duration := time.Duration(0)
-				durationStr := ""
-				if len(result) > 0 {
-					durationStr = result[1]
-					h, _ := strconv.Atoi(result[2])
-					duration += time.Duration(h) * time.Hour
-					m, _ := strconv.Atoi(result[3])
-					duration += time.Duration(m) * time.Minute
-					s, _ := strconv.Atoi(result[4])
-					duration += time.Duration(s) * time.Second
-					millis, _ := strconv.Atoi(result[5])
-					duration += time.Duration(millis) * time.Millisecond
-				}
-
-				*files = append(*files, map[string]interface{}{
-					"path":           path[len(mp4Path):],
-					"durationMillis": duration / time.Millisecond,
-					"duration":       durationStr})
-				return nil
-			}
+		name := strings.ToLower(path.Base(entry.Path))
+		if !strings.HasSuffix(name, ".m3u8") && !strings.HasSuffix(name, ".ts") {
+			continue
 		}
-		err = filepath.Walk(folder, visit(&files))
-		if err != nil {
-			log.Printf("Query RecordFolders err:%v", err)
+		duration := time.Duration(0)
+		durationStr := ""
+		// ffprobe reads local files, other backends report no duration
+		if local, ok := storage.(*rtsp.LocalStorage); ok {
+			file, _ := local.Resolve(entry.Path)
+			cmd := exec.Command(ffprobe, "-i", file)
+			cmdOutput := &bytes.Buffer{}
+			cmd.Stderr = cmdOutput
+			cmd.Run()
+			result := average.FindStringSubmatch(cmdOutput.String())
+			if len(result) > 0 {
+				durationStr = result[1]
+				h, _ := strconv.Atoi(result[2])
+				duration += time.Duration(h) * time.Hour
+				m, _ := strconv.Atoi(result[3])
+				duration += time.Duration(m) * time.Minute
+				s, _ := strconv.Atoi(result[4])
+				duration += time.Duration(s) * time.Second
+				millis, _ := strconv.Atoi(result[5])
+				duration += time.Duration(millis) * time.Millisecond
+			}
 		}
+
+		files = append(files, map[string]interface{}{
+			"path":           "/" + entry.Path,
+			"durationMillis": duration / time.Millisecond,
+			"duration":       durationStr})
 	}
 
 	pr := utils.NewPageResult(files)
@@ -186,3 +163,57 @@ func (h *APIHandler) RecordFiles(c *gin.Context) {
 	pr.Slice(form.Start, form.Limit)
 	c.IndentedJSON(200, pr)
 }
+
+/**
+ * @api {get} /api/v1/record/download 下载录像文件
+ * @apiGroup record
+ * @apiName RecordDownload
+ * @apiParam {String} path 录像文件的相对路径，即 /api/v1/record/files 返回的path
+ * @apiSuccess (200) {File} file 录像文件，文件名为 文件夹_文件名，本地存储时支持Range断点续传
+ */
+func (h *APIHandler) RecordDownload(c *gin.Context) {
+	type Form struct {
+		Path string `form:"path" binding:"required"`
+	}
+	var form = Form{}
+	if err := c.Bind(&form); err != nil {
+		RequestLogger(c).Printf("record download bind err:%v", err)
+		return
+	}
+	folder, name := path.Split(strings.Trim(path.Clean("/"+form.Path), "/"))
+	if folder = strings.Trim(folder, "/"); folder != "" {
+		name = strings.Replace(folder, "/", "_", -1) + "_" + name
+	}
+	storage := rtsp.NewRecordStorage()
+	local, ok := storage.(*rtsp.LocalStorage)
+	if !ok {
+		reader, err := storage.Read(form.Path)
+		if os.IsNotExist(err) {
+			c.AbortWithStatusJSON(http.StatusNotFound, "record file not found")
+			return
+		}
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadGateway, err.Error())
+			return
+		}
+		defer reader.Close()
+		c.DataFromReader(http.StatusOK, -1, "application/octet-stream", reader, map[string]string{
+			"Content-Disposition": fmt.Sprintf("attachment; filename=%q", name),
+		})
+		return
+	}
+	if local.Root == "" {
+		c.AbortWithStatusJSON(http.StatusNotFound, "record storage is not configured")
+		return
+	}
+	file, err := local.Resolve(form.Path)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	if info, err := os.Stat(file); err != nil || info.IsDir() {
+		c.AbortWithStatusJSON(http.StatusNotFound, "record file not found")
+		return
+	}
+	c.FileAttachment(file, name)
+}
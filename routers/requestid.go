@@ -0,0 +1,52 @@
+package routers
+
+import (
+	"crypto/rand"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+
+	"EasyDarwin/helper/gin-gonic/gin"
+	"EasyDarwin/helper/penggy/EasyGoLib/utils"
+	"EasyDarwin/rtsp"
+)
+
+const REQUEST_ID_HEADER = "X-Request-ID"
+
+var requestIDRegexp = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// RequestID takes the request ID from the X-Request-ID header if it is a UUID,
+// otherwise a UUIDv4 is generated. The ID is sent back in X-Request-ID, kept in
+// the gin context as "requestId", and in the request context for rtsp.RequestIDFromContext.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(REQUEST_ID_HEADER)
+		if !requestIDRegexp.MatchString(requestID) {
+			requestID = newUUID()
+		}
+		c.Set("requestId", requestID)
+		c.Header(REQUEST_ID_HEADER, requestID)
+		c.Request = c.Request.WithContext(rtsp.WithRequestID(c.Request.Context(), requestID))
+		c.Next()
+	}
+}
+
+// RequestLogger returns a logger that prefixes every line with the request ID.
+func RequestLogger(c *gin.Context) *log.Logger {
+	logger := log.New(os.Stdout, fmt.Sprintf("[EasyDarwin] [%s] ", c.GetString("requestId")), log.LstdFlags|log.Lshortfile)
+	if !utils.Debug {
+		logger.SetOutput(utils.GetLogWriter())
+	}
+	return logger
+}
+
+func newUUID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	b[6] = b[6]&0x0f | 0x40 // version 4
+	b[8] = b[8]&0x3f | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
@@ -3,7 +3,6 @@ package routers
 import (
 	"EasyDarwin/helper/penggy/EasyGoLib/db"
 	"fmt"
-	"log"
 	"mime"
 	"net/http"
 
@@ -13,6 +12,7 @@ import (
 	"EasyDarwin/helper/penggy/EasyGoLib/utils"
 	"EasyDarwin/helper/penggy/cors"
 	"EasyDarwin/helper/penggy/sessions"
+	"EasyDarwin/rtsp"
 	validator "gopkg.in/go-playground/validator.v8"
 )
 
@@ -51,11 +51,18 @@ import (
 
 var Router *gin.Engine
 
+// AdminRouter serves the admin APIs on a listener of their own, requiring a
+// client certificate, when tls_client_ca_file is configured. It is nil
+// otherwise, the admin APIs are then served by Router to logged in users.
+var AdminRouter *gin.Engine
+
 func init() {
 	mime.AddExtensionType(".svg", "image/svg+xml")
 	mime.AddExtensionType(".m3u8", "application/vnd.apple.mpegurl")
 	// mime.AddExtensionType(".m3u8", "application/x-mpegurl")
 	mime.AddExtensionType(".ts", "video/mp2t")
+	mime.AddExtensionType(".mpd", "application/dash+xml")
+	mime.AddExtensionType(".m4s", "video/iso.segment")
 	// prevent on Windows with Dreamware installed, modified registry .css -> application/x-css
 	// see https://stackoverflow.com/questions/22839278/python-built-in-server-not-loading-css
 	mime.AddExtensionType(".css", "text/css; charset=utf-8")
@@ -82,7 +89,7 @@ func Errors() gin.HandlerFunc {
 						return
 					}
 				default:
-					log.Println(err.Err.Error())
+					RequestLogger(c).Println(err.Err.Error())
 					c.AbortWithStatusJSON(http.StatusBadRequest, "Inner Error")
 					return
 				}
@@ -101,17 +108,42 @@ func NeedLogin() gin.HandlerFunc {
 	}
 }
 
+// SessionUser puts the id and name of the logged in user of the session in
+// the gin context as "uid" and "uname".
+func SessionUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sess := sessions.Default(c)
+		if uid := sess.Get("uid"); uid != nil {
+			c.Set("uid", uid)
+			c.Set("uname", sess.Get("uname"))
+		}
+		c.Next()
+	}
+}
+
 func Init() (err error) {
 	Router = gin.New()
 	pprof.Register(Router)
 	// Router.Use(gin.Logger())
 	Router.Use(gin.Recovery())
+	Router.Use(RequestID())
+	Router.Use(ServerHeader())
+	maxBodySize := utils.Conf().Section("http").Key("max_body_size").MustInt64(10 << 20)
+	Router.MaxBodyBytes = maxBodySize
+	Router.Use(MaxBodySize(maxBodySize))
 	Router.Use(Errors())
 	Router.Use(cors.Default())
 
-	store := sessions.NewGormStoreWithOptions(db.SQLite, sessions.GormStoreOptions{
-		TableName: "t_sessions",
-	}, []byte("EasyDarwin@2018"))
+	// sessions are shared by the nodes through the Redis ring of the RTSP
+	// server when [redis] is configured
+	var store sessions.Store
+	if rtsp.RedisConfigured() {
+		store = sessions.NewRingStore(rtsp.GetServer().Redis)
+	} else {
+		store = sessions.NewGormStoreWithOptions(db.SQLite, sessions.GormStoreOptions{
+			TableName: "t_sessions",
+		}, []byte("EasyDarwin@2018"))
+	}
 	tokenTimeout := utils.Conf().Section("http").Key("token_timeout").MustInt(7 * 86400)
 	store.Options(sessions.Options{HttpOnly: true, MaxAge: tokenTimeout, Path: "/"})
 	sessionHandle := sessions.Sessions("token", store)
@@ -122,24 +154,66 @@ func Init() (err error) {
 		Router.Use(static.Serve("/", static.LocalFile(wwwDir, true)))
 	}
 
+	Router.GET("/metrics", API.Metrics)
+
 	{
-		api := Router.Group("/api/v1").Use(sessionHandle)
+		api := Router.Group("/api/v1").Use(sessionHandle, SessionUser(), AuditAPI(false))
 		api.GET("/login", API.Login)
 		api.GET("/userinfo", API.UserInfo)
 		api.GET("/logout", API.Logout)
 		api.GET("/defaultlogininfo", API.DefaultLoginInfo)
 		api.GET("/modifypassword", NeedLogin(), API.ModifyPassword)
 		api.GET("/serverinfo", API.GetServerInfo)
-		api.GET("/restart", API.Restart)
+		api.GET("/version", API.Version)
 
 		api.GET("/pushers", API.Pushers)
 		api.GET("/players", API.Players)
+		api.GET("/stats", API.Stats)
+		api.GET("/stats/sessions", API.SessionStats)
+		api.GET("/stats/aggregate", API.AggregateStats)
+		api.GET("/redis/slowlog", API.RedisSlowLog)
+		api.GET("/events/sse", API.EventsSSE)
 
 		api.GET("/stream/start", API.StreamStart)
 		api.GET("/stream/stop", API.StreamStop)
+		api.GET("/stream/list", API.StreamList)
+		api.GET("/stream/alias", API.StreamAliasList)
+		api.POST("/stream/alias", NeedLogin(), API.StreamAliasSave)
+		api.DELETE("/stream/alias", NeedLogin(), API.StreamAliasDelete)
 
 		api.GET("/record/folders", API.RecordFolders)
 		api.GET("/record/files", API.RecordFiles)
+		api.GET("/record/download", API.RecordDownload)
+
+		api.GET("/channel", API.ChannelList)
+		api.POST("/channel", NeedLogin(), API.ChannelSave)
+		api.DELETE("/channel", NeedLogin(), API.ChannelDelete)
+
+		api.GET("/transcode/profile", API.TranscodeProfileList)
+		api.POST("/transcode/profile", NeedLogin(), API.TranscodeProfileSave)
+		api.DELETE("/transcode/profile", NeedLogin(), API.TranscodeProfileDelete)
+	}
+
+	{
+		var admin gin.IRoutes
+		sec := utils.Conf().Section("http")
+		if sec.Key("tls_client_ca_file").MustString("") != "" {
+			if sec.Key("tls_cert_file").MustString("") == "" || sec.Key("tls_key_file").MustString("") == "" {
+				return fmt.Errorf("tls_client_ca_file needs tls_cert_file and tls_key_file")
+			}
+			AdminRouter = gin.New()
+			AdminRouter.Use(gin.Recovery())
+			AdminRouter.Use(RequestID())
+			AdminRouter.Use(ServerHeader())
+			AdminRouter.Use(MaxBodySize(maxBodySize))
+			AdminRouter.Use(Errors())
+			admin = AdminRouter.Group("/api/v1/admin").Use(AuditAPI(true), RequireClientCert())
+		} else {
+			AdminRouter = nil
+			admin = Router.Group("/api/v1/admin").Use(sessionHandle, SessionUser(), AuditAPI(true), NeedLogin())
+		}
+		admin.GET("/restart", API.Restart)
+		admin.PUT("/config/mdns", API.SetMDNSConfig)
 	}
 
 	{
@@ -149,6 +223,19 @@ func Init() (err error) {
 			Router.Use(static.Serve("/record", static.LocalFile(mp4Path, true)))
 		}
 
+		// /dash/<stream>/manifest.mpd
+		dashPath := utils.Conf().Section("dash").Key("dir_path").MustString("")
+		if len(dashPath) != 0 {
+			Router.Use(static.Serve("/dash", static.LocalFile(dashPath, false)))
+		}
+
+		// /hls/<stream>_<variant>/index.m3u8, and /hls/<stream>/master.m3u8 generated
+		hlsPath := utils.Conf().Section("abr").Key("hls_dir_path").MustString("")
+		if len(hlsPath) != 0 {
+			Router.Use(static.Serve("/hls", static.LocalFile(hlsPath, false)))
+			Router.GET("/hls/*filepath", API.HLSMaster)
+		}
+
 	}
 
 	return
@@ -2,10 +2,13 @@ package routers
 
 import (
 	"fmt"
+	"net/http"
 	"strings"
+	"time"
 
 	"EasyDarwin/helper/gin-gonic/gin"
 	"EasyDarwin/helper/penggy/EasyGoLib/utils"
+	"EasyDarwin/models"
 	"EasyDarwin/rtsp"
 )
 
@@ -132,3 +135,186 @@ func (h *APIHandler) Players(c *gin.Context) {
 	pr.Slice(form.Start, form.Limit)
 	c.IndentedJSON(200, pr)
 }
+
+/**
+ * @api {get} /api/v1/stats 获取节点负载
+ * @apiGroup stats
+ * @apiName Stats
+ * @apiDescription 前端负载均衡节点轮询后端节点的该接口, 按各路流的播放人数分配播放端。
+ * @apiSuccess (200) {Number} rtspPort RTSP端口
+ * @apiSuccess (200) {Number} pushers 推流数
+ * @apiSuccess (200) {Number} players 播放人数
+ * @apiSuccess (200) {Object} streams 各路流的播放人数, 以流的PATH为键
+ */
+func (h *APIHandler) Stats(c *gin.Context) {
+	c.IndentedJSON(200, rtsp.GetServer().NodeStats())
+}
+
+/**
+ * @api {get} /api/v1/redis/slowlog 获取Redis慢命令日志
+ * @apiGroup stats
+ * @apiName RedisSlowLog
+ * @apiDescription 所有Redis分片上耗时超过 slow_threshold_ms 的最近128条命令, 最新的在前。
+ * @apiSuccess (200) {Object[]} - 慢命令列表
+ * @apiSuccess (200) {String} -.time 命令开始时间
+ * @apiSuccess (200) {String} -.shard 分片名称
+ * @apiSuccess (200) {String} -.command 命令名称
+ * @apiSuccess (200) {String[]} -.args 命令参数, 超过32个或单个超过128字节时被截断
+ * @apiSuccess (200) {String} -.duration 格式化好的耗时
+ * @apiSuccess (200) {Number} -.durationMillis 耗时, 毫秒为单位
+ * @apiSuccess (200) {String} -.err 命令的错误, 成功时为空
+ */
+func (h *APIHandler) RedisSlowLog(c *gin.Context) {
+	rows := make([]gin.H, 0)
+	for _, entry := range rtsp.RedisSlowLog().Entries() {
+		errStr := ""
+		if entry.Err != nil {
+			errStr = entry.Err.Error()
+		}
+		rows = append(rows, gin.H{
+			"time":           utils.DateTime(entry.Time),
+			"shard":          entry.Shard,
+			"command":        entry.Command,
+			"args":           entry.Args,
+			"duration":       entry.Duration.String(),
+			"durationMillis": float64(entry.Duration) / float64(time.Millisecond),
+			"err":            errStr,
+		})
+	}
+	c.IndentedJSON(200, rows)
+}
+
+/**
+ * @api {get} /metrics 获取Prometheus监控指标
+ * @apiGroup stats
+ * @apiName Metrics
+ */
+func (h *APIHandler) Metrics(c *gin.Context) {
+	c.Header("Content-Type", "text/plain; version=0.0.4")
+	c.Status(http.StatusOK)
+	rtsp.WriteMetrics(c.Writer)
+}
+
+/**
+ * @api {get} /api/v1/stats/sessions 查询历史会话统计
+ * @apiGroup stats
+ * @apiName SessionStats
+ * @apiParam {String} [stream] 流PATH
+ * @apiParam {Number} [from] 会话开始时间下限, Unix时间戳(秒)
+ * @apiParam {Number} [to] 会话开始时间上限, Unix时间戳(秒)
+ * @apiParam {Number} [start] 分页开始,从零开始
+ * @apiParam {Number} [limit] 分页大小
+ * @apiUse pageSuccess
+ * @apiSuccess (200) {String} rows.sessionId
+ * @apiSuccess (200) {String} rows.streamPath 流PATH
+ * @apiSuccess (200) {String} rows.clientIp 客户端IP
+ * @apiSuccess (200) {String=pub,sub} rows.role 推流或拉流, 认证失败的会话为空
+ * @apiSuccess (200) {String} rows.startTime 开始时间
+ * @apiSuccess (200) {String} rows.endTime 结束时间
+ * @apiSuccess (200) {Number} rows.bytesTransferred 传输字节数
+ * @apiSuccess (200) {Number} rows.packetsLost 丢包数
+ * @apiSuccess (200) {String=normal,timeout,server_shutdown,publisher_gone,auth_failure} rows.disconnectReason 断开原因
+ */
+func (h *APIHandler) SessionStats(c *gin.Context) {
+	type Form struct {
+		utils.PageForm
+		Stream string `form:"stream"`
+		From   int64  `form:"from"`
+		To     int64  `form:"to"`
+	}
+	form := Form{PageForm: *utils.NewPageForm()}
+	if err := c.Bind(&form); err != nil {
+		return
+	}
+	var from, to time.Time
+	if form.From > 0 {
+		from = time.Unix(form.From, 0)
+	}
+	if form.To > 0 {
+		to = time.Unix(form.To, 0)
+	}
+	stats, total, err := models.FindSessionStats(form.Stream, from, to, form.Start, form.Limit)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, fmt.Sprintf("Query session stats err: %v", err))
+		return
+	}
+	rows := make([]interface{}, 0, len(stats))
+	for _, stat := range stats {
+		rows = append(rows, map[string]interface{}{
+			"sessionId":        stat.SessionID,
+			"streamPath":       stat.StreamPath,
+			"clientIp":         stat.ClientIP,
+			"role":             stat.Role,
+			"startTime":        utils.DateTime(stat.StartTime),
+			"endTime":          utils.DateTime(stat.EndTime),
+			"bytesTransferred": stat.BytesTransferred,
+			"packetsLost":      stat.PacketsLost,
+			"disconnectReason": stat.DisconnectReason,
+		})
+	}
+	c.IndentedJSON(200, utils.PageResult{Total: total, Rows: rows})
+}
+
+/**
+ * @api {get} /api/v1/stats/aggregate 查询流统计汇总
+ * @apiGroup stats
+ * @apiName AggregateStats
+ * @apiDescription 按时间窗口汇总的每路流统计, 每个窗口结束时生成一行, 按时间升序返回, 可用于绘制趋势图。
+ * @apiParam {String} [stream] 流PATH, 为空时返回所有流
+ * @apiParam {String=1m,5m,1h} [window=1m] 时间窗口
+ * @apiParam {Number} [from] 窗口开始时间下限, Unix时间戳(秒)
+ * @apiParam {Number} [to] 窗口开始时间上限(不含), Unix时间戳(秒)
+ * @apiSuccess (200) {Number} total 总数
+ * @apiSuccess (200) {Array} rows 汇总列表
+ * @apiSuccess (200) {String} rows.streamPath 流PATH
+ * @apiSuccess (200) {String} rows.window 时间窗口
+ * @apiSuccess (200) {Number} rows.periodStart 窗口开始时间, Unix时间戳(秒)
+ * @apiSuccess (200) {Number} rows.avgBitrateKbps 推流平均码率(kbps)
+ * @apiSuccess (200) {Number} rows.peakBitrateKbps 推流峰值码率(kbps)
+ * @apiSuccess (200) {Number} rows.totalSubscribers 窗口内的播放人次
+ * @apiSuccess (200) {Number} rows.totalBytes 窗口内推流与播放的总流量
+ */
+func (h *APIHandler) AggregateStats(c *gin.Context) {
+	type Form struct {
+		Stream string `form:"stream"`
+		Window string `form:"window"`
+		From   int64  `form:"from"`
+		To     int64  `form:"to"`
+	}
+	var form Form
+	if err := c.Bind(&form); err != nil {
+		return
+	}
+	if form.Window == "" {
+		form.Window = "1m"
+	}
+	if !rtsp.IsStatAggWindow(form.Window) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, fmt.Sprintf("Unknown window %s", form.Window))
+		return
+	}
+	var from, to time.Time
+	if form.From > 0 {
+		from = time.Unix(form.From, 0)
+	}
+	if form.To > 0 {
+		to = time.Unix(form.To, 0)
+	}
+	aggs, err := models.FindStreamAggs(form.Stream, form.Window, from, to)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, fmt.Sprintf("Query aggregate stats err: %v", err))
+		return
+	}
+	rows := make([]interface{}, 0, len(aggs))
+	for _, agg := range aggs {
+		rows = append(rows, map[string]interface{}{
+			"streamPath":       agg.StreamPath,
+			"window":           agg.Window,
+			"periodStart":      agg.PeriodStart.Unix(),
+			"avgBitrateKbps":   agg.AvgBitrateKbps,
+			"peakBitrateKbps":  agg.PeakBitrateKbps,
+			"totalSubscribers": agg.TotalSubscribers,
+			"totalBytes":       agg.TotalBytes,
+		})
+	}
+	c.IndentedJSON(200, utils.PageResult{Total: len(rows), Rows: rows})
+}
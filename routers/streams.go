@@ -2,12 +2,12 @@ package routers
 
 import (
 	"fmt"
-	"log"
 	"net/http"
 	"strings"
 	"time"
 
 	"EasyDarwin/helper/penggy/EasyGoLib/db"
+	"EasyDarwin/helper/penggy/EasyGoLib/utils"
 	"EasyDarwin/models"
 
 	"EasyDarwin/helper/gin-gonic/gin"
@@ -27,6 +27,11 @@ import (
  * @apiParam {String=TCP,UDP} [transType=TCP] 拉流传输模式
  * @apiParam {Number} [idleTimeout] 拉流时的超时时间
  * @apiParam {Number} [heartbeatInterval] 拉流时的心跳间隔，毫秒为单位。如果心跳间隔不为0，那拉流时会向源地址以该间隔发送OPTION请求用来心跳保活
+ * @apiParam {String} [name] 流名称
+ * @apiParam {String} [description] 流描述
+ * @apiParam {String} [metadata] 流的其他信息，可用于全文检索
+ * @apiParam {Number} [dataCapBytes] 该路流向播放端发送的总字节数上限，达到后断开所有播放端并停止拉流。为0时使用配置的 stream_data_cap_bytes
+ * @apiParam {Number} [stallTimeout] 该路流超过多少秒未收到数据时TEARDOWN并重新拉流，同一PATH的推流端也以此为准。为0时使用配置的 stall_timeout，为负数时不检测
  * @apiSuccess (200) {String} ID	拉流的ID。后续可以通过该ID来停止拉流
  */
 func (h *APIHandler) StreamStart(c *gin.Context) {
@@ -36,18 +41,23 @@ func (h *APIHandler) StreamStart(c *gin.Context) {
 		TransType         string `form:"transType"`
 		IdleTimeout       int    `form:"idleTimeout"`
 		HeartbeatInterval int    `form:"heartbeatInterval"`
+		Name              string `form:"name"`
+		Description       string `form:"description"`
+		Metadata          string `form:"metadata"`
+		DataCapBytes      int64  `form:"dataCapBytes"`
+		StallTimeout      int    `form:"stallTimeout"`
 	}
 	var form Form
 	err := c.Bind(&form)
 	if err != nil {
-		log.Printf("Pull to push err:%v", err)
+		RequestLogger(c).Printf("Pull to push err:%v", err)
 		return
 	}
 	agent := fmt.Sprintf("EasyDarwinGo/%s", BuildVersion)
 	if BuildDateTime != "" {
 		agent = fmt.Sprintf("%s(%s)", agent, BuildDateTime)
 	}
-	client, err := rtsp.NewRTSPClient(rtsp.GetServer(), form.URL, int64(form.HeartbeatInterval)*1000, agent)
+	client, err := rtsp.NewRTSPClientContext(c.Request.Context(), rtsp.GetServer(), form.URL, int64(form.HeartbeatInterval)*1000, agent)
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
 		return
@@ -55,6 +65,14 @@ func (h *APIHandler) StreamStart(c *gin.Context) {
 	if form.CustomPath != "" && !strings.HasPrefix(form.CustomPath, "/") {
 		form.CustomPath = "/" + form.CustomPath
 	}
+	rawName := form.CustomPath
+	if rawName == "" {
+		rawName = client.Path
+	}
+	if form.CustomPath, err = rtsp.GetServer().NormalizeStreamName(rawName); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
+		return
+	}
 	client.CustomPath = form.CustomPath
 	switch strings.ToLower(form.TransType) {
 	case "udp":
@@ -70,20 +88,37 @@ func (h *APIHandler) StreamStart(c *gin.Context) {
 		c.AbortWithStatusJSON(http.StatusBadRequest, fmt.Sprintf("Path %s already exists", client.Path))
 		return
 	}
+	pusher.SetDataCap(form.DataCapBytes)
+	pusher.SetStallTimeout(form.StallTimeout)
+	if pusher.DataCapExceeded() {
+		c.AbortWithStatusJSON(http.StatusForbidden, fmt.Sprintf("Path %s used up its data cap", pusher.Path()))
+		return
+	}
 	err = client.Start(time.Duration(form.IdleTimeout) * time.Second)
 	if err != nil {
-		log.Printf("Pull stream err :%v", err)
+		RequestLogger(c).Printf("Pull stream err :%v", err)
 		c.AbortWithStatusJSON(http.StatusBadRequest, fmt.Sprintf("Pull stream err: %v", err))
 		return
 	}
-	log.Printf("Pull to push %v success ", form)
-	rtsp.GetServer().AddPusher(pusher)
+	if !rtsp.GetServer().AddPusher(pusher) {
+		client.Stop()
+		c.AbortWithStatusJSON(http.StatusBadRequest, fmt.Sprintf("Path %s can not be added", pusher.Path()))
+		return
+	}
+	RequestLogger(c).Printf("Pull to push %v success ", form)
 	// save to db.
 	var stream = models.Stream{
 		URL:               form.URL,
 		CustomPath:        form.CustomPath,
+		RawName:           rawName,
 		IdleTimeout:       form.IdleTimeout,
 		HeartbeatInterval: form.HeartbeatInterval,
+		Name:              form.Name,
+		Description:       form.Description,
+		Metadata:          form.Metadata,
+		DataCapBytes:      form.DataCapBytes,
+		StallTimeout:      form.StallTimeout,
+		Codec:             rtsp.VideoCodecName(pusher.VCodec()),
 	}
 	if db.SQLite.Where(&models.Stream{URL: form.URL}).First(&models.Stream{}).RecordNotFound() {
 		db.SQLite.Create(&stream)
@@ -107,7 +142,7 @@ func (h *APIHandler) StreamStop(c *gin.Context) {
 	var form Form
 	err := c.Bind(&form)
 	if err != nil {
-		log.Printf("stop pull to push err:%v", err)
+		RequestLogger(c).Printf("stop pull to push err:%v", err)
 		return
 	}
 	pushers := rtsp.GetServer().GetPushers()
@@ -115,7 +150,7 @@ func (h *APIHandler) StreamStop(c *gin.Context) {
 		if v.ID() == form.ID {
 			v.Stop()
 			c.IndentedJSON(200, "OK")
-			log.Printf("Stop %v success ", v)
+			RequestLogger(c).Printf("Stop %v success ", v)
 			if v.RTSPClient != nil {
 				var stream models.Stream
 				stream.URL = v.RTSPClient.URL
@@ -126,3 +161,73 @@ func (h *APIHandler) StreamStop(c *gin.Context) {
 	}
 	c.AbortWithStatusJSON(http.StatusBadRequest, fmt.Sprintf("Pusher[%s] not found", form.ID))
 }
+
+/**
+ * @api {get} /api/v1/stream/list 获取拉转推列表
+ * @apiGroup stream
+ * @apiName StreamList
+ * @apiUse pageParam
+ * @apiParam {String} [q] 全文检索参数, 匹配name, description, metadata
+ * @apiUse pageSuccess
+ * @apiSuccess (200) {String} rows.url RTSP源地址
+ * @apiSuccess (200) {String} rows.customPath 转推时的推送PATH
+ * @apiSuccess (200) {String} rows.rawName 规范化之前的推送PATH
+ * @apiSuccess (200) {String} rows.name 流名称
+ * @apiSuccess (200) {String} rows.description 流描述
+ * @apiSuccess (200) {String} rows.metadata 流的其他信息
+ * @apiSuccess (200) {String} rows.codec 视频编码, H264 或 H265, 最近一次拉流时记录
+ * @apiSuccess (200) {Object} [rows.highlights] 全文检索时各字段的匹配片段, 匹配部分以<b></b>标记
+ */
+func (h *APIHandler) StreamList(c *gin.Context) {
+	form := utils.NewPageForm()
+	if err := c.Bind(form); err != nil {
+		return
+	}
+	rows := make([]interface{}, 0)
+	if form.Q != "" && models.StreamFTSEnabled {
+		results, err := models.SearchStreams(form.Q)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, fmt.Sprintf("Search stream err: %v", err))
+			return
+		}
+		for _, result := range results {
+			row := streamRow(result.Stream)
+			row["highlights"] = result.Highlights
+			rows = append(rows, row)
+		}
+	} else {
+		streams := make([]models.Stream, 0)
+		query := db.SQLite
+		if form.Q != "" {
+			like := "%" + form.Q + "%"
+			query = query.Where("url LIKE ? OR name LIKE ? OR description LIKE ? OR metadata LIKE ?", like, like, like, like)
+		}
+		if err := query.Find(&streams).Error; err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, fmt.Sprintf("List stream err: %v", err))
+			return
+		}
+		for _, stream := range streams {
+			rows = append(rows, streamRow(stream))
+		}
+	}
+	pr := utils.NewPageResult(rows)
+	if form.Sort != "" {
+		pr.Sort(form.Sort, form.Order)
+	}
+	pr.Slice(form.Start, form.Limit)
+	c.IndentedJSON(200, pr)
+}
+
+func streamRow(stream models.Stream) map[string]interface{} {
+	return map[string]interface{}{
+		"url":               stream.URL,
+		"customPath":        stream.CustomPath,
+		"rawName":           stream.RawName,
+		"idleTimeout":       stream.IdleTimeout,
+		"heartbeatInterval": stream.HeartbeatInterval,
+		"name":              stream.Name,
+		"description":       stream.Description,
+		"metadata":          stream.Metadata,
+		"codec":             stream.Codec,
+	}
+}
@@ -2,7 +2,6 @@ package routers
 
 import (
 	"fmt"
-	"log"
 	"net/http"
 	"runtime"
 	"strings"
@@ -16,6 +15,7 @@ import (
 	"EasyDarwin/helper/shirou/gopsutil/mem"
 	"EasyDarwin/models"
 	"EasyDarwin/rtsp"
+	"EasyDarwin/version"
 )
 
 /**
@@ -122,13 +122,39 @@ func (h *APIHandler) GetServerInfo(c *gin.Context) {
 }
 
 /**
- * @api {get} /api/v1/restart 重启服务
+ * @api {get} /api/v1/version 获取版本
+ * @apiGroup sys
+ * @apiName Version
+ * @apiSuccess (200) {String} version 版本号, 如 8.1.0
+ * @apiSuccess (200) {String} buildTime 编译时间, 开发版本为空
+ * @apiSuccess (200) {String} gitCommit 编译时的git提交, 开发版本为空
+ */
+func (h *APIHandler) Version(c *gin.Context) {
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"version":   version.Version,
+		"buildTime": version.BuildTime,
+		"gitCommit": version.GitCommit,
+	})
+}
+
+// ServerHeader sets the Server header of the responses, as the RTSP server does.
+func ServerHeader() gin.HandlerFunc {
+	banner := version.Banner()
+	return func(c *gin.Context) {
+		c.Header("Server", banner)
+		c.Next()
+	}
+}
+
+/**
+ * @api {get} /api/v1/admin/restart 重启服务
  * @apiGroup sys
  * @apiName Restart
+ * @apiDescription 管理接口，见 http.tls_client_ca_file。原 /api/v1/restart 已移除。
  * @apiUse simpleSuccess
  */
 func (h *APIHandler) Restart(c *gin.Context) {
-	log.Println("Restart...")
+	RequestLogger(c).Println("Restart...")
 	c.JSON(http.StatusOK, "OK")
 	go func() {
 		select {
@@ -167,13 +193,16 @@ func (h *APIHandler) Login(c *gin.Context) {
 	var user models.User
 	db.SQLite.Where(&models.User{Username: form.Username}).First(&user)
 	if user.ID == "" {
+		auditLogin(c, form.Username, false)
 		c.AbortWithStatusJSON(401, "用户名或密码错误")
 		return
 	}
 	if !strings.EqualFold(user.Password, form.Password) {
+		auditLogin(c, form.Username, false)
 		c.AbortWithStatusJSON(401, "用户名或密码错误")
 		return
 	}
+	auditLogin(c, form.Username, true)
 	sess := sessions.Default(c)
 	sess.Set("uid", user.ID)
 	sess.Set("uname", user.Username)
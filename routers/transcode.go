@@ -0,0 +1,176 @@
+package routers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"EasyDarwin/helper/gin-gonic/gin"
+	"EasyDarwin/models"
+	"EasyDarwin/rtsp"
+)
+
+/**
+ * @apiDefine transcode 转码
+ */
+
+/**
+ * @api {get} /api/v1/transcode/profile 获取转码配置列表
+ * @apiGroup transcode
+ * @apiName TranscodeProfileList
+ * @apiParam {String} [path] 源流的PATH, 为空时返回所有流的转码配置
+ * @apiSuccess (200) {Object[]} - 转码配置列表, 按源流PATH与码率排序
+ * @apiSuccess (200) {Number} -.id 转码配置ID
+ * @apiSuccess (200) {String} -.path 源流的PATH
+ * @apiSuccess (200) {String} -.name 码率档位名称
+ * @apiSuccess (200) {String} -.variantPath 转码后的流的PATH, 即 <path>_<name>
+ * @apiSuccess (200) {Number} -.width 宽度, 0为按高度等比缩放
+ * @apiSuccess (200) {Number} -.height 高度, 0为不缩放
+ * @apiSuccess (200) {Number} -.videoBitrate 视频码率, kbps
+ * @apiSuccess (200) {Number} -.audioBitrate 音频码率, kbps
+ * @apiSuccess (200) {Boolean} -.live 转码后的流是否在推流
+ */
+func (h *APIHandler) TranscodeProfileList(c *gin.Context) {
+	var path []string
+	if p := c.Query("path"); p != "" {
+		normalized, err := rtsp.GetServer().NormalizeStreamName(p)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
+			return
+		}
+		path = append(path, normalized)
+	}
+	profiles, err := models.FindTranscodeProfiles(path...)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, fmt.Sprintf("Query transcode profiles err: %v", err))
+		return
+	}
+	rows := make([]interface{}, 0, len(profiles))
+	for _, profile := range profiles {
+		variantPath := rtsp.VariantPath(profile)
+		rows = append(rows, map[string]interface{}{
+			"id":           profile.ID,
+			"path":         profile.Path,
+			"name":         rtsp.VariantName(profile),
+			"variantPath":  variantPath,
+			"width":        profile.Width,
+			"height":       profile.Height,
+			"videoBitrate": profile.VideoBitrate,
+			"audioBitrate": profile.AudioBitrate,
+			"live":         rtsp.GetServer().GetPusher(variantPath) != nil,
+		})
+	}
+	c.IndentedJSON(200, rows)
+}
+
+/**
+ * @api {post} /api/v1/transcode/profile 添加或修改转码配置
+ * @apiGroup transcode
+ * @apiName TranscodeProfileSave
+ * @apiDescription 请求体为JSON。源流推流时, 每个转码配置由一个ffmpeg转码为H.264与AAC, 作为流 <path>_<name> 推回本服务,
+ * 配置了 abr.hls_dir_path 时同时输出HLS, 各档位汇总在 /hls/<path>/master.m3u8 中供播放器自适应码率切换。
+ * 添加、修改或删除一个档位时只启停该档位, 其他档位与源流的播放端不受影响。需要配置 rtsp.ffmpeg_path。
+ * @apiParam {Number} [id] 转码配置ID, 修改时填写
+ * @apiParam {String} path 源流的PATH
+ * @apiParam {String} [name] 码率档位名称, 只能包含字母、数字、-与_, 为空时为 <height>p, 如 720p
+ * @apiParam {Number} [width] 宽度, 为0时按高度等比缩放
+ * @apiParam {Number} height 高度
+ * @apiParam {Number} videoBitrate 视频码率, kbps
+ * @apiParam {Number} [audioBitrate=128] 音频码率, kbps
+ * @apiSuccess (200) {Number} id 转码配置ID
+ * @apiUse authError
+ */
+func (h *APIHandler) TranscodeProfileSave(c *gin.Context) {
+	type Form struct {
+		ID           uint   `json:"id"`
+		Path         string `json:"path" binding:"required"`
+		Name         string `json:"name"`
+		Width        int    `json:"width"`
+		Height       int    `json:"height" binding:"required"`
+		VideoBitrate int    `json:"videoBitrate" binding:"required"`
+		AudioBitrate int    `json:"audioBitrate"`
+	}
+	var form Form
+	if err := c.BindJSON(&form); err != nil {
+		return
+	}
+	server := rtsp.GetServer()
+	path, err := server.NormalizeStreamName(form.Path)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	if strings.Trim(form.Name, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_") != "" {
+		c.AbortWithStatusJSON(http.StatusBadRequest, fmt.Sprintf("invalid profile name %q", form.Name))
+		return
+	}
+	if form.Width < 0 || form.Height <= 0 || form.VideoBitrate <= 0 || form.AudioBitrate < 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, "width, height and bitrates must be positive")
+		return
+	}
+	if form.AudioBitrate == 0 {
+		form.AudioBitrate = 128
+	}
+	profile := models.TranscodeProfile{
+		ID:           form.ID,
+		Path:         path,
+		Width:        form.Width,
+		Height:       form.Height,
+		VideoBitrate: form.VideoBitrate,
+		AudioBitrate: form.AudioBitrate,
+	}
+	profile.Name = rtsp.VariantName(models.TranscodeProfile{Name: form.Name, Height: form.Height})
+	if err := models.SaveTranscodeProfile(&profile); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, fmt.Sprintf("Save transcode profile err: %v", err))
+		return
+	}
+	if abr := server.ABR(); abr != nil {
+		abr.Reload()
+	}
+	c.IndentedJSON(200, profile.ID)
+}
+
+/**
+ * @api {delete} /api/v1/transcode/profile 删除转码配置
+ * @apiGroup transcode
+ * @apiName TranscodeProfileDelete
+ * @apiParam {Number} id 转码配置ID
+ * @apiUse simpleSuccess
+ * @apiUse authError
+ */
+func (h *APIHandler) TranscodeProfileDelete(c *gin.Context) {
+	type Form struct {
+		ID uint `form:"id" binding:"required"`
+	}
+	var form Form
+	if err := c.Bind(&form); err != nil {
+		return
+	}
+	if err := models.DeleteTranscodeProfile(form.ID); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, fmt.Sprintf("Delete transcode profile err: %v", err))
+		return
+	}
+	if abr := rtsp.GetServer().ABR(); abr != nil {
+		abr.Reload()
+	}
+	c.IndentedJSON(200, "OK")
+}
+
+// HLSMaster serves /hls/<stream>/master.m3u8, the master playlist of the ABR
+// ladder of the stream. The playlists and segments of the variants are
+// served from abr.hls_dir_path.
+func (h *APIHandler) HLSMaster(c *gin.Context) {
+	file := c.Param("filepath")
+	abr := rtsp.GetServer().ABR()
+	if abr == nil || !strings.HasSuffix(file, "/master.m3u8") {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	playlist, ok := abr.MasterPlaylist(strings.TrimSuffix(file, "/master.m3u8"), "/hls")
+	if !ok {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	c.Header("Cache-Control", "no-cache")
+	c.Data(http.StatusOK, "application/vnd.apple.mpegurl", []byte(playlist))
+}
@@ -1,8 +1,11 @@
 package routers
 
-import "EasyDarwin/helper/penggy/EasyGoLib/utils"
+import (
+	"EasyDarwin/helper/penggy/EasyGoLib/utils"
+	"EasyDarwin/version"
+)
 
-var BuildVersion = "v8.1"
+var BuildVersion = version.String()
 var BuildDateTime = ""
 
 type PercentData struct {
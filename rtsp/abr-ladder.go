@@ -0,0 +1,319 @@
+package rtsp
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"EasyDarwin/helper/penggy/EasyGoLib/utils"
+	"EasyDarwin/models"
+)
+
+// ABR_INTERVAL is how often the ABR ladders are checked: the variants of a
+// source that started are started, and a variant whose ffmpeg exited is
+// restarted.
+const ABR_INTERVAL = 5 * time.Second
+
+// VariantName returns the name of the variant of profile, its Name or
+// <height>p.
+func VariantName(profile models.TranscodeProfile) string {
+	if profile.Name != "" {
+		return profile.Name
+	}
+	return fmt.Sprintf("%dp", profile.Height)
+}
+
+// VariantPath returns the path of the stream of the variant of profile,
+// e.g. /live/cam1_720p.
+func VariantPath(profile models.TranscodeProfile) string {
+	return profile.Path + "_" + VariantName(profile)
+}
+
+// ABRLadder is the adaptive bitrate ladder of a stream: one variant per
+// TranscodeProfile of its path, transcoded by ffmpeg while the stream is
+// live. A variant is published back to the server as the stream
+// <path>_<name>, and with an HLS directory written as live HLS under
+// <dir>/<path>_<name>/index.m3u8 too. The keyframes of every variant are
+// forced at the same times, every HLS segment, so players switch between
+// them at segment boundaries.
+type ABRLadder struct {
+	Path string
+
+	manager  *ABRManager
+	variants map[string]*abrVariant // variant path <-> variant
+}
+
+type abrVariant struct {
+	profile models.TranscodeProfile
+	cmd     *exec.Cmd
+	exited  chan struct{}
+}
+
+func (variant *abrVariant) running() bool {
+	select {
+	case <-variant.exited:
+		return false
+	default:
+		return true
+	}
+}
+
+// sync makes the running variants those of profiles while the source is
+// live. Only the variants whose profile was added, removed or changed are
+// started or stopped, the subscribers of the others are left alone.
+func (ladder *ABRLadder) sync(profiles []models.TranscodeProfile, live bool) {
+	logger := ladder.manager.logger
+	wanted := make(map[string]models.TranscodeProfile)
+	if live {
+		for _, profile := range profiles {
+			wanted[VariantPath(profile)] = profile
+		}
+	}
+	for variantPath, variant := range ladder.variants {
+		if profile, ok := wanted[variantPath]; ok && profile == variant.profile && variant.running() {
+			continue
+		}
+		ladder.manager.stopVariant(variant)
+		delete(ladder.variants, variantPath)
+	}
+	for variantPath, profile := range wanted {
+		if _, ok := ladder.variants[variantPath]; ok {
+			continue
+		}
+		variant, err := ladder.manager.startVariant(profile)
+		if err != nil {
+			logger.Printf("Start ABR variant %s err:%v", variantPath, err)
+			continue
+		}
+		ladder.variants[variantPath] = variant
+	}
+}
+
+func (ladder *ABRLadder) stop() {
+	for variantPath, variant := range ladder.variants {
+		ladder.manager.stopVariant(variant)
+		delete(ladder.variants, variantPath)
+	}
+}
+
+// ABRManager runs an ABRLadder for each stream with TranscodeProfiles. The
+// profiles are reloaded every ABR_INTERVAL and by Reload.
+type ABRManager struct {
+	// HLS output of the variants, none if empty
+	HLSDir      string
+	HLSTime     int
+	HLSListSize int
+
+	server *Server
+	ffmpeg string
+	logger *log.Logger
+	reload chan struct{}
+	done   chan struct{}
+
+	ladders map[string]*ABRLadder // source path <-> ladder
+}
+
+func NewABRManager(server *Server, ffmpeg string) *ABRManager {
+	sec := utils.Conf().Section("abr")
+	manager := &ABRManager{
+		HLSDir:      sec.Key("hls_dir_path").MustString(""),
+		HLSTime:     sec.Key("hls_time").MustInt(4),
+		HLSListSize: sec.Key("hls_list_size").MustInt(6),
+		server:      server,
+		ffmpeg:      ffmpeg,
+		logger:      server.logger,
+		reload:      make(chan struct{}, 1),
+		done:        make(chan struct{}),
+		ladders:     make(map[string]*ABRLadder),
+	}
+	go manager.run()
+	return manager
+}
+
+// Reload reloads the profiles after a change.
+func (manager *ABRManager) Reload() {
+	select {
+	case manager.reload <- struct{}{}:
+	default:
+	}
+}
+
+// Stop stops the manager and the variants.
+func (manager *ABRManager) Stop() {
+	close(manager.done)
+}
+
+func (manager *ABRManager) run() {
+	ticker := time.NewTicker(ABR_INTERVAL)
+	defer ticker.Stop()
+	for {
+		manager.load()
+		select {
+		case <-ticker.C:
+		case <-manager.reload:
+		case <-manager.done:
+			for _, ladder := range manager.ladders {
+				ladder.stop()
+			}
+			return
+		}
+	}
+}
+
+func (manager *ABRManager) load() {
+	profiles, err := models.FindTranscodeProfiles()
+	if err != nil {
+		manager.logger.Printf("Query transcode profiles err:%v", err)
+		return
+	}
+	byPath := make(map[string][]models.TranscodeProfile)
+	for _, profile := range profiles {
+		byPath[profile.Path] = append(byPath[profile.Path], profile)
+	}
+	for sourcePath, ladder := range manager.ladders {
+		if _, ok := byPath[sourcePath]; !ok {
+			ladder.stop()
+			delete(manager.ladders, sourcePath)
+		}
+	}
+	for sourcePath, profiles := range byPath {
+		ladder, ok := manager.ladders[sourcePath]
+		if !ok {
+			ladder = &ABRLadder{Path: sourcePath, manager: manager, variants: make(map[string]*abrVariant)}
+			manager.ladders[sourcePath] = ladder
+		}
+		ladder.sync(profiles, manager.server.GetPusher(sourcePath) != nil)
+	}
+}
+
+// startVariant starts the ffmpeg of the variant of profile.
+func (manager *ABRManager) startVariant(profile models.TranscodeProfile) (variant *abrVariant, err error) {
+	port := manager.server.TCPPort
+	variantPath := VariantPath(profile)
+	params := []string{"-fflags", "genpts", "-rtsp_transport", "tcp",
+		"-i", fmt.Sprintf("rtsp://localhost:%d%s", port, profile.Path),
+		"-map", "0:v:0", "-map", "0:a:0?",
+		"-c:v", "libx264", "-preset", "veryfast",
+		"-force_key_frames", fmt.Sprintf("expr:gte(t,n_forced*%d)", manager.HLSTime), "-sc_threshold", "0",
+		"-c:a", "aac", "-ac", "2"}
+	if profile.Height > 0 {
+		width := profile.Width
+		if width <= 0 {
+			width = -2
+		}
+		params = append(params, "-vf", fmt.Sprintf("scale=%d:%d", width, profile.Height))
+	}
+	if profile.VideoBitrate > 0 {
+		params = append(params, "-b:v", fmt.Sprintf("%dk", profile.VideoBitrate),
+			"-maxrate", fmt.Sprintf("%dk", abrMaxrate(profile.VideoBitrate)), "-bufsize", fmt.Sprintf("%dk", 2*profile.VideoBitrate))
+	}
+	if profile.AudioBitrate > 0 {
+		params = append(params, "-b:a", fmt.Sprintf("%dk", profile.AudioBitrate))
+	}
+	outputs := []string{fmt.Sprintf("[f=rtsp:rtsp_transport=tcp]rtsp://localhost:%d%s", port, variantPath)}
+	var logFile *os.File
+	if manager.HLSDir != "" {
+		dir := path.Join(manager.HLSDir, variantPath)
+		if err = utils.EnsureDir(dir); err != nil {
+			return
+		}
+		outputs = append(outputs, fmt.Sprintf("[f=hls:hls_time=%d:hls_list_size=%d:hls_flags=delete_segments+independent_segments]%s",
+			manager.HLSTime, manager.HLSListSize, path.Join(dir, "index.m3u8")))
+		if logFile, err = os.OpenFile(path.Join(dir, "log.txt"), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644); err != nil {
+			return
+		}
+	}
+	params = append(params, "-f", "tee", strings.Join(outputs, "|"))
+
+	cmd := exec.Command(manager.ffmpeg, params...)
+	if logFile != nil {
+		cmd.Stdout = logFile
+		cmd.Stderr = logFile
+	}
+	if err = cmd.Start(); err != nil {
+		if logFile != nil {
+			logFile.Close()
+		}
+		return
+	}
+	variant = &abrVariant{profile: profile, cmd: cmd, exited: make(chan struct{})}
+	go func() {
+		err := cmd.Wait()
+		if logFile != nil {
+			logFile.Close()
+			// a stale playlist would keep the variant in the master playlist
+			dir := path.Join(manager.HLSDir, variantPath)
+			files, _ := filepath.Glob(filepath.Join(dir, "*.ts"))
+			for _, file := range append(files, filepath.Join(dir, "index.m3u8")) {
+				os.Remove(file)
+			}
+		}
+		manager.logger.Printf("ABR variant %s ffmpeg exited:%v", variantPath, err)
+		close(variant.exited)
+	}()
+	manager.logger.Printf("add ABR variant %s with ffmpeg [%v]", variantPath, cmd)
+	return
+}
+
+func (manager *ABRManager) stopVariant(variant *abrVariant) {
+	manager.logger.Printf("remove ABR variant %s", VariantPath(variant.profile))
+	if !variant.running() {
+		return
+	}
+	variant.cmd.Process.Signal(syscall.SIGTERM)
+	select {
+	case <-variant.exited:
+	case <-time.After(5 * time.Second):
+		variant.cmd.Process.Kill()
+		<-variant.exited
+	}
+}
+
+// abrMaxrate is the peak video bitrate of a variant, in kbps.
+func abrMaxrate(videoBitrate int) int {
+	return videoBitrate * 11 / 10
+}
+
+// MasterPlaylist returns the HLS master playlist of the stream at sourcePath,
+// with an EXT-X-STREAM-INF per variant with a playlist, by ascending
+// bandwidth. The URIs are absolute paths under urlPrefix, where HLSDir is
+// served. It returns false when no variant has a playlist yet.
+func (manager *ABRManager) MasterPlaylist(sourcePath, urlPrefix string) (string, bool) {
+	if manager.HLSDir == "" {
+		return "", false
+	}
+	profiles, err := models.FindTranscodeProfiles(sourcePath)
+	if err != nil {
+		manager.logger.Printf("Query transcode profiles of %s err:%v", sourcePath, err)
+		return "", false
+	}
+	bandwidth := func(profile models.TranscodeProfile) int {
+		return (abrMaxrate(profile.VideoBitrate) + profile.AudioBitrate) * 1000
+	}
+	sort.SliceStable(profiles, func(i, j int) bool {
+		return bandwidth(profiles[i]) < bandwidth(profiles[j])
+	})
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-INDEPENDENT-SEGMENTS\n")
+	variants := 0
+	for _, profile := range profiles {
+		variantPath := VariantPath(profile)
+		if _, err := os.Stat(path.Join(manager.HLSDir, variantPath, "index.m3u8")); err != nil {
+			continue
+		}
+		fmt.Fprintf(&b, "#EXT-X-STREAM-INF:BANDWIDTH=%d,AVERAGE-BANDWIDTH=%d", bandwidth(profile), (profile.VideoBitrate+profile.AudioBitrate)*1000)
+		if profile.Width > 0 && profile.Height > 0 {
+			fmt.Fprintf(&b, ",RESOLUTION=%dx%d", profile.Width, profile.Height)
+		}
+		fmt.Fprintf(&b, ",NAME=\"%s\"\n%s%s/index.m3u8\n", VariantName(profile), urlPrefix, variantPath)
+		variants++
+	}
+	return b.String(), variants > 0
+}
@@ -0,0 +1,143 @@
+package rtsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	ACCESS_LOG_FORMAT_COMBINED = "combined"
+	ACCESS_LOG_FORMAT_JSON     = "json"
+)
+
+// RTSPAccessLog writes one line per RTSP request in Combined Log Format or as JSON.
+// Lines are buffered and flushed on a ticker, so logging does not cost a syscall per request.
+// Once closed, Log drops the lines, so sessions still running may keep the access log.
+type RTSPAccessLog struct {
+	Format string
+
+	lock   sync.Mutex
+	closed bool
+	out    io.Writer
+	w      *bufio.Writer
+	ticker *time.Ticker
+	done   chan struct{}
+}
+
+type accessLogEntry struct {
+	ClientIP  string `json:"client_ip"`
+	User      string `json:"user"`
+	Time      string `json:"time"`
+	Method    string `json:"method"`
+	Path      string `json:"path"`
+	Protocol  string `json:"protocol"`
+	Status    int    `json:"status"`
+	BytesSent int    `json:"bytes_sent"`
+	Referer   string `json:"referer"`
+	UserAgent string `json:"user_agent"`
+}
+
+func NewRTSPAccessLog(w io.Writer, format string, flushInterval time.Duration) *RTSPAccessLog {
+	if flushInterval <= 0 {
+		flushInterval = time.Second
+	}
+	accessLog := &RTSPAccessLog{
+		Format: format,
+		out:    w,
+		w:      bufio.NewWriter(w),
+		ticker: time.NewTicker(flushInterval),
+		done:   make(chan struct{}),
+	}
+	go func() {
+		for {
+			select {
+			case <-accessLog.ticker.C:
+				accessLog.Flush()
+			case <-accessLog.done:
+				return
+			}
+		}
+	}()
+	return accessLog
+}
+
+func (accessLog *RTSPAccessLog) Log(session *Session, req *Request, res *Response, bytesSent int) {
+	entry := accessLogEntry{
+		ClientIP:  "-",
+		User:      "-",
+		Time:      time.Now().Format("02/Jan/2006:15:04:05 -0700"),
+		Method:    req.Method,
+		Path:      req.URL,
+		Protocol:  req.Version,
+		Status:    res.StatusCode,
+		BytesSent: bytesSent,
+		Referer:   "-",
+		UserAgent: "-",
+	}
+	if session.Conn != nil {
+		if host, _, err := net.SplitHostPort(session.Conn.RemoteAddr().String()); err == nil {
+			entry.ClientIP = host
+		}
+	}
+	if session.authUser != "" {
+		entry.User = session.authUser
+	}
+	if u, err := url.Parse(req.URL); err == nil && u.Path != "" {
+		entry.Path = u.Path
+	}
+	if v := req.Header["Referer"]; v != "" {
+		entry.Referer = v
+	}
+	if v := req.Header["User-Agent"]; v != "" {
+		entry.UserAgent = v
+	}
+
+	var line []byte
+	if accessLog.Format == ACCESS_LOG_FORMAT_JSON {
+		line, _ = json.Marshal(entry)
+	} else {
+		line = []byte(fmt.Sprintf(`%s - %s [%s] "%s %s %s" %d %d "%s" "%s"`,
+			entry.ClientIP, entry.User, entry.Time, entry.Method, entry.Path, entry.Protocol,
+			entry.Status, entry.BytesSent, entry.Referer, entry.UserAgent))
+	}
+	accessLog.lock.Lock()
+	if !accessLog.closed {
+		accessLog.w.Write(line)
+		accessLog.w.WriteByte('\n')
+	}
+	accessLog.lock.Unlock()
+}
+
+func (accessLog *RTSPAccessLog) Flush() error {
+	accessLog.lock.Lock()
+	defer accessLog.lock.Unlock()
+	if accessLog.closed {
+		return nil
+	}
+	return accessLog.w.Flush()
+}
+
+// Close flushes the buffered lines and closes the underlying writer if it is an io.Closer.
+func (accessLog *RTSPAccessLog) Close() (err error) {
+	accessLog.lock.Lock()
+	defer accessLog.lock.Unlock()
+	if accessLog.closed {
+		return nil
+	}
+	accessLog.closed = true
+	accessLog.ticker.Stop()
+	close(accessLog.done)
+	err = accessLog.w.Flush()
+	if closer, ok := accessLog.out.(io.Closer); ok {
+		if cerr := closer.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return
+}
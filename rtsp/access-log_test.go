@@ -0,0 +1,59 @@
+package rtsp
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type closeBuffer struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (b *closeBuffer) Close() error {
+	b.closed = true
+	return nil
+}
+
+func TestAccessLogClose(t *testing.T) {
+	out := new(closeBuffer)
+	accessLog := NewRTSPAccessLog(out, ACCESS_LOG_FORMAT_COMBINED, time.Hour)
+	session := &Session{}
+	req := &Request{Method: DESCRIBE, URL: "rtsp://127.0.0.1/test", Version: RTSP_VERSION, Header: map[string]string{}}
+	res := &Response{StatusCode: 200}
+
+	// sessions keep logging while the server stops
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				accessLog.Log(session, req, res, 10)
+			}
+		}()
+	}
+	accessLog.Log(session, req, res, 10)
+	if err := accessLog.Close(); err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+	written := out.String()
+	accessLog.Log(session, req, res, 10)
+	if err := accessLog.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := accessLog.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !out.closed || out.String() != written {
+		t.Errorf("closed %v, written after close: %v", out.closed, out.String() != written)
+	}
+	if !strings.Contains(written, `"DESCRIBE /test RTSP/1.0" 200 10`) {
+		t.Errorf("access log %q", written)
+	}
+}
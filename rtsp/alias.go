@@ -0,0 +1,112 @@
+package rtsp
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"EasyDarwin/models"
+)
+
+// ALIAS_MAX_DEPTH caps how many aliases are followed to find a canonical path.
+const ALIAS_MAX_DEPTH = 5
+
+// AliasManager maps alias paths to the canonical paths of streams. Players asking
+// for an alias are redirected to the canonical path. Aliases are kept in t_alias
+// and cached in memory.
+type AliasManager struct {
+	aliases map[string]string // aliasPath <-> canonicalPath
+	lock    sync.RWMutex
+}
+
+func NewAliasManager() *AliasManager {
+	return &AliasManager{
+		aliases: make(map[string]string),
+	}
+}
+
+// Load replaces the cached aliases with the ones in the database.
+func (manager *AliasManager) Load() error {
+	aliases, err := models.FindAliases()
+	if err != nil {
+		return err
+	}
+	manager.lock.Lock()
+	manager.aliases = make(map[string]string, len(aliases))
+	for _, alias := range aliases {
+		manager.aliases[alias.AliasPath] = alias.CanonicalPath
+	}
+	manager.lock.Unlock()
+	return nil
+}
+
+// Resolve follows the alias chain of path and returns the path it ends at.
+// ok is false if path is not an alias or the chain is deeper than ALIAS_MAX_DEPTH.
+func (manager *AliasManager) Resolve(path string) (canonicalPath string, ok bool) {
+	if manager == nil {
+		return "", false
+	}
+	manager.lock.RLock()
+	defer manager.lock.RUnlock()
+	canonicalPath = path
+	for depth := 0; depth < ALIAS_MAX_DEPTH; depth++ {
+		next, found := manager.aliases[canonicalPath]
+		if !found {
+			return canonicalPath, depth > 0
+		}
+		canonicalPath = next
+	}
+	if _, found := manager.aliases[canonicalPath]; found {
+		return "", false
+	}
+	return canonicalPath, true
+}
+
+// Set adds or changes an alias. An alias that would make a cycle is rejected.
+func (manager *AliasManager) Set(aliasPath, canonicalPath string) error {
+	manager.lock.Lock()
+	defer manager.lock.Unlock()
+	for path, depth := canonicalPath, 0; ; depth++ {
+		if path == aliasPath {
+			return fmt.Errorf("alias %s -> %s makes a cycle", aliasPath, canonicalPath)
+		}
+		next, found := manager.aliases[path]
+		if !found {
+			break
+		}
+		if depth >= len(manager.aliases) {
+			// an existing cycle, which Set never lets in.
+			return fmt.Errorf("alias %s -> %s makes a cycle", aliasPath, canonicalPath)
+		}
+		path = next
+	}
+	if err := models.SaveAlias(&models.Alias{AliasPath: aliasPath, CanonicalPath: canonicalPath}); err != nil {
+		return err
+	}
+	manager.aliases[aliasPath] = canonicalPath
+	return nil
+}
+
+func (manager *AliasManager) Delete(aliasPath string) error {
+	manager.lock.Lock()
+	defer manager.lock.Unlock()
+	if err := models.DeleteAlias(aliasPath); err != nil {
+		return err
+	}
+	delete(manager.aliases, aliasPath)
+	return nil
+}
+
+// List returns the aliases sorted by alias path.
+func (manager *AliasManager) List() []models.Alias {
+	manager.lock.RLock()
+	aliases := make([]models.Alias, 0, len(manager.aliases))
+	for aliasPath, canonicalPath := range manager.aliases {
+		aliases = append(aliases, models.Alias{AliasPath: aliasPath, CanonicalPath: canonicalPath})
+	}
+	manager.lock.RUnlock()
+	sort.Slice(aliases, func(i, j int) bool {
+		return aliases[i].AliasPath < aliases[j].AliasPath
+	})
+	return aliases
+}
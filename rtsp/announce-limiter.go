@@ -0,0 +1,166 @@
+package rtsp
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"EasyDarwin/helper/go-redis/redis"
+)
+
+// ANNOUNCE_BAN_KEY_PREFIX prefixes the Redis keys of the banned client IPs.
+const ANNOUNCE_BAN_KEY_PREFIX = "easydarwin:announce_ban:"
+
+var announceRejectedTotal = NewCounterVec("rtsp_announce_rejected_total", "ANNOUNCE requests rejected by the rate limiter.", "reason")
+
+// BanStore keeps the client IPs banned from publishing.
+type BanStore interface {
+	Ban(ip string, duration time.Duration) error
+	Banned(ip string) (bool, error)
+}
+
+// MemoryBanStore keeps the bans of this node only.
+type MemoryBanStore struct {
+	bans map[string]time.Time // IP <-> end of the ban
+	lock sync.Mutex
+}
+
+func NewMemoryBanStore() *MemoryBanStore {
+	return &MemoryBanStore{bans: make(map[string]time.Time)}
+}
+
+func (store *MemoryBanStore) Ban(ip string, duration time.Duration) error {
+	store.lock.Lock()
+	store.bans[ip] = time.Now().Add(duration)
+	store.lock.Unlock()
+	return nil
+}
+
+func (store *MemoryBanStore) Banned(ip string) (bool, error) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	end, ok := store.bans[ip]
+	if ok && !time.Now().Before(end) {
+		delete(store.bans, ip)
+		ok = false
+	}
+	return ok, nil
+}
+
+// RedisBanStore shares the bans between the nodes through Redis keys expiring
+// with the ban. Bans are kept in memory too, so they still hold on this node
+// while Redis is unreachable.
+type RedisBanStore struct {
+	local *MemoryBanStore
+	ring  *redis.Ring
+}
+
+func NewRedisBanStore(ring *redis.Ring) *RedisBanStore {
+	return &RedisBanStore{local: NewMemoryBanStore(), ring: ring}
+}
+
+func (store *RedisBanStore) Ban(ip string, duration time.Duration) error {
+	store.local.Ban(ip, duration)
+	return store.ring.Set(ANNOUNCE_BAN_KEY_PREFIX+ip, 1, duration).Err()
+}
+
+func (store *RedisBanStore) Banned(ip string) (bool, error) {
+	if banned, _ := store.local.Banned(ip); banned {
+		return true, nil
+	}
+	n, err := store.ring.Exists(ANNOUNCE_BAN_KEY_PREFIX + ip).Result()
+	return n > 0, err
+}
+
+type announceKey struct {
+	ip   string
+	path string
+}
+
+type announceWindow struct {
+	start time.Time
+	count int
+}
+
+// AnnounceLimiter allows a client IP at most Limit ANNOUNCE requests per
+// minute for a stream path. An IP going over the limit is banned from
+// publishing any stream for BanDuration; with zero only the requests over the
+// limit are rejected. The requests are counted by each node, the bans are kept
+// in a BanStore.
+type AnnounceLimiter struct {
+	Limit       int
+	BanDuration time.Duration
+
+	bans      BanStore
+	logger    *log.Logger
+	windows   map[announceKey]*announceWindow
+	lastSweep time.Time
+	lock      sync.Mutex
+}
+
+func NewAnnounceLimiter(limit int, banDuration time.Duration, bans BanStore, logger *log.Logger) *AnnounceLimiter {
+	return &AnnounceLimiter{
+		Limit:       limit,
+		BanDuration: banDuration,
+		bans:        bans,
+		logger:      logger,
+		windows:     make(map[announceKey]*announceWindow),
+		lastSweep:   time.Now(),
+	}
+}
+
+// SetLimits changes the limits, keeping the counts and the bans.
+func (limiter *AnnounceLimiter) SetLimits(limit int, banDuration time.Duration) {
+	limiter.lock.Lock()
+	limiter.Limit = limit
+	limiter.BanDuration = banDuration
+	limiter.lock.Unlock()
+}
+
+// Allow counts an ANNOUNCE of path from ip and tells whether it may go on.
+// A ban store that fails is logged and ignored, so publishing does not depend
+// on Redis being up.
+func (limiter *AnnounceLimiter) Allow(ip, path string) bool {
+	if banned, err := limiter.bans.Banned(ip); err != nil {
+		limiter.logger.Printf("announce limiter check ban of %s err:%v", ip, err)
+	} else if banned {
+		announceRejectedTotal.WithLabelValue("banned").Inc()
+		return false
+	}
+
+	now := time.Now()
+	limiter.lock.Lock()
+	if now.Sub(limiter.lastSweep) >= time.Minute {
+		for key, window := range limiter.windows {
+			if now.Sub(window.start) >= time.Minute {
+				delete(limiter.windows, key)
+			}
+		}
+		limiter.lastSweep = now
+	}
+	key := announceKey{ip, path}
+	window, ok := limiter.windows[key]
+	if !ok || now.Sub(window.start) >= time.Minute {
+		window = &announceWindow{start: now}
+		limiter.windows[key] = window
+	}
+	window.count++
+	limit, banDuration := limiter.Limit, limiter.BanDuration
+	exceeded := window.count > limit
+	if exceeded {
+		delete(limiter.windows, key)
+	}
+	limiter.lock.Unlock()
+	if !exceeded {
+		return true
+	}
+
+	announceRejectedTotal.WithLabelValue("rate_limit").Inc()
+	limiter.logger.Printf("%s sent more than %d ANNOUNCE of %s in a minute, banned for %v", ip, limit, path, banDuration)
+	if banDuration > 0 {
+		if err := limiter.bans.Ban(ip, banDuration); err != nil {
+			limiter.logger.Printf("announce limiter ban %s err:%v", ip, err)
+		}
+	}
+	return false
+}
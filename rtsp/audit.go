@@ -0,0 +1,36 @@
+package rtsp
+
+import (
+	"EasyDarwin/models"
+)
+
+// audit appends an event of the session to the audit log, subject to the
+// stream path.
+func (session *Session) audit(eventType string, details map[string]interface{}) {
+	if !models.AuditLog.Enabled() {
+		return
+	}
+	if details == nil {
+		details = make(map[string]interface{})
+	}
+	details["sessionId"] = session.ID
+	details["clientIP"] = session.clientIP
+	if _, ok := details["user"]; !ok && session.authUser != "" {
+		details["user"] = session.authUser
+	}
+	if err := models.AuditLog.Append(eventType, session.Path, details); err != nil {
+		session.logger.Printf("audit %s err:%v", eventType, err)
+	}
+}
+
+// auditRole returns pub or sub, empty when the session neither pushed nor
+// played.
+func (session *Session) auditRole() string {
+	switch {
+	case session.Type == SESSION_TYPE_PUSHER && session.Pusher != nil:
+		return "pub"
+	case session.Type == SESSEION_TYPE_PLAYER && session.Player != nil:
+		return "sub"
+	}
+	return ""
+}
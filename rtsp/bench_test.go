@@ -0,0 +1,92 @@
+package rtsp_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"EasyDarwin/rtsp"
+	"EasyDarwin/rtsp/rtsptest"
+)
+
+// benchmarkDispatch publishes a camera to subscribers players, then measures
+// the time for the RTP packets of at least b.N camera packets, sent at once,
+// to reach every player. ns/packet is per packet sent by the camera, MB/s
+// counts the bytes received by all the players.
+func benchmarkDispatch(b *testing.B, streamPath string, subscribers int) {
+	camera := rtsptest.NewFakeCamera(streamPath, "")
+	camera.FrameRate = -1
+	if err := camera.Start(); err != nil {
+		b.Fatal(err)
+	}
+	defer camera.Close()
+
+	players := make([]*rtsptest.FakePlayer, subscribers)
+	for i := range players {
+		players[i] = newTestPlayer()
+		players[i].Discard = true
+		if err := players[i].Play(streamPath); err != nil {
+			b.Fatal(err)
+		}
+		defer players[i].Close()
+	}
+	// PLAY is answered before the player joins the pusher
+	pusher := rtsp.GetServer().GetPusher(streamPath)
+	for deadline := time.Now().Add(10 * time.Second); len(pusher.GetPlayers()) < subscribers; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			b.Fatalf("%d of %d players joined", len(pusher.GetPlayers()), subscribers)
+		}
+	}
+
+	b.ResetTimer()
+	start := time.Now()
+	sent := 0
+	for sent < b.N {
+		packets, err := camera.SendFrames(1)
+		if err != nil {
+			b.Fatal(err)
+		}
+		sent += packets
+	}
+	var bytes int64
+	for _, player := range players {
+		if err := player.WaitPackets(sent, time.Minute); err != nil {
+			b.Fatal(err)
+		}
+		_, n := player.Stats()
+		bytes += n
+	}
+	elapsed := time.Since(start)
+	b.StopTimer()
+
+	b.ReportMetric(float64(elapsed.Nanoseconds())/float64(sent), "ns/packet")
+	b.ReportMetric(float64(bytes)/1e6/elapsed.Seconds(), "MB/s")
+}
+
+func BenchmarkRTPDispatchSingleStream(b *testing.B) {
+	benchmarkDispatch(b, fmt.Sprintf("/bench/single/%d", b.N), 1)
+}
+
+func BenchmarkRTPDispatch1000Subscribers(b *testing.B) {
+	benchmarkDispatch(b, fmt.Sprintf("/bench/fanout/%d", b.N), 1000)
+}
+
+// BenchmarkRTSPSessionSetup measures DESCRIBE, SETUP and PLAY of a published
+// stream, then the teardown of the player.
+func BenchmarkRTSPSessionSetup(b *testing.B) {
+	camera := rtsptest.NewFakeCamera("/bench/setup", "")
+	if err := camera.Start(); err != nil {
+		b.Fatal(err)
+	}
+	defer camera.Close()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		player := newTestPlayer()
+		player.Discard = true
+		if err := player.Play("/bench/setup"); err != nil {
+			b.Fatal(err)
+		}
+		player.Close()
+	}
+}
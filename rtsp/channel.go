@@ -0,0 +1,360 @@
+package rtsp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"os/exec"
+	"strconv"
+	"syscall"
+	"time"
+
+	"EasyDarwin/models"
+	"EasyDarwin/rtsp/sdp"
+)
+
+const (
+	CHANNEL_TRANSITION_CUT  = ""
+	CHANNEL_TRANSITION_FADE = "fade"
+
+	// duration of the fade in of an item with CHANNEL_TRANSITION_FADE
+	CHANNEL_FADE_DURATION = time.Second
+)
+
+// channelItemTSGap separates the RTP timestamps of two items, a frame at 30 fps.
+const channelItemTSGap = 3000
+
+// channelSDP describes a channel as MPEG-TS over RTP (RFC 2250), so items of
+// any codecs are played on the same track.
+const channelSDP = "v=0\r\n" +
+	"o=- 0 0 IN IP4 127.0.0.1\r\n" +
+	"s=Channel\r\n" +
+	"c=IN IP4 0.0.0.0\r\n" +
+	"t=0 0\r\n" +
+	"m=video 0 RTP/AVP 33\r\n" +
+	"a=rtpmap:33 MP2T/90000\r\n" +
+	"a=control:streamid=0\r\n"
+
+// ChannelPlayer plays the items of a channel as a pusher session without a
+// connection. Each item is sent by ffmpeg, in real time, as MPEG-TS over RTP
+// to a local UDP port; the packets are renumbered into one sequence and
+// relayed to the players of the channel path, which stay connected from an
+// item to the next. The pusher is added when the first item starts and
+// removed after the last one.
+//
+// A channel starts with the last item whose StartTime has passed, seeking into
+// it as if it had been playing since then.
+type ChannelPlayer struct {
+	models.Channel
+	Session *Session
+	ffmpeg  string
+	storage *LocalStorage
+	conn    *net.UDPConn
+	logger  *log.Logger
+	done    chan struct{}
+	// the path had another pusher, the channel may be retried
+	rejected bool
+
+	// renumbering of the RTP packets of the items
+	ssrc       uint32
+	inSSRC     uint32
+	rtpStarted bool
+	seqOffset  uint16
+	tsOffset   uint32
+	lastSeq    uint16
+	lastTS     uint32
+}
+
+func NewChannelPlayer(server *Server, channel models.Channel, ffmpeg string, storage *LocalStorage) (player *ChannelPlayer, err error) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		return
+	}
+	session := newSession(server)
+	session.Type = SESSION_TYPE_PUSHER
+	session.TransType = TRANS_TYPE_CHANNEL
+	session.URL = fmt.Sprintf("channel://%d", channel.ID)
+	session.Path = channel.Path
+	session.RawPath = channel.Path
+	session.clientIP = "127.0.0.1"
+	session.SDPRaw = channelSDP
+	if session.Sdp, err = sdp.Parse(session.SDPRaw); err != nil {
+		conn.Close()
+		return
+	}
+	session.SDPMap = sdp.Infos(session.Sdp)
+	session.VControl = session.SDPMap["video"].Control
+	player = &ChannelPlayer{
+		Channel: channel,
+		Session: session,
+		ffmpeg:  ffmpeg,
+		storage: storage,
+		conn:    conn,
+		logger:  session.logger,
+		done:    make(chan struct{}),
+		ssrc:    rand.Uint32(),
+	}
+	session.StopHandles = append(session.StopHandles, func() {
+		close(player.done)
+		conn.Close()
+	})
+	go player.run()
+	return
+}
+
+// Stop stops the item playing and removes the pusher.
+func (player *ChannelPlayer) Stop() {
+	player.Session.Stop()
+}
+
+func (player *ChannelPlayer) run() {
+	defer player.Stop()
+	go player.relayRTP()
+	start := 0
+	now := time.Now()
+	for i, item := range player.Items {
+		if !item.StartTime.IsZero() && !item.StartTime.After(now) {
+			start = i
+		}
+	}
+	for i := start; i < len(player.Items); i++ {
+		item := player.Items[i]
+		if wait := item.StartTime.Sub(time.Now()); wait > 0 {
+			select {
+			case <-time.After(wait):
+			case <-player.done:
+				return
+			}
+		}
+		if player.Session.Pusher == nil && !player.publish() {
+			return
+		}
+		var offset time.Duration
+		if i == start && !item.StartTime.IsZero() {
+			offset = time.Since(item.StartTime)
+		}
+		if err := player.play(item, offset); err != nil {
+			player.logger.Printf("%v play %s err:%v", player.Session, item.RecordingID, err)
+		}
+		select {
+		case <-player.done:
+			return
+		default:
+		}
+	}
+	player.logger.Printf("%v channel %s ended", player.Session, player.Path)
+}
+
+func (player *ChannelPlayer) publish() bool {
+	session := player.Session
+	session.Pusher = NewPusher(session)
+	// MPEG-TS has no key frames to reset the gop cache at
+	session.Pusher.gopCacheEnable = false
+	if !session.Server.AddPusher(session.Pusher) {
+		player.logger.Printf("%v channel %s rejected, the path has a pusher", session, player.Path)
+		player.rejected = true
+		return false
+	}
+	return true
+}
+
+// play sends item with ffmpeg, from offset, until it ends or the channel stops.
+func (player *ChannelPlayer) play(item models.ScheduledItem, offset time.Duration) error {
+	file, err := player.storage.Resolve(item.RecordingID)
+	if err != nil {
+		return err
+	}
+	params := []string{"-re"}
+	if offset > 0 {
+		params = append(params, "-ss", strconv.FormatFloat(offset.Seconds(), 'f', 3, 64))
+	}
+	params = append(params, "-i", file)
+	if item.TransitionEffect == CHANNEL_TRANSITION_FADE {
+		d := strconv.FormatFloat(CHANNEL_FADE_DURATION.Seconds(), 'f', -1, 64)
+		params = append(params,
+			"-vf", "fade=t=in:st=0:d="+d, "-af", "afade=t=in:st=0:d="+d,
+			"-c:v", "libx264", "-preset", "veryfast", "-c:a", "aac")
+	} else {
+		params = append(params, "-c", "copy")
+	}
+	port := player.conn.LocalAddr().(*net.UDPAddr).Port
+	params = append(params, "-f", "rtp_mpegts", fmt.Sprintf("rtp://127.0.0.1:%d?pkt_size=1328", port))
+	cmd := exec.Command(player.ffmpeg, params...)
+	if err = cmd.Start(); err != nil {
+		return err
+	}
+	player.logger.Printf("%v play %s with ffmpeg [%v]", player.Session, item.RecordingID, cmd)
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+	select {
+	case err = <-exited:
+		return err
+	case <-player.done:
+		cmd.Process.Signal(syscall.SIGTERM)
+		return <-exited
+	}
+}
+
+// relayRTP relays the packets sent by ffmpeg until the channel stops.
+func (player *ChannelPlayer) relayRTP() {
+	session := player.Session
+	buf := make([]byte, 2048)
+	for {
+		n, addr, err := player.conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		if n < RTP_FIXED_HEADER_LENGTH || !addr.IP.IsLoopback() {
+			continue
+		}
+		pkt := append([]byte(nil), buf[:n]...)
+		player.renumber(pkt)
+		session.InBytes += n
+		pack := &RTPPack{
+			Type:   RTP_TYPE_VIDEO,
+			Buffer: bytes.NewBuffer(pkt),
+		}
+		for _, h := range session.RTPHandles {
+			h(pack)
+		}
+	}
+}
+
+// renumber rewrites the header of an RTP packet so the items make up one
+// stream: each ffmpeg starts with a random SSRC, sequence number and
+// timestamp, which continue those of the item before.
+func (player *ChannelPlayer) renumber(pkt []byte) {
+	seq := binary.BigEndian.Uint16(pkt[2:])
+	ts := binary.BigEndian.Uint32(pkt[4:])
+	ssrc := binary.BigEndian.Uint32(pkt[8:])
+	if !player.rtpStarted || ssrc != player.inSSRC {
+		player.inSSRC = ssrc
+		player.seqOffset = player.lastSeq + 1 - seq
+		player.tsOffset = player.lastTS + channelItemTSGap - ts
+		player.rtpStarted = true
+	}
+	player.lastSeq = seq + player.seqOffset
+	player.lastTS = ts + player.tsOffset
+	binary.BigEndian.PutUint16(pkt[2:], player.lastSeq)
+	binary.BigEndian.PutUint32(pkt[4:], player.lastTS)
+	binary.BigEndian.PutUint32(pkt[8:], player.ssrc)
+}
+
+// ChannelManager runs a ChannelPlayer for each channel of t_channel. The
+// channels are reloaded every minute and by Reload; a changed channel is
+// restarted, and a channel whose path had another pusher is retried.
+type ChannelManager struct {
+	server  *Server
+	ffmpeg  string
+	storage *LocalStorage
+	logger  *log.Logger
+	reload  chan struct{}
+	done    chan struct{}
+
+	players map[uint]*ChannelPlayer // ID <-> player
+}
+
+func NewChannelManager(server *Server, ffmpeg string, storage *LocalStorage) *ChannelManager {
+	manager := &ChannelManager{
+		server:  server,
+		ffmpeg:  ffmpeg,
+		storage: storage,
+		logger:  server.logger,
+		reload:  make(chan struct{}, 1),
+		done:    make(chan struct{}),
+		players: make(map[uint]*ChannelPlayer),
+	}
+	go manager.run()
+	return manager
+}
+
+// Reload reloads the channels after a change.
+func (manager *ChannelManager) Reload() {
+	select {
+	case manager.reload <- struct{}{}:
+	default:
+	}
+}
+
+// Stop stops the manager and the channels.
+func (manager *ChannelManager) Stop() {
+	close(manager.done)
+}
+
+func (manager *ChannelManager) run() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		manager.load()
+		select {
+		case <-ticker.C:
+		case <-manager.reload:
+		case <-manager.done:
+			for _, player := range manager.players {
+				player.Stop()
+			}
+			return
+		}
+	}
+}
+
+func (manager *ChannelManager) load() {
+	channels, err := models.FindChannels()
+	if err != nil {
+		manager.logger.Printf("Query channels err:%v", err)
+		return
+	}
+	players := make(map[uint]*ChannelPlayer, len(channels))
+	for _, channel := range channels {
+		if player, ok := manager.players[channel.ID]; ok {
+			delete(manager.players, channel.ID)
+			if sameChannel(player.Channel, channel) && !player.retry() {
+				players[channel.ID] = player
+				continue
+			}
+			player.Stop()
+		}
+		if len(channel.Items) == 0 {
+			continue
+		}
+		player, err := NewChannelPlayer(manager.server, channel, manager.ffmpeg, manager.storage)
+		if err != nil {
+			manager.logger.Printf("Start channel %s err:%v", channel.Path, err)
+			continue
+		}
+		players[channel.ID] = player
+	}
+	// deleted channels
+	for _, player := range manager.players {
+		player.Stop()
+	}
+	manager.players = players
+}
+
+// retry tells whether the player stopped because the path had another pusher.
+func (player *ChannelPlayer) retry() bool {
+	select {
+	case <-player.done:
+		return player.rejected
+	default:
+		return false
+	}
+}
+
+func sameChannel(a, b models.Channel) bool {
+	if a.Path != b.Path || len(a.Items) != len(b.Items) {
+		return false
+	}
+	for i := range a.Items {
+		x, y := a.Items[i], b.Items[i]
+		if x.RecordingID != y.RecordingID || !x.StartTime.Equal(y.StartTime) || x.TransitionEffect != y.TransitionEffect {
+			return false
+		}
+	}
+	return true
+}
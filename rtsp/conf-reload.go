@@ -0,0 +1,187 @@
+package rtsp
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"EasyDarwin/helper/go-ini/ini"
+	"EasyDarwin/helper/penggy/EasyGoLib/utils"
+)
+
+// ConfChange is a key of the config file whose value changed on a reload. An
+// empty Old or New is a key added or removed.
+type ConfChange struct {
+	Section string
+	Key     string
+	Old     string
+	New     string
+	// the key is only read at start, e.g. a port or a TLS certificate
+	RequiresRestart bool
+}
+
+func (change ConfChange) String() string {
+	return fmt.Sprintf("%s.%s: %q -> %q", change.Section, change.Key, change.Old, change.New)
+}
+
+// restartConfKeys are the keys read once at start, "*" for every key of the
+// section. The other keys are read when used, e.g. by the next session, or
+// applied by Server.ApplyConf.
+var restartConfKeys = map[string][]string{
+	"http":           {"*"},
+	"service":        {"*"},
+	"redis":          {"*"},
+	"dash":           {"*"},
+	"record_storage": {"*"},
+	"abr":            {"*"},
+	"merge":          {"*"},
+	"db":             {"*"},
+	"geoip":          {"*"},
+	"load_balancer":  {"*"},
+	"rtsp": {
+		"port", "rtp_rtcp_mux",
+		"access_log", "access_log_format", "access_log_flush_interval",
+		"save_stream_to_local", "ffmpeg_path", "m3u8_dir_path", "ts_duration_second",
+		"session_stat_enable", "stat_agg_enable", "stat_agg_retention_days",
+	},
+}
+
+func confRequiresRestart(section, key string) bool {
+	for _, k := range restartConfKeys[section] {
+		if k == "*" || k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// DiffConf returns the keys that differ between two loads of the config file,
+// sorted by section and key.
+func DiffConf(old, new *ini.File) (changes []ConfChange) {
+	sections := make(map[string]bool)
+	for _, name := range old.SectionStrings() {
+		sections[name] = true
+	}
+	for _, name := range new.SectionStrings() {
+		sections[name] = true
+	}
+	for name := range sections {
+		// a missing key is an empty one, File.Section().Key() adds keys read
+		// but not in the file with an empty value
+		oldKeys, newKeys := confKeys(old, name), confKeys(new, name)
+		keys := make(map[string]bool)
+		for key := range oldKeys {
+			keys[key] = true
+		}
+		for key := range newKeys {
+			keys[key] = true
+		}
+		for key := range keys {
+			if oldKeys[key] != newKeys[key] {
+				changes = append(changes, ConfChange{Section: name, Key: key, Old: oldKeys[key], New: newKeys[key]})
+			}
+		}
+	}
+	for i := range changes {
+		changes[i].RequiresRestart = confRequiresRestart(changes[i].Section, changes[i].Key)
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Section != changes[j].Section {
+			return changes[i].Section < changes[j].Section
+		}
+		return changes[i].Key < changes[j].Key
+	})
+	return
+}
+
+// confKeys returns the keys of a section without adding it to the file, as
+// File.Section does.
+func confKeys(f *ini.File, name string) map[string]string {
+	sec, err := f.GetSection(name)
+	if err != nil {
+		return nil
+	}
+	return sec.KeysHash()
+}
+
+// ApplyConf applies the changes of a reloaded config file to the components
+// built from it at start: the ANNOUNCE rate limit, the webhook, the default
+// stall timeout, the stream name rules and mDNS. Sessions and players are
+// left alone.
+func (server *Server) ApplyConf(changes []ConfChange) {
+	logger := server.logger
+	changed := func(section string, keys ...string) bool {
+		for _, change := range changes {
+			if change.Section != section {
+				continue
+			}
+			if len(keys) == 0 {
+				return true
+			}
+			for _, key := range keys {
+				if change.Key == key {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	sec := utils.Conf().Section("rtsp")
+
+	if changed("rtsp", "announce_rate_limit", "announce_ban_duration") {
+		limit := sec.Key("announce_rate_limit").MustInt(0)
+		banDuration := time.Duration(sec.Key("announce_ban_duration").MustInt(600)) * time.Second
+		switch limiter := server.AnnounceLimiter(); {
+		case limit <= 0:
+			server.setAnnounceLimiter(nil)
+		case limiter != nil:
+			limiter.SetLimits(limit, banDuration)
+		default:
+			var bans BanStore = NewMemoryBanStore()
+			if ring := server.Redis(); ring != nil {
+				bans = NewRedisBanStore(ring)
+			}
+			server.setAnnounceLimiter(NewAnnounceLimiter(limit, banDuration, bans, logger))
+		}
+		logger.Printf("Apply announce rate limit[%d] ban duration[%v]", limit, banDuration)
+	}
+
+	if changed("webhook") {
+		webhook := NewWebhook(logger)
+		server.confLock.Lock()
+		old := server.webhook
+		server.webhook = webhook
+		server.confLock.Unlock()
+		old.Close()
+		logger.Printf("Apply webhook")
+	}
+
+	if watchdog := server.Watchdog(); changed("rtsp", "stall_timeout") && watchdog != nil {
+		stallTimeout := time.Duration(sec.Key("stall_timeout").MustInt(0)) * time.Second
+		watchdog.SetStallTimeout(stallTimeout)
+		logger.Printf("Apply stall timeout[%v]", stallTimeout)
+	}
+
+	if changed("stream_name") {
+		if normalizer, err := NewStreamNameNormalizer(); err != nil {
+			logger.Printf("Stream name normalizer err:%v, keep the current rules.", err)
+		} else {
+			server.confLock.Lock()
+			server.nameNormalizer = normalizer
+			server.confLock.Unlock()
+			logger.Printf("Apply stream name rules")
+		}
+	}
+
+	if changed("rtsp", "mdns_enable", "mdns_name", "mdns_path") {
+		enabled := sec.Key("mdns_enable").MustInt(0) != 0
+		// the name and path are read when the advertiser starts
+		if server.MDNSEnabled() {
+			server.SetMDNSEnabled(false)
+		}
+		if err := server.SetMDNSEnabled(enabled); err != nil {
+			logger.Printf("Start mdns advertiser err:%v.", err)
+		}
+		logger.Printf("Apply mdns enabled[%v]", enabled)
+	}
+}
@@ -0,0 +1,77 @@
+package rtsp
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"sync"
+	"testing"
+
+	"EasyDarwin/helper/go-ini/ini"
+	"EasyDarwin/helper/penggy/EasyGoLib/utils"
+)
+
+func loadTestConf(t *testing.T, source string) *ini.File {
+	conf, err := ini.LoadSources(ini.LoadOptions{Insensitive: true}, []byte(source))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conf
+}
+
+// Run with -race: ApplyConf replaces the limiter, the webhook and the name
+// rules while sessions read them.
+func TestApplyConfConcurrentReaders(t *testing.T) {
+	saved := utils.Conf()
+	defer utils.SetConf(saved)
+
+	server := &Server{
+		SessionLogger: SessionLogger{log.New(ioutil.Discard, "", 0)},
+		Events:        NewBroadcaster(8),
+	}
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			if limiter := server.AnnounceLimiter(); limiter != nil {
+				limiter.Allow("10.0.0.1", "/live/a")
+			}
+			server.Emit(EVENT_STREAM_STALLED, "/live/a", nil)
+			server.NormalizeStreamName("/Live/A")
+		}
+	}()
+
+	old := loadTestConf(t, "")
+	for i := 1; i <= 20; i++ {
+		conf := loadTestConf(t, fmt.Sprintf(`
+[rtsp]
+announce_rate_limit=%d
+[webhook]
+url=http://127.0.0.1:1/%d
+queue_size=1
+[stream_name]
+lowercase=%d
+`, i%3, i, i%2))
+		changes := DiffConf(old, conf)
+		utils.SetConf(conf)
+		server.ApplyConf(changes)
+		old = conf
+	}
+	close(stop)
+	wg.Wait()
+
+	if server.AnnounceLimiter() == nil || server.Webhook() == nil {
+		t.Fatalf("limiter %v webhook %v, want both from the last config", server.AnnounceLimiter(), server.Webhook())
+	}
+	if server.Webhook().URL != "http://127.0.0.1:1/20" {
+		t.Fatalf("webhook url = %s", server.Webhook().URL)
+	}
+	server.Webhook().Close()
+}
@@ -0,0 +1,121 @@
+package rtsp
+
+import (
+	"testing"
+	"time"
+)
+
+func cronBits(values ...int) (bits uint64) {
+	for _, v := range values {
+		bits |= 1 << uint(v)
+	}
+	return
+}
+
+func TestParseCronField(t *testing.T) {
+	minute, dow := cronFields[0], cronFields[4]
+	tests := []struct {
+		field string
+		spec  cronField
+		want  uint64
+	}{
+		{"5", minute, cronBits(5)},
+		{"1,3-4", minute, cronBits(1, 3, 4)},
+		{"0-10/5", minute, cronBits(0, 5, 10)},
+		{"*/15", minute, cronBits(0, 15, 30, 45)},
+		{"5/20", minute, cronBits(5, 25, 45)},
+		{"1-5", dow, cronBits(1, 2, 3, 4, 5)},
+		{"7", dow, cronBits(7)},
+	}
+	for _, test := range tests {
+		bits, err := parseCronField(test.field, test.spec)
+		if err != nil || bits != test.want {
+			t.Errorf("%s %q: got %b %v, want %b", test.spec.name, test.field, bits, err, test.want)
+		}
+	}
+}
+
+func TestParseCronInvalid(t *testing.T) {
+	for _, expr := range []string{
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+	} {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("%q parsed", expr)
+		}
+	}
+}
+
+func TestCronMatch(t *testing.T) {
+	tests := []struct {
+		expr  string
+		at    string
+		match bool
+	}{
+		{"30 9 * * *", "2026-10-16 09:30", true},
+		{"30 9 * * *", "2026-10-16 09:31", false},
+		{"*/15 * * * *", "2026-10-16 10:45", true},
+		// 7 is Sunday too
+		{"0 0 * * 7", "2026-10-18 00:00", true},
+		{"0 0 * * 7", "2026-10-19 00:00", false},
+		// both day fields restricted: either matches, Friday or the 13th
+		{"0 0 13 * 5", "2026-11-13 00:00", true},
+		{"0 0 13 * 5", "2026-10-13 00:00", true},
+		{"0 0 13 * 5", "2026-10-16 00:00", true},
+		{"0 0 13 * 5", "2026-10-20 00:00", false},
+		// a field starting with * is not restricted, both must match
+		{"0 0 */2 * 1", "2026-10-19 00:00", true},
+		{"0 0 */2 * 1", "2026-10-21 00:00", false},
+		{"0 0 */2 * 1", "2026-10-12 00:00", false},
+		{"0 0 13 * *", "2026-10-16 00:00", false},
+	}
+	for _, test := range tests {
+		schedule, err := ParseCron(test.expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		at, _ := time.ParseInLocation("2006-01-02 15:04", test.at, time.UTC)
+		if schedule.Match(at) != test.match {
+			t.Errorf("%q at %s: match %v", test.expr, test.at, !test.match)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	tests := []struct {
+		expr string
+		from string
+		want string
+	}{
+		{"*/15 * * * *", "2026-10-16 10:46", "2026-10-16 11:00"},
+		{"30 9 * * *", "2026-10-16 09:30", "2026-10-17 09:30"},
+		// month and year ends
+		{"0 0 1 * *", "2026-01-31 10:00", "2026-02-01 00:00"},
+		{"0 0 31 * *", "2026-04-15 00:00", "2026-05-31 00:00"},
+		{"30 6 * 1 *", "2026-12-15 00:00", "2027-01-01 06:30"},
+		{"0 0 29 2 *", "2026-03-01 00:00", "2028-02-29 00:00"},
+		// never
+		{"0 0 30 2 *", "2026-10-16 00:00", ""},
+	}
+	for _, test := range tests {
+		schedule, err := ParseCron(test.expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		from, _ := time.ParseInLocation("2006-01-02 15:04", test.from, time.UTC)
+		var want time.Time
+		if test.want != "" {
+			want, _ = time.ParseInLocation("2006-01-02 15:04", test.want, time.UTC)
+		}
+		if next := schedule.Next(from); !next.Equal(want) {
+			t.Errorf("%q after %s: got %v, want %v", test.expr, test.from, next, want)
+		}
+	}
+}
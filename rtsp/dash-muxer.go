@@ -0,0 +1,75 @@
+package rtsp
+
+import (
+	"path"
+	"strconv"
+
+	"EasyDarwin/helper/penggy/EasyGoLib/utils"
+)
+
+const (
+	DASH_SEGMENT_TEMPLATE = "template"
+	DASH_SEGMENT_LIST     = "list"
+
+	DASH_MANIFEST_NAME = "manifest.mpd"
+)
+
+// DASHMuxer describes the MPEG-DASH output of a pusher. It is appended as a second
+// output to the ffmpeg process that already generates the HLS segments, so the
+// stream is pulled and demuxed only once.
+type DASHMuxer struct {
+	Dir             string
+	SegmentDuration int
+	// SegmentMode is DASH_SEGMENT_TEMPLATE or DASH_SEGMENT_LIST.
+	SegmentMode string
+	WindowSize  int
+}
+
+func NewDASHMuxer(dashDir string, pusher *Pusher) *DASHMuxer {
+	sec := utils.Conf().Section("dash")
+	mode := sec.Key("segment_mode").In(DASH_SEGMENT_TEMPLATE, []string{DASH_SEGMENT_TEMPLATE, DASH_SEGMENT_LIST})
+	// per stream mode, e.g. /stream_1=list
+	mode = sec.Key(pusher.Path()).In(mode, []string{DASH_SEGMENT_TEMPLATE, DASH_SEGMENT_LIST})
+	return &DASHMuxer{
+		Dir:             path.Join(dashDir, pusher.Path()),
+		SegmentDuration: sec.Key("segment_duration_second").MustInt(2),
+		SegmentMode:     mode,
+		WindowSize:      sec.Key("window_size").MustInt(5),
+	}
+}
+
+func (muxer *DASHMuxer) ManifestPath() string {
+	return path.Join(muxer.Dir, DASH_MANIFEST_NAME)
+}
+
+// EnsureDirs creates the directory of the manifest and one directory per
+// representation, named by its id: the stream index, 0 for video and 1 for
+// audio. ffmpeg writes one initialization segment per representation, so
+// each gets its own init.mp4 there.
+func (muxer *DASHMuxer) EnsureDirs() error {
+	for _, id := range []string{"0", "1"} {
+		if err := utils.EnsureDir(path.Join(muxer.Dir, id)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// OutputArgs returns the ffmpeg output options and file for this muxer.
+func (muxer *DASHMuxer) OutputArgs() []string {
+	useTemplate := "1"
+	if muxer.SegmentMode == DASH_SEGMENT_LIST {
+		useTemplate = "0"
+	}
+	return []string{
+		"-f", "dash",
+		"-seg_duration", strconv.Itoa(muxer.SegmentDuration),
+		"-use_template", useTemplate,
+		"-use_timeline", "1",
+		"-window_size", strconv.Itoa(muxer.WindowSize),
+		"-remove_at_exit", "1",
+		"-init_seg_name", "$RepresentationID$/init.mp4",
+		"-media_seg_name", "$RepresentationID$/chunk-$Number%05d$.m4s",
+		muxer.ManifestPath(),
+	}
+}
@@ -0,0 +1,151 @@
+package rtsp
+
+import (
+	"log"
+	"sync"
+	"sync/atomic"
+
+	"EasyDarwin/helper/penggy/EasyGoLib/utils"
+	"EasyDarwin/models"
+)
+
+// ByteCounter counts the bytes a stream sends to its players against its data
+// cap. The count is saved to t_stream_usage every PersistEvery bytes and when
+// the pusher ends, so it carries over restarts and new publishers of the path.
+type ByteCounter struct {
+	Path         string
+	CapBytes     int64
+	PersistEvery int64
+
+	bytes       int64
+	persisted   int64
+	exceeded    int32
+	persisting  int32
+	persistLock sync.Mutex
+	logger      *log.Logger
+}
+
+// NewByteCounter returns the counter of path starting from the saved usage,
+// nil when the stream has no data cap.
+func NewByteCounter(path string, logger *log.Logger) *ByteCounter {
+	capBytes, err := models.FindStreamDataCap(path)
+	if err != nil {
+		logger.Printf("Query data cap of %s err:%v", path, err)
+	}
+	if capBytes <= 0 {
+		capBytes = utils.Conf().Section("rtsp").Key("stream_data_cap_bytes").MustInt64(0)
+	}
+	if capBytes <= 0 {
+		return nil
+	}
+	used, err := models.FindStreamUsage(path)
+	if err != nil {
+		logger.Printf("Query usage of %s err:%v", path, err)
+	}
+	return &ByteCounter{
+		Path:         path,
+		CapBytes:     capBytes,
+		PersistEvery: utils.Conf().Section("rtsp").Key("stream_data_cap_persist_bytes").MustInt64(10 << 20),
+		bytes:        used,
+		persisted:    used,
+		logger:       logger,
+	}
+}
+
+// Add counts n bytes sent. It returns true once, for the bytes going over the cap.
+func (counter *ByteCounter) Add(n int64) bool {
+	bytes := atomic.AddInt64(&counter.bytes, n)
+	if bytes-atomic.LoadInt64(&counter.persisted) >= counter.PersistEvery && atomic.CompareAndSwapInt32(&counter.persisting, 0, 1) {
+		go func() {
+			counter.Persist()
+			atomic.StoreInt32(&counter.persisting, 0)
+		}()
+	}
+	return bytes > counter.CapBytes && atomic.CompareAndSwapInt32(&counter.exceeded, 0, 1)
+}
+
+func (counter *ByteCounter) Bytes() int64 {
+	return atomic.LoadInt64(&counter.bytes)
+}
+
+// Exceeded tells whether the stream used up its data cap.
+func (counter *ByteCounter) Exceeded() bool {
+	return counter.Bytes() >= counter.CapBytes
+}
+
+// Persist saves the count. Saves are serialized and always take the current
+// count, so the table never goes back to an older one.
+func (counter *ByteCounter) Persist() {
+	counter.persistLock.Lock()
+	defer counter.persistLock.Unlock()
+	bytes := counter.Bytes()
+	if bytes == atomic.LoadInt64(&counter.persisted) {
+		return
+	}
+	if err := models.SaveStreamUsage(counter.Path, bytes); err != nil {
+		counter.logger.Printf("Save usage of %s err:%v", counter.Path, err)
+		return
+	}
+	atomic.StoreInt64(&counter.persisted, bytes)
+}
+
+// DataCapExceeded tells whether the stream at path used up its data cap, e.g.
+// before pulling it again.
+func (server *Server) DataCapExceeded(path string) bool {
+	counter := NewByteCounter(path, server.logger)
+	return counter != nil && counter.Exceeded()
+}
+
+// DataCapExceeded tells whether the pusher used up its data cap.
+func (pusher *Pusher) DataCapExceeded() bool {
+	return pusher.dataCounter != nil && pusher.dataCounter.Exceeded()
+}
+
+// stopForDataCap ends a stream that used up its data cap: the players are sent
+// a BYE and disconnected, a pulled stream is torn down at its source and a
+// pushed one disconnected.
+func (pusher *Pusher) stopForDataCap() {
+	counter := pusher.dataCounter
+	pusher.Logger().Printf("%v sent %d bytes, over its data cap of %d bytes, stop it", pusher, counter.Bytes(), counter.CapBytes)
+	counter.Persist()
+	for _, player := range pusher.GetPlayers() {
+		player.sendBye()
+		player.SetStopReason(DISCONNECT_REASON_DATA_CAP)
+		player.Stop()
+	}
+	if pusher.RTSPClient != nil {
+		if err := pusher.RTSPClient.RequestNoResp("TEARDOWN", map[string]string{}); err != nil {
+			pusher.Logger().Printf("teardown %v err:%v", pusher, err)
+		}
+	} else {
+		pusher.Session.SetStopReason(DISCONNECT_REASON_DATA_CAP)
+	}
+	pusher.Stop()
+	pusher.Server().Emit(EVENT_STREAM_CAP_EXCEEDED, pusher.Path(), map[string]interface{}{
+		"id":       pusher.ID(),
+		"bytes":    counter.Bytes(),
+		"capBytes": counter.CapBytes,
+	})
+}
+
+// SetDataCap sets the data cap of a pusher not added to the server yet, e.g.
+// one given with the stream instead of read from t_streams.
+func (pusher *Pusher) SetDataCap(capBytes int64) {
+	if capBytes <= 0 {
+		return
+	}
+	if pusher.dataCounter == nil {
+		used, err := models.FindStreamUsage(pusher.Path())
+		if err != nil {
+			pusher.Logger().Printf("Query usage of %s err:%v", pusher.Path(), err)
+		}
+		pusher.dataCounter = &ByteCounter{
+			Path:         pusher.Path(),
+			PersistEvery: utils.Conf().Section("rtsp").Key("stream_data_cap_persist_bytes").MustInt64(10 << 20),
+			bytes:        used,
+			persisted:    used,
+			logger:       pusher.Logger(),
+		}
+	}
+	pusher.dataCounter.CapBytes = capBytes
+}
@@ -0,0 +1,171 @@
+package rtsp
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"EasyDarwin/helper/penggy/EasyGoLib/utils"
+)
+
+// DVRManager records streams on demand with one ffmpeg per stream, next to
+// the recordings of save_stream_to_local: every recording is a new
+// rec_<time>.m3u8 under m3u8_dir_path/<path>/<date>. A recording ends when
+// it is stopped or when ffmpeg exits, e.g. because the stream ended.
+// With a storage other than the local disk, the files of a recording are
+// moved to it once the recording ended.
+type DVRManager struct {
+	server     *Server
+	logger     *log.Logger
+	ffmpeg     string
+	dir        string
+	tsDuration int
+	storage    StorageBackend
+
+	recordings map[string]*exec.Cmd // Path <-> ffmpeg
+	lock       sync.Mutex
+}
+
+func NewDVRManager(server *Server, storage StorageBackend) *DVRManager {
+	return &DVRManager{
+		server:     server,
+		logger:     server.logger,
+		ffmpeg:     utils.Conf().Section("rtsp").Key("ffmpeg_path").MustString(""),
+		dir:        utils.Conf().Section("rtsp").Key("m3u8_dir_path").MustString(""),
+		tsDuration: utils.Conf().Section("rtsp").Key("ts_duration_second").MustInt(6),
+		storage:    storage,
+		recordings: make(map[string]*exec.Cmd),
+	}
+}
+
+// StartRecording starts recording the live stream at streamPath. Recording a
+// stream already being recorded does nothing.
+func (dvr *DVRManager) StartRecording(streamPath string) error {
+	if dvr.ffmpeg == "" || dvr.dir == "" {
+		return fmt.Errorf("recording needs ffmpeg_path and m3u8_dir_path")
+	}
+	dvr.lock.Lock()
+	defer dvr.lock.Unlock()
+	if _, ok := dvr.recordings[streamPath]; ok {
+		return nil
+	}
+	pusher := dvr.server.GetPusher(streamPath)
+	if pusher == nil {
+		return fmt.Errorf("stream %s is not live", streamPath)
+	}
+	now := time.Now()
+	dir := path.Join(dvr.dir, streamPath, now.Format("20060102"))
+	if err := utils.EnsureDir(dir); err != nil {
+		return err
+	}
+	rtsp := fmt.Sprintf("rtsp://localhost:%d%s", dvr.server.TCPPort, streamPath)
+	params := []string{"-fflags", "genpts", "-rtsp_transport", "tcp", "-i", rtsp}
+	var codecParams []string
+	if paramStr := utils.Conf().Section("rtsp").Key(streamPath).MustString("-c:v copy -c:a aac"); paramStr != "default" {
+		codecParams = strings.Split(paramStr, " ")
+	}
+	name := "rec_" + now.Format("150405")
+	params = append(params, codecParams...)
+	params = append(params, VideoOutputArgs(pusher.VCodec(), codecParams, name+"_master.m3u8")...)
+	params = append(params, "-hls_time", strconv.Itoa(dvr.tsDuration), "-hls_list_size", "0", path.Join(dir, name+".m3u8"))
+	cmd := exec.Command(dvr.ffmpeg, params...)
+	f, err := os.OpenFile(path.Join(dir, name+".log"), os.O_RDWR|os.O_CREATE, 0755)
+	if err == nil {
+		cmd.Stdout = f
+		cmd.Stderr = f
+	}
+	if err = cmd.Start(); err != nil {
+		if f != nil {
+			f.Close()
+		}
+		return err
+	}
+	dvr.recordings[streamPath] = cmd
+	dvr.logger.Printf("start recording %s with ffmpeg [%v]", streamPath, cmd)
+	go func() {
+		cmd.Wait()
+		if f != nil {
+			f.Close()
+		}
+		dvr.lock.Lock()
+		if dvr.recordings[streamPath] == cmd {
+			delete(dvr.recordings, streamPath)
+		}
+		dvr.lock.Unlock()
+		dvr.logger.Printf("end recording %s", streamPath)
+		if _, local := dvr.storage.(*LocalStorage); !local {
+			dvr.upload(streamPath, dir, name)
+		}
+	}()
+	return nil
+}
+
+// upload moves the files of the recording name from dir to the storage,
+// under <path>/<date> as on the local disk. The ffmpeg log stays in dir.
+func (dvr *DVRManager) upload(streamPath, dir, name string) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		dvr.logger.Printf("upload recording %s err:%v", streamPath, err)
+		return
+	}
+	for _, info := range files {
+		if info.IsDir() || !strings.HasPrefix(info.Name(), name) || strings.HasSuffix(info.Name(), ".log") {
+			continue
+		}
+		file := path.Join(dir, info.Name())
+		p := path.Join(streamPath, path.Base(dir), info.Name())
+		f, err := os.Open(file)
+		if err != nil {
+			dvr.logger.Printf("upload recording %s err:%v", p, err)
+			continue
+		}
+		err = dvr.storage.Write(p, f)
+		f.Close()
+		if err != nil {
+			dvr.logger.Printf("upload recording %s err:%v", p, err)
+			continue
+		}
+		os.Remove(file)
+	}
+}
+
+// StopRecording asks the ffmpeg recording streamPath to terminate.
+func (dvr *DVRManager) StopRecording(streamPath string) {
+	dvr.lock.Lock()
+	cmd, ok := dvr.recordings[streamPath]
+	if ok {
+		delete(dvr.recordings, streamPath)
+	}
+	dvr.lock.Unlock()
+	if ok && cmd.Process != nil {
+		cmd.Process.Signal(syscall.SIGTERM)
+	}
+}
+
+func (dvr *DVRManager) Recording(streamPath string) bool {
+	dvr.lock.Lock()
+	_, ok := dvr.recordings[streamPath]
+	dvr.lock.Unlock()
+	return ok
+}
+
+// StopAll stops every recording.
+func (dvr *DVRManager) StopAll() {
+	dvr.lock.Lock()
+	recordings := dvr.recordings
+	dvr.recordings = make(map[string]*exec.Cmd)
+	dvr.lock.Unlock()
+	for _, cmd := range recordings {
+		if cmd.Process != nil {
+			cmd.Process.Signal(syscall.SIGTERM)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package rtsp
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	EVENT_STREAM_START         = "stream.start"
+	EVENT_STREAM_STOP          = "stream.stop"
+	EVENT_STREAM_PARAM_CHANGED = "stream.param_changed"
+	EVENT_SUBSCRIBER_JOIN      = "subscriber.join"
+	EVENT_SUBSCRIBER_LEAVE     = "subscriber.leave"
+	EVENT_STREAM_CAP_EXCEEDED  = "stream.cap_exceeded"
+	EVENT_STREAM_STALLED       = "stream.stalled"
+)
+
+type StreamEvent struct {
+	Event  string      `json:"event"`
+	Stream string      `json:"stream"`
+	Time   int64       `json:"time"`
+	Data   interface{} `json:"data,omitempty"`
+}
+
+// Broadcaster fans stream events out to its subscribers, e.g. the SSE connections
+// of the web dashboard. Every subscriber has a buffered channel; a subscriber whose
+// buffer is full is dropped and its channel closed, so Publish never blocks.
+type Broadcaster struct {
+	BufferSize int
+
+	subscribers map[chan *StreamEvent]struct{}
+	lock        sync.Mutex
+}
+
+var broadcasterDroppedTotal = NewCounter("rtsp_event_subscribers_dropped_total", "Event subscribers dropped because they did not keep up.")
+
+func NewBroadcaster(bufferSize int) *Broadcaster {
+	return &Broadcaster{
+		BufferSize:  bufferSize,
+		subscribers: make(map[chan *StreamEvent]struct{}),
+	}
+}
+
+func (broadcaster *Broadcaster) Subscribe() chan *StreamEvent {
+	ch := make(chan *StreamEvent, broadcaster.BufferSize)
+	broadcaster.lock.Lock()
+	broadcaster.subscribers[ch] = struct{}{}
+	broadcaster.lock.Unlock()
+	return ch
+}
+
+// Unsubscribe removes and closes ch if it was not dropped already.
+func (broadcaster *Broadcaster) Unsubscribe(ch chan *StreamEvent) {
+	broadcaster.lock.Lock()
+	if _, ok := broadcaster.subscribers[ch]; ok {
+		delete(broadcaster.subscribers, ch)
+		close(ch)
+	}
+	broadcaster.lock.Unlock()
+}
+
+func (broadcaster *Broadcaster) Publish(e *StreamEvent) {
+	broadcaster.lock.Lock()
+	for ch := range broadcaster.subscribers {
+		select {
+		case ch <- e:
+		default:
+			delete(broadcaster.subscribers, ch)
+			close(ch)
+			broadcasterDroppedTotal.Inc()
+		}
+	}
+	broadcaster.lock.Unlock()
+}
+
+func (broadcaster *Broadcaster) Len() int {
+	broadcaster.lock.Lock()
+	defer broadcaster.lock.Unlock()
+	return len(broadcaster.subscribers)
+}
+
+// Emit sends a stream event to the webhook and the event subscribers.
+func (server *Server) Emit(event, stream string, data interface{}) {
+	e := &StreamEvent{
+		Event:  event,
+		Stream: stream,
+		Time:   time.Now().Unix(),
+		Data:   data,
+	}
+	server.Webhook().Post(e)
+	server.Events.Publish(e)
+}
@@ -0,0 +1,165 @@
+package rtsp
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+)
+
+// GeoDB is a MaxMind GeoLite2 database in its CSV edition, the Country or
+// the ASN one, unzipped in a directory. The networks are kept in memory as
+// sorted address ranges.
+type GeoDB struct {
+	ranges []geoRange
+}
+
+type geoRange struct {
+	start, end [16]byte
+	country    string
+	asn        uint
+	org        string
+}
+
+// OpenCountryDB reads the GeoLite2-Country-Blocks-IPv4.csv,
+// GeoLite2-Country-Blocks-IPv6.csv and GeoLite2-Country-Locations-en.csv
+// files of dir.
+func OpenCountryDB(dir string) (db *GeoDB, err error) {
+	countries := make(map[string]string)
+	err = readGeoCSV(filepath.Join(dir, "GeoLite2-Country-Locations-en.csv"), func(row map[string]string) error {
+		countries[row["geoname_id"]] = row["country_iso_code"]
+		return nil
+	})
+	if err != nil {
+		return
+	}
+	db = &GeoDB{}
+	for _, blocks := range []string{"GeoLite2-Country-Blocks-IPv4.csv", "GeoLite2-Country-Blocks-IPv6.csv"} {
+		err = readGeoCSV(filepath.Join(dir, blocks), func(row map[string]string) error {
+			r, err := newGeoRange(row["network"])
+			if err != nil {
+				return err
+			}
+			// anonymous proxies and such only have the registered country
+			id := row["geoname_id"]
+			if id == "" {
+				id = row["registered_country_geoname_id"]
+			}
+			r.country = countries[id]
+			db.ranges = append(db.ranges, r)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	db.sort()
+	return
+}
+
+// OpenASNDB reads the GeoLite2-ASN-Blocks-IPv4.csv and
+// GeoLite2-ASN-Blocks-IPv6.csv files of dir.
+func OpenASNDB(dir string) (db *GeoDB, err error) {
+	db = &GeoDB{}
+	for _, blocks := range []string{"GeoLite2-ASN-Blocks-IPv4.csv", "GeoLite2-ASN-Blocks-IPv6.csv"} {
+		err = readGeoCSV(filepath.Join(dir, blocks), func(row map[string]string) error {
+			r, err := newGeoRange(row["network"])
+			if err != nil {
+				return err
+			}
+			asn, err := strconv.ParseUint(row["autonomous_system_number"], 10, 32)
+			if err != nil {
+				return fmt.Errorf("network %s asn: %v", row["network"], err)
+			}
+			r.asn = uint(asn)
+			r.org = row["autonomous_system_organization"]
+			db.ranges = append(db.ranges, r)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	db.sort()
+	return
+}
+
+// readGeoCSV calls fn with every row of the CSV file, by the column names of
+// its header.
+func readGeoCSV(file string, fn func(row map[string]string) error) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	reader := csv.NewReader(f)
+	reader.ReuseRecord = true
+	header, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("%s: %v", file, err)
+	}
+	header = append([]string(nil), header...)
+	row := make(map[string]string, len(header))
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %v", file, err)
+		}
+		for i, name := range header {
+			row[name] = ""
+			if i < len(record) {
+				row[name] = record[i]
+			}
+		}
+		if err = fn(row); err != nil {
+			return fmt.Errorf("%s: %v", file, err)
+		}
+	}
+}
+
+// newGeoRange returns the range of addresses of network, e.g. 1.0.0.0/24,
+// IPv4 addresses being mapped to IPv6.
+func newGeoRange(network string) (r geoRange, err error) {
+	prefix, err := netip.ParsePrefix(network)
+	if err != nil {
+		return
+	}
+	prefix = prefix.Masked()
+	bits := prefix.Bits()
+	if prefix.Addr().Is4() {
+		bits += 96
+	}
+	r.start = prefix.Addr().As16()
+	r.end = r.start
+	for i := bits; i < 128; i++ {
+		r.end[i/8] |= 1 << uint(7-i%8)
+	}
+	return
+}
+
+func (db *GeoDB) sort() {
+	sort.Slice(db.ranges, func(i, j int) bool {
+		return bytes.Compare(db.ranges[i].start[:], db.ranges[j].start[:]) < 0
+	})
+}
+
+// lookup returns the range holding addr, nil if none. GeoLite2 networks do
+// not overlap.
+func (db *GeoDB) lookup(addr netip.Addr) *geoRange {
+	ip := addr.As16()
+	i := sort.Search(len(db.ranges), func(i int) bool {
+		return bytes.Compare(db.ranges[i].end[:], ip[:]) >= 0
+	})
+	if i == len(db.ranges) || bytes.Compare(db.ranges[i].start[:], ip[:]) > 0 {
+		return nil
+	}
+	return &db.ranges[i]
+}
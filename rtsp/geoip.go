@@ -0,0 +1,142 @@
+package rtsp
+
+import (
+	"fmt"
+	"net/netip"
+	"net/url"
+	"strings"
+
+	"EasyDarwin/helper/penggy/EasyGoLib/utils"
+)
+
+// IPPolicy admits the players of the RTSP server by the country of their IP,
+// resolved with the MaxMind GeoLite2 CSV databases of the [geoip] section, and
+// redirects the players of some countries to other servers, e.g. the edge of
+// a CDN. Publishers are not checked.
+type IPPolicy struct {
+	// ISO 3166-1 country codes, e.g. "CN". A player from a denied country is
+	// refused; with an allow list, so is a player from a country not in it,
+	// including an IP absent from the database such as a LAN one.
+	CountryDenyList  []string
+	CountryAllowList []string
+	// country <-> base URL of the server its players are redirected to
+	GeoRoute map[string]string
+
+	countries *GeoDB
+	asns      *GeoDB // nil without asn_db
+}
+
+// GeoInfo is where an IP comes from, empty when unknown.
+type GeoInfo struct {
+	Country string
+	ASN     uint
+	Org     string
+}
+
+// NewIPPolicy opens the databases of the [geoip] section. It returns nil when
+// no country database is configured.
+func NewIPPolicy() (policy *IPPolicy, err error) {
+	sec := utils.Conf().Section("geoip")
+	countryDB := sec.Key("country_db").MustString("")
+	if countryDB == "" {
+		return
+	}
+	policy = &IPPolicy{
+		CountryDenyList:  countryCodes(sec.Key("country_deny").Strings(",")),
+		CountryAllowList: countryCodes(sec.Key("country_allow").Strings(",")),
+		GeoRoute:         make(map[string]string),
+	}
+	for _, route := range sec.Key("geo_route").Strings(",") {
+		i := strings.Index(route, "=")
+		if i < 0 {
+			return nil, fmt.Errorf("geo_route[%s] is not country=url", route)
+		}
+		country, base := strings.ToUpper(strings.TrimSpace(route[:i])), strings.TrimSpace(route[i+1:])
+		if u, err := url.Parse(base); err != nil || u.Scheme != "rtsp" && u.Scheme != "rtsps" || u.Host == "" {
+			return nil, fmt.Errorf("geo_route[%s] url is not rtsp://host[:port][/path]", route)
+		}
+		policy.GeoRoute[country] = base
+	}
+	if policy.countries, err = OpenCountryDB(countryDB); err != nil {
+		return nil, fmt.Errorf("open country_db[%s] err:%v", countryDB, err)
+	}
+	if asnDB := sec.Key("asn_db").MustString(""); asnDB != "" {
+		if policy.asns, err = OpenASNDB(asnDB); err != nil {
+			return nil, fmt.Errorf("open asn_db[%s] err:%v", asnDB, err)
+		}
+	}
+	return
+}
+
+func countryCodes(codes []string) []string {
+	for i := range codes {
+		codes[i] = strings.ToUpper(codes[i])
+	}
+	return codes
+}
+
+// Lookup resolves the country and, with an ASN database, the autonomous
+// system of ip.
+func (policy *IPPolicy) Lookup(ip string) (info GeoInfo, err error) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return
+	}
+	if r := policy.countries.lookup(addr); r != nil {
+		info.Country = r.country
+	}
+	if policy.asns == nil {
+		return
+	}
+	if r := policy.asns.lookup(addr); r != nil {
+		info.ASN = r.asn
+		info.Org = r.org
+	}
+	return
+}
+
+// Allow reports whether the players from country are admitted.
+func (policy *IPPolicy) Allow(country string) bool {
+	for _, denied := range policy.CountryDenyList {
+		if denied == country {
+			return false
+		}
+	}
+	if len(policy.CountryAllowList) == 0 {
+		return true
+	}
+	for _, allowed := range policy.CountryAllowList {
+		if allowed == country {
+			return true
+		}
+	}
+	return false
+}
+
+// applyIPPolicy answers a DESCRIBE from a refused player with 403, and one
+// from a routed country with a redirect to the same stream on the server of
+// the route. It returns false when the player is left to the server.
+func (session *Session) applyIPPolicy(policy *IPPolicy, reqURL *url.URL, res *Response) bool {
+	info, err := policy.Lookup(session.clientIP)
+	if err != nil {
+		session.logger.Printf("geoip lookup %s err:%v", session.clientIP, err)
+	}
+	if !policy.Allow(info.Country) {
+		session.logger.Printf("refuse %s from country[%s] asn[%d %s]", session.Path, info.Country, info.ASN, info.Org)
+		res.StatusCode = 403
+		res.Status = "Forbidden"
+		return true
+	}
+	base, ok := policy.GeoRoute[info.Country]
+	if !ok {
+		return false
+	}
+	location, _ := url.Parse(base)
+	location.Path = strings.TrimSuffix(location.Path, "/") + session.Path
+	location.RawQuery = reqURL.RawQuery
+	session.logger.Printf("route %s from country[%s] asn[%d %s] to %s", session.Path, info.Country, info.ASN, info.Org, location)
+	res.StatusCode = 302
+	res.Status = "Moved Temporarily"
+	res.Header["Location"] = location.String()
+	return true
+}
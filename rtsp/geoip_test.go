@@ -0,0 +1,51 @@
+package rtsp
+
+import (
+	"io/ioutil"
+	"net/netip"
+	"path/filepath"
+	"testing"
+)
+
+func TestGeoDBLookup(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"GeoLite2-Country-Locations-en.csv": "geoname_id,locale_code,continent_code,continent_name,country_iso_code,country_name,is_in_european_union\n" +
+			"1814991,en,AS,Asia,CN,China,0\n" +
+			"6252001,en,NA,\"North America\",US,\"United States\",0\n",
+		"GeoLite2-Country-Blocks-IPv4.csv": "network,geoname_id,registered_country_geoname_id,represented_country_geoname_id,is_anonymous_proxy,is_satellite_provider\n" +
+			"8.8.8.0/24,6252001,6252001,,0,0\n" +
+			"1.0.1.0/24,1814991,1814991,,0,0\n" +
+			"1.0.2.0/23,,1814991,,1,0\n",
+		"GeoLite2-Country-Blocks-IPv6.csv": "network,geoname_id,registered_country_geoname_id,represented_country_geoname_id,is_anonymous_proxy,is_satellite_provider\n" +
+			"2001:4860::/32,6252001,6252001,,0,0\n",
+	}
+	for name, data := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db, err := OpenCountryDB(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]string{
+		"8.8.8.8":          "US",
+		"1.0.1.255":        "CN",
+		"1.0.3.1":          "CN",
+		"1.0.4.1":          "",
+		"8.8.9.0":          "",
+		"2001:4860:1::1":   "US",
+		"2001:4861::1":     "",
+		"::ffff:8.8.8.200": "US",
+	}
+	for ip, country := range tests {
+		got := ""
+		if r := db.lookup(netip.MustParseAddr(ip)); r != nil {
+			got = r.country
+		}
+		if got != country {
+			t.Errorf("%s: got %q, want %q", ip, got, country)
+		}
+	}
+}
@@ -0,0 +1,273 @@
+package rtsp
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NodeStats is the load of a node, served by /api/v1/stats and polled by the
+// BackendPool of a front-end.
+type NodeStats struct {
+	RTSPPort int            `json:"rtspPort"`
+	Pushers  int            `json:"pushers"`
+	Players  int            `json:"players"`
+	Streams  map[string]int `json:"streams"` // path <-> players
+}
+
+func (server *Server) NodeStats() NodeStats {
+	stats := NodeStats{
+		RTSPPort: server.TCPPort,
+		Streams:  make(map[string]int),
+	}
+	for path, pusher := range server.GetPushers() {
+		players := len(pusher.GetPlayers())
+		stats.Pushers++
+		stats.Players += players
+		stats.Streams[path] = players
+	}
+	return stats
+}
+
+// Backend is a node of the cluster behind a front-end, known by the URL of
+// its HTTP API. Its RTSP address is the host of the URL with the RTSP port the
+// node reports.
+type Backend struct {
+	APIURL string
+
+	stats     NodeStats
+	healthy   bool
+	checkedAt time.Time
+}
+
+// Addr returns the host:port of the RTSP server of the backend.
+func (backend *Backend) Addr() string {
+	host := backend.APIURL
+	if u, err := url.Parse(backend.APIURL); err == nil {
+		host = u.Hostname()
+	}
+	return net.JoinHostPort(host, strconv.Itoa(backend.stats.RTSPPort))
+}
+
+// BackendPool polls the load of the backends from their /api/v1/stats every
+// interval. A backend that does not answer is left out of the pool until it
+// answers again.
+type BackendPool struct {
+	backends []*Backend
+	client   *http.Client
+	logger   *log.Logger
+	lock     sync.Mutex
+	done     chan struct{}
+}
+
+func NewBackendPool(apiURLs []string, interval time.Duration, logger *log.Logger) *BackendPool {
+	pool := &BackendPool{
+		client: &http.Client{Timeout: interval / 2},
+		logger: logger,
+		done:   make(chan struct{}),
+	}
+	for _, apiURL := range apiURLs {
+		pool.backends = append(pool.backends, &Backend{APIURL: strings.TrimSuffix(apiURL, "/")})
+	}
+	go pool.run(interval)
+	return pool
+}
+
+func (pool *BackendPool) Stop() {
+	close(pool.done)
+}
+
+func (pool *BackendPool) run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		pool.Check()
+		select {
+		case <-ticker.C:
+		case <-pool.done:
+			return
+		}
+	}
+}
+
+// Check polls every backend once, in parallel.
+func (pool *BackendPool) Check() {
+	var wg sync.WaitGroup
+	for _, backend := range pool.backends {
+		wg.Add(1)
+		go func(backend *Backend) {
+			defer wg.Done()
+			stats, err := pool.poll(backend.APIURL)
+			pool.lock.Lock()
+			defer pool.lock.Unlock()
+			backend.checkedAt = time.Now()
+			if err != nil {
+				if backend.healthy {
+					pool.logger.Printf("backend %s removed from the pool, stats err:%v", backend.APIURL, err)
+				}
+				backend.healthy = false
+				return
+			}
+			if !backend.healthy {
+				pool.logger.Printf("backend %s added to the pool, rtsp on %d", backend.APIURL, stats.RTSPPort)
+			}
+			backend.stats = stats
+			backend.healthy = true
+		}(backend)
+	}
+	wg.Wait()
+}
+
+func (pool *BackendPool) poll(apiURL string) (stats NodeStats, err error) {
+	res, err := pool.client.Get(apiURL + "/api/v1/stats")
+	if err != nil {
+		return
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		err = fmt.Errorf("status %s", res.Status)
+		return
+	}
+	if err = json.NewDecoder(res.Body).Decode(&stats); err != nil {
+		return
+	}
+	if stats.RTSPPort <= 0 {
+		err = fmt.Errorf("invalid rtsp port %d", stats.RTSPPort)
+	}
+	return
+}
+
+// LoadBalancer sends the players of a front-end to the backends of its pool,
+// with an RTSP redirect. A stream goes to one of the backends that have it,
+// any backend when none has; of those, to the one with the fewest players of
+// it. Backends with as many are ordered by a hash of the stream and the
+// backend (rendezvous hashing), so a stream sticks to the same backends as
+// the pool changes. The counts are polled, and a pick counts for its backend
+// until the next poll.
+//
+// With Sticky, a player coming from the same IP goes to the same backend as
+// long as it is in the pool, for StickyTTL after its last pick.
+type LoadBalancer struct {
+	Pool      *BackendPool
+	Sticky    bool
+	StickyTTL time.Duration
+
+	sticky     map[string]*stickyBackend // client IP <-> backend
+	stickyLock sync.Mutex
+}
+
+type stickyBackend struct {
+	backend *Backend
+	usedAt  time.Time
+}
+
+func NewLoadBalancer(pool *BackendPool, sticky bool) *LoadBalancer {
+	return &LoadBalancer{
+		Pool:      pool,
+		Sticky:    sticky,
+		StickyTTL: time.Hour,
+		sticky:    make(map[string]*stickyBackend),
+	}
+}
+
+func (balancer *LoadBalancer) Stop() {
+	balancer.Pool.Stop()
+}
+
+// Pick returns the RTSP address of the backend for a player of path from
+// clientIP, false when no backend is in the pool.
+func (balancer *LoadBalancer) Pick(path, clientIP string) (string, bool) {
+	pool := balancer.Pool
+	now := time.Now()
+	balancer.stickyLock.Lock()
+	defer balancer.stickyLock.Unlock()
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	var backend *Backend
+	if balancer.Sticky {
+		for ip, s := range balancer.sticky {
+			if now.Sub(s.usedAt) >= balancer.StickyTTL {
+				delete(balancer.sticky, ip)
+			}
+		}
+	}
+	// a backend without the stream is only a candidate when none has it
+	anyHasPath := false
+	for _, b := range pool.backends {
+		if _, ok := b.stats.Streams[path]; ok && b.healthy {
+			anyHasPath = true
+			break
+		}
+	}
+	candidate := func(b *Backend) bool {
+		if !b.healthy {
+			return false
+		}
+		_, ok := b.stats.Streams[path]
+		return ok || !anyHasPath
+	}
+	if balancer.Sticky {
+		if s, ok := balancer.sticky[clientIP]; ok && candidate(s.backend) {
+			backend = s.backend
+		}
+	}
+	if backend == nil {
+		var bestHash uint64
+		for _, b := range pool.backends {
+			if !candidate(b) {
+				continue
+			}
+			hash := rendezvousHash(path, b.APIURL)
+			if backend == nil || b.stats.Streams[path] < backend.stats.Streams[path] ||
+				b.stats.Streams[path] == backend.stats.Streams[path] && hash > bestHash {
+				backend, bestHash = b, hash
+			}
+		}
+	}
+	if backend == nil {
+		return "", false
+	}
+	if backend.stats.Streams == nil {
+		backend.stats.Streams = make(map[string]int)
+	}
+	backend.stats.Streams[path]++
+	backend.stats.Players++
+	if balancer.Sticky {
+		balancer.sticky[clientIP] = &stickyBackend{backend: backend, usedAt: now}
+	}
+	return backend.Addr(), true
+}
+
+// redirectToBackend answers a DESCRIBE to a front-end with a redirect to the
+// backend picked by balancer.
+func (session *Session) redirectToBackend(balancer *LoadBalancer, reqURL *url.URL, res *Response) {
+	addr, ok := balancer.Pick(session.Path, session.clientIP)
+	if !ok {
+		session.logger.Printf("no backend to redirect %s to", session.Path)
+		res.StatusCode = 503
+		res.Status = "Service Unavailable"
+		return
+	}
+	location := url.URL{Scheme: "rtsp", Host: addr, Path: session.Path, RawQuery: reqURL.RawQuery}
+	session.logger.Printf("redirect %s to backend %s", session.Path, addr)
+	res.StatusCode = 302
+	res.Status = "Moved Temporarily"
+	res.Header["Location"] = location.String()
+}
+
+func rendezvousHash(path, backend string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(path))
+	h.Write([]byte{0})
+	h.Write([]byte(backend))
+	return h.Sum64()
+}
@@ -0,0 +1,65 @@
+package rtsp
+
+import (
+	"testing"
+)
+
+func newTestBalancer(streams ...map[string]int) *LoadBalancer {
+	pool := &BackendPool{}
+	for i, s := range streams {
+		pool.backends = append(pool.backends, &Backend{
+			APIURL:  "http://10.0.0." + string(rune('1'+i)) + ":10008",
+			stats:   NodeStats{RTSPPort: 554, Streams: s},
+			healthy: true,
+		})
+	}
+	return NewLoadBalancer(pool, false)
+}
+
+func TestLoadBalancerPickHasStream(t *testing.T) {
+	// only the second and third backends have the stream, the first one has
+	// no player of it and must still not be picked
+	balancer := newTestBalancer(
+		map[string]int{"/live/b": 0},
+		map[string]int{"/live/a": 5},
+		map[string]int{"/live/a": 3},
+	)
+	for i := 0; i < 2; i++ {
+		if addr, ok := balancer.Pick("/live/a", "192.168.1.1"); !ok || addr != "10.0.0.3:554" {
+			t.Fatalf("pick %d = %s %v, want the third backend", i, addr, ok)
+		}
+	}
+	for i := 0; i < 8; i++ {
+		if addr, _ := balancer.Pick("/live/a", "192.168.1.1"); addr == "10.0.0.1:554" {
+			t.Fatalf("pick %d went to the backend without the stream", i)
+		}
+	}
+	if a, b := balancer.Pool.backends[1].stats.Streams["/live/a"], balancer.Pool.backends[2].stats.Streams["/live/a"]; a != 9 || b != 9 {
+		t.Fatalf("players of the stream = %d, %d, want 9 each", a, b)
+	}
+	balancer.Pool.backends[1].healthy = false
+	balancer.Pool.backends[2].healthy = false
+	if addr, ok := balancer.Pick("/live/a", "192.168.1.1"); !ok || addr != "10.0.0.1:554" {
+		t.Fatalf("pick with no backend having the stream = %s %v, want the first backend", addr, ok)
+	}
+}
+
+func TestLoadBalancerPickNoneHasStream(t *testing.T) {
+	balancer := newTestBalancer(nil, map[string]int{"/live/b": 1})
+	first, ok := balancer.Pick("/live/a", "192.168.1.1")
+	if !ok {
+		t.Fatal("no backend picked")
+	}
+	// the pick counts for its backend, which now has the stream
+	for i := 0; i < 3; i++ {
+		if addr, _ := balancer.Pick("/live/a", "192.168.1.2"); addr != first {
+			t.Fatalf("pick %d = %s, want %s", i, addr, first)
+		}
+	}
+
+	balancer.Pool.backends[0].healthy = false
+	balancer.Pool.backends[1].healthy = false
+	if addr, ok := balancer.Pick("/live/a", "192.168.1.1"); ok {
+		t.Fatalf("pick with no healthy backend = %s", addr)
+	}
+}
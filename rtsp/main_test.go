@@ -0,0 +1,45 @@
+package rtsp_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"EasyDarwin/helper/go-ini/ini"
+	"EasyDarwin/helper/penggy/EasyGoLib/utils"
+	"EasyDarwin/models"
+	"EasyDarwin/rtsp"
+)
+
+// TestMain runs the tests against rtsp.GetServer(), started on a free port
+// with a scratch database. The fakes of rtsptest reach it over net.Pipe.
+func TestMain(m *testing.M) {
+	dir, err := ioutil.TempDir("", "rtsp-test")
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	// ini writes the default of a missing key back on read, so a key read by
+	// both Start and the first sessions is set here
+	conf, _ := ini.LoadSources(ini.LoadOptions{Insensitive: true}, []byte(`
+[rtsp]
+session_stat_enable=0
+network_buffer=204800
+`))
+	utils.SetConf(conf)
+	utils.FlagVarDBFile = filepath.Join(dir, "test.db")
+	if err = models.Init(); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	server := rtsp.GetServer()
+	server.TCPPort = 0
+	// AddPusher waits for the loop of Start, no need to wait for it here
+	go server.Start()
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
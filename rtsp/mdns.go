@@ -0,0 +1,277 @@
+package rtsp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"EasyDarwin/helper/penggy/EasyGoLib/utils"
+)
+
+const (
+	MDNS_SERVICE_TYPE = "_rtsp._tcp.local."
+
+	mdnsGroupAddr    = "224.0.0.251:5353"
+	mdnsServicesName = "_services._dns-sd._udp.local."
+	mdnsTTL          = 120
+
+	dnsTypeA   = 1
+	dnsTypePTR = 12
+	dnsTypeTXT = 16
+	dnsTypeSRV = 33
+	dnsTypeANY = 255
+
+	dnsClassIN         = 1
+	dnsClassCacheFlush = 0x8000
+)
+
+type dnsRecord struct {
+	name  string
+	rtype uint16
+	// records only this host answers for carry the cache-flush bit
+	unique bool
+	data   []byte
+}
+
+// MDNSAdvertiser announces the RTSP service as _rtsp._tcp on the local network
+// (DNS-SD over mDNS, RFC 6762/6763) and answers queries for it. The TXT record
+// carries the play path, the server version and the number of streams; call
+// Update when streams change. Stop says goodbye with a TTL of 0.
+type MDNSAdvertiser struct {
+	server   *Server
+	logger   *log.Logger
+	instance string // <name>._rtsp._tcp.local.
+	host     string // <hostname>.local.
+	path     string
+	version  string
+
+	group *net.UDPAddr
+	conn  *net.UDPConn
+
+	lock sync.Mutex
+	txt  []string
+}
+
+func NewMDNSAdvertiser(server *Server) (advertiser *MDNSAdvertiser, err error) {
+	group, err := net.ResolveUDPAddr("udp4", mdnsGroupAddr)
+	if err != nil {
+		return
+	}
+	conn, err := net.ListenMulticastUDP("udp4", nil, group)
+	if err != nil {
+		return
+	}
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "easydarwin"
+	}
+	hostname = strings.SplitN(hostname, ".", 2)[0]
+	name := utils.Conf().Section("rtsp").Key("mdns_name").MustString("EasyDarwin")
+	advertiser = &MDNSAdvertiser{
+		server:   server,
+		logger:   server.logger,
+		instance: strings.Replace(name, ".", "-", -1) + "." + MDNS_SERVICE_TYPE,
+		host:     hostname + ".local.",
+		path:     utils.Conf().Section("rtsp").Key("mdns_path").MustString("/live"),
+		version:  server.Version,
+		group:    group,
+		conn:     conn,
+	}
+	advertiser.txt = advertiser.buildTXT()
+	go advertiser.serve()
+	// announced twice, one second apart, see RFC 6762 8.3
+	advertiser.announce(mdnsTTL)
+	time.AfterFunc(time.Second, func() {
+		advertiser.announce(mdnsTTL)
+	})
+	return
+}
+
+// Update announces the TXT record again if the streams changed.
+func (advertiser *MDNSAdvertiser) Update() {
+	txt := advertiser.buildTXT()
+	advertiser.lock.Lock()
+	changed := strings.Join(txt, "\x00") != strings.Join(advertiser.txt, "\x00")
+	advertiser.txt = txt
+	advertiser.lock.Unlock()
+	if changed {
+		advertiser.announce(mdnsTTL)
+	}
+}
+
+func (advertiser *MDNSAdvertiser) Stop() {
+	advertiser.announce(0)
+	advertiser.conn.Close()
+}
+
+func (advertiser *MDNSAdvertiser) buildTXT() []string {
+	return []string{
+		"path=" + advertiser.path,
+		"version=" + advertiser.version,
+		fmt.Sprintf("streams=%d", advertiser.server.GetPusherSize()),
+	}
+}
+
+func (advertiser *MDNSAdvertiser) records() []dnsRecord {
+	advertiser.lock.Lock()
+	txt := advertiser.txt
+	advertiser.lock.Unlock()
+
+	srv := make([]byte, 6)
+	binary.BigEndian.PutUint16(srv[4:], uint16(advertiser.server.TCPPort))
+	srv = append(srv, encodeDNSName(advertiser.host)...)
+	var txtData []byte
+	for _, s := range txt {
+		if len(s) > 255 {
+			s = s[:255]
+		}
+		txtData = append(txtData, byte(len(s)))
+		txtData = append(txtData, s...)
+	}
+	records := []dnsRecord{
+		{name: mdnsServicesName, rtype: dnsTypePTR, data: encodeDNSName(MDNS_SERVICE_TYPE)},
+		{name: MDNS_SERVICE_TYPE, rtype: dnsTypePTR, data: encodeDNSName(advertiser.instance)},
+		{name: advertiser.instance, rtype: dnsTypeSRV, unique: true, data: srv},
+		{name: advertiser.instance, rtype: dnsTypeTXT, unique: true, data: txtData},
+	}
+	addrs, _ := net.InterfaceAddrs()
+	for _, addr := range addrs {
+		ipnet, ok := addr.(*net.IPNet)
+		if !ok || ipnet.IP.IsLoopback() {
+			continue
+		}
+		if ip4 := ipnet.IP.To4(); ip4 != nil {
+			records = append(records, dnsRecord{name: advertiser.host, rtype: dnsTypeA, unique: true, data: []byte(ip4)})
+		}
+	}
+	return records
+}
+
+// announce multicasts every record with ttl, 0 being a goodbye. The packets
+// leave from port 5353 as RFC 6762 asks, the multicast loopback of that socket
+// is off, so responders on this host do not see them.
+func (advertiser *MDNSAdvertiser) announce(ttl uint32) {
+	msg := encodeDNSResponse(advertiser.records(), ttl)
+	if _, err := advertiser.conn.WriteToUDP(msg, advertiser.group); err != nil {
+		advertiser.logger.Printf("mdns announce err:%v", err)
+	}
+}
+
+func (advertiser *MDNSAdvertiser) serve() {
+	buf := make([]byte, 9000)
+	for {
+		n, _, err := advertiser.conn.ReadFromUDP(buf)
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				continue
+			}
+			return
+		}
+		if advertiser.answers(buf[:n]) {
+			advertiser.announce(mdnsTTL)
+		}
+	}
+}
+
+// answers tells if msg is a query asking for one of the advertised records.
+func (advertiser *MDNSAdvertiser) answers(msg []byte) bool {
+	if len(msg) < 12 || msg[2]&0x80 != 0 {
+		return false
+	}
+	qdcount := int(binary.BigEndian.Uint16(msg[4:]))
+	off := 12
+	for i := 0; i < qdcount; i++ {
+		name, next, ok := decodeDNSName(msg, off)
+		if !ok || next+4 > len(msg) {
+			return false
+		}
+		qtype := binary.BigEndian.Uint16(msg[next:])
+		off = next + 4
+		switch {
+		case strings.EqualFold(name, mdnsServicesName), strings.EqualFold(name, MDNS_SERVICE_TYPE):
+			if qtype == dnsTypePTR || qtype == dnsTypeANY {
+				return true
+			}
+		case strings.EqualFold(name, advertiser.instance), strings.EqualFold(name, advertiser.host):
+			return true
+		}
+	}
+	return false
+}
+
+func encodeDNSResponse(records []dnsRecord, ttl uint32) []byte {
+	msg := make([]byte, 12)
+	// response, authoritative answer
+	binary.BigEndian.PutUint16(msg[2:], 0x8400)
+	binary.BigEndian.PutUint16(msg[6:], uint16(len(records)))
+	for _, record := range records {
+		msg = append(msg, encodeDNSName(record.name)...)
+		class := uint16(dnsClassIN)
+		if record.unique {
+			class |= dnsClassCacheFlush
+		}
+		buf := make([]byte, 10)
+		binary.BigEndian.PutUint16(buf, record.rtype)
+		binary.BigEndian.PutUint16(buf[2:], class)
+		binary.BigEndian.PutUint32(buf[4:], ttl)
+		binary.BigEndian.PutUint16(buf[8:], uint16(len(record.data)))
+		msg = append(msg, buf...)
+		msg = append(msg, record.data...)
+	}
+	return msg
+}
+
+// encodeDNSName encodes a dot terminated name without compression. The first
+// label may contain spaces, as instance names do.
+func encodeDNSName(name string) []byte {
+	var buf []byte
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		if len(label) > 63 {
+			label = label[:63]
+		}
+		buf = append(buf, byte(len(label)))
+		buf = append(buf, label...)
+	}
+	return append(buf, 0)
+}
+
+// decodeDNSName reads the name at off of msg, following compression pointers,
+// and returns it dot terminated with the offset after it.
+func decodeDNSName(msg []byte, off int) (name string, next int, ok bool) {
+	var labels []string
+	next = -1
+	for jumps := 0; jumps < 16; {
+		if off >= len(msg) {
+			return
+		}
+		n := int(msg[off])
+		switch {
+		case n == 0:
+			if next < 0 {
+				next = off + 1
+			}
+			return strings.Join(labels, ".") + ".", next, true
+		case n&0xc0 == 0xc0:
+			if off+2 > len(msg) {
+				return
+			}
+			if next < 0 {
+				next = off + 2
+			}
+			off = int(binary.BigEndian.Uint16(msg[off:]) & 0x3fff)
+			jumps++
+		default:
+			if off+1+n > len(msg) {
+				return
+			}
+			labels = append(labels, string(msg[off+1:off+1+n]))
+			off += 1 + n
+		}
+	}
+	return
+}
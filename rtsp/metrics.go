@@ -0,0 +1,163 @@
+package rtsp
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// Counter is a monotonically increasing metric exported in the Prometheus text format.
+type Counter struct {
+	Name  string
+	Help  string
+	value int64
+}
+
+func (c *Counter) Inc() {
+	atomic.AddInt64(&c.value, 1)
+}
+
+func (c *Counter) Add(n int64) {
+	atomic.AddInt64(&c.value, n)
+}
+
+func (c *Counter) Value() int64 {
+	return atomic.LoadInt64(&c.value)
+}
+
+// CounterVec is a family of counters partitioned by the value of a single label.
+type CounterVec struct {
+	Name  string
+	Help  string
+	Label string
+
+	lock     sync.RWMutex
+	counters map[string]*Counter
+}
+
+// WithLabelValue returns the counter for the given label value, creating it on first use.
+func (v *CounterVec) WithLabelValue(value string) *Counter {
+	v.lock.RLock()
+	c, ok := v.counters[value]
+	v.lock.RUnlock()
+	if ok {
+		return c
+	}
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	if c, ok = v.counters[value]; !ok {
+		c = &Counter{Name: v.Name, Help: v.Help}
+		v.counters[value] = c
+	}
+	return c
+}
+
+// GaugeVec is a family of values that go up and down, partitioned by the value
+// of a single label.
+type GaugeVec struct {
+	Name  string
+	Help  string
+	Label string
+
+	lock   sync.RWMutex
+	values map[string]int64
+}
+
+func (v *GaugeVec) Set(value string, n int64) {
+	v.lock.Lock()
+	v.values[value] = n
+	v.lock.Unlock()
+}
+
+// Delete removes the gauge of a label value, e.g. of a stream that ended.
+func (v *GaugeVec) Delete(value string) {
+	v.lock.Lock()
+	delete(v.values, value)
+	v.lock.Unlock()
+}
+
+var (
+	counters     []*Counter
+	counterVecs  []*CounterVec
+	gaugeVecs    []*GaugeVec
+	countersLock sync.RWMutex
+)
+
+func NewCounter(name, help string) *Counter {
+	c := &Counter{Name: name, Help: help}
+	countersLock.Lock()
+	counters = append(counters, c)
+	countersLock.Unlock()
+	return c
+}
+
+func NewCounterVec(name, help, label string) *CounterVec {
+	v := &CounterVec{Name: name, Help: help, Label: label, counters: make(map[string]*Counter)}
+	countersLock.Lock()
+	counterVecs = append(counterVecs, v)
+	countersLock.Unlock()
+	return v
+}
+
+func NewGaugeVec(name, help, label string) *GaugeVec {
+	v := &GaugeVec{Name: name, Help: help, Label: label, values: make(map[string]int64)}
+	countersLock.Lock()
+	gaugeVecs = append(gaugeVecs, v)
+	countersLock.Unlock()
+	return v
+}
+
+// WriteMetrics writes all registered counters and gauges to w in the Prometheus text exposition format.
+func WriteMetrics(w io.Writer) (err error) {
+	countersLock.RLock()
+	defer countersLock.RUnlock()
+	for _, c := range counters {
+		if _, err = fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n%s %d\n", c.Name, c.Help, c.Name, c.Name, c.Value()); err != nil {
+			return
+		}
+	}
+	for _, v := range counterVecs {
+		if _, err = fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", v.Name, v.Help, v.Name); err != nil {
+			return
+		}
+		v.lock.RLock()
+		values := make([]string, 0, len(v.counters))
+		for value := range v.counters {
+			values = append(values, value)
+		}
+		sort.Strings(values)
+		for _, value := range values {
+			if _, err = fmt.Fprintf(w, "%s{%s=%s} %d\n", v.Name, v.Label, strconv.Quote(value), v.counters[value].Value()); err != nil {
+				break
+			}
+		}
+		v.lock.RUnlock()
+		if err != nil {
+			return
+		}
+	}
+	for _, v := range gaugeVecs {
+		if _, err = fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n", v.Name, v.Help, v.Name); err != nil {
+			return
+		}
+		v.lock.RLock()
+		values := make([]string, 0, len(v.values))
+		for value := range v.values {
+			values = append(values, value)
+		}
+		sort.Strings(values)
+		for _, value := range values {
+			if _, err = fmt.Fprintf(w, "%s{%s=%s} %d\n", v.Name, v.Label, strconv.Quote(value), v.values[value]); err != nil {
+				break
+			}
+		}
+		v.lock.RUnlock()
+		if err != nil {
+			return
+		}
+	}
+	return
+}
@@ -2,10 +2,14 @@ package rtsp
 
 import (
 	"EasyDarwin/helper/penggy/EasyGoLib/utils"
+	"bytes"
+	"net"
 	"sync"
 	"time"
 )
 
+var subscriberPacketDropTotal = NewCounterVec("rtsp_subscriber_packet_drop_total", "RTP packets dropped instead of being sent to a player.", "reason")
+
 type Player struct {
 	*Session
 	Pusher               *Pusher
@@ -14,11 +18,37 @@ type Player struct {
 	queueLimit           int
 	dropPacketWhenPaused bool
 	paused               bool
+	reorderBuffers       map[RTPType]*ReorderBuffer
+	// cumulative packets lost per SSRC, from the player's receiver reports
+	reportedLost map[uint32]int
+	// fraction lost per SSRC in 1/256 from the last receiver reports, read by
+	// the pusher for REMB
+	fractionLost     map[uint32]uint8
+	fractionLostLock sync.Mutex
+
+	// New packets are dropped while the queue holds more than MaxWriteBacklogBytes,
+	// so a slow player never blocks the pusher. A player that stays over it for
+	// MaxBacklogDuration is disconnected, with an RTCP BYE first if ByeOnBacklog.
+	// Zero disables either limit.
+	MaxWriteBacklogBytes int
+	MaxBacklogDuration   time.Duration
+	ByeOnBacklog         bool
+	queueBytes           int
+	backlogSince         time.Time
+	backlogDropped       bool
+	// SSRC of the last packet sent per media, for the BYE
+	ssrcs map[RTPType]uint32
 }
 
 func NewPlayer(session *Session, pusher *Pusher) (player *Player) {
 	queueLimit := utils.Conf().Section("rtsp").Key("player_queue_limit").MustInt(0)
 	dropPacketWhenPaused := utils.Conf().Section("rtsp").Key("drop_packet_when_paused").MustInt(0)
+	reorderBufferSize := utils.Conf().Section("rtsp").Key("reorder_buffer_size").MustInt(0)
+	reorderJitterWindow := utils.Conf().Section("rtsp").Key("reorder_jitter_window").MustInt(50)
+	writeTimeout := utils.Conf().Section("rtsp").Key("player_write_timeout").MustInt(0)
+	maxWriteBacklogBytes := utils.Conf().Section("rtsp").Key("player_max_write_backlog_bytes").MustInt(0)
+	maxBacklogDuration := utils.Conf().Section("rtsp").Key("player_max_backlog_duration").MustInt(0)
+	byeOnBacklog := utils.Conf().Section("rtsp").Key("player_backlog_bye").MustInt(1)
 	player = &Player{
 		Session:              session,
 		Pusher:               pusher,
@@ -27,14 +57,79 @@ func NewPlayer(session *Session, pusher *Pusher) (player *Player) {
 		queueLimit:           queueLimit,
 		dropPacketWhenPaused: dropPacketWhenPaused != 0,
 		paused:               false,
+		reorderBuffers:       make(map[RTPType]*ReorderBuffer),
+		reportedLost:         make(map[uint32]int),
+		fractionLost:         make(map[uint32]uint8),
+		MaxWriteBacklogBytes: maxWriteBacklogBytes,
+		MaxBacklogDuration:   time.Duration(maxBacklogDuration) * time.Millisecond,
+		ByeOnBacklog:         byeOnBacklog != 0,
+		ssrcs:                make(map[RTPType]uint32),
+	}
+	if session.Conn != nil {
+		session.Conn.writeTimeout = time.Duration(writeTimeout) * time.Millisecond
+	}
+	if reorderBufferSize > 0 {
+		window := time.Duration(reorderJitterWindow) * time.Millisecond
+		player.reorderBuffers[RTP_TYPE_AUDIO] = NewReorderBuffer(reorderBufferSize, window, player.enqueue)
+		player.reorderBuffers[RTP_TYPE_VIDEO] = NewReorderBuffer(reorderBufferSize, window, player.enqueue)
 	}
 	session.StopHandles = append(session.StopHandles, func() {
 		pusher.RemovePlayer(player)
+		for _, rb := range player.reorderBuffers {
+			rb.Stop()
+		}
+		// under the lock, so Start can not miss it between its check and Wait
+		player.cond.L.Lock()
 		player.cond.Broadcast()
+		player.cond.L.Unlock()
 	})
 	return
 }
 
+// HandleRTCP takes the packet loss from the player's receiver reports, which
+// may make the pusher send a REMB, and forwards a FIR to the pusher of the
+// stream it is playing.
+func (player *Player) HandleRTCP(rtpType RTPType, rtcpBytes []byte) {
+	if blocks := ParseRTCPReportBlocks(rtcpBytes); len(blocks) > 0 {
+		player.fractionLostLock.Lock()
+		for _, block := range blocks {
+			player.reportedLost[block.SSRC] = block.CumulativeLost
+			player.fractionLost[block.SSRC] = block.FractionLost
+		}
+		player.fractionLostLock.Unlock()
+		lost := 0
+		for _, n := range player.reportedLost {
+			lost += n
+		}
+		player.PacketsLost = lost
+		if player.Pusher != nil {
+			player.Pusher.UpdateREMB()
+		}
+	}
+	if rtpType != RTP_TYPE_VIDEOCONTROL {
+		return
+	}
+	fir := ParseRTCPFeedback(rtcpBytes, RTCP_PSFB_FMT_FIR)
+	if fir == nil || player.Pusher == nil {
+		return
+	}
+	if err := player.Pusher.RequestKeyFrame(fir.SenderSSRC, fir.FIRSSRC); err != nil {
+		player.logger.Printf("forward fir to pusher %v error, %v", player.Pusher, err)
+	}
+}
+
+// FractionLost returns the fraction lost per SSRC in 1/256 from the last
+// receiver reports of the player.
+func (player *Player) FractionLost() map[uint32]uint8 {
+	player.fractionLostLock.Lock()
+	defer player.fractionLostLock.Unlock()
+	fractionLost := make(map[uint32]uint8, len(player.fractionLost))
+	for ssrc, fraction := range player.fractionLost {
+		fractionLost[ssrc] = fraction
+	}
+	return fractionLost
+}
+
 func (player *Player) QueueRTP(pack *RTPPack) *Player {
 	logger := player.logger
 	if pack == nil {
@@ -44,10 +139,41 @@ func (player *Player) QueueRTP(pack *RTPPack) *Player {
 	if player.paused && player.dropPacketWhenPaused {
 		return player
 	}
+	if rb, ok := player.reorderBuffers[pack.Type]; ok {
+		rb.Push(pack)
+		return player
+	}
+	player.enqueue(pack)
+	return player
+}
+
+func (player *Player) enqueue(pack *RTPPack) {
+	logger := player.logger
 	player.cond.L.Lock()
+	if player.MaxWriteBacklogBytes > 0 && player.queueBytes+pack.Buffer.Len() > player.MaxWriteBacklogBytes {
+		subscriberPacketDropTotal.WithLabelValue("backpressure").Inc()
+		now := time.Now()
+		if player.backlogSince.IsZero() {
+			player.backlogSince = now
+		}
+		disconnect := player.MaxBacklogDuration > 0 && !player.backlogDropped && now.Sub(player.backlogSince) >= player.MaxBacklogDuration
+		if disconnect {
+			player.backlogDropped = true
+		}
+		player.cond.L.Unlock()
+		if disconnect {
+			logger.Printf("Player %s, write backlog over %d bytes for %v, disconnect it", player.String(), player.MaxWriteBacklogBytes, player.MaxBacklogDuration)
+			go player.dropForBacklog()
+		}
+		return
+	}
+	player.backlogSince = time.Time{}
 	player.queue = append(player.queue, pack)
+	player.queueBytes += pack.Buffer.Len()
 	if oldLen := len(player.queue); player.queueLimit > 0 && oldLen > player.queueLimit {
+		player.queueBytes -= player.queue[0].Buffer.Len()
 		player.queue = player.queue[1:]
+		subscriberPacketDropTotal.WithLabelValue("queue_limit").Inc()
 		if player.debugLogEnable {
 			len := len(player.queue)
 			logger.Printf("Player %s, QueueRTP, exceeds limit(%d), drop %d old packets, current queue.len=%d\n", player.String(), player.queueLimit, oldLen-len, len)
@@ -55,21 +181,21 @@ func (player *Player) QueueRTP(pack *RTPPack) *Player {
 	}
 	player.cond.Signal()
 	player.cond.L.Unlock()
-	return player
 }
 
 func (player *Player) Start() {
 	logger := player.logger
 	timer := time.Unix(0, 0)
-	for !player.Stoped {
+	for !player.Stoped() {
 		var pack *RTPPack
 		player.cond.L.Lock()
-		if len(player.queue) == 0 {
+		if len(player.queue) == 0 && !player.Stoped() {
 			player.cond.Wait()
 		}
 		if len(player.queue) > 0 {
 			pack = player.queue[0]
 			player.queue = player.queue[1:]
+			player.queueBytes -= pack.Buffer.Len()
 		}
 		queueLen := len(player.queue)
 		player.cond.L.Unlock()
@@ -77,13 +203,25 @@ func (player *Player) Start() {
 			continue
 		}
 		if pack == nil {
-			if !player.Stoped {
+			if !player.Stoped() {
 				logger.Printf("player not stoped, but queue take out nil pack")
 			}
 			continue
 		}
 		if err := player.SendRTP(pack); err != nil {
 			logger.Println(err)
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				// a player that can not take a packet within the write timeout is gone
+				player.SetStopReason(DISCONNECT_REASON_BACKPRESSURE)
+				player.Stop()
+				return
+			}
+		} else if pack.Type == RTP_TYPE_AUDIO || pack.Type == RTP_TYPE_VIDEO {
+			if rtp := ParseRTP(pack.Buffer.Bytes()); rtp != nil {
+				player.cond.L.Lock()
+				player.ssrcs[pack.Type] = uint32(rtp.SSRC)
+				player.cond.L.Unlock()
+			}
 		}
 		elapsed := time.Now().Sub(timer)
 		if player.debugLogEnable && elapsed >= 30*time.Second {
@@ -102,7 +240,78 @@ func (player *Player) Pause(paused bool) {
 	player.cond.L.Lock()
 	if paused && player.dropPacketWhenPaused && len(player.queue) > 0 {
 		player.queue = make([]*RTPPack, 0)
+		player.queueBytes = 0
 	}
 	player.paused = paused
 	player.cond.L.Unlock()
 }
+
+// dropForBacklog disconnects a player whose write backlog did not drain, saying
+// BYE for the media it was sent first. Stop closes the connection, which
+// unblocks the stalled writer.
+func (player *Player) dropForBacklog() {
+	if player.ByeOnBacklog {
+		player.sendBye()
+	}
+	player.SetStopReason(DISCONNECT_REASON_BACKPRESSURE)
+	player.Stop()
+}
+
+// byeWriteTimeout bounds the write of a BYE, the peer may not read at all.
+const byeWriteTimeout = 200 * time.Millisecond
+
+// sendBye sends an RTCP BYE for the media the player was sent. The BYE skips
+// the queue, a backlog would hold it up. Over TCP it is written straight to the
+// connection with a deadline of its own, and not at all while another write
+// is in progress: that write is stalled and a BYE after half a frame would not
+// parse.
+func (player *Player) sendBye() {
+	player.cond.L.Lock()
+	ssrcs := make(map[RTPType]uint32, len(player.ssrcs))
+	for rtpType, ssrc := range player.ssrcs {
+		ssrcs[rtpType] = ssrc
+	}
+	player.cond.L.Unlock()
+	if player.TransType == TRANS_TYPE_UDP {
+		for rtpType, ssrc := range ssrcs {
+			bye := &RTPPack{Type: rtcpType(rtpType), Buffer: bytes.NewBuffer(NewRTCPBye(ssrc))}
+			if err := player.SendRTP(bye); err != nil {
+				player.logger.Printf("Player %s, send rtcp bye error, %v", player.String(), err)
+				return
+			}
+		}
+		return
+	}
+	if player.Conn == nil {
+		return
+	}
+	if !player.connWLock.TryLock() {
+		player.logger.Printf("Player %s, write in progress, skip rtcp bye", player.String())
+		return
+	}
+	defer player.connWLock.Unlock()
+	frames := new(bytes.Buffer)
+	for rtpType, ssrc := range ssrcs {
+		channel := player.vRTPControlChannel
+		if rtpType == RTP_TYPE_AUDIO {
+			channel = player.aRTPControlChannel
+		}
+		bye := NewRTCPBye(ssrc)
+		frames.Write([]byte{0x24, byte(channel), byte(len(bye) >> 8), byte(len(bye))})
+		frames.Write(bye)
+	}
+	player.Conn.Conn.SetWriteDeadline(time.Now().Add(byeWriteTimeout))
+	if _, err := player.Conn.Conn.Write(frames.Bytes()); err != nil {
+		player.logger.Printf("Player %s, send rtcp bye error, %v", player.String(), err)
+		return
+	}
+	player.OutBytes += frames.Len()
+}
+
+// rtcpType returns the control type of the media of rtpType.
+func rtcpType(rtpType RTPType) RTPType {
+	if rtpType == RTP_TYPE_AUDIO {
+		return RTP_TYPE_AUDIOCONTROL
+	}
+	return RTP_TYPE_VIDEOCONTROL
+}
@@ -1,12 +1,15 @@
 package rtsp
 
 import (
+	"bytes"
+	"fmt"
 	"log"
 	"strings"
 	"sync"
 	"time"
 
 	"EasyDarwin/helper/penggy/EasyGoLib/utils"
+	"EasyDarwin/rtsp/sdp"
 )
 
 type Pusher struct {
@@ -21,7 +24,50 @@ type Pusher struct {
 	spsppsInSTAPaPack bool
 	cond              *sync.Cond
 	queue             []*RTPPack
-}
+
+	// taps see the packets broadcast to the players, see AddTap
+	taps     map[string]func(pack *RTPPack)
+	tapsLock sync.RWMutex
+
+	// a VPS started the gop cache, the key frame after it does not restart it
+	h265ParamSetsStarted bool
+
+	// key frame requests from players are forwarded at most once per firMinInterval
+	firMinInterval time.Duration
+	firForwardAs   string
+	firForwardAt   time.Time
+	firSeq         uint8
+	firLock        sync.Mutex
+
+	// a REMB asking for a lower bitrate is sent at most once per second when
+	// players report losing more than rembLossThreshold percent of the packets
+	rembEnable        bool
+	rembLossThreshold int
+	rembAt            time.Time
+	rembInBytes       int
+	rembLock          sync.Mutex
+
+	// bytes sent to the players, nil when the stream has no data cap
+	dataCounter *ByteCounter
+
+	// unix nanoseconds of the last packet received, and the stall timeout of
+	// the stream in t_streams, see PublisherWatchdog
+	lastPacketAt int64
+	stallTimeout time.Duration
+	stalled      int32
+	// held by the rebinds while they swap Session or RTSPClient, so that the
+	// watchdog does not tear down the source that replaced a stalled one
+	sourceLock sync.Mutex
+}
+
+var (
+	firForwardedTotal = NewCounterVec("rtsp_fir_forwarded_total", "Key frame requests from players forwarded to the publisher.", "stream")
+	rembSentTotal     = NewCounterVec("rtsp_rtcp_remb_sent_total", "RTCP REMB messages sent to the publisher.", "stream")
+	rembSuggestedKbps = NewGaugeVec("rtsp_rtcp_remb_suggested_bitrate_kbps", "Bitrate suggested to the publisher by the last RTCP REMB.", "stream")
+)
+
+// REMB_INTERVAL is the minimum interval between two REMB checks of a pusher.
+const REMB_INTERVAL = time.Second
 
 func (pusher *Pusher) String() string {
 	if pusher.Session != nil {
@@ -44,9 +90,24 @@ func (pusher *Pusher) SDPRaw() string {
 	return pusher.RTSPClient.SDPRaw
 }
 
+// SDP returns the session description served to the players: the SDP of the
+// source encoded back when it parsed, as sent otherwise (see sdp_strict).
+func (pusher *Pusher) SDP() string {
+	var _sdp *sdp.SessionDescription
+	if pusher.Session != nil {
+		_sdp = pusher.Session.Sdp
+	} else {
+		_sdp = pusher.RTSPClient.Sdp
+	}
+	if _sdp == nil {
+		return pusher.SDPRaw()
+	}
+	return _sdp.String()
+}
+
 func (pusher *Pusher) Stoped() bool {
 	if pusher.Session != nil {
-		return pusher.Session.Stoped
+		return pusher.Session.Stoped()
 	}
 	return pusher.RTSPClient.Stoped
 }
@@ -163,7 +224,15 @@ func NewClientPusher(client *RTSPClient) (pusher *Pusher) {
 
 		cond:  sync.NewCond(&sync.Mutex{}),
 		queue: make([]*RTPPack, 0),
+
+		firMinInterval: time.Duration(utils.Conf().Section("rtsp").Key("fir_min_interval").MustInt(1000)) * time.Millisecond,
+		firForwardAs:   utils.Conf().Section("rtsp").Key("fir_forward_as").In("pli", []string{"pli", "fir"}),
+
+		rembEnable:        utils.Conf().Section("rtsp").Key("remb_enable").MustInt(0) != 0,
+		rembLossThreshold: utils.Conf().Section("rtsp").Key("remb_loss_threshold").MustInt(10),
 	}
+	pusher.dataCounter = NewByteCounter(pusher.Path(), pusher.Logger())
+	pusher.watchStall()
 	client.RTPHandles = append(client.RTPHandles, func(pack *RTPPack) {
 		pusher.QueueRTP(pack)
 	})
@@ -185,8 +254,16 @@ func NewPusher(session *Session) (pusher *Pusher) {
 
 		cond:  sync.NewCond(&sync.Mutex{}),
 		queue: make([]*RTPPack, 0),
+
+		firMinInterval: time.Duration(utils.Conf().Section("rtsp").Key("fir_min_interval").MustInt(1000)) * time.Millisecond,
+		firForwardAs:   utils.Conf().Section("rtsp").Key("fir_forward_as").In("pli", []string{"pli", "fir"}),
+
+		rembEnable:        utils.Conf().Section("rtsp").Key("remb_enable").MustInt(0) != 0,
+		rembLossThreshold: utils.Conf().Section("rtsp").Key("remb_loss_threshold").MustInt(10),
 	}
 	pusher.bindSession(session)
+	pusher.dataCounter = NewByteCounter(pusher.Path(), pusher.Logger())
+	pusher.watchStall()
 	return
 }
 
@@ -197,6 +274,7 @@ func (pusher *Pusher) bindSession(session *Session) {
 			session.logger.Printf("Session recv rtp to pusher.but pusher got a new session[%v].", pusher.Session.ID)
 			return
 		}
+		session.countRTPLoss(pack)
 		pusher.QueueRTP(pack)
 	})
 	session.StopHandles = append(session.StopHandles, func() {
@@ -219,13 +297,16 @@ func (pusher *Pusher) RebindSession(session *Session) bool {
 		pusher.Logger().Printf("call RebindSession[%s] to a Client-Pusher. got false", session.ID)
 		return false
 	}
+	pusher.sourceLock.Lock()
 	sess := pusher.Session
 	pusher.bindSession(session)
+	pusher.sourceLock.Unlock()
 	session.Pusher = pusher
 
 	pusher.gopCacheLock.Lock()
 	pusher.gopCache = make([]*RTPPack, 0)
 	pusher.gopCacheLock.Unlock()
+	pusher.rewatchStall()
 	if sess != nil {
 		sess.Stop()
 	}
@@ -237,15 +318,124 @@ func (pusher *Pusher) RebindClient(client *RTSPClient) bool {
 		pusher.Logger().Printf("call RebindClient[%s] to a Session-Pusher. got false", client.ID)
 		return false
 	}
+	pusher.sourceLock.Lock()
 	sess := pusher.RTSPClient
 	pusher.RTSPClient = client
+	pusher.sourceLock.Unlock()
 	if sess != nil {
 		sess.Stop()
 	}
 	return true
 }
 
+// RequestKeyFrame asks the publisher for a key frame on behalf of a player, sending
+// a PLI or FIR on the video control channel. Requests arriving within firMinInterval
+// of the last forwarded one are dropped, so many players joining at once cost the
+// encoder a single key frame.
+func (pusher *Pusher) RequestKeyFrame(senderSSRC, mediaSSRC uint32) (err error) {
+	pusher.firLock.Lock()
+	if time.Since(pusher.firForwardAt) < pusher.firMinInterval {
+		pusher.firLock.Unlock()
+		return
+	}
+	pusher.firForwardAt = time.Now()
+	var rtcpBytes []byte
+	if pusher.firForwardAs == "fir" {
+		pusher.firSeq++
+		rtcpBytes = NewRTCPFIR(senderSSRC, mediaSSRC, pusher.firSeq)
+	} else {
+		rtcpBytes = NewRTCPPLI(senderSSRC, mediaSSRC)
+	}
+	pusher.firLock.Unlock()
+
+	if err = pusher.sendVideoControl(rtcpBytes); err != nil {
+		return
+	}
+	firForwardedTotal.WithLabelValue(pusher.Path()).Inc()
+	return
+}
+
+// UpdateREMB suggests a lower bitrate to the publisher when players lose
+// packets. The bitrate received from the publisher is measured between two
+// checks; a player losing a fraction loss of the packets of an SSRC above
+// rembLossThreshold suggests bitrate * (1 - loss/2), and the lowest suggestion
+// is sent in a REMB for the SSRCs with losses. Nothing is sent while the
+// players do not lose packets, it is up to the publisher to ramp up again.
+func (pusher *Pusher) UpdateREMB() {
+	if !pusher.rembEnable {
+		return
+	}
+	pusher.rembLock.Lock()
+	now := time.Now()
+	elapsed := now.Sub(pusher.rembAt)
+	if elapsed < REMB_INTERVAL {
+		pusher.rembLock.Unlock()
+		return
+	}
+	inBytes := pusher.InBytes()
+	bitrate := 0.0
+	if !pusher.rembAt.IsZero() {
+		bitrate = float64(inBytes-pusher.rembInBytes) * 8 / elapsed.Seconds()
+	}
+	pusher.rembAt = now
+	pusher.rembInBytes = inBytes
+	pusher.rembLock.Unlock()
+	if bitrate <= 0 {
+		return
+	}
+
+	suggested := bitrate
+	lossySSRCs := make(map[uint32]bool)
+	for _, player := range pusher.GetPlayers() {
+		for ssrc, fraction := range player.FractionLost() {
+			loss := float64(fraction) / 256
+			if loss*100 <= float64(pusher.rembLossThreshold) {
+				continue
+			}
+			lossySSRCs[ssrc] = true
+			if rate := bitrate * (1 - loss/2); rate < suggested {
+				suggested = rate
+			}
+		}
+	}
+	if len(lossySSRCs) == 0 {
+		return
+	}
+	ssrcs := make([]uint32, 0, len(lossySSRCs))
+	for ssrc := range lossySSRCs {
+		ssrcs = append(ssrcs, ssrc)
+	}
+	if err := pusher.sendVideoControl(NewRTCPREMB(0, uint64(suggested), ssrcs...)); err != nil {
+		pusher.Logger().Printf("send remb to pusher %v error, %v", pusher, err)
+		return
+	}
+	rembSentTotal.WithLabelValue(pusher.Path()).Inc()
+	rembSuggestedKbps.Set(pusher.Path(), int64(suggested/1000))
+}
+
+// sendVideoControl sends an RTCP packet to the publisher on the video control channel.
+func (pusher *Pusher) sendVideoControl(rtcpBytes []byte) error {
+	if pusher.Session != nil {
+		if pusher.Session.TransType == TRANS_TYPE_UDP {
+			if pusher.UDPServer == nil {
+				return fmt.Errorf("pusher use udp transport but udp server not found")
+			}
+			return pusher.UDPServer.SendVideoControl(rtcpBytes)
+		}
+		if pusher.Session.TransType == TRANS_TYPE_CHANNEL {
+			// recordings played by ffmpeg take no feedback
+			return nil
+		}
+		if pusher.Session.TransType == TRANS_TYPE_MERGE {
+			return pusher.Session.Merger.sendVideoControl(rtcpBytes)
+		}
+		return pusher.Session.SendRTP(&RTPPack{Type: RTP_TYPE_VIDEOCONTROL, Buffer: bytes.NewBuffer(rtcpBytes)})
+	}
+	return pusher.RTSPClient.SendRTCP(rtcpBytes)
+}
+
 func (pusher *Pusher) QueueRTP(pack *RTPPack) *Pusher {
+	pusher.touch()
 	pusher.cond.L.Lock()
 	pusher.queue = append(pusher.queue, pack)
 	pusher.cond.Signal()
@@ -294,13 +484,41 @@ func (pusher *Pusher) Stop() {
 }
 
 func (pusher *Pusher) BroadcastRTP(pack *RTPPack) *Pusher {
-	for _, player := range pusher.GetPlayers() {
+	pusher.tapsLock.RLock()
+	for _, tap := range pusher.taps {
+		tap(pack)
+	}
+	pusher.tapsLock.RUnlock()
+	players := pusher.GetPlayers()
+	for _, player := range players {
 		player.QueueRTP(pack)
 		pusher.AddOutputBytes(pack.Buffer.Len())
 	}
+	if pusher.dataCounter != nil && len(players) > 0 && pusher.dataCounter.Add(int64(pack.Buffer.Len()*len(players))) {
+		go pusher.stopForDataCap()
+	}
 	return pusher
 }
 
+// AddTap calls tap with every packet broadcast to the players, on the
+// goroutine of the pusher, until RemoveTap(id). The packet is shared with the
+// players, tap must copy it before changing it, and must not call AddTap or
+// RemoveTap.
+func (pusher *Pusher) AddTap(id string, tap func(pack *RTPPack)) {
+	pusher.tapsLock.Lock()
+	defer pusher.tapsLock.Unlock()
+	if pusher.taps == nil {
+		pusher.taps = make(map[string]func(pack *RTPPack))
+	}
+	pusher.taps[id] = tap
+}
+
+func (pusher *Pusher) RemoveTap(id string) {
+	pusher.tapsLock.Lock()
+	defer pusher.tapsLock.Unlock()
+	delete(pusher.taps, id)
+}
+
 func (pusher *Pusher) GetPlayers() (players map[string]*Player) {
 	players = make(map[string]*Player)
 	pusher.playersLock.RLock()
@@ -330,12 +548,19 @@ func (pusher *Pusher) AddPlayer(player *Player) *Pusher {
 	}
 
 	pusher.playersLock.Lock()
-	if _, ok := pusher.players[player.ID]; !ok {
+	_, ok := pusher.players[player.ID]
+	if !ok {
 		pusher.players[player.ID] = player
 		go player.Start()
 		logger.Printf("%v start, now player size[%d]", player, len(pusher.players))
 	}
 	pusher.playersLock.Unlock()
+	if !ok {
+		pusher.Server().Emit(EVENT_SUBSCRIBER_JOIN, pusher.Path(), map[string]interface{}{
+			"id":        player.ID,
+			"transType": player.TransType.String(),
+		})
+	}
 	return pusher
 }
 
@@ -346,9 +571,15 @@ func (pusher *Pusher) RemovePlayer(player *Player) *Pusher {
 		pusher.playersLock.Unlock()
 		return pusher
 	}
+	_, ok := pusher.players[player.ID]
 	delete(pusher.players, player.ID)
 	logger.Printf("%v end, now player size[%d]\n", player, len(pusher.players))
 	pusher.playersLock.Unlock()
+	if ok {
+		pusher.Server().Emit(EVENT_SUBSCRIBER_LEAVE, pusher.Path(), map[string]interface{}{
+			"id": player.ID,
+		})
+	}
 	return pusher
 }
 
@@ -360,6 +591,7 @@ func (pusher *Pusher) ClearPlayer() {
 	pusher.playersLock.Unlock()
 	go func() { // do not block
 		for _, v := range players {
+			v.SetStopReason(DISCONNECT_REASON_PUBLISHER_GONE)
 			v.Stop()
 		}
 	}()
@@ -421,56 +653,24 @@ func (pusher *Pusher) shouldSequenceStart(rtp *RTPInfo) bool {
 			return true
 		}
 		return false
-	} else if strings.EqualFold(pusher.VCodec(), "h265") {
-		if len(rtp.Payload) >= 3 {
-			firstByte := rtp.Payload[0]
-			headerType := (firstByte >> 1) & 0x3f
-			var frameType uint8
-			if headerType == 49 { //Fragmentation Units
-
-				FUHeader := rtp.Payload[2]
-				/*
-				   +---------------+
-				   |0|1|2|3|4|5|6|7|
-				   +-+-+-+-+-+-+-+-+
-				   |S|E|  FuType   |
-				   +---------------+
-				*/
-				rtpStart := (FUHeader & 0x80) != 0
-				if !rtpStart {
-					if (FUHeader & 0x40) != 0 {
-						//log.Printf("FU frame end")
-					}
-					return false
-				} else {
-					//log.Printf("FU frame start")
-				}
-				frameType = FUHeader & 0x3f
-			} else if headerType == 48 { //Aggregation Packets
-
-			} else if headerType == 50 { //PACI Packets
-
-			} else { // Single NALU
-				/*
-					+---------------+---------------+
-					|0|1|2|3|4|5|6|7|0|1|2|3|4|5|6|7|
-					+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
-					|F|   Type    |  LayerId  | TID |
-					+-------------+-----------------+
-				*/
-				frameType = firstByte & 0x7e
-			}
-			if frameType >= 16 && frameType <= 21 {
-				return true
-			}
-			if frameType == 32 {
-				// vps sps pps...
-				if len(rtp.Payload) < 200 { // consider sps pps header only.
-					return false
-				}
-				return true
-			}
+	} else if VideoCodecName(pusher.VCodec()) == VIDEO_CODEC_H265 {
+		nalType, ok := h265NALType(rtp.Payload)
+		if !ok {
+			return false
+		}
+		switch {
+		case nalType == H265_NAL_VPS:
+			// vps sps pps, then the key frame
+			pusher.h265ParamSetsStarted = true
+			return true
+		case nalType == H265_NAL_SPS || nalType == H265_NAL_PPS || nalType > H265_NAL_PPS:
+			return false
+		case nalType >= H265_NAL_IRAP_FIRST && nalType <= H265_NAL_IRAP_LAST:
+			started := pusher.h265ParamSetsStarted
+			pusher.h265ParamSetsStarted = false
+			return !started
 		}
+		pusher.h265ParamSetsStarted = false
 		return false
 	}
 	return false
@@ -0,0 +1,147 @@
+package rtsp
+
+import (
+	"log"
+	"time"
+
+	"EasyDarwin/models"
+)
+
+// scheduleWindow tracks the recording window of one t_recording_schedule row.
+type scheduleWindow struct {
+	models.RecordingSchedule
+	cron *CronSchedule
+	next time.Time // next match opening a window, MaxDurationMinutes > 0 only
+	end  time.Time // end of the open window
+}
+
+func newScheduleWindow(schedule models.RecordingSchedule, cron *CronSchedule, now time.Time) *scheduleWindow {
+	window := &scheduleWindow{RecordingSchedule: schedule, cron: cron}
+	if schedule.MaxDurationMinutes > 0 {
+		// a window opened before the scheduler started is still honoured
+		duration := time.Duration(schedule.MaxDurationMinutes) * time.Minute
+		for t := cron.Next(now.Add(-duration)); !t.IsZero() && !t.After(now); t = cron.Next(t) {
+			window.end = t.Add(duration)
+		}
+		window.next = cron.Next(now)
+	}
+	return window
+}
+
+// Open tells whether now is within a window.
+func (window *scheduleWindow) Open(now time.Time) bool {
+	if window.MaxDurationMinutes <= 0 {
+		return window.cron.Match(now)
+	}
+	if !window.next.IsZero() && !now.Before(window.next) {
+		window.end = window.next.Add(time.Duration(window.MaxDurationMinutes) * time.Minute)
+		window.next = window.cron.Next(now)
+	}
+	return now.Before(window.end)
+}
+
+// CronScheduler records the streams of t_recording_schedule with a DVRManager
+// during their windows. A stream that is not live when its window opens is
+// recorded as soon as it comes online, until the window closes. Schedules are
+// reloaded every minute.
+type CronScheduler struct {
+	dvr    *DVRManager
+	server *Server
+	logger *log.Logger
+	done   chan struct{}
+
+	windows map[uint]*scheduleWindow // ID <-> window
+	started map[string]bool          // paths recorded by the scheduler
+}
+
+func NewCronScheduler(server *Server, dvr *DVRManager) *CronScheduler {
+	scheduler := &CronScheduler{
+		dvr:     dvr,
+		server:  server,
+		logger:  server.logger,
+		done:    make(chan struct{}),
+		windows: make(map[uint]*scheduleWindow),
+		started: make(map[string]bool),
+	}
+	go scheduler.run()
+	return scheduler
+}
+
+// Stop stops the scheduler and the recordings it started.
+func (scheduler *CronScheduler) Stop() {
+	close(scheduler.done)
+}
+
+func (scheduler *CronScheduler) run() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	var loadedAt time.Time
+	for {
+		now := time.Now()
+		if minute := now.Truncate(time.Minute); !minute.Equal(loadedAt) {
+			scheduler.load(now)
+			loadedAt = minute
+		}
+		scheduler.tick(now)
+		select {
+		case <-ticker.C:
+		case <-scheduler.done:
+			for streamPath := range scheduler.started {
+				scheduler.dvr.StopRecording(streamPath)
+			}
+			return
+		}
+	}
+}
+
+// load refreshes the schedules from the database. Windows of unchanged
+// schedules are kept.
+func (scheduler *CronScheduler) load(now time.Time) {
+	schedules, err := models.FindRecordingSchedules()
+	if err != nil {
+		scheduler.logger.Printf("Query recording schedules err:%v", err)
+		return
+	}
+	windows := make(map[uint]*scheduleWindow, len(schedules))
+	for _, schedule := range schedules {
+		if window, ok := scheduler.windows[schedule.ID]; ok && window.RecordingSchedule == schedule {
+			windows[schedule.ID] = window
+			continue
+		}
+		cron, err := ParseCron(schedule.CronExpr)
+		if err != nil {
+			scheduler.logger.Printf("recording schedule %d of %s err:%v", schedule.ID, schedule.StreamPath, err)
+			continue
+		}
+		windows[schedule.ID] = newScheduleWindow(schedule, cron, now)
+	}
+	scheduler.windows = windows
+}
+
+// tick starts the recordings of live streams in an open window and stops the
+// ones whose windows closed.
+func (scheduler *CronScheduler) tick(now time.Time) {
+	wanted := make(map[string]bool)
+	for _, window := range scheduler.windows {
+		if window.Open(now) {
+			wanted[window.StreamPath] = true
+		}
+	}
+	for streamPath := range scheduler.started {
+		if !wanted[streamPath] {
+			scheduler.logger.Printf("recording window of %s closed", streamPath)
+			scheduler.dvr.StopRecording(streamPath)
+			delete(scheduler.started, streamPath)
+		}
+	}
+	for streamPath := range wanted {
+		if scheduler.dvr.Recording(streamPath) || scheduler.server.GetPusher(streamPath) == nil {
+			continue
+		}
+		if err := scheduler.dvr.StartRecording(streamPath); err != nil {
+			scheduler.logger.Printf("Start recording %s err:%v", streamPath, err)
+			continue
+		}
+		scheduler.started[streamPath] = true
+	}
+}
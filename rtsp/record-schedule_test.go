@@ -0,0 +1,62 @@
+package rtsp
+
+import (
+	"testing"
+	"time"
+
+	"EasyDarwin/models"
+)
+
+func TestScheduleWindow(t *testing.T) {
+	at := func(s string) time.Time {
+		t, _ := time.ParseInLocation("2006-01-02 15:04", s, time.UTC)
+		return t
+	}
+	cron, err := ParseCron("0 9 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		minutes int
+		start   string
+		open    map[string]bool
+	}{
+		{"without a duration, the matched minutes", 0, "2026-10-16 08:00", map[string]bool{
+			"2026-10-16 08:59": false,
+			"2026-10-16 09:00": true,
+			"2026-10-16 09:01": false,
+		}},
+		{"opens on a match for the duration", 60, "2026-10-16 08:00", map[string]bool{
+			"2026-10-16 08:59": false,
+			"2026-10-16 09:00": true,
+			"2026-10-16 09:59": true,
+			"2026-10-16 10:00": false,
+			"2026-10-17 09:30": true,
+		}},
+		{"opened before the scheduler started", 60, "2026-10-16 09:30", map[string]bool{
+			"2026-10-16 09:30": true,
+			"2026-10-16 09:59": true,
+			"2026-10-16 10:00": false,
+		}},
+		{"closed before the scheduler started", 60, "2026-10-16 10:30", map[string]bool{
+			"2026-10-16 10:30": false,
+			"2026-10-17 09:00": true,
+		}},
+	}
+	for _, test := range tests {
+		schedule := models.RecordingSchedule{StreamPath: "/test/schedule", CronExpr: "0 9 * * *", MaxDurationMinutes: test.minutes}
+		window := newScheduleWindow(schedule, cron, at(test.start))
+		// Open is called with increasing times, as by the scheduler
+		for _, now := range []string{"2026-10-16 08:59", "2026-10-16 09:00", "2026-10-16 09:01", "2026-10-16 09:30",
+			"2026-10-16 09:59", "2026-10-16 10:00", "2026-10-16 10:30", "2026-10-17 09:00", "2026-10-17 09:30"} {
+			want, ok := test.open[now]
+			if at(now).Before(at(test.start)) {
+				continue
+			}
+			if open := window.Open(at(now)); ok && open != want {
+				t.Errorf("%s: open at %s = %v", test.name, now, open)
+			}
+		}
+	}
+}
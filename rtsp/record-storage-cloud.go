@@ -0,0 +1,266 @@
+package rtsp
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3Storage keeps recordings in a bucket of Amazon S3, or of a service
+// speaking its API such as MinIO. Objects are addressed path-style, at
+// <Endpoint>/<Bucket>/<Prefix><path>, and requests are signed with AWS
+// Signature Version 4.
+type S3Storage struct {
+	Endpoint  string
+	Region    string
+	Bucket    string
+	Prefix    string
+	AccessKey string
+	SecretKey string
+	Client    *http.Client
+}
+
+// NewS3Storage returns the storage of bucket. An empty endpoint is the AWS
+// endpoint of region.
+func NewS3Storage(endpoint, region, bucket, prefix, accessKey, secretKey string) *S3Storage {
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", region)
+	}
+	return &S3Storage{
+		Endpoint:  strings.TrimRight(endpoint, "/"),
+		Region:    region,
+		Bucket:    bucket,
+		Prefix:    prefix,
+		AccessKey: accessKey,
+		SecretKey: secretKey,
+		Client:    &http.Client{},
+	}
+}
+
+// GCSStorage keeps recordings in a Google Cloud Storage bucket. It goes
+// through the XML API with an HMAC key, which takes the requests and the
+// signatures of S3.
+type GCSStorage struct {
+	*S3Storage
+}
+
+func NewGCSStorage(bucket, prefix, accessKey, secretKey string) *GCSStorage {
+	return &GCSStorage{NewS3Storage("https://storage.googleapis.com", "auto", bucket, prefix, accessKey, secretKey)}
+}
+
+// Write uploads the content of r, which is read into memory first to sign it.
+func (storage *S3Storage) Write(p string, r io.Reader) error {
+	key, err := storage.key(p)
+	if err != nil {
+		return err
+	}
+	body, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	res, err := storage.do("PUT", key, nil, body)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	return nil
+}
+
+func (storage *S3Storage) Read(p string) (io.ReadCloser, error) {
+	key, err := storage.key(p)
+	if err != nil {
+		return nil, err
+	}
+	res, err := storage.do("GET", key, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return res.Body, nil
+}
+
+func (storage *S3Storage) Delete(p string) error {
+	key, err := storage.key(p)
+	if err != nil {
+		return err
+	}
+	res, err := storage.do("DELETE", key, nil, nil)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	return nil
+}
+
+type s3ListResult struct {
+	IsTruncated bool
+	NextMarker  string
+	Contents    []struct {
+		Key          string
+		Size         int64
+		LastModified time.Time
+	}
+}
+
+// List pages through the objects with version 1 of ListObjects, which GCS
+// supports too. Objects are listed in key order.
+func (storage *S3Storage) List(prefix string) (entries []StorageEntry, err error) {
+	entries = make([]StorageEntry, 0)
+	marker := ""
+	for {
+		query := map[string]string{"prefix": storage.Prefix + prefix}
+		if marker != "" {
+			query["marker"] = marker
+		}
+		res, err := storage.do("GET", "", query, nil)
+		if err != nil {
+			return entries, err
+		}
+		result := s3ListResult{}
+		err = xml.NewDecoder(res.Body).Decode(&result)
+		res.Body.Close()
+		if err != nil {
+			return entries, err
+		}
+		for _, object := range result.Contents {
+			entries = append(entries, StorageEntry{
+				Path:    strings.TrimPrefix(object.Key, storage.Prefix),
+				Size:    object.Size,
+				ModTime: object.LastModified,
+			})
+		}
+		if !result.IsTruncated || len(result.Contents) == 0 {
+			return entries, nil
+		}
+		// NextMarker is only sent with a delimiter
+		if marker = result.NextMarker; marker == "" {
+			marker = result.Contents[len(result.Contents)-1].Key
+		}
+	}
+}
+
+// key returns the object key of a logical path.
+func (storage *S3Storage) key(p string) (string, error) {
+	clean, err := cleanStoragePath(p)
+	if err != nil {
+		return "", err
+	}
+	return storage.Prefix + clean, nil
+}
+
+type s3Error struct {
+	Code    string
+	Message string
+}
+
+// do sends a signed request for key, or for the bucket when key is empty.
+// A response other than 2xx is returned as an error, 404 as one satisfying
+// os.IsNotExist.
+func (storage *S3Storage) do(method, key string, query map[string]string, body []byte) (*http.Response, error) {
+	u := storage.Endpoint + "/" + s3Escape(storage.Bucket, true)
+	if key != "" {
+		u += "/" + s3Escape(key, false)
+	}
+	req, err := http.NewRequest(method, u, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.URL.RawQuery = s3CanonicalQuery(query)
+	storage.sign(req, body, time.Now().UTC())
+	res, err := storage.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode/100 == 2 {
+		return res, nil
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound && key != "" {
+		return nil, &os.PathError{Op: method, Path: key, Err: os.ErrNotExist}
+	}
+	e := s3Error{}
+	xml.NewDecoder(res.Body).Decode(&e)
+	return nil, fmt.Errorf("%s %s: %s %s %s", method, u, res.Status, e.Code, e.Message)
+}
+
+// sign adds the headers of AWS Signature Version 4 to req, signing the
+// host, the date and the hash of body.
+func (storage *S3Storage) sign(req *http.Request, body []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := amzDate[:8]
+	payloadHash := sha256Hex(body)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		"host:" + req.URL.Host + "\n" +
+			"x-amz-content-sha256:" + payloadHash + "\n" +
+			"x-amz-date:" + amzDate + "\n",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + storage.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	signingKey := hmacSHA256([]byte("AWS4"+storage.SecretKey), date)
+	signingKey = hmacSHA256(signingKey, storage.Region)
+	signingKey = hmacSHA256(signingKey, "s3")
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		storage.AccessKey, scope, signedHeaders, signature))
+}
+
+func s3CanonicalQuery(query map[string]string) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, s3Escape(k, true)+"="+s3Escape(query[k], true))
+	}
+	return strings.Join(pairs, "&")
+}
+
+// s3Escape percent-encodes s as SigV4 expects, every byte but the unreserved
+// characters of RFC 3986, and "/" unless encodeSlash.
+func s3Escape(s string, encodeSlash bool) string {
+	var buf bytes.Buffer
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' || c == '/' && !encodeSlash {
+			buf.WriteByte(c)
+		} else {
+			fmt.Fprintf(&buf, "%%%02X", c)
+		}
+	}
+	return buf.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,159 @@
+package rtsp
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"EasyDarwin/helper/penggy/EasyGoLib/utils"
+)
+
+// StorageEntry is a recording file listed by a StorageBackend.
+type StorageEntry struct {
+	// logical path, relative to the root of the backend and "/" separated
+	Path    string
+	Size    int64
+	ModTime time.Time
+}
+
+// StorageBackend stores recordings under logical paths such as
+// "live/cam1/20060102/out.m3u8", so callers do not depend on where the
+// files are kept.
+type StorageBackend interface {
+	Write(path string, r io.Reader) error
+	Read(path string) (io.ReadCloser, error)
+	Delete(path string) error
+	// List returns the files whose path starts with prefix, sorted by path.
+	List(prefix string) ([]StorageEntry, error)
+}
+
+// LocalStorage keeps recordings in a directory of the local disk.
+type LocalStorage struct {
+	Root string
+}
+
+func NewLocalStorage(root string) *LocalStorage {
+	return &LocalStorage{Root: root}
+}
+
+// NewRecordStorage returns the storage of the recordings configured in
+// [record_storage], m3u8_dir_path on the local disk by default.
+func NewRecordStorage() StorageBackend {
+	sec := utils.Conf().Section("record_storage")
+	bucket := sec.Key("bucket").MustString("")
+	prefix := sec.Key("prefix").MustString("")
+	accessKey := sec.Key("access_key").MustString("")
+	secretKey := sec.Key("secret_key").MustString("")
+	switch sec.Key("type").MustString("local") {
+	case "s3":
+		return NewS3Storage(sec.Key("endpoint").MustString(""), sec.Key("region").MustString("us-east-1"), bucket, prefix, accessKey, secretKey)
+	case "gcs":
+		return NewGCSStorage(bucket, prefix, accessKey, secretKey)
+	}
+	return NewLocalStorage(utils.Conf().Section("rtsp").Key("m3u8_dir_path").MustString(""))
+}
+
+// cleanStoragePath returns p without its leading "/" and with the ".."
+// elements leaving the root removed.
+func cleanStoragePath(p string) (string, error) {
+	clean := path.Clean("/" + p)
+	if clean == "/" {
+		return "", fmt.Errorf("invalid storage path %q", p)
+	}
+	return clean[1:], nil
+}
+
+// Resolve returns the file of a logical path. Paths leaving Root are
+// rejected.
+func (storage *LocalStorage) Resolve(p string) (string, error) {
+	clean, err := cleanStoragePath(p)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(storage.Root, filepath.FromSlash(clean)), nil
+}
+
+// Write replaces the file at p with the content of r. The content is
+// written to a temporary file first, readers never see a partial file.
+func (storage *LocalStorage) Write(p string, r io.Reader) (err error) {
+	file, err := storage.Resolve(p)
+	if err != nil {
+		return
+	}
+	if err = utils.EnsureDir(filepath.Dir(file)); err != nil {
+		return
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(file), "."+filepath.Base(file))
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			os.Remove(tmp.Name())
+		}
+	}()
+	if _, err = io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return
+	}
+	if err = tmp.Close(); err != nil {
+		return
+	}
+	return os.Rename(tmp.Name(), file)
+}
+
+func (storage *LocalStorage) Read(p string) (io.ReadCloser, error) {
+	file, err := storage.Resolve(p)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(file)
+}
+
+func (storage *LocalStorage) Delete(p string) error {
+	file, err := storage.Resolve(p)
+	if err != nil {
+		return err
+	}
+	return os.Remove(file)
+}
+
+func (storage *LocalStorage) List(prefix string) (entries []StorageEntry, err error) {
+	entries = make([]StorageEntry, 0)
+	if storage.Root == "" {
+		return
+	}
+	// walk only the directory holding prefix
+	dir := storage.Root
+	if i := strings.LastIndex(prefix, "/"); i >= 0 {
+		if dir, err = storage.Resolve(prefix[:i]); err != nil {
+			return
+		}
+	}
+	err = filepath.Walk(dir, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && file == dir {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(storage.Root, file)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if strings.HasPrefix(rel, prefix) {
+			entries = append(entries, StorageEntry{Path: rel, Size: info.Size(), ModTime: info.ModTime()})
+		}
+		return nil
+	})
+	return
+}
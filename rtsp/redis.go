@@ -0,0 +1,62 @@
+package rtsp
+
+import (
+	"strings"
+	"time"
+
+	"EasyDarwin/helper/go-redis/redis"
+	"EasyDarwin/helper/penggy/EasyGoLib/utils"
+)
+
+// redisSlowLog gathers the slow commands of the rings of NewRedisRing.
+var redisSlowLog = redis.NewSlowCommandLog(0, 128)
+
+// RedisSlowLog returns the last commands of the rings over the [redis] shards
+// slower than slow_threshold_ms.
+func RedisSlowLog() *redis.SlowCommandLog {
+	return redisSlowLog
+}
+
+// RedisConfigured reports whether the [redis] section has shards, that is
+// whether Server.Start builds a ring.
+func RedisConfigured() bool {
+	return len(redisAddrs()) > 0
+}
+
+// NewRedisRing returns a ring client over the shards of the [redis] section,
+// shared by the EasyDarwin nodes. It returns nil when no shard is configured.
+// The server builds its ring with it at start, see Server.Redis.
+func NewRedisRing() *redis.Ring {
+	sec := utils.Conf().Section("redis")
+	addrs := redisAddrs()
+	if len(addrs) == 0 {
+		return nil
+	}
+	ring := redis.NewRing(&redis.RingOptions{
+		Addrs:    addrs,
+		Password: sec.Key("password").MustString(""),
+		DB:       sec.Key("db").MustInt(0),
+	})
+	redisSlowLog.SetSlowThreshold(time.Duration(sec.Key("slow_threshold_ms").MustInt(10)) * time.Millisecond)
+	redisSlowLog.WrapRing(ring)
+	return ring
+}
+
+// redisAddrs returns the addresses of the [redis] shards by name.
+func redisAddrs() map[string]string {
+	sec := utils.Conf().Section("redis")
+	addrs := make(map[string]string)
+	for _, shard := range strings.Split(sec.Key("addrs").MustString(""), ",") {
+		shard = strings.TrimSpace(shard)
+		if shard == "" {
+			continue
+		}
+		// name=host:port, or host:port named after itself
+		name, addr := shard, shard
+		if i := strings.Index(shard, "="); i >= 0 {
+			name, addr = shard[:i], shard[i+1:]
+		}
+		addrs[name] = addr
+	}
+	return addrs
+}
@@ -0,0 +1,186 @@
+package rtsp
+
+import (
+	"sync"
+	"time"
+)
+
+var (
+	reorderFlushOutOfOrderTotal = NewCounter("rtsp_reorder_buffer_flush_out_of_order_total", "RTP packets flushed from the reorder buffer with a sequence gap before them.")
+	reorderLateDropTotal        = NewCounter("rtsp_reorder_buffer_late_drop_total", "RTP packets dropped because they arrived after their sequence number was already flushed.")
+	reorderResyncTotal          = NewCounter("rtsp_reorder_buffer_resync_total", "Times the reorder buffer followed a backward jump of the RTP sequence numbers.")
+)
+
+type reorderEntry struct {
+	pack    *RTPPack
+	seq     uint16
+	arrival time.Time
+}
+
+// ReorderBuffer holds up to Size RTP packets of one media stream, sorted by sequence number,
+// and hands them to Emit in order. A packet waits at most Window for the gap before it to fill.
+// A packet more than Size behind the expected sequence number, or Size late packets in a row,
+// mean the source jumped back, e.g. after restarting, and the buffer restarts from that packet.
+type ReorderBuffer struct {
+	Size   int
+	Window time.Duration
+	Emit   func(*RTPPack)
+
+	lock    sync.Mutex
+	entries []*reorderEntry
+	nextSeq uint16
+	lastSeq uint16 // of the last packet emitted, kept across a resync
+	lates   int    // late packets in a row
+	started bool
+	timer   *time.Timer
+	stoped  bool
+}
+
+func NewReorderBuffer(size int, window time.Duration, emit func(*RTPPack)) *ReorderBuffer {
+	return &ReorderBuffer{
+		Size:    size,
+		Window:  window,
+		Emit:    emit,
+		entries: make([]*reorderEntry, 0, size),
+	}
+}
+
+func (rb *ReorderBuffer) Push(pack *RTPPack) {
+	rtp := ParseRTP(pack.Buffer.Bytes())
+	rb.lock.Lock()
+	defer rb.lock.Unlock()
+	if rb.stoped {
+		return
+	}
+	if rtp == nil {
+		rb.Emit(pack)
+		return
+	}
+	seq := uint16(rtp.SequenceNumber)
+	if !rb.started {
+		rb.started = true
+		rb.nextSeq = seq
+	}
+	diff := int16(seq - rb.nextSeq)
+	if diff < 0 {
+		rb.lates++
+		if int(-diff) <= rb.Size && rb.lates < rb.Size {
+			reorderLateDropTotal.Inc()
+			return
+		}
+		rb.resync(seq)
+		rb.lates = 0
+		// the run of late packets ended on the last one emitted, e.g. duplicates
+		if seq == rb.lastSeq {
+			rb.nextSeq = seq + 1
+			reorderLateDropTotal.Inc()
+			return
+		}
+		diff = 0
+	}
+	rb.lates = 0
+	if diff == 0 {
+		rb.emit(pack, seq)
+		rb.nextSeq = seq + 1
+		rb.drain()
+		rb.schedule()
+		return
+	}
+	rb.insert(&reorderEntry{pack: pack, seq: seq, arrival: time.Now()})
+	for len(rb.entries) > rb.Size {
+		rb.flushHead()
+	}
+	rb.schedule()
+}
+
+func (rb *ReorderBuffer) Stop() {
+	rb.lock.Lock()
+	rb.stoped = true
+	rb.entries = nil
+	if rb.timer != nil {
+		rb.timer.Stop()
+		rb.timer = nil
+	}
+	rb.lock.Unlock()
+}
+
+func (rb *ReorderBuffer) insert(entry *reorderEntry) {
+	i := len(rb.entries)
+	for i > 0 {
+		d := int16(entry.seq - rb.entries[i-1].seq)
+		if d == 0 {
+			return // duplicate
+		}
+		if d > 0 {
+			break
+		}
+		i--
+	}
+	rb.entries = append(rb.entries, nil)
+	copy(rb.entries[i+1:], rb.entries[i:])
+	rb.entries[i] = entry
+}
+
+// resync emits the buffered packets, which belong to the sequence before the
+// jump, and expects seq next.
+func (rb *ReorderBuffer) resync(seq uint16) {
+	for _, entry := range rb.entries {
+		rb.emit(entry.pack, entry.seq)
+	}
+	rb.entries = rb.entries[:0]
+	rb.nextSeq = seq
+	reorderResyncTotal.Inc()
+}
+
+// emit hands pack to Emit and remembers its sequence number.
+func (rb *ReorderBuffer) emit(pack *RTPPack, seq uint16) {
+	rb.Emit(pack)
+	rb.lastSeq = seq
+}
+
+// drain emits the packets at the head of the buffer as long as they are contiguous.
+func (rb *ReorderBuffer) drain() {
+	for len(rb.entries) > 0 && rb.entries[0].seq == rb.nextSeq {
+		rb.emit(rb.entries[0].pack, rb.nextSeq)
+		rb.nextSeq++
+		rb.entries = rb.entries[1:]
+	}
+}
+
+// flushHead gives up on the gap before the first buffered packet.
+func (rb *ReorderBuffer) flushHead() {
+	head := rb.entries[0]
+	rb.entries = rb.entries[1:]
+	rb.emit(head.pack, head.seq)
+	rb.nextSeq = head.seq + 1
+	reorderFlushOutOfOrderTotal.Inc()
+	rb.drain()
+}
+
+func (rb *ReorderBuffer) schedule() {
+	if len(rb.entries) == 0 {
+		if rb.timer != nil {
+			rb.timer.Stop()
+		}
+		return
+	}
+	wait := rb.entries[0].arrival.Add(rb.Window).Sub(time.Now())
+	if rb.timer == nil {
+		rb.timer = time.AfterFunc(wait, rb.expire)
+	} else {
+		rb.timer.Reset(wait)
+	}
+}
+
+func (rb *ReorderBuffer) expire() {
+	rb.lock.Lock()
+	defer rb.lock.Unlock()
+	if rb.stoped {
+		return
+	}
+	now := time.Now()
+	for len(rb.entries) > 0 && now.Sub(rb.entries[0].arrival) >= rb.Window {
+		rb.flushHead()
+	}
+	rb.schedule()
+}
@@ -0,0 +1,51 @@
+package rtsp_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+	"time"
+
+	"EasyDarwin/rtsp"
+)
+
+func newRTPPack(seq uint16) *rtsp.RTPPack {
+	b := make([]byte, 13)
+	b[0] = 0x80
+	b[1] = 96
+	binary.BigEndian.PutUint16(b[2:], seq)
+	return &rtsp.RTPPack{Type: rtsp.RTP_TYPE_VIDEO, Buffer: bytes.NewBuffer(b)}
+}
+
+func pushSeqs(rb *rtsp.ReorderBuffer, seqs ...uint16) {
+	for _, seq := range seqs {
+		rb.Push(newRTPPack(seq))
+	}
+}
+
+func TestReorderBuffer(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []uint16
+		out  []int
+	}{
+		{"in order", []uint16{1, 2, 3}, []int{1, 2, 3}},
+		{"reordered", []uint16{1, 3, 2, 4}, []int{1, 2, 3, 4}},
+		{"wraps", []uint16{65534, 0, 65535, 1}, []int{65534, 65535, 0, 1}},
+		{"late dropped", []uint16{10, 11, 12, 9, 13}, []int{10, 11, 12, 13}},
+		{"jump back past the size", []uint16{100, 101, 102, 10, 11}, []int{100, 101, 102, 10, 11}},
+		{"late packets in a row", []uint16{10, 11, 12, 9, 10, 11, 12, 13}, []int{10, 11, 12, 13}},
+	}
+	for _, test := range tests {
+		var out []int
+		rb := rtsp.NewReorderBuffer(4, time.Hour, func(pack *rtsp.RTPPack) {
+			out = append(out, rtsp.ParseRTP(pack.Buffer.Bytes()).SequenceNumber)
+		})
+		pushSeqs(rb, test.in...)
+		rb.Stop()
+		if !reflect.DeepEqual(out, test.out) {
+			t.Errorf("%s: got %v, want %v", test.name, out, test.out)
+		}
+	}
+}
@@ -8,6 +8,8 @@ import (
 type RichConn struct {
 	net.Conn
 	timeout time.Duration
+	// deadline of writes if > 0, otherwise timeout applies
+	writeTimeout time.Duration
 }
 
 func (conn *RichConn) Read(b []byte) (n int, err error) {
@@ -21,7 +23,9 @@ func (conn *RichConn) Read(b []byte) (n int, err error) {
 }
 
 func (conn *RichConn) Write(b []byte) (n int, err error) {
-	if conn.timeout > 0 {
+	if conn.writeTimeout > 0 {
+		conn.Conn.SetWriteDeadline(time.Now().Add(conn.writeTimeout))
+	} else if conn.timeout > 0 {
 		conn.Conn.SetWriteDeadline(time.Now().Add(conn.timeout))
 	} else {
 		var t time.Time
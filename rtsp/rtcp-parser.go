@@ -0,0 +1,218 @@
+package rtsp
+
+import (
+	"encoding/binary"
+)
+
+const (
+	RTCP_PT_SR   = 200
+	RTCP_PT_RR   = 201
+	RTCP_PT_BYE  = 203
+	RTCP_PT_APP  = 204
+	RTCP_PT_PSFB = 206 // payload-specific feedback, RFC 4585
+
+	RTCP_PSFB_FMT_PLI  = 1
+	RTCP_PSFB_FMT_FIR  = 4  // RFC 5104
+	RTCP_PSFB_FMT_REMB = 15 // application layer feedback, draft-alvestrand-rmcat-remb
+)
+
+type RTCPFeedback struct {
+	FMT        int
+	SenderSSRC uint32
+	MediaSSRC  uint32
+	// FIRSSRC is the SSRC of the first FCI entry of a FIR, which names the stream
+	// the receiver wants a key frame for.
+	FIRSSRC uint32
+}
+
+// IsRTCPPacket tells RTCP from RTP on a port carrying both, RFC 5761 section 4.
+// The second octet of RTCP is a packet type in SR..APP (200-204), which falls in
+// the reserved range of RTP marker bit plus payload type.
+func IsRTCPPacket(pack []byte) bool {
+	return len(pack) >= 2 && pack[1] >= RTCP_PT_SR && pack[1] <= RTCP_PT_APP
+}
+
+// ParseRTCPFeedback walks a (compound) RTCP packet and returns the first
+// payload-specific feedback message with the given FMT, or nil.
+func ParseRTCPFeedback(rtcpBytes []byte, format int) *RTCPFeedback {
+	for len(rtcpBytes) >= 4 {
+		if rtcpBytes[0]>>6 != 2 {
+			return nil
+		}
+		pktLen := 4 * (int(binary.BigEndian.Uint16(rtcpBytes[2:])) + 1)
+		if pktLen > len(rtcpBytes) {
+			return nil
+		}
+		pkt := rtcpBytes[:pktLen]
+		rtcpBytes = rtcpBytes[pktLen:]
+		if int(pkt[1]) != RTCP_PT_PSFB || int(pkt[0]&0x1f) != format || pktLen < 12 {
+			continue
+		}
+		fb := &RTCPFeedback{
+			FMT:        format,
+			SenderSSRC: binary.BigEndian.Uint32(pkt[4:]),
+			MediaSSRC:  binary.BigEndian.Uint32(pkt[8:]),
+		}
+		if format == RTCP_PSFB_FMT_FIR && pktLen >= 20 {
+			fb.FIRSSRC = binary.BigEndian.Uint32(pkt[12:])
+		}
+		return fb
+	}
+	return nil
+}
+
+// RTCPSenderReport is the sender info of an SR, which maps the RTP timestamps
+// of SSRC to the wallclock of the sender.
+type RTCPSenderReport struct {
+	SSRC uint32
+	// NTP timestamp, seconds since 1900 in 32.32 fixed point
+	NTPTime     uint64
+	RTPTime     uint32
+	PacketCount uint32
+	OctetCount  uint32
+}
+
+// ParseRTCPSenderReport walks a (compound) RTCP packet and returns the sender
+// info of the first SR, or nil.
+func ParseRTCPSenderReport(rtcpBytes []byte) *RTCPSenderReport {
+	for len(rtcpBytes) >= 4 {
+		if rtcpBytes[0]>>6 != 2 {
+			return nil
+		}
+		pktLen := 4 * (int(binary.BigEndian.Uint16(rtcpBytes[2:])) + 1)
+		if pktLen > len(rtcpBytes) {
+			return nil
+		}
+		pkt := rtcpBytes[:pktLen]
+		rtcpBytes = rtcpBytes[pktLen:]
+		if int(pkt[1]) != RTCP_PT_SR || pktLen < 28 {
+			continue
+		}
+		return &RTCPSenderReport{
+			SSRC:        binary.BigEndian.Uint32(pkt[4:]),
+			NTPTime:     binary.BigEndian.Uint64(pkt[8:]),
+			RTPTime:     binary.BigEndian.Uint32(pkt[16:]),
+			PacketCount: binary.BigEndian.Uint32(pkt[20:]),
+			OctetCount:  binary.BigEndian.Uint32(pkt[24:]),
+		}
+	}
+	return nil
+}
+
+type RTCPReportBlock struct {
+	SSRC uint32
+	// fraction of the packets lost since the previous report, in 1/256
+	FractionLost   uint8
+	CumulativeLost int
+}
+
+// ParseRTCPReportBlocks returns the report blocks of all SR and RR packets
+// in a (compound) RTCP packet.
+func ParseRTCPReportBlocks(rtcpBytes []byte) (blocks []RTCPReportBlock) {
+	for len(rtcpBytes) >= 4 {
+		if rtcpBytes[0]>>6 != 2 {
+			return
+		}
+		pktLen := 4 * (int(binary.BigEndian.Uint16(rtcpBytes[2:])) + 1)
+		if pktLen > len(rtcpBytes) {
+			return
+		}
+		pkt := rtcpBytes[:pktLen]
+		rtcpBytes = rtcpBytes[pktLen:]
+		offset := 0
+		switch int(pkt[1]) {
+		case RTCP_PT_SR:
+			offset = 28 // header, sender SSRC and sender info
+		case RTCP_PT_RR:
+			offset = 8
+		default:
+			continue
+		}
+		count := int(pkt[0] & 0x1f)
+		for i := 0; i < count && offset+24 <= pktLen; i++ {
+			block := pkt[offset : offset+24]
+			// cumulative number of packets lost is a 24 bit signed integer
+			lost := int(int32(binary.BigEndian.Uint32(block[4:])<<8) >> 8)
+			blocks = append(blocks, RTCPReportBlock{
+				SSRC:           binary.BigEndian.Uint32(block),
+				FractionLost:   block[4],
+				CumulativeLost: lost,
+			})
+			offset += 24
+		}
+	}
+	return
+}
+
+// NewRTCPPLI builds a Picture Loss Indication asking mediaSSRC for a key frame.
+func NewRTCPPLI(senderSSRC, mediaSSRC uint32) []byte {
+	pkt := make([]byte, 12)
+	pkt[0] = 2<<6 | RTCP_PSFB_FMT_PLI
+	pkt[1] = RTCP_PT_PSFB
+	binary.BigEndian.PutUint16(pkt[2:], 2)
+	binary.BigEndian.PutUint32(pkt[4:], senderSSRC)
+	binary.BigEndian.PutUint32(pkt[8:], mediaSSRC)
+	return pkt
+}
+
+// NewRTCPFIR builds a Full Intra Request with a single FCI entry for mediaSSRC.
+func NewRTCPFIR(senderSSRC, mediaSSRC uint32, seq uint8) []byte {
+	pkt := make([]byte, 20)
+	pkt[0] = 2<<6 | RTCP_PSFB_FMT_FIR
+	pkt[1] = RTCP_PT_PSFB
+	binary.BigEndian.PutUint16(pkt[2:], 4)
+	binary.BigEndian.PutUint32(pkt[4:], senderSSRC)
+	// media source SSRC is unused for FIR, the target is in the FCI
+	binary.BigEndian.PutUint32(pkt[12:], mediaSSRC)
+	pkt[16] = seq
+	return pkt
+}
+
+// NewRTCPREMB builds a Receiver Estimated Maximum Bitrate message telling the
+// sender of ssrcs to keep their total bitrate under bitrate bits per second.
+func NewRTCPREMB(senderSSRC uint32, bitrate uint64, ssrcs ...uint32) []byte {
+	pkt := make([]byte, 20+4*len(ssrcs))
+	pkt[0] = 2<<6 | RTCP_PSFB_FMT_REMB
+	pkt[1] = RTCP_PT_PSFB
+	binary.BigEndian.PutUint16(pkt[2:], uint16(4+len(ssrcs)))
+	binary.BigEndian.PutUint32(pkt[4:], senderSSRC)
+	// media source SSRC is unused for REMB, the targets follow the bitrate
+	copy(pkt[12:], "REMB")
+	// bitrate is mantissa (18 bits) * 2^exp (6 bits)
+	exp := uint(0)
+	for bitrate > 0x3ffff {
+		bitrate >>= 1
+		exp++
+	}
+	binary.BigEndian.PutUint32(pkt[16:], uint32(len(ssrcs))<<24|uint32(exp)<<18|uint32(bitrate))
+	for i, ssrc := range ssrcs {
+		binary.BigEndian.PutUint32(pkt[20+4*i:], ssrc)
+	}
+	return pkt
+}
+
+// NewRTCPSR builds a Sender Report of sr without report blocks.
+func NewRTCPSR(sr RTCPSenderReport) []byte {
+	pkt := make([]byte, 28)
+	pkt[0] = 2 << 6
+	pkt[1] = RTCP_PT_SR
+	binary.BigEndian.PutUint16(pkt[2:], 6)
+	binary.BigEndian.PutUint32(pkt[4:], sr.SSRC)
+	binary.BigEndian.PutUint64(pkt[8:], sr.NTPTime)
+	binary.BigEndian.PutUint32(pkt[16:], sr.RTPTime)
+	binary.BigEndian.PutUint32(pkt[20:], sr.PacketCount)
+	binary.BigEndian.PutUint32(pkt[24:], sr.OctetCount)
+	return pkt
+}
+
+// NewRTCPBye builds a BYE telling the receiver that ssrcs are leaving.
+func NewRTCPBye(ssrcs ...uint32) []byte {
+	pkt := make([]byte, 4+4*len(ssrcs))
+	pkt[0] = 2<<6 | byte(len(ssrcs))
+	pkt[1] = RTCP_PT_BYE
+	binary.BigEndian.PutUint16(pkt[2:], uint16(len(ssrcs)))
+	for i, ssrc := range ssrcs {
+		binary.BigEndian.PutUint32(pkt[4+4*i:], ssrc)
+	}
+	return pkt
+}
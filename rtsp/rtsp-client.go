@@ -3,6 +3,7 @@ package rtsp
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"crypto/md5"
 	"encoding/binary"
 	"fmt"
@@ -14,13 +15,14 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"EasyDarwin/helper/teris-io/shortid"
 
 	"EasyDarwin/helper/penggy/EasyGoLib/utils"
 
-	"EasyDarwin/helper/pixelbender/go-sdp/sdp"
+	"EasyDarwin/rtsp/sdp"
 )
 
 type RTSPClient struct {
@@ -32,15 +34,17 @@ type RTSPClient struct {
 	Path                 string
 	CustomPath           string //custom path for pusher
 	ID                   string
+	RequestID            string // of the HTTP API request that started the client
 	Conn                 *RichConn
 	Session              string
 	Seq                  int
 	connRW               *bufio.ReadWriter
+	connWLock            sync.Mutex
 	InBytes              int
 	OutBytes             int
 	TransType            TransType
 	StartAt              time.Time
-	Sdp                  *sdp.Session
+	Sdp                  *sdp.SessionDescription
 	AControl             string
 	VControl             string
 	ACodec               string
@@ -70,6 +74,11 @@ func (client *RTSPClient) String() string {
 }
 
 func NewRTSPClient(server *Server, rawUrl string, sendOptionMillis int64, agent string) (client *RTSPClient, err error) {
+	return NewRTSPClientContext(context.Background(), server, rawUrl, sendOptionMillis, agent)
+}
+
+// NewRTSPClientContext is like NewRTSPClient. The client logs the request ID of ctx, see WithRequestID.
+func NewRTSPClientContext(ctx context.Context, server *Server, rawUrl string, sendOptionMillis int64, agent string) (client *RTSPClient, err error) {
 	url, err := url.Parse(rawUrl)
 	if err != nil {
 		return
@@ -91,7 +100,12 @@ func NewRTSPClient(server *Server, rawUrl string, sendOptionMillis int64, agent
 		Agent:                agent,
 		debugLogEnable:       debugLogEnable != 0,
 	}
-	client.logger = log.New(os.Stdout, fmt.Sprintf("[%s]", client.ID), log.LstdFlags|log.Lshortfile)
+	client.RequestID = RequestIDFromContext(ctx)
+	prefix := fmt.Sprintf("[%s]", client.ID)
+	if client.RequestID != "" {
+		prefix = fmt.Sprintf("[%s][%s]", client.ID, client.RequestID)
+	}
+	client.logger = log.New(os.Stdout, prefix, log.LstdFlags|log.Lshortfile)
 	if !utils.Debug {
 		client.logger.SetOutput(utils.GetLogWriter())
 	}
@@ -206,8 +220,8 @@ func (client *RTSPClient) requestStream(timeout time.Duration) (err error) {
 	networkBuffer := utils.Conf().Section("rtsp").Key("network_buffer").MustInt(204800)
 
 	timeoutConn := RichConn{
-		conn,
-		timeout,
+		Conn:    conn,
+		timeout: timeout,
 	}
 	client.Conn = &timeoutConn
 	client.connRW = bufio.NewReadWriter(bufio.NewReaderSize(&timeoutConn, networkBuffer), bufio.NewWriterSize(&timeoutConn, networkBuffer))
@@ -256,7 +270,7 @@ func (client *RTSPClient) requestStream(timeout time.Duration) (err error) {
 			return err
 		}
 	}
-	_sdp, err := sdp.ParseString(resp.Body)
+	_sdp, err := sdp.Parse(resp.Body)
 	if err != nil {
 		return err
 	}
@@ -546,11 +560,14 @@ func (client *RTSPClient) RequestWithPath(method string, path string, headers ma
 	builder.WriteString(fmt.Sprintf("\r\n"))
 	s := builder.String()
 	logger.Printf("[OUT]>>>\n%s", s)
+	client.connWLock.Lock()
 	_, err = client.connRW.WriteString(s)
 	if err != nil {
+		client.connWLock.Unlock()
 		return
 	}
 	client.connRW.Flush()
+	client.connWLock.Unlock()
 
 	if !needResp {
 		return nil, nil
@@ -672,3 +689,33 @@ func (client *RTSPClient) RequestNoResp(method string, headers map[string]string
 	}
 	return nil
 }
+
+// SendRTCP writes an RTCP packet to the source on the video control channel.
+func (client *RTSPClient) SendRTCP(rtcpBytes []byte) (err error) {
+	if client.TransType == TRANS_TYPE_UDP {
+		if client.UDPServer == nil {
+			err = fmt.Errorf("client use udp transport but udp server not found")
+			return
+		}
+		return client.UDPServer.SendVideoControl(rtcpBytes)
+	}
+	if client.connRW == nil {
+		err = fmt.Errorf("client conn closed")
+		return
+	}
+	header := make([]byte, 4)
+	header[0] = 0x24
+	header[1] = byte(client.vRTPControlChannel)
+	binary.BigEndian.PutUint16(header[2:], uint16(len(rtcpBytes)))
+	client.connWLock.Lock()
+	defer client.connWLock.Unlock()
+	if _, err = client.connRW.Write(header); err != nil {
+		return
+	}
+	if _, err = client.connRW.Write(rtcpBytes); err != nil {
+		return
+	}
+	err = client.connRW.Flush()
+	client.OutBytes += len(rtcpBytes) + 4
+	return
+}
@@ -38,6 +38,12 @@ const (
 	DATA     = "DATA"
 )
 
+// PUBLIC_METHODS is the Public header of an OPTIONS response, SUPPORTED_FEATURES its Supported header.
+var (
+	PUBLIC_METHODS     = []string{OPTIONS, DESCRIBE, ANNOUNCE, SETUP, PLAY, PAUSE, RECORD, TEARDOWN, GET_PARAMETER, SET_PARAMETER}
+	SUPPORTED_FEATURES = []string{"play.basic", "con.persistent"}
+)
+
 type Request struct {
 	Method  string
 	URL     string
@@ -1,6 +1,7 @@
 package rtsp
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net"
@@ -13,6 +14,7 @@ import (
 	"syscall"
 	"time"
 
+	"EasyDarwin/helper/go-redis/redis"
 	"EasyDarwin/helper/penggy/EasyGoLib/utils"
 )
 
@@ -25,6 +27,34 @@ type Server struct {
 	pushersLock    sync.RWMutex
 	addPusherCh    chan *Pusher
 	removePusherCh chan *Pusher
+	Aliases        *AliasManager
+	Events         *Broadcaster
+	MuxRTPRTCP     bool
+	// shared with the other nodes, nil without a [redis] section
+	ring     *redis.Ring
+	ringLock sync.RWMutex
+	// replaced by ApplyConf while sessions read them, see the getters
+	accessLog       *RTSPAccessLog
+	nameNormalizer  *StreamNameNormalizer
+	webhook         *Webhook
+	announceLimiter *AnnounceLimiter
+	ipPolicy        *IPPolicy
+	// made by Start and dropped by Stop while sessions and handlers read
+	// them, see the getters
+	statAggregator *StatAggregator
+	dvr            *DVRManager
+	scheduler      *CronScheduler
+	channels       *ChannelManager
+	watchdog       *PublisherWatchdog
+	loadBalancer   *LoadBalancer
+	abr            *ABRManager
+	// publish the streams of [merge]
+	mergers  []*StreamMerger
+	confLock sync.RWMutex
+	// advertised over mDNS
+	Version  string
+	mdns     *MDNSAdvertiser
+	mdnsLock sync.Mutex
 }
 
 var Instance *Server = &Server{
@@ -34,12 +64,122 @@ var Instance *Server = &Server{
 	pushers:        make(map[string]*Pusher),
 	addPusherCh:    make(chan *Pusher),
 	removePusherCh: make(chan *Pusher),
+	Aliases:        NewAliasManager(),
+	Events:         NewBroadcaster(utils.Conf().Section("http").Key("sse_buffer_size").MustInt(64)),
+	MuxRTPRTCP:     utils.Conf().Section("rtsp").Key("rtp_rtcp_mux").MustBool(false),
 }
 
 func GetServer() *Server {
 	return Instance
 }
 
+// AccessLog returns the RTSP access log, nil without access_log. The one of a
+// stopped server is closed and drops the lines.
+func (server *Server) AccessLog() *RTSPAccessLog {
+	server.confLock.RLock()
+	defer server.confLock.RUnlock()
+	return server.accessLog
+}
+
+// NameNormalizer returns the rules of the stream names, nil when names are
+// used as is.
+func (server *Server) NameNormalizer() *StreamNameNormalizer {
+	server.confLock.RLock()
+	defer server.confLock.RUnlock()
+	return server.nameNormalizer
+}
+
+// Webhook returns the webhook of the stream events, nil without a url.
+func (server *Server) Webhook() *Webhook {
+	server.confLock.RLock()
+	defer server.confLock.RUnlock()
+	return server.webhook
+}
+
+// AnnounceLimiter returns the ANNOUNCE rate limiter, nil without a limit.
+func (server *Server) AnnounceLimiter() *AnnounceLimiter {
+	server.confLock.RLock()
+	defer server.confLock.RUnlock()
+	return server.announceLimiter
+}
+
+// IPPolicy returns the policy admitting and routing the players by country,
+// nil without [geoip].
+func (server *Server) IPPolicy() *IPPolicy {
+	server.confLock.RLock()
+	defer server.confLock.RUnlock()
+	return server.ipPolicy
+}
+
+// StatAggregator returns the aggregator of the session stats, nil when
+// disabled or stopped.
+func (server *Server) StatAggregator() *StatAggregator {
+	server.confLock.RLock()
+	defer server.confLock.RUnlock()
+	return server.statAggregator
+}
+
+// DVR returns the manager of the recordings, nil without ffmpeg_path and
+// m3u8_dir_path.
+func (server *Server) DVR() *DVRManager {
+	server.confLock.RLock()
+	defer server.confLock.RUnlock()
+	return server.dvr
+}
+
+// Scheduler returns the scheduler of the recordings, nil without a DVR.
+func (server *Server) Scheduler() *CronScheduler {
+	server.confLock.RLock()
+	defer server.confLock.RUnlock()
+	return server.scheduler
+}
+
+// Channels returns the manager of the linear channels, nil without a DVR on
+// local storage.
+func (server *Server) Channels() *ChannelManager {
+	server.confLock.RLock()
+	defer server.confLock.RUnlock()
+	return server.channels
+}
+
+// Watchdog returns the watchdog of the stalled publishers, nil when stopped.
+func (server *Server) Watchdog() *PublisherWatchdog {
+	server.confLock.RLock()
+	defer server.confLock.RUnlock()
+	return server.watchdog
+}
+
+// LoadBalancer returns the balancer redirecting the players to the backends,
+// nil unless a front-end.
+func (server *Server) LoadBalancer() *LoadBalancer {
+	server.confLock.RLock()
+	defer server.confLock.RUnlock()
+	return server.loadBalancer
+}
+
+// ABR returns the manager transcoding the streams with TranscodeProfiles, nil
+// without ffmpeg.
+func (server *Server) ABR() *ABRManager {
+	server.confLock.RLock()
+	defer server.confLock.RUnlock()
+	return server.abr
+}
+
+func (server *Server) setAnnounceLimiter(limiter *AnnounceLimiter) {
+	server.confLock.Lock()
+	server.announceLimiter = limiter
+	server.confLock.Unlock()
+}
+
+// Redis returns the ring shared with the other nodes, built at start from
+// the [redis] section. It is nil without one, or while the server is
+// stopped.
+func (server *Server) Redis() *redis.Ring {
+	server.ringLock.RLock()
+	defer server.ringLock.RUnlock()
+	return server.ring
+}
+
 func (server *Server) Start() (err error) {
 	var (
 		logger   = server.logger
@@ -53,6 +193,94 @@ func (server *Server) Start() (err error) {
 		return
 	}
 
+	var accessLog *RTSPAccessLog
+	if accessLogPath := utils.Conf().Section("rtsp").Key("access_log").MustString(""); accessLogPath != "" {
+		f, err := os.OpenFile(accessLogPath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+		if err != nil {
+			logger.Printf("Open access_log[%s] err:%v.", accessLogPath, err)
+		} else {
+			format := utils.Conf().Section("rtsp").Key("access_log_format").In(ACCESS_LOG_FORMAT_COMBINED, []string{ACCESS_LOG_FORMAT_COMBINED, ACCESS_LOG_FORMAT_JSON})
+			flushInterval := utils.Conf().Section("rtsp").Key("access_log_flush_interval").MustInt(1000)
+			accessLog = NewRTSPAccessLog(f, format, time.Duration(flushInterval)*time.Millisecond)
+		}
+	}
+	server.confLock.Lock()
+	server.accessLog = accessLog
+	server.confLock.Unlock()
+
+	normalizer, err := NewStreamNameNormalizer()
+	if err != nil {
+		logger.Printf("Stream name normalizer err:%v, stream names are used as is.", err)
+		err = nil
+	}
+	// players must not be admitted from everywhere when the policy can not be enforced
+	ipPolicy, err := NewIPPolicy()
+	if err != nil {
+		listener.Close()
+		return fmt.Errorf("geoip %v", err)
+	}
+
+	webhook := NewWebhook(logger)
+	server.confLock.Lock()
+	server.nameNormalizer = normalizer
+	server.webhook = webhook
+	server.ipPolicy = ipPolicy
+	server.confLock.Unlock()
+
+	ring := NewRedisRing()
+	if ring != nil {
+		// dial the shards now rather than on the first session or ANNOUNCE
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := ring.WarmUp(ctx); err != nil {
+			logger.Printf("WARN %v", err)
+		}
+		cancel()
+	}
+	server.ringLock.Lock()
+	server.ring = ring
+	server.ringLock.Unlock()
+	if limit := utils.Conf().Section("rtsp").Key("announce_rate_limit").MustInt(0); limit > 0 {
+		var bans BanStore = NewMemoryBanStore()
+		if ring != nil {
+			bans = NewRedisBanStore(ring)
+		}
+		banDuration := time.Duration(utils.Conf().Section("rtsp").Key("announce_ban_duration").MustInt(600)) * time.Second
+		server.setAnnounceLimiter(NewAnnounceLimiter(limit, banDuration, bans, logger))
+	}
+
+	var statAggregator *StatAggregator
+	if utils.Conf().Section("rtsp").Key("session_stat_enable").MustInt(1) != 0 && utils.Conf().Section("rtsp").Key("stat_agg_enable").MustInt(1) != 0 {
+		statAggregator = NewStatAggregator(server, utils.Conf().Section("rtsp").Key("stat_agg_retention_days").MustInt(30))
+	}
+
+	// always run, a stream may have a stall timeout in t_streams without a default
+	stallTimeout := utils.Conf().Section("rtsp").Key("stall_timeout").MustInt(0)
+	watchdog := NewPublisherWatchdog(server, time.Duration(stallTimeout)*time.Second)
+
+	var loadBalancer *LoadBalancer
+	if sec := utils.Conf().Section("load_balancer"); len(sec.Key("backends").Strings(",")) > 0 {
+		interval := time.Duration(sec.Key("health_check_interval").MustInt(10)) * time.Second
+		pool := NewBackendPool(sec.Key("backends").Strings(","), interval, logger)
+		loadBalancer = NewLoadBalancer(pool, sec.Key("sticky").MustBool(false))
+	}
+	server.confLock.Lock()
+	server.statAggregator = statAggregator
+	server.watchdog = watchdog
+	server.loadBalancer = loadBalancer
+	server.confLock.Unlock()
+
+	if utils.Conf().Section("rtsp").Key("mdns_enable").MustInt(0) != 0 {
+		if err = server.SetMDNSEnabled(true); err != nil {
+			logger.Printf("Start mdns advertiser err:%v.", err)
+			err = nil
+		}
+	}
+
+	if err = server.Aliases.Load(); err != nil {
+		logger.Printf("Load stream aliases err:%v.", err)
+		err = nil
+	}
+
 	localRecord := utils.Conf().Section("rtsp").Key("save_stream_to_local").MustInt(0)
 	ffmpeg := utils.Conf().Section("rtsp").Key("ffmpeg_path").MustString("")
 	m3u8_dir_path := utils.Conf().Section("rtsp").Key("m3u8_dir_path").MustString("")
@@ -66,9 +294,44 @@ func (server *Server) Start() (err error) {
 			SaveStreamToLocal = true
 		}
 	}
-	go func() { // save to local.
+	if len(ffmpeg) > 0 && len(m3u8_dir_path) > 0 {
+		storage := NewRecordStorage()
+		dvr := NewDVRManager(server, storage)
+		scheduler := NewCronScheduler(server, dvr)
+		server.confLock.Lock()
+		server.dvr = dvr
+		server.scheduler = scheduler
+		server.confLock.Unlock()
+		if storage, ok := storage.(*LocalStorage); ok {
+			channels := NewChannelManager(server, ffmpeg, storage)
+			server.confLock.Lock()
+			server.channels = channels
+			server.confLock.Unlock()
+		}
+	}
+	var abr *ABRManager
+	if len(ffmpeg) > 0 {
+		abr = NewABRManager(server, ffmpeg)
+	}
+	mergers := NewStreamMergers(server)
+	server.confLock.Lock()
+	server.abr = abr
+	server.mergers = mergers
+	server.confLock.Unlock()
+	dashEnable := utils.Conf().Section("dash").Key("enable").MustInt(0)
+	dash_dir_path := utils.Conf().Section("dash").Key("dir_path").MustString("")
+	DASHOutput := false
+	if (len(ffmpeg) > 0) && dashEnable > 0 && len(dash_dir_path) > 0 {
+		err = utils.EnsureDir(dash_dir_path)
+		if err != nil {
+			logger.Printf("Create dash dir_path[%s] err:%v.", dash_dir_path, err)
+		} else {
+			DASHOutput = true
+		}
+	}
+	go func() { // save to local, HLS and DASH share one ffmpeg per pusher.
 		pusher2ffmpegMap := make(map[*Pusher]*exec.Cmd)
-		if SaveStreamToLocal {
+		if SaveStreamToLocal || DASHOutput {
 			logger.Printf("Prepare to save stream to local....")
 			defer logger.Printf("End save stream to local....")
 		}
@@ -78,26 +341,47 @@ func (server *Server) Start() (err error) {
 		for addChnOk || removeChnOk {
 			select {
 			case pusher, addChnOk = <-server.addPusherCh:
-				if SaveStreamToLocal {
+				if SaveStreamToLocal || DASHOutput {
 					if addChnOk {
-						dir := path.Join(m3u8_dir_path, pusher.Path(), time.Now().Format("20060102"))
-						err := utils.EnsureDir(dir)
-						if err != nil {
-							logger.Printf("EnsureDir:[%s] err:%v.", dir, err)
-							continue
-						}
-						m3u8path := path.Join(dir, fmt.Sprintf("out.m3u8"))
 						port := pusher.Server().TCPPort
 						rtsp := fmt.Sprintf("rtsp://localhost:%d%s", port, pusher.Path())
 						paramStr := utils.Conf().Section("rtsp").Key(pusher.Path()).MustString("-c:v copy -c:a aac")
-						params := []string{"-fflags", "genpts", "-rtsp_transport", "tcp", "-i", rtsp, "-hls_time", strconv.Itoa(ts_duration_second), "-hls_list_size", "0", m3u8path}
+						var paramsOfThisPath []string
 						if paramStr != "default" {
-							paramsOfThisPath := strings.Split(paramStr, " ")
-							params = append(params[:6], append(paramsOfThisPath, params[6:]...)...)
+							paramsOfThisPath = strings.Split(paramStr, " ")
+						}
+						params := []string{"-fflags", "genpts", "-rtsp_transport", "tcp", "-i", rtsp}
+						logDir := ""
+						if SaveStreamToLocal {
+							dir := path.Join(m3u8_dir_path, pusher.Path(), time.Now().Format("20060102"))
+							err := utils.EnsureDir(dir)
+							if err != nil {
+								logger.Printf("EnsureDir:[%s] err:%v.", dir, err)
+								continue
+							}
+							m3u8path := path.Join(dir, fmt.Sprintf("out.m3u8"))
+							params = append(params, paramsOfThisPath...)
+							params = append(params, VideoOutputArgs(pusher.VCodec(), paramsOfThisPath, "master.m3u8")...)
+							params = append(params, "-hls_time", strconv.Itoa(ts_duration_second), "-hls_list_size", "0", m3u8path)
+							logDir = dir
+						}
+						if DASHOutput {
+							muxer := NewDASHMuxer(dash_dir_path, pusher)
+							err := muxer.EnsureDirs()
+							if err != nil {
+								logger.Printf("EnsureDir:[%s] err:%v.", muxer.Dir, err)
+								continue
+							}
+							params = append(params, paramsOfThisPath...)
+							params = append(params, VideoOutputArgs(pusher.VCodec(), paramsOfThisPath, "")...)
+							params = append(params, muxer.OutputArgs()...)
+							if logDir == "" {
+								logDir = muxer.Dir
+							}
 						}
 						// ffmpeg -i ~/Downloads/720p.mp4 -s 640x360 -g 15 -c:a aac -hls_time 5 -hls_list_size 0 record.m3u8
 						cmd := exec.Command(ffmpeg, params...)
-						f, err := os.OpenFile(path.Join(dir, fmt.Sprintf("log.txt")), os.O_RDWR|os.O_CREATE, 0755)
+						f, err := os.OpenFile(path.Join(logDir, fmt.Sprintf("log.txt")), os.O_RDWR|os.O_CREATE, 0755)
 						if err == nil {
 							cmd.Stdout = f
 							cmd.Stderr = f
@@ -113,7 +397,7 @@ func (server *Server) Start() (err error) {
 					}
 				}
 			case pusher, removeChnOk = <-server.removePusherCh:
-				if SaveStreamToLocal {
+				if SaveStreamToLocal || DASHOutput {
 					if removeChnOk {
 						cmd := pusher2ffmpegMap[pusher]
 						proc := cmd.Process
@@ -184,15 +468,104 @@ func (server *Server) Stop() {
 		server.TCPListener = nil
 	}
 	server.pushersLock.Lock()
+	pushers := server.pushers
 	server.pushers = make(map[string]*Pusher)
 	server.pushersLock.Unlock()
+	for _, pusher := range pushers {
+		pusher.Stop()
+	}
+	// sessions may still log, the closed access log drops their lines
+	if accessLog := server.AccessLog(); accessLog != nil {
+		accessLog.Close()
+	}
+	server.confLock.Lock()
+	webhook := server.webhook
+	server.webhook = nil
+	server.ipPolicy = nil
+	statAggregator, watchdog, loadBalancer := server.statAggregator, server.watchdog, server.loadBalancer
+	scheduler, channels, abr, mergers, dvr := server.scheduler, server.channels, server.abr, server.mergers, server.dvr
+	server.statAggregator, server.watchdog, server.loadBalancer = nil, nil, nil
+	server.scheduler, server.channels, server.abr, server.mergers, server.dvr = nil, nil, nil, nil, nil
+	server.confLock.Unlock()
+	webhook.Close()
+	if statAggregator != nil {
+		statAggregator.Stop()
+	}
+	if watchdog != nil {
+		watchdog.Stop()
+	}
+	if loadBalancer != nil {
+		loadBalancer.Stop()
+	}
+	if scheduler != nil {
+		scheduler.Stop()
+	}
+	if channels != nil {
+		channels.Stop()
+	}
+	if abr != nil {
+		abr.Stop()
+	}
+	for _, merger := range mergers {
+		merger.Stop()
+	}
+	if dvr != nil {
+		dvr.StopAll()
+	}
+	server.setAnnounceLimiter(nil)
+	server.ringLock.Lock()
+	if server.ring != nil {
+		server.ring.Close()
+		server.ring = nil
+	}
+	server.ringLock.Unlock()
+	server.SetMDNSEnabled(false)
 
 	close(server.addPusherCh)
 	close(server.removePusherCh)
 }
 
+// SetMDNSEnabled starts or stops advertising the server over mDNS.
+func (server *Server) SetMDNSEnabled(enabled bool) (err error) {
+	server.mdnsLock.Lock()
+	defer server.mdnsLock.Unlock()
+	if enabled == (server.mdns != nil) {
+		return
+	}
+	if !enabled {
+		server.mdns.Stop()
+		server.mdns = nil
+		return
+	}
+	server.mdns, err = NewMDNSAdvertiser(server)
+	return
+}
+
+func (server *Server) MDNSEnabled() bool {
+	server.mdnsLock.Lock()
+	defer server.mdnsLock.Unlock()
+	return server.mdns != nil
+}
+
+func (server *Server) updateMDNS() {
+	server.mdnsLock.Lock()
+	if server.mdns != nil {
+		server.mdns.Update()
+	}
+	server.mdnsLock.Unlock()
+}
+
+// NormalizeStreamName returns the name a stream is stored and looked up under.
+func (server *Server) NormalizeStreamName(rawName string) (string, error) {
+	return server.NameNormalizer().Normalize(rawName)
+}
+
 func (server *Server) AddPusher(pusher *Pusher) bool {
 	logger := server.logger
+	if pusher.DataCapExceeded() {
+		logger.Printf("%v used up its data cap of %d bytes, reject it", pusher, pusher.dataCounter.CapBytes)
+		return false
+	}
 	added := false
 	server.pushersLock.Lock()
 	_, ok := server.pushers[pusher.Path()]
@@ -207,6 +580,11 @@ func (server *Server) AddPusher(pusher *Pusher) bool {
 	if added {
 		go pusher.Start()
 		server.addPusherCh <- pusher
+		server.Emit(EVENT_STREAM_START, pusher.Path(), map[string]interface{}{
+			"id":     pusher.ID(),
+			"source": pusher.Source(),
+		})
+		server.updateMDNS()
 	}
 	return added
 }
@@ -240,6 +618,14 @@ func (server *Server) RemovePusher(pusher *Pusher) {
 	server.pushersLock.Unlock()
 	if removed {
 		server.removePusherCh <- pusher
+		rembSuggestedKbps.Delete(pusher.Path())
+		if pusher.dataCounter != nil {
+			go pusher.dataCounter.Persist()
+		}
+		server.Emit(EVENT_STREAM_STOP, pusher.Path(), map[string]interface{}{
+			"id": pusher.ID(),
+		})
+		server.updateMDNS()
 	}
 }
 
@@ -15,11 +15,14 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"EasyDarwin/helper/penggy/EasyGoLib/db"
 	"EasyDarwin/helper/penggy/EasyGoLib/utils"
 	"EasyDarwin/models"
+	"EasyDarwin/rtsp/sdp"
+	"EasyDarwin/version"
 
 	"EasyDarwin/helper/teris-io/shortid"
 )
@@ -74,6 +77,8 @@ type TransType int
 const (
 	TRANS_TYPE_TCP TransType = iota
 	TRANS_TYPE_UDP
+	TRANS_TYPE_CHANNEL
+	TRANS_TYPE_MERGE
 )
 
 func (tt TransType) String() string {
@@ -82,6 +87,10 @@ func (tt TransType) String() string {
 		return "TCP"
 	case TRANS_TYPE_UDP:
 		return "UDP"
+	case TRANS_TYPE_CHANNEL:
+		return "Channel"
+	case TRANS_TYPE_MERGE:
+		return "Merge"
 	}
 	return "unknow"
 }
@@ -98,12 +107,15 @@ type Session struct {
 	Type      SessionType
 	TransType TransType
 	Path      string
+	RawPath   string // path as sent by the client, before normalization
 	URL       string
 	SDPRaw    string
-	SDPMap    map[string]*SDPInfo
+	Sdp       *sdp.SessionDescription // nil when the ANNOUNCE SDP was only scanned, see sdp_strict
+	SDPMap    map[string]*sdp.Info
 
 	authorizationEnable bool
 	nonce               string
+	authUser            string
 	closeOld            bool
 	debugLogEnable      bool
 
@@ -113,12 +125,20 @@ type Session struct {
 	VCodec   string
 
 	// stats info
-	InBytes  int
-	OutBytes int
-	StartAt  time.Time
-	Timeout  int
+	InBytes     int
+	OutBytes    int
+	PacketsLost int
+	StartAt     time.Time
+	Timeout     int
 
-	Stoped bool
+	clientIP       string
+	stopReason     string
+	stopReasonLock sync.Mutex
+	authFailed     bool
+	lastRTPSeqs    map[RTPType]uint16
+
+	// set once by Stop, read by the goroutines of the session, see Stoped
+	stoped int32
 
 	//tcp channels
 	aRTPChannel        int
@@ -129,26 +149,40 @@ type Session struct {
 	Pusher      *Pusher
 	Player      *Player
 	UDPClient   *UDPClient
+	Merger      *StreamMerger // publisher merging other streams, no Conn
 	RTPHandles  []func(*RTPPack)
 	StopHandles []func()
 }
 
 func (session *Session) String() string {
-	return fmt.Sprintf("session[%v][%v][%s][%s][%s]", session.Type, session.TransType, session.Path, session.ID, session.Conn.RemoteAddr().String())
+	remoteAddr := session.clientIP
+	if session.Conn != nil {
+		remoteAddr = session.Conn.RemoteAddr().String()
+	}
+	return fmt.Sprintf("session[%v][%v][%s][%s][%s]", session.Type, session.TransType, session.Path, session.ID, remoteAddr)
 }
 
 func NewSession(server *Server, conn net.Conn) *Session {
 	networkBuffer := utils.Conf().Section("rtsp").Key("network_buffer").MustInt(204800)
 	timeoutMillis := utils.Conf().Section("rtsp").Key("timeout").MustInt(0)
-	timeoutTCPConn := &RichConn{conn, time.Duration(timeoutMillis) * time.Millisecond}
+	timeoutTCPConn := &RichConn{Conn: conn, timeout: time.Duration(timeoutMillis) * time.Millisecond}
+	session := newSession(server)
+	session.Conn = timeoutTCPConn
+	session.connRW = bufio.NewReadWriter(bufio.NewReaderSize(timeoutTCPConn, networkBuffer), bufio.NewWriterSize(timeoutTCPConn, networkBuffer))
+	if host, _, err := net.SplitHostPort(conn.RemoteAddr().String()); err == nil {
+		session.clientIP = host
+	}
+	return session
+}
+
+// newSession returns a session without a connection.
+func newSession(server *Server) *Session {
 	authorizationEnable := utils.Conf().Section("rtsp").Key("authorization_enable").MustInt(0)
 	close_old := utils.Conf().Section("rtsp").Key("close_old").MustInt(0)
 	debugLogEnable := utils.Conf().Section("rtsp").Key("debug_log_enable").MustInt(0)
 	session := &Session{
 		ID:                  shortid.MustGenerate(),
 		Server:              server,
-		Conn:                timeoutTCPConn,
-		connRW:              bufio.NewReadWriter(bufio.NewReaderSize(timeoutTCPConn, networkBuffer), bufio.NewWriterSize(timeoutTCPConn, networkBuffer)),
 		StartAt:             time.Now(),
 		Timeout:             utils.Conf().Section("rtsp").Key("timeout").MustInt(0),
 		authorizationEnable: authorizationEnable != 0,
@@ -160,6 +194,7 @@ func NewSession(server *Server, conn net.Conn) *Session {
 		aRTPChannel:         -1,
 		aRTPControlChannel:  -1,
 		closeOld:            close_old != 0,
+		lastRTPSeqs:         make(map[RTPType]uint16),
 	}
 
 	session.logger = log.New(os.Stdout, fmt.Sprintf("[%s]", session.ID), log.LstdFlags|log.Lshortfile)
@@ -169,22 +204,34 @@ func NewSession(server *Server, conn net.Conn) *Session {
 	return session
 }
 
+// Stoped tells whether Stop was called.
+func (session *Session) Stoped() bool {
+	return atomic.LoadInt32(&session.stoped) == 1
+}
+
+// Stop ends the session. It may be called from any goroutine, and more than
+// once. The connection is closed but kept, so the goroutines still using it
+// get an error rather than a nil connection.
 func (session *Session) Stop() {
-	if session.Stoped {
+	if !atomic.CompareAndSwapInt32(&session.stoped, 0, 1) {
 		return
 	}
-	session.Stoped = true
 	for _, h := range session.StopHandles {
 		h()
 	}
+	session.saveStat()
+	if role := session.auditRole(); role != "" {
+		session.audit(models.AUDIT_SESSION_STOP, map[string]interface{}{
+			"role":   role,
+			"reason": session.disconnectReason(),
+		})
+	}
+	// every write is flushed under connWLock, nothing is left to flush
 	if session.Conn != nil {
-		session.connRW.Flush()
 		session.Conn.Close()
-		session.Conn = nil
 	}
 	if session.UDPClient != nil {
 		session.UDPClient.Stop()
-		session.UDPClient = nil
 	}
 }
 
@@ -194,9 +241,12 @@ func (session *Session) Start() {
 	buf2 := make([]byte, 2)
 	logger := session.logger
 	timer := time.Unix(0, 0)
-	for !session.Stoped {
+	for !session.Stoped() {
 		if _, err := io.ReadFull(session.connRW, buf1); err != nil {
 			logger.Println(session, err)
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				session.SetStopReason(DISCONNECT_REASON_TIMEOUT)
+			}
 			return
 		}
 		if buf1[0] == 0x24 { //rtp data
@@ -253,13 +303,16 @@ func (session *Session) Start() {
 				continue
 			}
 			session.InBytes += rtpLen + 4
+			if session.Type == SESSEION_TYPE_PLAYER && session.Player != nil && (pack.Type == RTP_TYPE_VIDEOCONTROL || pack.Type == RTP_TYPE_AUDIOCONTROL) {
+				session.Player.HandleRTCP(pack.Type, rtpBytes)
+			}
 			for _, h := range session.RTPHandles {
 				h(pack)
 			}
 		} else { // rtsp cmd
 			reqBuf := bytes.NewBuffer(nil)
 			reqBuf.Write(buf1)
-			for !session.Stoped {
+			for !session.Stoped() {
 				if line, isPrefix, err := session.connRW.ReadLine(); err != nil {
 					logger.Println(err)
 					return
@@ -296,10 +349,11 @@ func (session *Session) Start() {
 	}
 }
 
+var usernameRex = regexp.MustCompile(`username="(.*?)"`)
+
 func CheckAuth(authLine string, method string, sessionNonce string) error {
 	realmRex := regexp.MustCompile(`realm="(.*?)"`)
 	nonceRex := regexp.MustCompile(`nonce="(.*?)"`)
-	usernameRex := regexp.MustCompile(`username="(.*?)"`)
 	responseRex := regexp.MustCompile(`response="(.*?)"`)
 	uriRex := regexp.MustCompile(`uri="(.*?)"`)
 
@@ -365,6 +419,7 @@ func (session *Session) handleRequest(req *Request) {
 	logger := session.logger
 	logger.Printf("<<<\n%s", req)
 	res := NewResponse(200, "OK", req.Header["CSeq"], session.ID, "")
+	res.Header["Server"] = version.Banner()
 	defer func() {
 		if p := recover(); p != nil {
 			logger.Printf("handleRequest err ocurs:%v", p)
@@ -378,6 +433,9 @@ func (session *Session) handleRequest(req *Request) {
 		session.connRW.Flush()
 		session.connWLock.Unlock()
 		session.OutBytes += len(outBytes)
+		if accessLog := session.Server.AccessLog(); accessLog != nil {
+			accessLog.Log(session, req, res, len(outBytes))
+		}
 		switch req.Method {
 		case "PLAY", "RECORD":
 			switch session.Type {
@@ -386,6 +444,7 @@ func (session *Session) handleRequest(req *Request) {
 					session.Player.Pause(false)
 				} else {
 					session.Pusher.AddPlayer(session.Player)
+					session.audit(models.AUDIT_SESSION_START, map[string]interface{}{"role": "sub"})
 				}
 				// case SESSION_TYPE_PUSHER:
 				// 	session.Server.AddPusher(session.Pusher)
@@ -396,7 +455,8 @@ func (session *Session) handleRequest(req *Request) {
 				return
 			}
 		}
-		if res.StatusCode != 200 && res.StatusCode != 401 {
+		// 451 and 455 only reject the parameters, the stream goes on.
+		if res.StatusCode != 200 && res.StatusCode != 401 && res.StatusCode != 451 && res.StatusCode != 455 {
 			logger.Printf("Response request error[%d]. stop session.", res.StatusCode)
 			session.Stop()
 		}
@@ -409,11 +469,27 @@ func (session *Session) handleRequest(req *Request) {
 				err := CheckAuth(authLine, req.Method, session.nonce)
 				if err == nil {
 					authFailed = false
+					session.authFailed = false
+					if result := usernameRex.FindStringSubmatch(authLine); len(result) == 2 {
+						// audit the first request of the user only
+						if session.authUser != result[1] {
+							session.authUser = result[1]
+							session.audit(models.AUDIT_AUTH, map[string]interface{}{"method": req.Method, "success": true})
+						}
+					}
 				} else {
 					logger.Printf("%v", err)
 				}
 			}
 			if authFailed {
+				if authLine != "" {
+					details := map[string]interface{}{"method": req.Method, "success": false}
+					if result := usernameRex.FindStringSubmatch(authLine); len(result) == 2 {
+						details["user"] = result[1]
+					}
+					session.audit(models.AUDIT_AUTH, details)
+				}
+				session.authFailed = true
 				res.StatusCode = 401
 				res.Status = "Unauthorized"
 				nonce := fmt.Sprintf("%x", md5.Sum([]byte(shortid.MustGenerate())))
@@ -425,7 +501,8 @@ func (session *Session) handleRequest(req *Request) {
 	}
 	switch req.Method {
 	case "OPTIONS":
-		res.Header["Public"] = "DESCRIBE, SETUP, TEARDOWN, PLAY, PAUSE, OPTIONS, ANNOUNCE, RECORD"
+		res.Header["Public"] = strings.Join(PUBLIC_METHODS, ", ")
+		res.Header["Supported"] = strings.Join(SUPPORTED_FEATURES, ", ")
 	case "ANNOUNCE":
 		session.Type = SESSION_TYPE_PUSHER
 		session.URL = req.URL
@@ -436,10 +513,39 @@ func (session *Session) handleRequest(req *Request) {
 			res.Status = "Invalid URL"
 			return
 		}
-		session.Path = url.Path
+		session.RawPath = url.Path
+		if session.Path, err = session.Server.NormalizeStreamName(url.Path); err != nil {
+			logger.Printf("%v", err)
+			res.StatusCode = 400
+			res.Status = "Invalid Stream Name"
+			return
+		}
+		if session.RawPath != session.Path {
+			logger.Printf("stream name[%s] normalized to [%s]", session.RawPath, session.Path)
+		}
+		if limiter := session.Server.AnnounceLimiter(); limiter != nil && !limiter.Allow(session.clientIP, session.Path) {
+			res.StatusCode = 503
+			res.Status = "Service Unavailable"
+			return
+		}
 
+		_sdp, err := sdp.Parse(req.Body)
+		if err != nil {
+			if utils.Conf().Section("rtsp").Key("sdp_strict").MustBool(false) {
+				logger.Printf("invalid sdp:%v", err)
+				res.StatusCode = 400
+				res.Status = "Invalid SDP"
+				return
+			}
+			logger.Printf("WARN invalid sdp:%v, scan it line by line", err)
+		}
 		session.SDPRaw = req.Body
-		session.SDPMap = ParseSDP(req.Body)
+		session.Sdp = _sdp
+		if _sdp != nil {
+			session.SDPMap = sdp.Infos(_sdp)
+		} else {
+			session.SDPMap = sdp.ScanInfos(req.Body)
+		}
 		sdp, ok := session.SDPMap["audio"]
 		if ok {
 			session.AControl = sdp.Control
@@ -491,6 +597,8 @@ func (session *Session) handleRequest(req *Request) {
 				logger.Printf("reject pusher.")
 				res.StatusCode = 406
 				res.Status = "Not Acceptable"
+			} else {
+				session.audit(models.AUDIT_SESSION_START, map[string]interface{}{"role": "pub"})
 			}
 		}
 	case "DESCRIBE":
@@ -503,9 +611,25 @@ func (session *Session) handleRequest(req *Request) {
 			res.Status = "Invalid URL"
 			return
 		}
-		session.Path = url.Path
+		session.RawPath = url.Path
+		if session.Path, err = session.Server.NormalizeStreamName(url.Path); err != nil {
+			logger.Printf("%v", err)
+			res.StatusCode = 400
+			res.Status = "Invalid Stream Name"
+			return
+		}
+		if policy := session.Server.IPPolicy(); policy != nil && session.applyIPPolicy(policy, url, res) {
+			return
+		}
+		if balancer := session.Server.LoadBalancer(); balancer != nil {
+			session.redirectToBackend(balancer, url, res)
+			return
+		}
 		pusher := session.Server.GetPusher(session.Path)
 		if pusher == nil {
+			if session.redirectAlias(url, res) {
+				return
+			}
 			res.StatusCode = 404
 			res.Status = "NOT FOUND"
 			return
@@ -517,7 +641,8 @@ func (session *Session) handleRequest(req *Request) {
 		session.ACodec = pusher.ACodec()
 		session.VCodec = pusher.VCodec()
 		session.Conn.timeout = 0
-		res.SetBody(session.Pusher.SDPRaw())
+		res.Header["Content-Type"] = sdp.ContentType
+		res.SetBody(session.Pusher.SDP())
 	case "SETUP":
 		ts := req.Header["Transport"]
 		// control字段可能是`stream=1`字样，也可能是rtsp://...字样。即control可能是url的path，也可能是整个url
@@ -598,6 +723,16 @@ func (session *Session) handleRequest(req *Request) {
 			session.TransType = TRANS_TYPE_UDP
 			// no need for tcp timeout.
 			session.Conn.timeout = 0
+			// RTP and RTCP share one port only if the client offers rtcp-mux and the server enables it,
+			// otherwise the offer is dropped from the reply and the client falls back to two ports.
+			mux := false
+			if tss := strings.Split(ts, ";"); transportHasRTCPMux(tss) {
+				if session.Server.MuxRTPRTCP {
+					mux = true
+				} else {
+					ts = strings.Join(removeTransportRTCPMux(tss), ";")
+				}
+			}
 			if session.Type == SESSEION_TYPE_PLAYER && session.UDPClient == nil {
 				session.UDPClient = &UDPClient{
 					Session: session,
@@ -613,6 +748,7 @@ func (session *Session) handleRequest(req *Request) {
 				if session.Type == SESSEION_TYPE_PLAYER {
 					session.UDPClient.APort, _ = strconv.Atoi(udpMatchs[1])
 					session.UDPClient.AControlPort, _ = strconv.Atoi(udpMatchs[3])
+					session.UDPClient.Mux = mux
 					if err := session.UDPClient.SetupAudio(); err != nil {
 						res.StatusCode = 500
 						res.Status = fmt.Sprintf("udp client setup audio error, %v", err)
@@ -620,6 +756,7 @@ func (session *Session) handleRequest(req *Request) {
 					}
 				}
 				if session.Type == SESSION_TYPE_PUSHER {
+					session.Pusher.UDPServer.Mux = mux
 					if err := session.Pusher.UDPServer.SetupAudio(); err != nil {
 						res.StatusCode = 500
 						res.Status = fmt.Sprintf("udp server setup audio error, %v", err)
@@ -633,7 +770,11 @@ func (session *Session) handleRequest(req *Request) {
 						}
 					}
 					tail := append([]string{}, tss[idx+1:]...)
-					tss = append(tss[:idx+1], fmt.Sprintf("server_port=%d-%d", session.Pusher.UDPServer.APort, session.Pusher.UDPServer.AControlPort))
+					serverPort := fmt.Sprintf("server_port=%d-%d", session.Pusher.UDPServer.APort, session.Pusher.UDPServer.AControlPort)
+					if mux {
+						serverPort = fmt.Sprintf("server_port=%d", session.Pusher.UDPServer.APort)
+					}
+					tss = append(tss[:idx+1], serverPort)
 					tss = append(tss, tail...)
 					ts = strings.Join(tss, ";")
 				}
@@ -641,6 +782,7 @@ func (session *Session) handleRequest(req *Request) {
 				if session.Type == SESSEION_TYPE_PLAYER {
 					session.UDPClient.VPort, _ = strconv.Atoi(udpMatchs[1])
 					session.UDPClient.VControlPort, _ = strconv.Atoi(udpMatchs[3])
+					session.UDPClient.Mux = mux
 					if err := session.UDPClient.SetupVideo(); err != nil {
 						res.StatusCode = 500
 						res.Status = fmt.Sprintf("udp client setup video error, %v", err)
@@ -649,6 +791,7 @@ func (session *Session) handleRequest(req *Request) {
 				}
 
 				if session.Type == SESSION_TYPE_PUSHER {
+					session.Pusher.UDPServer.Mux = mux
 					if err := session.Pusher.UDPServer.SetupVideo(); err != nil {
 						res.StatusCode = 500
 						res.Status = fmt.Sprintf("udp server setup video error, %v", err)
@@ -662,7 +805,11 @@ func (session *Session) handleRequest(req *Request) {
 						}
 					}
 					tail := append([]string{}, tss[idx+1:]...)
-					tss = append(tss[:idx+1], fmt.Sprintf("server_port=%d-%d", session.Pusher.UDPServer.VPort, session.Pusher.UDPServer.VControlPort))
+					serverPort := fmt.Sprintf("server_port=%d-%d", session.Pusher.UDPServer.VPort, session.Pusher.UDPServer.VControlPort)
+					if mux {
+						serverPort = fmt.Sprintf("server_port=%d", session.Pusher.UDPServer.VPort)
+					}
+					tss = append(tss[:idx+1], serverPort)
 					tss = append(tss, tail...)
 					ts = strings.Join(tss, ";")
 				}
@@ -674,6 +821,14 @@ func (session *Session) handleRequest(req *Request) {
 	case "PLAY":
 		// error status. PLAY without ANNOUNCE or DESCRIBE.
 		if session.Pusher == nil {
+			if url, err := url.Parse(req.URL); err == nil {
+				if path, err := session.Server.NormalizeStreamName(url.Path); err == nil {
+					session.Path = path
+					if session.redirectAlias(url, res) {
+						return
+					}
+				}
+			}
 			res.StatusCode = 500
 			res.Status = "Error Status"
 			return
@@ -693,9 +848,93 @@ func (session *Session) handleRequest(req *Request) {
 			return
 		}
 		session.Player.Pause(true)
+	case "GET_PARAMETER":
+		names := parseParameterNames(req.Body)
+		if len(names) == 0 {
+			// keep-alive
+			return
+		}
+		for _, name := range names {
+			if !streamParameterAllowed(name) {
+				res.StatusCode = 451
+				res.Status = "Parameter Not Understood"
+				return
+			}
+		}
+		metadata, _, err := models.FindStreamMetadata(session.Path)
+		if err != nil {
+			logger.Printf("find metadata of %s err:%v", session.Path, err)
+			res.StatusCode = 500
+			res.Status = "Internal Server Error"
+			return
+		}
+		body := ""
+		for _, name := range names {
+			// a parameter not set yet is empty
+			value, _ := streamParameter(metadata, name)
+			body += fmt.Sprintf("%s: %s\r\n", name, value)
+		}
+		res.Header["Content-Type"] = "text/parameters"
+		res.SetBody(body)
+	case "SET_PARAMETER":
+		params, ok := parseParameters(req.Body)
+		if !ok {
+			res.StatusCode = 400
+			res.Status = "Bad Request"
+			return
+		}
+		if len(params) == 0 {
+			// keep-alive
+			return
+		}
+		// only the publisher may change the parameters of its stream.
+		if session.Type != SESSION_TYPE_PUSHER || session.Pusher == nil {
+			res.StatusCode = 455
+			res.Status = "Method Not Valid in This State"
+			return
+		}
+		for name := range params {
+			if !streamParameterAllowed(name) {
+				res.StatusCode = 451
+				res.Status = "Parameter Not Understood"
+				return
+			}
+		}
+		changed, found, err := updateStreamParameters(session.Path, params)
+		if err != nil {
+			logger.Printf("update metadata of %s err:%v", session.Path, err)
+			res.StatusCode = 500
+			res.Status = "Internal Server Error"
+			return
+		}
+		// the parameters live in the metadata of the stream in t_streams
+		if !found {
+			res.StatusCode = 455
+			res.Status = "Method Not Valid in This State"
+			return
+		}
+		if len(changed) > 0 {
+			session.Server.Emit(EVENT_STREAM_PARAM_CHANGED, session.Path, map[string]interface{}{
+				"params": changed,
+			})
+		}
 	}
 }
 
+// redirectAlias turns res into a 302 to the canonical path if session.Path is an alias.
+func (session *Session) redirectAlias(reqURL *url.URL, res *Response) bool {
+	canonicalPath, ok := session.Server.Aliases.Resolve(session.Path)
+	if !ok {
+		return false
+	}
+	location := url.URL{Scheme: "rtsp", Host: reqURL.Host, Path: canonicalPath, RawQuery: reqURL.RawQuery}
+	session.logger.Printf("redirect alias %s to %s", session.Path, canonicalPath)
+	res.StatusCode = 302
+	res.Status = "Moved Temporarily"
+	res.Header["Location"] = location.String()
+	return true
+}
+
 func (session *Session) SendRTP(pack *RTPPack) (err error) {
 	if pack == nil {
 		err = fmt.Errorf("player send rtp got nil pack")
@@ -720,7 +959,7 @@ func (session *Session) SendRTP(pack *RTPPack) (err error) {
 		binary.BigEndian.PutUint16(bufLen, uint16(pack.Buffer.Len()))
 		session.connRW.Write(bufLen)
 		session.connRW.Write(pack.Buffer.Bytes())
-		session.connRW.Flush()
+		err = session.connRW.Flush()
 		session.connWLock.Unlock()
 		session.OutBytes += pack.Buffer.Len() + 4
 	case RTP_TYPE_AUDIOCONTROL:
@@ -733,7 +972,7 @@ func (session *Session) SendRTP(pack *RTPPack) (err error) {
 		binary.BigEndian.PutUint16(bufLen, uint16(pack.Buffer.Len()))
 		session.connRW.Write(bufLen)
 		session.connRW.Write(pack.Buffer.Bytes())
-		session.connRW.Flush()
+		err = session.connRW.Flush()
 		session.connWLock.Unlock()
 		session.OutBytes += pack.Buffer.Len() + 4
 	case RTP_TYPE_VIDEO:
@@ -746,7 +985,7 @@ func (session *Session) SendRTP(pack *RTPPack) (err error) {
 		binary.BigEndian.PutUint16(bufLen, uint16(pack.Buffer.Len()))
 		session.connRW.Write(bufLen)
 		session.connRW.Write(pack.Buffer.Bytes())
-		session.connRW.Flush()
+		err = session.connRW.Flush()
 		session.connWLock.Unlock()
 		session.OutBytes += pack.Buffer.Len() + 4
 	case RTP_TYPE_VIDEOCONTROL:
@@ -759,7 +998,7 @@ func (session *Session) SendRTP(pack *RTPPack) (err error) {
 		binary.BigEndian.PutUint16(bufLen, uint16(pack.Buffer.Len()))
 		session.connRW.Write(bufLen)
 		session.connRW.Write(pack.Buffer.Bytes())
-		session.connRW.Flush()
+		err = session.connRW.Flush()
 		session.connWLock.Unlock()
 		session.OutBytes += pack.Buffer.Len() + 4
 	default:
@@ -767,3 +1006,22 @@ func (session *Session) SendRTP(pack *RTPPack) (err error) {
 	}
 	return
 }
+
+func transportHasRTCPMux(tss []string) bool {
+	for _, val := range tss {
+		if strings.TrimSpace(val) == "rtcp-mux" {
+			return true
+		}
+	}
+	return false
+}
+
+func removeTransportRTCPMux(tss []string) []string {
+	kept := make([]string, 0, len(tss))
+	for _, val := range tss {
+		if strings.TrimSpace(val) != "rtcp-mux" {
+			kept = append(kept, val)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,111 @@
+package rtsp_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"EasyDarwin/rtsp"
+	"EasyDarwin/rtsp/rtsptest"
+	"EasyDarwin/rtsp/sdp"
+)
+
+func newTestPlayer() *rtsptest.FakePlayer {
+	player := rtsptest.NewFakePlayer("rtsptest")
+	player.Dial = rtsptest.PipeDialer(rtsp.GetServer())
+	return player
+}
+
+func TestSessionRecordThenPlay(t *testing.T) {
+	camera := rtsptest.NewFakeCamera("/test/record-play", "")
+	if err := camera.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer camera.Close()
+	pusher := rtsp.GetServer().GetPusher("/test/record-play")
+	if pusher == nil {
+		t.Fatal("no pusher after RECORD")
+	}
+	if pusher.VCodec() != "h264" {
+		t.Fatalf("pusher codec = %s, want h264", pusher.VCodec())
+	}
+
+	player := newTestPlayer()
+	if err := player.Play("/test/record-play"); err != nil {
+		t.Fatal(err)
+	}
+	defer player.Close()
+	if err := player.WaitPackets(30, 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	video := player.RTP(0)
+	if len(video) == 0 {
+		t.Fatalf("packets = %d, none on the video channel", len(player.Packets()))
+	}
+	for i, pkt := range video {
+		if len(pkt) < 12 || pkt[0]>>6 != 2 || pkt[1]&0x7f != 96 {
+			t.Fatalf("packet %d is not an RTP packet of payload type 96: % x", i, pkt[:12])
+		}
+	}
+	if !bytes.Contains([]byte(player.SDP), []byte("H264/90000")) {
+		t.Fatalf("DESCRIBE sdp = %q", player.SDP)
+	}
+}
+
+func TestSessionPlayUnknownStream(t *testing.T) {
+	player := newTestPlayer()
+	if err := player.Play("/test/none"); err == nil {
+		player.Close()
+		t.Fatal("PLAY of a stream without pusher succeeded")
+	}
+}
+
+func TestSessionPlayerLeavesWithCamera(t *testing.T) {
+	camera := rtsptest.NewFakeCamera("/test/camera-leaves", "")
+	if err := camera.Start(); err != nil {
+		t.Fatal(err)
+	}
+	player := newTestPlayer()
+	if err := player.Play("/test/camera-leaves"); err != nil {
+		camera.Close()
+		t.Fatal(err)
+	}
+	defer player.Close()
+	if err := player.WaitPackets(1, 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	camera.Close()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for rtsp.GetServer().GetPusher("/test/camera-leaves") != nil {
+		if time.Now().After(deadline) {
+			t.Fatal("pusher still there after the camera left")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestSessionDescribeSDP checks that DESCRIBE serves the ANNOUNCE SDP encoded
+// back by the SDP package, not the text the camera sent.
+func TestSessionDescribeSDP(t *testing.T) {
+	lf := strings.Replace(rtsptest.DefaultSDP, "\r\n", "\n", -1)
+	parsed, err := sdp.Parse(lf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	camera := rtsptest.NewFakeCamera("/test/describe", lf)
+	if err := camera.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer camera.Close()
+	player := newTestPlayer()
+	defer player.Close()
+	if err := player.Play("/test/describe"); err != nil {
+		t.Fatal(err)
+	}
+	if player.SDP != parsed.String() {
+		t.Errorf("DESCRIBE sdp = %q, want %q", player.SDP, parsed.String())
+	}
+}
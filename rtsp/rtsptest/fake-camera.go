@@ -0,0 +1,254 @@
+package rtsptest
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"EasyDarwin/rtsp"
+	"EasyDarwin/rtsp/sdp"
+)
+
+const rtpMTU = 1400
+
+var (
+	// DefaultSPS and DefaultPPS are the parameter sets of DefaultGOP, a 320x240
+	// baseline stream.
+	DefaultSPS = []byte{0x67, 0x42, 0xc0, 0x0d, 0xda, 0x05, 0x07, 0xec, 0x04, 0x40}
+	DefaultPPS = []byte{0x68, 0xce, 0x3c, 0x80}
+	// DefaultGOP is an SPS, a PPS, an IDR slice then 24 P slices, the slices
+	// filled with zeros. The IDR slice is larger than an RTP packet, so it is
+	// sent in FU-A fragments.
+	DefaultGOP = defaultGOP()
+	// DefaultSDP describes the H.264 video of DefaultGOP on payload type 96.
+	DefaultSDP = fmt.Sprintf("v=0\r\n"+
+		"o=- 0 0 IN IP4 127.0.0.1\r\n"+
+		"s=rtsptest\r\n"+
+		"c=IN IP4 0.0.0.0\r\n"+
+		"t=0 0\r\n"+
+		"m=video 0 RTP/AVP 96\r\n"+
+		"a=rtpmap:96 H264/90000\r\n"+
+		"a=fmtp:96 packetization-mode=1;sprop-parameter-sets=%s,%s\r\n"+
+		"a=control:streamid=0\r\n",
+		base64.StdEncoding.EncodeToString(DefaultSPS), base64.StdEncoding.EncodeToString(DefaultPPS))
+)
+
+func defaultGOP() [][]byte {
+	gop := [][]byte{DefaultSPS, DefaultPPS, append([]byte{0x65}, make([]byte, 4000)...)}
+	for i := 0; i < 24; i++ {
+		gop = append(gop, append([]byte{0x41}, make([]byte, 600)...))
+	}
+	return gop
+}
+
+// FakeCamera publishes a H.264 stream to the server with ANNOUNCE, SETUP and
+// RECORD, then sends NALUs over interleaved channel 0 at FrameRate. SPS and PPS
+// NAL units go with the slice after them, every slice is a frame. The fields
+// may be changed between NewFakeCamera and Start.
+type FakeCamera struct {
+	StreamPath string
+	SDP        string
+	// frames per second, negative to send frames only with SendFrames
+	FrameRate int
+	// NAL units sent in a loop, without start codes
+	NALUs [][]byte
+	// RTP payload type of the video
+	PayloadType int
+	Dial        Dialer
+
+	conn      *conn
+	ssrc      uint32
+	seq       uint16
+	timestamp uint32
+	next      int
+	frames    uint64
+	done      chan struct{}
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+}
+
+// NewFakeCamera returns a camera publishing streamPath, described by sdp or by
+// DefaultSDP if sdp is empty, to rtsp.GetServer() over net.Pipe.
+func NewFakeCamera(streamPath string, sdp string) *FakeCamera {
+	if sdp == "" {
+		sdp = DefaultSDP
+	}
+	return &FakeCamera{
+		StreamPath:  streamPath,
+		SDP:         sdp,
+		FrameRate:   25,
+		NALUs:       DefaultGOP,
+		PayloadType: 96,
+		Dial:        PipeDialer(rtsp.GetServer()),
+		ssrc:        rand.Uint32(),
+		done:        make(chan struct{}),
+	}
+}
+
+// Start publishes the stream and returns once the server accepted it.
+func (camera *FakeCamera) Start() (err error) {
+	c, err := camera.Dial()
+	if err != nil {
+		return
+	}
+	camera.conn = newConn(c)
+	defer func() {
+		if err != nil {
+			camera.conn.Close()
+		}
+	}()
+	url := "rtsp://rtsptest" + camera.StreamPath
+	if _, err = camera.conn.request(rtsp.ANNOUNCE, url, map[string]string{"Content-Type": "application/sdp"}, camera.SDP); err != nil {
+		return
+	}
+	control := ""
+	if info, ok := sdp.ParseInfos(camera.SDP)["video"]; ok {
+		control = info.Control
+	}
+	if _, err = camera.conn.request(rtsp.SETUP, controlURL(url, control), map[string]string{"Transport": "RTP/AVP/TCP;unicast;interleaved=0-1;mode=record"}, ""); err != nil {
+		return
+	}
+	if _, err = camera.conn.request(rtsp.RECORD, url, nil, ""); err != nil {
+		return
+	}
+	if camera.FrameRate >= 0 {
+		camera.wg.Add(1)
+		go camera.push()
+	}
+	camera.wg.Add(1)
+	go camera.drain()
+	return
+}
+
+// SendFrames sends n frames right away and returns the number of RTP packets
+// sent. The camera must have a negative FrameRate, it sends nothing else.
+func (camera *FakeCamera) SendFrames(n int) (packets int, err error) {
+	for i := 0; i < n && err == nil; i++ {
+		var sent int
+		sent, err = camera.sendFrame()
+		packets += sent
+	}
+	return
+}
+
+// FramesSent returns the number of frames sent so far.
+func (camera *FakeCamera) FramesSent() int {
+	return int(atomic.LoadUint64(&camera.frames))
+}
+
+// Close stops the stream and closes the connection, the server then removes
+// the pusher.
+func (camera *FakeCamera) Close() (err error) {
+	camera.closeOnce.Do(func() {
+		close(camera.done)
+		if camera.conn != nil {
+			err = camera.conn.Close()
+		}
+		camera.wg.Wait()
+	})
+	return
+}
+
+func (camera *FakeCamera) push() {
+	defer camera.wg.Done()
+	frameRate := camera.FrameRate
+	if frameRate == 0 {
+		frameRate = 25
+	}
+	ticker := time.NewTicker(time.Second / time.Duration(frameRate))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-camera.done:
+			return
+		}
+		if _, err := camera.sendFrame(); err != nil {
+			return
+		}
+	}
+}
+
+// sendFrame sends the next slice of NALUs, with the parameter sets before it
+// and sharing its timestamp.
+func (camera *FakeCamera) sendFrame() (packets int, err error) {
+	seq := camera.seq
+	for n := 0; n < len(camera.NALUs); n++ {
+		nalu := camera.NALUs[camera.next%len(camera.NALUs)]
+		camera.next++
+		last := len(nalu) == 0 || (nalu[0]&0x1f != 7 && nalu[0]&0x1f != 8)
+		if err = camera.sendNALU(nalu, last); err != nil || last {
+			break
+		}
+	}
+	packets = int(camera.seq - seq)
+	if err != nil {
+		return
+	}
+	atomic.AddUint64(&camera.frames, 1)
+	frameRate := camera.FrameRate
+	if frameRate <= 0 {
+		frameRate = 25
+	}
+	camera.timestamp += uint32(90000 / frameRate)
+	return
+}
+
+// drain reads what the server sends, e.g. forwarded key frame requests, so
+// the server is never blocked writing to the pipe.
+func (camera *FakeCamera) drain() {
+	defer camera.wg.Done()
+	for {
+		if _, _, err := camera.conn.readInterleaved(); err != nil {
+			return
+		}
+	}
+}
+
+// sendNALU sends nalu in a single NAL unit packet, or in FU-A fragments if it
+// does not fit. marker is set on the last packet of the frame.
+func (camera *FakeCamera) sendNALU(nalu []byte, marker bool) error {
+	if len(nalu) <= rtpMTU {
+		return camera.sendRTP(nalu, marker)
+	}
+	indicator := nalu[0]&0xe0 | 28
+	naluType := nalu[0] & 0x1f
+	payload := nalu[1:]
+	for start := true; len(payload) > 0; start = false {
+		n := len(payload)
+		if n > rtpMTU-2 {
+			n = rtpMTU - 2
+		}
+		header := naluType
+		if start {
+			header |= 0x80
+		}
+		end := n == len(payload)
+		if end {
+			header |= 0x40
+		}
+		if err := camera.sendRTP(append([]byte{indicator, header}, payload[:n]...), marker && end); err != nil {
+			return err
+		}
+		payload = payload[n:]
+	}
+	return nil
+}
+
+func (camera *FakeCamera) sendRTP(payload []byte, marker bool) error {
+	pkt := make([]byte, 12, 12+len(payload))
+	pkt[0] = 2 << 6
+	pkt[1] = byte(camera.PayloadType & 0x7f)
+	if marker {
+		pkt[1] |= 0x80
+	}
+	binary.BigEndian.PutUint16(pkt[2:], camera.seq)
+	binary.BigEndian.PutUint32(pkt[4:], camera.timestamp)
+	binary.BigEndian.PutUint32(pkt[8:], camera.ssrc)
+	camera.seq++
+	return camera.conn.writeInterleaved(0, append(pkt, payload...))
+}
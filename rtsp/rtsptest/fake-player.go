@@ -0,0 +1,169 @@
+package rtsptest
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"EasyDarwin/rtsp"
+	"EasyDarwin/rtsp/sdp"
+)
+
+// Packet is an interleaved frame received by a FakePlayer.
+type Packet struct {
+	Channel int
+	Data    []byte
+}
+
+// FakePlayer plays a stream with DESCRIBE, SETUP and PLAY over TCP interleaved
+// transport, the video on channels 0-1 and the audio on 2-3, and keeps every
+// packet it receives unless Discard is set.
+type FakePlayer struct {
+	// host:port of the server, the host of the URLs sent
+	ServerAddr string
+	Dial       Dialer
+	// SDP returned by DESCRIBE
+	SDP string
+	// only count the packets, e.g. for the many players of a benchmark
+	Discard bool
+
+	conn      *conn
+	cond      *sync.Cond
+	packets   []Packet
+	received  int
+	bytes     int64
+	err       error
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+}
+
+// NewFakePlayer returns a player of the server at serverAddr, connecting over
+// TCP. Set Dial to PipeDialer to connect over net.Pipe instead.
+func NewFakePlayer(serverAddr string) *FakePlayer {
+	return &FakePlayer{
+		ServerAddr: serverAddr,
+		Dial:       TCPDialer(serverAddr),
+		cond:       sync.NewCond(&sync.Mutex{}),
+	}
+}
+
+// Play starts playing streamPath and returns once the server answered PLAY.
+func (player *FakePlayer) Play(streamPath string) (err error) {
+	c, err := player.Dial()
+	if err != nil {
+		return
+	}
+	player.conn = newConn(c)
+	defer func() {
+		if err != nil {
+			player.conn.Close()
+		}
+	}()
+	url := fmt.Sprintf("rtsp://%s%s", player.ServerAddr, streamPath)
+	res, err := player.conn.request(rtsp.DESCRIBE, url, map[string]string{"Accept": "application/sdp"}, "")
+	if err != nil {
+		return
+	}
+	player.SDP = res.Body
+	infos := sdp.ParseInfos(res.Body)
+	for i, media := range []string{"video", "audio"} {
+		info, ok := infos[media]
+		if !ok {
+			continue
+		}
+		transport := fmt.Sprintf("RTP/AVP/TCP;unicast;interleaved=%d-%d", 2*i, 2*i+1)
+		if _, err = player.conn.request(rtsp.SETUP, controlURL(url, info.Control), map[string]string{"Transport": transport}, ""); err != nil {
+			return
+		}
+	}
+	if _, err = player.conn.request(rtsp.PLAY, url, nil, ""); err != nil {
+		return
+	}
+	player.wg.Add(1)
+	go player.read()
+	return
+}
+
+func (player *FakePlayer) read() {
+	defer player.wg.Done()
+	for {
+		channel, pkt, err := player.conn.readInterleaved()
+		player.cond.L.Lock()
+		if err != nil {
+			player.err = err
+		} else {
+			player.received++
+			player.bytes += int64(len(pkt))
+			if !player.Discard {
+				player.packets = append(player.packets, Packet{Channel: channel, Data: pkt})
+			}
+		}
+		player.cond.Broadcast()
+		player.cond.L.Unlock()
+		if err != nil {
+			return
+		}
+	}
+}
+
+// Packets returns the packets received so far.
+func (player *FakePlayer) Packets() []Packet {
+	player.cond.L.Lock()
+	defer player.cond.L.Unlock()
+	return append([]Packet(nil), player.packets...)
+}
+
+// Stats returns the number of packets received so far and their size in
+// bytes, discarded packets included.
+func (player *FakePlayer) Stats() (packets int, bytes int64) {
+	player.cond.L.Lock()
+	defer player.cond.L.Unlock()
+	return player.received, player.bytes
+}
+
+// RTP returns the RTP packets of channel received so far, e.g. 0 for the video.
+func (player *FakePlayer) RTP(channel int) [][]byte {
+	var pkts [][]byte
+	for _, pkt := range player.Packets() {
+		if pkt.Channel == channel {
+			pkts = append(pkts, pkt.Data)
+		}
+	}
+	return pkts
+}
+
+// WaitPackets waits until n packets were received. It fails if the
+// connection ends or timeout passes first.
+func (player *FakePlayer) WaitPackets(n int, timeout time.Duration) error {
+	timer := time.AfterFunc(timeout, func() {
+		player.cond.L.Lock()
+		player.cond.Broadcast()
+		player.cond.L.Unlock()
+	})
+	defer timer.Stop()
+	deadline := time.Now().Add(timeout)
+	player.cond.L.Lock()
+	defer player.cond.L.Unlock()
+	for player.received < n {
+		if player.err != nil {
+			return fmt.Errorf("got %d of %d packets: %v", player.received, n, player.err)
+		}
+		if !time.Now().Before(deadline) {
+			return fmt.Errorf("got %d of %d packets in %v", player.received, n, timeout)
+		}
+		player.cond.Wait()
+	}
+	return nil
+}
+
+// Close closes the connection, the server then removes the player.
+func (player *FakePlayer) Close() (err error) {
+	player.closeOnce.Do(func() {
+		if player.conn == nil {
+			return
+		}
+		err = player.conn.Close()
+		player.wg.Wait()
+	})
+	return
+}
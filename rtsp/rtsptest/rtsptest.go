@@ -0,0 +1,214 @@
+// Package rtsptest provides fake cameras and players to exercise an rtsp.Server
+// without real RTSP devices. The fakes speak RTSP over TCP interleaved
+// transport; with PipeDialer they reach the server over net.Pipe, so no socket
+// is opened.
+package rtsptest
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"EasyDarwin/rtsp"
+)
+
+// Closeable is implemented by every fake of the package.
+type Closeable interface {
+	Close() error
+}
+
+// Dialer opens the connection of a fake to the server.
+type Dialer func() (net.Conn, error)
+
+// PipeDialer connects to server over net.Pipe, serving the server end with an
+// rtsp.Session as the server does for an accepted connection. The server must
+// be started.
+func PipeDialer(server *rtsp.Server) Dialer {
+	return func() (net.Conn, error) {
+		client, conn := net.Pipe()
+		session := rtsp.NewSession(server, conn)
+		go session.Start()
+		return client, nil
+	}
+}
+
+// TCPDialer connects to the server at addr, host:port.
+func TCPDialer(addr string) Dialer {
+	return func() (net.Conn, error) {
+		return net.DialTimeout("tcp", addr, 5*time.Second)
+	}
+}
+
+// conn is the client side of an RTSP connection.
+type conn struct {
+	net.Conn
+	rw      *bufio.ReadWriter
+	wlock   sync.Mutex
+	seq     int
+	session string
+}
+
+func newConn(c net.Conn) *conn {
+	return &conn{
+		Conn: c,
+		rw:   bufio.NewReadWriter(bufio.NewReader(c), bufio.NewWriter(c)),
+	}
+}
+
+// request sends a request and reads its response, which must come before any
+// interleaved data.
+func (c *conn) request(method, url string, header map[string]string, body string) (*rtsp.Response, error) {
+	c.seq++
+	req := &rtsp.Request{
+		Method:  method,
+		URL:     url,
+		Version: rtsp.RTSP_VERSION,
+		Header:  map[string]string{"CSeq": strconv.Itoa(c.seq)},
+		Body:    body,
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if c.session != "" {
+		req.Header["Session"] = c.session
+	}
+	if body != "" {
+		req.Header["Content-Length"] = strconv.Itoa(len(body))
+	}
+	c.wlock.Lock()
+	c.rw.WriteString(req.String())
+	err := c.rw.Flush()
+	c.wlock.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	res, err := c.readResponse()
+	if err != nil {
+		return nil, err
+	}
+	if sid, ok := res.Header["Session"].(string); ok && sid != "" {
+		c.session = strings.TrimSpace(strings.SplitN(sid, ";", 2)[0])
+	}
+	if res.StatusCode != 200 {
+		return res, fmt.Errorf("%s %s: %d %s", method, url, res.StatusCode, res.Status)
+	}
+	return res, nil
+}
+
+func (c *conn) readResponse() (*rtsp.Response, error) {
+	line, err := c.rw.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	items := strings.SplitN(strings.TrimSpace(line), " ", 3)
+	if len(items) < 2 || !strings.HasPrefix(items[0], "RTSP/") {
+		return nil, fmt.Errorf("invalid rtsp response %q", line)
+	}
+	res := &rtsp.Response{
+		Version: items[0],
+		Header:  make(map[string]interface{}),
+	}
+	if res.StatusCode, err = strconv.Atoi(items[1]); err != nil {
+		return nil, fmt.Errorf("invalid rtsp response %q", line)
+	}
+	if len(items) == 3 {
+		res.Status = items[2]
+	}
+	header, err := c.readHeader()
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		res.Header[k] = v
+	}
+	if n, _ := strconv.Atoi(header["Content-Length"]); n > 0 {
+		body := make([]byte, n)
+		if _, err = io.ReadFull(c.rw, body); err != nil {
+			return nil, err
+		}
+		res.Body = string(body)
+	}
+	return res, nil
+}
+
+func (c *conn) readHeader() (map[string]string, error) {
+	header := make(map[string]string)
+	for {
+		line, err := c.rw.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			return header, nil
+		}
+		if kv := strings.SplitN(line, ":", 2); len(kv) == 2 {
+			header[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+		}
+	}
+}
+
+// readInterleaved returns the next interleaved frame, skipping RTSP messages
+// sent by the server in between.
+func (c *conn) readInterleaved() (channel int, pkt []byte, err error) {
+	for {
+		var b byte
+		if b, err = c.rw.ReadByte(); err != nil {
+			return
+		}
+		if b != '$' {
+			c.rw.UnreadByte()
+			var header map[string]string
+			if _, err = c.rw.ReadString('\n'); err != nil {
+				return
+			}
+			if header, err = c.readHeader(); err != nil {
+				return
+			}
+			if n, _ := strconv.Atoi(header["Content-Length"]); n > 0 {
+				if _, err = c.rw.Discard(n); err != nil {
+					return
+				}
+			}
+			continue
+		}
+		buf := make([]byte, 3)
+		if _, err = io.ReadFull(c.rw, buf); err != nil {
+			return
+		}
+		channel = int(buf[0])
+		pkt = make([]byte, int(buf[1])<<8|int(buf[2]))
+		_, err = io.ReadFull(c.rw, pkt)
+		return
+	}
+}
+
+func (c *conn) writeInterleaved(channel int, pkt []byte) error {
+	c.wlock.Lock()
+	defer c.wlock.Unlock()
+	c.rw.Write([]byte{'$', byte(channel), byte(len(pkt) >> 8), byte(len(pkt))})
+	c.rw.Write(pkt)
+	return c.rw.Flush()
+}
+
+// controlURL resolves the a=control of a media against the presentation URL.
+func controlURL(base, control string) string {
+	switch {
+	case control == "":
+		return base
+	case strings.HasPrefix(strings.ToLower(control), "rtsp://"):
+		return control
+	}
+	return strings.TrimSuffix(base, "/") + "/" + control
+}
+
+// Ensure the fakes stay Closeable.
+var (
+	_ Closeable = (*FakeCamera)(nil)
+	_ Closeable = (*FakePlayer)(nil)
+)
@@ -0,0 +1,178 @@
+package sdp
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"strconv"
+	"strings"
+)
+
+// Info holds the codec parameters of an audio or a video media.
+type Info struct {
+	AVType             string
+	Codec              string
+	TimeScale          int
+	Control            string
+	Rtpmap             int
+	Config             []byte
+	SpropParameterSets [][]byte
+	PayloadType        int
+	SizeLength         int
+	IndexLength        int
+	// H.265 parameter sets, RFC 7798 sprop-vps, sprop-sps and sprop-pps
+	SpropVPS []byte
+	SpropSPS []byte
+	SpropPPS []byte
+}
+
+// ParseInfos parses text and returns the Info of its audio and video media.
+// It returns an empty map for an invalid description, use Parse to get the
+// error.
+func ParseInfos(text string) map[string]*Info {
+	sess, err := Parse(text)
+	if err != nil {
+		return make(map[string]*Info)
+	}
+	return Infos(sess)
+}
+
+// Infos returns the Info of the first audio and the first video media of
+// sess, by media type. The codec parameters are those of the first format
+// with an rtpmap.
+func Infos(sess *SessionDescription) map[string]*Info {
+	infos := make(map[string]*Info)
+	for _, media := range sess.Media {
+		if media.Type != "audio" && media.Type != "video" {
+			continue
+		}
+		if _, ok := infos[media.Type]; ok {
+			continue
+		}
+		info := &Info{AVType: media.Type}
+		infos[media.Type] = info
+		info.Control = media.Attributes.Get("control")
+		if len(media.Formats) > 0 {
+			info.PayloadType = media.Formats[0].Payload
+		}
+		for _, format := range media.Formats {
+			if format.Name == "" {
+				continue
+			}
+			info.Rtpmap = format.Payload
+			info.TimeScale = format.ClockRate
+			info.Codec = codecName(format.Name)
+			for _, params := range format.Params {
+				parseFmtp(info, params)
+			}
+			break
+		}
+	}
+	return infos
+}
+
+// ScanInfos returns the Info of the audio and video media of text line by
+// line, skipping what it does not understand. It is the fallback for the
+// descriptions of devices that Parse rejects.
+func ScanInfos(text string) map[string]*Info {
+	infos := make(map[string]*Info)
+	var info *Info
+	for _, line := range strings.Split(text, "\n") {
+		typeval := strings.SplitN(strings.TrimSpace(line), "=", 2)
+		if len(typeval) != 2 {
+			continue
+		}
+		switch typeval[0] {
+		case "m":
+			fields := strings.Fields(typeval[1])
+			info = nil
+			if len(fields) == 0 || fields[0] != "audio" && fields[0] != "video" {
+				continue
+			}
+			if _, ok := infos[fields[0]]; ok {
+				continue
+			}
+			info = &Info{AVType: fields[0]}
+			infos[fields[0]] = info
+			if len(fields) >= 4 {
+				info.PayloadType, _ = strconv.Atoi(fields[3])
+			}
+		case "a":
+			if info == nil {
+				continue
+			}
+			keyval := strings.SplitN(typeval[1], ":", 2)
+			if len(keyval) != 2 {
+				continue
+			}
+			switch keyval[0] {
+			case "control":
+				info.Control = strings.TrimSpace(keyval[1])
+			case "rtpmap":
+				// 96 H264/90000
+				fields := strings.Fields(keyval[1])
+				if len(fields) != 2 || info.Codec != "" {
+					continue
+				}
+				info.Rtpmap, _ = strconv.Atoi(fields[0])
+				encoding := strings.Split(fields[1], "/")
+				info.Codec = codecName(encoding[0])
+				if len(encoding) > 1 {
+					info.TimeScale, _ = strconv.Atoi(encoding[1])
+				}
+			case "fmtp":
+				// 96 packetization-mode=1;...
+				fields := strings.SplitN(strings.TrimSpace(keyval[1]), " ", 2)
+				if len(fields) == 2 {
+					parseFmtp(info, fields[1])
+				}
+			}
+		}
+	}
+	return infos
+}
+
+func codecName(encoding string) string {
+	switch strings.ToUpper(encoding) {
+	case "MPEG4-GENERIC":
+		return "aac"
+	case "H264":
+		return "h264"
+	case "H265", "HEVC":
+		return "h265"
+	case "VP8":
+		return "vp8"
+	}
+	return ""
+}
+
+// parseFmtp sets the codec parameters of info from the fmtp parameters,
+// e.g. "packetization-mode=1;sprop-parameter-sets=Z0IAH5WoFAFuQA==,aM48gA==".
+func parseFmtp(info *Info, params string) {
+	for _, field := range strings.Split(params, ";") {
+		keyval := strings.SplitN(field, "=", 2)
+		if len(keyval) != 2 {
+			continue
+		}
+		key := strings.ToLower(strings.TrimSpace(keyval[0]))
+		val := strings.TrimSpace(keyval[1])
+		switch key {
+		case "config":
+			info.Config, _ = hex.DecodeString(val)
+		case "sizelength":
+			info.SizeLength, _ = strconv.Atoi(val)
+		case "indexlength":
+			info.IndexLength, _ = strconv.Atoi(val)
+		case "sprop-vps":
+			info.SpropVPS, _ = base64.StdEncoding.DecodeString(val)
+		case "sprop-sps":
+			info.SpropSPS, _ = base64.StdEncoding.DecodeString(val)
+		case "sprop-pps":
+			info.SpropPPS, _ = base64.StdEncoding.DecodeString(val)
+		case "sprop-parameter-sets":
+			for _, field := range strings.Split(val, ",") {
+				val, _ := base64.StdEncoding.DecodeString(field)
+				info.SpropParameterSets = append(info.SpropParameterSets, val)
+			}
+		}
+	}
+}
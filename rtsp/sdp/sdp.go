@@ -0,0 +1,29 @@
+// Package sdp parses and generates the session descriptions (RFC 4566)
+// exchanged by ANNOUNCE and DESCRIBE, and extracts the codec parameters the
+// server needs from them.
+package sdp
+
+import (
+	gosdp "EasyDarwin/helper/pixelbender/go-sdp/sdp"
+)
+
+// ContentType is the media type of a session description.
+const ContentType = gosdp.ContentType
+
+type (
+	SessionDescription = gosdp.Session
+	MediaDescription   = gosdp.Media
+	Attribute          = gosdp.Attr
+	Attributes         = gosdp.Attributes
+	Format             = gosdp.Format
+	Origin             = gosdp.Origin
+	Connection         = gosdp.Connection
+	Bandwidth          = gosdp.Bandwidth
+	Timing             = gosdp.Timing
+)
+
+// Parse parses text, a complete session description. Use String of the
+// result to encode it back.
+func Parse(text string) (*SessionDescription, error) {
+	return gosdp.ParseString(text)
+}
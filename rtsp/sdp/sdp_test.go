@@ -0,0 +1,101 @@
+package sdp
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const cameraSDP = "v=0\r\n" +
+	"o=- 1 1 IN IP4 192.168.1.64\r\n" +
+	"s=Media Presentation\r\n" +
+	"c=IN IP4 0.0.0.0\r\n" +
+	"b=AS:5050\r\n" +
+	"t=0 0\r\n" +
+	"a=control:*\r\n" +
+	"m=video 0 RTP/AVP 96\r\n" +
+	"a=rtpmap:96 H264/90000\r\n" +
+	"a=fmtp:96 profile-level-id=420029; packetization-mode=1; sprop-parameter-sets=Z00AKpWoHgCJ+WEAAAMAAQAAAwAoIA==,aO48gA==\r\n" +
+	"a=control:trackID=1\r\n" +
+	"m=audio 0 RTP/AVP 97\r\n" +
+	"a=rtpmap:97 MPEG4-GENERIC/44100/2\r\n" +
+	"a=fmtp:97 streamtype=5;profile-level-id=1;mode=AAC-hbr;sizelength=13;indexlength=3;indexdeltalength=3;config=1210\r\n" +
+	"a=control:trackID=2\r\n"
+
+func TestParse(t *testing.T) {
+	sess, err := Parse(cameraSDP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sess.Name != "Media Presentation" || sess.Connection == nil || sess.Connection.Address != "0.0.0.0" {
+		t.Fatalf("session = %+v", sess)
+	}
+	if sess.Bandwidth["AS"] != 5050 || sess.Timing == nil {
+		t.Fatalf("bandwidth %v timing %v", sess.Bandwidth, sess.Timing)
+	}
+	if len(sess.Media) != 2 || sess.Media[0].Type != "video" || sess.Media[1].Attributes.Get("control") != "trackID=2" {
+		t.Fatalf("media = %+v", sess.Media)
+	}
+
+	again, err := Parse(sess.String())
+	if err != nil {
+		t.Fatalf("Parse of String: %v", err)
+	}
+	if again.String() != sess.String() {
+		t.Fatalf("String is not stable:\n%s\n%s", sess.String(), again.String())
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, text := range []string{
+		"",
+		"m=video 0 RTP/AVP 96\r\n",
+		"v=0\r\no=- 1 1 IN IP4 127.0.0.1\r\ns=x\r\nt=0 0\r\nm=video zero RTP/AVP 96\r\n",
+	} {
+		if _, err := Parse(text); err == nil {
+			t.Errorf("Parse(%q) succeeded", text)
+		}
+	}
+}
+
+func checkInfos(t *testing.T, infos map[string]*Info) {
+	video, audio := infos["video"], infos["audio"]
+	if video == nil || audio == nil {
+		t.Fatalf("infos = %v", infos)
+	}
+	if video.Codec != "h264" || video.TimeScale != 90000 || video.Rtpmap != 96 || video.PayloadType != 96 || video.Control != "trackID=1" {
+		t.Fatalf("video = %+v", video)
+	}
+	if len(video.SpropParameterSets) != 2 || video.SpropParameterSets[1][0] != 0x68 {
+		t.Fatalf("sprop-parameter-sets = %x", video.SpropParameterSets)
+	}
+	if audio.Codec != "aac" || audio.TimeScale != 44100 || audio.SizeLength != 13 || audio.IndexLength != 3 || !bytes.Equal(audio.Config, []byte{0x12, 0x10}) {
+		t.Fatalf("audio = %+v", audio)
+	}
+}
+
+func TestInfos(t *testing.T) {
+	checkInfos(t, ParseInfos(cameraSDP))
+	if infos := ParseInfos("garbage"); len(infos) != 0 {
+		t.Fatalf("ParseInfos of garbage = %v", infos)
+	}
+}
+
+func TestInfosH265(t *testing.T) {
+	infos := ParseInfos("v=0\r\no=- 1 1 IN IP4 127.0.0.1\r\ns=x\r\nt=0 0\r\n" +
+		"m=video 0 RTP/AVP 98\r\na=rtpmap:98 H265/90000\r\n" +
+		"a=fmtp:98 sprop-vps=QAEMAf//; sprop-sps=QgEB; sprop-pps=RAHA\r\n")
+	video := infos["video"]
+	if video == nil || video.Codec != "h265" || len(video.SpropVPS) == 0 || len(video.SpropSPS) == 0 || len(video.SpropPPS) == 0 {
+		t.Fatalf("video = %+v", video)
+	}
+}
+
+// Some cameras send a broken session part, their media are still usable.
+func TestScanInfos(t *testing.T) {
+	broken := strings.Replace(cameraSDP, "b=AS:5050", "b=AS:5050kbps", 1)
+	if _, err := Parse(broken); err == nil {
+		t.Fatal("Parse of a broken bandwidth succeeded")
+	}
+	checkInfos(t, ScanInfos(broken))
+}
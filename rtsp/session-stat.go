@@ -0,0 +1,99 @@
+package rtsp
+
+import (
+	"time"
+
+	"EasyDarwin/helper/penggy/EasyGoLib/db"
+	"EasyDarwin/helper/penggy/EasyGoLib/utils"
+	"EasyDarwin/models"
+)
+
+const (
+	DISCONNECT_REASON_NORMAL          = "normal"
+	DISCONNECT_REASON_TIMEOUT         = "timeout"
+	DISCONNECT_REASON_SERVER_SHUTDOWN = "server_shutdown"
+	DISCONNECT_REASON_PUBLISHER_GONE  = "publisher_gone"
+	DISCONNECT_REASON_AUTH_FAILURE    = "auth_failure"
+	DISCONNECT_REASON_BACKPRESSURE    = "backpressure"
+	DISCONNECT_REASON_DATA_CAP        = "data_cap"
+	DISCONNECT_REASON_STALLED         = "stalled"
+)
+
+// SetStopReason records why the session is about to end. The first reason wins.
+// It may be called from any goroutine, e.g. the pusher's dropping a player.
+func (session *Session) SetStopReason(reason string) {
+	session.stopReasonLock.Lock()
+	if session.stopReason == "" {
+		session.stopReason = reason
+	}
+	session.stopReasonLock.Unlock()
+}
+
+func (session *Session) disconnectReason() string {
+	session.stopReasonLock.Lock()
+	stopReason := session.stopReason
+	session.stopReasonLock.Unlock()
+	switch {
+	case session.Server.Stoped:
+		return DISCONNECT_REASON_SERVER_SHUTDOWN
+	case stopReason != "":
+		return stopReason
+	case session.authFailed:
+		return DISCONNECT_REASON_AUTH_FAILURE
+	}
+	return DISCONNECT_REASON_NORMAL
+}
+
+// countRTPLoss counts the gaps in the sequence numbers of the RTP packets a pusher sends us.
+// Only a packet ahead of the highest sequence number seen moves it, so a reordered packet
+// is counted once as lost, when the packet after it arrives first, and does not open the
+// gap again.
+func (session *Session) countRTPLoss(pack *RTPPack) {
+	if pack.Type != RTP_TYPE_AUDIO && pack.Type != RTP_TYPE_VIDEO {
+		return
+	}
+	rtp := ParseRTP(pack.Buffer.Bytes())
+	if rtp == nil {
+		return
+	}
+	seq := uint16(rtp.SequenceNumber)
+	last, ok := session.lastRTPSeqs[pack.Type]
+	if ok && int16(seq-last) <= 0 {
+		return
+	}
+	if ok {
+		session.PacketsLost += int(int16(seq - last - 1))
+	}
+	session.lastRTPSeqs[pack.Type] = seq
+}
+
+// saveStat writes the session to t_session_stat. Sessions that neither pushed nor
+// played are skipped, unless they were turned away by authorization.
+func (session *Session) saveStat() {
+	if utils.Conf().Section("rtsp").Key("session_stat_enable").MustInt(1) == 0 || db.SQLite == nil {
+		return
+	}
+	role := ""
+	switch {
+	case session.Type == SESSION_TYPE_PUSHER && session.Pusher != nil:
+		role = "pub"
+	case session.Type == SESSEION_TYPE_PLAYER && session.Player != nil:
+		role = "sub"
+	case !session.authFailed:
+		return
+	}
+	stat := models.SessionStat{
+		SessionID:        session.ID,
+		StreamPath:       session.Path,
+		ClientIP:         session.clientIP,
+		Role:             role,
+		StartTime:        session.StartAt,
+		EndTime:          time.Now(),
+		BytesTransferred: int64(session.InBytes + session.OutBytes),
+		PacketsLost:      int64(session.PacketsLost),
+		DisconnectReason: session.disconnectReason(),
+	}
+	if err := db.SQLite.Create(&stat).Error; err != nil {
+		session.logger.Printf("save session stat err:%v", err)
+	}
+}
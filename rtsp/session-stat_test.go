@@ -0,0 +1,33 @@
+package rtsp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestCountRTPLoss(t *testing.T) {
+	tests := []struct {
+		name string
+		seqs []uint16
+		lost int
+	}{
+		{"in order", []uint16{1, 2, 3, 4}, 0},
+		{"gap", []uint16{1, 2, 5, 6}, 2},
+		{"reordered", []uint16{1, 3, 2, 4}, 1},
+		{"duplicate", []uint16{1, 2, 2, 3}, 0},
+		{"wraps", []uint16{65534, 65535, 0, 2}, 1},
+	}
+	for _, test := range tests {
+		session := &Session{lastRTPSeqs: make(map[RTPType]uint16)}
+		for _, seq := range test.seqs {
+			b := make([]byte, 13)
+			b[0] = 0x80
+			binary.BigEndian.PutUint16(b[2:], seq)
+			session.countRTPLoss(&RTPPack{Type: RTP_TYPE_VIDEO, Buffer: bytes.NewBuffer(b)})
+		}
+		if session.PacketsLost != test.lost {
+			t.Errorf("%s: %d lost, want %d", test.name, session.PacketsLost, test.lost)
+		}
+	}
+}
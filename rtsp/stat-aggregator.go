@@ -0,0 +1,202 @@
+package rtsp
+
+import (
+	"log"
+	"time"
+
+	"EasyDarwin/models"
+)
+
+type statAggWindow struct {
+	Name     string
+	Duration time.Duration
+}
+
+// STAT_AGG_WINDOWS are the windows of t_stream_agg, finest first.
+var STAT_AGG_WINDOWS = []statAggWindow{
+	{"1m", time.Minute},
+	{"5m", 5 * time.Minute},
+	{"1h", time.Hour},
+}
+
+func IsStatAggWindow(name string) bool {
+	for _, window := range STAT_AGG_WINDOWS {
+		if window.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// StatAggregator writes the traffic of every stream to t_stream_agg when a period
+// of a window ends. A session counts for the periods it overlaps, its bytes spread
+// evenly over its lifetime; sessions still running are taken from the server.
+// Rows older than RetentionDays are pruned every hour.
+type StatAggregator struct {
+	RetentionDays int
+
+	server *Server
+	logger *log.Logger
+	done   chan struct{}
+}
+
+func NewStatAggregator(server *Server, retentionDays int) *StatAggregator {
+	aggregator := &StatAggregator{
+		RetentionDays: retentionDays,
+		server:        server,
+		logger:        server.logger,
+		done:          make(chan struct{}),
+	}
+	go aggregator.run()
+	return aggregator
+}
+
+func (aggregator *StatAggregator) Stop() {
+	close(aggregator.done)
+}
+
+func (aggregator *StatAggregator) run() {
+	for {
+		end := time.Now().Truncate(time.Minute).Add(time.Minute)
+		timer := time.NewTimer(time.Until(end))
+		select {
+		case <-timer.C:
+		case <-aggregator.done:
+			timer.Stop()
+			return
+		}
+		aggregator.Aggregate(end)
+	}
+}
+
+// Aggregate writes the rows of the periods ending at end.
+func (aggregator *StatAggregator) Aggregate(end time.Time) {
+	for i, window := range STAT_AGG_WINDOWS {
+		if !end.Truncate(window.Duration).Equal(end) {
+			continue
+		}
+		start := end.Add(-window.Duration)
+		sessions, err := aggregator.sessions(start, end)
+		if err != nil {
+			aggregator.logger.Printf("stat aggregator find sessions err:%v", err)
+			return
+		}
+		aggs := aggregateSessions(sessions, window, start, end)
+		if i > 0 {
+			// The peak of a window is the highest average of the window below it.
+			finer, err := models.FindStreamAggs("", STAT_AGG_WINDOWS[i-1].Name, start, end)
+			if err != nil {
+				aggregator.logger.Printf("stat aggregator find %s rows err:%v", STAT_AGG_WINDOWS[i-1].Name, err)
+			}
+			peaks := make(map[string]float64)
+			for _, agg := range finer {
+				if agg.AvgBitrateKbps > peaks[agg.StreamPath] {
+					peaks[agg.StreamPath] = agg.AvgBitrateKbps
+				}
+			}
+			for j := range aggs {
+				if peak, ok := peaks[aggs[j].StreamPath]; ok {
+					aggs[j].PeakBitrateKbps = peak
+				}
+			}
+		}
+		if err := models.SaveStreamAggs(window.Name, start, aggs); err != nil {
+			aggregator.logger.Printf("stat aggregator save %s rows err:%v", window.Name, err)
+		}
+	}
+	if aggregator.RetentionDays > 0 && end.Truncate(time.Hour).Equal(end) {
+		if _, err := models.DeleteStreamAggsBefore(end.AddDate(0, 0, -aggregator.RetentionDays)); err != nil {
+			aggregator.logger.Printf("stat aggregator prune err:%v", err)
+		}
+	}
+}
+
+// sessions returns the sessions running at some time in [start, end), both ended
+// ones from t_session_stat and the ones still running.
+func (aggregator *StatAggregator) sessions(start, end time.Time) ([]models.SessionStat, error) {
+	sessions, err := models.FindSessionStatsBetween(start, end)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool, len(sessions))
+	for _, session := range sessions {
+		seen[session.SessionID] = true
+	}
+	now := time.Now()
+	for _, pusher := range aggregator.server.GetPushers() {
+		if !seen[pusher.ID()] {
+			sessions = append(sessions, models.SessionStat{
+				SessionID:        pusher.ID(),
+				StreamPath:       pusher.Path(),
+				Role:             "pub",
+				StartTime:        pusher.StartAt(),
+				EndTime:          now,
+				BytesTransferred: int64(pusher.InBytes() + pusher.OutBytes()),
+			})
+		}
+		for _, player := range pusher.GetPlayers() {
+			if !seen[player.ID] {
+				sessions = append(sessions, models.SessionStat{
+					SessionID:        player.ID,
+					StreamPath:       player.Path,
+					Role:             "sub",
+					StartTime:        player.StartAt,
+					EndTime:          now,
+					BytesTransferred: int64(player.InBytes + player.OutBytes),
+				})
+			}
+		}
+	}
+	return sessions, nil
+}
+
+// aggregateSessions sums the sessions overlapping [start, end) per stream.
+func aggregateSessions(sessions []models.SessionStat, window statAggWindow, start, end time.Time) []models.StreamAgg {
+	aggs := make(map[string]*models.StreamAgg)
+	pubBytes := make(map[string]float64)
+	for _, session := range sessions {
+		if session.Role == "" {
+			continue
+		}
+		from, to := session.StartTime, session.EndTime
+		if from.Before(start) {
+			from = start
+		}
+		if to.After(end) {
+			to = end
+		}
+		overlap := to.Sub(from)
+		if overlap <= 0 {
+			continue
+		}
+		bytes := float64(session.BytesTransferred)
+		if lifetime := session.EndTime.Sub(session.StartTime); lifetime > overlap {
+			bytes = bytes * float64(overlap) / float64(lifetime)
+		}
+		agg, ok := aggs[session.StreamPath]
+		if !ok {
+			agg = &models.StreamAgg{
+				StreamPath:  session.StreamPath,
+				Window:      window.Name,
+				PeriodStart: start,
+			}
+			aggs[session.StreamPath] = agg
+		}
+		agg.TotalBytes += int64(bytes)
+		switch session.Role {
+		case "pub":
+			pubBytes[session.StreamPath] += bytes
+			if kbps := bytes * 8 / 1000 / overlap.Seconds(); kbps > agg.PeakBitrateKbps {
+				agg.PeakBitrateKbps = kbps
+			}
+		case "sub":
+			agg.TotalSubscribers++
+		}
+	}
+	rows := make([]models.StreamAgg, 0, len(aggs))
+	for path, agg := range aggs {
+		agg.AvgBitrateKbps = pubBytes[path] * 8 / 1000 / window.Duration.Seconds()
+		rows = append(rows, *agg)
+	}
+	return rows
+}
@@ -0,0 +1,502 @@
+package rtsp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"EasyDarwin/helper/penggy/EasyGoLib/utils"
+	"EasyDarwin/rtsp/sdp"
+)
+
+// MERGE_INTERVAL is how often the sources of the mergers are checked: a
+// merger starts once all its sources are live, and follows a source that
+// published again.
+const MERGE_INTERVAL = 2 * time.Second
+
+// MergeSource is a source of a StreamMerger, the video or audio track of the
+// stream at Path, or all its tracks when Media is empty.
+type MergeSource struct {
+	Path  string
+	Media string
+}
+
+func (source MergeSource) String() string {
+	if source.Media == "" {
+		return source.Path
+	}
+	return source.Path + ":" + source.Media
+}
+
+// ParseMergeSources parses the sources of a [merge] key, e.g.
+// /cam1:video,/mic1:audio.
+func ParseMergeSources(value string) (sources []MergeSource, err error) {
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		source := MergeSource{Path: item}
+		if i := strings.LastIndex(item, ":"); i >= 0 {
+			switch media := item[i+1:]; media {
+			case "video", "audio":
+				source = MergeSource{Path: item[:i], Media: media}
+			default:
+				return nil, fmt.Errorf("invalid media %q of source %s, video or audio", media, item)
+			}
+		}
+		if !strings.HasPrefix(source.Path, "/") {
+			source.Path = "/" + source.Path
+		}
+		sources = append(sources, source)
+	}
+	if len(sources) < 2 {
+		return nil, fmt.Errorf("a merged stream takes 2 sources or more, got %d", len(sources))
+	}
+	return
+}
+
+// mergeTrack is a track of the merged stream, taken from a source.
+type mergeTrack struct {
+	source    MergeSource
+	media     string // video or audio
+	section   string // m= section of the source SDP, without a=control
+	clockRate int
+	pusher    *Pusher // tapped pusher of the source
+
+	// SSRC of the source and its last SR, the packets are dropped until
+	// the first one
+	inSSRC    uint32
+	seqSynced bool
+	sr        *RTCPSenderReport
+
+	// renumbering into the merged stream
+	ssrc        uint32
+	tsBase      uint32
+	seqOffset   uint16
+	lastSeq     uint16
+	packetCount uint32
+	octetCount  uint32
+}
+
+// reset starts over with a new SSRC of the source, e.g. after it published
+// again: the sequence goes on from the last packet, the timestamps wait for
+// an SR of the new SSRC.
+func (track *mergeTrack) reset(ssrc uint32) {
+	track.inSSRC = ssrc
+	track.seqSynced = false
+	track.sr = nil
+}
+
+// StreamMerger publishes the tracks of several streams as one stream, e.g.
+// the video of a camera with the audio of a microphone. The merged stream is
+// a pusher without a connection at Path, whose SDP has the m= sections of
+// the sources; the packets of the sources are renumbered into it with new
+// SSRCs, and their timestamps are mapped to a common clock with the RTCP SRs
+// of the sources, so the tracks of different sources play in sync as long
+// as the wallclocks of their senders agree, e.g. are synced by NTP. The
+// packets of a track are dropped until its first SR.
+//
+// Like any stream of the server, the merged stream has at most one video and
+// one audio track. It starts once all the sources are live and stays live
+// while a source publishes again with the same media, the players only see
+// a gap; it is restarted when the media of a source change.
+type StreamMerger struct {
+	Path    string
+	Sources []MergeSource
+
+	server *Server
+	logger *log.Logger
+	done   chan struct{}
+
+	lock    sync.Mutex
+	session *Session
+	tracks  []*mergeTrack
+	// NTP time of the first SR of the sources, the origin of the timestamps
+	ntpBase uint64
+	aligned bool
+}
+
+// NewStreamMergers starts a StreamMerger for each key of [merge].
+func NewStreamMergers(server *Server) (mergers []*StreamMerger) {
+	for _, key := range utils.Conf().Section("merge").Keys() {
+		path, err := server.NormalizeStreamName(key.Name())
+		if err != nil {
+			server.logger.Printf("Merge %s err:%v", key.Name(), err)
+			continue
+		}
+		sources, err := ParseMergeSources(key.String())
+		if err != nil {
+			server.logger.Printf("Merge %s err:%v", path, err)
+			continue
+		}
+		merger := &StreamMerger{
+			Path:    path,
+			Sources: sources,
+			server:  server,
+			logger:  server.logger,
+			done:    make(chan struct{}),
+		}
+		go merger.run()
+		mergers = append(mergers, merger)
+	}
+	return
+}
+
+// Stop stops the merger and the merged stream.
+func (merger *StreamMerger) Stop() {
+	close(merger.done)
+}
+
+func (merger *StreamMerger) run() {
+	ticker := time.NewTicker(MERGE_INTERVAL)
+	defer ticker.Stop()
+	for {
+		merger.check()
+		select {
+		case <-ticker.C:
+		case <-merger.done:
+			merger.stop()
+			return
+		}
+	}
+}
+
+// tapID is the ID of the tap of track on the pusher of its source. A
+// pusher whose video and audio are both merged is tapped once per track.
+func (merger *StreamMerger) tapID(track *mergeTrack) string {
+	return "merge:" + merger.Path + ":" + track.media
+}
+
+// check starts the merged stream when the sources are live, and taps the
+// pushers of sources that published again.
+func (merger *StreamMerger) check() {
+	merger.lock.Lock()
+	session := merger.session
+	merger.lock.Unlock()
+	if session != nil && session.Stoped() {
+		// torn down, e.g. by the watchdog
+		merger.stop()
+		session = nil
+	}
+
+	pushers := make(map[string]*Pusher, len(merger.Sources))
+	for _, source := range merger.Sources {
+		if pusher := merger.server.GetPusher(source.Path); pusher != nil {
+			pushers[source.Path] = pusher
+		}
+	}
+	if session == nil {
+		if len(pushers) == len(merger.Sources) {
+			merger.start(pushers)
+		}
+		return
+	}
+
+	for _, track := range merger.tracks {
+		pusher := pushers[track.source.Path]
+		if pusher == nil || pusher == track.pusher {
+			continue
+		}
+		tracks, err := mergeTracks(track.source, pusher.SDPRaw())
+		if err != nil || !hasMergeTrack(tracks, track) {
+			merger.logger.Printf("%v source %s changed, restart it", session, track.source)
+			merger.stop()
+			return
+		}
+		track.pusher.RemoveTap(merger.tapID(track))
+		merger.lock.Lock()
+		track.pusher = pusher
+		merger.lock.Unlock()
+		merger.tap(track)
+	}
+}
+
+// start publishes the merged stream of the pushers of the sources.
+func (merger *StreamMerger) start(pushers map[string]*Pusher) {
+	var tracks []*mergeTrack
+	for _, source := range merger.Sources {
+		sourceTracks, err := mergeTracks(source, pushers[source.Path].SDPRaw())
+		if err != nil {
+			merger.logger.Printf("Merge %s err:%v", merger.Path, err)
+			return
+		}
+		for _, track := range sourceTracks {
+			for _, other := range tracks {
+				if other.media == track.media {
+					merger.logger.Printf("Merge %s err:%s and %s both have %s, a stream has one video and one audio track",
+						merger.Path, other.source, track.source, track.media)
+					return
+				}
+			}
+			track.pusher = pushers[source.Path]
+			tracks = append(tracks, track)
+		}
+	}
+
+	session := newSession(merger.server)
+	session.Type = SESSION_TYPE_PUSHER
+	session.TransType = TRANS_TYPE_MERGE
+	session.URL = "merge://" + strings.TrimPrefix(merger.Path, "/")
+	session.Path = merger.Path
+	session.RawPath = merger.Path
+	session.clientIP = "127.0.0.1"
+	session.Merger = merger
+	session.SDPRaw = mergeSDP(tracks)
+	var err error
+	if session.Sdp, err = sdp.Parse(session.SDPRaw); err != nil {
+		merger.logger.Printf("Merge %s err:%v", merger.Path, err)
+		return
+	}
+	session.SDPMap = sdp.Infos(session.Sdp)
+	if info, ok := session.SDPMap["video"]; ok {
+		session.VControl = info.Control
+		session.VCodec = info.Codec
+	}
+	if info, ok := session.SDPMap["audio"]; ok {
+		session.AControl = info.Control
+		session.ACodec = info.Codec
+	}
+	for _, track := range tracks {
+		track.ssrc = rand.Uint32()
+		track.tsBase = rand.Uint32()
+		track.lastSeq = uint16(rand.Uint32())
+	}
+
+	session.Pusher = NewPusher(session)
+	if !merger.server.AddPusher(session.Pusher) {
+		merger.logger.Printf("%v merge %s rejected, the path has a pusher", session, merger.Path)
+		return
+	}
+	merger.lock.Lock()
+	merger.session = session
+	merger.tracks = tracks
+	merger.aligned = false
+	merger.lock.Unlock()
+	for _, track := range tracks {
+		merger.tap(track)
+	}
+	merger.logger.Printf("%v merge %v into %s", session, merger.Sources, merger.Path)
+}
+
+// stop untaps the sources and stops the merged stream.
+func (merger *StreamMerger) stop() {
+	merger.lock.Lock()
+	session, tracks := merger.session, merger.tracks
+	merger.session, merger.tracks = nil, nil
+	merger.lock.Unlock()
+	for _, track := range tracks {
+		track.pusher.RemoveTap(merger.tapID(track))
+	}
+	if session != nil {
+		session.Stop()
+	}
+}
+
+// tap relays the packets of the track from its pusher.
+func (merger *StreamMerger) tap(track *mergeTrack) {
+	pusher := track.pusher
+	pusher.AddTap(merger.tapID(track), func(pack *RTPPack) {
+		merger.relay(track, pusher, pack)
+	})
+}
+
+// relay renumbers a packet of the source of track into the merged stream.
+func (merger *StreamMerger) relay(track *mergeTrack, pusher *Pusher, pack *RTPPack) {
+	var control bool
+	switch {
+	case track.media == "video" && pack.Type == RTP_TYPE_VIDEO, track.media == "audio" && pack.Type == RTP_TYPE_AUDIO:
+	case track.media == "video" && pack.Type == RTP_TYPE_VIDEOCONTROL, track.media == "audio" && pack.Type == RTP_TYPE_AUDIOCONTROL:
+		control = true
+	default:
+		return
+	}
+	merger.lock.Lock()
+	defer merger.lock.Unlock()
+	session := merger.session
+	if session == nil || track.pusher != pusher {
+		return
+	}
+	buf := pack.Buffer.Bytes()
+
+	if control {
+		sr := ParseRTCPSenderReport(buf)
+		if sr == nil {
+			// the other reports are about the source, not the merged stream
+			return
+		}
+		if sr.SSRC != track.inSSRC {
+			track.reset(sr.SSRC)
+		}
+		if track.sr == nil && track.media == "video" {
+			// the packets before the first SR were dropped
+			go pusher.RequestKeyFrame(0, sr.SSRC)
+		}
+		track.sr = sr
+		if !merger.aligned {
+			merger.ntpBase = sr.NTPTime
+			merger.aligned = true
+		}
+		merger.send(session, pack.Type, NewRTCPSR(RTCPSenderReport{
+			SSRC:        track.ssrc,
+			NTPTime:     sr.NTPTime,
+			RTPTime:     merger.mapTS(track, sr.RTPTime),
+			PacketCount: track.packetCount,
+			OctetCount:  track.octetCount,
+		}))
+		return
+	}
+
+	if len(buf) < RTP_FIXED_HEADER_LENGTH {
+		return
+	}
+	seq := binary.BigEndian.Uint16(buf[2:])
+	ts := binary.BigEndian.Uint32(buf[4:])
+	if ssrc := binary.BigEndian.Uint32(buf[8:]); ssrc != track.inSSRC {
+		track.reset(ssrc)
+	}
+	if track.sr == nil {
+		return
+	}
+	if !track.seqSynced {
+		track.seqOffset = track.lastSeq + 1 - seq
+		track.seqSynced = true
+	}
+	pkt := append([]byte(nil), buf...)
+	track.lastSeq = seq + track.seqOffset
+	binary.BigEndian.PutUint16(pkt[2:], track.lastSeq)
+	binary.BigEndian.PutUint32(pkt[4:], merger.mapTS(track, ts))
+	binary.BigEndian.PutUint32(pkt[8:], track.ssrc)
+	track.packetCount++
+	track.octetCount += uint32(len(pkt) - RTP_FIXED_HEADER_LENGTH)
+	merger.send(session, pack.Type, pkt)
+}
+
+// mapTS maps an RTP timestamp of the source of track to the merged stream:
+// the wallclock of ts, from the last SR of the source, since ntpBase, in the
+// clock rate of the track, after tsBase.
+func (merger *StreamMerger) mapTS(track *mergeTrack, ts uint32) uint32 {
+	rate := float64(track.clockRate)
+	seconds := float64(int64(track.sr.NTPTime-merger.ntpBase))/(1<<32) + float64(int32(ts-track.sr.RTPTime))/rate
+	return track.tsBase + uint32(int64(math.Round(seconds*rate)))
+}
+
+func (merger *StreamMerger) send(session *Session, rtpType RTPType, pkt []byte) {
+	session.InBytes += len(pkt)
+	pack := &RTPPack{
+		Type:   rtpType,
+		Buffer: bytes.NewBuffer(pkt),
+	}
+	for _, h := range session.RTPHandles {
+		h(pack)
+	}
+}
+
+// sendVideoControl forwards the key frame requests of the players of the
+// merged stream to the source of the video. The other feedback is dropped,
+// e.g. a REMB about the merged stream.
+func (merger *StreamMerger) sendVideoControl(rtcpBytes []byte) error {
+	if ParseRTCPFeedback(rtcpBytes, RTCP_PSFB_FMT_PLI) == nil && ParseRTCPFeedback(rtcpBytes, RTCP_PSFB_FMT_FIR) == nil {
+		return nil
+	}
+	merger.lock.Lock()
+	var pusher *Pusher
+	var ssrc uint32
+	for _, track := range merger.tracks {
+		if track.media == "video" {
+			pusher, ssrc = track.pusher, track.inSSRC
+		}
+	}
+	merger.lock.Unlock()
+	if pusher == nil {
+		return nil
+	}
+	return pusher.RequestKeyFrame(0, ssrc)
+}
+
+// mergeTracks returns the tracks of source in the SDP of its stream.
+func mergeTracks(source MergeSource, sdpRaw string) (tracks []*mergeTrack, err error) {
+	parsed, err := sdp.Parse(sdpRaw)
+	if err != nil {
+		return
+	}
+	infos := sdp.Infos(parsed)
+	for _, section := range sdpMediaSections(sdpRaw) {
+		media := strings.TrimPrefix(strings.Fields(section[0])[0], "m=")
+		if media != "video" && media != "audio" || source.Media != "" && media != source.Media {
+			continue
+		}
+		info := infos[media]
+		if info == nil || info.TimeScale <= 0 {
+			return nil, fmt.Errorf("source %s has no clock rate for %s", source, media)
+		}
+		lines := make([]string, 0, len(section))
+		for _, line := range section {
+			if !strings.HasPrefix(line, "a=control:") {
+				lines = append(lines, line)
+			}
+		}
+		for _, track := range tracks {
+			if track.media == media {
+				return nil, fmt.Errorf("source %s has several %s tracks", source, media)
+			}
+		}
+		tracks = append(tracks, &mergeTrack{
+			source:    source,
+			media:     media,
+			section:   strings.Join(lines, "\r\n"),
+			clockRate: info.TimeScale,
+		})
+	}
+	if len(tracks) == 0 {
+		return nil, fmt.Errorf("source %s has no track to merge", source)
+	}
+	return
+}
+
+// hasMergeTrack tells whether tracks have the media of track unchanged.
+func hasMergeTrack(tracks []*mergeTrack, track *mergeTrack) bool {
+	for _, t := range tracks {
+		if t.media == track.media && t.section == track.section {
+			return true
+		}
+	}
+	return false
+}
+
+// sdpMediaSections returns the lines of each m= section of an SDP.
+func sdpMediaSections(sdpRaw string) (sections [][]string) {
+	for _, line := range strings.Split(sdpRaw, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.HasPrefix(line, "m=") {
+			sections = append(sections, nil)
+		}
+		if line == "" || len(sections) == 0 {
+			continue
+		}
+		sections[len(sections)-1] = append(sections[len(sections)-1], line)
+	}
+	return
+}
+
+// mergeSDP returns the SDP of the merged stream, the m= sections of the
+// tracks with the controls streamid=0, 1...
+func mergeSDP(tracks []*mergeTrack) string {
+	lines := []string{
+		"v=0",
+		"o=- 0 0 IN IP4 127.0.0.1",
+		"s=Merge",
+		"c=IN IP4 0.0.0.0",
+		"t=0 0",
+	}
+	for i, track := range tracks {
+		lines = append(lines, track.section, fmt.Sprintf("a=control:streamid=%d", i))
+	}
+	return strings.Join(lines, "\r\n") + "\r\n"
+}
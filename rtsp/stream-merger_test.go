@@ -0,0 +1,163 @@
+package rtsp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// ntpTime returns seconds as an NTP timestamp, 32.32 fixed point.
+func ntpTime(seconds float64) uint64 {
+	return uint64(seconds * (1 << 32))
+}
+
+func newMergeRTP(seq uint16, ts, ssrc uint32) []byte {
+	pkt := make([]byte, RTP_FIXED_HEADER_LENGTH+1)
+	pkt[0] = 0x80
+	pkt[1] = 96
+	binary.BigEndian.PutUint16(pkt[2:], seq)
+	binary.BigEndian.PutUint32(pkt[4:], ts)
+	binary.BigEndian.PutUint32(pkt[8:], ssrc)
+	return pkt
+}
+
+// mergeTest relays the packets of the sources of a merger whose merged
+// session keeps what it is sent.
+type mergeTest struct {
+	merger *StreamMerger
+	out    []*RTPPack
+}
+
+func newMergeTest(tracks ...*mergeTrack) *mergeTest {
+	test := &mergeTest{}
+	session := newSession(GetServer())
+	session.RTPHandles = append(session.RTPHandles, func(pack *RTPPack) {
+		test.out = append(test.out, pack)
+	})
+	test.merger = &StreamMerger{Path: "/test/merge", server: GetServer(), logger: GetServer().logger, session: session, tracks: tracks}
+	return test
+}
+
+// newMergeTrack returns a track of the merged stream with fixed bases, for
+// the source pusher at path.
+func newMergeTrack(path, media string, clockRate int, tsBase uint32) *mergeTrack {
+	pusher := newTestPusher(path)
+	// a channel takes no feedback, e.g. the key frame request of the first SR
+	pusher.Session.TransType = TRANS_TYPE_CHANNEL
+	return &mergeTrack{
+		source:    MergeSource{Path: path, Media: media},
+		media:     media,
+		clockRate: clockRate,
+		pusher:    pusher,
+		ssrc:      0x5eed0000 + uint32(clockRate),
+		tsBase:    tsBase,
+		lastSeq:   99,
+	}
+}
+
+func (test *mergeTest) sr(track *mergeTrack, ssrc uint32, ntp uint64, rtpTime uint32) {
+	rtpType := RTP_TYPE_VIDEOCONTROL
+	if track.media == "audio" {
+		rtpType = RTP_TYPE_AUDIOCONTROL
+	}
+	pkt := NewRTCPSR(RTCPSenderReport{SSRC: ssrc, NTPTime: ntp, RTPTime: rtpTime})
+	test.merger.relay(track, track.pusher, &RTPPack{Type: rtpType, Buffer: bytes.NewBuffer(pkt)})
+}
+
+// rtp relays a packet and returns the one sent to the merged stream, nil if
+// it was dropped.
+func (test *mergeTest) rtp(track *mergeTrack, seq uint16, ts, ssrc uint32) []byte {
+	rtpType := RTP_TYPE_VIDEO
+	if track.media == "audio" {
+		rtpType = RTP_TYPE_AUDIO
+	}
+	sent := len(test.out)
+	test.merger.relay(track, track.pusher, &RTPPack{Type: rtpType, Buffer: bytes.NewBuffer(newMergeRTP(seq, ts, ssrc))})
+	if len(test.out) == sent {
+		return nil
+	}
+	return test.out[len(test.out)-1].Buffer.Bytes()
+}
+
+func TestStreamMergerAlignsSources(t *testing.T) {
+	video := newMergeTrack("/test/merge-cam", "video", 90000, 1000)
+	audio := newMergeTrack("/test/merge-mic", "audio", 48000, 0xfffff000)
+	test := newMergeTest(video, audio)
+
+	if pkt := test.rtp(video, 4999, 410000, 0xaaaa); pkt != nil {
+		t.Fatal("packet before the first SR relayed")
+	}
+	// the clocks of the sources start at unrelated values, their SRs half a
+	// second apart; the first SR is the origin of the merged timestamps
+	test.sr(video, 0xaaaa, ntpTime(1000), 500000)
+	test.sr(audio, 0xbbbb, ntpTime(1000.5), 7000)
+	if mergedSR := ParseRTCPSenderReport(test.out[1].Buffer.Bytes()); mergedSR.RTPTime != audio.tsBase+24000 || mergedSR.SSRC != audio.ssrc {
+		t.Errorf("merged audio SR %+v", mergedSR)
+	}
+
+	// captured at 1001s by both
+	pkt := test.rtp(video, 5000, 500000+90000, 0xaaaa)
+	if ts := binary.BigEndian.Uint32(pkt[4:]); ts != video.tsBase+90000 {
+		t.Errorf("video ts %d, want %d", ts, video.tsBase+90000)
+	}
+	if seq, ssrc := binary.BigEndian.Uint16(pkt[2:]), binary.BigEndian.Uint32(pkt[8:]); seq != 100 || ssrc != video.ssrc {
+		t.Errorf("video seq %d ssrc %x", seq, ssrc)
+	}
+	pkt = test.rtp(audio, 20, 7000+24000, 0xbbbb)
+	if ts := binary.BigEndian.Uint32(pkt[4:]); ts != audio.tsBase+48000 {
+		t.Errorf("audio ts %d, want %d", ts, audio.tsBase+48000)
+	}
+	if seq := binary.BigEndian.Uint16(pkt[2:]); seq != 100 {
+		t.Errorf("audio seq %d", seq)
+	}
+}
+
+func TestStreamMergerSRBeforeBase(t *testing.T) {
+	video := newMergeTrack("/test/merge-cam-late", "video", 90000, 1000)
+	audio := newMergeTrack("/test/merge-mic-early", "audio", 48000, 1000)
+	test := newMergeTest(video, audio)
+
+	test.sr(video, 0xaaaa, ntpTime(1000), 500000)
+	// the first SR of the audio is 1s older than the origin
+	test.sr(audio, 0xbbbb, ntpTime(999), 7000)
+	for _, c := range []struct {
+		name string
+		ts   uint32
+		want uint32
+	}{
+		{"after the origin", 7000 + 2*48000, audio.tsBase + 48000},
+		{"before the origin", 7000 + 24000, audio.tsBase - 24000},
+	} {
+		pkt := test.rtp(audio, 1, c.ts, 0xbbbb)
+		if ts := binary.BigEndian.Uint32(pkt[4:]); ts != c.want {
+			t.Errorf("%s: ts %d, want %d", c.name, ts, c.want)
+		}
+	}
+}
+
+func TestStreamMergerSourceRestart(t *testing.T) {
+	video := newMergeTrack("/test/merge-cam-restart", "video", 90000, 1000)
+	test := newMergeTest(video)
+
+	test.sr(video, 0xaaaa, ntpTime(1000), 500000)
+	test.rtp(video, 5000, 500000, 0xaaaa)
+	test.rtp(video, 5001, 503000, 0xaaaa)
+
+	// published again with a new SSRC and sequence
+	if pkt := test.rtp(video, 7, 0, 0xcccc); pkt != nil {
+		t.Fatal("packet of the new SSRC relayed before its SR")
+	}
+	test.sr(video, 0xcccc, ntpTime(1010), 0)
+	for i, want := range []uint16{102, 103} {
+		pkt := test.rtp(video, 7+uint16(i), uint32(i)*3000, 0xcccc)
+		if seq := binary.BigEndian.Uint16(pkt[2:]); seq != want {
+			t.Errorf("seq %d after the restart, want %d", seq, want)
+		}
+		if ts, want := binary.BigEndian.Uint32(pkt[4:]), video.tsBase+10*90000+uint32(i)*3000; ts != want {
+			t.Errorf("ts %d after the restart, want %d", ts, want)
+		}
+		if ssrc := binary.BigEndian.Uint32(pkt[8:]); ssrc != video.ssrc {
+			t.Errorf("ssrc %x after the restart", ssrc)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package rtsp
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"EasyDarwin/helper/penggy/EasyGoLib/utils"
+)
+
+type ReplacePattern struct {
+	Regex       string
+	Replacement string
+}
+
+// StreamNameNormalizer maps the stream names sent by encoders to one canonical form,
+// so that "/Live/Cam1" and "/live/cam1" end up on the same pusher. The rules run in
+// field order on the path without its leading "/".
+type StreamNameNormalizer struct {
+	LowerCase      bool
+	TrimPrefix     string
+	ReplacePattern ReplacePattern
+
+	regex *regexp.Regexp
+}
+
+// NewStreamNameNormalizer reads the rules from the [stream_name] section.
+func NewStreamNameNormalizer() (normalizer *StreamNameNormalizer, err error) {
+	sec := utils.Conf().Section("stream_name")
+	normalizer = &StreamNameNormalizer{
+		LowerCase:  sec.Key("lowercase").MustBool(false),
+		TrimPrefix: sec.Key("trim_prefix").MustString(""),
+		ReplacePattern: ReplacePattern{
+			Regex:       sec.Key("replace_regex").MustString(""),
+			Replacement: sec.Key("replace_with").MustString(""),
+		},
+	}
+	if normalizer.ReplacePattern.Regex != "" {
+		if normalizer.regex, err = regexp.Compile(normalizer.ReplacePattern.Regex); err != nil {
+			return nil, err
+		}
+	}
+	return
+}
+
+// Normalize returns the canonical "/name" for a stream. A nil normalizer only validates.
+func (normalizer *StreamNameNormalizer) Normalize(rawName string) (string, error) {
+	name := strings.TrimPrefix(rawName, "/")
+	if normalizer != nil {
+		if normalizer.TrimPrefix != "" {
+			name = strings.TrimPrefix(name, strings.TrimPrefix(normalizer.TrimPrefix, "/"))
+		}
+		if normalizer.LowerCase {
+			name = strings.ToLower(name)
+		}
+		if normalizer.regex != nil {
+			name = normalizer.regex.ReplaceAllString(name, normalizer.ReplacePattern.Replacement)
+		}
+		name = strings.TrimPrefix(name, "/")
+	}
+	if name == "" || strings.Contains(name, "..") {
+		return "", fmt.Errorf("invalid stream name[%s]", rawName)
+	}
+	return "/" + name, nil
+}
@@ -0,0 +1,134 @@
+package rtsp
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"EasyDarwin/helper/penggy/EasyGoLib/utils"
+	"EasyDarwin/models"
+)
+
+// The runtime parameters of a stream are kept in the Metadata of its row in
+// t_streams, as "name: value" lines among the free text, so that they outlive
+// the publisher and show in the stream list.
+var streamParamsLock sync.Mutex
+
+// parseParameterNames parses a GET_PARAMETER body, one parameter name per line.
+func parseParameterNames(body string) (names []string) {
+	for _, line := range strings.Split(body, "\n") {
+		if name := strings.TrimSpace(line); name != "" {
+			names = append(names, name)
+		}
+	}
+	return
+}
+
+// parseParameters parses a SET_PARAMETER body of "name: value" lines.
+// ok is false if a line has no ':'.
+func parseParameters(body string) (params map[string]string, ok bool) {
+	params = make(map[string]string)
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		idx := strings.Index(line, ":")
+		if idx <= 0 {
+			return nil, false
+		}
+		params[strings.TrimSpace(line[:idx])] = strings.TrimSpace(line[idx+1:])
+	}
+	return params, true
+}
+
+// streamParameterAllowed checks name against rtsp.stream_parameters.
+// An empty list allows any name.
+func streamParameterAllowed(name string) bool {
+	allowed := utils.Conf().Section("rtsp").Key("stream_parameters").Strings(",")
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, v := range allowed {
+		if v == name {
+			return true
+		}
+	}
+	return false
+}
+
+// cutParameter splits a "name: value" line of metadata, ok is false for other
+// lines.
+func cutParameter(line string) (name, value string, ok bool) {
+	idx := strings.Index(line, ":")
+	if idx <= 0 {
+		return "", "", false
+	}
+	return strings.TrimSpace(line[:idx]), strings.TrimSpace(line[idx+1:]), true
+}
+
+// streamParameter returns the value of the parameter name in metadata.
+func streamParameter(metadata, name string) (value string, ok bool) {
+	for _, line := range strings.Split(metadata, "\n") {
+		if _name, value, ok := cutParameter(line); ok && _name == name {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// setStreamParameters sets params in metadata, replacing the line of a
+// parameter already there and appending the others. It returns the new
+// metadata and the parameters whose value changed.
+func setStreamParameters(metadata string, params map[string]string) (string, map[string]string) {
+	var lines []string
+	if metadata != "" {
+		lines = strings.Split(metadata, "\n")
+	}
+	changed := make(map[string]string)
+	found := make(map[string]bool)
+	for i, line := range lines {
+		name, value, ok := cutParameter(line)
+		if !ok || found[name] {
+			continue
+		}
+		newValue, ok := params[name]
+		if !ok {
+			continue
+		}
+		found[name] = true
+		if newValue != value {
+			lines[i] = name + ": " + newValue
+			changed[name] = newValue
+		}
+	}
+	names := make([]string, 0, len(params))
+	for name := range params {
+		if !found[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		lines = append(lines, name+": "+params[name])
+		changed[name] = params[name]
+	}
+	return strings.Join(lines, "\n"), changed
+}
+
+// updateStreamParameters sets params in the metadata of the stream at path and
+// returns the ones whose value changed. found is false when t_streams has no
+// stream at path.
+func updateStreamParameters(path string, params map[string]string) (changed map[string]string, found bool, err error) {
+	streamParamsLock.Lock()
+	defer streamParamsLock.Unlock()
+	metadata, found, err := models.FindStreamMetadata(path)
+	if err != nil || !found {
+		return
+	}
+	metadata, changed = setStreamParameters(metadata, params)
+	if len(changed) > 0 {
+		err = models.UpdateStreamMetadata(path, metadata)
+	}
+	return
+}
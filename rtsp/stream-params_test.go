@@ -0,0 +1,55 @@
+package rtsp
+
+import (
+	"reflect"
+	"testing"
+
+	"EasyDarwin/helper/penggy/EasyGoLib/db"
+	"EasyDarwin/models"
+)
+
+func TestSetStreamParameters(t *testing.T) {
+	tests := []struct {
+		metadata string
+		params   map[string]string
+		want     string
+		changed  map[string]string
+	}{
+		{"", map[string]string{"bitrate": "2000"}, "bitrate: 2000", map[string]string{"bitrate": "2000"}},
+		{"lobby camera\nbitrate: 2000", map[string]string{"bitrate": "1500", "temperature": "41"},
+			"lobby camera\nbitrate: 1500\ntemperature: 41", map[string]string{"bitrate": "1500", "temperature": "41"}},
+		{"bitrate: 2000\ntemperature: 41", map[string]string{"temperature": "41"},
+			"bitrate: 2000\ntemperature: 41", map[string]string{}},
+	}
+	for _, test := range tests {
+		metadata, changed := setStreamParameters(test.metadata, test.params)
+		if metadata != test.want || !reflect.DeepEqual(changed, test.changed) {
+			t.Errorf("%q set %v: got %q %v, want %q %v", test.metadata, test.params, metadata, changed, test.want, test.changed)
+		}
+	}
+	for name, want := range map[string]string{"bitrate": "1500", "temperature": "41", "lobby camera": ""} {
+		if value, _ := streamParameter("lobby camera\nbitrate: 1500\ntemperature: 41", name); value != want {
+			t.Errorf("%s = %q, want %q", name, value, want)
+		}
+	}
+}
+
+func TestUpdateStreamParameters(t *testing.T) {
+	if _, found, err := updateStreamParameters("/test/params-none", map[string]string{"bitrate": "2000"}); err != nil || found {
+		t.Fatalf("stream not in t_streams: found %v err %v", found, err)
+	}
+
+	stream := models.Stream{URL: "rtsp://10.0.0.1/params", CustomPath: "/test/params", Metadata: "lobby camera"}
+	if err := db.SQLite.Create(&stream).Error; err != nil {
+		t.Fatal(err)
+	}
+	defer db.SQLite.Delete(&stream)
+	changed, found, err := updateStreamParameters("/test/params", map[string]string{"bitrate": "2000"})
+	if err != nil || !found || changed["bitrate"] != "2000" {
+		t.Fatalf("changed %v found %v err %v", changed, found, err)
+	}
+	metadata, _, err := models.FindStreamMetadata("/test/params")
+	if err != nil || metadata != "lobby camera\nbitrate: 2000" {
+		t.Errorf("metadata %q err %v", metadata, err)
+	}
+}
@@ -20,6 +20,9 @@ type UDPClient struct {
 	VControlPort int
 	VControlConn *net.UDPConn
 
+	// Mux sends RTCP on the RTP conn of each media (RFC 5761).
+	Mux bool
+
 	Stoped bool
 }
 
@@ -73,6 +76,9 @@ func (c *UDPClient) SetupAudio() (err error) {
 	if err = c.AConn.SetWriteBuffer(networkBuffer); err != nil {
 		logger.Printf("udp client audio conn set write buffer error, %v", err)
 	}
+	if c.Mux {
+		return
+	}
 
 	addr, err = net.ResolveUDPAddr("udp", fmt.Sprintf("%s:%d", host, c.AControlPort))
 	if err != nil {
@@ -117,6 +123,9 @@ func (c *UDPClient) SetupVideo() (err error) {
 	if err = c.VConn.SetWriteBuffer(networkBuffer); err != nil {
 		logger.Printf("udp client video conn set write buffer error, %v", err)
 	}
+	if c.Mux {
+		return
+	}
 
 	addr, err = net.ResolveUDPAddr("udp", fmt.Sprintf("%s:%d", host, c.VControlPort))
 	if err != nil {
@@ -146,10 +155,16 @@ func (c *UDPClient) SendRTP(pack *RTPPack) (err error) {
 		conn = c.AConn
 	case RTP_TYPE_AUDIOCONTROL:
 		conn = c.AControlConn
+		if c.Mux {
+			conn = c.AConn
+		}
 	case RTP_TYPE_VIDEO:
 		conn = c.VConn
 	case RTP_TYPE_VIDEOCONTROL:
 		conn = c.VControlConn
+		if c.Mux {
+			conn = c.VConn
+		}
 	default:
 		err = fmt.Errorf("udp client send rtp got unkown pack type[%v]", pack.Type)
 		return
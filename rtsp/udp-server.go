@@ -7,6 +7,7 @@ import (
 	"net"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"EasyDarwin/helper/penggy/EasyGoLib/utils"
@@ -25,6 +26,14 @@ type UDPServer struct {
 	VControlPort int
 	VControlConn *net.UDPConn
 
+	// Mux carries RTCP on the RTP port of each media (RFC 5761), so no control
+	// conns are opened and the control ports equal the RTP ports.
+	Mux bool
+
+	// peer of the video control port, learned from the RTCP it sends us
+	vControlRemote     *net.UDPAddr
+	vControlRemoteLock sync.RWMutex
+
 	Stoped bool
 }
 
@@ -133,6 +142,9 @@ func (s *UDPServer) SetupAudio() (err error) {
 					Type:   RTP_TYPE_AUDIO,
 					Buffer: bytes.NewBuffer(rtpBytes),
 				}
+				if s.Mux && IsRTCPPacket(rtpBytes) {
+					pack.Type = RTP_TYPE_AUDIOCONTROL
+				}
 				s.HandleRTP(pack)
 			} else {
 				logger.Println("udp server read audio pack error", err)
@@ -140,6 +152,10 @@ func (s *UDPServer) SetupAudio() (err error) {
 			}
 		}
 	}()
+	if s.Mux {
+		s.AControlPort = s.APort
+		return
+	}
 	addr, err = net.ResolveUDPAddr("udp", ":0")
 	if err != nil {
 		return
@@ -217,7 +233,8 @@ func (s *UDPServer) SetupVideo() (err error) {
 		timer := time.Unix(0, 0)
 		for !s.Stoped {
 			var n int
-			if n, _, err = s.VConn.ReadFromUDP(bufUDP); err == nil {
+			var remote *net.UDPAddr
+			if n, remote, err = s.VConn.ReadFromUDP(bufUDP); err == nil {
 				elapsed := time.Now().Sub(timer)
 				if elapsed >= 30*time.Second {
 					logger.Printf("Package recv from VConn.len:%d\n", n)
@@ -230,6 +247,12 @@ func (s *UDPServer) SetupVideo() (err error) {
 					Type:   RTP_TYPE_VIDEO,
 					Buffer: bytes.NewBuffer(rtpBytes),
 				}
+				if s.Mux && IsRTCPPacket(rtpBytes) {
+					s.vControlRemoteLock.Lock()
+					s.vControlRemote = remote
+					s.vControlRemoteLock.Unlock()
+					pack.Type = RTP_TYPE_VIDEOCONTROL
+				}
 				s.HandleRTP(pack)
 			} else {
 				logger.Println("udp server read video pack error", err)
@@ -237,6 +260,10 @@ func (s *UDPServer) SetupVideo() (err error) {
 			}
 		}
 	}()
+	if s.Mux {
+		s.VControlPort = s.VPort
+		return
+	}
 
 	addr, err = net.ResolveUDPAddr("udp", ":0")
 	if err != nil {
@@ -264,8 +291,12 @@ func (s *UDPServer) SetupVideo() (err error) {
 		defer logger.Printf("udp server stop listen video control port[%d]", s.VControlPort)
 		for !s.Stoped {
 			var n int
-			if n, _, err = s.VControlConn.ReadFromUDP(bufUDP); err == nil {
+			var remote *net.UDPAddr
+			if n, remote, err = s.VControlConn.ReadFromUDP(bufUDP); err == nil {
 				//logger.Printf("Package recv from VControlConn.len:%d\n", n)
+				s.vControlRemoteLock.Lock()
+				s.vControlRemote = remote
+				s.vControlRemoteLock.Unlock()
 				rtpBytes := make([]byte, n)
 				s.AddInputBytes(n)
 				copy(rtpBytes, bufUDP)
@@ -282,3 +313,20 @@ func (s *UDPServer) SetupVideo() (err error) {
 	}()
 	return
 }
+
+// SendVideoControl sends an RTCP packet back to the peer of the video control port.
+func (s *UDPServer) SendVideoControl(rtcpBytes []byte) (err error) {
+	s.vControlRemoteLock.RLock()
+	remote := s.vControlRemote
+	s.vControlRemoteLock.RUnlock()
+	conn := s.VControlConn
+	if s.Mux {
+		conn = s.VConn
+	}
+	if conn == nil || remote == nil {
+		err = fmt.Errorf("udp server video control peer unknown")
+		return
+	}
+	_, err = conn.WriteToUDP(rtcpBytes, remote)
+	return
+}
@@ -0,0 +1,94 @@
+package rtsp
+
+import "strings"
+
+// video codec names, as stored in t_streams.codec
+const (
+	VIDEO_CODEC_H264 = "H264"
+	VIDEO_CODEC_H265 = "H265"
+)
+
+// H.265 NAL unit types, https://tools.ietf.org/html/rfc7798#section-1.1.4
+const (
+	H265_NAL_IRAP_FIRST = 16 // BLA_W_LP
+	H265_NAL_IRAP_LAST  = 21 // CRA_NUT
+	H265_NAL_VPS        = 32
+	H265_NAL_SPS        = 33
+	H265_NAL_PPS        = 34
+	H265_NAL_AP         = 48
+	H265_NAL_FU         = 49
+	H265_NAL_PACI       = 50
+)
+
+// VideoCodecName returns the name of a video codec of an SDP rtpmap, e.g.
+// VIDEO_CODEC_H265 for "h265", "H265" or "HEVC". Other codecs are returned
+// upper-cased.
+func VideoCodecName(codec string) string {
+	switch codec = strings.ToUpper(codec); codec {
+	case "H265", "HEVC":
+		return VIDEO_CODEC_H265
+	}
+	return codec
+}
+
+// h265NALType returns the type of the NAL unit an H.265 RTP payload starts:
+// the type of a single NAL unit, of the first unit of an aggregation packet,
+// or of the unit of the first fragment. ok is false for a payload not starting
+// a NAL unit, e.g. a non-first fragment.
+func h265NALType(payload []byte) (nalType uint8, ok bool) {
+	if len(payload) < 3 {
+		return 0, false
+	}
+	switch nalType = (payload[0] >> 1) & 0x3f; nalType {
+	case H265_NAL_FU:
+		/*
+		   +---------------+
+		   |0|1|2|3|4|5|6|7|
+		   +-+-+-+-+-+-+-+-+
+		   |S|E|  FuType   |
+		   +---------------+
+		*/
+		if payload[2]&0x80 == 0 {
+			return 0, false
+		}
+		return payload[2] & 0x3f, true
+	case H265_NAL_AP:
+		// payload header, 16 bits NALU size, first NAL unit header
+		if len(payload) < 5 {
+			return 0, false
+		}
+		return (payload[4] >> 1) & 0x3f, true
+	case H265_NAL_PACI:
+		return 0, false
+	}
+	return nalType, true
+}
+
+// VideoOutputArgs returns the ffmpeg output options an HLS or DASH output of
+// a stream needs for its video codec, given the codec options of the output.
+// H.265 copied as is is tagged hvc1 rather than hev1, the tag players such as
+// Safari require, and an HLS output given masterPlaylist writes it with the
+// hvc1 codec in EXT-X-CODECS. There are none for H.264 or transcoded video.
+func VideoOutputArgs(vcodec string, codecParams []string, masterPlaylist string) []string {
+	if VideoCodecName(vcodec) != VIDEO_CODEC_H265 || !videoCopied(codecParams) {
+		return nil
+	}
+	args := []string{"-tag:v", "hvc1"}
+	if masterPlaylist != "" {
+		args = append(args, "-master_pl_name", masterPlaylist)
+	}
+	return args
+}
+
+// videoCopied tells whether ffmpeg options copy the video stream.
+func videoCopied(params []string) bool {
+	for i := 0; i+1 < len(params); i++ {
+		switch params[i] {
+		case "-c", "-codec", "-c:v", "-codec:v", "-vcodec":
+			if params[i+1] == "copy" {
+				return true
+			}
+		}
+	}
+	return false
+}
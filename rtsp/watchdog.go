@@ -0,0 +1,188 @@
+package rtsp
+
+import (
+	"log"
+	"sync/atomic"
+	"time"
+
+	"EasyDarwin/models"
+)
+
+// WATCHDOG_INTERVAL is how often PublisherWatchdog checks the pushers.
+const WATCHDOG_INTERVAL = time.Second
+
+// PublisherWatchdog tears down the pushers that stopped sending packets. An
+// encoder that crashed often reconnects before its old session times out, and
+// its ANNOUNCE is rejected as long as the old pusher holds the path; the
+// watchdog frees the path first. A stalled publisher is sent a TEARDOWN and
+// disconnected, a stalled pulled stream is torn down at its source and pulled
+// again.
+//
+// The stall timeout of the watchdog applies to the streams without a
+// StallTimeout in t_streams, 0 watches only those.
+type PublisherWatchdog struct {
+	stallTimeout int64 // time.Duration, changed on config reloads
+
+	server *Server
+	logger *log.Logger
+	done   chan struct{}
+}
+
+func NewPublisherWatchdog(server *Server, stallTimeout time.Duration) *PublisherWatchdog {
+	watchdog := &PublisherWatchdog{
+		stallTimeout: int64(stallTimeout),
+		server:       server,
+		logger:       server.logger,
+		done:         make(chan struct{}),
+	}
+	go watchdog.run()
+	return watchdog
+}
+
+func (watchdog *PublisherWatchdog) StallTimeout() time.Duration {
+	return time.Duration(atomic.LoadInt64(&watchdog.stallTimeout))
+}
+
+func (watchdog *PublisherWatchdog) SetStallTimeout(stallTimeout time.Duration) {
+	atomic.StoreInt64(&watchdog.stallTimeout, int64(stallTimeout))
+}
+
+func (watchdog *PublisherWatchdog) Stop() {
+	close(watchdog.done)
+}
+
+func (watchdog *PublisherWatchdog) run() {
+	ticker := time.NewTicker(WATCHDOG_INTERVAL)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			watchdog.Check(now)
+		case <-watchdog.done:
+			return
+		}
+	}
+}
+
+// Check tears down the pushers stalled at now.
+func (watchdog *PublisherWatchdog) Check(now time.Time) {
+	defaultTimeout := watchdog.StallTimeout()
+	for _, pusher := range watchdog.server.GetPushers() {
+		session, client := pusher.source()
+		// a channel sends nothing while waiting for the start of an item
+		if session != nil && session.TransType == TRANS_TYPE_CHANNEL {
+			continue
+		}
+		timeout := pusher.stallTimeout
+		if timeout == 0 {
+			timeout = defaultTimeout
+		}
+		if timeout <= 0 {
+			continue
+		}
+		idle := now.Sub(pusher.LastPacketAt())
+		if idle < timeout || !atomic.CompareAndSwapInt32(&pusher.stalled, 0, 1) {
+			continue
+		}
+		watchdog.logger.Printf("WARN %v stalled, no packet for %v over the stall timeout %v, source[%s] in bytes[%d] players[%d], tear it down",
+			pusher, idle.Round(time.Millisecond), timeout, pusher.Source(), pusher.InBytes(), len(pusher.GetPlayers()))
+		// the TEARDOWN may block on a stalled connection
+		go pusher.stopForStall(session, client, idle)
+	}
+}
+
+// LastPacketAt returns when the pusher last received a packet, or when it was
+// created or rebound to a new session if later.
+func (pusher *Pusher) LastPacketAt() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&pusher.lastPacketAt))
+}
+
+func (pusher *Pusher) touch() {
+	atomic.StoreInt64(&pusher.lastPacketAt, time.Now().UnixNano())
+}
+
+// SetStallTimeout sets the stall timeout of a pusher, in seconds, e.g. one
+// given with the stream instead of read from t_streams. 0 keeps the current one.
+func (pusher *Pusher) SetStallTimeout(seconds int) {
+	if seconds != 0 {
+		pusher.stallTimeout = time.Duration(seconds) * time.Second
+	}
+}
+
+// rewatchStall restarts the stall clock of a pusher rebound to a new session.
+func (pusher *Pusher) rewatchStall() {
+	pusher.touch()
+	atomic.StoreInt32(&pusher.stalled, 0)
+}
+
+// watchStall starts the stall clock of a new pusher and reads the stall
+// timeout of its stream.
+func (pusher *Pusher) watchStall() {
+	pusher.touch()
+	seconds, err := models.FindStreamStallTimeout(pusher.Path())
+	if err != nil {
+		pusher.Logger().Printf("Query stall timeout of %s err:%v", pusher.Path(), err)
+	}
+	pusher.stallTimeout = time.Duration(seconds) * time.Second
+}
+
+// source returns the session of a publisher or the client of a pulled stream.
+func (pusher *Pusher) source() (*Session, *RTSPClient) {
+	pusher.sourceLock.Lock()
+	defer pusher.sourceLock.Unlock()
+	return pusher.Session, pusher.RTSPClient
+}
+
+// stopForStall ends a stalled stream, freeing its path: a pulled stream is
+// torn down at its source, a publisher is sent a TEARDOWN and disconnected.
+// session and client are the source found stalled; a pusher rebound to a new
+// source meanwhile is left alone.
+func (pusher *Pusher) stopForStall(session *Session, client *RTSPClient, idle time.Duration) {
+	if _session, _client := pusher.source(); _session != session || _client != client {
+		pusher.Logger().Printf("%v rebound since it stalled, keep it", pusher)
+		return
+	}
+	if client != nil {
+		if err := client.RequestNoResp("TEARDOWN", map[string]string{}); err != nil {
+			pusher.Logger().Printf("teardown %v err:%v", pusher, err)
+		}
+		client.Stop()
+	} else {
+		session.SetStopReason(DISCONNECT_REASON_STALLED)
+		if err := session.sendTeardown(); err != nil {
+			session.logger.Printf("teardown %v err:%v", session, err)
+		}
+		session.Stop()
+	}
+	pusher.Server().Emit(EVENT_STREAM_STALLED, pusher.Path(), map[string]interface{}{
+		"id":     pusher.ID(),
+		"idleMs": idle.Nanoseconds() / int64(time.Millisecond),
+	})
+}
+
+// sendTeardown asks the client of the session to end it, RTSP allowing a
+// server to send TEARDOWN. Sessions without a connection, e.g. merged
+// streams, are skipped.
+func (session *Session) sendTeardown() error {
+	if session.Conn == nil {
+		return nil
+	}
+	req := &Request{
+		Method:  TEARDOWN,
+		URL:     session.URL,
+		Version: RTSP_VERSION,
+		Header: map[string]string{
+			"CSeq":    "1",
+			"Session": session.ID,
+		},
+	}
+	session.logger.Printf(">>>\n%s", req)
+	outBytes := []byte(req.String())
+	session.connWLock.Lock()
+	defer session.connWLock.Unlock()
+	if _, err := session.connRW.Write(outBytes); err != nil {
+		return err
+	}
+	session.OutBytes += len(outBytes)
+	return session.connRW.Flush()
+}
@@ -0,0 +1,58 @@
+package rtsp
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestPusher(path string) *Pusher {
+	session := newSession(GetServer())
+	session.Type = SESSION_TYPE_PUSHER
+	session.Path = path
+	return NewPusher(session)
+}
+
+// stopReason returns the reason set on session, not the one disconnectReason
+// derives from the state of the server.
+func stopReason(session *Session) string {
+	session.stopReasonLock.Lock()
+	defer session.stopReasonLock.Unlock()
+	return session.stopReason
+}
+
+func TestStopForStall(t *testing.T) {
+	pusher := newTestPusher("/test/watchdog-stall")
+	session, client := pusher.source()
+	pusher.stopForStall(session, client, time.Minute)
+	if atomic.LoadInt32(&session.stoped) == 0 {
+		t.Error("stalled session not stopped")
+	}
+	if reason := stopReason(session); reason != DISCONNECT_REASON_STALLED {
+		t.Errorf("stop reason %q", reason)
+	}
+}
+
+// TestStopForStallRebound rebinds the pusher to the session of the
+// reconnected encoder between Check and the teardown.
+func TestStopForStallRebound(t *testing.T) {
+	pusher := newTestPusher("/test/watchdog-rebound")
+	stalled, client := pusher.source()
+
+	reconnected := newSession(GetServer())
+	reconnected.Type = SESSION_TYPE_PUSHER
+	reconnected.Path = stalled.Path
+	if !pusher.RebindSession(reconnected) {
+		t.Fatal("rebind refused")
+	}
+	pusher.stopForStall(stalled, client, time.Minute)
+	if atomic.LoadInt32(&reconnected.stoped) != 0 {
+		t.Error("reconnected session stopped")
+	}
+	if pusher.Session != reconnected {
+		t.Error("pusher lost the reconnected session")
+	}
+	if reason := stopReason(reconnected); reason != "" {
+		t.Errorf("reconnected session given stop reason %q", reason)
+	}
+}
@@ -0,0 +1,100 @@
+package rtsp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"EasyDarwin/helper/penggy/EasyGoLib/utils"
+)
+
+// Webhook POSTs stream events as JSON to the url of the [webhook] section.
+// Events are queued and sent by one goroutine, so a slow receiver never blocks
+// a session; when the queue is full the event is dropped.
+type Webhook struct {
+	URL string
+
+	client *http.Client
+	events chan *StreamEvent
+	logger *log.Logger
+	// Post and Close may race when the webhook is replaced on a config reload
+	closed bool
+	lock   sync.RWMutex
+}
+
+var webhookDroppedTotal = NewCounter("rtsp_webhook_dropped_total", "Webhook events dropped because the queue was full.")
+
+// NewWebhook returns nil when no url is configured.
+func NewWebhook(logger *log.Logger) *Webhook {
+	sec := utils.Conf().Section("webhook")
+	url := sec.Key("url").MustString("")
+	if url == "" {
+		return nil
+	}
+	hook := &Webhook{
+		URL:    url,
+		client: &http.Client{Timeout: time.Duration(sec.Key("timeout").MustInt(3000)) * time.Millisecond},
+		events: make(chan *StreamEvent, sec.Key("queue_size").MustInt(256)),
+		logger: logger,
+	}
+	go hook.run()
+	return hook
+}
+
+// Post queues an event. It is a no-op on a nil Webhook.
+func (hook *Webhook) Post(e *StreamEvent) {
+	if hook == nil {
+		return
+	}
+	hook.lock.RLock()
+	defer hook.lock.RUnlock()
+	if hook.closed {
+		return
+	}
+	select {
+	case hook.events <- e:
+	default:
+		webhookDroppedTotal.Inc()
+	}
+}
+
+// Close stops the sender after the queued events are sent.
+func (hook *Webhook) Close() {
+	if hook == nil {
+		return
+	}
+	hook.lock.Lock()
+	defer hook.lock.Unlock()
+	if !hook.closed {
+		hook.closed = true
+		close(hook.events)
+	}
+}
+
+func (hook *Webhook) run() {
+	for e := range hook.events {
+		if err := hook.post(e); err != nil {
+			hook.logger.Printf("webhook %s for %s err:%v", e.Event, e.Stream, err)
+		}
+	}
+}
+
+func (hook *Webhook) post(e *StreamEvent) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	res, err := hook.client.Post(hook.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected status %s", res.Status)
+	}
+	return nil
+}
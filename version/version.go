@@ -0,0 +1,29 @@
+// Package version holds the version of the build. main sets it from the
+// variables given with -ldflags "-X main.Version=... -X main.buildDateTime=...
+// -X main.gitCommitCode=...".
+package version
+
+import "fmt"
+
+var (
+	// Version is the semantic version of the release.
+	Version = "8.1.0"
+	// BuildTime is when the binary was built, empty for a dev build.
+	BuildTime = ""
+	// GitCommit is the abbreviated commit the binary was built from.
+	GitCommit = ""
+)
+
+// String returns the version with the commit as build metadata, e.g.
+// 8.1.0+2968efe, as sent in the Server headers.
+func String() string {
+	if GitCommit == "" {
+		return Version
+	}
+	return fmt.Sprintf("%s+%s", Version, GitCommit)
+}
+
+// Banner returns the Server header of the RTSP and HTTP responses.
+func Banner() string {
+	return "EasyDarwin/" + String()
+}
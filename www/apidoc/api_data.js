@@ -1,10 +1,10 @@
 define({ "api": [
   {
-    "type": "get",
-    "url": "/api/v1/record/files",
-    "title": "获取所有录像文件",
-    "group": "record",
-    "name": "RecordFiles",
+    "type": "delete",
+    "url": "/api/v1/channel",
+    "title": "删除频道",
+    "group": "channel",
+    "name": "ChannelDelete",
     "parameter": {
       "fields": {
         "Parameter": [
@@ -12,20 +12,133 @@ define({ "api": [
             "group": "Parameter",
             "type": "Number",
             "optional": false,
-            "field": "folder",
-            "description": "<p>录像文件所在的文件夹</p>"
+            "field": "id",
+            "description": "<p>频道ID</p>"
+          }
+        ]
+      }
+    },
+    "version": "0.0.0",
+    "filename": "routers/channel.go",
+    "groupTitle": "频道",
+    "success": {
+      "examples": [
+        {
+          "title": "成功",
+          "content": "HTTP/1.1 200 OK",
+          "type": "json"
+        }
+      ]
+    },
+    "error": {
+      "examples": [
+        {
+          "title": "认证失败",
+          "content": "HTTP/1.1 401 access denied",
+          "type": "json"
+        }
+      ]
+    }
+  },
+  {
+    "type": "get",
+    "url": "/api/v1/channel",
+    "title": "获取频道列表",
+    "group": "channel",
+    "name": "ChannelList",
+    "success": {
+      "fields": {
+        "200": [
+          {
+            "group": "200",
+            "type": "Number",
+            "optional": false,
+            "field": "rows.id",
+            "description": "<p>频道ID</p>"
+          },
+          {
+            "group": "200",
+            "type": "String",
+            "optional": false,
+            "field": "rows.path",
+            "description": "<p>频道的播放PATH</p>"
+          },
+          {
+            "group": "200",
+            "type": "String",
+            "optional": false,
+            "field": "rows.name",
+            "description": "<p>频道名称</p>"
+          },
+          {
+            "group": "200",
+            "type": "Boolean",
+            "optional": false,
+            "field": "rows.live",
+            "description": "<p>是否正在播出</p>"
+          },
+          {
+            "group": "200",
+            "type": "Array",
+            "optional": false,
+            "field": "rows.items",
+            "description": "<p>节目单, 按播出顺序</p>"
+          },
+          {
+            "group": "200",
+            "type": "String",
+            "optional": false,
+            "field": "rows.items.recordingID",
+            "description": "<p>录像文件的相对路径, 即 /api/v1/record/files 返回的path</p>"
           },
+          {
+            "group": "200",
+            "type": "String",
+            "optional": false,
+            "field": "rows.items.startTime",
+            "description": "<p>开始时间, RFC3339格式, 为空时接在上一个节目之后播出</p>"
+          },
+          {
+            "group": "200",
+            "type": "String",
+            "optional": false,
+            "field": "rows.items.transitionEffect",
+            "description": "<p>转场效果, 为空时直接切换, fade 为淡入</p>"
+          },
+          {
+            "group": "200",
+            "type": "Number",
+            "optional": false,
+            "field": "total",
+            "description": "<p>总数</p>"
+          },
+          {
+            "group": "200",
+            "type": "Array",
+            "optional": false,
+            "field": "rows",
+            "description": "<p>分页数据</p>"
+          }
+        ]
+      }
+    },
+    "version": "0.0.0",
+    "filename": "routers/channel.go",
+    "groupTitle": "频道",
+    "parameter": {
+      "fields": {
+        "Parameter": [
           {
             "group": "Parameter",
             "type": "Number",
-            "optional": true,
+            "optional": false,
             "field": "start",
             "description": "<p>分页开始,从零开始</p>"
           },
           {
             "group": "Parameter",
             "type": "Number",
-            "optional": true,
+            "optional": false,
             "field": "limit",
             "description": "<p>分页大小</p>"
           },
@@ -56,6 +169,73 @@ define({ "api": [
           }
         ]
       }
+    }
+  },
+  {
+    "type": "post",
+    "url": "/api/v1/channel",
+    "title": "添加或修改频道",
+    "group": "channel",
+    "name": "ChannelSave",
+    "description": "<p>请求体为JSON。频道按节目单顺序播放录像, 像直播流一样通过RTSP播放, 视频以MPEG-TS(MP2T)格式传输。\n频道启动时从开始时间已过的最后一个节目开始, 并跳到其应播放到的位置。需要配置 rtsp.ffmpeg_path 与 rtsp.m3u8_dir_path。</p>",
+    "parameter": {
+      "fields": {
+        "Parameter": [
+          {
+            "group": "Parameter",
+            "type": "Number",
+            "optional": true,
+            "field": "id",
+            "description": "<p>频道ID, 修改时填写</p>"
+          },
+          {
+            "group": "Parameter",
+            "type": "String",
+            "optional": false,
+            "field": "path",
+            "description": "<p>频道的播放PATH</p>"
+          },
+          {
+            "group": "Parameter",
+            "type": "String",
+            "optional": true,
+            "field": "name",
+            "description": "<p>频道名称</p>"
+          },
+          {
+            "group": "Parameter",
+            "type": "Array",
+            "optional": false,
+            "field": "items",
+            "description": "<p>节目单, 按播出顺序</p>"
+          },
+          {
+            "group": "Parameter",
+            "type": "String",
+            "optional": false,
+            "field": "items.recordingID",
+            "description": "<p>录像文件的相对路径, 即 /api/v1/record/files 返回的path</p>"
+          },
+          {
+            "group": "Parameter",
+            "type": "String",
+            "optional": true,
+            "field": "items.startTime",
+            "description": "<p>开始时间, RFC3339格式, 为空或早于上一个节目结束时接在上一个节目之后播出</p>"
+          },
+          {
+            "group": "Parameter",
+            "type": "String",
+            "allowedValues": [
+              "",
+              "fade"
+            ],
+            "optional": true,
+            "field": "items.transitionEffect",
+            "description": "<p>转场效果, fade 为1秒淡入, 需要转码</p>"
+          }
+        ]
+      }
     },
     "success": {
       "fields": {
@@ -64,36 +244,98 @@ define({ "api": [
             "group": "200",
             "type": "Number",
             "optional": false,
-            "field": "total",
-            "description": "<p>总数</p>"
-          },
+            "field": "id",
+            "description": "<p>频道ID</p>"
+          }
+        ]
+      }
+    },
+    "version": "0.0.0",
+    "filename": "routers/channel.go",
+    "groupTitle": "频道",
+    "error": {
+      "examples": [
+        {
+          "title": "认证失败",
+          "content": "HTTP/1.1 401 access denied",
+          "type": "json"
+        }
+      ]
+    }
+  },
+  {
+    "type": "get",
+    "url": "/api/v1/events/sse",
+    "title": "订阅实时事件",
+    "group": "events",
+    "name": "EventsSSE",
+    "description": "<p>以 Server-Sent Events (text/event-stream) 推送事件, 每个事件为一行 event: 类型 与一行 data: JSON。\n事件类型有 stream.start, stream.stop, stream.param_changed, subscriber.join, subscriber.leave。\n客户端接收过慢导致缓冲区(http.sse_buffer_size)满时, 服务器会断开连接, 客户端应重新连接。</p>",
+    "success": {
+      "fields": {
+        "200": [
           {
             "group": "200",
-            "type": "Array",
+            "type": "String",
             "optional": false,
-            "field": "rows",
-            "description": "<p>文件列表</p>"
+            "field": "event",
+            "description": "<p>事件类型</p>"
           },
           {
             "group": "200",
             "type": "String",
             "optional": false,
-            "field": "rows.duration",
-            "description": "<p>格式化好的录像时长</p>"
+            "field": "stream",
+            "description": "<p>流PATH</p>"
           },
           {
             "group": "200",
             "type": "Number",
             "optional": false,
-            "field": "rows.durationMillis",
-            "description": "<p>录像时长，毫秒为单位</p>"
+            "field": "time",
+            "description": "<p>事件时间, Unix时间戳(秒)</p>"
           },
           {
             "group": "200",
+            "type": "Object",
+            "optional": true,
+            "field": "data",
+            "description": "<p>事件数据</p>"
+          }
+        ]
+      }
+    },
+    "version": "0.0.0",
+    "filename": "routers/events.go",
+    "groupTitle": "事件"
+  },
+  {
+    "type": "get",
+    "url": "/api/v1/record/download",
+    "title": "下载录像文件",
+    "group": "record",
+    "name": "RecordDownload",
+    "parameter": {
+      "fields": {
+        "Parameter": [
+          {
+            "group": "Parameter",
             "type": "String",
             "optional": false,
-            "field": "rows.path",
-            "description": "<p>录像文件的相对路径,录像文件为m3u8格式，将其放到video标签中便可直接播放。其绝对路径为：http[s]://host:port/record/[path]。</p>"
+            "field": "path",
+            "description": "<p>录像文件的相对路径，即 /api/v1/record/files 返回的path</p>"
+          }
+        ]
+      }
+    },
+    "success": {
+      "fields": {
+        "200": [
+          {
+            "group": "200",
+            "type": "File",
+            "optional": false,
+            "field": "file",
+            "description": "<p>录像文件，文件名为 文件夹_文件名，本地存储时支持Range断点续传</p>"
           }
         ]
       }
@@ -104,13 +346,20 @@ define({ "api": [
   },
   {
     "type": "get",
-    "url": "/api/v1/record/folders",
-    "title": "获取所有录像文件夹",
+    "url": "/api/v1/record/files",
+    "title": "获取所有录像文件",
     "group": "record",
-    "name": "RecordFolders",
+    "name": "RecordFiles",
     "parameter": {
       "fields": {
         "Parameter": [
+          {
+            "group": "Parameter",
+            "type": "Number",
+            "optional": false,
+            "field": "folder",
+            "description": "<p>录像文件所在的文件夹</p>"
+          },
           {
             "group": "Parameter",
             "type": "Number",
@@ -168,14 +417,28 @@ define({ "api": [
             "type": "Array",
             "optional": false,
             "field": "rows",
-            "description": "<p>文件夹列表</p>"
+            "description": "<p>文件列表</p>"
           },
           {
             "group": "200",
             "type": "String",
             "optional": false,
-            "field": "rows.folder",
-            "description": "<p>录像文件夹名称</p>"
+            "field": "rows.duration",
+            "description": "<p>格式化好的录像时长</p>"
+          },
+          {
+            "group": "200",
+            "type": "Number",
+            "optional": false,
+            "field": "rows.durationMillis",
+            "description": "<p>录像时长，毫秒为单位</p>"
+          },
+          {
+            "group": "200",
+            "type": "String",
+            "optional": false,
+            "field": "rows.path",
+            "description": "<p>录像文件的相对路径,录像文件为m3u8格式，将其放到video标签中便可直接播放。其绝对路径为：http[s]://host:port/record/[path]。</p>"
           }
         ]
       }
@@ -186,10 +449,10 @@ define({ "api": [
   },
   {
     "type": "get",
-    "url": "/api/v1/players",
-    "title": "获取拉流列表",
-    "group": "stats",
-    "name": "Players",
+    "url": "/api/v1/record/folders",
+    "title": "获取所有录像文件夹",
+    "group": "record",
+    "name": "RecordFolders",
     "parameter": {
       "fields": {
         "Parameter": [
@@ -250,24 +513,236 @@ define({ "api": [
             "type": "Array",
             "optional": false,
             "field": "rows",
-            "description": "<p>推流列表</p>"
-          },
-          {
-            "group": "200",
-            "type": "String",
-            "optional": false,
-            "field": "rows.id",
-            "description": ""
+            "description": "<p>文件夹列表</p>"
           },
           {
             "group": "200",
             "type": "String",
             "optional": false,
-            "field": "rows.path",
-            "description": ""
-          },
-          {
-            "group": "200",
+            "field": "rows.folder",
+            "description": "<p>录像文件夹名称</p>"
+          }
+        ]
+      }
+    },
+    "version": "0.0.0",
+    "filename": "routers/record.go",
+    "groupTitle": "录像"
+  },
+  {
+    "type": "get",
+    "url": "/api/v1/stats/aggregate",
+    "title": "查询流统计汇总",
+    "group": "stats",
+    "name": "AggregateStats",
+    "description": "<p>按时间窗口汇总的每路流统计, 每个窗口结束时生成一行, 按时间升序返回, 可用于绘制趋势图。</p>",
+    "parameter": {
+      "fields": {
+        "Parameter": [
+          {
+            "group": "Parameter",
+            "type": "String",
+            "optional": true,
+            "field": "stream",
+            "description": "<p>流PATH, 为空时返回所有流</p>"
+          },
+          {
+            "group": "Parameter",
+            "type": "String",
+            "allowedValues": [
+              "1m",
+              "5m",
+              "1h"
+            ],
+            "optional": true,
+            "field": "window",
+            "defaultValue": "1m",
+            "description": "<p>时间窗口</p>"
+          },
+          {
+            "group": "Parameter",
+            "type": "Number",
+            "optional": true,
+            "field": "from",
+            "description": "<p>窗口开始时间下限, Unix时间戳(秒)</p>"
+          },
+          {
+            "group": "Parameter",
+            "type": "Number",
+            "optional": true,
+            "field": "to",
+            "description": "<p>窗口开始时间上限(不含), Unix时间戳(秒)</p>"
+          }
+        ]
+      }
+    },
+    "success": {
+      "fields": {
+        "200": [
+          {
+            "group": "200",
+            "type": "Number",
+            "optional": false,
+            "field": "total",
+            "description": "<p>总数</p>"
+          },
+          {
+            "group": "200",
+            "type": "Array",
+            "optional": false,
+            "field": "rows",
+            "description": "<p>汇总列表</p>"
+          },
+          {
+            "group": "200",
+            "type": "String",
+            "optional": false,
+            "field": "rows.streamPath",
+            "description": "<p>流PATH</p>"
+          },
+          {
+            "group": "200",
+            "type": "String",
+            "optional": false,
+            "field": "rows.window",
+            "description": "<p>时间窗口</p>"
+          },
+          {
+            "group": "200",
+            "type": "Number",
+            "optional": false,
+            "field": "rows.periodStart",
+            "description": "<p>窗口开始时间, Unix时间戳(秒)</p>"
+          },
+          {
+            "group": "200",
+            "type": "Number",
+            "optional": false,
+            "field": "rows.avgBitrateKbps",
+            "description": "<p>推流平均码率(kbps)</p>"
+          },
+          {
+            "group": "200",
+            "type": "Number",
+            "optional": false,
+            "field": "rows.peakBitrateKbps",
+            "description": "<p>推流峰值码率(kbps)</p>"
+          },
+          {
+            "group": "200",
+            "type": "Number",
+            "optional": false,
+            "field": "rows.totalSubscribers",
+            "description": "<p>窗口内的播放人次</p>"
+          },
+          {
+            "group": "200",
+            "type": "Number",
+            "optional": false,
+            "field": "rows.totalBytes",
+            "description": "<p>窗口内推流与播放的总流量</p>"
+          }
+        ]
+      }
+    },
+    "version": "0.0.0",
+    "filename": "routers/stats.go",
+    "groupTitle": "统计"
+  },
+  {
+    "type": "get",
+    "url": "/metrics",
+    "title": "获取Prometheus监控指标",
+    "group": "stats",
+    "name": "Metrics",
+    "version": "0.0.0",
+    "filename": "routers/stats.go",
+    "groupTitle": "统计"
+  },
+  {
+    "type": "get",
+    "url": "/api/v1/players",
+    "title": "获取拉流列表",
+    "group": "stats",
+    "name": "Players",
+    "parameter": {
+      "fields": {
+        "Parameter": [
+          {
+            "group": "Parameter",
+            "type": "Number",
+            "optional": true,
+            "field": "start",
+            "description": "<p>分页开始,从零开始</p>"
+          },
+          {
+            "group": "Parameter",
+            "type": "Number",
+            "optional": true,
+            "field": "limit",
+            "description": "<p>分页大小</p>"
+          },
+          {
+            "group": "Parameter",
+            "type": "String",
+            "optional": true,
+            "field": "sort",
+            "description": "<p>排序字段</p>"
+          },
+          {
+            "group": "Parameter",
+            "type": "String",
+            "allowedValues": [
+              "ascending",
+              "descending"
+            ],
+            "optional": true,
+            "field": "order",
+            "description": "<p>排序顺序</p>"
+          },
+          {
+            "group": "Parameter",
+            "type": "String",
+            "optional": true,
+            "field": "q",
+            "description": "<p>查询参数</p>"
+          }
+        ]
+      }
+    },
+    "success": {
+      "fields": {
+        "200": [
+          {
+            "group": "200",
+            "type": "Number",
+            "optional": false,
+            "field": "total",
+            "description": "<p>总数</p>"
+          },
+          {
+            "group": "200",
+            "type": "Array",
+            "optional": false,
+            "field": "rows",
+            "description": "<p>推流列表</p>"
+          },
+          {
+            "group": "200",
+            "type": "String",
+            "optional": false,
+            "field": "rows.id",
+            "description": ""
+          },
+          {
+            "group": "200",
+            "type": "String",
+            "optional": false,
+            "field": "rows.path",
+            "description": ""
+          },
+          {
+            "group": "200",
             "type": "String",
             "optional": false,
             "field": "rows.transType",
@@ -413,17 +888,597 @@ define({ "api": [
           },
           {
             "group": "200",
-            "type": "Number",
+            "type": "Number",
+            "optional": false,
+            "field": "rows.onlines",
+            "description": "<p>在线人数</p>"
+          }
+        ]
+      }
+    },
+    "version": "0.0.0",
+    "filename": "routers/stats.go",
+    "groupTitle": "统计"
+  },
+  {
+    "type": "get",
+    "url": "/api/v1/redis/slowlog",
+    "title": "获取Redis慢命令日志",
+    "group": "stats",
+    "name": "RedisSlowLog",
+    "description": "<p>所有Redis分片上耗时超过 slow_threshold_ms 的最近128条命令, 最新的在前。</p>",
+    "success": {
+      "fields": {
+        "200": [
+          {
+            "group": "200",
+            "type": "Object[]",
+            "optional": false,
+            "field": "-",
+            "description": "<p>慢命令列表</p>"
+          },
+          {
+            "group": "200",
+            "type": "String",
+            "optional": false,
+            "field": "-.time",
+            "description": "<p>命令开始时间</p>"
+          },
+          {
+            "group": "200",
+            "type": "String",
+            "optional": false,
+            "field": "-.shard",
+            "description": "<p>分片名称</p>"
+          },
+          {
+            "group": "200",
+            "type": "String",
+            "optional": false,
+            "field": "-.command",
+            "description": "<p>命令名称</p>"
+          },
+          {
+            "group": "200",
+            "type": "String[]",
+            "optional": false,
+            "field": "-.args",
+            "description": "<p>命令参数, 超过32个或单个超过128字节时被截断</p>"
+          },
+          {
+            "group": "200",
+            "type": "String",
+            "optional": false,
+            "field": "-.duration",
+            "description": "<p>格式化好的耗时</p>"
+          },
+          {
+            "group": "200",
+            "type": "Number",
+            "optional": false,
+            "field": "-.durationMillis",
+            "description": "<p>耗时, 毫秒为单位</p>"
+          },
+          {
+            "group": "200",
+            "type": "String",
+            "optional": false,
+            "field": "-.err",
+            "description": "<p>命令的错误, 成功时为空</p>"
+          }
+        ]
+      }
+    },
+    "version": "0.0.0",
+    "filename": "routers/stats.go",
+    "groupTitle": "统计"
+  },
+  {
+    "type": "get",
+    "url": "/api/v1/stats/sessions",
+    "title": "查询历史会话统计",
+    "group": "stats",
+    "name": "SessionStats",
+    "parameter": {
+      "fields": {
+        "Parameter": [
+          {
+            "group": "Parameter",
+            "type": "String",
+            "optional": true,
+            "field": "stream",
+            "description": "<p>流PATH</p>"
+          },
+          {
+            "group": "Parameter",
+            "type": "Number",
+            "optional": true,
+            "field": "from",
+            "description": "<p>会话开始时间下限, Unix时间戳(秒)</p>"
+          },
+          {
+            "group": "Parameter",
+            "type": "Number",
+            "optional": true,
+            "field": "to",
+            "description": "<p>会话开始时间上限, Unix时间戳(秒)</p>"
+          },
+          {
+            "group": "Parameter",
+            "type": "Number",
+            "optional": true,
+            "field": "start",
+            "description": "<p>分页开始,从零开始</p>"
+          },
+          {
+            "group": "Parameter",
+            "type": "Number",
+            "optional": true,
+            "field": "limit",
+            "description": "<p>分页大小</p>"
+          }
+        ]
+      }
+    },
+    "success": {
+      "fields": {
+        "200": [
+          {
+            "group": "200",
+            "type": "String",
+            "optional": false,
+            "field": "rows.sessionId",
+            "description": ""
+          },
+          {
+            "group": "200",
+            "type": "String",
+            "optional": false,
+            "field": "rows.streamPath",
+            "description": "<p>流PATH</p>"
+          },
+          {
+            "group": "200",
+            "type": "String",
+            "optional": false,
+            "field": "rows.clientIp",
+            "description": "<p>客户端IP</p>"
+          },
+          {
+            "group": "200",
+            "type": "String",
+            "allowedValues": [
+              "pub",
+              "sub"
+            ],
+            "optional": false,
+            "field": "rows.role",
+            "description": "<p>推流或拉流, 认证失败的会话为空</p>"
+          },
+          {
+            "group": "200",
+            "type": "String",
+            "optional": false,
+            "field": "rows.startTime",
+            "description": "<p>开始时间</p>"
+          },
+          {
+            "group": "200",
+            "type": "String",
+            "optional": false,
+            "field": "rows.endTime",
+            "description": "<p>结束时间</p>"
+          },
+          {
+            "group": "200",
+            "type": "Number",
+            "optional": false,
+            "field": "rows.bytesTransferred",
+            "description": "<p>传输字节数</p>"
+          },
+          {
+            "group": "200",
+            "type": "Number",
+            "optional": false,
+            "field": "rows.packetsLost",
+            "description": "<p>丢包数</p>"
+          },
+          {
+            "group": "200",
+            "type": "String",
+            "allowedValues": [
+              "normal",
+              "timeout",
+              "server_shutdown",
+              "publisher_gone",
+              "auth_failure"
+            ],
+            "optional": false,
+            "field": "rows.disconnectReason",
+            "description": "<p>断开原因</p>"
+          },
+          {
+            "group": "200",
+            "type": "Number",
+            "optional": false,
+            "field": "total",
+            "description": "<p>总数</p>"
+          },
+          {
+            "group": "200",
+            "type": "Array",
+            "optional": false,
+            "field": "rows",
+            "description": "<p>分页数据</p>"
+          }
+        ]
+      }
+    },
+    "version": "0.0.0",
+    "filename": "routers/stats.go",
+    "groupTitle": "统计"
+  },
+  {
+    "type": "get",
+    "url": "/api/v1/stats",
+    "title": "获取节点负载",
+    "group": "stats",
+    "name": "Stats",
+    "description": "<p>前端负载均衡节点轮询后端节点的该接口, 按各路流的播放人数分配播放端。</p>",
+    "success": {
+      "fields": {
+        "200": [
+          {
+            "group": "200",
+            "type": "Number",
+            "optional": false,
+            "field": "rtspPort",
+            "description": "<p>RTSP端口</p>"
+          },
+          {
+            "group": "200",
+            "type": "Number",
+            "optional": false,
+            "field": "pushers",
+            "description": "<p>推流数</p>"
+          },
+          {
+            "group": "200",
+            "type": "Number",
+            "optional": false,
+            "field": "players",
+            "description": "<p>播放人数</p>"
+          },
+          {
+            "group": "200",
+            "type": "Object",
+            "optional": false,
+            "field": "streams",
+            "description": "<p>各路流的播放人数, 以流的PATH为键</p>"
+          }
+        ]
+      }
+    },
+    "version": "0.0.0",
+    "filename": "routers/stats.go",
+    "groupTitle": "统计"
+  },
+  {
+    "type": "delete",
+    "url": "/api/v1/stream/alias",
+    "title": "删除流别名",
+    "group": "stream",
+    "name": "StreamAliasDelete",
+    "parameter": {
+      "fields": {
+        "Parameter": [
+          {
+            "group": "Parameter",
+            "type": "String",
+            "optional": false,
+            "field": "alias",
+            "description": "<p>别名PATH</p>"
+          }
+        ]
+      }
+    },
+    "version": "0.0.0",
+    "filename": "routers/alias.go",
+    "groupTitle": "stream",
+    "success": {
+      "examples": [
+        {
+          "title": "成功",
+          "content": "HTTP/1.1 200 OK",
+          "type": "json"
+        }
+      ]
+    },
+    "error": {
+      "examples": [
+        {
+          "title": "认证失败",
+          "content": "HTTP/1.1 401 access denied",
+          "type": "json"
+        }
+      ]
+    }
+  },
+  {
+    "type": "get",
+    "url": "/api/v1/stream/alias",
+    "title": "获取流别名列表",
+    "group": "stream",
+    "name": "StreamAliasList",
+    "success": {
+      "fields": {
+        "200": [
+          {
+            "group": "200",
+            "type": "String",
+            "optional": false,
+            "field": "rows.alias",
+            "description": "<p>别名PATH</p>"
+          },
+          {
+            "group": "200",
+            "type": "String",
+            "optional": false,
+            "field": "rows.canonical",
+            "description": "<p>别名指向的流PATH</p>"
+          },
+          {
+            "group": "200",
+            "type": "Number",
+            "optional": false,
+            "field": "total",
+            "description": "<p>总数</p>"
+          },
+          {
+            "group": "200",
+            "type": "Array",
+            "optional": false,
+            "field": "rows",
+            "description": "<p>分页数据</p>"
+          }
+        ]
+      }
+    },
+    "version": "0.0.0",
+    "filename": "routers/alias.go",
+    "groupTitle": "stream",
+    "parameter": {
+      "fields": {
+        "Parameter": [
+          {
+            "group": "Parameter",
+            "type": "Number",
+            "optional": false,
+            "field": "start",
+            "description": "<p>分页开始,从零开始</p>"
+          },
+          {
+            "group": "Parameter",
+            "type": "Number",
+            "optional": false,
+            "field": "limit",
+            "description": "<p>分页大小</p>"
+          },
+          {
+            "group": "Parameter",
+            "type": "String",
+            "optional": true,
+            "field": "sort",
+            "description": "<p>排序字段</p>"
+          },
+          {
+            "group": "Parameter",
+            "type": "String",
+            "allowedValues": [
+              "ascending",
+              "descending"
+            ],
+            "optional": true,
+            "field": "order",
+            "description": "<p>排序顺序</p>"
+          },
+          {
+            "group": "Parameter",
+            "type": "String",
+            "optional": true,
+            "field": "q",
+            "description": "<p>查询参数</p>"
+          }
+        ]
+      }
+    }
+  },
+  {
+    "type": "post",
+    "url": "/api/v1/stream/alias",
+    "title": "添加或修改流别名",
+    "group": "stream",
+    "name": "StreamAliasSave",
+    "description": "<p>播放别名时服务器返回RTSP 302, 重定向到别名指向的流。别名可以指向另一个别名, 最多解析5层, 形成循环的别名会被拒绝。</p>",
+    "parameter": {
+      "fields": {
+        "Parameter": [
+          {
+            "group": "Parameter",
+            "type": "String",
+            "optional": false,
+            "field": "alias",
+            "description": "<p>别名PATH</p>"
+          },
+          {
+            "group": "Parameter",
+            "type": "String",
+            "optional": false,
+            "field": "canonical",
+            "description": "<p>别名指向的流PATH</p>"
+          }
+        ]
+      }
+    },
+    "version": "0.0.0",
+    "filename": "routers/alias.go",
+    "groupTitle": "stream",
+    "success": {
+      "examples": [
+        {
+          "title": "成功",
+          "content": "HTTP/1.1 200 OK",
+          "type": "json"
+        }
+      ]
+    },
+    "error": {
+      "examples": [
+        {
+          "title": "认证失败",
+          "content": "HTTP/1.1 401 access denied",
+          "type": "json"
+        }
+      ]
+    }
+  },
+  {
+    "type": "get",
+    "url": "/api/v1/stream/list",
+    "title": "获取拉转推列表",
+    "group": "stream",
+    "name": "StreamList",
+    "parameter": {
+      "fields": {
+        "Parameter": [
+          {
+            "group": "Parameter",
+            "type": "String",
+            "optional": true,
+            "field": "q",
+            "description": "<p>全文检索参数, 匹配name, description, metadata</p>"
+          },
+          {
+            "group": "Parameter",
+            "type": "Number",
+            "optional": false,
+            "field": "start",
+            "description": "<p>分页开始,从零开始</p>"
+          },
+          {
+            "group": "Parameter",
+            "type": "Number",
+            "optional": false,
+            "field": "limit",
+            "description": "<p>分页大小</p>"
+          },
+          {
+            "group": "Parameter",
+            "type": "String",
+            "optional": true,
+            "field": "sort",
+            "description": "<p>排序字段</p>"
+          },
+          {
+            "group": "Parameter",
+            "type": "String",
+            "allowedValues": [
+              "ascending",
+              "descending"
+            ],
+            "optional": true,
+            "field": "order",
+            "description": "<p>排序顺序</p>"
+          },
+          {
+            "group": "Parameter",
+            "type": "String",
+            "optional": true,
+            "field": "q",
+            "description": "<p>查询参数</p>"
+          }
+        ]
+      }
+    },
+    "success": {
+      "fields": {
+        "200": [
+          {
+            "group": "200",
+            "type": "String",
+            "optional": false,
+            "field": "rows.url",
+            "description": "<p>RTSP源地址</p>"
+          },
+          {
+            "group": "200",
+            "type": "String",
+            "optional": false,
+            "field": "rows.customPath",
+            "description": "<p>转推时的推送PATH</p>"
+          },
+          {
+            "group": "200",
+            "type": "String",
+            "optional": false,
+            "field": "rows.rawName",
+            "description": "<p>规范化之前的推送PATH</p>"
+          },
+          {
+            "group": "200",
+            "type": "String",
+            "optional": false,
+            "field": "rows.name",
+            "description": "<p>流名称</p>"
+          },
+          {
+            "group": "200",
+            "type": "String",
+            "optional": false,
+            "field": "rows.description",
+            "description": "<p>流描述</p>"
+          },
+          {
+            "group": "200",
+            "type": "String",
+            "optional": false,
+            "field": "rows.metadata",
+            "description": "<p>流的其他信息</p>"
+          },
+          {
+            "group": "200",
+            "type": "String",
+            "optional": false,
+            "field": "rows.codec",
+            "description": "<p>视频编码, H264 或 H265, 最近一次拉流时记录</p>"
+          },
+          {
+            "group": "200",
+            "type": "Object",
+            "optional": true,
+            "field": "rows.highlights",
+            "description": "<p>全文检索时各字段的匹配片段, 匹配部分以<b></b>标记</p>"
+          },
+          {
+            "group": "200",
+            "type": "Number",
+            "optional": false,
+            "field": "total",
+            "description": "<p>总数</p>"
+          },
+          {
+            "group": "200",
+            "type": "Array",
             "optional": false,
-            "field": "rows.onlines",
-            "description": "<p>在线人数</p>"
+            "field": "rows",
+            "description": "<p>分页数据</p>"
           }
         ]
       }
     },
     "version": "0.0.0",
-    "filename": "routers/stats.go",
-    "groupTitle": "统计"
+    "filename": "routers/streams.go",
+    "groupTitle": "流管理"
   },
   {
     "type": "get",
@@ -473,6 +1528,41 @@ define({ "api": [
             "optional": true,
             "field": "heartbeatInterval",
             "description": "<p>拉流时的心跳间隔，毫秒为单位。如果心跳间隔不为0，那拉流时会向源地址以该间隔发送OPTION请求用来心跳保活</p>"
+          },
+          {
+            "group": "Parameter",
+            "type": "String",
+            "optional": true,
+            "field": "name",
+            "description": "<p>流名称</p>"
+          },
+          {
+            "group": "Parameter",
+            "type": "String",
+            "optional": true,
+            "field": "description",
+            "description": "<p>流描述</p>"
+          },
+          {
+            "group": "Parameter",
+            "type": "String",
+            "optional": true,
+            "field": "metadata",
+            "description": "<p>流的其他信息，可用于全文检索</p>"
+          },
+          {
+            "group": "Parameter",
+            "type": "Number",
+            "optional": true,
+            "field": "dataCapBytes",
+            "description": "<p>该路流向播放端发送的总字节数上限，达到后断开所有播放端并停止拉流。为0时使用配置的 stream_data_cap_bytes</p>"
+          },
+          {
+            "group": "Parameter",
+            "type": "Number",
+            "optional": true,
+            "field": "stallTimeout",
+            "description": "<p>该路流超过多少秒未收到数据时TEARDOWN并重新拉流，同一PATH的推流端也以此为准。为0时使用配置的 stall_timeout，为负数时不检测</p>"
           }
         ]
       }
@@ -630,10 +1720,11 @@ define({ "api": [
   },
   {
     "type": "get",
-    "url": "/api/v1/restart",
+    "url": "/api/v1/admin/restart",
     "title": "重启服务",
     "group": "sys",
     "name": "Restart",
+    "description": "<p>管理接口，见 http.tls_client_ca_file。原 /api/v1/restart 已移除。</p>",
     "version": "0.0.0",
     "filename": "routers/sys.go",
     "groupTitle": "系统",
@@ -647,6 +1738,52 @@ define({ "api": [
       ]
     }
   },
+  {
+    "type": "put",
+    "url": "/api/v1/admin/config/mdns",
+    "title": "开关mDNS服务发现",
+    "group": "sys",
+    "name": "SetMDNSConfig",
+    "description": "<p>开启后服务器在局域网内通过mDNS(Bonjour)广播 _rtsp._tcp 服务, TXT记录包含播放路径、版本与流数量。重启服务后恢复配置文件中的 mdns_enable。管理接口，见 http.tls_client_ca_file。</p>",
+    "parameter": {
+      "fields": {
+        "Parameter": [
+          {
+            "group": "Parameter",
+            "type": "Boolean",
+            "optional": false,
+            "field": "enabled",
+            "description": "<p>是否开启</p>"
+          }
+        ]
+      }
+    },
+    "success": {
+      "fields": {
+        "200": [
+          {
+            "group": "200",
+            "type": "Boolean",
+            "optional": false,
+            "field": "enabled",
+            "description": "<p>当前是否开启</p>"
+          }
+        ]
+      }
+    },
+    "version": "0.0.0",
+    "filename": "routers/config.go",
+    "groupTitle": "sys",
+    "error": {
+      "examples": [
+        {
+          "title": "认证失败",
+          "content": "HTTP/1.1 401 access denied",
+          "type": "json"
+        }
+      ]
+    }
+  },
   {
     "type": "get",
     "url": "/api/v1/userInfo",
@@ -683,5 +1820,271 @@ define({ "api": [
         ]
       }
     }
+  },
+  {
+    "type": "get",
+    "url": "/api/v1/version",
+    "title": "获取版本",
+    "group": "sys",
+    "name": "Version",
+    "success": {
+      "fields": {
+        "200": [
+          {
+            "group": "200",
+            "type": "String",
+            "optional": false,
+            "field": "version",
+            "description": "<p>版本号, 如 8.1.0</p>"
+          },
+          {
+            "group": "200",
+            "type": "String",
+            "optional": false,
+            "field": "buildTime",
+            "description": "<p>编译时间, 开发版本为空</p>"
+          },
+          {
+            "group": "200",
+            "type": "String",
+            "optional": false,
+            "field": "gitCommit",
+            "description": "<p>编译时的git提交, 开发版本为空</p>"
+          }
+        ]
+      }
+    },
+    "version": "0.0.0",
+    "filename": "routers/sys.go",
+    "groupTitle": "系统"
+  },
+  {
+    "type": "delete",
+    "url": "/api/v1/transcode/profile",
+    "title": "删除转码配置",
+    "group": "transcode",
+    "name": "TranscodeProfileDelete",
+    "parameter": {
+      "fields": {
+        "Parameter": [
+          {
+            "group": "Parameter",
+            "type": "Number",
+            "optional": false,
+            "field": "id",
+            "description": "<p>转码配置ID</p>"
+          }
+        ]
+      }
+    },
+    "version": "0.0.0",
+    "filename": "routers/transcode.go",
+    "groupTitle": "转码",
+    "success": {
+      "examples": [
+        {
+          "title": "成功",
+          "content": "HTTP/1.1 200 OK",
+          "type": "json"
+        }
+      ]
+    },
+    "error": {
+      "examples": [
+        {
+          "title": "认证失败",
+          "content": "HTTP/1.1 401 access denied",
+          "type": "json"
+        }
+      ]
+    }
+  },
+  {
+    "type": "get",
+    "url": "/api/v1/transcode/profile",
+    "title": "获取转码配置列表",
+    "group": "transcode",
+    "name": "TranscodeProfileList",
+    "parameter": {
+      "fields": {
+        "Parameter": [
+          {
+            "group": "Parameter",
+            "type": "String",
+            "optional": true,
+            "field": "path",
+            "description": "<p>源流的PATH, 为空时返回所有流的转码配置</p>"
+          }
+        ]
+      }
+    },
+    "success": {
+      "fields": {
+        "200": [
+          {
+            "group": "200",
+            "type": "Object[]",
+            "optional": false,
+            "field": "-",
+            "description": "<p>转码配置列表, 按源流PATH与码率排序</p>"
+          },
+          {
+            "group": "200",
+            "type": "Number",
+            "optional": false,
+            "field": "-.id",
+            "description": "<p>转码配置ID</p>"
+          },
+          {
+            "group": "200",
+            "type": "String",
+            "optional": false,
+            "field": "-.path",
+            "description": "<p>源流的PATH</p>"
+          },
+          {
+            "group": "200",
+            "type": "String",
+            "optional": false,
+            "field": "-.name",
+            "description": "<p>码率档位名称</p>"
+          },
+          {
+            "group": "200",
+            "type": "String",
+            "optional": false,
+            "field": "-.variantPath",
+            "description": "<p>转码后的流的PATH, 即 <path>_<name></p>"
+          },
+          {
+            "group": "200",
+            "type": "Number",
+            "optional": false,
+            "field": "-.width",
+            "description": "<p>宽度, 0为按高度等比缩放</p>"
+          },
+          {
+            "group": "200",
+            "type": "Number",
+            "optional": false,
+            "field": "-.height",
+            "description": "<p>高度, 0为不缩放</p>"
+          },
+          {
+            "group": "200",
+            "type": "Number",
+            "optional": false,
+            "field": "-.videoBitrate",
+            "description": "<p>视频码率, kbps</p>"
+          },
+          {
+            "group": "200",
+            "type": "Number",
+            "optional": false,
+            "field": "-.audioBitrate",
+            "description": "<p>音频码率, kbps</p>"
+          },
+          {
+            "group": "200",
+            "type": "Boolean",
+            "optional": false,
+            "field": "-.live",
+            "description": "<p>转码后的流是否在推流</p>"
+          }
+        ]
+      }
+    },
+    "version": "0.0.0",
+    "filename": "routers/transcode.go",
+    "groupTitle": "转码"
+  },
+  {
+    "type": "post",
+    "url": "/api/v1/transcode/profile",
+    "title": "添加或修改转码配置",
+    "group": "transcode",
+    "name": "TranscodeProfileSave",
+    "description": "<p>请求体为JSON。源流推流时, 每个转码配置由一个ffmpeg转码为H.264与AAC, 作为流 <path>_<name> 推回本服务,\n配置了 abr.hls_dir_path 时同时输出HLS, 各档位汇总在 /hls/<path>/master.m3u8 中供播放器自适应码率切换。\n添加、修改或删除一个档位时只启停该档位, 其他档位与源流的播放端不受影响。需要配置 rtsp.ffmpeg_path。</p>",
+    "parameter": {
+      "fields": {
+        "Parameter": [
+          {
+            "group": "Parameter",
+            "type": "Number",
+            "optional": true,
+            "field": "id",
+            "description": "<p>转码配置ID, 修改时填写</p>"
+          },
+          {
+            "group": "Parameter",
+            "type": "String",
+            "optional": false,
+            "field": "path",
+            "description": "<p>源流的PATH</p>"
+          },
+          {
+            "group": "Parameter",
+            "type": "String",
+            "optional": true,
+            "field": "name",
+            "description": "<p>码率档位名称, 只能包含字母、数字、-与_, 为空时为 <height>p, 如 720p</p>"
+          },
+          {
+            "group": "Parameter",
+            "type": "Number",
+            "optional": true,
+            "field": "width",
+            "description": "<p>宽度, 为0时按高度等比缩放</p>"
+          },
+          {
+            "group": "Parameter",
+            "type": "Number",
+            "optional": false,
+            "field": "height",
+            "description": "<p>高度</p>"
+          },
+          {
+            "group": "Parameter",
+            "type": "Number",
+            "optional": false,
+            "field": "videoBitrate",
+            "description": "<p>视频码率, kbps</p>"
+          },
+          {
+            "group": "Parameter",
+            "type": "Number",
+            "optional": true,
+            "field": "audioBitrate",
+            "defaultValue": "128",
+            "description": "<p>音频码率, kbps</p>"
+          }
+        ]
+      }
+    },
+    "success": {
+      "fields": {
+        "200": [
+          {
+            "group": "200",
+            "type": "Number",
+            "optional": false,
+            "field": "id",
+            "description": "<p>转码配置ID</p>"
+          }
+        ]
+      }
+    },
+    "version": "0.0.0",
+    "filename": "routers/transcode.go",
+    "groupTitle": "转码",
+    "error": {
+      "examples": [
+        {
+          "title": "认证失败",
+          "content": "HTTP/1.1 401 access denied",
+          "type": "json"
+        }
+      ]
+    }
   }
 ] });
@@ -3,11 +3,25 @@ define({
   "title": "EasyDarwin API Reference",
   "order": [
     "stats",
+    "Stats",
     "Pushers",
     "Players",
+    "SessionStats",
+    "AggregateStats",
+    "RedisSlowLog",
     "stream",
     "StreamStart",
     "StreamStop",
+    "StreamList",
+    "StreamAliasList",
+    "StreamAliasSave",
+    "StreamAliasDelete",
+    "transcode",
+    "TranscodeProfileList",
+    "TranscodeProfileSave",
+    "TranscodeProfileDelete",
+    "events",
+    "EventsSSE",
     "record",
     "RecordFolders",
     "RecordFiles",
@@ -16,7 +30,9 @@ define({
     "Logout",
     "GetUserInfo",
     "ModifyPassword",
-    "GetServerInfo"
+    "GetServerInfo",
+    "Version",
+    "SetMDNSConfig"
   ],
   "version": "8.1.0",
   "description": "EasyDarwin Open Source Media Server",
@@ -25,7 +41,7 @@ define({
   "apidoc": "0.3.0",
   "generator": {
     "name": "apidoc",
-    "time": "2019-01-13T08:33:51.329Z",
+    "time": "2026-10-16T08:32:47.000Z",
     "url": "http://apidocjs.com",
     "version": "0.17.7"
   }